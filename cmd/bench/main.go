@@ -0,0 +1,50 @@
+// Command bench runs DistriChat's benchmark suite (see internal/bench) and
+// writes its report to stdout, for comparing against earlier releases.
+//
+//	bench [-format json|csv] > bench.json
+//
+// The workload is seeded, so two reports differ only by the code under test
+// and timing noise. SIGINT or SIGTERM stops it.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/sh4shv4t/DistriChat/internal/bench"
+	"github.com/sh4shv4t/DistriChat/pkg/logging"
+)
+
+func main() {
+	format := flag.String("format", "json", "Report format: json or csv")
+	seed := flag.Int64("seed", 1, "Workload seed")
+	flag.Parse()
+	if *format != "json" && *format != "csv" {
+		fmt.Fprintf(os.Stderr, "bench: unknown -format %q (want json or csv)\n", *format)
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// The servers' logs would drown the report
+	logging.Setup(logging.Config{Output: io.Discard})
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	config := bench.DefaultConfig()
+	config.Seed = *seed
+	report, err := bench.Run(ctx, config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "bench: %v\n", err)
+		os.Exit(1)
+	}
+	if err := report.Write(os.Stdout, *format); err != nil {
+		fmt.Fprintf(os.Stderr, "bench: %v\n", err)
+		os.Exit(1)
+	}
+}
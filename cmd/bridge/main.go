@@ -0,0 +1,107 @@
+// Command bridge runs a federation bridge between DistriChat clusters,
+// relaying the messages of the chats its routes join.
+//
+//	bridge [-port 50060] -coordinator acme=acme-coord:50050 \
+//	    -servers globex=globex-1:50051,globex-2:50051 \
+//	    -route partners=acme/acme-globex,globex/partners/acme
+//
+// Each cluster is named and reached through its coordinator (-coordinator)
+// or by following its servers (-servers); both flags repeat. Each -route
+// joins one chat per cluster, written CLUSTER/CHAT (the chat ID may itself
+// contain slashes). More routes can be added over FederationService. SIGINT
+// or SIGTERM stops it. Logs go to stderr, as JSON unless LOG_FORMAT=text,
+// at LOG_LEVEL (default: info).
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/internal/bridge"
+	"github.com/sh4shv4t/DistriChat/pkg/logging"
+)
+
+func main() {
+	var clusters []bridge.ClusterConfig
+	var routes []bridge.Route
+	port := flag.Int("port", 50060, "FederationService port")
+	pollInterval := flag.Duration("poll-interval", time.Second, "How often shared chats are checked for new messages")
+	historyWindow := flag.Int("history-window", 100, "Most recent messages read per chat and poll")
+	flag.Func("coordinator", "NAME=ADDRESS: a cluster reached through its coordinator (repeatable)", func(value string) error {
+		name, address, ok := strings.Cut(value, "=")
+		if !ok || name == "" || address == "" {
+			return fmt.Errorf("expected NAME=ADDRESS, got %q", value)
+		}
+		clusters = append(clusters, bridge.ClusterConfig{Name: name, Coordinator: address})
+		return nil
+	})
+	flag.Func("servers", "NAME=ADDRESS,...: a cluster reached through its servers (repeatable)", func(value string) error {
+		name, addresses, ok := strings.Cut(value, "=")
+		if !ok || name == "" || addresses == "" {
+			return fmt.Errorf("expected NAME=ADDRESS,..., got %q", value)
+		}
+		clusters = append(clusters, bridge.ClusterConfig{Name: name, Servers: strings.Split(addresses, ",")})
+		return nil
+	})
+	flag.Func("route", "NAME=CLUSTER/CHAT,CLUSTER/CHAT,...: chats to join (repeatable)", func(value string) error {
+		route, err := parseRoute(value)
+		if err != nil {
+			return err
+		}
+		routes = append(routes, route)
+		return nil
+	})
+	flag.Parse()
+	if len(clusters) < 2 {
+		fmt.Fprintln(os.Stderr, "bridge: at least two clusters (-coordinator or -servers) are required")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	logging.Setup(logging.Config{Format: os.Getenv("LOG_FORMAT")})
+	defer logging.HandleSignals()()
+
+	b := bridge.NewBridge(bridge.BridgeConfig{
+		Port:          *port,
+		Clusters:      clusters,
+		PollInterval:  *pollInterval,
+		HistoryWindow: *historyWindow,
+	})
+	if err := b.Start(); err != nil {
+		fmt.Fprintf(os.Stderr, "bridge: %v\n", err)
+		os.Exit(1)
+	}
+	defer b.Stop()
+	for _, route := range routes {
+		if err := b.AddRoute(route); err != nil {
+			fmt.Fprintf(os.Stderr, "bridge: %v\n", err)
+			os.Exit(2)
+		}
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	<-signals
+}
+
+// parseRoute parses NAME=CLUSTER/CHAT,CLUSTER/CHAT,...
+func parseRoute(value string) (bridge.Route, error) {
+	name, endpoints, ok := strings.Cut(value, "=")
+	if !ok || name == "" {
+		return bridge.Route{}, fmt.Errorf("expected NAME=CLUSTER/CHAT,..., got %q", value)
+	}
+	route := bridge.Route{Name: name}
+	for _, endpoint := range strings.Split(endpoints, ",") {
+		cluster, chatID, ok := strings.Cut(endpoint, "/")
+		if !ok || cluster == "" || chatID == "" {
+			return bridge.Route{}, fmt.Errorf("route %s: expected CLUSTER/CHAT, got %q", name, endpoint)
+		}
+		route.Endpoints = append(route.Endpoints, bridge.Endpoint{Cluster: cluster, ChatID: chatID})
+	}
+	return route, nil
+}
@@ -0,0 +1,74 @@
+// Command coordinator runs the control plane of a DistriChat cluster: the
+// authoritative ring servers register with (serverd -coordinator) and
+// clients and servers follow.
+//
+//	coordinator [-port 50050] [-dashboard-port 8080] [-replicas 3] [-rebalance]
+//
+// With -lease-duration, servers hold ownership leases and accept writes
+// only for their leased ranges (see CoordinatorConfig.LeaseDuration). With
+// -rebalance, sessions are moved to their new owners after every topology
+// change, at most -rebalance-bandwidth bytes per second. Members' admin
+// services are sent the DISTRICHAT_ADMIN_TOKEN environment variable, if
+// set, to stream their stats to the dashboard. SIGINT or SIGTERM stops it.
+// Logs go to stderr, as JSON unless LOG_FORMAT=text, at LOG_LEVEL
+// (default: info).
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/internal/coordinator"
+	"github.com/sh4shv4t/DistriChat/pkg/logging"
+	"github.com/sh4shv4t/DistriChat/pkg/rebalance"
+)
+
+func main() {
+	port := flag.Int("port", 50050, "Coordinator service port")
+	dashboardPort := flag.Int("dashboard-port", 0, "Web dashboard port (0: none)")
+	heartbeatTimeout := flag.Duration("heartbeat-timeout", 5*time.Second, "How long a silent server stays in the ring")
+	checkInterval := flag.Duration("check-interval", time.Second, "How often members are probed")
+	virtualNodes := flag.Int("virtual-nodes", 100, "Virtual nodes of servers registering without a capacity")
+	replicas := flag.Int("replicas", 1, "Replicas per chat and region; should match the servers'")
+	leaseDuration := flag.Duration("lease-duration", 0, "Ownership lease duration; must exceed the servers' heartbeat interval (0: no leases)")
+	rebalanceSessions := flag.Bool("rebalance", false, "Move sessions to their new owners after topology changes")
+	rebalanceBandwidth := flag.Int64("rebalance-bandwidth", 1<<20, "Bytes per second sessions are moved at")
+	flag.Parse()
+	if *replicas < 1 {
+		fmt.Fprintln(os.Stderr, "coordinator: -replicas must be at least 1")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	logging.Setup(logging.Config{Format: os.Getenv("LOG_FORMAT")})
+	defer logging.HandleSignals()()
+
+	config := coordinator.CoordinatorConfig{
+		Port:              *port,
+		DashboardPort:     *dashboardPort,
+		HeartbeatTimeout:  *heartbeatTimeout,
+		CheckInterval:     *checkInterval,
+		VirtualNodes:      *virtualNodes,
+		ReplicationFactor: *replicas,
+		LeaseDuration:     *leaseDuration,
+		AdminToken:        os.Getenv("DISTRICHAT_ADMIN_TOKEN"),
+	}
+	if *rebalanceSessions {
+		config.Rebalance = &rebalance.Config{BytesPerSecond: *rebalanceBandwidth}
+	}
+
+	coord := coordinator.NewCoordinator(config)
+	if err := coord.Start(); err != nil {
+		fmt.Fprintf(os.Stderr, "coordinator: %v\n", err)
+		os.Exit(1)
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	<-signals
+	coord.Stop()
+}
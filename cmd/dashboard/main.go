@@ -0,0 +1,71 @@
+// Command dashboard watches a running DistriChat cluster in the terminal
+// (see internal/dashboard).
+//
+//	dashboard -server server-a=localhost:50051,localhost:50151 \
+//	    -server server-b=localhost:50052,localhost:50152
+//
+// Each -server names a server and gives its chat address, for the ring
+// view, and its admin address, for the stats stream; the flag repeats. The
+// admin services are sent the DISTRICHAT_ADMIN_TOKEN environment variable,
+// if set. q, Ctrl+C, SIGINT or SIGTERM stops it. Logs go to stderr, as JSON
+// unless LOG_FORMAT=text, at LOG_LEVEL (default: info).
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/internal/dashboard"
+	"github.com/sh4shv4t/DistriChat/pkg/logging"
+)
+
+func main() {
+	var targets []dashboard.Target
+	interval := flag.Duration("interval", time.Second, "How often servers report and the ring is refreshed")
+	flag.Func("server", "NAME=CHAT_ADDRESS,ADMIN_ADDRESS: a server to watch (repeatable)", func(value string) error {
+		target, err := parseTarget(value)
+		if err != nil {
+			return err
+		}
+		targets = append(targets, target)
+		return nil
+	})
+	flag.Parse()
+	if len(targets) == 0 {
+		fmt.Fprintln(os.Stderr, "dashboard: at least one -server is required")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	logging.Setup(logging.Config{Format: os.Getenv("LOG_FORMAT")})
+	defer logging.HandleSignals()()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	dash := dashboard.NewDashboard(dashboard.DashboardConfig{
+		Servers:    targets,
+		AdminToken: os.Getenv("DISTRICHAT_ADMIN_TOKEN"),
+		Interval:   *interval,
+	})
+	if err := dash.Run(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "dashboard: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// parseTarget parses NAME=CHAT_ADDRESS,ADMIN_ADDRESS
+func parseTarget(value string) (dashboard.Target, error) {
+	name, addresses, ok := strings.Cut(value, "=")
+	chat, admin, _ := strings.Cut(addresses, ",")
+	if !ok || name == "" || chat == "" || admin == "" {
+		return dashboard.Target{}, fmt.Errorf("expected NAME=CHAT_ADDRESS,ADMIN_ADDRESS, got %q", value)
+	}
+	return dashboard.Target{Name: name, Address: chat, AdminAddress: admin}, nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	pb "github.com/sh4shv4t/DistriChat/proto"
+)
+
+// runAudit prints the audit events of one or more servers, oldest first:
+//
+//	districhatctl audit [--since 24h] [--action admin_call,drain] [--target Drain]
+//	    [--actor A] [--outcome failed] [--limit 100] ADMIN_ADDRESS...
+func runAudit(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("audit", flag.ContinueOnError)
+	token := flags.String("token", os.Getenv("DISTRICHAT_ADMIN_TOKEN"), "Admin token")
+	since := flags.Duration("since", 24*time.Hour, "How far back to look (0: everything)")
+	actions := flags.String("action", "", "Comma-separated actions to show, e.g. admin_call,drain (default: all)")
+	target := flags.String("target", "", "Only events with this target, e.g. an admin method such as Drain")
+	actor := flags.String("actor", "", "Only events by this actor")
+	outcome := flags.String("outcome", "", "Only events with this outcome: ok, denied, rejected or failed")
+	limit := flags.Int("limit", 100, "Events to show per server")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		return fmt.Errorf("expected at least one admin address")
+	}
+
+	ctx, conns, err := dialAdmin(ctx, *token, flags.Args())
+	if err != nil {
+		return err
+	}
+	defer closeAll(conns)
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := &pb.AuditLogQuery{Actor: *actor, Target: *target, Outcome: *outcome, Limit: int32(*limit)}
+	if *since > 0 {
+		query.SinceMs = time.Now().Add(-*since).UnixMilli()
+	}
+	if *actions != "" {
+		query.Actions = strings.Split(*actions, ",")
+	}
+
+	var events []*pb.AuditEvent
+	for i, conn := range conns {
+		resp, err := pb.NewAdminServiceClient(conn).QueryAuditLog(ctx, query)
+		if err != nil {
+			return fmt.Errorf("%s: %w", flags.Arg(i), err)
+		}
+		events = append(events, resp.Events...)
+		if resp.More {
+			fmt.Fprintf(os.Stderr, "%s has more events; narrow the query or raise --limit\n", flags.Arg(i))
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].TimestampMs < events[j].TimestampMs })
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tSERVER\tACTOR\tACTION\tTARGET\tOUTCOME\tDETAILS")
+	for _, e := range events {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", time.UnixMilli(e.TimestampMs).Format(time.RFC3339),
+			e.ServerId, e.Actor, e.Action, orDash(e.Target), e.Outcome, orDash(formatDetails(e)))
+	}
+	return w.Flush()
+}
+
+// formatDetails joins an event's reason and details as key=value pairs
+func formatDetails(e *pb.AuditEvent) string {
+	var parts []string
+	if e.Reason != "" {
+		parts = append(parts, fmt.Sprintf("reason=%q", e.Reason))
+	}
+	keys := make([]string, 0, len(e.Details))
+	for k := range e.Details {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		parts = append(parts, k+"="+e.Details[k])
+	}
+	return strings.Join(parts, " ")
+}
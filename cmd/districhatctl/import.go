@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/sh4shv4t/DistriChat/pkg/client"
+	"github.com/sh4shv4t/DistriChat/pkg/importer"
+	"google.golang.org/grpc/metadata"
+)
+
+// runImport migrates a chat export into the cluster:
+//
+//	districhatctl import [--coordinator ADDR] [--namespace N] [--format jsonl]
+//	    [--replicas 1] [--batch 500] [--token T] [--dry-run] FILE [CHAT_ADDRESS...]
+//
+// FILE - reads the export from stdin. Like search it talks to the servers'
+// chat ports, finding them through the coordinator or the ring of any
+// server given. --replicas must match the servers' replication factor.
+// Servers only take imports from their peers, so a cluster with an admin
+// token needs it passed.
+func runImport(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	coordinator := flags.String("coordinator", "", "Coordinator to find the servers through")
+	namespace := flags.String("namespace", "", "Namespace to import the chats into")
+	format := flags.String("format", "jsonl", "Export format: "+strings.Join(importer.Formats, ", "))
+	replicas := flags.Int("replicas", 1, "Copies of each chat the servers keep per region")
+	batch := flags.Int("batch", 500, "Messages sent per snapshot")
+	token := flags.String("token", os.Getenv("DISTRICHAT_ADMIN_TOKEN"), "Admin token")
+	dryRun := flags.Bool("dry-run", false, "Read the export and report what would be imported, without importing")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		return fmt.Errorf("no export file given")
+	}
+	servers := flags.Args()[1:]
+	if *coordinator == "" && len(servers) == 0 && !*dryRun {
+		return fmt.Errorf("expected --coordinator or at least one chat address")
+	}
+
+	var in io.Reader = os.Stdin
+	if name := flags.Arg(0); name != "-" {
+		file, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		in = file
+	}
+	reader, err := importer.NewReader(*format, in)
+	if err != nil {
+		return err
+	}
+	records, err := importer.ReadAll(reader)
+	if err != nil {
+		return fmt.Errorf("%s: %w", flags.Arg(0), err)
+	}
+	sessions := importer.Sessions(records)
+	if *dryRun {
+		fmt.Printf("Would import %d messages in %d chats\n", len(records), len(sessions))
+		return nil
+	}
+
+	config := client.DefaultClientConfig()
+	config.Namespace = *namespace
+	c := client.NewSmartClient(config)
+	defer c.Close()
+	if *coordinator != "" {
+		err = c.FollowCoordinator(*coordinator)
+	} else {
+		err = c.FollowServers(servers...)
+	}
+	if err != nil {
+		return err
+	}
+
+	if *token != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, adminTokenHeader, *token)
+	}
+	im := importer.New(importer.Config{Namespace: *namespace, Replicas: *replicas, BatchSize: *batch})
+	defer im.Close()
+	result, err := im.Import(ctx, c.RingState(), sessions)
+	fmt.Printf("Imported %d chats (%d messages) to %d servers, %d messages new to them\n",
+		result.Sessions, len(records), result.Servers, result.Messages)
+	if len(result.Refused) > 0 {
+		fmt.Fprintf(os.Stderr, "Refused %d chats already written to, which can't take numbered history:\n", len(result.Refused))
+		for _, chatID := range result.Refused {
+			fmt.Fprintf(os.Stderr, "  %s\n", chatID)
+		}
+	}
+	if len(result.Failed) > 0 {
+		failed := make([]string, 0, len(result.Failed))
+		for id := range result.Failed {
+			failed = append(failed, id)
+		}
+		sort.Strings(failed)
+		fmt.Fprintf(os.Stderr, "Failed on %d servers, rerun to finish:\n", len(failed))
+		for _, id := range failed {
+			fmt.Fprintf(os.Stderr, "  %s: %v\n", id, result.Failed[id])
+		}
+	}
+	return err
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	pb "github.com/sh4shv4t/DistriChat/proto"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// runInspect prints each server's debug dump: configuration, ring view,
+// resident sessions, peer connections, gossip members and recent errors
+func runInspect(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("inspect", flag.ContinueOnError)
+	sessions := flags.Int("sessions", 20, "Resident sessions to list (0: all)")
+	asJSON := flags.Bool("json", false, "Print the raw dump as JSON")
+	token := flags.String("token", os.Getenv("DISTRICHAT_ADMIN_TOKEN"), "Admin token")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		return fmt.Errorf("no admin addresses given")
+	}
+
+	ctx, conns, err := dialAdmin(ctx, *token, flags.Args())
+	if err != nil {
+		return err
+	}
+	defer closeAll(conns)
+
+	for i, conn := range conns {
+		callCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		state, err := pb.NewAdminServiceClient(conn).DebugState(callCtx, &pb.DebugStateRequest{MaxSessions: int32(*sessions)})
+		cancel()
+		if err != nil {
+			return fmt.Errorf("%s: %w", flags.Arg(i), err)
+		}
+
+		if *asJSON {
+			out, err := protojson.MarshalOptions{Multiline: true}.Marshal(state)
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(out))
+			continue
+		}
+		if i > 0 {
+			fmt.Println()
+		}
+		printDebugState(os.Stdout, state)
+	}
+	return nil
+}
+
+// printDebugState writes a debug dump as sections of tables
+func printDebugState(w io.Writer, s *pb.ServerDebugState) {
+	fmt.Fprintf(w, "Server %s (%s) %s, up %s\n", s.ServerId, s.Address, stateLabel(s.State),
+		time.Duration(s.UptimeSeconds)*time.Second)
+
+	section(w, "Config", "SETTING\tVALUE", func(row rowFunc) {
+		keys := make([]string, 0, len(s.Config))
+		for key := range s.Config {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			row("%s\t%s", key, orDash(s.Config[key]))
+		}
+	})
+
+	ring := s.GetRing()
+	section(w, fmt.Sprintf("Ring (epoch %d, digest %s)", ring.GetEpoch(), orDash(ring.GetDigest())),
+		"NODE\tADDRESS\tWEIGHT\tREGION\tNAMESPACE", func(row rowFunc) {
+			for _, n := range ring.GetNodes() {
+				row("%s\t%s\t%d\t%s\t%s", n.NodeId, n.Address, n.Weight, orDash(n.Region), orDash(n.Namespace))
+			}
+		})
+
+	section(w, fmt.Sprintf("Sessions (%d resident, %d shown)", s.TotalSessions, len(s.Sessions)),
+		"CHAT\tTIER\tMESSAGES\tLAST SEQ\tLAST ACCESS", func(row rowFunc) {
+			for _, session := range s.Sessions {
+				lastAccess := "shared"
+				if session.LastAccessedMs > 0 {
+					lastAccess = time.UnixMilli(session.LastAccessedMs).Format(time.TimeOnly)
+				}
+				row("%s\t%s\t%d\t%d\t%s", session.ChatId, session.Tier, session.Messages,
+					session.LastSeq, lastAccess)
+			}
+		})
+
+	section(w, "Peer connections", "ADDRESS\tSTATE", func(row rowFunc) {
+		for _, c := range s.Connections {
+			row("%s\t%s", c.Address, c.State)
+		}
+	})
+
+	if len(s.Members) > 0 {
+		section(w, "Gossip members", "ID\tADDRESS\tSTATE\tINCARNATION", func(row rowFunc) {
+			for _, m := range s.Members {
+				row("%s\t%s\t%s\t%d", m.Id, m.Address,
+					strings.TrimPrefix(m.State.String(), "MEMBER_STATE_"), m.Incarnation)
+			}
+		})
+	}
+
+	section(w, "Recent errors", "TIME\tLEVEL\tMESSAGE\tATTRIBUTES", func(row rowFunc) {
+		for _, r := range s.RecentErrors {
+			row("%s\t%s\t%s\t%s", time.UnixMilli(r.TimestampMs).Format(time.TimeOnly),
+				r.Level, r.Message, formatAttrs(r.Attrs))
+		}
+	})
+}
+
+// section writes a titled, indented table whose rows are added by rows
+func section(w io.Writer, title, header string, rows func(row rowFunc)) {
+	fmt.Fprintf(w, "\n%s\n", title)
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "  "+header)
+	rows(func(format string, args ...any) {
+		fmt.Fprintf(tw, "  "+format+"\n", args...)
+	})
+	tw.Flush()
+}
+
+// rowFunc adds a row to a table, its cells separated by tabs
+type rowFunc func(format string, args ...any)
+
+// formatAttrs returns attributes as sorted key=value pairs
+func formatAttrs(attrs map[string]string) string {
+	pairs := make([]string, 0, len(attrs))
+	for key, value := range attrs {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, " ")
+}
+
+// orDash returns s, or "-" if it is empty
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
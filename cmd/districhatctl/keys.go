@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	pb "github.com/sh4shv4t/DistriChat/proto"
+)
+
+// runKeys manages a server's API keys:
+//
+//	districhatctl keys list [--tenant T | --all] ADMIN_ADDRESS
+//	districhatctl keys create --principal P [--tenant T] [--scopes chat:read,chat:write] ADMIN_ADDRESS
+//	districhatctl keys revoke --id ID ADMIN_ADDRESS
+func runKeys(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("expected list, create or revoke")
+	}
+	action := args[0]
+	flags := flag.NewFlagSet("keys "+action, flag.ContinueOnError)
+	token := flags.String("token", os.Getenv("DISTRICHAT_ADMIN_TOKEN"), "Admin token")
+	tenant := flags.String("tenant", "", "Tenant (namespace) of the keys")
+	all := flags.Bool("all", false, "List every tenant's keys")
+	principal := flags.String("principal", "", "Principal calls with a new key are made as")
+	scopes := flags.String("scopes", "chat:read,chat:write", "Comma-separated scopes of a new key")
+	description := flags.String("description", "", "Description of a new key")
+	id := flags.String("id", "", "ID of the key to revoke")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("expected one admin address")
+	}
+
+	ctx, conns, err := dialAdmin(ctx, *token, flags.Args())
+	if err != nil {
+		return err
+	}
+	defer closeAll(conns)
+	admin := pb.NewAdminServiceClient(conns[0])
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	switch action {
+	case "list":
+		resp, err := admin.ListAPIKeys(ctx, &pb.ListAPIKeysRequest{Tenant: *tenant, AllTenants: *all})
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tTENANT\tPRINCIPAL\tSCOPES\tCREATED\tREVOKED\tDESCRIPTION")
+		for _, key := range resp.Keys {
+			revoked := "-"
+			if key.RevokedMs > 0 {
+				revoked = time.UnixMilli(key.RevokedMs).Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", key.Id, orDash(key.Tenant), key.Principal,
+				orDash(strings.Join(key.Scopes, ",")), time.UnixMilli(key.CreatedMs).Format(time.RFC3339),
+				revoked, orDash(key.Description))
+		}
+		return w.Flush()
+	case "create":
+		req := &pb.CreateAPIKeyRequest{Tenant: *tenant, Principal: *principal, Description: *description}
+		if *scopes != "" {
+			req.Scopes = strings.Split(*scopes, ",")
+		}
+		resp, err := admin.CreateAPIKey(ctx, req)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Created key %s; send it as x-api-key (it isn't shown again):\n%s\n", resp.Key.Id, resp.Secret)
+		return nil
+	case "revoke":
+		key, err := admin.RevokeAPIKey(ctx, &pb.RevokeAPIKeyRequest{Id: *id})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Revoked key %s of %s\n", key.Id, key.Principal)
+		return nil
+	default:
+		return fmt.Errorf("unknown action %q; expected list, create or revoke", action)
+	}
+}
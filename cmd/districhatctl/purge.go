@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	pb "github.com/sh4shv4t/DistriChat/proto"
+)
+
+// runPurgeUser purges a user's messages from every server of the cluster,
+// through the server at ADMIN_ADDRESS, or shows or resumes an earlier
+// purge:
+//
+//	districhatctl purge-user [--reason R] [--wait] USER ADMIN_ADDRESS
+//	districhatctl purge-user --job ID [--resume] [--wait] ADMIN_ADDRESS
+func runPurgeUser(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("purge-user", flag.ContinueOnError)
+	token := flags.String("token", os.Getenv("DISTRICHAT_ADMIN_TOKEN"), "Admin token")
+	reason := flags.String("reason", "", "Why the user is purged, e.g. a request reference, for the audit log")
+	jobID := flags.String("job", "", "Show the purge job with this ID instead of starting one")
+	resume := flags.Bool("resume", false, "Resume the --job, retrying the servers that failed")
+	wait := flags.Bool("wait", false, "Wait for the job to finish")
+	interval := flags.Duration("interval", time.Second, "How often to check on the job with --wait")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	want := 2
+	if *jobID != "" {
+		want = 1
+	}
+	if flags.NArg() != want {
+		if want == 1 {
+			return fmt.Errorf("expected one admin address")
+		}
+		return fmt.Errorf("expected a user and one admin address")
+	}
+
+	ctx, conns, err := dialAdmin(ctx, *token, flags.Args()[want-1:])
+	if err != nil {
+		return err
+	}
+	defer closeAll(conns)
+	admin := pb.NewAdminServiceClient(conns[0])
+
+	call := func(f func(ctx context.Context) (*pb.PurgeJob, error)) (*pb.PurgeJob, error) {
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		return f(ctx)
+	}
+	job, err := call(func(ctx context.Context) (*pb.PurgeJob, error) {
+		switch {
+		case *jobID == "":
+			return admin.PurgeUser(ctx, &pb.PurgeUserRequest{UserId: flags.Arg(0), Reason: *reason})
+		case *resume:
+			return admin.PurgeUser(ctx, &pb.PurgeUserRequest{JobId: *jobID})
+		default:
+			return admin.GetPurgeJob(ctx, &pb.GetPurgeJobRequest{JobId: *jobID})
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	for *wait && job.State == pb.PurgeJobState_PURGE_JOB_RUNNING {
+		select {
+		case <-time.After(*interval):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		id := job.Id
+		job, err = call(func(ctx context.Context) (*pb.PurgeJob, error) {
+			return admin.GetPurgeJob(ctx, &pb.GetPurgeJobRequest{JobId: id})
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return printPurgeJob(job)
+}
+
+// printPurgeJob writes a purge job and each server's part in it to stdout
+func printPurgeJob(job *pb.PurgeJob) error {
+	fmt.Printf("Job %s: %s of %s, %d/%d servers done, %d messages purged\n", job.Id,
+		job.State, job.UserId, job.ServersDone, len(job.Servers), job.MessagesPurged)
+	fmt.Printf("Messages sent up to %s; started %s, updated %s\n\n",
+		time.UnixMilli(job.BeforeMs).Format(time.RFC3339), time.UnixMilli(job.CreatedMs).Format(time.RFC3339),
+		time.UnixMilli(job.UpdatedMs).Format(time.RFC3339))
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SERVER\tDONE\tCHATS\tMESSAGES\tATTEMPTS\tERROR")
+	for _, p := range job.Servers {
+		fmt.Fprintf(w, "%s\t%t\t%d\t%d\t%d\t%s\n", p.ServerId, p.Done, p.Chats, p.Messages, p.Attempts, orDash(p.Error))
+	}
+	return w.Flush()
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/pkg/client"
+)
+
+// runSearch runs a full-text query on every server of the cluster and
+// prints the merged matches, best first:
+//
+//	districhatctl search [--coordinator ADDR] [--namespace N] [--chat C1,C2]
+//	    [--sender S] [--limit 20] [--api-key K] QUERY [CHAT_ADDRESS...]
+//
+// Unlike the other commands it talks to the servers' chat ports, finding
+// them through the coordinator or the ring of any server given.
+func runSearch(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("search", flag.ContinueOnError)
+	coordinator := flags.String("coordinator", "", "Coordinator to find the servers through")
+	namespace := flags.String("namespace", "", "Namespace to search")
+	chats := flags.String("chat", "", "Comma-separated chats to search (default: all)")
+	sender := flags.String("sender", "", "Only messages from this sender")
+	limit := flags.Int("limit", 20, "Matches to show")
+	apiKey := flags.String("api-key", os.Getenv("DISTRICHAT_API_KEY"), "API key, for servers requiring one")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		return fmt.Errorf("no query given")
+	}
+	servers := flags.Args()[1:]
+	if *coordinator == "" && len(servers) == 0 {
+		return fmt.Errorf("expected --coordinator or at least one chat address")
+	}
+
+	config := client.DefaultClientConfig()
+	config.APIKey = *apiKey
+	config.Namespace = *namespace
+	c := client.NewSmartClient(config)
+	defer c.Close()
+	var err error
+	if *coordinator != "" {
+		err = c.FollowCoordinator(*coordinator)
+	} else {
+		err = c.FollowServers(servers...)
+	}
+	if err != nil {
+		return err
+	}
+
+	query := client.SearchQuery{Text: flags.Arg(0), SenderID: *sender, Limit: *limit}
+	if *chats != "" {
+		query.ChatIDs = strings.Split(*chats, ",")
+	}
+	result, err := c.Search(query)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SCORE\tTIME\tCHAT\tSEQ\tSENDER\tTEXT")
+	for _, hit := range result.Hits {
+		fmt.Fprintf(w, "%.2f\t%s\t%s\t%d\t%s\t%s\n", hit.Score, time.Unix(hit.Timestamp, 0).Format(time.RFC3339),
+			hit.ChatId, hit.Seq, hit.SenderId, hit.Text)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if result.Partial() {
+		failed := make([]string, 0, len(result.Failed))
+		for id := range result.Failed {
+			failed = append(failed, id)
+		}
+		sort.Strings(failed)
+		fmt.Fprintf(os.Stderr, "Partial results: %d servers answered, %d did not\n", result.Servers, len(failed))
+		for _, id := range failed {
+			fmt.Fprintf(os.Stderr, "  %s: %v\n", id, result.Failed[id])
+		}
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/internal/statsdiff"
+	pb "github.com/sh4shv4t/DistriChat/proto"
+	"google.golang.org/grpc"
+)
+
+// runStats prints each server's cumulative statistics, or with --watch
+// polls them every interval and prints what happened in each interval
+func runStats(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("stats", flag.ContinueOnError)
+	watch := flags.Bool("watch", false, "Print per-second rates every interval instead of totals")
+	interval := flags.Duration("interval", time.Second, "How often to poll with --watch")
+	token := flags.String("token", os.Getenv("DISTRICHAT_ADMIN_TOKEN"), "Admin token")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		return fmt.Errorf("no admin addresses given")
+	}
+	if *interval <= 0 {
+		return fmt.Errorf("interval must be positive")
+	}
+
+	ctx, conns, err := dialAdmin(ctx, *token, flags.Args())
+	if err != nil {
+		return err
+	}
+	defer closeAll(conns)
+
+	if !*watch {
+		printTotals(os.Stdout, flags.Args(), fetchSnapshots(ctx, conns))
+		return nil
+	}
+
+	tracker := statsdiff.NewTracker()
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	for {
+		now := time.Now()
+		var rows []rateRow
+		for i, snapshot := range fetchSnapshots(ctx, conns) {
+			address := flags.Arg(i)
+			if snapshot == nil {
+				tracker.Forget(address)
+				rows = append(rows, rateRow{address: address, down: true})
+				continue
+			}
+			delta, ok := tracker.Observe(address, statsdiff.FromServer(now, snapshot))
+			rows = append(rows, rateRow{address: address, snapshot: snapshot, delta: delta, warm: ok})
+		}
+		printRates(os.Stdout, now, rows)
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// fetchSnapshots asks every server for its stats; a server that doesn't
+// answer gets nil
+func fetchSnapshots(ctx context.Context, conns []*grpc.ClientConn) []*pb.StatsSnapshot {
+	snapshots := make([]*pb.StatsSnapshot, len(conns))
+	for i, conn := range conns {
+		callCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+		snapshot, err := pb.NewAdminServiceClient(conn).GetStatsSnapshot(callCtx, &pb.StatsSnapshotRequest{})
+		cancel()
+		if err == nil {
+			snapshots[i] = snapshot
+		}
+	}
+	return snapshots
+}
+
+// printTotals writes the servers' cumulative counters as a table
+func printTotals(w io.Writer, addresses []string, snapshots []*pb.StatsSnapshot) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SERVER\tSTATE\tREQUESTS\tHITS\tMISSES\tHIT%\tEVICTIONS\tSLOW")
+	for i, s := range snapshots {
+		if s == nil {
+			fmt.Fprintf(tw, "%s\tDOWN\t-\t-\t-\t-\t-\t-\n", addresses[i])
+			continue
+		}
+		hitRatio := 0.0
+		if lookups := s.CacheHits + s.CacheMisses; lookups > 0 {
+			hitRatio = float64(s.CacheHits) / float64(lookups)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\t%.1f\t%d\t%d\n", s.ServerId, stateLabel(s.State), s.TotalRequests,
+			s.CacheHits, s.CacheMisses, 100*hitRatio, s.Evictions, s.SlowRequests)
+	}
+	tw.Flush()
+}
+
+// rateRow is one server's line in a --watch table
+type rateRow struct {
+	address  string
+	down     bool
+	snapshot *pb.StatsSnapshot
+	delta    statsdiff.Delta
+	warm     bool // delta holds an interval; false on the first poll
+}
+
+// printRates writes the servers' rates over the last interval as a table
+func printRates(w io.Writer, at time.Time, rows []rateRow) {
+	fmt.Fprintf(w, "\n%s\n", at.Format("15:04:05"))
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SERVER\tSTATE\tREQ/S\tHITS/S\tMISSES/S\tHIT%\tEVICTIONS/S\tSLOW/S")
+	for _, row := range rows {
+		switch {
+		case row.down:
+			fmt.Fprintf(tw, "%s\tDOWN\t-\t-\t-\t-\t-\t-\n", row.address)
+		case !row.warm:
+			fmt.Fprintf(tw, "%s\t%s\t-\t-\t-\t-\t-\t-\n", row.snapshot.ServerId, stateLabel(row.snapshot.State))
+		default:
+			d := row.delta
+			fmt.Fprintf(tw, "%s\t%s\t%.1f\t%.1f\t%.1f\t%.1f\t%.1f\t%.1f\n", row.snapshot.ServerId, stateLabel(row.snapshot.State),
+				d.Rate(statsdiff.Requests), d.Rate(statsdiff.Hits), d.Rate(statsdiff.Misses),
+				100*d.HitRatio(), d.Rate(statsdiff.Evictions), d.Rate(statsdiff.SlowRequests))
+		}
+	}
+	tw.Flush()
+}
+
+// stateLabel returns a server state without its enum prefix
+func stateLabel(state pb.ServerState) string {
+	return strings.TrimPrefix(state.String(), "SERVER_STATE_")
+}
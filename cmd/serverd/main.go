@@ -0,0 +1,202 @@
+// Command serverd runs one DistriChat chat server as its own process, for
+// clusters of separate processes (the simulation's -processes mode, or by
+// hand).
+//
+//	serverd -id Server-A -port 50051 [-admin-port 50151] [-l1 5] [-l2 20]
+//
+// With -tls-cert, -tls-key and -tls-ca the chat port requires mutual TLS;
+// the files are reread as they change, so certificates rotate in place.
+// With -api-keys, clients authenticate with API keys kept in that file and
+// issued with districhatctl keys. With -jwks-url, they may instead present
+// bearer tokens from an OIDC provider, checked against its published keys.
+// With -audit-log, admin calls and security events are appended to that
+// file (see districhatctl audit) rather than kept in memory. With
+// -purge-jobs, user purges started on this server (see districhatctl
+// purge-user) are kept in that file and resumed after a restart. With
+// -dedup-log, the IDs of messages stored are kept in that file for
+// -dedup-window, so a write retried after a restart isn't stored twice.
+// With -outbox, messages accepted but not yet delivered are kept in that
+// file and delivered after a crash; with -encryption-keys too, they are
+// encrypted under that keyring (see encryption.LoadKeyring).
+// With -metrics-port, metrics are served on that port, and with -graphql
+// the read API too, as GraphQL on /graphql.
+// SIGINT or SIGTERM stops it gracefully, cutting off requests still in
+// flight after -shutdown-timeout; SIGKILL is a crash. Logs go to
+// stderr, as JSON unless LOG_FORMAT=text, at LOG_LEVEL (default: info).
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/pkg/audit"
+	"github.com/sh4shv4t/DistriChat/pkg/auth"
+	"github.com/sh4shv4t/DistriChat/pkg/dedup"
+	"github.com/sh4shv4t/DistriChat/pkg/encryption"
+	"github.com/sh4shv4t/DistriChat/pkg/logging"
+	"github.com/sh4shv4t/DistriChat/pkg/mtls"
+	"github.com/sh4shv4t/DistriChat/pkg/outbox"
+	"github.com/sh4shv4t/DistriChat/pkg/purge"
+	"github.com/sh4shv4t/DistriChat/pkg/server"
+)
+
+func main() {
+	id := flag.String("id", "", "Server ID (required)")
+	port := flag.Int("port", 50051, "Chat service port")
+	adminPort := flag.Int("admin-port", 0, "Admin service port (0: none)")
+	metricsPort := flag.Int("metrics-port", 0, "Metrics port, serving /metrics and /debug/vars (0: none)")
+	graphQL := flag.Bool("graphql", false, "Also serve the read API as GraphQL on the metrics port's /graphql")
+	l1 := flag.Int("l1", 5, "L1 cache capacity, in sessions")
+	l2 := flag.Int("l2", 20, "L2 cache capacity, in sessions")
+	coordinator := flag.String("coordinator", "", "Coordinator address to join (default: none)")
+	capacity := flag.Int("capacity", 0, "Virtual nodes to register with the coordinator (default: the coordinator's)")
+	shutdownTimeout := flag.Duration("shutdown-timeout", 30*time.Second, "How long to wait for in-flight requests on shutdown")
+	tlsCert := flag.String("tls-cert", "", "Certificate presented to clients and peers, PEM (default: plaintext)")
+	tlsKey := flag.String("tls-key", "", "Private key of -tls-cert, PEM")
+	tlsCA := flag.String("tls-ca", "", "CAs client and peer certificates must chain to, PEM")
+	auditLog := flag.String("audit-log", "", "File the audit log is appended to (default: in memory)")
+	purgeJobs := flag.String("purge-jobs", "", "File user purge jobs are kept in, to resume them after a restart (default: in memory)")
+	dedupLog := flag.String("dedup-log", "", "File the IDs of stored messages are kept in, to refuse duplicates after a restart (default: in memory)")
+	dedupWindow := flag.Duration("dedup-window", 24*time.Hour, "How long a stored message's ID is remembered")
+	outboxFile := flag.String("outbox", "", "File accepted messages are kept in until delivered, to deliver them after a crash (default: in memory)")
+	encryptionKeys := flag.String("encryption-keys", "", "JSON keyring the messages written to -outbox are encrypted under (default: unencrypted)")
+	apiKeys := flag.String("api-keys", "", "File of API keys clients must present (default: none required)")
+	jwksURL := flag.String("jwks-url", "", "JWKS URL of the identity provider whose tokens clients may present (default: none)")
+	jwtIssuer := flag.String("jwt-issuer", "", "Issuer (iss) tokens must name (default: any)")
+	jwtAudience := flag.String("jwt-audience", "", "Audience (aud) tokens must name (default: any)")
+	jwtTenantClaim := flag.String("jwt-tenant-claim", "", "Claim naming the tenant a token is limited to (default: unlimited)")
+	flag.Parse()
+	if *id == "" {
+		fmt.Fprintln(os.Stderr, "serverd: -id is required")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	logging.Setup(logging.Config{Format: os.Getenv("LOG_FORMAT")})
+	defer logging.HandleSignals()()
+
+	var opts []server.Option
+	if *tlsCert != "" || *tlsKey != "" || *tlsCA != "" {
+		provider, err := mtls.NewProvider(mtls.Config{CertFile: *tlsCert, KeyFile: *tlsKey, CAFile: *tlsCA},
+			mtls.WithLogger(logging.Logger("tls")))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "serverd: %v\n", err)
+			os.Exit(2)
+		}
+		opts = append(opts, server.WithTLS(provider))
+	}
+	var events audit.Log
+	if *auditLog != "" {
+		log, err := audit.OpenFile(*auditLog)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "serverd: %v\n", err)
+			os.Exit(2)
+		}
+		defer log.Close()
+		events = log
+	}
+	var keys auth.KeyStore
+	if *apiKeys != "" {
+		store, err := auth.OpenKeyStore(*apiKeys)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "serverd: %v\n", err)
+			os.Exit(2)
+		}
+		keys = store
+	}
+	var jobs purge.Store
+	if *purgeJobs != "" {
+		store, err := purge.OpenStore(*purgeJobs)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "serverd: %v\n", err)
+			os.Exit(2)
+		}
+		jobs = store
+	}
+	var seen dedup.Store
+	if *dedupLog != "" {
+		store, err := dedup.OpenFile(*dedupLog)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "serverd: %v\n", err)
+			os.Exit(2)
+		}
+		defer store.Close()
+		seen = store
+	}
+	if *encryptionKeys != "" && *outboxFile == "" {
+		fmt.Fprintln(os.Stderr, "serverd: -encryption-keys encrypts -outbox, which isn't set")
+		os.Exit(2)
+	}
+	var box outbox.Store
+	if *outboxFile != "" {
+		var opts []outbox.Option
+		if *encryptionKeys != "" {
+			keyring, err := encryption.LoadKeyring(*encryptionKeys)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "serverd: %v\n", err)
+				os.Exit(2)
+			}
+			opts = append(opts, outbox.WithEncrypter(encryption.New(keyring)))
+		}
+		store, err := outbox.OpenFile(*outboxFile, opts...)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "serverd: %v\n", err)
+			os.Exit(2)
+		}
+		defer store.Close()
+		box = store
+	}
+	var verifier *auth.JWTVerifier
+	if *jwksURL != "" {
+		v, err := auth.NewJWTVerifier(auth.JWTConfig{
+			JWKSURL:     *jwksURL,
+			Issuer:      *jwtIssuer,
+			Audience:    *jwtAudience,
+			TenantClaim: *jwtTenantClaim,
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "serverd: %v\n", err)
+			os.Exit(2)
+		}
+		verifier = v
+	}
+
+	srv := server.NewChatServer(server.ServerConfig{
+		ServerID:    *id,
+		Port:        *port,
+		AdminPort:   *adminPort,
+		MetricsPort: *metricsPort,
+		GraphQL:     *graphQL,
+		L1Capacity:  *l1,
+		L2Capacity:  *l2,
+		Coordinator: *coordinator,
+		Capacity:    *capacity,
+		AdminToken:  os.Getenv("DISTRICHAT_ADMIN_TOKEN"),
+		APIKeys:     keys,
+		JWT:         verifier,
+		AuditLog:    events,
+		PurgeJobs:   jobs,
+		DedupStore:  seen,
+		DedupWindow: *dedupWindow,
+		Outbox:      box,
+	}, opts...)
+	if err := srv.Start(); err != nil {
+		fmt.Fprintf(os.Stderr, "serverd: %v\n", err)
+		os.Exit(1)
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	<-signals
+
+	ctx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "serverd: shutdown: %v\n", err)
+	}
+}
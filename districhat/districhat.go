@@ -0,0 +1,374 @@
+// Package districhat embeds DistriChat in a Go program. A Cluster starts
+// chat servers in the process, on free local ports, and a Client routing
+// chats to them by consistent hashing, all with the simulation's defaults,
+// so a program needs a few lines where it would otherwise wire the ring,
+// cache, client and server packages together itself:
+//
+//	cluster, err := districhat.NewCluster(districhat.Config{Servers: 3})
+//	if err != nil {
+//		return err
+//	}
+//	defer cluster.Close()
+//
+//	reply, err := cluster.Client().Send("chat-1", "alice", "Hello!")
+//	if err != nil {
+//		return err
+//	}
+//	fmt.Println("stored by", reply.ServerId)
+//
+// Servers and clients can also run apart: a Server in each process, and
+// Clients given the servers' Nodes. Settings the package doesn't cover are
+// on the pkg/server and pkg/client types underneath, reached with
+// Server.ChatServer and Client.SmartClient.
+package districhat
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net"
+
+	"github.com/sh4shv4t/DistriChat/pkg/chaterr"
+	"github.com/sh4shv4t/DistriChat/pkg/client"
+	"github.com/sh4shv4t/DistriChat/pkg/mtls"
+	"github.com/sh4shv4t/DistriChat/pkg/ring"
+	"github.com/sh4shv4t/DistriChat/pkg/server"
+	pb "github.com/sh4shv4t/DistriChat/proto"
+)
+
+// Defaults of the settings left at zero
+const (
+	DefaultServers    = 3
+	DefaultWeight     = 100
+	DefaultL1Capacity = 5
+	DefaultL2Capacity = 20
+)
+
+// Errors clients return, matched with errors.Is; a server's refusal is a
+// *chaterr.Rejection
+var (
+	ErrNoServers         = chaterr.ErrNoServers
+	ErrAllReplicasFailed = chaterr.ErrAllReplicasFailed
+	ErrNotOwner          = chaterr.ErrNotOwner
+	ErrRateLimited       = chaterr.ErrRateLimited
+	ErrDraining          = chaterr.ErrDraining
+)
+
+// Reply is a server's answer to a message: the server that stored it,
+// where the chat's session was found, and the chat's message count
+type Reply = pb.ChatResponse
+
+// Message is a stored message, as read back from a chat's history
+type Message = pb.StoredMessage
+
+// Stats are a client's request counts: successes, failures and failovers
+type Stats = client.ClientStats
+
+// Node is a server as clients see it
+type Node struct {
+	ID      string
+	Address string // host:port of its chat service
+	Weight  int    // Virtual nodes, so its share of the chats (default: 100)
+}
+
+// ServerConfig describes a server
+type ServerConfig struct {
+	ID      string // default: its address
+	Address string // host:port to listen on (default: a free port on localhost)
+	Weight  int    // Virtual nodes, so its share of the chats (default: 100)
+
+	// Cache capacities, in sessions (defaults: 5 and 20)
+	L1Capacity int
+	L2Capacity int
+
+	// Replicas is how many servers keep each chat (default: 1). Above one,
+	// the servers must know each other's Nodes, as a Cluster's do.
+	Replicas int
+
+	// TLS, if set, requires mutual TLS from clients and peers
+	TLS *mtls.Provider
+
+	// PeerSecret, if set, is shared by the cluster's servers to recognize
+	// each other's replication calls. Without it, a peer's certificate must
+	// name the peer's ID; a certificate clients present too doesn't.
+	PeerSecret string
+}
+
+// Server is a chat server
+type Server struct {
+	config  ServerConfig
+	address string
+	chat    *server.ChatServer
+}
+
+// NewServer creates a server; Start starts it
+func NewServer(config ServerConfig) *Server {
+	if config.Address == "" {
+		config.Address = "localhost:0"
+	}
+	if config.Weight <= 0 {
+		config.Weight = DefaultWeight
+	}
+	if config.L1Capacity <= 0 {
+		config.L1Capacity = DefaultL1Capacity
+	}
+	if config.L2Capacity <= 0 {
+		config.L2Capacity = DefaultL2Capacity
+	}
+	return &Server{config: config}
+}
+
+// Start listens on the server's address and starts serving
+func (s *Server) Start() error {
+	listener, err := net.Listen("tcp", s.config.Address)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.config.Address, err)
+	}
+	s.address = listener.Addr().String()
+	if s.config.ID == "" {
+		s.config.ID = s.address
+	}
+
+	s.chat = server.NewChatServer(server.ServerConfig{
+		ServerID:         s.config.ID,
+		AdvertiseAddress: s.address,
+		Listener:         listener,
+		L1Capacity:       s.config.L1Capacity,
+		L2Capacity:       s.config.L2Capacity,
+		Capacity:         s.config.Weight,
+		Replication:      server.ReplicationConfig{N: s.config.Replicas},
+		TLS:              s.config.TLS,
+		AdminToken:       s.config.PeerSecret,
+	})
+	if err := s.chat.Start(); err != nil {
+		listener.Close()
+		return fmt.Errorf("failed to start %s: %w", s.config.ID, err)
+	}
+	return nil
+}
+
+// Stop stops the server gracefully. Clients fail its chats over to the
+// next servers on the ring.
+func (s *Server) Stop() {
+	if s.chat != nil {
+		s.chat.Stop()
+	}
+}
+
+// ID returns the server's ID
+func (s *Server) ID() string {
+	return s.config.ID
+}
+
+// Address returns the host:port the server listens on, once started
+func (s *Server) Address() string {
+	return s.address
+}
+
+// Node returns the server as clients see it
+func (s *Server) Node() Node {
+	return Node{ID: s.config.ID, Address: s.address, Weight: s.config.Weight}
+}
+
+// ChatServer returns the server underneath, once started
+func (s *Server) ChatServer() *server.ChatServer {
+	return s.chat
+}
+
+// ClientConfig describes a client
+type ClientConfig struct {
+	Nodes    []Node // Servers to route to
+	Replicas int    // Copies the servers keep of each chat (their Replicas, default: 1)
+
+	// TLS, if set, connects over mutual TLS, as the servers require when
+	// they have TLS set
+	TLS *mtls.Provider
+}
+
+// Client sends messages to the server owning each chat, failing over to
+// the next servers on the ring when it is down. It is safe for concurrent
+// use.
+type Client struct {
+	smart *client.SmartClient
+}
+
+// NewClient creates a client routing to config's servers
+func NewClient(config ClientConfig) *Client {
+	clientConfig := client.DefaultClientConfig()
+	clientConfig.ReplicationFactor = config.Replicas
+	clientConfig.TLS = config.TLS
+	smart := client.NewSmartClient(clientConfig)
+	smart.ApplyRingState(ringState(config.Nodes))
+	return &Client{smart: smart}
+}
+
+// Send sends a message to a chat, returning the reply of the server that
+// stored it
+func (c *Client) Send(chatID, senderID, text string) (*Reply, error) {
+	return c.smart.SendMessage(chatID, senderID, text)
+}
+
+// History returns a chat's last limit messages, oldest first (all of them
+// if limit <= 0)
+func (c *Client) History(chatID string, limit int) ([]*Message, error) {
+	resp, err := c.smart.GetHistory(chatID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Messages, nil
+}
+
+// Owner returns the ID of the server a chat's messages go to while it is
+// up
+func (c *Client) Owner(chatID string) string {
+	id, _, _ := c.smart.GetTargetServer(chatID)
+	return id
+}
+
+// Stats returns the client's request counts so far
+func (c *Client) Stats() Stats {
+	return c.smart.GetStats()
+}
+
+// SmartClient returns the client underneath
+func (c *Client) SmartClient() *client.SmartClient {
+	return c.smart
+}
+
+// Close closes the client's connections
+func (c *Client) Close() {
+	c.smart.Close()
+}
+
+// Config describes a cluster
+type Config struct {
+	Servers  int // Servers to start, called server-1, server-2 and so on (default: 3)
+	Weight   int // Virtual nodes of each server (default: 100)
+	Replicas int // Servers keeping each chat; writes need a majority of them up (default: 1)
+
+	// Cache capacities per server, in sessions (defaults: 5 and 20)
+	L1Capacity int
+	L2Capacity int
+
+	// TLS, if set, secures the cluster with mutual TLS: its servers and
+	// clients all present this certificate, which must be valid for
+	// localhost as both a server and a client. The servers recognize each
+	// other by a secret the cluster generates, not by the certificate.
+	TLS *mtls.Provider
+}
+
+// Cluster is a set of servers started in the process, and a client routing
+// to them
+type Cluster struct {
+	servers  []*Server
+	replicas int
+	tls      *mtls.Provider
+	client   *Client
+}
+
+// NewCluster starts a cluster. Close stops it.
+func NewCluster(config Config) (*Cluster, error) {
+	if config.Servers <= 0 {
+		config.Servers = DefaultServers
+	}
+	if config.Replicas > config.Servers {
+		return nil, fmt.Errorf("can't keep %d replicas of each chat on %d servers", config.Replicas, config.Servers)
+	}
+
+	secret, err := peerSecret()
+	if err != nil {
+		return nil, err
+	}
+	c := &Cluster{replicas: config.Replicas, tls: config.TLS}
+	for i := 1; i <= config.Servers; i++ {
+		srv := NewServer(ServerConfig{
+			ID:         fmt.Sprintf("server-%d", i),
+			Weight:     config.Weight,
+			L1Capacity: config.L1Capacity,
+			L2Capacity: config.L2Capacity,
+			Replicas:   config.Replicas,
+			TLS:        config.TLS,
+			PeerSecret: secret,
+		})
+		if err := srv.Start(); err != nil {
+			c.Close()
+			return nil, err
+		}
+		c.servers = append(c.servers, srv)
+	}
+
+	// The servers need the ring to find each chat's replicas
+	state := ringState(c.Nodes())
+	for _, srv := range c.servers {
+		srv.chat.SetRingState(state)
+	}
+	c.client = c.NewClient()
+	return c, nil
+}
+
+// peerSecret generates the secret a cluster's servers share
+func peerSecret() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate the peer secret: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Client returns the cluster's client
+func (c *Cluster) Client() *Client {
+	return c.client
+}
+
+// NewClient creates another client of the cluster, e.g. one per user;
+// the caller closes it
+func (c *Cluster) NewClient() *Client {
+	return NewClient(ClientConfig{Nodes: c.Nodes(), Replicas: c.replicas, TLS: c.tls})
+}
+
+// Servers returns the cluster's servers, server-1 first
+func (c *Cluster) Servers() []*Server {
+	return c.servers
+}
+
+// Server returns the server called id, or nil
+func (c *Cluster) Server(id string) *Server {
+	for _, srv := range c.servers {
+		if srv.ID() == id {
+			return srv
+		}
+	}
+	return nil
+}
+
+// Nodes returns the cluster's servers as clients see them
+func (c *Cluster) Nodes() []Node {
+	nodes := make([]Node, len(c.servers))
+	for i, srv := range c.servers {
+		nodes[i] = srv.Node()
+	}
+	return nodes
+}
+
+// Close stops the client and the servers
+func (c *Cluster) Close() {
+	if c.client != nil {
+		c.client.Close()
+	}
+	for _, srv := range c.servers {
+		srv.Stop()
+	}
+}
+
+// ringState is the ring of nodes, weights defaulted
+func ringState(nodes []Node) ring.RingState {
+	state := ring.RingState{Epoch: 1}
+	for _, n := range nodes {
+		weight := n.Weight
+		if weight <= 0 {
+			weight = DefaultWeight
+		}
+		state.Nodes = append(state.Nodes, ring.NodeSpec{NodeID: n.ID, Address: n.Address, Capacity: weight})
+	}
+	return state
+}
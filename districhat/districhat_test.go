@@ -0,0 +1,236 @@
+package districhat
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/pkg/client"
+	"github.com/sh4shv4t/DistriChat/pkg/mtls"
+	pb "github.com/sh4shv4t/DistriChat/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestClusterSendsAndReadsBack(t *testing.T) {
+	cluster, err := NewCluster(Config{})
+	if err != nil {
+		t.Fatalf("Expected the cluster to start, got %v", err)
+	}
+	defer cluster.Close()
+
+	if got := len(cluster.Servers()); got != DefaultServers {
+		t.Errorf("Expected %d servers by default, got %d", DefaultServers, got)
+	}
+	for i := 1; i <= 3; i++ {
+		reply, err := cluster.Client().Send("chat-1", "alice", fmt.Sprintf("Hello %d", i))
+		if err != nil {
+			t.Fatalf("Expected message %d sent, got %v", i, err)
+		}
+		if owner := cluster.Client().Owner("chat-1"); reply.ServerId != owner {
+			t.Errorf("Expected the owner %s to store message %d, got %s", owner, i, reply.ServerId)
+		}
+		if reply.MessageCount != int32(i) {
+			t.Errorf("Expected %d messages in the chat, got %d", i, reply.MessageCount)
+		}
+	}
+
+	history, err := cluster.Client().History("chat-1", 0)
+	if err != nil {
+		t.Fatalf("Expected the history, got %v", err)
+	}
+	if len(history) != 3 || history[2].GetRequest().GetText() != "Hello 3" {
+		t.Errorf("Expected the 3 messages back, last \"Hello 3\", got %v", history)
+	}
+}
+
+func TestClusterFailsOver(t *testing.T) {
+	cluster, err := NewCluster(Config{Servers: 3, Replicas: 3})
+	if err != nil {
+		t.Fatalf("Expected the cluster to start, got %v", err)
+	}
+	defer cluster.Close()
+
+	client := cluster.NewClient()
+	defer client.Close()
+	if _, err := client.Send("chat-1", "alice", "Before"); err != nil {
+		t.Fatalf("Expected the message sent, got %v", err)
+	}
+
+	owner := client.Owner("chat-1")
+	cluster.Server(owner).Stop()
+	reply, err := client.Send("chat-1", "alice", "After")
+	if err != nil {
+		t.Fatalf("Expected the message to fail over, got %v", err)
+	}
+	if reply.ServerId == owner {
+		t.Errorf("Expected a server other than the stopped %s, got it", owner)
+	}
+	if reply.MessageCount != 2 {
+		t.Errorf("Expected the replica to hold both messages, got %d", reply.MessageCount)
+	}
+	if stats := client.Stats(); stats.FailoverCount != 1 {
+		t.Errorf("Expected 1 failover, got %d", stats.FailoverCount)
+	}
+}
+
+func TestStandaloneServerAndClient(t *testing.T) {
+	srv := NewServer(ServerConfig{ID: "solo"})
+	if err := srv.Start(); err != nil {
+		t.Fatalf("Expected the server to start, got %v", err)
+	}
+	defer srv.Stop()
+
+	client := NewClient(ClientConfig{Nodes: []Node{srv.Node()}})
+	defer client.Close()
+	reply, err := client.Send("chat-1", "alice", "Hello")
+	if err != nil {
+		t.Fatalf("Expected the message sent, got %v", err)
+	}
+	if reply.ServerId != "solo" {
+		t.Errorf("Expected solo to store it, got %s", reply.ServerId)
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	client := NewClient(ClientConfig{})
+	defer client.Close()
+	if _, err := client.Send("chat-1", "alice", "Hello"); !errors.Is(err, ErrNoServers) {
+		t.Errorf("Expected ErrNoServers without servers, got %v", err)
+	}
+
+	srv := NewServer(ServerConfig{ID: "solo"})
+	if err := srv.Start(); err != nil {
+		t.Fatalf("Expected the server to start, got %v", err)
+	}
+	defer srv.Stop()
+	srv.ChatServer().Drain("test")
+
+	client = NewClient(ClientConfig{Nodes: []Node{srv.Node()}})
+	defer client.Close()
+	_, err := client.Send("chat-1", "alice", "Hello")
+	if !errors.Is(err, ErrAllReplicasFailed) || !errors.Is(err, ErrDraining) {
+		t.Errorf("Expected ErrAllReplicasFailed and ErrDraining from a draining server, got %v", err)
+	}
+}
+
+func TestClusterOverMutualTLS(t *testing.T) {
+	provider, err := mtls.NewProvider(writeTestCertificates(t))
+	if err != nil {
+		t.Fatalf("Expected the certificates loaded, got %v", err)
+	}
+	cluster, err := NewCluster(Config{Servers: 3, Replicas: 3, TLS: provider})
+	if err != nil {
+		t.Fatalf("Expected the cluster to start, got %v", err)
+	}
+	defer cluster.Close()
+
+	reply, err := cluster.Client().Send("chat-1", "alice", "Hello")
+	if err != nil {
+		t.Fatalf("Expected the message sent over mutual TLS, got %v", err)
+	}
+	if reply.MessageCount != 1 {
+		t.Errorf("Expected 1 message in the chat, got %d", reply.MessageCount)
+	}
+
+	plain := client.NewSmartClient(client.ClientConfig{ConnectTimeout: 200 * time.Millisecond})
+	defer plain.Close()
+	plain.ApplyRingState(ringState(cluster.Nodes()))
+	if _, err := plain.SendMessage("chat-1", "mallory", "Hello"); err == nil {
+		t.Errorf("Expected a client without a certificate to be refused")
+	}
+
+	// The clients' certificate doesn't make them peers
+	conn, err := grpc.Dial(cluster.Servers()[0].Address(), grpc.WithTransportCredentials(provider.ClientCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_, err = pb.NewChatServiceClient(conn).Replicate(context.Background(), &pb.ReplicateRequest{Message: &pb.StoredMessage{MessageId: "m-1"}})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Errorf("Expected a client's Replicate to be denied, got %v", err)
+	}
+}
+
+// writeTestCertificates writes a CA and a certificate it signed, valid for
+// localhost as a server and a client, returning the provider config
+func writeTestCertificates(t *testing.T) mtls.Config {
+	t.Helper()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "node"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, caTemplate, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	config := mtls.Config{
+		CertFile: filepath.Join(dir, "node.crt"),
+		KeyFile:  filepath.Join(dir, "node.key"),
+		CAFile:   filepath.Join(dir, "ca.crt"),
+	}
+	files := map[string]*pem.Block{
+		config.CertFile: {Type: "CERTIFICATE", Bytes: der},
+		config.KeyFile:  {Type: "EC PRIVATE KEY", Bytes: keyDER},
+		config.CAFile:   {Type: "CERTIFICATE", Bytes: caDER},
+	}
+	for name, block := range files {
+		if err := os.WriteFile(name, pem.EncodeToMemory(block), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return config
+}
+
+func TestTooManyReplicas(t *testing.T) {
+	if _, err := NewCluster(Config{Servers: 2, Replicas: 3}); err == nil {
+		t.Errorf("Expected an error for 3 replicas on 2 servers")
+	}
+}
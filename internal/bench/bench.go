@@ -0,0 +1,350 @@
+// Package bench is DistriChat's end-to-end benchmark runner. It compares
+// routing strategies on their own, and runs whole clusters in memory under
+// different cache and capacity settings, reporting hit rates, latency
+// percentiles and the keys moved on scale events as JSON or CSV so results
+// can be tracked between releases.
+package bench
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/internal/keyspace"
+	"github.com/sh4shv4t/DistriChat/pkg/chattest"
+	"github.com/sh4shv4t/DistriChat/pkg/client"
+	"github.com/sh4shv4t/DistriChat/pkg/metrics"
+	"github.com/sh4shv4t/DistriChat/pkg/server"
+	"github.com/sh4shv4t/DistriChat/pkg/sim"
+)
+
+// Case is one end-to-end run: a cluster served in memory and a workload
+// sent through a SmartClient
+type Case struct {
+	Name string
+
+	// Ring weight (virtual nodes) of each server (default: 100, 100, 100)
+	Capacities []int
+	L1Capacity int // Sessions per server (defaults: the server's)
+	L2Capacity int
+
+	Chats        int    // Distinct chats (default: 200)
+	Messages     int    // Messages sent (default: 2000)
+	Distribution string // "zipf" (a few hot chats, default), "uniform", "hotspot" or "sequential" (see keyspace)
+	Concurrency  int    // Senders in parallel (default: 8)
+
+	// ScaleOut adds a server with the first server's capacity halfway
+	// through, counting the chats whose owner changes
+	ScaleOut bool
+}
+
+// withDefaults fills unset values
+func (c Case) withDefaults() Case {
+	if len(c.Capacities) == 0 {
+		c.Capacities = []int{100, 100, 100}
+	}
+	if c.Chats <= 0 {
+		c.Chats = 200
+	}
+	if c.Messages <= 0 {
+		c.Messages = 2000
+	}
+	if c.Distribution == "" {
+		c.Distribution = "zipf"
+	}
+	if c.Concurrency <= 0 {
+		c.Concurrency = 8
+	}
+	return c
+}
+
+// Result is what a Case measured
+type Result struct {
+	Name         string `json:"name"`
+	VirtualNodes int    `json:"virtual_nodes"` // Across the servers started with
+	Servers      int    `json:"servers"`
+	L1Capacity   int    `json:"l1_capacity"`
+	L2Capacity   int    `json:"l2_capacity"`
+	Chats        int    `json:"chats"`
+	Messages     int    `json:"messages"`
+	Distribution string `json:"distribution"`
+
+	Errors     int     `json:"errors"`
+	Failovers  int64   `json:"failovers"`
+	HitRate    float64 `json:"hit_rate"`    // Cache hits over lookups, across servers
+	L1HitRate  float64 `json:"l1_hit_rate"` // L1 hits over lookups
+	Evictions  int64   `json:"evictions"`
+	Throughput float64 `json:"throughput"` // Messages per second
+
+	P50Ms float64 `json:"p50_ms"`
+	P99Ms float64 `json:"p99_ms"`
+	MaxMs float64 `json:"max_ms"`
+
+	// Fraction of chats whose owner changed on scale-out (ScaleOut only)
+	MovedOnScaleOut float64 `json:"moved_on_scale_out"`
+}
+
+// Config is a benchmark suite
+type Config struct {
+	Seed        int64 // Workload seed (default: 1), fixed so runs are comparable
+	RoutingKeys int   // Keys sampled per routing case (default: 100000)
+	Routing     []RoutingCase
+	Cases       []Case
+}
+
+// Report is the outcome of a suite
+type Report struct {
+	Started   time.Time       `json:"started"`
+	GoVersion string          `json:"go_version"`
+	Seed      int64           `json:"seed"`
+	Routing   []RoutingResult `json:"routing"`
+	Runs      []Result        `json:"runs"`
+}
+
+// DefaultConfig is the standard suite: modulo hashing against the ring at
+// several virtual node counts, and end-to-end runs varying one setting at a
+// time from a baseline
+func DefaultConfig() Config {
+	equal := func(vnodes int) []int { return []int{vnodes, vnodes, vnodes, vnodes} }
+	return Config{
+		Routing: []RoutingCase{
+			{Name: "modulo", Strategy: "modulo", Capacities: equal(1)},
+			{Name: "ring-vnodes-1", Strategy: "consistent", Capacities: equal(1)},
+			{Name: "ring-vnodes-10", Strategy: "consistent", Capacities: equal(10)},
+			{Name: "ring-vnodes-100", Strategy: "consistent", Capacities: equal(100)},
+			{Name: "ring-vnodes-500", Strategy: "consistent", Capacities: equal(500)},
+			{Name: "ring-weighted", Strategy: "consistent", Capacities: []int{50, 100, 150, 200}},
+		},
+		Cases: []Case{
+			{Name: "baseline", ScaleOut: true},
+			{Name: "vnodes-10", Capacities: []int{10, 10, 10}, ScaleOut: true},
+			{Name: "uniform", Distribution: "uniform"},
+			{Name: "hotspot", Distribution: "hotspot"},
+			{Name: "large-l1", L1Capacity: 20, L2Capacity: 20},
+			{Name: "large-l2", L1Capacity: 5, L2Capacity: 60},
+			{Name: "small-cache", L1Capacity: 2, L2Capacity: 5},
+			{Name: "weighted", Capacities: []int{50, 100, 200}},
+		},
+	}
+}
+
+// Run executes the suite
+func Run(ctx context.Context, config Config) (Report, error) {
+	if config.Seed == 0 {
+		config.Seed = 1
+	}
+	if config.RoutingKeys <= 0 {
+		config.RoutingKeys = 100000
+	}
+
+	report := Report{Started: time.Now(), GoVersion: runtime.Version(), Seed: config.Seed}
+	for _, c := range config.Routing {
+		report.Routing = append(report.Routing, runRouting(c, config.RoutingKeys))
+	}
+	for _, c := range config.Cases {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		result, err := RunCase(ctx, c, config.Seed)
+		if err != nil {
+			return report, fmt.Errorf("case %s: %w", c.Name, err)
+		}
+		report.Runs = append(report.Runs, result)
+	}
+	return report, nil
+}
+
+// RunCase starts the case's cluster in memory, sends its workload and
+// measures it
+func RunCase(ctx context.Context, c Case, seed int64) (Result, error) {
+	c = c.withDefaults()
+	network := chattest.NewNetwork()
+
+	var servers []*server.ChatServer
+	defer func() {
+		for _, srv := range servers {
+			srv.Stop()
+		}
+	}()
+	startServer := func(id string) error {
+		srv := server.NewChatServer(server.ServerConfig{
+			ServerID:         id,
+			AdvertiseAddress: id,
+			Listener:         network.Listen(id),
+			Dialer:           network.Dial,
+			L1Capacity:       c.L1Capacity,
+			L2Capacity:       c.L2Capacity,
+			Metrics:          metrics.Nop(),
+		})
+		if err := srv.Start(); err != nil {
+			return err
+		}
+		servers = append(servers, srv)
+		return nil
+	}
+
+	cl := client.NewSmartClient(client.ClientConfig{Dialer: network.Dial})
+	defer cl.Close()
+
+	for i, capacity := range c.Capacities {
+		id := fmt.Sprintf("server-%d", i+1)
+		if err := startServer(id); err != nil {
+			return Result{}, err
+		}
+		cl.AddServer(id, id, capacity)
+	}
+
+	chats, err := workload(c, seed)
+	if err != nil {
+		return Result{}, err
+	}
+	result := Result{
+		Name:         c.Name,
+		Servers:      len(c.Capacities),
+		Chats:        c.Chats,
+		Messages:     c.Messages,
+		Distribution: c.Distribution,
+	}
+	for _, capacity := range c.Capacities {
+		result.VirtualNodes += capacity
+	}
+
+	start := time.Now()
+	latencies := make([]time.Duration, 0, len(chats))
+	half := len(chats) / 2
+	if !c.ScaleOut {
+		half = len(chats)
+	}
+	lat, errs := send(ctx, cl, chats[:half], c.Concurrency)
+	latencies, result.Errors = append(latencies, lat...), result.Errors+errs
+
+	if c.ScaleOut {
+		before := owners(cl, c.Chats)
+		id := fmt.Sprintf("server-%d", len(c.Capacities)+1)
+		if err := startServer(id); err != nil {
+			return Result{}, err
+		}
+		cl.AddServer(id, id, c.Capacities[0])
+		after := owners(cl, c.Chats)
+
+		moved := 0
+		for chat, owner := range before {
+			if after[chat] != owner {
+				moved++
+			}
+		}
+		result.MovedOnScaleOut = float64(moved) / float64(c.Chats)
+		result.Servers++
+
+		lat, errs := send(ctx, cl, chats[half:], c.Concurrency)
+		latencies, result.Errors = append(latencies, lat...), result.Errors+errs
+	}
+	elapsed := time.Since(start)
+
+	result.Throughput = float64(len(chats)) / elapsed.Seconds()
+	result.Failovers = cl.GetStats().FailoverCount
+	result.P50Ms, result.P99Ms, result.MaxMs = percentiles(latencies)
+
+	var hits, l1Hits, misses int64
+	for _, srv := range servers {
+		info := srv.GetCacheInfo()
+		result.L1Capacity, result.L2Capacity = info.L1Capacity, info.L2Capacity
+		hits += info.Stats.CacheHits
+		l1Hits += info.Stats.L1Hits
+		misses += info.Stats.CacheMisses
+		result.Evictions += info.Stats.Evictions
+	}
+	if lookups := hits + misses; lookups > 0 {
+		result.HitRate = float64(hits) / float64(lookups)
+		result.L1HitRate = float64(l1Hits) / float64(lookups)
+	}
+	return result, nil
+}
+
+// workload returns the chat each message goes to, drawn from the case's
+// distribution
+func workload(c Case, seed int64) ([]string, error) {
+	keys, err := keyspace.New(keyspace.Config{
+		Distribution: keyspace.Distribution(c.Distribution),
+		Keys:         c.Chats,
+	}, sim.NewRand(seed))
+	if err != nil {
+		return nil, err
+	}
+
+	chats := make([]string, c.Messages)
+	for i := range chats {
+		chats[i] = fmt.Sprintf("chat-%04d", keys.Next())
+	}
+	return chats, nil
+}
+
+// send posts a message to each of chats from concurrency senders, returning
+// the latency of each successful post and the number that failed
+func send(ctx context.Context, cl *client.SmartClient, chats []string, concurrency int) ([]time.Duration, int) {
+	var (
+		mu        sync.Mutex
+		latencies []time.Duration
+		errs      int
+		wg        sync.WaitGroup
+	)
+	work := make(chan string)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func(sender string) {
+			defer wg.Done()
+			for chat := range work {
+				start := time.Now()
+				_, err := cl.SendMessage(chat, sender, "benchmark message")
+				took := time.Since(start)
+
+				mu.Lock()
+				if err != nil {
+					errs++
+				} else {
+					latencies = append(latencies, took)
+				}
+				mu.Unlock()
+			}
+		}(fmt.Sprintf("sender-%d", i))
+	}
+
+	for _, chat := range chats {
+		if ctx.Err() != nil {
+			break
+		}
+		work <- chat
+	}
+	close(work)
+	wg.Wait()
+	return latencies, errs
+}
+
+// owners returns the server each of the first n chats routes to
+func owners(cl *client.SmartClient, n int) map[string]string {
+	result := make(map[string]string, n)
+	for i := 0; i < n; i++ {
+		chat := fmt.Sprintf("chat-%04d", i)
+		result[chat], _, _ = cl.GetTargetServer(chat)
+	}
+	return result
+}
+
+// percentiles returns the median, 99th percentile and maximum of
+// latencies, in milliseconds
+func percentiles(latencies []time.Duration) (p50, p99, max float64) {
+	if len(latencies) == 0 {
+		return 0, 0, 0
+	}
+	sorted := append([]time.Duration(nil), latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	at := func(q float64) float64 {
+		i := int(q * float64(len(sorted)-1))
+		return float64(sorted[i]) / float64(time.Millisecond)
+	}
+	return at(0.5), at(0.99), at(1)
+}
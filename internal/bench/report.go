@@ -0,0 +1,61 @@
+package bench
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// WriteJSON writes the report as indented JSON
+func (r Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteCSV writes the report in long form, one measurement per row:
+// section ("routing" or "run"), case, metric, value. Rows from different
+// releases can be joined on the first three columns.
+func (r Report) WriteCSV(w io.Writer) error {
+	out := csv.NewWriter(w)
+	out.Write([]string{"section", "case", "metric", "value"})
+
+	row := func(section, name, metric string, value float64) {
+		out.Write([]string{section, name, metric, strconv.FormatFloat(value, 'f', -1, 64)})
+	}
+	for _, result := range r.Routing {
+		row("routing", result.Name, "max_load", result.MaxLoad)
+		row("routing", result.Name, "load_std_dev", result.LoadStdDev)
+		row("routing", result.Name, "moved_on_join", result.MovedOnJoin)
+		row("routing", result.Name, "moved_on_leave", result.MovedOnLeave)
+	}
+	for _, result := range r.Runs {
+		row("run", result.Name, "hit_rate", result.HitRate)
+		row("run", result.Name, "l1_hit_rate", result.L1HitRate)
+		row("run", result.Name, "evictions", float64(result.Evictions))
+		row("run", result.Name, "p50_ms", result.P50Ms)
+		row("run", result.Name, "p99_ms", result.P99Ms)
+		row("run", result.Name, "max_ms", result.MaxMs)
+		row("run", result.Name, "throughput", result.Throughput)
+		row("run", result.Name, "errors", float64(result.Errors))
+		row("run", result.Name, "failovers", float64(result.Failovers))
+		row("run", result.Name, "moved_on_scale_out", result.MovedOnScaleOut)
+	}
+
+	out.Flush()
+	return out.Error()
+}
+
+// Write writes the report in format, "json" or "csv"
+func (r Report) Write(w io.Writer, format string) error {
+	switch format {
+	case "json":
+		return r.WriteJSON(w)
+	case "csv":
+		return r.WriteCSV(w)
+	default:
+		return fmt.Errorf("unknown report format %q (want json or csv)", format)
+	}
+}
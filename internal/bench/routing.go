@@ -0,0 +1,143 @@
+package bench
+
+import (
+	"fmt"
+	"hash/crc32"
+	"math"
+
+	"github.com/sh4shv4t/DistriChat/pkg/ring"
+)
+
+// RoutingCase is a routing strategy measured on its own, without servers:
+// how evenly it spreads keys and how many it moves when a node joins or
+// leaves
+type RoutingCase struct {
+	Name string
+
+	// Strategy is "consistent" (the hash ring) or "modulo" (hash mod node
+	// count, the naive baseline)
+	Strategy string
+
+	// One per node: its ring weight, which is its number of virtual nodes
+	// (modulo ignores them). The node joining gets the first node's.
+	Capacities []int
+}
+
+// RoutingResult is what a RoutingCase measured
+type RoutingResult struct {
+	Name         string  `json:"name"`
+	Strategy     string  `json:"strategy"`
+	VirtualNodes int     `json:"virtual_nodes"` // Across all nodes (0 for modulo)
+	Nodes        int     `json:"nodes"`
+	Keys         int     `json:"keys"`
+	MaxLoad      float64 `json:"max_load"`      // Busiest node's keys over its fair share
+	LoadStdDev   float64 `json:"load_std_dev"`  // Of keys over fair share, across nodes
+	MovedOnJoin  float64 `json:"moved_on_join"` // Fraction of keys changing owner when a node joins
+	MovedOnLeave float64 `json:"moved_on_leave"`
+}
+
+// router places keys on nodes
+type router interface {
+	owner(key string) string
+}
+
+type hashRing struct{ *ring.HashRing }
+
+func (r hashRing) owner(key string) string {
+	id, _, _ := r.GetNode(key)
+	return id
+}
+
+// modulo assigns keys by hash mod node count, ignoring capacity
+type modulo []string
+
+func (m modulo) owner(key string) string {
+	return m[crc32.ChecksumIEEE([]byte(key))%uint32(len(m))]
+}
+
+// nodeIDs names n nodes
+func nodeIDs(n int) []string {
+	ids := make([]string, n)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("node-%d", i+1)
+	}
+	return ids
+}
+
+// build creates the case's router over the first n of its nodes, plus
+// one more with the first node's capacity if extra is set
+func (c RoutingCase) build(n int, extra bool) router {
+	ids := nodeIDs(n + 1)
+	if !extra {
+		ids = ids[:n]
+	}
+	if c.Strategy == "modulo" {
+		return modulo(ids)
+	}
+
+	r := ring.NewHashRing(c.Capacities[0])
+	for i, id := range ids {
+		capacity := c.Capacities[0]
+		if i < n {
+			capacity = c.Capacities[i]
+		}
+		r.AddNode(id, capacity, id)
+	}
+	return hashRing{r}
+}
+
+// runRouting measures c over keys sample keys
+func runRouting(c RoutingCase, keys int) RoutingResult {
+	n := len(c.Capacities)
+	base := c.build(n, false)
+	joined := c.build(n, true)
+	left := c.build(n-1, false)
+
+	counts := make(map[string]int)
+	movedJoin, movedLeave := 0, 0
+	for i := 0; i < keys; i++ {
+		key := fmt.Sprintf("chat-%d", i)
+		owner := base.owner(key)
+		counts[owner]++
+		if joined.owner(key) != owner {
+			movedJoin++
+		}
+		if left.owner(key) != owner {
+			movedLeave++
+		}
+	}
+
+	total := 0
+	for _, capacity := range c.Capacities {
+		total += capacity
+	}
+	var loads []float64
+	for i, id := range nodeIDs(n) {
+		fair := float64(keys) * float64(c.Capacities[i]) / float64(total)
+		if c.Strategy == "modulo" {
+			fair = float64(keys) / float64(n)
+		}
+		loads = append(loads, float64(counts[id])/fair)
+	}
+
+	result := RoutingResult{
+		Name:         c.Name,
+		Strategy:     c.Strategy,
+		Nodes:        n,
+		Keys:         keys,
+		MovedOnJoin:  float64(movedJoin) / float64(keys),
+		MovedOnLeave: float64(movedLeave) / float64(keys),
+	}
+	if c.Strategy != "modulo" {
+		result.VirtualNodes = total
+	}
+	var sum, sumSq float64
+	for _, load := range loads {
+		result.MaxLoad = math.Max(result.MaxLoad, load)
+		sum += load
+		sumSq += load * load
+	}
+	mean := sum / float64(len(loads))
+	result.LoadStdDev = math.Sqrt(math.Max(sumSq/float64(len(loads))-mean*mean, 0))
+	return result
+}
@@ -0,0 +1,385 @@
+// Package bridge federates independent DistriChat clusters. A bridge holds
+// a client for every cluster it joins and a routing table of shared chats:
+// each route names one chat per cluster, and messages posted to any of them
+// are relayed to the others. The clusters keep their own rings, replicas and
+// control planes; they only ever see the bridge as another client.
+//
+// Relayed messages carry the cluster they came from (federated_from), and
+// the bridge never relays such a message again, so routes can't loop.
+// Delivery is at-least-once: a relay that fails is retried on the next poll.
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/pkg/client"
+	"github.com/sh4shv4t/DistriChat/pkg/logging"
+	pb "github.com/sh4shv4t/DistriChat/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+// ClusterConfig describes how the bridge reaches one cluster
+type ClusterConfig struct {
+	Name string
+
+	// Coordinator, if set, is followed for the cluster's topology;
+	// otherwise the bridge follows Servers directly
+	Coordinator string
+	Servers     []string
+
+	// Dialer, if set, connects to the cluster's servers and coordinator in
+	// place of TCP (e.g. an in-memory network in tests)
+	Dialer func(ctx context.Context, address string) (net.Conn, error)
+}
+
+// BridgeConfig contains configuration for a federation bridge
+type BridgeConfig struct {
+	Port     int
+	Clusters []ClusterConfig
+
+	// How often shared chats are checked for new messages (default: 1s)
+	PollInterval time.Duration
+
+	// Most recent messages read per chat and poll (default: 100). More
+	// messages than this arriving between polls are not relayed.
+	HistoryWindow int
+
+	// Listener, if set, serves FederationService in place of listening on
+	// Port
+	Listener net.Listener
+}
+
+// Endpoint is one chat in one cluster
+type Endpoint struct {
+	Cluster string
+	ChatID  string
+}
+
+// Route joins two or more endpoints into one shared chat
+type Route struct {
+	Name      string
+	Endpoints []Endpoint
+}
+
+// BridgeStats tracks relay activity
+type BridgeStats struct {
+	Polls         int64
+	Relayed       int64
+	RelayFailures int64
+	PollFailures  int64
+}
+
+// Bridge relays messages between the chats joined by its routes
+type Bridge struct {
+	mu sync.RWMutex
+
+	// Cluster clients by name
+	clusters map[string]*client.SmartClient
+
+	// Routing table by route name
+	routes map[string]*routeState
+
+	config BridgeConfig
+	stats  BridgeStats
+
+	// gRPC server instance
+	grpcServer *grpc.Server
+
+	log *slog.Logger
+
+	// Shutdown coordination
+	shutdownCh chan struct{}
+	stopOnce   sync.Once
+}
+
+// routeState tracks which messages of each endpoint have been handled
+type routeState struct {
+	route Route
+
+	// Message IDs handled per endpoint; nil until the endpoint's first
+	// poll, which marks existing history as handled instead of relaying it
+	seen map[Endpoint]map[string]bool
+}
+
+// NewBridge creates a bridge for the configured clusters
+func NewBridge(config BridgeConfig) *Bridge {
+	if config.PollInterval <= 0 {
+		config.PollInterval = time.Second
+	}
+	if config.HistoryWindow <= 0 {
+		config.HistoryWindow = 100
+	}
+
+	return &Bridge{
+		clusters:   make(map[string]*client.SmartClient),
+		routes:     make(map[string]*routeState),
+		config:     config,
+		log:        logging.Logger("bridge"),
+		shutdownCh: make(chan struct{}),
+	}
+}
+
+// Start connects to every cluster, serves FederationService and begins
+// relaying
+func (b *Bridge) Start() error {
+	for _, cluster := range b.config.Clusters {
+		config := client.DefaultClientConfig()
+		config.Dialer = cluster.Dialer
+		c := client.NewSmartClient(config)
+
+		var err error
+		if cluster.Coordinator != "" {
+			err = c.FollowCoordinator(cluster.Coordinator)
+		} else {
+			err = c.FollowServers(cluster.Servers...)
+		}
+		if err != nil {
+			c.Close()
+			b.closeClusters()
+			return fmt.Errorf("failed to join cluster %s: %w", cluster.Name, err)
+		}
+		b.clusters[cluster.Name] = c
+	}
+
+	listener := b.config.Listener
+	if listener == nil {
+		var err error
+		if listener, err = net.Listen("tcp", fmt.Sprintf(":%d", b.config.Port)); err != nil {
+			b.closeClusters()
+			return fmt.Errorf("failed to listen on port %d: %w", b.config.Port, err)
+		}
+	}
+
+	b.grpcServer = grpc.NewServer()
+	pb.RegisterFederationServiceServer(b.grpcServer, NewFederationServer(b))
+
+	b.log.Info("Starting", "port", b.config.Port, "clusters", len(b.clusters), "poll_interval", b.config.PollInterval)
+
+	go func() {
+		if err := b.grpcServer.Serve(listener); err != nil {
+			b.log.Error("gRPC server error", logging.Err(err))
+		}
+	}()
+
+	go b.relayLoop()
+
+	return nil
+}
+
+// Stop stops relaying and closes all cluster connections
+func (b *Bridge) Stop() {
+	b.stopOnce.Do(func() {
+		close(b.shutdownCh)
+
+		if b.grpcServer != nil {
+			b.grpcServer.GracefulStop()
+		}
+		b.closeClusters()
+		b.log.Info("Stopped")
+	})
+}
+
+// closeClusters closes every cluster client
+func (b *Bridge) closeClusters() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for name, c := range b.clusters {
+		c.Close()
+		delete(b.clusters, name)
+	}
+}
+
+// AddRoute adds a route to the routing table, replacing one with the same name
+func (b *Bridge) AddRoute(route Route) error {
+	if route.Name == "" {
+		return fmt.Errorf("route name is required")
+	}
+	if len(route.Endpoints) < 2 {
+		return fmt.Errorf("route %s needs at least two endpoints", route.Name)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	seen := make(map[Endpoint]bool)
+	for _, endpoint := range route.Endpoints {
+		if _, ok := b.clusters[endpoint.Cluster]; !ok {
+			return fmt.Errorf("unknown cluster %q", endpoint.Cluster)
+		}
+		if endpoint.ChatID == "" {
+			return fmt.Errorf("route %s has an endpoint without a chat ID", route.Name)
+		}
+		if seen[endpoint] {
+			return fmt.Errorf("route %s lists %s/%s twice", route.Name, endpoint.Cluster, endpoint.ChatID)
+		}
+		seen[endpoint] = true
+	}
+
+	b.routes[route.Name] = &routeState{
+		route: route,
+		seen:  make(map[Endpoint]map[string]bool),
+	}
+	b.log.Info("Route added", "route", route.Name, "chats", len(route.Endpoints))
+	return nil
+}
+
+// RemoveRoute removes a route. Returns whether it existed.
+func (b *Bridge) RemoveRoute(name string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.routes[name]; !ok {
+		return false
+	}
+	delete(b.routes, name)
+	b.log.Info("Route removed", "route", name)
+	return true
+}
+
+// Routes returns the routing table, sorted by name
+func (b *Bridge) Routes() []Route {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	routes := make([]Route, 0, len(b.routes))
+	for _, state := range b.routes {
+		routes = append(routes, state.route)
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		return routes[i].Name < routes[j].Name
+	})
+	return routes
+}
+
+// Clusters returns the names of the joined clusters, sorted
+func (b *Bridge) Clusters() []string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	names := make([]string, 0, len(b.clusters))
+	for name := range b.clusters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetStats returns relay statistics
+func (b *Bridge) GetStats() BridgeStats {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.stats
+}
+
+// relayLoop polls every route until Stop
+func (b *Bridge) relayLoop() {
+	ticker := time.NewTicker(b.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			b.relayAll()
+		case <-b.shutdownCh:
+			return
+		}
+	}
+}
+
+// relayAll relays new messages on every route once
+func (b *Bridge) relayAll() {
+	b.mu.RLock()
+	states := make([]*routeState, 0, len(b.routes))
+	for _, state := range b.routes {
+		states = append(states, state)
+	}
+	b.mu.RUnlock()
+
+	for _, state := range states {
+		for _, endpoint := range state.route.Endpoints {
+			b.relayFrom(state, endpoint)
+		}
+	}
+}
+
+// relayFrom relays the messages posted locally to one endpoint since the
+// last poll to the route's other endpoints
+func (b *Bridge) relayFrom(state *routeState, source Endpoint) {
+	b.mu.Lock()
+	b.stats.Polls++
+	src := b.clusters[source.Cluster]
+	b.mu.Unlock()
+	if src == nil {
+		return
+	}
+
+	history, err := src.GetHistory(source.ChatID, b.config.HistoryWindow)
+	if err != nil || !history.Success {
+		b.mu.Lock()
+		b.stats.PollFailures++
+		b.mu.Unlock()
+		b.log.Warn("Failed to read history", "cluster", source.Cluster, logging.ChatID(source.ChatID),
+			logging.Err(err), "details", history.GetErrorDetails())
+		return
+	}
+
+	previous := state.seen[source]
+	seen := make(map[string]bool, len(history.Messages))
+	for _, stored := range history.Messages {
+		id := stored.MessageId
+		if previous == nil || previous[id] || stored.Request.GetFederatedFrom() != "" {
+			seen[id] = true // History before the route, already relayed, or relayed in
+			continue
+		}
+		if b.relay(state.route, source, stored.Request) {
+			seen[id] = true
+		}
+	}
+	// Only IDs still inside the window can reappear, so older ones are dropped
+	state.seen[source] = seen
+}
+
+// relay posts one message to every endpoint of the route except its source.
+// Returns whether all targets accepted it.
+func (b *Bridge) relay(route Route, source Endpoint, req *pb.ChatRequest) bool {
+	ok := true
+	for _, target := range route.Endpoints {
+		if target == source {
+			continue
+		}
+
+		b.mu.RLock()
+		dst := b.clusters[target.Cluster]
+		b.mu.RUnlock()
+		if dst == nil {
+			ok = false
+			continue
+		}
+
+		relayed := proto.Clone(req).(*pb.ChatRequest)
+		relayed.ChatId = target.ChatID
+		relayed.FederatedFrom = source.Cluster
+
+		resp, err := dst.SendRequest(relayed)
+		b.mu.Lock()
+		if err != nil || !resp.Success {
+			b.stats.RelayFailures++
+			ok = false
+			b.log.Warn("Relay failed", "cluster", source.Cluster, logging.ChatID(source.ChatID),
+				"target_cluster", target.Cluster, "target_chat_id", target.ChatID,
+				logging.Err(err), "details", resp.GetErrorDetails())
+		} else {
+			b.stats.Relayed++
+		}
+		b.mu.Unlock()
+	}
+	return ok
+}
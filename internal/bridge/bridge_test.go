@@ -0,0 +1,102 @@
+package bridge
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/pkg/chattest"
+	"github.com/sh4shv4t/DistriChat/pkg/client"
+	pb "github.com/sh4shv4t/DistriChat/proto"
+)
+
+// waitFor polls condition until it holds or a few seconds pass
+func waitFor(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// history reads chatID through cl
+func history(t *testing.T, cl *client.SmartClient, chatID string) []*pb.StoredMessage {
+	t.Helper()
+	resp, err := cl.GetHistory(chatID, 100)
+	if err != nil || !resp.Success {
+		t.Fatalf("GetHistory(%s) failed: %v (%v)", chatID, resp, err)
+	}
+	return resp.Messages
+}
+
+func TestRelaysBetweenClusters(t *testing.T) {
+	a := chattest.NewCluster(t, chattest.ClusterConfig{Servers: 1})
+	b := chattest.NewCluster(t, chattest.ClusterConfig{Servers: 1})
+
+	br := NewBridge(BridgeConfig{
+		Clusters: []ClusterConfig{
+			{Name: "a", Servers: []string{"server-1"}, Dialer: a.Network.Dial},
+			{Name: "b", Servers: []string{"server-1"}, Dialer: b.Network.Dial},
+		},
+		PollInterval: 20 * time.Millisecond,
+		Listener:     chattest.NewNetwork().Listen("bridge"),
+	})
+	if err := br.Start(); err != nil {
+		t.Fatalf("Failed to start the bridge: %v", err)
+	}
+	defer br.Stop()
+	if err := br.AddRoute(Route{Name: "partners", Endpoints: []Endpoint{
+		{Cluster: "a", ChatID: "chat-1"},
+		{Cluster: "b", ChatID: "room"},
+	}}); err != nil {
+		t.Fatalf("AddRoute failed: %v", err)
+	}
+	// The first poll of each endpoint marks its history as seen
+	waitFor(t, "the first polls", func() bool { return br.GetStats().Polls >= 2 })
+
+	// a -> b
+	if _, err := a.Client.SendMessage("chat-1", "alice", "hello from a"); err != nil {
+		t.Fatalf("SendMessage to a failed: %v", err)
+	}
+	waitFor(t, "the message relayed to b", func() bool { return len(history(t, b.Client, "room")) == 1 })
+	if relayed := history(t, b.Client, "room")[0].Request; relayed.GetText() != "hello from a" || relayed.FederatedFrom != "a" {
+		t.Errorf("Expected alice's message from a, got %v", relayed)
+	}
+
+	// b -> a
+	if _, err := b.Client.SendMessage("room", "bob", "hello from b"); err != nil {
+		t.Fatalf("SendMessage to b failed: %v", err)
+	}
+	waitFor(t, "the message relayed to a", func() bool { return len(history(t, a.Client, "chat-1")) == 2 })
+	if relayed := history(t, a.Client, "chat-1")[1].Request; relayed.GetText() != "hello from b" || relayed.FederatedFrom != "b" {
+		t.Errorf("Expected bob's message from b, got %v", relayed)
+	}
+
+	// Relayed messages aren't relayed back, so both chats settle at two
+	polls := br.GetStats().Polls
+	waitFor(t, "more polls", func() bool { return br.GetStats().Polls >= polls+10 })
+	if got := len(history(t, a.Client, "chat-1")); got != 2 {
+		t.Errorf("Expected 2 messages in a, got %d", got)
+	}
+	if got := len(history(t, b.Client, "room")); got != 2 {
+		t.Errorf("Expected 2 messages in b, got %d", got)
+	}
+	if stats := br.GetStats(); stats.Relayed != 2 || stats.RelayFailures != 0 {
+		t.Errorf("Expected 2 messages relayed without failures, got %+v", stats)
+	}
+}
+
+func TestAddRouteRequiresKnownClusters(t *testing.T) {
+	br := NewBridge(BridgeConfig{Clusters: []ClusterConfig{{Name: "a"}, {Name: "b"}}})
+	if err := br.AddRoute(Route{Name: "one", Endpoints: []Endpoint{{Cluster: "a", ChatID: "chat-1"}}}); err == nil {
+		t.Error("Expected a route with one endpoint refused")
+	}
+	if err := br.AddRoute(Route{Name: "unknown", Endpoints: []Endpoint{
+		{Cluster: "a", ChatID: "chat-1"},
+		{Cluster: "c", ChatID: "chat-1"},
+	}}); err == nil {
+		t.Error("Expected a route to an unknown cluster refused")
+	}
+}
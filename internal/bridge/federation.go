@@ -0,0 +1,58 @@
+package bridge
+
+import (
+	"context"
+
+	pb "github.com/sh4shv4t/DistriChat/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// FederationServer implements the gRPC FederationService for a Bridge
+type FederationServer struct {
+	pb.UnimplementedFederationServiceServer
+
+	bridge *Bridge
+}
+
+// NewFederationServer creates a federation service bound to a bridge
+func NewFederationServer(bridge *Bridge) *FederationServer {
+	return &FederationServer{bridge: bridge}
+}
+
+// AddRoute joins chats across clusters
+func (f *FederationServer) AddRoute(ctx context.Context, req *pb.AddRouteRequest) (*pb.AddRouteResponse, error) {
+	route := Route{Name: req.GetRoute().GetName()}
+	for _, endpoint := range req.GetRoute().GetEndpoints() {
+		route.Endpoints = append(route.Endpoints, Endpoint{
+			Cluster: endpoint.Cluster,
+			ChatID:  endpoint.ChatId,
+		})
+	}
+
+	if err := f.bridge.AddRoute(route); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return &pb.AddRouteResponse{}, nil
+}
+
+// RemoveRoute stops relaying for a route
+func (f *FederationServer) RemoveRoute(ctx context.Context, req *pb.RemoveRouteRequest) (*pb.RemoveRouteResponse, error) {
+	return &pb.RemoveRouteResponse{Removed: f.bridge.RemoveRoute(req.Name)}, nil
+}
+
+// ListRoutes returns the routing table and known clusters
+func (f *FederationServer) ListRoutes(ctx context.Context, req *pb.ListRoutesRequest) (*pb.ListRoutesResponse, error) {
+	resp := &pb.ListRoutesResponse{Clusters: f.bridge.Clusters()}
+	for _, route := range f.bridge.Routes() {
+		wire := &pb.FederationRoute{Name: route.Name}
+		for _, endpoint := range route.Endpoints {
+			wire.Endpoints = append(wire.Endpoints, &pb.FederatedEndpoint{
+				Cluster: endpoint.Cluster,
+				ChatId:  endpoint.ChatID,
+			})
+		}
+		resp.Routes = append(resp.Routes, wire)
+	}
+	return resp, nil
+}
@@ -0,0 +1,221 @@
+// Package clusterstats collects every server's cache statistics into one
+// cluster-level summary. An Aggregator, run by a single node (the metadata
+// leader), polls each ring member's GetCacheStats on an interval, derives
+// request rates from successive samples, and serves the latest summary as
+// an RPC response or a Prometheus /metrics page, so operators don't have to
+// scrape every server and merge the numbers themselves.
+package clusterstats
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/pkg/logging"
+	"github.com/sh4shv4t/DistriChat/pkg/ring"
+	pb "github.com/sh4shv4t/DistriChat/proto"
+)
+
+// Fetcher reads one server's statistics
+type Fetcher interface {
+	Fetch(ctx context.Context, address string) (*pb.StatsResponse, error)
+}
+
+// Config contains configuration for an aggregator
+type Config struct {
+	// Interval between collection rounds (default: 10s)
+	Interval time.Duration
+
+	// Timeout bounds each server's fetch (default: 2s)
+	Timeout time.Duration
+}
+
+// ServerStats is one server's part of a summary
+type ServerStats struct {
+	NodeID            string
+	Address           string
+	Reachable         bool
+	Error             string // Why the last fetch failed
+	L1Size            int
+	L1Capacity        int
+	L2Size            int
+	L2Capacity        int
+	TotalRequests     int64
+	CacheHits         int64
+	CacheMisses       int64
+	RequestsPerSecond float64 // Since the previous round (0 on the first)
+}
+
+// Summary is the cluster-wide view from one collection round
+type Summary struct {
+	CollectedAt       time.Time
+	Servers           int // Ring members polled
+	Reachable         int // Members that answered
+	L1Size            int
+	L1Capacity        int
+	L2Size            int
+	L2Capacity        int
+	TotalRequests     int64
+	CacheHits         int64
+	CacheMisses       int64
+	RequestsPerSecond float64
+	PerServer         []ServerStats // Sorted by NodeID
+}
+
+// HitRatio returns the fraction of lookups served from cache
+func (s Summary) HitRatio() float64 {
+	lookups := s.CacheHits + s.CacheMisses
+	if lookups == 0 {
+		return 0
+	}
+	return float64(s.CacheHits) / float64(lookups)
+}
+
+// sample is a server's request count at a point in time
+type sample struct {
+	at       time.Time
+	requests int64
+}
+
+// Aggregator periodically collects statistics from every ring member
+type Aggregator struct {
+	config  Config
+	nodes   func() []ring.NodeSpec
+	fetcher Fetcher
+	log     *slog.Logger
+
+	mu       sync.RWMutex
+	summary  Summary
+	previous map[string]sample // Last successful sample per server
+}
+
+// New creates an aggregator polling the members returned by nodes
+func New(config Config, nodes func() []ring.NodeSpec, fetcher Fetcher) *Aggregator {
+	if config.Interval <= 0 {
+		config.Interval = 10 * time.Second
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 2 * time.Second
+	}
+	return &Aggregator{
+		config:   config,
+		nodes:    nodes,
+		fetcher:  fetcher,
+		log:      logging.Logger("clusterstats"),
+		previous: make(map[string]sample),
+	}
+}
+
+// Run collects a summary every interval until ctx is cancelled
+func (a *Aggregator) Run(ctx context.Context) {
+	ticker := time.NewTicker(a.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		a.Collect(ctx)
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Collect polls every member once, concurrently, and stores and returns
+// the resulting summary
+func (a *Aggregator) Collect(ctx context.Context) Summary {
+	nodes := a.nodes()
+	results := make([]ServerStats, len(nodes))
+	var wg sync.WaitGroup
+	for i, node := range nodes {
+		wg.Add(1)
+		go func(i int, node ring.NodeSpec) {
+			defer wg.Done()
+			results[i] = a.fetch(ctx, node)
+		}(i, node)
+	}
+	wg.Wait()
+
+	now := time.Now()
+	summary := Summary{CollectedAt: now, Servers: len(nodes)}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	seen := make(map[string]bool, len(results))
+	for i := range results {
+		stats := &results[i]
+		seen[stats.NodeID] = true
+		if !stats.Reachable {
+			summary.PerServer = append(summary.PerServer, *stats)
+			continue
+		}
+
+		if prev, ok := a.previous[stats.NodeID]; ok && stats.TotalRequests >= prev.requests {
+			if elapsed := now.Sub(prev.at).Seconds(); elapsed > 0 {
+				stats.RequestsPerSecond = float64(stats.TotalRequests-prev.requests) / elapsed
+			}
+		}
+		a.previous[stats.NodeID] = sample{at: now, requests: stats.TotalRequests}
+
+		summary.Reachable++
+		summary.L1Size += stats.L1Size
+		summary.L1Capacity += stats.L1Capacity
+		summary.L2Size += stats.L2Size
+		summary.L2Capacity += stats.L2Capacity
+		summary.TotalRequests += stats.TotalRequests
+		summary.CacheHits += stats.CacheHits
+		summary.CacheMisses += stats.CacheMisses
+		summary.RequestsPerSecond += stats.RequestsPerSecond
+		summary.PerServer = append(summary.PerServer, *stats)
+	}
+	// Forget members that left the ring
+	for nodeID := range a.previous {
+		if !seen[nodeID] {
+			delete(a.previous, nodeID)
+		}
+	}
+
+	sort.Slice(summary.PerServer, func(i, j int) bool {
+		return summary.PerServer[i].NodeID < summary.PerServer[j].NodeID
+	})
+	a.summary = summary
+	return summary
+}
+
+// fetch reads one member's statistics
+func (a *Aggregator) fetch(ctx context.Context, node ring.NodeSpec) ServerStats {
+	stats := ServerStats{NodeID: node.NodeID, Address: node.Address}
+
+	ctx, cancel := context.WithTimeout(ctx, a.config.Timeout)
+	defer cancel()
+
+	resp, err := a.fetcher.Fetch(ctx, node.Address)
+	if err != nil {
+		a.log.Warn("Failed to collect stats", logging.NodeID(node.NodeID), logging.Err(err))
+		stats.Error = err.Error()
+		return stats
+	}
+
+	stats.Reachable = true
+	stats.L1Size = int(resp.L1Size)
+	stats.L1Capacity = int(resp.L1Capacity)
+	stats.L2Size = int(resp.L2Size)
+	stats.L2Capacity = int(resp.L2Capacity)
+	stats.TotalRequests = resp.TotalRequests
+	stats.CacheHits = resp.CacheHits
+	stats.CacheMisses = resp.CacheMisses
+	return stats
+}
+
+// Summary returns the latest summary (zero before the first round)
+func (a *Aggregator) Summary() Summary {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	summary := a.summary
+	summary.PerServer = append([]ServerStats(nil), a.summary.PerServer...)
+	return summary
+}
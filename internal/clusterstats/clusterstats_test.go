@@ -0,0 +1,101 @@
+package clusterstats
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sh4shv4t/DistriChat/pkg/ring"
+	pb "github.com/sh4shv4t/DistriChat/proto"
+)
+
+// fakeFetcher serves canned stats per address
+type fakeFetcher struct {
+	mu    sync.Mutex
+	stats map[string]*pb.StatsResponse
+}
+
+func (f *fakeFetcher) Fetch(ctx context.Context, address string) (*pb.StatsResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	resp, ok := f.stats[address]
+	if !ok {
+		return nil, errors.New("unreachable")
+	}
+	return resp, nil
+}
+
+func members(ids ...string) func() []ring.NodeSpec {
+	return func() []ring.NodeSpec {
+		var nodes []ring.NodeSpec
+		for _, id := range ids {
+			nodes = append(nodes, ring.NodeSpec{NodeID: id, Address: id + ":1"})
+		}
+		return nodes
+	}
+}
+
+func TestCollectSumsServers(t *testing.T) {
+	fetcher := &fakeFetcher{stats: map[string]*pb.StatsResponse{
+		"a:1": {L1Size: 2, L1Capacity: 5, L2Size: 4, L2Capacity: 20, TotalRequests: 10, CacheHits: 6, CacheMisses: 4},
+		"b:1": {L1Size: 3, L1Capacity: 5, L2Size: 1, L2Capacity: 20, TotalRequests: 30, CacheHits: 24, CacheMisses: 6},
+	}}
+	agg := New(Config{}, members("c", "a", "b"), fetcher)
+
+	summary := agg.Collect(context.Background())
+	if summary.Servers != 3 || summary.Reachable != 2 {
+		t.Errorf("Expected 2 of 3 servers reachable, got %d of %d", summary.Reachable, summary.Servers)
+	}
+	if summary.L1Size != 5 || summary.L2Capacity != 40 || summary.TotalRequests != 40 {
+		t.Errorf("Unexpected totals: %+v", summary)
+	}
+	if ratio := summary.HitRatio(); ratio != 0.75 {
+		t.Errorf("Expected hit ratio 0.75, got %v", ratio)
+	}
+	if len(summary.PerServer) != 3 || summary.PerServer[2].NodeID != "c" || summary.PerServer[2].Reachable {
+		t.Errorf("Expected c listed last as unreachable, got %+v", summary.PerServer)
+	}
+
+	// Rates come from successive rounds
+	fetcher.mu.Lock()
+	fetcher.stats["a:1"] = &pb.StatsResponse{TotalRequests: 1010}
+	fetcher.mu.Unlock()
+	summary = agg.Collect(context.Background())
+	if summary.PerServer[0].RequestsPerSecond <= 0 || summary.PerServer[1].RequestsPerSecond != 0 {
+		t.Errorf("Expected only a to have a request rate, got %v and %v",
+			summary.PerServer[0].RequestsPerSecond, summary.PerServer[1].RequestsPerSecond)
+	}
+	if got := agg.Summary(); got.TotalRequests != 1040 {
+		t.Errorf("Expected the latest summary to be kept, got %d requests", got.TotalRequests)
+	}
+}
+
+func TestWriteMetrics(t *testing.T) {
+	summary := Summary{
+		Servers: 2, Reachable: 1, TotalRequests: 40, CacheHits: 30, CacheMisses: 10,
+		PerServer: []ServerStats{
+			{NodeID: "a", Reachable: true, L1Size: 2, L2Size: 3, TotalRequests: 40},
+			{NodeID: "b"},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteMetrics(&buf, summary); err != nil {
+		t.Fatalf("WriteMetrics failed: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"districhat_cluster_servers_reachable 1\n",
+		"districhat_cluster_requests_total 40\n",
+		"districhat_cluster_cache_hit_ratio 0.75\n",
+		`districhat_server_up{server="b"} 0` + "\n",
+		`districhat_server_sessions{server="a"} 5` + "\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected metrics to contain %q", want)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package clusterstats
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	pb "github.com/sh4shv4t/DistriChat/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// GRPCFetcher reads statistics with the servers' GetCacheStats RPC
+type GRPCFetcher struct {
+	mu    sync.Mutex
+	conns map[string]*grpc.ClientConn
+	opts  []grpc.DialOption
+}
+
+// NewGRPCFetcher creates a gRPC-backed fetcher, dialing servers with opts
+// (insecure credentials unless opts set others)
+func NewGRPCFetcher(opts ...grpc.DialOption) *GRPCFetcher {
+	return &GRPCFetcher{
+		conns: make(map[string]*grpc.ClientConn),
+		opts:  append([]grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}, opts...),
+	}
+}
+
+// Fetch calls GetCacheStats on the server at address
+func (f *GRPCFetcher) Fetch(ctx context.Context, address string) (*pb.StatsResponse, error) {
+	conn, err := f.conn(address)
+	if err != nil {
+		return nil, err
+	}
+	return pb.NewChatServiceClient(conn).GetCacheStats(ctx, &pb.StatsRequest{})
+}
+
+// Close closes all cached connections
+func (f *GRPCFetcher) Close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for address, conn := range f.conns {
+		conn.Close()
+		delete(f.conns, address)
+	}
+}
+
+// conn returns a cached connection to address
+func (f *GRPCFetcher) conn(address string) (*grpc.ClientConn, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if conn, ok := f.conns[address]; ok {
+		return conn, nil
+	}
+	conn, err := grpc.Dial(address, f.opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", address, err)
+	}
+	f.conns[address] = conn
+	return conn, nil
+}
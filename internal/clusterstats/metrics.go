@@ -0,0 +1,98 @@
+package clusterstats
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// WriteMetrics writes a summary in the Prometheus text exposition format
+func WriteMetrics(w io.Writer, summary Summary) error {
+	m := &metricWriter{w: w}
+
+	m.gauge("districhat_cluster_servers", "Ring members polled in the last round", float64(summary.Servers))
+	m.gauge("districhat_cluster_servers_reachable", "Ring members that answered in the last round", float64(summary.Reachable))
+	m.gauge("districhat_cluster_l1_sessions", "Sessions in L1 across the cluster", float64(summary.L1Size))
+	m.gauge("districhat_cluster_l1_capacity", "L1 capacity across the cluster", float64(summary.L1Capacity))
+	m.gauge("districhat_cluster_l2_sessions", "Sessions in L2 across the cluster", float64(summary.L2Size))
+	m.gauge("districhat_cluster_l2_capacity", "L2 capacity across the cluster", float64(summary.L2Capacity))
+	m.counter("districhat_cluster_requests_total", "Requests handled across the cluster", float64(summary.TotalRequests))
+	m.counter("districhat_cluster_cache_hits_total", "Cache hits across the cluster", float64(summary.CacheHits))
+	m.counter("districhat_cluster_cache_misses_total", "Cache misses across the cluster", float64(summary.CacheMisses))
+	m.gauge("districhat_cluster_cache_hit_ratio", "Fraction of lookups served from cache", summary.HitRatio())
+	m.gauge("districhat_cluster_requests_per_second", "Request rate across the cluster", summary.RequestsPerSecond)
+	if !summary.CollectedAt.IsZero() {
+		m.gauge("districhat_cluster_collected_timestamp_seconds", "When the last round finished", float64(summary.CollectedAt.Unix()))
+	}
+
+	m.header("districhat_server_up", "gauge", "Whether the server answered the last round")
+	for _, s := range summary.PerServer {
+		up := 0.0
+		if s.Reachable {
+			up = 1
+		}
+		m.sample("districhat_server_up", s.NodeID, up)
+	}
+	m.header("districhat_server_sessions", "gauge", "Sessions cached per server (L1 + L2)")
+	for _, s := range summary.PerServer {
+		if s.Reachable {
+			m.sample("districhat_server_sessions", s.NodeID, float64(s.L1Size+s.L2Size))
+		}
+	}
+	m.header("districhat_server_requests_total", "counter", "Requests handled per server")
+	for _, s := range summary.PerServer {
+		if s.Reachable {
+			m.sample("districhat_server_requests_total", s.NodeID, float64(s.TotalRequests))
+		}
+	}
+	m.header("districhat_server_requests_per_second", "gauge", "Request rate per server")
+	for _, s := range summary.PerServer {
+		if s.Reachable {
+			m.sample("districhat_server_requests_per_second", s.NodeID, s.RequestsPerSecond)
+		}
+	}
+	return m.err
+}
+
+// Handler serves the aggregator's latest summary as Prometheus metrics
+func Handler(a *Aggregator) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		WriteMetrics(w, a.Summary())
+	})
+}
+
+// metricWriter writes exposition lines, keeping the first error
+type metricWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (m *metricWriter) printf(format string, args ...interface{}) {
+	if m.err == nil {
+		_, m.err = fmt.Fprintf(m.w, format, args...)
+	}
+}
+
+func (m *metricWriter) header(name, kind, help string) {
+	m.printf("# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func (m *metricWriter) gauge(name, help string, value float64) {
+	m.header(name, "gauge", help)
+	m.printf("%s %s\n", name, formatValue(value))
+}
+
+func (m *metricWriter) counter(name, help string, value float64) {
+	m.header(name, "counter", help)
+	m.printf("%s %s\n", name, formatValue(value))
+}
+
+func (m *metricWriter) sample(name, server string, value float64) {
+	m.printf("%s{server=%s} %s\n", name, strconv.Quote(server), formatValue(value))
+}
+
+func formatValue(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,599 @@
+// Package coordinator implements the control plane for a DistriChat cluster.
+// The coordinator owns the authoritative hash ring, tracks which servers are
+// registered and alive, and streams topology changes to every client and
+// server that watches it, so no party has to build its own ring by hand.
+package coordinator
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/pkg/logging"
+	"github.com/sh4shv4t/DistriChat/pkg/rebalance"
+	"github.com/sh4shv4t/DistriChat/pkg/ring"
+	pb "github.com/sh4shv4t/DistriChat/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// Coordinator implements the gRPC CoordinatorService
+type Coordinator struct {
+	pb.UnimplementedCoordinatorServiceServer
+
+	mu sync.RWMutex
+
+	// Authoritative hash ring
+	ring *ring.HashRing
+
+	// Registered servers by ID
+	members map[string]*member
+
+	// Unexpired ownership lease grants by server ID, kept past eviction so
+	// an evicted server's ranges stay fenced until its last grant runs out
+	leases map[string][]lease
+
+	// Topology watchers - each receives the latest ring after every change
+	watchers map[int]chan ring.RingState
+	nextID   int
+
+	// Configuration
+	config CoordinatorConfig
+
+	// gRPC server instance
+	grpcServer *grpc.Server
+
+	// Session migration on topology change (nil when disabled)
+	rebalancer *rebalance.Rebalancer
+	mover      *rebalance.GRPCMover
+
+	// Most recent removals from the ring, oldest first
+	failovers []Failover
+
+	// Web dashboard (nil when disabled)
+	dashboardServer *http.Server
+
+	log *slog.Logger
+
+	// Shutdown coordination
+	shutdownCh chan struct{}
+	stopOnce   sync.Once
+}
+
+// member tracks a registered server
+type member struct {
+	serverID      string
+	address       string
+	capacity      int
+	region        string
+	namespace     string
+	registeredAt  time.Time
+	lastHeartbeat time.Time
+
+	// Connection used to probe the server's HealthCheck
+	conn   *grpc.ClientConn
+	client pb.ChatServiceClient
+
+	// Latest stats pushed by the server's admin service, and its request
+	// rate since the ones before
+	adminAddress string
+	stats        *pb.StatsSnapshot
+	statsAt      time.Time
+	requestRate  float64
+	stopStats    context.CancelFunc
+}
+
+// CoordinatorConfig contains configuration for the coordinator
+type CoordinatorConfig struct {
+	Port int
+
+	// Servers that neither heartbeat nor answer health probes for this
+	// long are evicted from the ring (default: 5s)
+	HeartbeatTimeout time.Duration
+
+	// How often members are probed and liveness is checked (default: 1s)
+	CheckInterval time.Duration
+
+	// Virtual nodes for servers registering without a capacity (default: 100)
+	VirtualNodes int
+
+	// Hasher places chats on the ring (default: CRC32); it must match the
+	// servers' and clients'
+	Hasher ring.Hasher
+
+	// Replicas per chat and region reported by the chat directory; should
+	// match the servers' Replication.N (default: 1)
+	ReplicationFactor int
+
+	// Rebalance migrates sessions to their new owners after every
+	// topology change (nil disables rebalancing)
+	Rebalance *rebalance.Config
+
+	// LeaseDuration is how long an ownership lease granted on Register and
+	// renewed on every Heartbeat stays valid. Servers only accept writes
+	// for chats in their leased ranges, and a range changes hands only
+	// after its previous holder's lease expires, so two servers never
+	// accept writes for the same chat. Must exceed the servers' heartbeat
+	// interval (0 disables leases).
+	LeaseDuration time.Duration
+
+	// DashboardPort serves the web dashboard over HTTP (0 disables it)
+	DashboardPort int
+
+	// AdminToken is sent to members' admin services when subscribing to
+	// their stats, if they require one
+	AdminToken string
+
+	// Listener, if set, serves the coordinator in place of listening on
+	// Port (e.g. an in-memory bufconn listener in tests), and Dialer
+	// connects to members in place of TCP
+	Listener net.Listener
+	Dialer   func(ctx context.Context, address string) (net.Conn, error)
+}
+
+// MemberInfo describes a registered server
+type MemberInfo struct {
+	ServerID      string
+	Address       string
+	Capacity      int
+	Region        string
+	Namespace     string
+	RegisteredAt  time.Time
+	LastHeartbeat time.Time
+
+	// Admin service address and the latest stats it pushed (nil before
+	// the first, or if the server serves no admin service)
+	AdminAddress string
+	Stats        *pb.StatsSnapshot
+}
+
+// NewCoordinator creates a new coordinator instance
+func NewCoordinator(config CoordinatorConfig) *Coordinator {
+	if config.HeartbeatTimeout <= 0 {
+		config.HeartbeatTimeout = 5 * time.Second
+	}
+	if config.CheckInterval <= 0 {
+		config.CheckInterval = time.Second
+	}
+	if config.VirtualNodes <= 0 {
+		config.VirtualNodes = 100
+	}
+	if config.ReplicationFactor <= 0 {
+		config.ReplicationFactor = 1
+	}
+
+	ringOpts := []ring.Option{ring.WithLogger(logging.Logger("ring"))}
+	if config.Hasher != nil {
+		ringOpts = append(ringOpts, ring.WithHasher(config.Hasher))
+	}
+	return &Coordinator{
+		ring:       ring.NewHashRing(config.VirtualNodes, ringOpts...),
+		members:    make(map[string]*member),
+		leases:     make(map[string][]lease),
+		watchers:   make(map[int]chan ring.RingState),
+		config:     config,
+		log:        logging.Logger("coordinator"),
+		shutdownCh: make(chan struct{}),
+	}
+}
+
+// rangeOptions are the ring options the ranges computed from states
+// (leases, the dashboard) need to match the ring's
+func (c *Coordinator) rangeOptions() []ring.Option {
+	if c.config.Hasher == nil {
+		return nil
+	}
+	return []ring.Option{ring.WithHasher(c.config.Hasher)}
+}
+
+// dialOptions are the options of every connection to a member
+func (c *Coordinator) dialOptions() []grpc.DialOption {
+	opts := []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+	if c.config.Dialer != nil {
+		opts = append(opts, grpc.WithContextDialer(c.config.Dialer))
+	}
+	return opts
+}
+
+// Start starts the coordinator's gRPC server and liveness checker
+func (c *Coordinator) Start() error {
+	listener := c.config.Listener
+	if listener == nil {
+		var err error
+		if listener, err = net.Listen("tcp", fmt.Sprintf(":%d", c.config.Port)); err != nil {
+			return fmt.Errorf("failed to listen on port %d: %w", c.config.Port, err)
+		}
+	}
+
+	// Created before serving, since the chat directory reads its progress
+	if c.config.Rebalance != nil {
+		config := *c.config.Rebalance
+		if config.Replicas <= 0 {
+			config.Replicas = c.config.ReplicationFactor
+		}
+		if config.Hasher == nil {
+			config.Hasher = c.config.Hasher
+		}
+		c.mover = rebalance.NewGRPCMover(c.dialOptions()...)
+		c.rebalancer = rebalance.New(config, c.mover)
+	}
+
+	c.grpcServer = grpc.NewServer()
+	pb.RegisterCoordinatorServiceServer(c.grpcServer, c)
+
+	c.log.Info("Starting", "port", c.config.Port, "heartbeat_timeout", c.config.HeartbeatTimeout)
+
+	go func() {
+		if err := c.grpcServer.Serve(listener); err != nil {
+			c.log.Error("gRPC server error", logging.Err(err))
+		}
+	}()
+
+	go c.checkLiveness()
+
+	if c.rebalancer != nil {
+		go c.rebalance()
+	}
+
+	if c.config.DashboardPort > 0 {
+		if err := c.startDashboard(); err != nil {
+			c.Stop()
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Stop gracefully stops the coordinator
+func (c *Coordinator) Stop() {
+	c.stopOnce.Do(func() {
+		// Signal watch streams first so GracefulStop doesn't wait on them
+		close(c.shutdownCh)
+
+		if c.rebalancer != nil {
+			c.rebalancer.Stop()
+			c.mover.Close()
+		}
+
+		if c.grpcServer != nil {
+			c.grpcServer.GracefulStop()
+		}
+		if c.dashboardServer != nil {
+			c.dashboardServer.Close()
+		}
+
+		c.mu.Lock()
+		for _, m := range c.members {
+			c.closeMember(m)
+		}
+		c.mu.Unlock()
+		c.log.Info("Stopped")
+	})
+}
+
+// Register adds (or refreshes) a server in the authoritative ring
+func (c *Coordinator) Register(ctx context.Context, req *pb.RegisterRequest) (*pb.RegisterResponse, error) {
+	if req.ServerId == "" || req.Address == "" {
+		return nil, status.Error(codes.InvalidArgument, "server_id and address are required")
+	}
+
+	state := c.register(req.ServerId, req.Address, int(req.Capacity), req.Region, req.Namespace, req.AdminAddress)
+
+	c.mu.Lock()
+	lease := c.grantLease(req.ServerId)
+	c.mu.Unlock()
+
+	return &pb.RegisterResponse{Ring: pb.NewRingState(state), Lease: lease}, nil
+}
+
+// Deregister removes a server from the authoritative ring. The server has
+// stopped accepting writes, so its lease is released rather than left to
+// expire.
+func (c *Coordinator) Deregister(ctx context.Context, req *pb.DeregisterRequest) (*pb.DeregisterResponse, error) {
+	state := c.deregister(req.ServerId, "deregistered")
+
+	c.mu.Lock()
+	c.releaseLease(req.ServerId)
+	c.mu.Unlock()
+
+	return &pb.DeregisterResponse{Ring: pb.NewRingState(state)}, nil
+}
+
+// Heartbeat records liveness for a registered server and renews its lease
+func (c *Coordinator) Heartbeat(ctx context.Context, req *pb.HeartbeatRequest) (*pb.HeartbeatResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	m, ok := c.members[req.ServerId]
+	if ok {
+		m.lastHeartbeat = time.Now()
+	}
+
+	return &pb.HeartbeatResponse{
+		Registered: ok,
+		RingEpoch:  c.ring.Epoch(),
+		Lease:      c.grantLease(req.ServerId),
+	}, nil
+}
+
+// GetRing returns the authoritative ring, or only an acknowledgement if the
+// caller already holds the same view
+func (c *Coordinator) GetRing(ctx context.Context, req *pb.RingStateRequest) (*pb.RingStateResponse, error) {
+	state := c.ring.State()
+	if req.KnownEpoch == state.Epoch && req.KnownDigest == state.Digest() {
+		return &pb.RingStateResponse{InSync: true}, nil
+	}
+	return &pb.RingStateResponse{State: pb.NewRingState(state)}, nil
+}
+
+// WatchTopology streams the ring whenever it changes
+func (c *Coordinator) WatchTopology(req *pb.WatchTopologyRequest, stream pb.CoordinatorService_WatchTopologyServer) error {
+	id, updates := c.addWatcher()
+	defer c.removeWatcher(id)
+
+	if state := c.ring.State(); state.Epoch > req.KnownEpoch {
+		if err := stream.Send(pb.NewRingState(state)); err != nil {
+			return err
+		}
+	}
+
+	for {
+		select {
+		case state := <-updates:
+			if err := stream.Send(pb.NewRingState(state)); err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		case <-c.shutdownCh:
+			return nil
+		}
+	}
+}
+
+// register adds or refreshes a member and publishes the resulting ring
+func (c *Coordinator) register(serverID, address string, capacity int, region, namespace, adminAddress string) ring.RingState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if capacity <= 0 {
+		capacity = c.config.VirtualNodes
+	}
+
+	now := time.Now()
+	if m, ok := c.members[serverID]; ok {
+		m.lastHeartbeat = now
+		if m.address == address && m.capacity == capacity && m.region == region && m.namespace == namespace {
+			if m.adminAddress != adminAddress {
+				c.watchStats(m, adminAddress)
+			}
+			return c.ring.State() // Idempotent re-registration
+		}
+		// Placement changed - re-add with the new address/capacity/region/namespace
+		c.closeMember(m)
+		c.ring.RemoveNode(serverID)
+	}
+
+	m := &member{
+		serverID:      serverID,
+		address:       address,
+		capacity:      capacity,
+		region:        region,
+		namespace:     namespace,
+		registeredAt:  now,
+		lastHeartbeat: now,
+	}
+
+	// Non-blocking dial; probes fail until the server is reachable
+	conn, err := grpc.Dial(address, c.dialOptions()...)
+	if err != nil {
+		c.log.Warn("Cannot probe server", logging.NodeID(serverID), "address", address, logging.Err(err))
+	} else {
+		m.conn = conn
+		m.client = pb.NewChatServiceClient(conn)
+	}
+	c.watchStats(m, adminAddress)
+
+	c.members[serverID] = m
+	c.ring.AddNodeInNamespace(serverID, capacity, address, region, namespace)
+
+	c.log.Info("Registered server", logging.NodeID(serverID), "address", address, "capacity", capacity,
+		"region", region, "namespace", namespace, logging.Epoch(c.ring.Epoch()))
+
+	state := c.ring.State()
+	c.publish(state)
+	return state
+}
+
+// deregister removes a member and publishes the resulting ring
+func (c *Coordinator) deregister(serverID, reason string) ring.RingState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	m, ok := c.members[serverID]
+	if !ok {
+		return c.ring.State()
+	}
+
+	before := c.ring.State()
+	c.closeMember(m)
+	delete(c.members, serverID)
+	c.ring.RemoveNode(serverID)
+
+	c.log.Info("Removed server", logging.NodeID(serverID), "reason", reason, logging.Epoch(c.ring.Epoch()))
+
+	state := c.ring.State()
+	c.recordFailover(serverID, reason, before, state)
+	c.publish(state)
+	return state
+}
+
+// closeMember releases a member's probe connection and stats subscription
+// (must be called with lock held)
+func (c *Coordinator) closeMember(m *member) {
+	if m.stopStats != nil {
+		m.stopStats()
+		m.stopStats = nil
+	}
+	if m.conn != nil {
+		m.conn.Close()
+		m.conn = nil
+		m.client = nil
+	}
+}
+
+// checkLiveness periodically probes members and evicts those that went quiet
+func (c *Coordinator) checkLiveness() {
+	ticker := time.NewTicker(c.config.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.probeMembers()
+			for _, serverID := range c.expiredMembers() {
+				c.deregister(serverID, "heartbeat timeout")
+			}
+		case <-c.shutdownCh:
+			return
+		}
+	}
+}
+
+// probeMembers calls HealthCheck on every member concurrently and counts a
+// healthy answer as a heartbeat
+func (c *Coordinator) probeMembers() {
+	c.mu.RLock()
+	targets := make(map[string]pb.ChatServiceClient, len(c.members))
+	for serverID, m := range c.members {
+		if m.client != nil {
+			targets[serverID] = m.client
+		}
+	}
+	c.mu.RUnlock()
+
+	var wg sync.WaitGroup
+	for serverID, client := range targets {
+		wg.Add(1)
+		go func(serverID string, client pb.ChatServiceClient) {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(context.Background(), c.config.CheckInterval)
+			defer cancel()
+
+			resp, err := client.HealthCheck(ctx, &pb.HealthRequest{})
+			if err != nil || !resp.Healthy {
+				return
+			}
+
+			c.mu.Lock()
+			if m, ok := c.members[serverID]; ok {
+				m.lastHeartbeat = time.Now()
+			}
+			c.mu.Unlock()
+		}(serverID, client)
+	}
+	wg.Wait()
+}
+
+// expiredMembers returns the IDs of members whose heartbeats have lapsed
+func (c *Coordinator) expiredMembers() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var expired []string
+	for serverID, m := range c.members {
+		if time.Since(m.lastHeartbeat) > c.config.HeartbeatTimeout {
+			expired = append(expired, serverID)
+		}
+	}
+	return expired
+}
+
+// rebalance feeds every topology change to the rebalancer. Transfers run on
+// this goroutine, so changes arriving mid-transfer collapse into the latest
+// state and are planned against the last state actually migrated.
+func (c *Coordinator) rebalance() {
+	id, updates := c.addWatcher()
+	defer c.removeWatcher(id)
+
+	c.rebalancer.Apply(c.RingState())
+	for {
+		select {
+		case state := <-updates:
+			c.rebalancer.Apply(state)
+		case <-c.shutdownCh:
+			return
+		}
+	}
+}
+
+// addWatcher registers a topology watcher
+func (c *Coordinator) addWatcher() (int, <-chan ring.RingState) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	id := c.nextID
+	c.nextID++
+	ch := make(chan ring.RingState, 1)
+	c.watchers[id] = ch
+	return id, ch
+}
+
+// removeWatcher unregisters a topology watcher
+func (c *Coordinator) removeWatcher(id int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.watchers, id)
+}
+
+// publish delivers a ring state to every watcher (must be called with lock held).
+// Watchers only ever need the latest state, so a pending older one is replaced.
+func (c *Coordinator) publish(state ring.RingState) {
+	for _, ch := range c.watchers {
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- state
+	}
+}
+
+// RingState returns the authoritative ring
+func (c *Coordinator) RingState() ring.RingState {
+	return c.ring.State()
+}
+
+// GetMembers returns all registered servers
+func (c *Coordinator) GetMembers() []MemberInfo {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	members := make([]MemberInfo, 0, len(c.members))
+	for _, m := range c.members {
+		members = append(members, MemberInfo{
+			ServerID:      m.serverID,
+			Address:       m.address,
+			Capacity:      m.capacity,
+			Region:        m.region,
+			Namespace:     m.namespace,
+			RegisteredAt:  m.registeredAt,
+			LastHeartbeat: m.lastHeartbeat,
+			AdminAddress:  m.adminAddress,
+			Stats:         m.stats,
+		})
+	}
+	return members
+}
+
+// GetAddress returns the coordinator's network address
+func (c *Coordinator) GetAddress() string {
+	return fmt.Sprintf("localhost:%d", c.config.Port)
+}
@@ -0,0 +1,150 @@
+package coordinator
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/pkg/chattest"
+	"github.com/sh4shv4t/DistriChat/pkg/client"
+	"github.com/sh4shv4t/DistriChat/pkg/server"
+	pb "github.com/sh4shv4t/DistriChat/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// startCoordinator starts a coordinator called "coordinator" on network,
+// stopped when the test finishes
+func startCoordinator(t *testing.T, network *chattest.Network, config CoordinatorConfig) *Coordinator {
+	t.Helper()
+	config.Listener = network.Listen("coordinator")
+	config.Dialer = network.Dial
+	coord := NewCoordinator(config)
+	if err := coord.Start(); err != nil {
+		t.Fatalf("Failed to start the coordinator: %v", err)
+	}
+	t.Cleanup(coord.Stop)
+	return coord
+}
+
+// dial connects to an address on network, closed when the test finishes
+func dial(t *testing.T, network *chattest.Network, address string) *grpc.ClientConn {
+	t.Helper()
+	conn, err := grpc.Dial(address, grpc.WithContextDialer(network.Dial),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// nodeIDs lists the servers of a streamed ring
+func nodeIDs(state *pb.RingState) []string {
+	var ids []string
+	for _, node := range state.ToRing().Nodes {
+		ids = append(ids, node.NodeID)
+	}
+	return ids
+}
+
+func TestRegisterWatchDeregister(t *testing.T) {
+	network := chattest.NewNetwork()
+	coord := startCoordinator(t, network, CoordinatorConfig{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	watch, err := pb.NewCoordinatorServiceClient(dial(t, network, "coordinator")).
+		WatchTopology(ctx, &pb.WatchTopologyRequest{})
+	if err != nil {
+		t.Fatalf("WatchTopology failed: %v", err)
+	}
+
+	// A server joining registers, and watchers get the ring with it
+	srv := server.NewChatServer(server.ServerConfig{
+		ServerID:         "server-1",
+		AdvertiseAddress: "server-1",
+		Listener:         network.Listen("server-1"),
+		Dialer:           network.Dial,
+		Coordinator:      "coordinator",
+	})
+	if err := srv.Start(); err != nil {
+		t.Fatalf("Failed to start server-1: %v", err)
+	}
+	t.Cleanup(srv.Stop)
+
+	state, err := watch.Recv()
+	if err != nil {
+		t.Fatalf("Expected the ring streamed, got %v", err)
+	}
+	if ids := nodeIDs(state); len(ids) != 1 || ids[0] != "server-1" {
+		t.Errorf("Expected a ring of server-1, got %v", ids)
+	}
+	if members := coord.GetMembers(); len(members) != 1 || members[0].Address != "server-1" {
+		t.Errorf("Expected server-1 registered, got %+v", members)
+	}
+
+	// A client following the coordinator routes to it without AddServer
+	cl := client.NewSmartClient(client.ClientConfig{Dialer: network.Dial})
+	defer cl.Close()
+	if err := cl.FollowCoordinator("coordinator"); err != nil {
+		t.Fatalf("FollowCoordinator failed: %v", err)
+	}
+	if resp, err := cl.SendMessage("chat-1", "alice", "hello"); err != nil || resp.ServerId != "server-1" {
+		t.Errorf("Expected the message stored by server-1, got %v (%v)", resp, err)
+	}
+
+	// Shutting down deregisters it, and watchers get the empty ring
+	if err := srv.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+	state, err = watch.Recv()
+	if err != nil {
+		t.Fatalf("Expected the ring streamed, got %v", err)
+	}
+	if ids := nodeIDs(state); len(ids) != 0 {
+		t.Errorf("Expected an empty ring, got %v", ids)
+	}
+	if failovers := coord.RecentFailovers(); len(failovers) != 1 || failovers[0].Reason != "deregistered" {
+		t.Errorf("Expected server-1's departure recorded, got %+v", failovers)
+	}
+}
+
+func TestEvictsSilentServers(t *testing.T) {
+	network := chattest.NewNetwork()
+	coord := startCoordinator(t, network, CoordinatorConfig{
+		HeartbeatTimeout: 100 * time.Millisecond,
+		CheckInterval:    20 * time.Millisecond,
+	})
+	coordinator := pb.NewCoordinatorServiceClient(dial(t, network, "coordinator"))
+
+	// Nothing listens at "ghost", so probes fail as heartbeats stop
+	resp, err := coordinator.Register(context.Background(), &pb.RegisterRequest{ServerId: "ghost", Address: "ghost"})
+	if err != nil || len(nodeIDs(resp.Ring)) != 1 {
+		t.Fatalf("Expected ghost registered, got %v (%v)", resp, err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(coord.GetMembers()) > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if members := coord.GetMembers(); len(members) != 0 {
+		t.Fatalf("Expected ghost evicted, got %+v", members)
+	}
+	if failovers := coord.RecentFailovers(); len(failovers) != 1 || failovers[0].Reason != "heartbeat timeout" {
+		t.Errorf("Expected ghost's eviction recorded, got %+v", failovers)
+	}
+}
+
+func TestRegisterRequiresIDAndAddress(t *testing.T) {
+	network := chattest.NewNetwork()
+	startCoordinator(t, network, CoordinatorConfig{})
+	coordinator := pb.NewCoordinatorServiceClient(dial(t, network, "coordinator"))
+
+	_, err := coordinator.Register(context.Background(), &pb.RegisterRequest{ServerId: "server-1"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument without an address, got %v", err)
+	}
+}
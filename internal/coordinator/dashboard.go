@@ -0,0 +1,221 @@
+package coordinator
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/pkg/logging"
+	"github.com/sh4shv4t/DistriChat/pkg/ring"
+)
+
+// dashboardPage is the web dashboard, which polls /api/cluster
+//
+//go:embed dashboard.html
+var dashboardPage []byte
+
+// startDashboard serves the web dashboard on DashboardPort
+func (c *Coordinator) startDashboard() error {
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", c.config.DashboardPort))
+	if err != nil {
+		return fmt.Errorf("failed to listen on dashboard port %d: %w", c.config.DashboardPort, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", c.serveDashboard)
+	mux.HandleFunc("/api/cluster", c.serveCluster)
+	c.dashboardServer = &http.Server{Handler: mux}
+
+	c.log.Info("Serving dashboard", "port", c.config.DashboardPort)
+	go func() {
+		if err := c.dashboardServer.Serve(listener); err != nil && err != http.ErrServerClosed {
+			c.log.Error("Dashboard server error", logging.Err(err))
+		}
+	}()
+	return nil
+}
+
+// serveDashboard writes the dashboard page
+func (c *Coordinator) serveDashboard(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(dashboardPage)
+}
+
+// clusterView is the JSON the dashboard renders
+type clusterView struct {
+	IntervalMs int64          `json:"interval_ms"` // How often stats arrive
+	Epoch      uint64         `json:"epoch"`
+	Rings      []ringView     `json:"rings"`
+	Members    []memberView   `json:"members"`
+	Failovers  []failoverView `json:"failovers"`
+}
+
+// ringView is the ring of one namespace and region
+type ringView struct {
+	Namespace string    `json:"namespace"`
+	Region    string    `json:"region"`
+	Nodes     []arcView `json:"nodes"`
+}
+
+// arcView is one node's part of a ring: the arcs it owns, as [start, end]
+// key hashes, and their share of the hash space
+type arcView struct {
+	NodeID string      `json:"node_id"`
+	Weight int         `json:"weight"`
+	Share  float64     `json:"share"`
+	Arcs   [][2]uint32 `json:"arcs"`
+}
+
+// memberView is a registered server and its latest stats
+type memberView struct {
+	ServerID       string  `json:"server_id"`
+	Address        string  `json:"address"`
+	Region         string  `json:"region"`
+	Namespace      string  `json:"namespace"`
+	Capacity       int     `json:"capacity"`
+	HeartbeatAgeMs int64   `json:"heartbeat_age_ms"`
+	Stats          *stats  `json:"stats"`
+	StatsAgeMs     int64   `json:"stats_age_ms"`
+	RequestRate    float64 `json:"request_rate"`
+}
+
+// stats is the part of a StatsSnapshot the dashboard shows
+type stats struct {
+	State         string `json:"state"`
+	UptimeSeconds int64  `json:"uptime_seconds"`
+	L1Size        int32  `json:"l1_size"`
+	L1Capacity    int32  `json:"l1_capacity"`
+	L2Size        int32  `json:"l2_size"`
+	L2Capacity    int32  `json:"l2_capacity"`
+	TotalRequests int64  `json:"total_requests"`
+	CacheHits     int64  `json:"cache_hits"`
+	CacheMisses   int64  `json:"cache_misses"`
+	L1Hits        int64  `json:"l1_hits"`
+	L2Hits        int64  `json:"l2_hits"`
+	Evictions     int64  `json:"evictions"`
+	RateLimited   int64  `json:"rate_limited"`
+}
+
+// failoverView is a Failover for the dashboard
+type failoverView struct {
+	Time      time.Time          `json:"time"`
+	ServerID  string             `json:"server_id"`
+	Reason    string             `json:"reason"`
+	Epoch     uint64             `json:"epoch"`
+	TakenOver map[string]float64 `json:"taken_over"`
+}
+
+// serveCluster writes the ring, the members and their stats, and the
+// recent failovers as JSON
+func (c *Coordinator) serveCluster(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(c.clusterView())
+}
+
+// clusterView captures what the dashboard shows
+func (c *Coordinator) clusterView() clusterView {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	state := c.ring.State()
+	view := clusterView{
+		IntervalMs: c.config.CheckInterval.Milliseconds(),
+		Epoch:      state.Epoch,
+		Rings:      ringViews(state, c.rangeOptions()...),
+		Members:    make([]memberView, 0, len(c.members)),
+		Failovers:  make([]failoverView, 0, len(c.failovers)),
+	}
+
+	now := time.Now()
+	for _, m := range c.members {
+		mv := memberView{
+			ServerID:       m.serverID,
+			Address:        m.address,
+			Region:         m.region,
+			Namespace:      m.namespace,
+			Capacity:       m.capacity,
+			HeartbeatAgeMs: now.Sub(m.lastHeartbeat).Milliseconds(),
+			RequestRate:    m.requestRate,
+		}
+		if s := m.stats; s != nil {
+			mv.StatsAgeMs = now.Sub(m.statsAt).Milliseconds()
+			mv.Stats = &stats{
+				State:         strings.TrimPrefix(s.State.String(), "SERVER_STATE_"),
+				UptimeSeconds: s.UptimeSeconds,
+				L1Size:        s.L1Size,
+				L1Capacity:    s.L1Capacity,
+				L2Size:        s.L2Size,
+				L2Capacity:    s.L2Capacity,
+				TotalRequests: s.TotalRequests,
+				CacheHits:     s.CacheHits,
+				CacheMisses:   s.CacheMisses,
+				L1Hits:        s.L1Hits,
+				L2Hits:        s.L2Hits,
+				Evictions:     s.Evictions,
+				RateLimited:   s.RateLimited,
+			}
+		}
+		view.Members = append(view.Members, mv)
+	}
+	sort.Slice(view.Members, func(i, j int) bool {
+		return view.Members[i].ServerID < view.Members[j].ServerID
+	})
+
+	// Newest first
+	for i := len(c.failovers) - 1; i >= 0; i-- {
+		f := c.failovers[i]
+		view.Failovers = append(view.Failovers, failoverView(f))
+	}
+	return view
+}
+
+// ringViews splits state into the rings of its namespaces and regions,
+// each node with the arcs it owns under opts
+func ringViews(state ring.RingState, opts ...ring.Option) []ringView {
+	type key struct{ namespace, region string }
+	groups := make(map[key]*ringView)
+	var order []key
+
+	for _, node := range state.Nodes {
+		k := key{node.Namespace, node.Region}
+		rv, ok := groups[k]
+		if !ok {
+			rv = &ringView{Namespace: node.Namespace, Region: node.Region}
+			groups[k] = rv
+			order = append(order, k)
+		}
+
+		arcs := [][2]uint32{}
+		for _, r := range ring.OwnedRanges(state, node.NodeID, opts...) {
+			arcs = append(arcs, [2]uint32{r.Start, r.End})
+		}
+		rv.Nodes = append(rv.Nodes, arcView{
+			NodeID: node.NodeID,
+			Weight: node.Capacity,
+			Share:  ring.Share(state, node.NodeID, opts...),
+			Arcs:   arcs,
+		})
+	}
+
+	sort.Slice(order, func(i, j int) bool {
+		if order[i].namespace != order[j].namespace {
+			return order[i].namespace < order[j].namespace
+		}
+		return order[i].region < order[j].region
+	})
+	views := make([]ringView, 0, len(order))
+	for _, k := range order {
+		views = append(views, *groups[k])
+	}
+	return views
+}
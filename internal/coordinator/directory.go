@@ -0,0 +1,106 @@
+package coordinator
+
+import (
+	"context"
+	"sort"
+
+	"github.com/sh4shv4t/DistriChat/pkg/rebalance"
+	"github.com/sh4shv4t/DistriChat/pkg/ring"
+	pb "github.com/sh4shv4t/DistriChat/proto"
+)
+
+// ChatLocation is one chat's entry in the chat directory
+type ChatLocation struct {
+	ring.Placement
+
+	// Previous owner per region while the rebalancer is still moving the
+	// chat to its new owner
+	MigratingFrom map[string]string
+}
+
+// Locate looks chats in the default namespace up in the directory
+func (c *Coordinator) Locate(chatIDs ...string) []ChatLocation {
+	return c.LocateInNamespace("", chatIDs...)
+}
+
+// LocateInNamespace looks chats up in the directory, placing them on the
+// given namespace's servers. Every location is computed from the same
+// authoritative ring, whose epoch they all carry.
+func (c *Coordinator) LocateInNamespace(namespace string, chatIDs ...string) []ChatLocation {
+	// Ring changes happen under c.mu, so holding it keeps the epoch and
+	// the placements consistent
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var pending []rebalance.Transfer
+	if c.rebalancer != nil && c.rebalancer.Epoch() == c.ring.Epoch() {
+		pending = c.rebalancer.Pending()
+	}
+
+	placement := c.ring.Namespace(namespace)
+	locations := make([]ChatLocation, 0, len(chatIDs))
+	for _, chatID := range chatIDs {
+		location := ChatLocation{
+			Placement:     placement.Place(chatID, c.config.ReplicationFactor),
+			MigratingFrom: make(map[string]string),
+		}
+
+		hash := c.ring.KeyHash(chatID)
+		for region := range location.Replicas {
+			owner, _ := location.Owner(region)
+			for _, transfer := range pending {
+				if transfer.To == owner.NodeID && moves(transfer, hash) {
+					location.MigratingFrom[region] = transfer.From
+					break
+				}
+			}
+		}
+		locations = append(locations, location)
+	}
+	return locations
+}
+
+// moves reports whether a transfer covers the given key hash
+func moves(transfer rebalance.Transfer, hash uint32) bool {
+	for _, r := range transfer.Ranges {
+		if r.Contains(hash) {
+			return true
+		}
+	}
+	return false
+}
+
+// LocateChats answers chat directory lookups
+func (c *Coordinator) LocateChats(ctx context.Context, req *pb.LocateChatsRequest) (*pb.LocateChatsResponse, error) {
+	resp := &pb.LocateChatsResponse{Epoch: c.ring.Epoch()}
+
+	for _, location := range c.LocateInNamespace(req.Namespace, req.ChatIds...) {
+		resp.Epoch = location.Epoch
+
+		regions := make([]string, 0, len(location.Replicas))
+		for region := range location.Replicas {
+			regions = append(regions, region)
+		}
+		sort.Strings(regions)
+
+		entry := &pb.ChatLocation{ChatId: location.Key}
+		for _, region := range regions {
+			replicas := &pb.RegionReplicas{
+				Region:        region,
+				MigratingFrom: location.MigratingFrom[region],
+			}
+			for _, node := range location.Replicas[region] {
+				weight, _ := c.ring.GetNodeCapacity(node.NodeID)
+				replicas.Replicas = append(replicas.Replicas, &pb.RingNode{
+					NodeId:  node.NodeID,
+					Address: node.Address,
+					Weight:  int32(weight),
+					Region:  node.Region,
+				})
+			}
+			entry.Regions = append(entry.Regions, replicas)
+		}
+		resp.Locations = append(resp.Locations, entry)
+	}
+	return resp, nil
+}
@@ -0,0 +1,71 @@
+package coordinator
+
+import (
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/pkg/ring"
+	pb "github.com/sh4shv4t/DistriChat/proto"
+)
+
+// lease is one grant of write ownership over a set of ranges
+type lease struct {
+	ranges  []ring.HashRange
+	expires time.Time
+}
+
+// grantLease renews a member's lease over the ranges it owns in the
+// current ring, minus any range another server's unexpired lease still
+// covers. A server that lost ranges to a ring change or was evicted may
+// keep writing to them until its last grant runs out, so the new owner only
+// gets them afterwards. Returns nil if leases are disabled or serverID is
+// not registered (must be called with lock held).
+func (c *Coordinator) grantLease(serverID string) *pb.OwnershipLease {
+	if c.config.LeaseDuration <= 0 {
+		return nil
+	}
+	if _, ok := c.members[serverID]; !ok {
+		return nil
+	}
+
+	now := time.Now()
+	var fenced []ring.HashRange
+	for holder, grants := range c.leases {
+		live := grants[:0]
+		for _, g := range grants {
+			if g.expires.After(now) {
+				live = append(live, g)
+			}
+		}
+		if len(live) == 0 {
+			delete(c.leases, holder)
+			continue
+		}
+		c.leases[holder] = live
+
+		if holder != serverID {
+			for _, g := range live {
+				fenced = append(fenced, g.ranges...)
+			}
+		}
+	}
+
+	state := c.ring.State()
+	ranges := ring.SubtractRanges(ring.OwnedRanges(state, serverID, c.rangeOptions()...), fenced)
+	c.leases[serverID] = append(c.leases[serverID], lease{
+		ranges:  ranges,
+		expires: now.Add(c.config.LeaseDuration),
+	})
+
+	return &pb.OwnershipLease{
+		Epoch:      state.Epoch,
+		Ranges:     pb.NewHashRanges(ranges),
+		DurationMs: c.config.LeaseDuration.Milliseconds(),
+	}
+}
+
+// releaseLease drops a server's grants, freeing its ranges at once. Only
+// for servers that stopped writing before leaving (must be called with lock
+// held).
+func (c *Coordinator) releaseLease(serverID string) {
+	delete(c.leases, serverID)
+}
@@ -0,0 +1,107 @@
+package coordinator
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/pkg/chattest"
+	"github.com/sh4shv4t/DistriChat/pkg/ring"
+	"github.com/sh4shv4t/DistriChat/pkg/server"
+	pb "github.com/sh4shv4t/DistriChat/proto"
+)
+
+// joinServer starts a server on network that registers with the
+// coordinator, heartbeating every 20ms
+func joinServer(t *testing.T, network *chattest.Network, id string) *server.ChatServer {
+	t.Helper()
+	srv := server.NewChatServer(server.ServerConfig{
+		ServerID:          id,
+		AdvertiseAddress:  id,
+		Listener:          network.Listen(id),
+		Dialer:            network.Dial,
+		Coordinator:       "coordinator",
+		HeartbeatInterval: 20 * time.Millisecond,
+	})
+	if err := srv.Start(); err != nil {
+		t.Fatalf("Failed to start %s: %v", id, err)
+	}
+	t.Cleanup(srv.Stop)
+	return srv
+}
+
+// post writes to chatID on the server at address, returning the error code
+func post(t *testing.T, network *chattest.Network, address, chatID string) pb.ErrorCode {
+	t.Helper()
+	resp, err := pb.NewChatServiceClient(dial(t, network, address)).PostMessage(context.Background(),
+		&pb.ChatRequest{ChatId: chatID, SenderId: "alice", Content: &pb.ChatRequest_Text{Text: "hello"}})
+	if err != nil {
+		t.Fatalf("PostMessage to %s failed: %v", address, err)
+	}
+	return resp.ErrorCode
+}
+
+func TestLeaseFencesMovedRanges(t *testing.T) {
+	const leaseDuration = 300 * time.Millisecond
+	network := chattest.NewNetwork()
+	coord := startCoordinator(t, network, CoordinatorConfig{
+		LeaseDuration:    leaseDuration,
+		HeartbeatTimeout: time.Minute,
+	})
+	joinServer(t, network, "server-a")
+
+	// server-b joins and takes over some of server-a's chats
+	joined := time.Now()
+	joinServer(t, network, "server-b")
+	placement := ring.NewHashRing(0)
+	placement.Replace(coord.RingState())
+	moved := ""
+	for i := 0; moved == ""; i++ {
+		if owner, _, _ := placement.GetNode(fmt.Sprintf("chat-%d", i)); owner == "server-b" {
+			moved = fmt.Sprintf("chat-%d", i)
+		}
+	}
+
+	// server-a's last grant still covers the chat, so server-b's lease
+	// excludes it, while server-a no longer owns it
+	if code := post(t, network, "server-b", moved); code != pb.ErrorCode_ERROR_NO_LEASE {
+		t.Fatalf("Expected %s fenced on server-b, got %v", moved, code)
+	}
+	if code := post(t, network, "server-a", moved); code != pb.ErrorCode_ERROR_NOT_OWNER {
+		t.Errorf("Expected %s refused by server-a, got %v", moved, code)
+	}
+
+	// Once server-a's grant runs out, server-b's next renewal covers it
+	deadline := time.Now().Add(5 * time.Second)
+	code := post(t, network, "server-b", moved)
+	for code == pb.ErrorCode_ERROR_NO_LEASE && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		code = post(t, network, "server-b", moved)
+	}
+	if code != pb.ErrorCode_ERROR_NONE {
+		t.Fatalf("Expected %s accepted by server-b after server-a's grant expired, got %v", moved, code)
+	}
+	if waited := time.Since(joined); waited < leaseDuration {
+		t.Errorf("Expected server-b fenced for server-a's lease of %v, accepted after %v", leaseDuration, waited)
+	}
+}
+
+func TestExpiredLeaseRejectsWrites(t *testing.T) {
+	network := chattest.NewNetwork()
+	coord := startCoordinator(t, network, CoordinatorConfig{
+		LeaseDuration:    200 * time.Millisecond,
+		HeartbeatTimeout: time.Minute,
+	})
+	joinServer(t, network, "server-a")
+	if code := post(t, network, "server-a", "chat-1"); code != pb.ErrorCode_ERROR_NONE {
+		t.Fatalf("Expected the write accepted under the lease, got %v", code)
+	}
+
+	// Without the coordinator, heartbeats fail and the lease isn't renewed
+	coord.Stop()
+	time.Sleep(300 * time.Millisecond)
+	if code := post(t, network, "server-a", "chat-1"); code != pb.ErrorCode_ERROR_NO_LEASE {
+		t.Errorf("Expected the write refused with an expired lease, got %v", code)
+	}
+}
@@ -0,0 +1,142 @@
+package coordinator
+
+import (
+	"context"
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/pkg/logging"
+	"github.com/sh4shv4t/DistriChat/pkg/ring"
+	pb "github.com/sh4shv4t/DistriChat/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// adminTokenHeader is the metadata key servers read the admin token from
+	adminTokenHeader = "x-admin-token"
+
+	// maxFailovers is how many removals are remembered
+	maxFailovers = 20
+)
+
+// Failover records a server leaving the ring and the servers that took
+// over its keys
+type Failover struct {
+	Time     time.Time
+	ServerID string
+	Reason   string // "heartbeat timeout" or "deregistered"
+	Epoch    uint64 // Ring epoch without the server
+
+	// Fraction of its namespace and region's hash space each remaining
+	// server gained
+	TakenOver map[string]float64
+}
+
+// RecentFailovers returns the most recent removals from the ring, oldest
+// first
+func (c *Coordinator) RecentFailovers() []Failover {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return append([]Failover(nil), c.failovers...)
+}
+
+// recordFailover remembers serverID's removal, which changed the ring from
+// before to after (must be called with lock held)
+func (c *Coordinator) recordFailover(serverID, reason string, before, after ring.RingState) {
+	taken := make(map[string]float64)
+	for _, node := range after.Nodes {
+		if gain := ring.Share(after, node.NodeID) - ring.Share(before, node.NodeID); gain > 0 {
+			taken[node.NodeID] = gain
+		}
+	}
+
+	c.failovers = append(c.failovers, Failover{
+		Time:      time.Now(),
+		ServerID:  serverID,
+		Reason:    reason,
+		Epoch:     after.Epoch,
+		TakenOver: taken,
+	})
+	if n := len(c.failovers); n > maxFailovers {
+		c.failovers = append(c.failovers[:0], c.failovers[n-maxFailovers:]...)
+	}
+}
+
+// watchStats (re)subscribes to a member's stats at adminAddress, dropping
+// any earlier subscription; "" only drops it (must be called with lock
+// held)
+func (c *Coordinator) watchStats(m *member, adminAddress string) {
+	if m.stopStats != nil {
+		m.stopStats()
+		m.stopStats = nil
+	}
+	m.adminAddress = adminAddress
+	m.stats, m.requestRate = nil, 0
+	if adminAddress == "" {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m.stopStats = cancel
+	go c.followStats(ctx, m, adminAddress)
+}
+
+// followStats records the stats of m's admin service at address until ctx
+// is cancelled, resubscribing every CheckInterval after a failure
+func (c *Coordinator) followStats(ctx context.Context, m *member, address string) {
+	conn, err := grpc.Dial(address, c.dialOptions()...)
+	if err != nil {
+		c.log.Warn("Cannot follow server stats", logging.NodeID(m.serverID), "address", address,
+			logging.Err(err))
+		return
+	}
+	defer conn.Close()
+
+	admin := pb.NewAdminServiceClient(conn)
+	if c.config.AdminToken != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, adminTokenHeader, c.config.AdminToken)
+	}
+
+	for ctx.Err() == nil {
+		err := c.streamStats(ctx, admin, m)
+		if ctx.Err() != nil {
+			return
+		}
+		c.log.Debug("Stats stream failed", logging.NodeID(m.serverID), logging.Err(err))
+
+		select {
+		case <-time.After(c.config.CheckInterval):
+		case <-ctx.Done():
+		}
+	}
+}
+
+// streamStats records the snapshots m's admin service pushes every
+// CheckInterval until the stream fails
+func (c *Coordinator) streamStats(ctx context.Context, admin pb.AdminServiceClient, m *member) error {
+	stream, err := admin.SubscribeStats(ctx, &pb.SubscribeStatsRequest{
+		IntervalMs: int32(c.config.CheckInterval / time.Millisecond),
+	})
+	if err != nil {
+		return err
+	}
+
+	for {
+		snapshot, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+
+		c.mu.Lock()
+		if c.members[m.serverID] == m {
+			now := time.Now()
+			if prev := m.stats; prev != nil && snapshot.TotalRequests >= prev.TotalRequests {
+				if elapsed := now.Sub(m.statsAt).Seconds(); elapsed > 0 {
+					m.requestRate = float64(snapshot.TotalRequests-prev.TotalRequests) / elapsed
+				}
+			}
+			m.stats, m.statsAt = snapshot, now
+		}
+		c.mu.Unlock()
+	}
+}
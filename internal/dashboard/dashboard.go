@@ -0,0 +1,255 @@
+// Package dashboard is a terminal UI for watching a DistriChat cluster. It
+// subscribes to every server's stats stream on the admin port and follows
+// the ring through the chat port, and redraws a table of each server's
+// health, cache occupancy and hit rates, and how keys and requests are
+// spread over the servers, whenever a server reports.
+//
+// A server whose stream breaks is shown as down and resubscribed to until
+// it answers again, so the dashboard can be started before the cluster and
+// keeps running through failovers.
+package dashboard
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/sh4shv4t/DistriChat/pkg/logging"
+	"github.com/sh4shv4t/DistriChat/pkg/ring"
+	pb "github.com/sh4shv4t/DistriChat/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+)
+
+// adminTokenHeader is the metadata key servers read the admin token from
+const adminTokenHeader = "x-admin-token"
+
+// Target is one server to watch
+type Target struct {
+	// Shown until the server reports its own ID
+	Name string
+
+	// Chat service address, for the ring view ("" to not ask this server)
+	Address string
+
+	// Admin service address, for the stats stream
+	AdminAddress string
+}
+
+// DashboardConfig contains configuration for a dashboard
+type DashboardConfig struct {
+	Servers []Target
+
+	// Sent to the servers' admin services, if they require one
+	AdminToken string
+
+	// How often servers report and the ring is refreshed (default: 1s)
+	Interval time.Duration
+
+	// Where the ring view comes from, e.g. a client's RingState (default:
+	// the first server that answers GetRingState)
+	RingState func() ring.RingState
+
+	// Terminal to draw on and read keys from (default: stdout and stdin)
+	Output io.Writer
+	Input  io.Reader
+}
+
+// Dashboard draws a cluster's live state in the terminal
+type Dashboard struct {
+	config DashboardConfig
+	log    *slog.Logger
+
+	// Admin and chat connections by address
+	mu    sync.Mutex
+	conns map[string]*grpc.ClientConn
+}
+
+// NewDashboard creates a dashboard for the configured servers
+func NewDashboard(config DashboardConfig) *Dashboard {
+	if config.Interval <= 0 {
+		config.Interval = time.Second
+	}
+	if config.Output == nil {
+		config.Output = os.Stdout
+	}
+	if config.Input == nil {
+		config.Input = os.Stdin
+	}
+
+	return &Dashboard{
+		config: config,
+		log:    logging.Logger("dashboard"),
+		conns:  make(map[string]*grpc.ClientConn),
+	}
+}
+
+// Run takes over the terminal and draws the cluster until the user quits
+// (q or Ctrl+C) or ctx is done
+func (d *Dashboard) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	defer d.closeConns()
+
+	program := tea.NewProgram(newModel(d.config.Servers),
+		tea.WithContext(ctx),
+		tea.WithAltScreen(),
+		tea.WithOutput(d.config.Output),
+		tea.WithInput(d.config.Input),
+	)
+
+	for i, target := range d.config.Servers {
+		go d.watchStats(ctx, program, i, target)
+	}
+	go d.watchRing(ctx, program)
+
+	_, err := program.Run()
+	if err == tea.ErrProgramKilled && ctx.Err() != nil {
+		return nil // Stopped through ctx
+	}
+	return err
+}
+
+// snapshotMsg carries a server's latest stats
+type snapshotMsg struct {
+	server   int
+	snapshot *pb.StatsSnapshot
+}
+
+// downMsg reports that a server's stats stream failed
+type downMsg struct {
+	server int
+	err    error
+}
+
+// ringMsg carries the cluster's ring view
+type ringMsg struct {
+	state ring.RingState
+}
+
+// watchStats feeds the program target's stats until ctx is done,
+// resubscribing after every failure
+func (d *Dashboard) watchStats(ctx context.Context, program *tea.Program, server int, target Target) {
+	for ctx.Err() == nil {
+		err := d.subscribe(ctx, program, server, target)
+		if ctx.Err() != nil {
+			return
+		}
+		d.log.Debug("Stats stream failed", logging.NodeID(target.Name), logging.Err(err))
+		program.Send(downMsg{server: server, err: err})
+
+		select {
+		case <-time.After(d.config.Interval):
+		case <-ctx.Done():
+		}
+	}
+}
+
+// subscribe streams target's stats into the program until the stream fails
+func (d *Dashboard) subscribe(ctx context.Context, program *tea.Program, server int, target Target) error {
+	conn, err := d.conn(target.AdminAddress)
+	if err != nil {
+		return err
+	}
+	if d.config.AdminToken != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, adminTokenHeader, d.config.AdminToken)
+	}
+
+	stream, err := pb.NewAdminServiceClient(conn).SubscribeStats(ctx, &pb.SubscribeStatsRequest{
+		IntervalMs: int32(d.config.Interval / time.Millisecond),
+	})
+	if err != nil {
+		return err
+	}
+	for {
+		snapshot, err := stream.Recv()
+		if err == io.EOF {
+			return fmt.Errorf("server stopped")
+		}
+		if err != nil {
+			return err
+		}
+		program.Send(snapshotMsg{server: server, snapshot: snapshot})
+	}
+}
+
+// watchRing feeds the program the ring view of the first server that
+// answers, every interval until ctx is done
+func (d *Dashboard) watchRing(ctx context.Context, program *tea.Program) {
+	ticker := time.NewTicker(d.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		if d.config.RingState != nil {
+			program.Send(ringMsg{state: d.config.RingState()})
+		} else {
+			for _, target := range d.config.Servers {
+				if target.Address == "" {
+					continue
+				}
+				state, err := d.ringState(ctx, target.Address)
+				if err != nil {
+					continue
+				}
+				program.Send(ringMsg{state: state})
+				break
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// ringState asks the server at address for its ring view
+func (d *Dashboard) ringState(ctx context.Context, address string) (ring.RingState, error) {
+	conn, err := d.conn(address)
+	if err != nil {
+		return ring.RingState{}, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, d.config.Interval)
+	defer cancel()
+
+	resp, err := pb.NewChatServiceClient(conn).GetRingState(ctx, &pb.RingStateRequest{})
+	if err != nil {
+		return ring.RingState{}, err
+	}
+	return resp.State.ToRing(), nil
+}
+
+// conn returns the connection to address, dialling it the first time.
+// Connections reconnect by themselves, so one that failed is kept.
+func (d *Dashboard) conn(address string) (*grpc.ClientConn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if conn, ok := d.conns[address]; ok {
+		return conn, nil
+	}
+	conn, err := grpc.Dial(address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", address, err)
+	}
+	d.conns[address] = conn
+	return conn, nil
+}
+
+// closeConns closes every connection
+func (d *Dashboard) closeConns() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for address, conn := range d.conns {
+		conn.Close()
+		delete(d.conns, address)
+	}
+}
@@ -0,0 +1,227 @@
+package dashboard
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/sh4shv4t/DistriChat/pkg/ring"
+	pb "github.com/sh4shv4t/DistriChat/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// barWidth is the width of the occupancy and distribution bars
+const barWidth = 20
+
+var (
+	titleStyle   = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("12"))
+	headerStyle  = lipgloss.NewStyle().Bold(true).Underline(true)
+	servingStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("10"))
+	warnStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("11"))
+	downStyle    = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("9"))
+	dimStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("8"))
+)
+
+// serverView is what the dashboard knows about one server
+type serverView struct {
+	target Target
+
+	// Latest stats, and the rate of requests since the previous ones
+	snapshot *pb.StatsSnapshot
+	rate     float64
+	updated  time.Time
+
+	// Why the stats stream last failed; nil while it is up
+	err       error
+	downSince time.Time
+}
+
+// name returns the server's ID, or its configured name until it reports
+func (v *serverView) name() string {
+	if v.snapshot != nil {
+		return v.snapshot.ServerId
+	}
+	return v.target.Name
+}
+
+// model is the dashboard's bubbletea model
+type model struct {
+	servers []*serverView
+	ring    ring.RingState
+}
+
+func newModel(targets []Target) model {
+	servers := make([]*serverView, len(targets))
+	for i, target := range targets {
+		servers[i] = &serverView{target: target}
+	}
+	return model{servers: servers}
+}
+
+func (m model) Init() tea.Cmd {
+	return nil
+}
+
+func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "q", "ctrl+c", "esc":
+			return m, tea.Quit
+		}
+
+	case snapshotMsg:
+		v := m.servers[msg.server]
+		now := time.Now()
+		if prev := v.snapshot; prev != nil && v.err == nil && msg.snapshot.TotalRequests >= prev.TotalRequests {
+			if elapsed := now.Sub(v.updated).Seconds(); elapsed > 0 {
+				v.rate = float64(msg.snapshot.TotalRequests-prev.TotalRequests) / elapsed
+			}
+		} else {
+			v.rate = 0
+		}
+		v.snapshot, v.updated = msg.snapshot, now
+		v.err, v.downSince = nil, time.Time{}
+
+	case downMsg:
+		v := m.servers[msg.server]
+		if v.err == nil {
+			v.downSince = time.Now()
+		}
+		v.err, v.rate = msg.err, 0
+
+	case ringMsg:
+		m.ring = msg.state
+	}
+	return m, nil
+}
+
+func (m model) View() string {
+	var b strings.Builder
+
+	b.WriteString(titleStyle.Render("DistriChat cluster"))
+	fmt.Fprintf(&b, "  %s\n\n", dimStyle.Render(fmt.Sprintf("ring epoch %d · %d nodes · q to quit",
+		m.ring.Epoch, len(m.ring.Nodes))))
+
+	b.WriteString(headerStyle.Render("Servers"))
+	b.WriteString("\n")
+	fmt.Fprintf(&b, "%-12s %-10s %-*s %-*s %8s %9s %8s\n", "SERVER", "STATE",
+		barWidth+8, "L1", barWidth+8, "L2", "HIT RATE", "L1/L2", "REQ/S")
+	for _, v := range m.servers {
+		b.WriteString(m.serverRow(v))
+		b.WriteString("\n")
+	}
+
+	b.WriteString("\n")
+	b.WriteString(headerStyle.Render("Routing"))
+	b.WriteString("\n")
+	fmt.Fprintf(&b, "%-12s %-*s %-*s\n", "SERVER", barWidth+8, "KEYS (RING)", barWidth+8, "REQUESTS")
+
+	var total int64
+	for _, v := range m.servers {
+		if v.snapshot != nil {
+			total += v.snapshot.TotalRequests
+		}
+	}
+	for _, v := range m.servers {
+		keys := ring.Share(m.ring, v.name())
+		var requests float64
+		if v.snapshot != nil && total > 0 {
+			requests = float64(v.snapshot.TotalRequests) / float64(total)
+		}
+		fmt.Fprintf(&b, "%-12s %s %s\n", v.name(), percentBar(keys), percentBar(requests))
+	}
+
+	return b.String()
+}
+
+// serverRow renders one line of the servers table
+func (m model) serverRow(v *serverView) string {
+	s := v.snapshot
+	if s == nil && v.err == nil {
+		return fmt.Sprintf("%-12s %s", v.name(), dimStyle.Render("connecting..."))
+	}
+	if v.err != nil {
+		down := fmt.Sprintf("%-12s %s", v.name(), downStyle.Render(fmt.Sprintf("%-10s", "DOWN")))
+		return down + dimStyle.Render(fmt.Sprintf(" for %s: %s",
+			time.Since(v.downSince).Round(time.Second), downReason(v.err)))
+	}
+
+	lookups := s.CacheHits + s.CacheMisses
+	hitRate, split := "-", "-"
+	if lookups > 0 {
+		hitRate = fmt.Sprintf("%.1f%%", 100*float64(s.CacheHits)/float64(lookups))
+	}
+	if s.CacheHits > 0 {
+		split = fmt.Sprintf("%d/%d", s.L1Hits, s.L2Hits)
+	}
+
+	return fmt.Sprintf("%-12s %s %s %s %8s %9s %8.1f", v.name(), stateLabel(s.State),
+		occupancyBar(s.L1Size, s.L1Capacity), occupancyBar(s.L2Size, s.L2Capacity),
+		hitRate, split, v.rate)
+}
+
+// downReason summarises why a server's stats stream failed
+func downReason(err error) string {
+	switch status.Code(err) {
+	case codes.Unavailable:
+		return "unreachable"
+	case codes.Unauthenticated, codes.PermissionDenied:
+		return "admin token rejected"
+	case codes.Unknown:
+		return err.Error() // Not from gRPC, e.g. the stream ending
+	default:
+		return status.Convert(err).Message()
+	}
+}
+
+// stateLabel renders a server state in a fixed-width column
+func stateLabel(state pb.ServerState) string {
+	label := fmt.Sprintf("%-10s", strings.TrimPrefix(state.String(), "SERVER_STATE_"))
+	switch state {
+	case pb.ServerState_SERVER_STATE_SERVING:
+		return servingStyle.Render(label)
+	case pb.ServerState_SERVER_STATE_DRAINING:
+		return warnStyle.Render(label)
+	default:
+		return downStyle.Render(label)
+	}
+}
+
+// occupancyBar renders size out of capacity as a bar and a count
+func occupancyBar(size, capacity int32) string {
+	var fraction float64
+	if capacity > 0 {
+		fraction = float64(size) / float64(capacity)
+	}
+	return bar(fraction) + fmt.Sprintf(" %3d/%-3d", size, capacity)
+}
+
+// percentBar renders a fraction as a bar and a percentage
+func percentBar(fraction float64) string {
+	return bar(fraction) + fmt.Sprintf(" %6.1f%%", 100*fraction)
+}
+
+// bar renders a fraction from 0 to 1 as a bar of barWidth cells, yellow
+// from 75% and red when full
+func bar(fraction float64) string {
+	if fraction < 0 {
+		fraction = 0
+	}
+	if fraction > 1 {
+		fraction = 1
+	}
+	filled := int(fraction*barWidth + 0.5)
+
+	style := servingStyle
+	switch {
+	case fraction >= 1:
+		style = downStyle
+	case fraction >= 0.75:
+		style = warnStyle
+	}
+	return style.Render(strings.Repeat("█", filled)) + dimStyle.Render(strings.Repeat("░", barWidth-filled))
+}
@@ -0,0 +1,411 @@
+// Package experiment describes the simulation main.go runs: the servers,
+// cache sizes, workload and events (failures, recoveries, new servers and
+// rolling restarts) of the built-in demo or of an experiment file, adjusted
+// by flags and environment variables. It parses and validates them; running
+// them is main.go's.
+//
+//	servers:
+//	  - {id: Server-A, capacity: 100}
+//	  - {id: Server-B, capacity: 150}
+//	workload: {messages: 100, chats: 25, keys: zipf}
+//	events:
+//	  - {after: 20, kill: Server-B}
+//	  - {after: 60, restart: Server-B}
+package experiment
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/internal/keyspace"
+	"github.com/sh4shv4t/DistriChat/pkg/sim"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// Servers without a port in the settings listen on consecutive ports
+	// from this one
+	FirstPort = 50051
+
+	// Admin ports are the server ports plus this (for the dashboard)
+	AdminPortOffset = 100
+)
+
+// Settings describe the experiment: the built-in demo, or one read from
+// a YAML or JSON file (-config), either adjusted by flags and environment
+// variables (go run main.go -h lists them)
+type Settings struct {
+	Servers []Server `yaml:"servers" json:"servers"`
+
+	// Cache capacities per server, in sessions
+	L1Capacity int `yaml:"l1" json:"l1"` // L1 (VRAM)
+	L2Capacity int `yaml:"l2" json:"l2"` // L2 (RAM)
+
+	Workload Workload `yaml:"workload" json:"workload"`
+	Events   []Event  `yaml:"events" json:"events"`
+
+	// Seed makes the run repeat exactly: the same traffic, failures and
+	// output (nil: fresh randomness and the system clock)
+	Seed *int64 `yaml:"seed" json:"seed,omitempty"`
+
+	// File to write the run's report to, as CSV if it ends in .csv, else
+	// as JSON (empty: none)
+	Report string `yaml:"report" json:"-"`
+
+	// Processes runs each server as a serverd process of its own, so kills
+	// are real crashes (SIGKILL), in place of servers in this process
+	Processes bool   `yaml:"processes" json:"processes"`
+	Serverd   string `yaml:"-" json:"-"` // Path of the serverd binary (-serverd; default: built from cmd/serverd)
+
+	// Flags of a second run to compare this one with (-compare), not part
+	// of the experiment
+	Compare string `yaml:"-" json:"-"`
+}
+
+// Server describes one server
+type Server struct {
+	ID       string `yaml:"id" json:"id"`
+	Port     int    `yaml:"port" json:"port"`         // default: the previous server's plus one
+	Capacity int    `yaml:"capacity" json:"capacity"` // Virtual nodes - affects load distribution (default: 100)
+}
+
+// Workload is the traffic the client sends
+type Workload struct {
+	Messages int           `yaml:"messages" json:"messages"` // Total messages to send
+	Chats    int           `yaml:"chats" json:"chats"`       // Number of unique chat sessions
+	Delay    time.Duration `yaml:"delay" json:"delay_ns"`    // Pause between messages
+	Workers  int           `yaml:"workers" json:"workers"`   // Goroutines sending at once (default: 1)
+	Warmup   int           `yaml:"warmup" json:"warmup"`     // Messages sent first to fill the caches, left out of the statistics
+	QPS      float64       `yaml:"qps" json:"qps"`           // Messages handed out per second, in place of Delay (0: use Delay)
+
+	// How each message picks its chat: sequential (each in turn, the
+	// default), uniform, zipf or hotspot (see internal/keyspace)
+	Keys       keyspace.Distribution `yaml:"keys" json:"keys"`
+	Skew       float64               `yaml:"skew" json:"skew,omitempty"`               // zipf's exponent (default: 1.1)
+	HotKeys    float64               `yaml:"hot_keys" json:"hot_keys,omitempty"`       // hotspot's hot chats, as a fraction (default: 0.1)
+	HotTraffic float64               `yaml:"hot_traffic" json:"hot_traffic,omitempty"` // Fraction of messages to them (default: 0.9)
+}
+
+// KeySpace is the workload's key space: its chats and how they are picked
+func (w Workload) KeySpace() keyspace.Config {
+	return keyspace.Config{
+		Distribution: w.Keys,
+		Keys:         w.Chats,
+		Skew:         w.Skew,
+		HotKeys:      w.HotKeys,
+		HotTraffic:   w.HotTraffic,
+	}
+}
+
+// Interval is the pause between handing out messages
+func (w Workload) Interval() time.Duration {
+	if w.QPS > 0 {
+		return time.Duration(float64(time.Second) / w.QPS)
+	}
+	return w.Delay
+}
+
+// Event is a change to the cluster once a number of messages have been
+// sent: a failure, a recovery, a new server or a rolling restart. Events
+// after the same message happen in order.
+type Event struct {
+	After          int             `yaml:"after" json:"after"`
+	Kill           string          `yaml:"kill" json:"kill,omitempty"`       // ID of the server to stop
+	Restart        string          `yaml:"restart" json:"restart,omitempty"` // ID of a killed server to start again, with empty caches
+	Add            *Server         `yaml:"add" json:"add,omitempty"`         // Server to start and add to the ring
+	RollingRestart *RollingRestart `yaml:"rolling_restart" json:"rolling_restart,omitempty"`
+}
+
+// RollingRestart restarts every server up, one at a time, while messages
+// keep flowing: each is drained and its sessions handed off, then it is
+// restarted and they are handed back. No message should fail.
+type RollingRestart struct {
+	Every int `yaml:"every" json:"every,omitempty"` // Messages between steps (default: 5)
+}
+
+// Spacing is the number of messages between the rolling restart's steps
+func (r RollingRestart) Spacing() int {
+	if r.Every > 0 {
+		return r.Every
+	}
+	return 5
+}
+
+// String describes the event, e.g. "kill Server-B"
+func (e Event) String() string {
+	switch {
+	case e.Kill != "":
+		return "kill " + e.Kill
+	case e.Restart != "":
+		return "restart " + e.Restart
+	case e.Add != nil:
+		return "add " + e.Add.ID
+	case e.RollingRestart != nil:
+		return "rolling restart"
+	}
+	return "nothing"
+}
+
+// Default is the built-in demo: three servers, Server B with more virtual
+// nodes (so more load), which is killed after 10 of 50 messages and
+// restarted after 30, and Server D joining after 40
+func Default() Settings {
+	return Settings{
+		Servers: []Server{
+			{ID: "Server-A", Port: FirstPort, Capacity: 100},
+			{ID: "Server-B", Port: FirstPort + 1, Capacity: 150},
+			{ID: "Server-C", Port: FirstPort + 2, Capacity: 100},
+		},
+		L1Capacity: 5,
+		L2Capacity: 20,
+		Workload:   Workload{Messages: 50, Chats: 25, Delay: 100 * time.Millisecond, Workers: 1},
+		Events: []Event{
+			{After: 10, Kill: "Server-B"},
+			{After: 30, Restart: "Server-B"},
+			{After: 40, Add: &Server{ID: "Server-D", Port: FirstPort + 3, Capacity: 100}},
+		},
+	}
+}
+
+// DemoServers returns n servers on consecutive ports from FirstPort, named
+// Server-A to Server-Z, then Server-AA, Server-AB and so on
+func DemoServers(n, capacity int) []Server {
+	servers := make([]Server, n)
+	for i := range servers {
+		name := ""
+		for j := i; j >= 0; j = j/26 - 1 {
+			name = string(rune('A'+j%26)) + name
+		}
+		servers[i] = Server{ID: "Server-" + name, Port: FirstPort + i, Capacity: capacity}
+	}
+	return servers
+}
+
+// Load reads an experiment file (see Parse)
+func Load(path string) (Settings, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Settings{}, err
+	}
+	s, err := Parse(data)
+	if err != nil {
+		return Settings{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Parse decodes an experiment, YAML or JSON. Unknown fields are errors,
+// and settings left out take the built-in demo's values, except that there
+// are no failures unless events are listed. It isn't validated, since flags
+// may still change it.
+func Parse(data []byte) (Settings, error) {
+	// YAML is a superset of JSON, so one decoder reads both
+	var s Settings
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&s); err != nil {
+		return Settings{}, err
+	}
+
+	defaults := Default()
+	if len(s.Servers) == 0 {
+		s.Servers = defaults.Servers
+	}
+	port := FirstPort
+	fill := func(srv *Server) {
+		if srv.Port == 0 {
+			srv.Port = port
+		}
+		if srv.Capacity == 0 {
+			srv.Capacity = 100
+		}
+		port = srv.Port + 1
+	}
+	for i := range s.Servers {
+		fill(&s.Servers[i])
+	}
+	for _, e := range s.Events {
+		if e.Add != nil {
+			fill(e.Add)
+		}
+	}
+	if s.L1Capacity == 0 {
+		s.L1Capacity = defaults.L1Capacity
+	}
+	if s.L2Capacity == 0 {
+		s.L2Capacity = defaults.L2Capacity
+	}
+	if s.Workload.Messages == 0 {
+		s.Workload.Messages = defaults.Workload.Messages
+	}
+	if s.Workload.Chats == 0 {
+		s.Workload.Chats = defaults.Workload.Chats
+	}
+	if s.Workload.Delay == 0 {
+		s.Workload.Delay = defaults.Workload.Delay
+	}
+	if s.Workload.Workers == 0 {
+		s.Workload.Workers = defaults.Workload.Workers
+	}
+	return s, nil
+}
+
+// Validate checks that the experiment can run. Events must be sorted by
+// After.
+func (s Settings) Validate() error {
+	if len(s.Servers) == 0 {
+		return fmt.Errorf("no servers")
+	}
+	ids := make(map[string]bool)
+	ports := make(map[int]bool)
+	check := func(srv Server) error {
+		switch {
+		case srv.ID == "":
+			return fmt.Errorf("a server has no id")
+		case ids[srv.ID]:
+			return fmt.Errorf("server %s is listed twice", srv.ID)
+		case ports[srv.Port] || ports[srv.Port+AdminPortOffset]:
+			return fmt.Errorf("%s's port %d or admin port %d is used by another server",
+				srv.ID, srv.Port, srv.Port+AdminPortOffset)
+		case srv.Capacity < 1:
+			return fmt.Errorf("server %s needs at least 1 virtual node, got %d", srv.ID, srv.Capacity)
+		}
+		ids[srv.ID] = true
+		ports[srv.Port] = true
+		ports[srv.Port+AdminPortOffset] = true
+		return nil
+	}
+	for _, srv := range s.Servers {
+		if err := check(srv); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case s.L1Capacity < 1 || s.L2Capacity < 1:
+		return fmt.Errorf("cache capacities must be at least 1, got l1 %d and l2 %d", s.L1Capacity, s.L2Capacity)
+	case s.Workload.Messages < 1:
+		return fmt.Errorf("messages must be at least 1, got %d", s.Workload.Messages)
+	case s.Workload.Chats < 1:
+		return fmt.Errorf("chats must be at least 1, got %d", s.Workload.Chats)
+	case s.Workload.Delay < 0:
+		return fmt.Errorf("delay must not be negative, got %v", s.Workload.Delay)
+	case s.Workload.Workers < 1:
+		return fmt.Errorf("workers must be at least 1, got %d", s.Workload.Workers)
+	case s.Workload.QPS < 0:
+		return fmt.Errorf("qps must not be negative, got %v", s.Workload.QPS)
+	case s.Workload.Warmup < 0:
+		return fmt.Errorf("warmup must not be negative, got %d", s.Workload.Warmup)
+	}
+	if _, err := keyspace.New(s.Workload.KeySpace(), sim.NewRand(0)); err != nil {
+		return err
+	}
+
+	// Events are sorted by the time they happen, so the servers up and
+	// down at each can be followed
+	down := make(map[string]bool)
+	rolling := 0 // Messages by which the last rolling restart is done
+	for _, e := range s.Events {
+		if e.After < 1 || e.After > s.Workload.Messages {
+			return fmt.Errorf("event after %d messages is outside the %d sent", e.After, s.Workload.Messages)
+		}
+		if e.After <= rolling {
+			return fmt.Errorf("event after %d messages comes during the rolling restart, which is done after %d", e.After, rolling)
+		}
+		actions := 0
+		for _, set := range []bool{e.Kill != "", e.Restart != "", e.Add != nil, e.RollingRestart != nil} {
+			if set {
+				actions++
+			}
+		}
+		if actions != 1 {
+			return fmt.Errorf("event after %d messages needs exactly one of kill, restart, add or rolling_restart", e.After)
+		}
+		if e.RollingRestart != nil {
+			// A step per server up, each a number of messages apart
+			if e.RollingRestart.Every < 0 {
+				return fmt.Errorf("rolling restart after %d messages: every must not be negative, got %d", e.After, e.RollingRestart.Every)
+			}
+			rolling = e.After + e.RollingRestart.Spacing()*(len(ids)-len(down))
+			if rolling > s.Workload.Messages {
+				return fmt.Errorf("rolling restart after %d messages is done after %d, past the %d sent",
+					e.After, rolling, s.Workload.Messages)
+			}
+			continue
+		}
+		if e.Add != nil {
+			if err := check(*e.Add); err != nil {
+				return fmt.Errorf("event after %d messages: %w", e.After, err)
+			}
+			continue
+		}
+		switch {
+		case e.Kill != "" && !ids[e.Kill]:
+			return fmt.Errorf("event after %d messages kills unknown server %q", e.After, e.Kill)
+		case e.Kill != "" && down[e.Kill]:
+			return fmt.Errorf("event after %d messages kills %s, which is already down", e.After, e.Kill)
+		case e.Restart != "" && !down[e.Restart]:
+			return fmt.Errorf("event after %d messages restarts %q, which isn't down then", e.After, e.Restart)
+		}
+		if e.Kill != "" {
+			down[e.Kill] = true
+		}
+		delete(down, e.Restart)
+	}
+	return nil
+}
+
+// Killed reports whether an event kills the server called id
+func (s Settings) Killed(id string) bool {
+	for _, e := range s.Events {
+		if e.Kill == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Server returns the settings of the server called id, from the start
+// or added later
+func (s Settings) Server(id string) Server {
+	for _, srv := range s.Servers {
+		if srv.ID == id {
+			return srv
+		}
+	}
+	for _, e := range s.Events {
+		if e.Add != nil && e.Add.ID == id {
+			return *e.Add
+		}
+	}
+	return Server{}
+}
+
+// Message is the outcome of one message of a run: the phase it was sent in
+// and whether it failed
+type Message struct {
+	Phase  int
+	Failed bool
+}
+
+// CheckRollingRestart counts the messages sent during a rolling restart's
+// phase, and returns an error if any of them failed: drained servers hand
+// their sessions off before stopping, so none should
+func CheckRollingRestart(messages []Message, phase int) (sent int, err error) {
+	failed := 0
+	for _, m := range messages {
+		if m.Phase != phase {
+			continue
+		}
+		sent++
+		if m.Failed {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return sent, fmt.Errorf("%d of %d messages failed; a drain should lose none", failed, sent)
+	}
+	return sent, nil
+}
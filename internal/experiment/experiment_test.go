@@ -0,0 +1,300 @@
+package experiment
+
+import (
+	"flag"
+	"io"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// parse runs ParseFlags on a fresh flag set with args and the environment
+// variables in env
+func parse(args []string, env map[string]string) (Settings, Output, error) {
+	fs := flag.NewFlagSet("main", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	return ParseFlags(fs, args, func(name string) string { return env[name] })
+}
+
+func TestParseFlags(t *testing.T) {
+	tests := []struct {
+		name  string
+		args  []string
+		env   map[string]string
+		check func(t *testing.T, s Settings, o Output)
+	}{
+		{"demo", nil, nil, func(t *testing.T, s Settings, o Output) {
+			if !reflect.DeepEqual(s, Default()) || o != (Output{}) {
+				t.Errorf("Expected the built-in demo, got %+v, %+v", s, o)
+			}
+		}},
+		{"more servers", []string{"-servers", "28", "-vnodes-b", "200"}, nil, func(t *testing.T, s Settings, o Output) {
+			if len(s.Servers) != 28 || s.Servers[27].ID != "Server-AB" || s.Servers[27].Port != FirstPort+27 {
+				t.Errorf("Expected Server-A to Server-AB, got %+v", s.Servers)
+			}
+			if s.Servers[1].Capacity != 200 || s.Servers[3].Capacity != 100 {
+				t.Errorf("Expected Server-B at 200 virtual nodes and the rest at 100, got %+v", s.Servers)
+			}
+			if add := s.Events[2].Add; add == nil || add.ID != "Server-AC" {
+				t.Errorf("Expected the next server added, got %v", s.Events[2])
+			}
+		}},
+		{"no events", []string{"-kill-after", "0", "-add-after", "0"}, nil, func(t *testing.T, s Settings, o Output) {
+			if len(s.Events) != 0 {
+				t.Errorf("Expected no events, got %v", s.Events)
+			}
+		}},
+		{"events sorted", []string{"-rolling-after", "45", "-add-after", "5", "-messages", "80"}, nil, func(t *testing.T, s Settings, o Output) {
+			var got []string
+			for _, e := range s.Events {
+				got = append(got, e.String())
+			}
+			if want := "add Server-D,kill Server-B,restart Server-B,rolling restart"; strings.Join(got, ",") != want {
+				t.Errorf("Expected events %s, got %v", want, got)
+			}
+		}},
+		{"environment", nil, map[string]string{"MESSAGES": "80", "MESSAGE_DELAY": "5ms", "QPS": "50", "SIM_SEED": "7", "KEYS": "zipf", "QUIET": "1"},
+			func(t *testing.T, s Settings, o Output) {
+				w := s.Workload
+				if w.Messages != 80 || w.Delay != 5*time.Millisecond || w.QPS != 50 || w.Keys != "zipf" || s.Seed == nil || *s.Seed != 7 {
+					t.Errorf("Expected the workload from the environment, got %+v (seed %v)", w, s.Seed)
+				}
+				if w.Interval() != 20*time.Millisecond {
+					t.Errorf("Expected -qps to set the interval, got %v", w.Interval())
+				}
+				if !o.Quiet {
+					t.Errorf("Expected quiet output, got %+v", o)
+				}
+			}},
+		{"flags over environment", []string{"-messages", "90"}, map[string]string{"MESSAGES": "80"}, func(t *testing.T, s Settings, o Output) {
+			if s.Workload.Messages != 90 {
+				t.Errorf("Expected the flag's 90 messages, got %d", s.Workload.Messages)
+			}
+		}},
+		{"config", []string{"-config", "../../experiments/rolling-restart.yaml", "-l1", "8", "-compare", "-l2 40"}, nil, func(t *testing.T, s Settings, o Output) {
+			if len(s.Servers) != 4 || s.Servers[3].Port != FirstPort+3 || s.L1Capacity != 8 || s.L2Capacity != 20 {
+				t.Errorf("Expected the file's 4 servers with -l1 8, got %+v", s)
+			}
+			if len(s.Events) != 1 || s.Events[0].RollingRestart.Spacing() != 10 || s.Compare != "-l2 40" {
+				t.Errorf("Expected the file's rolling restart and the comparison, got %+v", s)
+			}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, o, err := parse(tt.args, tt.env)
+			if err != nil {
+				t.Fatalf("ParseFlags failed: %v", err)
+			}
+			tt.check(t, s, o)
+		})
+	}
+}
+
+func TestParseFlagsErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+		want string
+	}{
+		{"unknown flag", []string{"-nodes", "3"}, nil, "not defined"},
+		{"bad variable", nil, map[string]string{"WORKERS": "many"}, "invalid $WORKERS"},
+		{"bad duration", nil, map[string]string{"MESSAGE_DELAY": "10"}, "invalid $MESSAGE_DELAY"},
+		{"quiet and verbose", []string{"-quiet", "-verbose"}, nil, "don't go together"},
+		{"demo flag with config", []string{"-config", "../../experiments/two-failures.yaml", "-kill-after", "3"}, nil, "-kill-after only applies to the built-in demo"},
+		{"demo variable with config", nil, map[string]string{"SIM_CONFIG": "../../experiments/two-failures.yaml", "SERVERS": "4"}, "-servers only applies"},
+		{"missing config", []string{"-config", "nowhere.yaml"}, nil, "no such file"},
+		{"no servers", []string{"-servers", "0"}, nil, "-servers must be at least 1"},
+		{"report with compare", []string{"-compare", "-l1 8", "-report", "run.json"}, nil, "-report doesn't apply"},
+		{"invalid settings", []string{"-l1", "0"}, nil, "cache capacities"},
+		{"event past the messages", []string{"-messages", "20"}, nil, "outside the 20 sent"},
+		{"bad keys", []string{"-keys", "pareto"}, nil, "pareto"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := parse(tt.args, tt.env)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected an error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestParseDefaults(t *testing.T) {
+	s, err := Parse([]byte(`
+servers:
+  - {id: a, port: 6000}
+  - {id: b}
+events:
+  - {after: 5, add: {id: c, capacity: 50}}
+`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	if s.Servers[1].Port != 6001 || s.Servers[1].Capacity != 100 {
+		t.Errorf("Expected b on the next port with 100 virtual nodes, got %+v", s.Servers[1])
+	}
+	if add := s.Events[0].Add; add.Port != 6002 || add.Capacity != 50 {
+		t.Errorf("Expected c on the port after b's with its own capacity, got %+v", add)
+	}
+	defaults := Default()
+	if s.L1Capacity != defaults.L1Capacity || s.Workload != defaults.Workload {
+		t.Errorf("Expected the demo's caches and workload, got %+v", s)
+	}
+	if _, err := Parse([]byte("workload: {messages: 10, burst: 5}")); err == nil || !strings.Contains(err.Error(), "field burst not found") {
+		t.Errorf("Expected an unknown field refused, got %v", err)
+	}
+}
+
+func TestValidateEvents(t *testing.T) {
+	tests := []struct {
+		name   string
+		events []Event
+		want   string // "" if valid
+	}{
+		{"kill and restart", []Event{{After: 5, Kill: "a"}, {After: 10, Restart: "a"}, {After: 15, Kill: "a"}}, ""},
+		{"add then kill", []Event{{After: 5, Add: &Server{ID: "c", Port: 7002, Capacity: 1}}, {After: 6, Kill: "c"}}, ""},
+		{"rolling restart", []Event{{After: 5, RollingRestart: &RollingRestart{Every: 5}}, {After: 16, Kill: "a"}}, ""},
+		{"before the first message", []Event{{After: 0, Kill: "a"}}, "outside the 20 sent"},
+		{"after the last message", []Event{{After: 21, Kill: "a"}}, "outside the 20 sent"},
+		{"no action", []Event{{After: 5}}, "exactly one of"},
+		{"two actions", []Event{{After: 5, Kill: "a", Restart: "b"}}, "exactly one of"},
+		{"kill unknown", []Event{{After: 5, Kill: "z"}}, `unknown server "z"`},
+		{"kill twice", []Event{{After: 5, Kill: "a"}, {After: 6, Kill: "a"}}, "already down"},
+		{"restart a server up", []Event{{After: 5, Restart: "a"}}, "isn't down then"},
+		{"add a duplicate", []Event{{After: 5, Add: &Server{ID: "b", Port: 7002, Capacity: 1}}}, "listed twice"},
+		{"add on a used port", []Event{{After: 5, Add: &Server{ID: "c", Port: 7001, Capacity: 1}}}, "is used by another server"},
+		{"add on an admin port", []Event{{After: 5, Add: &Server{ID: "c", Port: 7000 + AdminPortOffset, Capacity: 1}}}, "is used by another server"},
+		{"add without capacity", []Event{{After: 5, Add: &Server{ID: "c", Port: 7002}}}, "at least 1 virtual node"},
+		{"negative rolling spacing", []Event{{After: 5, RollingRestart: &RollingRestart{Every: -1}}}, "must not be negative"},
+		{"rolling restart past the end", []Event{{After: 15, RollingRestart: &RollingRestart{}}}, "done after 25, past the 20 sent"},
+		{"event during rolling restart", []Event{{After: 5, RollingRestart: &RollingRestart{}}, {After: 15, Kill: "a"}}, "during the rolling restart, which is done after 15"},
+		// A server down isn't restarted, so the rolling restart takes a step less
+		{"rolling restart of the servers up", []Event{{After: 5, Kill: "a"}, {After: 10, RollingRestart: &RollingRestart{}}, {After: 16, Restart: "a"}}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := Settings{
+				Servers:    []Server{{ID: "a", Port: 7000, Capacity: 1}, {ID: "b", Port: 7001, Capacity: 1}},
+				L1Capacity: 1,
+				L2Capacity: 1,
+				Workload:   Workload{Messages: 20, Chats: 1, Workers: 1},
+				Events:     tt.events,
+			}
+			err := s.Validate()
+			if tt.want == "" && err != nil {
+				t.Errorf("Expected the events valid, got %v", err)
+			}
+			if tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)) {
+				t.Errorf("Expected an error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestExamplesLoad(t *testing.T) {
+	paths, err := filepath.Glob("../../experiments/*.yaml")
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("Expected example experiments, got %v (%v)", paths, err)
+	}
+	for _, path := range paths {
+		s, err := Load(path)
+		if err == nil {
+			err = s.Validate()
+		}
+		if err != nil {
+			t.Errorf("Expected %s to load, got %v", path, err)
+		}
+	}
+}
+
+func TestCheckRollingRestart(t *testing.T) {
+	tests := []struct {
+		name     string
+		messages []Message
+		sent     int
+		want     string // "" if none failed
+	}{
+		{"none sent", nil, 0, ""},
+		{"none failed", []Message{{Phase: 1}, {Phase: 1}, {Phase: 2}}, 2, ""},
+		{"failures in other phases", []Message{{Phase: 0, Failed: true}, {Phase: 1}, {Phase: 2, Failed: true}}, 1, ""},
+		{"failures during", []Message{{Phase: 1, Failed: true}, {Phase: 1}, {Phase: 1, Failed: true}}, 3, "2 of 3 messages failed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sent, err := CheckRollingRestart(tt.messages, 1)
+			if sent != tt.sent {
+				t.Errorf("Expected %d messages sent during the restart, got %d", tt.sent, sent)
+			}
+			if tt.want == "" && err != nil {
+				t.Errorf("Expected no failures, got %v", err)
+			}
+			if tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)) {
+				t.Errorf("Expected an error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestComparisonArgs(t *testing.T) {
+	seven := int64(7)
+	tests := []struct {
+		name     string
+		args     []string
+		settings Settings
+		first    string
+		second   string
+		seed     int64
+	}{
+		{"fresh seed", []string{"-compare", "-l1 10", "-messages", "40"}, Settings{Compare: "-l1 10"},
+			"-messages 40 -seed 99", "-messages 40 -seed 99 -l1 10", 99},
+		{"given seed", []string{"-seed", "7", "--compare=-workers 4"}, Settings{Compare: "-workers 4", Seed: &seven},
+			"-seed 7", "-seed 7 -workers 4", 7},
+		{"double dash", []string{"--compare", "-keys zipf", "-quiet"}, Settings{Compare: "-keys zipf"},
+			"-quiet -seed 99", "-quiet -seed 99 -keys zipf", 99},
+		{"from the environment", []string{"-quiet"}, Settings{Compare: "-l2 40"},
+			"-quiet -seed 99", "-quiet -seed 99 -l2 40", 99},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runs, seed := ComparisonArgs(tt.args, tt.settings, 99)
+			if got := strings.Join(runs[0], " "); got != tt.first {
+				t.Errorf("Expected the first run with %q, got %q", tt.first, got)
+			}
+			if got := strings.Join(runs[1], " "); got != tt.second {
+				t.Errorf("Expected the second run with %q, got %q", tt.second, got)
+			}
+			if seed != tt.seed {
+				t.Errorf("Expected seed %d, got %d", tt.seed, seed)
+			}
+		})
+	}
+}
+
+func TestWithoutFlag(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-compare", "x", "-quiet"}, "-quiet"},
+		{[]string{"-quiet", "--compare", "x"}, "-quiet"},
+		{[]string{"-compare=x", "-l1", "3"}, "-l1 3"},
+		{[]string{"-comparex", "-l1", "3"}, "-comparex -l1 3"},
+		{[]string{"-l1", "3"}, "-l1 3"},
+	}
+
+	for _, tt := range tests {
+		if got := strings.Join(WithoutFlag(tt.args, "compare"), " "); got != tt.want {
+			t.Errorf("WithoutFlag(%v) = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
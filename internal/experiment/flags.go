@@ -0,0 +1,269 @@
+package experiment
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/internal/keyspace"
+)
+
+// Output is how a run prints, rather than what it does
+type Output struct {
+	Quiet   bool // Only a summary of each phase and the final statistics
+	Verbose bool // Also a line for every message
+	NoLive  bool // No live statistics under the output while messages are sent
+	NoEmoji bool // For piping the output into other tools
+}
+
+// envFlags defines flags on a flag set that default to environment
+// variables
+type envFlags struct {
+	fs     *flag.FlagSet
+	getenv func(string) string
+	given  map[string]bool // Flags set on the command line or by environment variable
+	err    error           // The first variable that didn't parse
+}
+
+// intVar defines an int flag defaulting to the environment variable env,
+// or to value if env is unset
+func (f *envFlags) intVar(p *int, name, env string, value int, usage string) {
+	if s := f.getenv(env); s != "" {
+		n, err := strconv.Atoi(s)
+		f.parsed(name, env, err)
+		value = n
+	}
+	f.fs.IntVar(p, name, value, usage+" ($"+env+")")
+}
+
+// floatVar defines a float flag defaulting to the environment variable
+// env, or to value if env is unset
+func (f *envFlags) floatVar(p *float64, name, env string, value float64, usage string) {
+	if s := f.getenv(env); s != "" {
+		n, err := strconv.ParseFloat(s, 64)
+		f.parsed(name, env, err)
+		value = n
+	}
+	f.fs.Float64Var(p, name, value, usage+" ($"+env+")")
+}
+
+// durationVar defines a duration flag defaulting to the environment
+// variable env, or to value if env is unset
+func (f *envFlags) durationVar(p *time.Duration, name, env string, value time.Duration, usage string) {
+	if s := f.getenv(env); s != "" {
+		d, err := time.ParseDuration(s)
+		f.parsed(name, env, err)
+		value = d
+	}
+	f.fs.DurationVar(p, name, value, usage+" ($"+env+")")
+}
+
+// stringVar defines a string flag defaulting to the environment variable
+// env
+func (f *envFlags) stringVar(name, env, usage string) *string {
+	return f.fs.String(name, f.getenv(env), usage+" ($"+env+")")
+}
+
+// boolVar defines a bool flag set by the environment variable env being
+// set to anything
+func (f *envFlags) boolVar(name, env, usage string) *bool {
+	return f.fs.Bool(name, f.getenv(env) != "", usage+" ($"+env+")")
+}
+
+// parsed marks the flag called name given by its variable env, or records
+// that env didn't parse
+func (f *envFlags) parsed(name, env string, err error) {
+	if err != nil && f.err == nil {
+		f.err = fmt.Errorf("invalid $%s: %w", env, err)
+	}
+	f.given[name] = true
+}
+
+// ParseFlags reads the settings from args (the command line, without the
+// program name) and from the environment through getenv, defining the
+// flags on fs. Flags override their environment variables, which override
+// the built-in demo or the experiment file (-config). The settings are
+// validated.
+func ParseFlags(fs *flag.FlagSet, args []string, getenv func(string) string) (Settings, Output, error) {
+	s := Default()
+	f := &envFlags{fs: fs, getenv: getenv, given: make(map[string]bool)}
+
+	config := f.stringVar("config", "SIM_CONFIG", "Experiment file, YAML or JSON, in place of the built-in demo")
+	var vnodes [3]int
+	var count, killAfter, restartAfter, addAfter, rollingAfter int
+	f.intVar(&count, "servers", "SERVERS", len(s.Servers), "Servers to start, named Server-A, Server-B, ... (those after C get 100 virtual nodes)")
+	f.intVar(&vnodes[0], "vnodes-a", "VNODES_A", s.Servers[0].Capacity, "Virtual nodes of Server A")
+	f.intVar(&vnodes[1], "vnodes-b", "VNODES_B", s.Servers[1].Capacity, "Virtual nodes of Server B")
+	f.intVar(&vnodes[2], "vnodes-c", "VNODES_C", s.Servers[2].Capacity, "Virtual nodes of Server C")
+	f.intVar(&killAfter, "kill-after", "KILL_AFTER", s.Events[0].After, "Kill Server B after this many messages (0: never)")
+	f.intVar(&restartAfter, "restart-after", "RESTART_AFTER", s.Events[1].After, "Restart Server B after this many messages (0: never)")
+	f.intVar(&addAfter, "add-after", "ADD_AFTER", s.Events[2].After, "Add Server D after this many messages (0: never)")
+	f.intVar(&rollingAfter, "rolling-after", "ROLLING_AFTER", 0, "Restart every server in turn, draining it first, after this many messages (0: never)")
+
+	var l1, l2, messages, chats, workers, warmup, seed int
+	var delay time.Duration
+	var qps, skew, hotKeys, hotTraffic float64
+	f.intVar(&l1, "l1", "L1_CAPACITY", s.L1Capacity, "L1 cache capacity per server, in sessions")
+	f.intVar(&l2, "l2", "L2_CAPACITY", s.L2Capacity, "L2 cache capacity per server, in sessions")
+	f.intVar(&messages, "messages", "MESSAGES", s.Workload.Messages, "Messages to send")
+	f.intVar(&chats, "chats", "CHATS", s.Workload.Chats, "Distinct chats the messages go to")
+	f.durationVar(&delay, "delay", "MESSAGE_DELAY", s.Workload.Delay, "Pause between messages")
+	f.intVar(&workers, "workers", "WORKERS", s.Workload.Workers, "Goroutines sending messages at once")
+	f.intVar(&warmup, "warmup", "WARMUP", s.Workload.Warmup, "Messages sent first to fill the caches, left out of the statistics")
+	f.floatVar(&qps, "qps", "QPS", s.Workload.QPS, "Messages per second across the workers, in place of -delay (0: use -delay)")
+	keys := f.stringVar("keys", "KEYS", "How messages pick chats: sequential, uniform, zipf or hotspot")
+	f.floatVar(&skew, "skew", "ZIPF_SKEW", 1.1, "Exponent of -keys zipf: higher is more skewed")
+	f.floatVar(&hotKeys, "hot-keys", "HOT_KEYS", 0.1, "Fraction of the chats that are hot with -keys hotspot")
+	f.floatVar(&hotTraffic, "hot-traffic", "HOT_TRAFFIC", 0.9, "Fraction of the messages the hot chats get with -keys hotspot")
+	f.intVar(&seed, "seed", "SIM_SEED", 0, "Replay the run with this seed: seeded randomness and simulated time")
+	processes := f.boolVar("processes", "PROCESSES", "Run each server as a serverd process, killed with SIGKILL")
+	serverd := f.stringVar("serverd", "SERVERD", "serverd binary for -processes (default: build cmd/serverd)")
+	compare := f.stringVar("compare", "COMPARE", "Run twice, the second time with these flags added, and compare the runs")
+	report := f.stringVar("report", "REPORT", "Write a report of the run to this file, as CSV if it ends in .csv, else as JSON")
+	var output Output
+	quiet := f.boolVar("quiet", "QUIET", "Print only a summary of each phase and the final statistics")
+	verbose := f.boolVar("verbose", "VERBOSE", "Also print a line for every message")
+	noLive := f.boolVar("no-live", "NO_LIVE", "Don't show live statistics under the output while messages are sent")
+	noEmoji := f.boolVar("no-emoji", "NO_EMOJI", "Print without emoji, for piping the output into other tools")
+	if err := fs.Parse(args); err != nil {
+		return Settings{}, output, err
+	}
+	if f.err != nil {
+		return Settings{}, output, f.err
+	}
+	fs.Visit(func(fl *flag.Flag) { f.given[fl.Name] = true })
+	given := f.given
+
+	if *quiet && *verbose {
+		return Settings{}, output, fmt.Errorf("-quiet and -verbose don't go together")
+	}
+	output = Output{Quiet: *quiet, Verbose: *verbose, NoLive: *noLive, NoEmoji: *noEmoji}
+
+	if *config != "" {
+		// The file describes the cluster and its failures itself
+		for _, name := range []string{"servers", "vnodes-a", "vnodes-b", "vnodes-c", "kill-after", "restart-after", "add-after", "rolling-after"} {
+			if given[name] {
+				return Settings{}, output, fmt.Errorf("-%s only applies to the built-in demo, not %s", name, *config)
+			}
+		}
+		loaded, err := Load(*config)
+		if err != nil {
+			return Settings{}, output, err
+		}
+		s = loaded
+	} else {
+		if count < 1 {
+			return Settings{}, output, fmt.Errorf("-servers must be at least 1, got %d", count)
+		}
+		s.Servers = DemoServers(count, 100)
+		for i := 0; i < len(s.Servers) && i < len(vnodes); i++ {
+			s.Servers[i].Capacity = vnodes[i]
+		}
+		add := &DemoServers(count+1, 100)[count]
+		s.Events = nil
+		if killAfter > 0 {
+			s.Events = append(s.Events, Event{After: killAfter, Kill: "Server-B"})
+			if restartAfter > 0 {
+				s.Events = append(s.Events, Event{After: restartAfter, Restart: "Server-B"})
+			}
+		}
+		if addAfter > 0 {
+			s.Events = append(s.Events, Event{After: addAfter, Add: add})
+		}
+		if rollingAfter > 0 {
+			s.Events = append(s.Events, Event{After: rollingAfter, RollingRestart: &RollingRestart{}})
+		}
+	}
+
+	if given["l1"] {
+		s.L1Capacity = l1
+	}
+	if given["l2"] {
+		s.L2Capacity = l2
+	}
+	if given["messages"] {
+		s.Workload.Messages = messages
+	}
+	if given["chats"] {
+		s.Workload.Chats = chats
+	}
+	if given["delay"] {
+		s.Workload.Delay = delay
+	}
+	if given["workers"] {
+		s.Workload.Workers = workers
+	}
+	if given["qps"] {
+		s.Workload.QPS = qps
+	}
+	if given["warmup"] {
+		s.Workload.Warmup = warmup
+	}
+	if *keys != "" {
+		s.Workload.Keys = keyspace.Distribution(*keys)
+	}
+	if given["skew"] {
+		s.Workload.Skew = skew
+	}
+	if given["hot-keys"] {
+		s.Workload.HotKeys = hotKeys
+	}
+	if given["hot-traffic"] {
+		s.Workload.HotTraffic = hotTraffic
+	}
+	if given["seed"] {
+		s.Seed = new(int64)
+		*s.Seed = int64(seed)
+	}
+	if *report != "" {
+		s.Report = *report
+	}
+	if *compare != "" && s.Report != "" {
+		return Settings{}, output, fmt.Errorf("-report doesn't apply with -compare")
+	}
+	s.Compare = *compare
+	if *processes {
+		s.Processes = true
+	}
+	s.Serverd = *serverd
+	sort.SliceStable(s.Events, func(i, j int) bool { return s.Events[i].After < s.Events[j].After })
+	if err := s.Validate(); err != nil {
+		return Settings{}, output, err
+	}
+	return s, output, nil
+}
+
+// ComparisonArgs returns the command lines of the two runs -compare makes
+// from args, the first run's: it without -compare, and it with s.Compare's
+// flags added. Both runs get the same seed, s.Seed or else fresh (added to
+// both command lines), so they send the same traffic; it is returned.
+func ComparisonArgs(args []string, s Settings, fresh int64) ([2][]string, int64) {
+	first := WithoutFlag(args, "compare")
+	seed := fresh
+	if s.Seed != nil {
+		seed = *s.Seed
+	} else {
+		first = append(first, "-seed", strconv.FormatInt(seed, 10))
+	}
+	second := append(append([]string(nil), first...), strings.Fields(s.Compare)...)
+	return [2][]string{first, second}, seed
+}
+
+// WithoutFlag returns args without the flag called name and its value
+func WithoutFlag(args []string, name string) []string {
+	var out []string
+	for i := 0; i < len(args); i++ {
+		arg := strings.TrimPrefix(strings.TrimPrefix(args[i], "-"), "-")
+		switch {
+		case arg == name:
+			i++ // And its value
+		case strings.HasPrefix(arg, name+"="):
+		default:
+			out = append(out, args[i])
+		}
+	}
+	return out
+}
@@ -0,0 +1,154 @@
+// Package keyspace picks the chats load is sent to. Real chat traffic is
+// skewed - a few busy chats get most messages - and a uniform spread
+// overstates how well caches do, so the simulation and the benchmark suite
+// draw their chats from one of these distributions instead.
+package keyspace
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync/atomic"
+
+	"github.com/sh4shv4t/DistriChat/pkg/sim"
+)
+
+// Distribution is how keys are picked
+type Distribution string
+
+const (
+	// Sequential takes each key in turn, so every key gets the same share
+	// in a fixed order
+	Sequential Distribution = "sequential"
+	// Uniform draws every key with the same probability
+	Uniform Distribution = "uniform"
+	// Zipf draws key k with probability proportional to 1/(k+1)^Skew, so
+	// key 0 is the busiest and a long tail of keys is rarely used
+	Zipf Distribution = "zipf"
+	// Hotspot sends HotTraffic of the requests to the first HotKeys of the
+	// keys, and the rest to the others, uniformly within each set
+	Hotspot Distribution = "hotspot"
+)
+
+// ParseDistribution returns the distribution called name ("" is
+// Sequential)
+func ParseDistribution(name string) (Distribution, error) {
+	switch d := Distribution(name); d {
+	case "":
+		return Sequential, nil
+	case Sequential, Uniform, Zipf, Hotspot:
+		return d, nil
+	}
+	return "", fmt.Errorf("unknown key distribution %q (want sequential, uniform, zipf or hotspot)", name)
+}
+
+// Config describes a key space and how it is drawn from
+type Config struct {
+	Distribution Distribution
+	Keys         int // Distinct keys, numbered from 0
+
+	Skew float64 // Zipf exponent, above 0 (default: 1.1)
+
+	// Hotspot's hot keys, as a fraction of the keys (default: 0.1, at least
+	// one key), and the fraction of requests they get (default: 0.9)
+	HotKeys    float64
+	HotTraffic float64
+}
+
+// Keys draws keys from a key space. It is safe for concurrent use.
+type Keys struct {
+	config Config
+	rand   *sim.Rand
+	next   atomic.Int64 // Sequential's next key
+	cdf    []float64    // Zipf's cumulative probabilities, by key
+	hot    int          // Hotspot's hot keys
+}
+
+// New creates a generator drawing from config's key space with rnd
+func New(config Config, rnd *sim.Rand) (*Keys, error) {
+	if config.Skew == 0 {
+		config.Skew = 1.1
+	}
+	if config.HotKeys == 0 {
+		config.HotKeys = 0.1
+	}
+	if config.HotTraffic == 0 {
+		config.HotTraffic = 0.9
+	}
+	d, err := ParseDistribution(string(config.Distribution))
+	if err != nil {
+		return nil, err
+	}
+	config.Distribution = d
+
+	switch {
+	case config.Keys < 1:
+		return nil, fmt.Errorf("a key space needs at least 1 key, got %d", config.Keys)
+	case config.Skew < 0:
+		return nil, fmt.Errorf("zipf skew must be above 0, got %v", config.Skew)
+	case config.HotKeys < 0 || config.HotKeys > 1:
+		return nil, fmt.Errorf("hot keys must be a fraction from 0 to 1, got %v", config.HotKeys)
+	case config.HotTraffic < 0 || config.HotTraffic > 1:
+		return nil, fmt.Errorf("hot traffic must be a fraction from 0 to 1, got %v", config.HotTraffic)
+	}
+
+	k := &Keys{config: config, rand: rnd}
+	switch d {
+	case Zipf:
+		k.cdf = make([]float64, config.Keys)
+		total := 0.0
+		for i := range k.cdf {
+			total += math.Pow(float64(i+1), -config.Skew)
+			k.cdf[i] = total
+		}
+		for i := range k.cdf {
+			k.cdf[i] /= total
+		}
+	case Hotspot:
+		k.hot = min(max(int(math.Ceil(config.HotKeys*float64(config.Keys))), 1), config.Keys)
+	}
+	return k, nil
+}
+
+// Config returns the generator's settings, defaults filled in
+func (k *Keys) Config() Config {
+	return k.config
+}
+
+// Probability returns the share of requests key gets
+func (k *Keys) Probability(key int) float64 {
+	n := k.config.Keys
+	switch {
+	case key < 0 || key >= n:
+		return 0
+	case k.config.Distribution == Zipf:
+		if key == 0 {
+			return k.cdf[0]
+		}
+		return k.cdf[key] - k.cdf[key-1]
+	case k.config.Distribution == Hotspot && k.hot < n:
+		if key < k.hot {
+			return k.config.HotTraffic / float64(k.hot)
+		}
+		return (1 - k.config.HotTraffic) / float64(n-k.hot)
+	}
+	return 1 / float64(n)
+}
+
+// Next returns the key of the next request, from 0 to Keys-1
+func (k *Keys) Next() int {
+	n := k.config.Keys
+	switch k.config.Distribution {
+	case Uniform:
+		return k.rand.Intn(n)
+	case Zipf:
+		u := k.rand.Float64()
+		return min(sort.SearchFloat64s(k.cdf, u), n-1)
+	case Hotspot:
+		if k.hot == n || k.rand.Float64() < k.config.HotTraffic {
+			return k.rand.Intn(k.hot)
+		}
+		return k.hot + k.rand.Intn(n-k.hot)
+	}
+	return int((k.next.Add(1) - 1) % int64(n))
+}
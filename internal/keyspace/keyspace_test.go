@@ -0,0 +1,140 @@
+package keyspace
+
+import (
+	"testing"
+
+	"github.com/sh4shv4t/DistriChat/pkg/sim"
+)
+
+// counts draws n keys from config's key space
+func counts(t *testing.T, config Config, n int) []int {
+	t.Helper()
+	keys, err := New(config, sim.NewRand(1))
+	if err != nil {
+		t.Fatalf("Expected a valid config, got %v", err)
+	}
+	got := make([]int, config.Keys)
+	for i := 0; i < n; i++ {
+		got[keys.Next()]++
+	}
+	return got
+}
+
+func TestSequential(t *testing.T) {
+	keys, err := New(Config{Keys: 3}, sim.NewRand(1))
+	if err != nil {
+		t.Fatalf("Expected a valid config, got %v", err)
+	}
+	for i, want := range []int{0, 1, 2, 0, 1} {
+		if got := keys.Next(); got != want {
+			t.Errorf("Expected key %d at draw %d, got %d", want, i, got)
+		}
+	}
+}
+
+func TestUniform(t *testing.T) {
+	for key, n := range counts(t, Config{Distribution: Uniform, Keys: 10}, 10000) {
+		if n < 800 || n > 1200 {
+			t.Errorf("Expected about 1000 draws of key %d, got %d", key, n)
+		}
+	}
+}
+
+func TestZipf(t *testing.T) {
+	got := counts(t, Config{Distribution: Zipf, Keys: 100, Skew: 1}, 100000)
+
+	// With skew 1, key k is drawn 1/(k+1) as often as key 0
+	if ratio := float64(got[0]) / float64(got[1]); ratio < 1.8 || ratio > 2.2 {
+		t.Errorf("Expected key 0 drawn twice as often as key 1, got %.2f times", ratio)
+	}
+	if ratio := float64(got[0]) / float64(got[9]); ratio < 8 || ratio > 12 {
+		t.Errorf("Expected key 0 drawn 10 times as often as key 9, got %.2f times", ratio)
+	}
+	tail := 0
+	for _, n := range got[50:] {
+		tail += n
+	}
+	if share := float64(tail) / 100000; share > 0.15 {
+		t.Errorf("Expected the last half of the keys to get a small share, got %.2f", share)
+	}
+}
+
+func TestHotspot(t *testing.T) {
+	got := counts(t, Config{Distribution: Hotspot, Keys: 100, HotKeys: 0.05, HotTraffic: 0.8}, 100000)
+	hot := 0
+	for _, n := range got[:5] {
+		hot += n
+	}
+	if share := float64(hot) / 100000; share < 0.78 || share > 0.82 {
+		t.Errorf("Expected the 5 hot keys to get 80%% of the traffic, got %.3f", share)
+	}
+	for key, n := range got[5:] {
+		if n == 0 {
+			t.Errorf("Expected cold key %d drawn sometimes", key+5)
+		}
+	}
+}
+
+func TestProbability(t *testing.T) {
+	for _, config := range []Config{
+		{Keys: 10},
+		{Distribution: Zipf, Keys: 10},
+		{Distribution: Hotspot, Keys: 10, HotKeys: 0.2, HotTraffic: 0.5},
+	} {
+		keys, err := New(config, sim.NewRand(1))
+		if err != nil {
+			t.Fatalf("Expected a valid config, got %v", err)
+		}
+		total := 0.0
+		for key := 0; key < config.Keys; key++ {
+			total += keys.Probability(key)
+		}
+		if total < 0.999 || total > 1.001 {
+			t.Errorf("Expected %s probabilities summing to 1, got %v", keys.Config().Distribution, total)
+		}
+	}
+
+	keys, _ := New(Config{Distribution: Hotspot, Keys: 10, HotKeys: 0.2, HotTraffic: 0.5}, sim.NewRand(1))
+	if got := keys.Probability(0); got != 0.25 {
+		t.Errorf("Expected a hot key to get 0.25, got %v", got)
+	}
+	if got := keys.Probability(10); got != 0 {
+		t.Errorf("Expected 0 outside the key space, got %v", got)
+	}
+}
+
+func TestSeededDrawsRepeat(t *testing.T) {
+	a := counts(t, Config{Distribution: Zipf, Keys: 50}, 1000)
+	b := counts(t, Config{Distribution: Zipf, Keys: 50}, 1000)
+	for key := range a {
+		if a[key] != b[key] {
+			t.Fatalf("Expected the same draws from the same seed, key %d got %d and %d", key, a[key], b[key])
+		}
+	}
+}
+
+func TestInvalidConfig(t *testing.T) {
+	for _, config := range []Config{
+		{Keys: 0},
+		{Distribution: "pareto", Keys: 10},
+		{Distribution: Zipf, Keys: 10, Skew: -1},
+		{Distribution: Hotspot, Keys: 10, HotKeys: 2},
+		{Distribution: Hotspot, Keys: 10, HotTraffic: 1.5},
+	} {
+		if _, err := New(config, sim.NewRand(1)); err == nil {
+			t.Errorf("Expected an error for %+v", config)
+		}
+	}
+}
+
+func TestParseDistribution(t *testing.T) {
+	if d, err := ParseDistribution(""); err != nil || d != Sequential {
+		t.Errorf("Expected sequential by default, got %q, %v", d, err)
+	}
+	if d, err := ParseDistribution("hotspot"); err != nil || d != Hotspot {
+		t.Errorf("Expected hotspot, got %q, %v", d, err)
+	}
+	if _, err := ParseDistribution("pareto"); err == nil {
+		t.Errorf("Expected an error for an unknown distribution")
+	}
+}
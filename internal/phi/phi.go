@@ -0,0 +1,202 @@
+// Package phi implements the phi accrual failure detector (Hayashibara et
+// al.). Instead of a binary up/down verdict after a fixed timeout, a
+// detector learns the distribution of heartbeat inter-arrival times and
+// reports a suspicion level phi for the current silence: phi = -log10 of
+// the probability that a live node would have stayed quiet this long. A phi
+// of 1 means a 10% chance of being wrong, 2 means 1%, 3 means 0.1%, and so
+// on. Callers pick their own thresholds, so one detector can back both a
+// cheap decision (stop routing) and an expensive one (evict the node).
+package phi
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Config contains configuration for a failure detector
+type Config struct {
+	// Number of recent inter-arrival times the distribution is estimated
+	// from (default: 100)
+	WindowSize int
+
+	// Lower bound on the estimated standard deviation, so a perfectly
+	// regular heartbeat doesn't turn the slightest delay into certainty
+	// (default: FirstInterval / 4)
+	MinStdDev time.Duration
+
+	// Silence tolerated on top of the learned distribution, e.g. for GC
+	// pauses (default: 0)
+	AcceptablePause time.Duration
+
+	// Expected interval used until real ones are observed (default: 1s)
+	FirstInterval time.Duration
+}
+
+// withDefaults fills in unset fields
+func (c Config) withDefaults() Config {
+	if c.WindowSize <= 0 {
+		c.WindowSize = 100
+	}
+	if c.FirstInterval <= 0 {
+		c.FirstInterval = time.Second
+	}
+	if c.MinStdDev <= 0 {
+		c.MinStdDev = c.FirstInterval / 4
+	}
+	return c
+}
+
+// Detector estimates one node's suspicion level from its heartbeats. It is
+// safe for concurrent use.
+type Detector struct {
+	mu     sync.Mutex
+	config Config
+
+	// Sliding window of inter-arrival times in milliseconds, with running
+	// sums for the mean and variance
+	intervals []float64
+	next      int
+	sum       float64
+	sumSq     float64
+
+	last time.Time // Zero until the first heartbeat
+}
+
+// NewDetector creates a detector that has seen no heartbeats
+func NewDetector(config Config) *Detector {
+	config = config.withDefaults()
+	return &Detector{
+		config:    config,
+		intervals: make([]float64, 0, config.WindowSize),
+	}
+}
+
+// Heartbeat records that the node was heard from at now
+func (d *Detector) Heartbeat(now time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.last.IsZero() {
+		// Seed the window around the expected interval so phi is
+		// meaningful before real samples arrive
+		mean := millis(d.config.FirstInterval)
+		d.add(mean - mean/4)
+		d.add(mean + mean/4)
+	} else if now.After(d.last) {
+		d.add(millis(now.Sub(d.last)))
+	}
+	if now.After(d.last) {
+		d.last = now
+	}
+}
+
+// Phi returns the suspicion level at now: 0 right after a heartbeat,
+// growing the longer the node stays silent relative to its usual rhythm.
+// A node never heard from has phi 0; use Last to tell the two apart.
+func (d *Detector) Phi(now time.Time) float64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.last.IsZero() {
+		return 0
+	}
+
+	n := float64(len(d.intervals))
+	mean := d.sum / n
+	stdDev := math.Sqrt(math.Max(d.sumSq/n-mean*mean, 0))
+	if min := millis(d.config.MinStdDev); stdDev < min {
+		stdDev = min
+	}
+
+	elapsed := millis(now.Sub(d.last)) - millis(d.config.AcceptablePause)
+	return phi(elapsed, mean, stdDev)
+}
+
+// Last returns when the node was last heard from (zero if never)
+func (d *Detector) Last() time.Time {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.last
+}
+
+// add appends an interval, overwriting the oldest once the window is full
+// (must be called with lock held)
+func (d *Detector) add(interval float64) {
+	if len(d.intervals) < d.config.WindowSize {
+		d.intervals = append(d.intervals, interval)
+	} else {
+		old := d.intervals[d.next]
+		d.sum -= old
+		d.sumSq -= old * old
+		d.intervals[d.next] = interval
+		d.next = (d.next + 1) % d.config.WindowSize
+	}
+	d.sum += interval
+	d.sumSq += interval * interval
+}
+
+// phi is -log10 of the probability that a normally distributed interval
+// exceeds elapsed, using the logistic approximation of the normal CDF
+// (accurate to about 1e-4 and stable far into the tail)
+func phi(elapsed, mean, stdDev float64) float64 {
+	y := (elapsed - mean) / stdDev
+	e := math.Exp(-y * (1.5976 + 0.070566*y*y))
+	if elapsed > mean {
+		return -math.Log10(e / (1 + e))
+	}
+	return -math.Log10(1 - 1/(1+e))
+}
+
+// millis converts a duration to fractional milliseconds
+func millis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// Monitor keeps a detector per node ID
+type Monitor struct {
+	mu        sync.Mutex
+	config    Config
+	detectors map[string]*Detector
+}
+
+// NewMonitor creates a monitor whose detectors share config
+func NewMonitor(config Config) *Monitor {
+	return &Monitor{
+		config:    config,
+		detectors: make(map[string]*Detector),
+	}
+}
+
+// Heartbeat records that id was heard from at now
+func (m *Monitor) Heartbeat(id string, now time.Time) {
+	m.mu.Lock()
+	d, ok := m.detectors[id]
+	if !ok {
+		d = NewDetector(m.config)
+		m.detectors[id] = d
+	}
+	m.mu.Unlock()
+
+	d.Heartbeat(now)
+}
+
+// Phi returns id's suspicion level at now, and false if id was never heard
+// from
+func (m *Monitor) Phi(id string, now time.Time) (float64, bool) {
+	m.mu.Lock()
+	d, ok := m.detectors[id]
+	m.mu.Unlock()
+
+	if !ok {
+		return 0, false
+	}
+	return d.Phi(now), true
+}
+
+// Remove forgets id's history
+func (m *Monitor) Remove(id string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.detectors, id)
+}
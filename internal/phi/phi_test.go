@@ -0,0 +1,86 @@
+package phi
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPhiGrowsWithSilence(t *testing.T) {
+	d := NewDetector(Config{FirstInterval: 100 * time.Millisecond, MinStdDev: 10 * time.Millisecond})
+
+	start := time.Unix(0, 0)
+	if got := d.Phi(start); got != 0 {
+		t.Errorf("Expected phi 0 before any heartbeat, got %f", got)
+	}
+
+	now := start
+	for i := 0; i < 20; i++ {
+		d.Heartbeat(now)
+		now = now.Add(100 * time.Millisecond)
+	}
+	last := now.Add(-100 * time.Millisecond)
+
+	if got := d.Phi(last); got > 0.1 {
+		t.Errorf("Expected phi near 0 right after a heartbeat, got %f", got)
+	}
+
+	prev := 0.0
+	for _, silence := range []time.Duration{100, 150, 200, 300} {
+		got := d.Phi(last.Add(silence * time.Millisecond))
+		if got <= prev {
+			t.Errorf("Expected phi to grow with silence, got %f after %dms (was %f)", got, silence, prev)
+		}
+		prev = got
+	}
+	if prev < 8 {
+		t.Errorf("Expected phi above 8 after triple the usual interval, got %f", prev)
+	}
+}
+
+func TestPhiAdaptsToJitter(t *testing.T) {
+	config := Config{FirstInterval: 100 * time.Millisecond, MinStdDev: time.Millisecond}
+	steady := NewDetector(config)
+	jittery := NewDetector(config)
+
+	now := time.Unix(0, 0)
+	steady.Heartbeat(now)
+	jittery.Heartbeat(now)
+	steadyAt, jitteryAt := now, now
+	for i := 0; i < 50; i++ {
+		steadyAt = steadyAt.Add(100 * time.Millisecond)
+		steady.Heartbeat(steadyAt)
+
+		gap := 50 * time.Millisecond
+		if i%2 == 0 {
+			gap = 150 * time.Millisecond
+		}
+		jitteryAt = jitteryAt.Add(gap)
+		jittery.Heartbeat(jitteryAt)
+	}
+
+	// The same silence is far more suspicious on a regular heartbeat
+	s := steady.Phi(steadyAt.Add(180 * time.Millisecond))
+	j := jittery.Phi(jitteryAt.Add(180 * time.Millisecond))
+	if s <= j {
+		t.Errorf("Expected steady phi %f to exceed jittery phi %f", s, j)
+	}
+}
+
+func TestMonitor(t *testing.T) {
+	m := NewMonitor(Config{FirstInterval: 100 * time.Millisecond})
+
+	if _, ok := m.Phi("node-1", time.Now()); ok {
+		t.Error("Expected unknown node to report no history")
+	}
+
+	now := time.Now()
+	m.Heartbeat("node-1", now)
+	if got, ok := m.Phi("node-1", now); !ok || got > 0.5 {
+		t.Errorf("Expected low phi right after a heartbeat, got %f (%v)", got, ok)
+	}
+
+	m.Remove("node-1")
+	if _, ok := m.Phi("node-1", now); ok {
+		t.Error("Expected removed node to report no history")
+	}
+}
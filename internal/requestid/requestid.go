@@ -0,0 +1,75 @@
+// Package requestid carries a request ID from the client that generated it
+// to every server that handles the request, in gRPC metadata. The client
+// keeps one ID across its retries and failovers, and servers pass it on to
+// the replicas they call, so one message's journey can be followed in the
+// logs of every process it touched.
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/sh4shv4t/DistriChat/pkg/logging"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// MetadataKey is the gRPC metadata key the ID travels under
+const MetadataKey = "x-request-id"
+
+// New returns a random request ID
+func New() string {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic("requestid: " + err.Error())
+	}
+	return hex.EncodeToString(b[:])
+}
+
+type idKey struct{}
+
+// With returns a copy of ctx carrying id, which outgoing calls made with
+// DialOption send and records logged with the context include
+func With(ctx context.Context, id string) context.Context {
+	ctx = context.WithValue(ctx, idKey{}, id)
+	return logging.With(ctx, logging.RequestID(id))
+}
+
+// FromContext returns the request ID carried by ctx, or ""
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(idKey{}).(string)
+	return id
+}
+
+// DialOption makes calls on a connection send the request ID of their
+// context
+func DialOption() grpc.DialOption {
+	return grpc.WithChainUnaryInterceptor(func(ctx context.Context, method string, req, reply any,
+		cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if id := FromContext(ctx); id != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, MetadataKey, id)
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	})
+}
+
+// ServerOption makes a server's unary handlers run with the request ID sent
+// by the caller in their context, or a new one if the caller sent none.
+// The ID is also returned to the caller in the response header.
+func ServerOption() grpc.ServerOption {
+	return grpc.ChainUnaryInterceptor(func(ctx context.Context, req any, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (any, error) {
+		var id string
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if values := md.Get(MetadataKey); len(values) > 0 {
+				id = values[0]
+			}
+		}
+		if id == "" {
+			id = New()
+		}
+		grpc.SetHeader(ctx, metadata.Pairs(MetadataKey, id))
+		return handler(With(ctx, id), req)
+	})
+}
@@ -0,0 +1,69 @@
+package requestid
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	pb "github.com/sh4shv4t/DistriChat/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+)
+
+// echoServer records the request ID each PostMessage is handled under
+type echoServer struct {
+	pb.UnimplementedChatServiceServer
+	ids []string
+}
+
+func (e *echoServer) PostMessage(ctx context.Context, req *pb.ChatRequest) (*pb.ChatResponse, error) {
+	e.ids = append(e.ids, FromContext(ctx))
+	return &pb.ChatResponse{Success: true}, nil
+}
+
+func TestRequestIDCrossesGRPC(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	echo := &echoServer{}
+	server := grpc.NewServer(ServerOption())
+	pb.RegisterChatServiceServer(server, echo)
+	go server.Serve(listener)
+	defer server.Stop()
+
+	conn, err := grpc.Dial(listener.Addr().String(),
+		grpc.WithTransportCredentials(insecure.NewCredentials()), DialOption())
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+	client := pb.NewChatServiceClient(conn)
+
+	var header metadata.MD
+	ctx := With(context.Background(), "req-1")
+	if _, err := client.PostMessage(ctx, &pb.ChatRequest{ChatId: "chat-1"}, grpc.Header(&header)); err != nil {
+		t.Fatalf("PostMessage failed: %v", err)
+	}
+	if echo.ids[0] != "req-1" {
+		t.Errorf("Expected the server to handle req-1, got %q", echo.ids[0])
+	}
+	if got := header.Get(MetadataKey); len(got) != 1 || got[0] != "req-1" {
+		t.Errorf("Expected req-1 in the response header, got %v", got)
+	}
+
+	// A caller without an ID gets one generated by the server
+	if _, err := client.PostMessage(context.Background(), &pb.ChatRequest{ChatId: "chat-1"}); err != nil {
+		t.Fatalf("PostMessage failed: %v", err)
+	}
+	if len(echo.ids[1]) != 16 {
+		t.Errorf("Expected a generated ID, got %q", echo.ids[1])
+	}
+}
+
+func TestNewIsUnique(t *testing.T) {
+	if a, b := New(), New(); a == b {
+		t.Errorf("Expected distinct IDs, got %s twice", a)
+	}
+}
@@ -0,0 +1,718 @@
+package scenario
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/pkg/cache"
+	"github.com/sh4shv4t/DistriChat/pkg/chaos"
+	"github.com/sh4shv4t/DistriChat/pkg/chattest"
+	"github.com/sh4shv4t/DistriChat/pkg/client"
+	"github.com/sh4shv4t/DistriChat/pkg/clock"
+	"github.com/sh4shv4t/DistriChat/pkg/metrics"
+	"github.com/sh4shv4t/DistriChat/pkg/ring"
+	"github.com/sh4shv4t/DistriChat/pkg/server"
+	"github.com/sh4shv4t/DistriChat/pkg/sim"
+)
+
+// Metrics are what assert phases check, over the whole scenario so far
+type Metrics struct {
+	Sent      int64 // Messages sent
+	Delivered int64 // Messages the cluster accepted
+	Errors    int64 // Messages that failed
+	Failovers int64 // Client retries on another server
+	Dropped   int64 // Calls failed by faults and partitions, client's and servers'
+
+	// Most servers a delivered message went past, down its chat's failover
+	// chain, to the one that took it (0: every chat's owner answered)
+	MaxChain int
+
+	HitRate   float64 // Cache hits over lookups, across servers
+	L1HitRate float64
+	Evictions int64
+
+	Servers int // Running
+}
+
+// metricValues maps the names assert phases use to Metrics fields
+var metricValues = map[string]func(Metrics) float64{
+	"sent":        func(m Metrics) float64 { return float64(m.Sent) },
+	"delivered":   func(m Metrics) float64 { return float64(m.Delivered) },
+	"errors":      func(m Metrics) float64 { return float64(m.Errors) },
+	"failovers":   func(m Metrics) float64 { return float64(m.Failovers) },
+	"hit_rate":    func(m Metrics) float64 { return m.HitRate },
+	"l1_hit_rate": func(m Metrics) float64 { return m.L1HitRate },
+	"evictions":   func(m Metrics) float64 { return float64(m.Evictions) },
+	"servers":     func(m Metrics) float64 { return float64(m.Servers) },
+	"max_chain":   func(m Metrics) float64 { return float64(m.MaxChain) },
+	"dropped":     func(m Metrics) float64 { return float64(m.Dropped) },
+	"error_rate": func(m Metrics) float64 {
+		if m.Sent == 0 {
+			return 0
+		}
+		return float64(m.Errors) / float64(m.Sent)
+	},
+}
+
+// MetricNames lists the metrics assert phases can check
+func MetricNames() []string {
+	names := make([]string, 0, len(metricValues))
+	for name := range metricValues {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Check is the outcome of one metric of an assert phase
+type Check struct {
+	Phase  string
+	Metric string
+	Value  float64
+	Bound  Bound
+	Passed bool
+}
+
+func (c Check) String() string {
+	verdict := "ok"
+	if !c.Passed {
+		verdict = "FAILED"
+	}
+	return fmt.Sprintf("%s: %s = %g, want %s: %s", c.Phase, c.Metric, c.Value, c.Bound, verdict)
+}
+
+// Report is the outcome of a scenario
+type Report struct {
+	Name    string
+	Seed    int64
+	Elapsed time.Duration // On the scenario's clock
+	Metrics Metrics       // At the end
+	Checks  []Check
+}
+
+// Passed reports whether every check passed
+func (r Report) Passed() bool {
+	for _, check := range r.Checks {
+		if !check.Passed {
+			return false
+		}
+	}
+	return true
+}
+
+// engine runs one scenario
+type engine struct {
+	scenario Scenario
+	out      io.Writer
+	clock    clock.Clock
+	sim      *sim.Simulation // nil in real time
+	injector *chaos.Injector
+	network  *chattest.Network
+	workload *rand.Rand
+	victims  *rand.Rand // Picks the servers of kills phases with a count
+	start    time.Time
+
+	mu      sync.Mutex
+	config  Start
+	servers map[string]*server.ChatServer
+	killed  map[string]bool
+	state   ring.RingState
+	ring    *ring.HashRing // Built from state, for the chats' failover chains
+	client  *client.SmartClient
+	metrics Metrics
+	errs    []error
+
+	// Scheduled phases not yet run (or, for kills, not yet finished), by
+	// index
+	pending map[int]pendingStep
+
+	partitions map[string]Partition // In place, by name
+	heals      []func() bool        // Partitions' heals due
+}
+
+// pendingStep is a scheduled phase, or the next kill of a kills phase,
+// waiting for its offset
+type pendingStep struct {
+	at   time.Duration
+	stop func() bool
+}
+
+// Run plays the scenario, writing a line per phase to out (which may be
+// nil). It fails if a phase can't run, e.g. a server fails to start or a
+// scheduled phase is still pending when the scenario ends; failed
+// assertions are reported in the Report instead.
+func Run(ctx context.Context, s Scenario, out io.Writer) (Report, error) {
+	if err := s.Validate(); err != nil {
+		return Report{}, err
+	}
+	if out == nil {
+		out = io.Discard
+	}
+
+	e := &engine{
+		scenario: s,
+		out:      out,
+		clock:    clock.System(),
+		injector: chaos.New(),
+		network:  chattest.NewNetwork(),
+		servers:  make(map[string]*server.ChatServer),
+		killed:   make(map[string]bool),
+		ring:     ring.NewHashRing(0),
+		pending:  make(map[int]pendingStep),
+
+		partitions: make(map[string]Partition),
+	}
+	seed := time.Now().UnixNano()
+	victimSeed := seed + 1
+	if s.Seed != 0 {
+		e.sim = sim.New(s.Seed)
+		e.clock = e.sim.Clock
+		e.injector.SetClock(e.sim.Clock)
+		e.injector.SetRand(e.sim.Rand("chaos"))
+		seed = int64(e.sim.Rand("workload").Uint64())
+		victimSeed = int64(e.sim.Rand("victims").Uint64())
+	}
+	e.workload = rand.New(rand.NewSource(seed))
+	e.victims = rand.New(rand.NewSource(victimSeed))
+	e.start = e.clock.Now()
+	defer e.close()
+
+	report := Report{Name: s.Name, Seed: s.Seed}
+	for i, phase := range s.Phases {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		if phase.scheduled() {
+			e.schedule(i, phase)
+			continue
+		}
+		checks, err := e.run(ctx, i, phase)
+		report.Checks = append(report.Checks, checks...)
+		if err != nil {
+			return report, fmt.Errorf("phase %d (%s): %w", i+1, phase, err)
+		}
+		// A phase scheduled during this one may have failed
+		if err := e.firstError(); err != nil {
+			return report, err
+		}
+	}
+
+	report.Elapsed = e.clock.Now().Sub(e.start)
+	report.Metrics = e.snapshot()
+	if err := e.firstError(); err != nil {
+		return report, err
+	}
+	return report, e.unrun()
+}
+
+// elapsed returns the time since the scenario started
+func (e *engine) elapsed() time.Duration {
+	return e.clock.Now().Sub(e.start).Round(time.Millisecond)
+}
+
+// logf writes a progress line stamped with the scenario time
+func (e *engine) logf(format string, args ...any) {
+	fmt.Fprintf(e.out, "[%8v] %s\n", e.elapsed(), fmt.Sprintf(format, args...))
+}
+
+// schedule arranges for phase i to run at its offset from the start
+func (e *engine) schedule(i int, phase Phase) {
+	e.scheduleStep(i, phase.At, func() error {
+		_, err := e.run(context.Background(), i, phase)
+		return err
+	})
+}
+
+// scheduleStep arranges for step, all or part of phase i, to run at the
+// offset at from the start
+func (e *engine) scheduleStep(i int, at time.Duration, step func() error) {
+	delay := at - e.clock.Now().Sub(e.start)
+	run := func() {
+		e.mu.Lock()
+		delete(e.pending, i)
+		e.mu.Unlock()
+
+		if err := step(); err != nil {
+			e.mu.Lock()
+			e.errs = append(e.errs, fmt.Errorf("phase %d (%s): %w", i+1, e.scenario.Phases[i], err))
+			e.mu.Unlock()
+		}
+	}
+	if delay <= 0 {
+		run()
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.pending[i] = pendingStep{at: at, stop: e.clock.AfterFunc(delay, run)}
+}
+
+// unrun cancels the scheduled phases still pending, returning an error
+// naming them: their offsets lie beyond the end of the scenario
+func (e *engine) unrun() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var missed []int
+	for i, step := range e.pending {
+		if step.stop() {
+			missed = append(missed, i)
+		}
+	}
+	if len(missed) == 0 {
+		return nil
+	}
+	sort.Ints(missed)
+	i := missed[0]
+	return fmt.Errorf("phase %d (%s) at %v never ran: the scenario ended at %v",
+		i+1, e.scenario.Phases[i], e.pending[i].at, e.elapsed())
+}
+
+// firstError returns the first error of a scheduled phase, if any
+func (e *engine) firstError() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.errs) == 0 {
+		return nil
+	}
+	return e.errs[0]
+}
+
+// run performs phase i's action, returning the checks of an assert phase
+func (e *engine) run(ctx context.Context, i int, phase Phase) ([]Check, error) {
+	e.logf("%s", phase)
+
+	switch phase.action() {
+	case "start":
+		return nil, e.startCluster(*phase.Start)
+	case "send":
+		e.send(ctx, *phase.Send)
+	case "wait":
+		e.clock.Sleep(phase.Wait)
+	case "kill":
+		return nil, e.kill(phase.Kill)
+	case "kills":
+		return nil, e.kills(i, phase, 0)
+	case "add":
+		return nil, e.add(*phase.Add)
+	case "remove":
+		return nil, e.remove(phase.Remove)
+	case "fault":
+		f := phase.Fault
+		e.injector.Add(chaos.Rule{
+			Name: f.Name, From: f.From, To: f.To,
+			Latency: f.Latency, Jitter: f.Jitter, Distribution: f.Distribution,
+			DropRate: f.DropRate, Stall: f.Stall,
+		})
+	case "partition":
+		e.partition(*phase.Partition)
+	case "heal":
+		e.heal(phase.Heal)
+	case "assert":
+		return e.assert(phase), nil
+	}
+	return nil, nil
+}
+
+// startCluster starts the servers and the client routing to them
+func (e *engine) startCluster(config Start) error {
+	e.mu.Lock()
+	e.config = config
+	e.mu.Unlock()
+
+	state := ring.RingState{Epoch: 1}
+	for n := 1; n <= config.Servers; n++ {
+		id := fmt.Sprintf("server-%d", n)
+		if err := e.startServer(id); err != nil {
+			return err
+		}
+		state.Nodes = append(state.Nodes, ring.NodeSpec{NodeID: id, Address: id, Capacity: config.Capacity})
+	}
+
+	clientConfig := client.ClientConfig{
+		Dialer:            e.network.Dial,
+		Chaos:             e.injector,
+		Clock:             e.clock,
+		ReplicationFactor: config.Replicas,
+	}
+	if e.sim != nil {
+		clientConfig.Rand = e.sim.Rand("client")
+	}
+	e.mu.Lock()
+	e.client = client.NewSmartClient(clientConfig)
+	e.mu.Unlock()
+
+	e.publish(state)
+	return nil
+}
+
+// startServer starts the server called id on the in-memory network
+func (e *engine) startServer(id string) error {
+	e.mu.Lock()
+	config := e.config
+	e.mu.Unlock()
+
+	srv := server.NewChatServer(server.ServerConfig{
+		ServerID:         id,
+		AdvertiseAddress: id,
+		Listener:         e.network.Listen(id),
+		Dialer:           e.network.Dial,
+		L1Capacity:       config.L1,
+		L2Capacity:       config.L2,
+		Replication:      server.ReplicationConfig{N: config.Replicas},
+		Metrics:          metrics.Nop(),
+		Clock:            e.clock,
+		Chaos:            e.injector,
+	})
+	if err := srv.Start(); err != nil {
+		return fmt.Errorf("failed to start %s: %w", id, err)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.servers[id] = srv
+	return nil
+}
+
+// publish installs state on every running server and the client
+func (e *engine) publish(state ring.RingState) {
+	e.mu.Lock()
+	e.state = state
+	var running []*server.ChatServer
+	for id, srv := range e.servers {
+		if !e.killed[id] {
+			running = append(running, srv)
+		}
+	}
+	cl := e.client
+	e.mu.Unlock()
+
+	for _, srv := range running {
+		srv.SetRingState(state)
+	}
+	cl.ApplyRingState(state)
+	e.ring.Replace(state)
+}
+
+// partition cuts the links between p's sides until it is healed: by name,
+// or after For
+func (e *engine) partition(p Partition) {
+	for _, link := range p.links() {
+		e.injector.Partition(link[0], link[1])
+	}
+	e.logf("  %s cut off from %s", strings.Join(p.Sides[0], ", "), strings.Join(p.Sides[1], ", "))
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.partitions[p.Name] = p
+	if p.For > 0 {
+		e.heals = append(e.heals, e.clock.AfterFunc(p.For, func() {
+			e.logf("heal %s", p.Name)
+			e.heal(p.Name)
+		}))
+	}
+}
+
+// heal lifts the partition called name, or else removes the fault rule of
+// that name
+func (e *engine) heal(name string) {
+	e.mu.Lock()
+	p, ok := e.partitions[name]
+	delete(e.partitions, name)
+	e.mu.Unlock()
+
+	if !ok {
+		e.injector.Remove(name)
+		return
+	}
+	for _, link := range p.links() {
+		e.injector.Heal(link[0], link[1])
+	}
+}
+
+// kill stops the server called id without taking it out of the ring, as
+// a crash would
+func (e *engine) kill(id string) error {
+	e.mu.Lock()
+	srv, ok := e.servers[id]
+	ok = ok && !e.killed[id]
+	if ok {
+		e.killed[id] = true
+	}
+	e.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("server %s is not running", id)
+	}
+	srv.Stop()
+	return nil
+}
+
+// kills performs the kills phase i from its nth kill on, waiting out the
+// gaps between kills, or if the phase is scheduled, scheduling the next
+// kill and returning
+func (e *engine) kills(i int, phase Phase, n int) error {
+	k := phase.Kills
+	for first := n; n < k.total(); n++ {
+		if n > first && k.Every > 0 {
+			if phase.scheduled() {
+				next := n
+				e.scheduleStep(i, phase.At+time.Duration(n)*k.Every, func() error {
+					return e.kills(i, phase, next)
+				})
+				return nil
+			}
+			e.clock.Sleep(k.Every)
+		}
+
+		id, err := e.victim(k, n)
+		if err != nil {
+			return err
+		}
+		if err := e.kill(id); err != nil {
+			return err
+		}
+		e.logf("  killed %s (%d of %d), %d left running", id, n+1, k.total(), e.running())
+	}
+	return nil
+}
+
+// victim returns the server to kill nth in a kills phase: the one listed,
+// or one of those running, at random
+func (e *engine) victim(k *Kills, n int) (string, error) {
+	if len(k.Servers) > 0 {
+		return k.Servers[n], nil
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var running []string
+	for id := range e.servers {
+		if !e.killed[id] {
+			running = append(running, id)
+		}
+	}
+	if len(running) == 0 {
+		return "", fmt.Errorf("no server left to kill")
+	}
+	sort.Strings(running)
+	return running[e.victims.Intn(len(running))], nil
+}
+
+// running returns the number of servers still running
+func (e *engine) running() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return len(e.servers) - len(e.killed)
+}
+
+// add starts a server and adds it to the ring
+func (e *engine) add(spec Server) error {
+	if err := e.startServer(spec.ID); err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	state := ring.RingState{Epoch: e.state.Epoch + 1}
+	state.Nodes = append(append(state.Nodes, e.state.Nodes...),
+		ring.NodeSpec{NodeID: spec.ID, Address: spec.ID, Capacity: spec.Capacity})
+	e.mu.Unlock()
+
+	e.publish(state)
+	return nil
+}
+
+// remove takes the server called id out of the ring, leaving it running
+func (e *engine) remove(id string) error {
+	e.mu.Lock()
+	state := ring.RingState{Epoch: e.state.Epoch + 1}
+	for _, node := range e.state.Nodes {
+		if node.NodeID != id {
+			state.Nodes = append(state.Nodes, node)
+		}
+	}
+	removed := len(state.Nodes) < len(e.state.Nodes)
+	e.mu.Unlock()
+
+	if !removed {
+		return fmt.Errorf("server %s is not in the ring", id)
+	}
+	e.publish(state)
+	return nil
+}
+
+// send sends the phase's messages one at a time, waiting Interval after
+// each. Failed sends are counted, not returned: they are what scenarios
+// assert on. It then reports how far down their chats' failover chains the
+// messages went, and what each server left running made of its share.
+func (e *engine) send(ctx context.Context, s Send) {
+	var zipf *rand.Zipf
+	if s.Distribution == "zipf" && s.Chats > 1 {
+		zipf = rand.NewZipf(e.workload, 1.1, 1, uint64(s.Chats-1))
+	}
+
+	e.mu.Lock()
+	cl := e.client
+	e.mu.Unlock()
+	before := e.cacheStats()
+
+	var chains []int // Messages delivered, by servers gone past
+	for i := 0; i < s.Messages && ctx.Err() == nil; i++ {
+		chat := e.workload.Intn(s.Chats)
+		if zipf != nil {
+			chat = int(zipf.Uint64())
+		}
+		chatID := fmt.Sprintf("chat-%03d", chat)
+		resp, err := cl.SendMessage(chatID, "scenario", fmt.Sprintf("message %d", i+1))
+
+		e.mu.Lock()
+		e.metrics.Sent++
+		if err != nil {
+			e.metrics.Errors++
+		} else {
+			e.metrics.Delivered++
+			chain := e.chain(chatID, resp.ServerId)
+			for len(chains) <= chain {
+				chains = append(chains, 0)
+			}
+			chains[chain]++
+			e.metrics.MaxChain = max(e.metrics.MaxChain, chain)
+		}
+		e.mu.Unlock()
+
+		if s.Interval > 0 {
+			e.clock.Sleep(s.Interval)
+		}
+	}
+
+	if len(chains) > 0 {
+		parts := []string{fmt.Sprintf("%d by server 1 of their chat's chain (the owner)", chains[0])}
+		for chain, n := range chains[1:] {
+			parts = append(parts, fmt.Sprintf("%d by server %d", n, chain+2))
+		}
+		e.logf("  delivered: %s", strings.Join(parts, ", "))
+	}
+	after := e.cacheStats()
+	for _, id := range sortedIDs(after) {
+		hits := after[id].CacheHits - before[id].CacheHits
+		misses := after[id].CacheMisses - before[id].CacheMisses
+		evictions := after[id].Evictions - before[id].Evictions
+		rate := 0.0
+		if hits+misses > 0 {
+			rate = float64(hits) / float64(hits+misses)
+		}
+		e.logf("  %s: %d lookups, %.0f%% hits, %d evictions", id, hits+misses, 100*rate, evictions)
+	}
+}
+
+// chain returns the position of the server that took a message for chatID
+// in the chat's failover chain: 0 for its owner, 1 for the next server on
+// the ring, and so on (must be called with e.mu held)
+func (e *engine) chain(chatID, serverID string) int {
+	for i, node := range e.ring.GetNodes(chatID, len(e.state.Nodes)) {
+		if node.NodeID == serverID {
+			return i
+		}
+	}
+	return 0
+}
+
+// cacheStats returns the cache statistics of the servers running, by ID
+func (e *engine) cacheStats() map[string]cache.CacheStats {
+	e.mu.Lock()
+	running := make(map[string]*server.ChatServer)
+	for id, srv := range e.servers {
+		if !e.killed[id] {
+			running[id] = srv
+		}
+	}
+	e.mu.Unlock()
+
+	stats := make(map[string]cache.CacheStats, len(running))
+	for id, srv := range running {
+		stats[id] = srv.GetCacheInfo().Stats
+	}
+	return stats
+}
+
+// sortedIDs returns the keys of a map by server ID, in order
+func sortedIDs[V any](m map[string]V) []string {
+	ids := make([]string, 0, len(m))
+	for id := range m {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// snapshot gathers the metrics so far
+func (e *engine) snapshot() Metrics {
+	e.mu.Lock()
+	m := e.metrics
+	cl := e.client
+	servers := make([]*server.ChatServer, 0, len(e.servers))
+	for id, srv := range e.servers {
+		servers = append(servers, srv)
+		if !e.killed[id] {
+			m.Servers++
+		}
+	}
+	e.mu.Unlock()
+
+	if cl != nil {
+		m.Failovers = cl.GetStats().FailoverCount
+	}
+	m.Dropped = e.injector.Stats().Dropped
+	var hits, l1Hits, misses int64
+	for _, srv := range servers {
+		stats := srv.GetCacheInfo().Stats
+		hits += stats.CacheHits
+		l1Hits += stats.L1Hits
+		misses += stats.CacheMisses
+		m.Evictions += stats.Evictions
+	}
+	if lookups := hits + misses; lookups > 0 {
+		m.HitRate = float64(hits) / float64(lookups)
+		m.L1HitRate = float64(l1Hits) / float64(lookups)
+	}
+	return m
+}
+
+// assert checks the phase's bounds against the metrics so far
+func (e *engine) assert(phase Phase) []Check {
+	m := e.snapshot()
+	var checks []Check
+	for _, name := range sortedMetrics(phase.Assert) {
+		bound := phase.Assert[name]
+		value := metricValues[name](m)
+		check := Check{Phase: phase.String(), Metric: name, Value: value, Bound: bound, Passed: bound.Contains(value)}
+		checks = append(checks, check)
+		e.logf("  %s", check)
+	}
+	return checks
+}
+
+// close stops the client and the servers, and any partition's heal
+func (e *engine) close() {
+	e.mu.Lock()
+	cl := e.client
+	servers := e.servers
+	for _, stop := range e.heals {
+		stop()
+	}
+	e.mu.Unlock()
+
+	if cl != nil {
+		cl.Close()
+	}
+	for _, srv := range servers {
+		srv.Stop()
+	}
+}
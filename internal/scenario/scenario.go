@@ -0,0 +1,461 @@
+// Package scenario runs failure narratives written as YAML instead of Go:
+// start a cluster, send a workload, kill and add servers at given times,
+// inject faults, and check the resulting metrics. Scenarios run in memory
+// (see package chattest), and with a seed on a virtual clock, so the same
+// file plays out the same way on every run.
+//
+//	name: lose a server
+//	seed: 7
+//	phases:
+//	  - start: {servers: 3, capacity: 100}
+//	  - kill: server-2
+//	    at: 1s
+//	  - send: {messages: 50, chats: 25, interval: 100ms}
+//	  - assert:
+//	      errors: {max: 0}
+//	      failovers: {min: 1}
+//
+// A kills phase takes down several servers in a row, e.g. two of three a
+// second apart, to follow chats down their failover chains to whichever
+// server is left:
+//
+//	name: cascade
+//	phases:
+//	  - start: {servers: 3}
+//	  - kills: {count: 2, every: 1s}
+//	    at: 1s
+//	  - send: {messages: 100, interval: 50ms}
+package scenario
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/pkg/chaos"
+	"gopkg.in/yaml.v3"
+)
+
+// Scenario is a named sequence of phases
+type Scenario struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description"`
+
+	// Seed, if set, runs the scenario on a virtual clock with random
+	// streams drawn from it, so it plays out identically on every run;
+	// otherwise it runs in real time
+	Seed int64 `yaml:"seed"`
+
+	Phases []Phase `yaml:"phases"`
+}
+
+// Phase is one step of a scenario. Exactly one of its actions is set.
+// Phases run in order, except that kill, kills, add, remove, fault,
+// partition and heal phases with At set are scheduled for that offset from
+// the start of the scenario instead, and happen as time passes in later
+// phases (e.g. between the messages of a paced send).
+type Phase struct {
+	Name string        `yaml:"name"`
+	At   time.Duration `yaml:"at"`
+
+	Start     *Start           `yaml:"start"`
+	Send      *Send            `yaml:"send"`
+	Wait      time.Duration    `yaml:"wait"`
+	Kill      string           `yaml:"kill"` // Stops the server; it stays in the ring
+	Kills     *Kills           `yaml:"kills"`
+	Add       *Server          `yaml:"add"`    // Starts a server and adds it to the ring
+	Remove    string           `yaml:"remove"` // Takes the server out of the ring
+	Fault     *Fault           `yaml:"fault"`
+	Partition *Partition       `yaml:"partition"`
+	Heal      string           `yaml:"heal"` // Removes the fault or partition of that name
+	Assert    map[string]Bound `yaml:"assert"`
+}
+
+// Start starts the cluster: servers named server-1, server-2 and so on
+type Start struct {
+	Servers  int `yaml:"servers"`  // default: 3
+	Capacity int `yaml:"capacity"` // Virtual nodes of each (default: 100)
+	L1       int `yaml:"l1"`       // Per server (defaults: the server's)
+	L2       int `yaml:"l2"`
+
+	// Servers holding each chat, with writes acknowledged by a majority
+	// (default: 1, so servers don't call each other)
+	Replicas int `yaml:"replicas"`
+}
+
+// Send sends messages through the cluster's client, one at a time
+type Send struct {
+	Messages int `yaml:"messages"`
+	Chats    int `yaml:"chats"` // Distinct chats (default: 10)
+
+	// "uniform" (default) or "zipf", where a few chats get most messages
+	Distribution string `yaml:"distribution"`
+
+	// Time between messages; scheduled phases fall due during it
+	Interval time.Duration `yaml:"interval"`
+}
+
+// Kills stops several servers one after another, as kill does each: the
+// ones listed, or a number of those running, picked at random (from the
+// seed, if set) as each is killed
+type Kills struct {
+	Servers []string `yaml:"servers"`
+	Count   int      `yaml:"count"`
+
+	// Time between kills (default: none). A scheduled kills phase starts
+	// at its offset, and the phases after it run in the meantime;
+	// otherwise it waits out the gaps itself.
+	Every time.Duration `yaml:"every"`
+}
+
+// total is the number of servers the phase kills
+func (k Kills) total() int {
+	if len(k.Servers) > 0 {
+		return len(k.Servers)
+	}
+	return k.Count
+}
+
+// Server is a server joining the cluster. It takes the L1 and L2 sizes
+// of the start phase.
+type Server struct {
+	ID       string `yaml:"id"`
+	Capacity int    `yaml:"capacity"` // default: the start phase's
+}
+
+// Fault is a chaos rule between nodes: the client is "client" and servers
+// go by their IDs
+type Fault struct {
+	Name         string             `yaml:"name"`
+	From         string             `yaml:"from"`
+	To           string             `yaml:"to"`
+	Latency      time.Duration      `yaml:"latency"`
+	Jitter       time.Duration      `yaml:"jitter"`
+	Distribution chaos.Distribution `yaml:"distribution"` // uniform (default), normal, exponential or pareto
+	DropRate     float64            `yaml:"drop_rate"`
+	Stall        bool               `yaml:"stall"` // Dropped calls hang until their deadline
+}
+
+// Partition severs every link between two sides, in both directions: the
+// client is "client" and servers go by their IDs. The nodes on each side
+// still reach each other, and the rest of the cluster.
+type Partition struct {
+	Name  string        `yaml:"name"`  // For heal (default: the sides)
+	Sides [][]string    `yaml:"sides"` // Exactly two
+	For   time.Duration `yaml:"for"`   // Heals after this long (default: at a heal phase)
+}
+
+// links returns the pairs of nodes the partition cuts apart
+func (p Partition) links() [][2]string {
+	var links [][2]string
+	for _, a := range p.Sides[0] {
+		for _, b := range p.Sides[1] {
+			links = append(links, [2]string{a, b})
+		}
+	}
+	return links
+}
+
+// Bound is the range a metric must fall in; either end may be left open
+type Bound struct {
+	Min *float64 `yaml:"min"`
+	Max *float64 `yaml:"max"`
+}
+
+// Contains reports whether value is in range
+func (b Bound) Contains(value float64) bool {
+	return (b.Min == nil || value >= *b.Min) && (b.Max == nil || value <= *b.Max)
+}
+
+func (b Bound) String() string {
+	switch {
+	case b.Min != nil && b.Max != nil:
+		return fmt.Sprintf("in [%g, %g]", *b.Min, *b.Max)
+	case b.Min != nil:
+		return fmt.Sprintf(">= %g", *b.Min)
+	case b.Max != nil:
+		return fmt.Sprintf("<= %g", *b.Max)
+	default:
+		return "any value"
+	}
+}
+
+// Load reads and validates the scenario in the YAML file at path
+func Load(path string) (Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Scenario{}, err
+	}
+	return Parse(data)
+}
+
+// Parse decodes and validates a YAML scenario. Unknown fields are errors,
+// so a misspelt action isn't silently skipped.
+func Parse(data []byte) (Scenario, error) {
+	var s Scenario
+	dec := yaml.NewDecoder(strings.NewReader(string(data)))
+	dec.KnownFields(true)
+	if err := dec.Decode(&s); err != nil {
+		return Scenario{}, fmt.Errorf("invalid scenario: %w", err)
+	}
+	if err := s.Validate(); err != nil {
+		return Scenario{}, err
+	}
+	return s, nil
+}
+
+// action names the phase's action, or returns "" if it has none or more
+// than one
+func (p Phase) action() string {
+	var set []string
+	add := func(name string, ok bool) {
+		if ok {
+			set = append(set, name)
+		}
+	}
+	add("start", p.Start != nil)
+	add("send", p.Send != nil)
+	add("wait", p.Wait != 0)
+	add("kill", p.Kill != "")
+	add("kills", p.Kills != nil)
+	add("add", p.Add != nil)
+	add("remove", p.Remove != "")
+	add("fault", p.Fault != nil)
+	add("partition", p.Partition != nil)
+	add("heal", p.Heal != "")
+	add("assert", p.Assert != nil)
+	if len(set) != 1 {
+		return ""
+	}
+	return set[0]
+}
+
+// scheduled reports whether the phase runs at its offset rather than in
+// order
+func (p Phase) scheduled() bool {
+	switch p.action() {
+	case "kill", "kills", "add", "remove", "fault", "partition", "heal":
+		return p.At > 0
+	}
+	return false
+}
+
+// String describes the phase for progress output and errors
+func (p Phase) String() string {
+	if p.Name != "" {
+		return p.Name
+	}
+	switch p.action() {
+	case "start":
+		return fmt.Sprintf("start %d servers", p.Start.Servers)
+	case "send":
+		return fmt.Sprintf("send %d messages to %d chats (%s)", p.Send.Messages, p.Send.Chats, p.Send.Distribution)
+	case "wait":
+		return fmt.Sprintf("wait %v", p.Wait)
+	case "kill":
+		return "kill " + p.Kill
+	case "kills":
+		victims := strings.Join(p.Kills.Servers, ", ")
+		if victims == "" {
+			victims = fmt.Sprintf("%d servers", p.Kills.Count)
+		}
+		if p.Kills.Every > 0 {
+			return fmt.Sprintf("kill %s, %v apart", victims, p.Kills.Every)
+		}
+		return "kill " + victims
+	case "add":
+		return "add " + p.Add.ID
+	case "remove":
+		return "remove " + p.Remove
+	case "fault":
+		return "fault " + p.Fault.Name
+	case "partition":
+		s := "partition " + p.Partition.Name
+		if p.Partition.For > 0 {
+			s += fmt.Sprintf(" for %v", p.Partition.For)
+		}
+		return s
+	case "heal":
+		return "heal " + p.Heal
+	case "assert":
+		return "assert " + strings.Join(sortedMetrics(p.Assert), ", ")
+	}
+	return "invalid phase"
+}
+
+// Validate checks the scenario can run, filling in defaults: one action
+// per phase, a single start before anything touching the cluster, known
+// servers and metrics, and offsets only on phases that can be scheduled
+func (s *Scenario) Validate() error {
+	if len(s.Phases) == 0 {
+		return fmt.Errorf("scenario %q has no phases", s.Name)
+	}
+
+	started := false
+	servers := make(map[string]bool)
+	capacity := 0
+	for i := range s.Phases {
+		p := &s.Phases[i]
+		fail := func(format string, args ...any) error {
+			return fmt.Errorf("phase %d (%s): %s", i+1, p, fmt.Sprintf(format, args...))
+		}
+
+		action := p.action()
+		if action == "" {
+			return fmt.Errorf("phase %d: needs exactly one of start, send, wait, kill, kills, add, remove, fault, partition, heal or assert", i+1)
+		}
+		if p.At < 0 {
+			return fail("at must not be negative")
+		}
+		if p.At > 0 && !p.scheduled() {
+			return fail("only kill, kills, add, remove, fault, partition and heal phases can be scheduled with at")
+		}
+		if action != "start" && !started {
+			return fail("the cluster must be started first")
+		}
+
+		switch action {
+		case "start":
+			if started {
+				return fail("the cluster is already started")
+			}
+			started = true
+			if p.Start.Servers <= 0 {
+				p.Start.Servers = 3
+			}
+			if p.Start.Capacity <= 0 {
+				p.Start.Capacity = 100
+			}
+			if p.Start.Replicas <= 0 {
+				p.Start.Replicas = 1
+			}
+			if p.Start.Replicas > p.Start.Servers {
+				return fail("can't keep %d replicas on %d servers", p.Start.Replicas, p.Start.Servers)
+			}
+			capacity = p.Start.Capacity
+			for n := 1; n <= p.Start.Servers; n++ {
+				servers[fmt.Sprintf("server-%d", n)] = true
+			}
+		case "send":
+			if p.Send.Messages <= 0 {
+				return fail("messages must be positive")
+			}
+			if p.Send.Chats <= 0 {
+				p.Send.Chats = 10
+			}
+			if p.Send.Distribution == "" {
+				p.Send.Distribution = "uniform"
+			}
+			if p.Send.Distribution != "uniform" && p.Send.Distribution != "zipf" {
+				return fail("unknown distribution %q (want uniform or zipf)", p.Send.Distribution)
+			}
+		case "wait":
+			if p.Wait < 0 {
+				return fail("wait must not be negative")
+			}
+		case "kills":
+			k := p.Kills
+			switch {
+			case len(k.Servers) > 0 && k.Count != 0:
+				return fail("kills takes servers or a count, not both")
+			case len(k.Servers) == 0 && k.Count <= 0:
+				return fail("kills needs servers or a positive count")
+			case k.Count > len(servers):
+				return fail("can't kill %d of the %d servers", k.Count, len(servers))
+			case k.Every < 0:
+				return fail("every must not be negative")
+			}
+			listed := make(map[string]bool)
+			for _, id := range k.Servers {
+				if listed[id] {
+					return fail("server %s is listed twice", id)
+				}
+				listed[id] = true
+			}
+		case "add":
+			if p.Add.ID == "" {
+				return fail("the server to add needs an id")
+			}
+			if servers[p.Add.ID] {
+				return fail("server %s already exists", p.Add.ID)
+			}
+			if p.Add.Capacity <= 0 {
+				p.Add.Capacity = capacity
+			}
+			servers[p.Add.ID] = true
+		case "fault":
+			if p.Fault.Name == "" {
+				return fail("the fault needs a name")
+			}
+			if p.Fault.DropRate < 0 || p.Fault.DropRate > 1 {
+				return fail("drop_rate must be between 0 and 1")
+			}
+			if _, err := chaos.ParseDistribution(string(p.Fault.Distribution)); err != nil {
+				return fail("%v", err)
+			}
+		case "partition":
+			part := p.Partition
+			if len(part.Sides) != 2 || len(part.Sides[0]) == 0 || len(part.Sides[1]) == 0 {
+				return fail("a partition needs two sides, each of one node or more")
+			}
+			if part.For < 0 {
+				return fail("for must not be negative")
+			}
+			for _, id := range part.Sides[0] {
+				if slices.Contains(part.Sides[1], id) {
+					return fail("%s is on both sides", id)
+				}
+			}
+			if part.Name == "" {
+				part.Name = strings.Join(part.Sides[0], ",") + " | " + strings.Join(part.Sides[1], ",")
+			}
+		case "assert":
+			if len(p.Assert) == 0 {
+				return fail("nothing to assert")
+			}
+			for _, metric := range sortedMetrics(p.Assert) {
+				if _, ok := metricValues[metric]; !ok {
+					return fail("unknown metric %q (want one of %s)", metric, strings.Join(MetricNames(), ", "))
+				}
+			}
+		}
+	}
+
+	// Servers may be killed or removed before they're added, if the add is
+	// scheduled earlier, so only check that they're added at some point
+	for i, p := range s.Phases {
+		ids := []string{p.Kill, p.Remove}
+		if p.Kills != nil {
+			ids = append(ids, p.Kills.Servers...)
+		}
+		if p.Partition != nil {
+			for _, side := range p.Partition.Sides {
+				for _, id := range side {
+					if id != "client" {
+						ids = append(ids, id)
+					}
+				}
+			}
+		}
+		for _, id := range ids {
+			if id != "" && !servers[id] {
+				return fmt.Errorf("phase %d (%s): no server %s in the scenario", i+1, p, id)
+			}
+		}
+	}
+	return nil
+}
+
+// sortedMetrics returns the metrics of an assert phase in order, so checks
+// run and print in the same order every time
+func sortedMetrics(bounds map[string]Bound) []string {
+	names := make([]string, 0, len(bounds))
+	for name := range bounds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,280 @@
+package scenario
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseDefaults(t *testing.T) {
+	s, err := Parse([]byte(`
+name: defaults
+phases:
+  - start: {}
+  - send: {messages: 5}
+  - add: {id: server-9}
+    at: 2s
+`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	if start := s.Phases[0].Start; start.Servers != 3 || start.Capacity != 100 {
+		t.Errorf("Expected 3 servers of capacity 100, got %+v", start)
+	}
+	if send := s.Phases[1].Send; send.Chats != 10 || send.Distribution != "uniform" {
+		t.Errorf("Expected 10 chats, uniform, got %+v", send)
+	}
+	if add := s.Phases[2]; add.Add.Capacity != 100 || add.At != 2*time.Second || !add.scheduled() {
+		t.Errorf("Expected a scheduled add of capacity 100 at 2s, got %+v", add)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		scenario string
+		want     string
+	}{
+		{"no phases", `name: empty`, "no phases"},
+		{"unknown field", "phases:\n  - start: {}\n  - crash: server-1", "field crash not found"},
+		{"two actions", "phases:\n  - start: {}\n    kill: server-1", "exactly one"},
+		{"not started", "phases:\n  - send: {messages: 1}", "must be started first"},
+		{"started twice", "phases:\n  - start: {}\n  - start: {}", "already started"},
+		{"unknown server", "phases:\n  - start: {}\n  - kill: server-4", "no server server-4"},
+		{"duplicate server", "phases:\n  - start: {}\n  - add: {id: server-1}", "already exists"},
+		{"scheduled send", "phases:\n  - start: {}\n  - send: {messages: 1}\n    at: 1s", "can be scheduled"},
+		{"bad distribution", "phases:\n  - start: {}\n  - send: {messages: 1, distribution: pareto}", "unknown distribution"},
+		{"unknown metric", "phases:\n  - start: {}\n  - assert: {latency: {max: 1}}", "unknown metric"},
+		{"bad drop rate", "phases:\n  - start: {}\n  - fault: {name: f, drop_rate: 2}", "drop_rate"},
+		{"bad latency distribution", "phases:\n  - start: {}\n  - fault: {name: f, distribution: zipf}", "latency distribution"},
+		{"kills without servers", "phases:\n  - start: {}\n  - kills: {every: 1s}", "servers or a positive count"},
+		{"kills both ways", "phases:\n  - start: {}\n  - kills: {servers: [server-1], count: 1}", "not both"},
+		{"kills too many", "phases:\n  - start: {servers: 2}\n  - kills: {count: 3}", "can't kill 3"},
+		{"kills unknown server", "phases:\n  - start: {}\n  - kills: {servers: [server-1, server-7]}", "no server server-7"},
+		{"kills twice", "phases:\n  - start: {}\n  - kills: {servers: [server-1, server-1]}", "listed twice"},
+		{"too many replicas", "phases:\n  - start: {servers: 2, replicas: 3}", "3 replicas on 2 servers"},
+		{"one-sided partition", "phases:\n  - start: {}\n  - partition: {sides: [[client]]}", "two sides"},
+		{"empty partition side", "phases:\n  - start: {}\n  - partition: {sides: [[client], []]}", "two sides"},
+		{"partition both sides", "phases:\n  - start: {}\n  - partition: {sides: [[server-1], [server-1]]}", "on both sides"},
+		{"partition unknown server", "phases:\n  - start: {}\n  - partition: {sides: [[client], [server-5]]}", "no server server-5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.scenario))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected an error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestExamplesParse(t *testing.T) {
+	paths, err := filepath.Glob("../../scenarios/*.yaml")
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("Expected example scenarios, got %v (%v)", paths, err)
+	}
+	for _, path := range paths {
+		if _, err := Load(path); err != nil {
+			t.Errorf("Expected %s to load, got %v", path, err)
+		}
+	}
+}
+
+func TestRunFailover(t *testing.T) {
+	s, err := Parse([]byte(`
+seed: 3
+phases:
+  - start: {servers: 3}
+  - kill: server-1
+    at: 100ms
+  - send: {messages: 30, chats: 10, interval: 10ms}
+  - assert:
+      sent: {min: 30, max: 30}
+      errors: {max: 0}
+      failovers: {min: 1}
+      servers: {max: 2}
+`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	var out strings.Builder
+	report, err := Run(context.Background(), s, &out)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if !report.Passed() {
+		t.Errorf("Expected every check to pass, got %v", report.Checks)
+	}
+	if report.Elapsed != 300*time.Millisecond {
+		t.Errorf("Expected 300ms of simulated time, got %v", report.Elapsed)
+	}
+	if !strings.Contains(out.String(), "[   100ms] kill server-1") {
+		t.Errorf("Expected the kill at 100ms in the output, got:\n%s", out.String())
+	}
+}
+
+func TestRunKills(t *testing.T) {
+	s, err := Parse([]byte(`
+seed: 5
+phases:
+  - start: {servers: 3}
+  - kills: {count: 2, every: 100ms}
+    at: 100ms
+  - send: {messages: 40, chats: 20, interval: 10ms}
+  - assert:
+      errors: {max: 0}
+      servers: {min: 1, max: 1}
+      max_chain: {min: 1}
+`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	var out strings.Builder
+	report, err := Run(context.Background(), s, &out)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if !report.Passed() {
+		t.Errorf("Expected every check to pass, got %v", report.Checks)
+	}
+	for _, line := range []string{"[   100ms]   killed", "[   200ms]   killed"} {
+		if !strings.Contains(out.String(), line) {
+			t.Errorf("Expected %q in the output, got:\n%s", line, out.String())
+		}
+	}
+
+	// Run in order, the kills wait out their gaps themselves
+	s, err = Parse([]byte(`
+seed: 5
+phases:
+  - start: {servers: 3}
+  - send: {messages: 20, chats: 20}
+  - kills: {servers: [server-3, server-1], every: 100ms}
+  - send: {messages: 20, chats: 20, interval: 10ms}
+`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	report, err = Run(context.Background(), s, nil)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if report.Elapsed != 300*time.Millisecond || report.Metrics.Servers != 1 || report.Metrics.Errors != 0 {
+		t.Errorf("Expected 1 server left after 300ms and no errors, got %+v after %v", report.Metrics, report.Elapsed)
+	}
+}
+
+func TestRunPartition(t *testing.T) {
+	s, err := Parse([]byte(`
+seed: 2
+phases:
+  - start: {servers: 3, replicas: 3}
+  - send: {messages: 10, chats: 10}
+  - partition: {sides: [[client], [server-1]], for: 200ms}
+    at: 100ms
+  - partition: {name: servers, sides: [[server-2], [server-3]]}
+    at: 100ms
+  - heal: servers
+    at: 300ms
+  - send: {messages: 40, chats: 10, interval: 10ms}
+  - assert:
+      errors: {max: 0}
+      failovers: {min: 1}
+      dropped: {min: 1}
+      servers: {min: 3}
+`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if name := s.Phases[2].Partition.Name; name != "client | server-1" {
+		t.Errorf("Expected the partition named after its sides, got %q", name)
+	}
+
+	var out strings.Builder
+	report, err := Run(context.Background(), s, &out)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if !report.Passed() {
+		t.Errorf("Expected every check to pass, got %v", report.Checks)
+	}
+	for _, line := range []string{"[   300ms] heal client | server-1", "[   300ms] heal servers"} {
+		if !strings.Contains(out.String(), line) {
+			t.Errorf("Expected %q in the output, got:\n%s", line, out.String())
+		}
+	}
+}
+
+func TestRunIsReproducible(t *testing.T) {
+	s, err := Parse([]byte(`
+seed: 11
+phases:
+  - start: {servers: 2, l1: 2, l2: 4}
+  - fault: {name: lossy, to: server-2, drop_rate: 0.3}
+  - add: {id: server-3}
+    at: 50ms
+  - send: {messages: 40, chats: 20, distribution: zipf, interval: 5ms}
+`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	run := func() (string, Metrics) {
+		var out strings.Builder
+		report, err := Run(context.Background(), s, &out)
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		return out.String(), report.Metrics
+	}
+	firstOut, first := run()
+	secondOut, second := run()
+	if firstOut != secondOut || first != second {
+		t.Errorf("Expected identical runs from the same seed, got %+v and %+v", first, second)
+	}
+}
+
+func TestFailedAssertion(t *testing.T) {
+	s, err := Parse([]byte(`
+phases:
+  - start: {servers: 1}
+  - send: {messages: 3}
+  - assert: {sent: {min: 4}}
+`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	report, err := Run(context.Background(), s, nil)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if report.Passed() || len(report.Checks) != 1 || report.Checks[0].Value != 3 {
+		t.Errorf("Expected one failed check of sent = 3, got %v", report.Checks)
+	}
+}
+
+func TestPhaseNeverRuns(t *testing.T) {
+	s, err := Parse([]byte(`
+seed: 1
+phases:
+  - start: {servers: 2}
+  - kill: server-2
+    at: 1h
+  - wait: 1s
+`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	_, err = Run(context.Background(), s, nil)
+	if err == nil || !strings.Contains(err.Error(), "never ran") {
+		t.Errorf("Expected the kill at 1h to be reported, got %v", err)
+	}
+}
@@ -0,0 +1,180 @@
+// Package statsdiff turns the cumulative counters reported by clients,
+// servers and caches into rates. A Snapshot captures a source's counters
+// at one moment; the Delta between two snapshots gives what happened in
+// between, e.g. hits, evictions or failovers per second, which is what
+// an operator watching a live cluster wants rather than totals since
+// start.
+package statsdiff
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/pkg/cache"
+	"github.com/sh4shv4t/DistriChat/pkg/client"
+	pb "github.com/sh4shv4t/DistriChat/proto"
+)
+
+// Counter names. Sources report the ones they keep.
+const (
+	Requests      = "requests"
+	Hits          = "hits"
+	Misses        = "misses"
+	L1Hits        = "l1_hits"
+	L2Hits        = "l2_hits"
+	Evictions     = "evictions"
+	Demotions     = "demotions"
+	ArchiveHits   = "archive_hits"
+	Duplicates    = "duplicates"
+	StaleReads    = "stale_reads"
+	RateLimited   = "rate_limited"
+	RingConflicts = "ring_conflicts"
+	SlowRequests  = "slow_requests"
+	PolicyFlags   = "policy_flagged"
+	PolicyRejects = "policy_rejected"
+	Successes     = "successes"
+	Failures      = "failures"
+	Failovers     = "failovers"
+	PrimaryHits   = "primary_hits"
+)
+
+// Snapshot is a source's cumulative counters at one moment
+type Snapshot struct {
+	Time     time.Time
+	Counters map[string]int64
+}
+
+// FromCache captures a cache's statistics, read at the given time
+func FromCache(at time.Time, s cache.CacheStats) Snapshot {
+	return Snapshot{Time: at, Counters: map[string]int64{
+		Requests:    s.TotalRequests,
+		Hits:        s.CacheHits,
+		Misses:      s.CacheMisses,
+		L1Hits:      s.L1Hits,
+		L2Hits:      s.L2Hits,
+		Evictions:   s.Evictions,
+		Demotions:   s.Demotions,
+		ArchiveHits: s.ArchiveHits,
+		Duplicates:  s.Duplicates,
+	}}
+}
+
+// FromServer captures a server's stats snapshot, received at the given
+// time (the snapshot's own timestamp has only second resolution)
+func FromServer(at time.Time, s *pb.StatsSnapshot) Snapshot {
+	return Snapshot{Time: at, Counters: map[string]int64{
+		Requests:      s.GetTotalRequests(),
+		Hits:          s.GetCacheHits(),
+		Misses:        s.GetCacheMisses(),
+		L1Hits:        s.GetL1Hits(),
+		L2Hits:        s.GetL2Hits(),
+		Evictions:     s.GetEvictions(),
+		Demotions:     s.GetDemotions(),
+		StaleReads:    s.GetStaleReads(),
+		RateLimited:   s.GetRateLimited(),
+		RingConflicts: s.GetRingConflicts(),
+		SlowRequests:  s.GetSlowRequests(),
+		PolicyFlags:   s.GetPolicyFlagged(),
+		PolicyRejects: s.GetPolicyRejected(),
+	}}
+}
+
+// FromClient captures a client's statistics, read at the given time
+func FromClient(at time.Time, s client.ClientStats) Snapshot {
+	return Snapshot{Time: at, Counters: map[string]int64{
+		Requests:    s.TotalRequests,
+		Successes:   s.SuccessRequests,
+		Failures:    s.FailedRequests,
+		Failovers:   s.FailoverCount,
+		PrimaryHits: s.PrimaryHits,
+	}}
+}
+
+// Delta is how much each counter grew between two snapshots
+type Delta struct {
+	Start, End time.Time
+	Counters   map[string]int64
+}
+
+// Diff returns the change from prev to cur. A counter lower in cur than
+// in prev was reset (its source restarted), so it counts from zero.
+// Counters missing from prev count from zero too.
+func Diff(prev, cur Snapshot) Delta {
+	d := Delta{Start: prev.Time, End: cur.Time, Counters: make(map[string]int64, len(cur.Counters))}
+	for name, value := range cur.Counters {
+		if before := prev.Counters[name]; value >= before {
+			value -= before
+		}
+		d.Counters[name] = value
+	}
+	return d
+}
+
+// Interval returns the time between the snapshots
+func (d Delta) Interval() time.Duration {
+	return d.End.Sub(d.Start)
+}
+
+// Rate returns a counter's growth per second over the interval (0 for an
+// empty interval)
+func (d Delta) Rate(name string) float64 {
+	seconds := d.Interval().Seconds()
+	if seconds <= 0 {
+		return 0
+	}
+	return float64(d.Counters[name]) / seconds
+}
+
+// HitRatio returns the share of lookups in the interval that hit (0
+// without lookups)
+func (d Delta) HitRatio() float64 {
+	lookups := d.Counters[Hits] + d.Counters[Misses]
+	if lookups == 0 {
+		return 0
+	}
+	return float64(d.Counters[Hits]) / float64(lookups)
+}
+
+// Names returns the delta's counter names, sorted
+func (d Delta) Names() []string {
+	names := make([]string, 0, len(d.Counters))
+	for name := range d.Counters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Tracker keeps the latest snapshot of each source, so every new one can
+// be turned into the delta since the last. It is safe for concurrent use.
+type Tracker struct {
+	mu   sync.Mutex
+	last map[string]Snapshot
+}
+
+// NewTracker creates an empty tracker
+func NewTracker() *Tracker {
+	return &Tracker{last: make(map[string]Snapshot)}
+}
+
+// Observe records source's snapshot and returns the delta since its
+// previous one; ok is false for a source's first snapshot
+func (t *Tracker) Observe(source string, s Snapshot) (delta Delta, ok bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	prev, ok := t.last[source]
+	t.last[source] = s
+	if !ok {
+		return Delta{}, false
+	}
+	return Diff(prev, s), true
+}
+
+// Forget drops a source's snapshot, e.g. when it leaves the cluster
+func (t *Tracker) Forget(source string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.last, source)
+}
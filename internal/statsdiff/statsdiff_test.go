@@ -0,0 +1,70 @@
+package statsdiff
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/pkg/cache"
+	"github.com/sh4shv4t/DistriChat/pkg/client"
+	pb "github.com/sh4shv4t/DistriChat/proto"
+)
+
+var start = time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+func TestDiff(t *testing.T) {
+	prev := FromCache(start, cache.CacheStats{CacheHits: 100, CacheMisses: 20, Evictions: 5})
+	cur := FromCache(start.Add(2*time.Second), cache.CacheStats{CacheHits: 130, CacheMisses: 30, Evictions: 9})
+
+	d := Diff(prev, cur)
+	if d.Interval() != 2*time.Second {
+		t.Errorf("Expected a 2s interval, got %v", d.Interval())
+	}
+	if got := d.Rate(Hits); got != 15 {
+		t.Errorf("Expected 15 hits/sec, got %v", got)
+	}
+	if got := d.Rate(Evictions); got != 2 {
+		t.Errorf("Expected 2 evictions/sec, got %v", got)
+	}
+	if got := d.HitRatio(); got != 0.75 {
+		t.Errorf("Expected a hit ratio of 0.75 over the interval, got %v", got)
+	}
+}
+
+func TestDiffReset(t *testing.T) {
+	prev := FromServer(start, &pb.StatsSnapshot{TotalRequests: 500, CacheHits: 400})
+	cur := FromServer(start.Add(time.Second), &pb.StatsSnapshot{TotalRequests: 40, CacheHits: 30})
+
+	// The server restarted: its new totals are all since the restart
+	d := Diff(prev, cur)
+	if d.Counters[Requests] != 40 || d.Counters[Hits] != 30 {
+		t.Errorf("Expected the reset counters to count from zero, got %v", d.Counters)
+	}
+}
+
+func TestDeltaEmptyInterval(t *testing.T) {
+	s := FromClient(start, client.ClientStats{TotalRequests: 10, FailoverCount: 2})
+	d := Diff(s, s)
+	if d.Rate(Failovers) != 0 || d.HitRatio() != 0 {
+		t.Errorf("Expected zero rates over an empty interval, got %v and %v", d.Rate(Failovers), d.HitRatio())
+	}
+}
+
+func TestTracker(t *testing.T) {
+	tracker := NewTracker()
+
+	if _, ok := tracker.Observe("client", FromClient(start, client.ClientStats{FailoverCount: 1})); ok {
+		t.Error("Expected no delta for the first snapshot")
+	}
+	d, ok := tracker.Observe("client", FromClient(start.Add(time.Second), client.ClientStats{FailoverCount: 4}))
+	if !ok {
+		t.Fatal("Expected a delta for the second snapshot")
+	}
+	if got := d.Rate(Failovers); got != 3 {
+		t.Errorf("Expected 3 failovers/sec, got %v", got)
+	}
+
+	tracker.Forget("client")
+	if _, ok := tracker.Observe("client", FromClient(start.Add(2*time.Second), client.ClientStats{})); ok {
+		t.Error("Expected no delta after forgetting the source")
+	}
+}
@@ -0,0 +1,159 @@
+// Package timeseries keeps the last few minutes of a process's metrics in
+// memory: a fixed number of samples, each holding every metric's value at
+// one moment, the oldest overwritten by the newest. It is enough for a
+// dashboard to draw sparklines of recent load without a Prometheus server
+// in between.
+package timeseries
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Sample is every metric's value at one moment
+type Sample struct {
+	Time   time.Time
+	Values map[string]float64
+}
+
+// Point is one metric's value at one moment
+type Point struct {
+	Time  time.Time
+	Value float64
+}
+
+// Series is one metric's points, oldest first
+type Series struct {
+	Name   string
+	Points []Point
+}
+
+// Buffer holds the most recent samples, up to its capacity. It is safe
+// for concurrent use.
+type Buffer struct {
+	mu      sync.RWMutex
+	samples []Sample // Circular once full; next is the oldest
+	next    int
+	full    bool
+}
+
+// NewBuffer creates a buffer keeping the last capacity samples (at least
+// one)
+func NewBuffer(capacity int) *Buffer {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &Buffer{samples: make([]Sample, 0, capacity)}
+}
+
+// Add appends a sample, dropping the oldest if the buffer is full
+func (b *Buffer) Add(s Sample) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.full {
+		b.samples = append(b.samples, s)
+		if len(b.samples) == cap(b.samples) {
+			b.full = true
+		}
+		return
+	}
+	b.samples[b.next] = s
+	b.next = (b.next + 1) % len(b.samples)
+}
+
+// Len returns the number of samples held
+func (b *Buffer) Len() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.samples)
+}
+
+// Capacity returns the number of samples the buffer keeps
+func (b *Buffer) Capacity() int {
+	return cap(b.samples)
+}
+
+// Query selects series from the buffer. Zero fields select everything.
+type Query struct {
+	Names []string  // Metrics to return
+	Since time.Time // Samples at or after
+
+	// MaxPoints caps the points per series: adjacent samples are averaged
+	// into one, keeping the shape of the series for a narrow chart
+	MaxPoints int
+}
+
+// Query returns the selected metrics in the order named (sorted by name
+// without names). A metric missing from some samples has no points for
+// them.
+func (b *Buffer) Query(q Query) []Series {
+	samples := b.since(q.Since)
+
+	names := q.Names
+	if len(names) == 0 {
+		names = metricNames(samples)
+	}
+	series := make([]Series, 0, len(names))
+	for _, name := range names {
+		var points []Point
+		for _, s := range samples {
+			if v, ok := s.Values[name]; ok {
+				points = append(points, Point{Time: s.Time, Value: v})
+			}
+		}
+		series = append(series, Series{Name: name, Points: downsample(points, q.MaxPoints)})
+	}
+	return series
+}
+
+// since returns the samples at or after t, oldest first
+func (b *Buffer) since(t time.Time) []Sample {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	ordered := make([]Sample, 0, len(b.samples))
+	ordered = append(ordered, b.samples[b.next:]...)
+	ordered = append(ordered, b.samples[:b.next]...)
+	if t.IsZero() {
+		return ordered
+	}
+	first := sort.Search(len(ordered), func(i int) bool { return !ordered[i].Time.Before(t) })
+	return ordered[first:]
+}
+
+// metricNames returns every metric appearing in samples, sorted
+func metricNames(samples []Sample) []string {
+	seen := make(map[string]bool)
+	for _, s := range samples {
+		for name := range s.Values {
+			seen[name] = true
+		}
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// downsample averages runs of adjacent points so at most max remain, each
+// timed at the last point of its run (max <= 0 keeps them all)
+func downsample(points []Point, max int) []Point {
+	if max <= 0 || len(points) <= max {
+		return points
+	}
+	run := (len(points) + max - 1) / max
+	out := make([]Point, 0, max)
+	for start := 0; start < len(points); start += run {
+		end := min(start+run, len(points))
+		sum := 0.0
+		for _, p := range points[start:end] {
+			sum += p.Value
+		}
+		out = append(out, Point{Time: points[end-1].Time, Value: sum / float64(end-start)})
+	}
+	return out
+}
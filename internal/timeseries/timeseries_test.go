@@ -0,0 +1,90 @@
+package timeseries
+
+import (
+	"testing"
+	"time"
+)
+
+var start = time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+// fill adds n samples a second apart with requests = i and, on even
+// seconds, size = 10*i
+func fill(b *Buffer, n int) {
+	for i := 0; i < n; i++ {
+		values := map[string]float64{"requests": float64(i)}
+		if i%2 == 0 {
+			values["size"] = float64(10 * i)
+		}
+		b.Add(Sample{Time: start.Add(time.Duration(i) * time.Second), Values: values})
+	}
+}
+
+func values(points []Point) []float64 {
+	var out []float64
+	for _, p := range points {
+		out = append(out, p.Value)
+	}
+	return out
+}
+
+func equal(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestBufferWraps(t *testing.T) {
+	b := NewBuffer(4)
+	fill(b, 6)
+
+	if b.Len() != 4 {
+		t.Errorf("Expected 4 samples kept, got %d", b.Len())
+	}
+	got := b.Query(Query{Names: []string{"requests"}})[0].Points
+	if want := []float64{2, 3, 4, 5}; !equal(values(got), want) {
+		t.Errorf("Expected the newest samples oldest first %v, got %v", want, values(got))
+	}
+	if !got[0].Time.Equal(start.Add(2 * time.Second)) {
+		t.Errorf("Expected the first point at +2s, got %v", got[0].Time)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	b := NewBuffer(10)
+	fill(b, 6)
+
+	series := b.Query(Query{})
+	if len(series) != 2 || series[0].Name != "requests" || series[1].Name != "size" {
+		t.Fatalf("Expected every metric by name without names, got %v", series)
+	}
+	if want := []float64{0, 20, 40}; !equal(values(series[1].Points), want) {
+		t.Errorf("Expected size only where sampled %v, got %v", want, values(series[1].Points))
+	}
+
+	series = b.Query(Query{Names: []string{"size", "requests"}, Since: start.Add(3 * time.Second)})
+	if series[0].Name != "size" || series[1].Name != "requests" {
+		t.Errorf("Expected the metrics in the order named, got %v", series)
+	}
+	if want := []float64{3, 4, 5}; !equal(values(series[1].Points), want) {
+		t.Errorf("Expected samples since +3s %v, got %v", want, values(series[1].Points))
+	}
+}
+
+func TestQueryMaxPoints(t *testing.T) {
+	b := NewBuffer(10)
+	fill(b, 6)
+
+	got := b.Query(Query{Names: []string{"requests"}, MaxPoints: 3})[0].Points
+	if want := []float64{0.5, 2.5, 4.5}; !equal(values(got), want) {
+		t.Errorf("Expected pairs averaged %v, got %v", want, values(got))
+	}
+	if !got[2].Time.Equal(start.Add(5 * time.Second)) {
+		t.Errorf("Expected the last point at +5s, got %v", got[2].Time)
+	}
+}
@@ -0,0 +1,119 @@
+// Package topology keeps a local ring view in sync with a remote source of
+// truth (the coordinator or a peer) by following its topology stream.
+//
+// The watcher reconnects with exponential backoff when the stream breaks,
+// always resuming from the locally known epoch, so updates missed while
+// disconnected are delivered as a single catch-up state.
+package topology
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/pkg/logging"
+	"github.com/sh4shv4t/DistriChat/pkg/ring"
+	pb "github.com/sh4shv4t/DistriChat/proto"
+)
+
+// Stream is a receive-only topology stream
+type Stream interface {
+	Recv() (*pb.RingState, error)
+}
+
+// Source opens a topology stream starting after knownEpoch
+type Source func(ctx context.Context, knownEpoch uint64) (Stream, error)
+
+// Applier installs a ring state locally and reports whether it was newer
+type Applier func(state ring.RingState) bool
+
+// Watcher follows a topology source in the background
+type Watcher struct {
+	name   string
+	source Source
+	epoch  func() uint64
+	apply  Applier
+	log    *slog.Logger
+
+	minBackoff time.Duration
+	maxBackoff time.Duration
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+	once   sync.Once
+}
+
+// NewWatcher creates a watcher. epoch reports the local view's epoch and is
+// used to resume after reconnects; apply installs each received state.
+// The name only appears in log lines.
+func NewWatcher(name string, source Source, epoch func() uint64, apply Applier) *Watcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Watcher{
+		name:       name,
+		source:     source,
+		epoch:      epoch,
+		apply:      apply,
+		log:        logging.Logger("topology").With("watcher", name),
+		minBackoff: 100 * time.Millisecond,
+		maxBackoff: 5 * time.Second,
+		ctx:        ctx,
+		cancel:     cancel,
+		done:       make(chan struct{}),
+	}
+}
+
+// Start begins following the source in a background goroutine
+func (w *Watcher) Start() {
+	go w.run()
+}
+
+// Stop ends the watch and waits for the background goroutine to exit
+func (w *Watcher) Stop() {
+	w.once.Do(w.cancel)
+	<-w.done
+}
+
+// run consumes the stream, reconnecting with backoff until stopped
+func (w *Watcher) run() {
+	defer close(w.done)
+
+	backoff := w.minBackoff
+	for {
+		stream, err := w.source(w.ctx, w.epoch())
+		if err == nil {
+			backoff = w.minBackoff
+			err = w.consume(stream)
+		}
+
+		if w.ctx.Err() != nil {
+			return
+		}
+		w.log.Warn("Watch interrupted", logging.Err(err), "retry_in", backoff)
+
+		select {
+		case <-time.After(backoff):
+		case <-w.ctx.Done():
+			return
+		}
+
+		backoff *= 2
+		if backoff > w.maxBackoff {
+			backoff = w.maxBackoff
+		}
+	}
+}
+
+// consume applies states from a stream until it fails
+func (w *Watcher) consume(stream Stream) error {
+	for {
+		state, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		if w.apply(state.ToRing()) {
+			w.log.Info("Applied ring", logging.Epoch(state.Epoch), "nodes", len(state.Nodes))
+		}
+	}
+}
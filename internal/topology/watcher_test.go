@@ -0,0 +1,111 @@
+package topology
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/pkg/ring"
+	pb "github.com/sh4shv4t/DistriChat/proto"
+)
+
+// fakeStream replays a fixed list of states and then fails
+type fakeStream struct {
+	states []*pb.RingState
+	err    error
+}
+
+func (s *fakeStream) Recv() (*pb.RingState, error) {
+	if len(s.states) == 0 {
+		return nil, s.err
+	}
+	state := s.states[0]
+	s.states = s.states[1:]
+	return state, nil
+}
+
+func TestWatcherAppliesStates(t *testing.T) {
+	r := ring.NewHashRing(10)
+
+	source := func(ctx context.Context, knownEpoch uint64) (Stream, error) {
+		return &fakeStream{
+			states: []*pb.RingState{
+				{Epoch: 1, Nodes: []*pb.RingNode{{NodeId: "server-a", Address: "a:1", Weight: 10}}},
+				{Epoch: 2, Nodes: []*pb.RingNode{
+					{NodeId: "server-a", Address: "a:1", Weight: 10},
+					{NodeId: "server-b", Address: "b:1", Weight: 10},
+				}},
+			},
+			err: io.EOF,
+		}, nil
+	}
+
+	w := NewWatcher("test", source, r.Epoch, r.Replace)
+	w.Start()
+	defer w.Stop()
+
+	deadline := time.Now().Add(time.Second)
+	for r.Epoch() != 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if r.Epoch() != 2 {
+		t.Fatalf("Expected epoch 2, got %d", r.Epoch())
+	}
+	if r.GetNodeCount() != 2 {
+		t.Errorf("Expected 2 nodes, got %d", r.GetNodeCount())
+	}
+}
+
+func TestWatcherResumesFromLocalEpoch(t *testing.T) {
+	r := ring.NewHashRing(10)
+
+	var mu sync.Mutex
+	var requested []uint64
+	calls := 0
+
+	source := func(ctx context.Context, knownEpoch uint64) (Stream, error) {
+		mu.Lock()
+		requested = append(requested, knownEpoch)
+		calls++
+		first := calls == 1
+		mu.Unlock()
+
+		if first {
+			return &fakeStream{
+				states: []*pb.RingState{{Epoch: 5, Nodes: []*pb.RingNode{{NodeId: "server-a", Address: "a:1", Weight: 10}}}},
+				err:    errors.New("connection reset"),
+			}, nil
+		}
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	w := NewWatcher("test", source, r.Epoch, r.Replace)
+	w.minBackoff = time.Millisecond
+	w.Start()
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		mu.Lock()
+		n := len(requested)
+		mu.Unlock()
+		if n >= 2 {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	w.Stop()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(requested) < 2 {
+		t.Fatalf("Expected a reconnect, got %d source calls", len(requested))
+	}
+	if requested[0] != 0 || requested[1] != 5 {
+		t.Errorf("Expected resume epochs [0 5], got %v", requested[:2])
+	}
+}
@@ -5,50 +5,131 @@
 // 2. Hierarchical L1/L2 Caching
 // 3. Automatic Failover when a server goes down
 //
-// Run with: go run main.go
+// Run with: go run main.go (go run main.go -h lists the settings)
 package main
 
 import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
 	"fmt"
-	"log"
-	"math/rand"
+	"io"
+	"log/slog"
+	"math"
 	"os"
+	"os/exec"
 	"os/signal"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
-	"github.com/distribchat/cmd/client"
-	"github.com/distribchat/cmd/server"
-)
-
-const (
-	// Server ports
-	serverAPort = 50051
-	serverBPort = 50052
-	serverCPort = 50053
-
-	// Virtual node counts (capacity - affects load distribution)
-	serverACapacity = 100 // Standard capacity
-	serverBCapacity = 150 // Higher capacity - more load
-	serverCCapacity = 100 // Standard capacity
-
-	// Cache settings
-	l1Capacity = 5  // L1 (VRAM) capacity per server
-	l2Capacity = 20 // L2 (RAM) capacity per server
-
-	// Simulation settings
-	totalMessages    = 50  // Total messages to send
-	uniqueChats      = 25  // Number of unique chat sessions
-	killServerAfter  = 10  // Kill Server B after this many messages
-	messageDelay     = 100 * time.Millisecond
+	"github.com/sh4shv4t/DistriChat/internal/bench"
+	"github.com/sh4shv4t/DistriChat/internal/dashboard"
+	"github.com/sh4shv4t/DistriChat/internal/experiment"
+	"github.com/sh4shv4t/DistriChat/internal/keyspace"
+	"github.com/sh4shv4t/DistriChat/internal/scenario"
+	"github.com/sh4shv4t/DistriChat/pkg/cache"
+	"github.com/sh4shv4t/DistriChat/pkg/chaos"
+	"github.com/sh4shv4t/DistriChat/pkg/client"
+	"github.com/sh4shv4t/DistriChat/pkg/clock"
+	"github.com/sh4shv4t/DistriChat/pkg/logging"
+	"github.com/sh4shv4t/DistriChat/pkg/rebalance"
+	"github.com/sh4shv4t/DistriChat/pkg/ring"
+	"github.com/sh4shv4t/DistriChat/pkg/server"
+	"github.com/sh4shv4t/DistriChat/pkg/sim"
+	"github.com/sh4shv4t/DistriChat/pkg/tracing"
+	pb "github.com/sh4shv4t/DistriChat/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
 )
 
 func main() {
-	fmt.Println(banner)
-	fmt.Println("DistriChat - High-Performance Distributed Routing Engine")
-	fmt.Println(strings.Repeat("=", 60))
-	fmt.Println()
+	run, output, err := experiment.ParseFlags(flag.CommandLine, os.Args[1:], os.Getenv)
+	if err != nil {
+		fatal("Invalid settings", err)
+	}
+	switch {
+	case output.Quiet:
+		out.level = quiet
+	case output.Verbose:
+		out.level = verbose
+	}
+	out.plain = output.NoEmoji
+	out.live = !output.NoLive
+
+	// A failed check (a message lost in a rolling restart) fails the run
+	// once everything has shut down
+	exitCode := 0
+	defer func() {
+		if exitCode != 0 {
+			os.Exit(exitCode)
+		}
+	}()
+
+	// BENCH=json or BENCH=csv runs the benchmark suite instead of the demo
+	if format := os.Getenv("BENCH"); format != "" {
+		runBenchmarks(format)
+		return
+	}
+
+	// SCENARIO=path runs a YAML scenario instead of the demo
+	if path := os.Getenv("SCENARIO"); path != "" {
+		runScenario(path)
+		return
+	}
+
+	// -compare runs the demo twice and compares the runs
+	if run.Compare != "" {
+		runComparison(run)
+		return
+	}
+
+	w := out.at(normal)
+	fmt.Fprint(w, banner)
+	fmt.Fprintln(w, "DistriChat - High-Performance Distributed Routing Engine")
+	fmt.Fprintln(w, strings.Repeat("=", 60))
+	fmt.Fprintln(w)
+
+	// DASHBOARD=1 watches the run in a terminal dashboard, which replaces
+	// the demo output and the logs
+	watch := os.Getenv("DASHBOARD") != ""
+	logOutput := out.beside(os.Stderr) // Clear of the live statistics
+	if watch {
+		logOutput = io.Discard
+	}
+
+	// Component logs are JSON on stderr; LOG_FORMAT=text is easier to read.
+	// LOG_LEVEL=debug shows every message, as does sending SIGUSR1.
+	logging.Setup(logging.Config{Output: logOutput, Format: os.Getenv("LOG_FORMAT")})
+	defer logging.HandleSignals()()
+
+	// Export traces if a collector is configured (e.g. Jaeger's OTLP port)
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Config{
+		Endpoint: os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"),
+	})
+	if err != nil {
+		fatal("Failed to set up tracing", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	env := newEnvironment(run)
+	env.logs = logOutput
+	if run.Processes && run.Serverd == "" {
+		path, remove := buildServerd()
+		defer remove()
+		run.Serverd = path
+	}
+	keys, err := keyspace.New(run.Workload.KeySpace(), env.keys)
+	if err != nil {
+		fatal("Invalid settings", err)
+	}
 
 	// Handle graceful shutdown
 	sigChan := make(chan os.Signal, 1)
@@ -57,221 +138,1852 @@ func main() {
 	// ================================================================
 	// PHASE 1: Start Servers
 	// ================================================================
-	fmt.Println("📦 PHASE 1: Starting Servers...")
-	fmt.Println(strings.Repeat("-", 40))
+	fmt.Fprintln(w, "📦 PHASE 1: Starting Servers...")
+	fmt.Fprintln(w, strings.Repeat("-", 40))
 
-	servers := startServers()
+	servers := startServers(env, run)
 	defer stopServers(servers)
 
 	// Give servers time to start
-	time.Sleep(500 * time.Millisecond)
-	fmt.Println()
+	env.clock.Sleep(500 * time.Millisecond)
+	fmt.Fprintln(w)
 
 	// ================================================================
 	// PHASE 2: Initialize Smart Client
 	// ================================================================
-	fmt.Println("🔗 PHASE 2: Initializing Smart Client...")
-	fmt.Println(strings.Repeat("-", 40))
+	fmt.Fprintln(w, "🔗 PHASE 2: Initializing Smart Client...")
+	fmt.Fprintln(w, strings.Repeat("-", 40))
 
-	smartClient := initializeClient(servers)
+	smartClient := initializeClient(servers, env, run)
 	defer smartClient.Close()
 
-	fmt.Println()
+	fmt.Fprintln(w)
+	printDistribution(smartClient, keys, run)
+
+	var dashboardDone <-chan struct{}
+	if watch {
+		dashboardDone = startDashboard(smartClient, sigChan, run)
+	}
 
 	// ================================================================
 	// PHASE 3: Send Messages (Normal Operation)
 	// ================================================================
-	fmt.Println("📨 PHASE 3: Sending Messages (Normal Operation)...")
-	fmt.Println(strings.Repeat("-", 40))
+	fmt.Fprintln(w, "📨 PHASE 3: Sending Messages (Normal Operation)...")
+	fmt.Fprintln(w, strings.Repeat("-", 40))
 
-	messagesSent := 0
-	serverBKilled := false
+	if env.chaos {
+		defer env.injector.Schedule(chaosScenario)()
+	}
 
 	// Track which chats go to which servers (before failure)
 	chatAssignments := make(map[string]string)
 
-	for i := 1; i <= totalMessages; i++ {
+	// Restarted servers, by ID, as their caches fill up again
+	restarted := make(map[string]*rewarm)
+	latencies := newLatencyLog()
+
+	// Workers send the messages this loop hands out at the workload's pace;
+	// each event waits for the messages before it to finish
+	jobs := make(chan outgoing)
+	var inFlight sync.WaitGroup
+	for w := 0; w < run.Workload.Workers; w++ {
+		go func() {
+			for m := range jobs {
+				m.send(smartClient, restarted, latencies)
+				inFlight.Done()
+			}
+		}()
+	}
+	defer close(jobs)
+
+	// hand gives a message to a free worker, noting where its chat went
+	// if it is the chat's first
+	hand := func(m outgoing) {
+		if _, exists := chatAssignments[m.chatID]; !exists {
+			targetServer, _, _ := smartClient.GetTargetServer(m.chatID)
+			chatAssignments[m.chatID] = targetServer
+		}
+		inFlight.Add(1)
+		jobs <- m
+	}
+
+	// Warm-up messages fill the caches first, so the statistics show the
+	// steady state rather than the cold start's misses
+	warm := newWarmState()
+	if run.Workload.Warmup > 0 {
+		fmt.Fprintf(w, "🔥 Warming up with %d messages (left out of the statistics)...\n", run.Workload.Warmup)
+		for i := 1; i <= run.Workload.Warmup; i++ {
+			hand(outgoing{
+				n:        i,
+				warmup:   true,
+				chatID:   chatName(keys.Next()),
+				senderID: fmt.Sprintf("user-%d", env.rand.Intn(100)),
+				text:     generateMessage(i),
+			})
+		}
+		inFlight.Wait()
+		warm = takeWarmState(smartClient, servers)
+		fmt.Fprintf(w, "   Done: %d cache hits and %d misses across the servers\n\n",
+			warm.total.CacheHits, warm.total.CacheMisses)
+	}
+	sendStart := time.Now()
+	stopLive := func() {}
+	if !watch {
+		stopLive = showLiveStats(smartClient, latencies, warm.client.FailoverCount)
+		defer stopLive()
+	}
+
+	// The events split the run into phases, each with its own numbers in
+	// the report
+	phases := []phase{{name: "start"}}
+	failovers := warm.client.FailoverCount // Up to the current phase
+	endPhase := func(until int) {
+		total := smartClient.GetStats().FailoverCount
+		phases[len(phases)-1].failovers = total - failovers
+		failovers = total
+		printPhase(phases[len(phases)-1], len(phases)-1, until, latencies)
+	}
+
+	var rolling *rollout // The rolling restart under way, if any
+	for i := 1; i <= run.Workload.Messages; i++ {
 		select {
 		case <-sigChan:
-			fmt.Println("\n🛑 Received shutdown signal, stopping simulation...")
+			fmt.Fprintln(w, "\n🛑 Received shutdown signal, stopping simulation...")
 			return
 		default:
 		}
 
-		// Generate a chat ID
-		chatID := fmt.Sprintf("chat-%03d", (i-1)%uniqueChats)
-		senderID := fmt.Sprintf("user-%d", rand.Intn(100))
-		message := generateMessage(i)
-
-		// Record initial assignment if not seen before
-		if _, exists := chatAssignments[chatID]; !exists {
-			targetServer, _, _ := smartClient.GetTargetServer(chatID)
-			chatAssignments[chatID] = targetServer
-		}
+		// Pick the chat from the workload's key space
+		hand(outgoing{
+			n:        i,
+			phase:    len(phases) - 1,
+			chatID:   chatName(keys.Next()),
+			senderID: fmt.Sprintf("user-%d", env.rand.Intn(100)),
+			text:     generateMessage(i),
+		})
 
-		// Send the message
-		resp, err := smartClient.SendMessage(chatID, senderID, message)
-		if err != nil {
-			log.Printf("❌ Message %d failed: %v", i, err)
-		} else {
-			cacheIndicator := getCacheIndicator(resp.CacheLocation.String())
-			log.Printf("✅ Message %d → Server %s | %s | Chat: %s (msgs: %d)",
-				i, resp.ServerId, cacheIndicator, chatID, resp.MessageCount)
+		// A rolling restart steps on between messages, leaving those in
+		// flight to the drained servers' failover
+		if rolling != nil && rolling.due(i) {
+			id, done := rolling.step()
+			delete(warm.caches, id) // Its statistics start again
+			if done {
+				inFlight.Wait()
+				endPhase(i)
+				if !rolling.finish(latencies) {
+					exitCode = 1
+				}
+				phases = append(phases, phase{name: "after rolling restart", after: i})
+				rolling = nil
+			}
 		}
 
-		messagesSent++
-
 		// ================================================================
-		// PHASE 4: Simulate Server Failure
+		// PHASE 4: Simulate Server Failure and Recovery
 		// ================================================================
-		if i == killServerAfter && !serverBKilled {
-			fmt.Println()
-			fmt.Println("💥 PHASE 4: SIMULATING SERVER FAILURE!")
-			fmt.Println(strings.Repeat("=", 60))
-			fmt.Printf("🔥 Killing Server B (port %d)...\n", serverBPort)
-
-			// Stop Server B
-			servers["B"].Stop()
-
-			// Mark as down in client
-			smartClient.MarkServerDown("Server-B")
-
-			serverBKilled = true
-
-			// Show which chats were on Server B and will need failover
-			affectedChats := 0
-			for chatID, targetServer := range chatAssignments {
-				if targetServer == "Server-B" {
-					affectedChats++
-					newTarget, _, _ := smartClient.GetTargetServer(chatID)
-					fmt.Printf("   📍 %s: Server-B → %s (failover)\n", chatID, newTarget)
-				}
+		for _, e := range run.Events {
+			if e.After != i {
+				continue
 			}
-			fmt.Printf("\n   Total affected chats: %d\n", affectedChats)
-			fmt.Println(strings.Repeat("=", 60))
-			fmt.Println()
-
-			time.Sleep(500 * time.Millisecond)
+			inFlight.Wait()
+			endPhase(i)
+			switch {
+			case e.Kill != "":
+				killServer(e.Kill, servers, smartClient, chatAssignments, run)
+			case e.Restart != "":
+				servers[e.Restart] = restartServer(e.Restart, env, smartClient, chatAssignments, run)
+				restarted[e.Restart] = &rewarm{after: i}
+				delete(warm.caches, e.Restart) // Its statistics start again
+			case e.Add != nil:
+				servers[e.Add.ID] = addServer(*e.Add, env, smartClient, chatAssignments, run)
+			case e.RollingRestart != nil:
+				rolling = startRollout(*e.RollingRestart, i, len(phases), env, run, servers, smartClient)
+			}
+			phases = append(phases, phase{name: e.String(), after: i})
+			env.clock.Sleep(500 * time.Millisecond)
 		}
 
-		time.Sleep(messageDelay)
+		env.clock.Sleep(run.Workload.Interval())
 	}
-
-	fmt.Println()
+	inFlight.Wait()
+	sendTime := time.Since(sendStart)
+	stopLive()
+	endPhase(run.Workload.Messages)
 
 	// ================================================================
 	// PHASE 5: Final Statistics
 	// ================================================================
-	fmt.Println("📊 PHASE 5: Final Statistics")
-	fmt.Println(strings.Repeat("=", 60))
+	w = out.at(quiet) // Printed even with -quiet
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "📊 PHASE 5: Final Statistics")
+	fmt.Fprintln(w, strings.Repeat("=", 60))
 
 	// Client statistics
-	stats := smartClient.GetStats()
-	fmt.Println("\n📈 Client Statistics:")
-	fmt.Printf("   Total Requests:   %d\n", stats.TotalRequests)
-	fmt.Printf("   Successful:       %d (%.1f%%)\n", stats.SuccessRequests,
+	stats := warm.clientStats(smartClient.GetStats())
+	fmt.Fprintln(w, "\n📈 Client Statistics:")
+	fmt.Fprintf(w, "   Total Requests:   %d\n", stats.TotalRequests)
+	fmt.Fprintf(w, "   Successful:       %d (%.1f%%)\n", stats.SuccessRequests,
 		float64(stats.SuccessRequests)/float64(stats.TotalRequests)*100)
-	fmt.Printf("   Failed:           %d\n", stats.FailedRequests)
-	fmt.Printf("   Primary Hits:     %d\n", stats.PrimaryHits)
-	fmt.Printf("   Failovers:        %d\n", stats.FailoverCount)
+	fmt.Fprintf(w, "   Failed:           %d\n", stats.FailedRequests)
+	fmt.Fprintf(w, "   Primary Hits:     %d\n", stats.PrimaryHits)
+	fmt.Fprintf(w, "   Failovers:        %d\n", stats.FailoverCount)
+	fmt.Fprintf(w, "   Throughput:       %.1f msgs/s over %v from %d workers",
+		float64(run.Workload.Messages)/sendTime.Seconds(), sendTime.Round(time.Millisecond), run.Workload.Workers)
+	if run.Workload.QPS > 0 {
+		fmt.Fprintf(w, " (target %.1f)", run.Workload.QPS)
+	}
+	fmt.Fprintln(w)
+
+	if env.injector != nil {
+		faults := env.injector.Stats()
+		fmt.Fprintln(w, "\n🌪️  Injected Faults:")
+		fmt.Fprintf(w, "   Delayed calls:    %d\n", faults.Delayed)
+		fmt.Fprintf(w, "   Dropped calls:    %d\n", faults.Dropped)
+	}
+
+	printLatencies(latencies)
+
+	if len(restarted) > 0 {
+		fmt.Fprintln(w, "\n♻️  Cache Re-warming:")
+		for _, id := range sortedKeys(restarted) {
+			r := restarted[id]
+			fmt.Fprintf(w, "   %s, restarted after message %d: %d messages, %d misses, %d hits",
+				id, r.after, r.misses+r.hits, r.misses, r.hits)
+			if r.firstHit > 0 {
+				fmt.Fprintf(w, " (warm again from message %d)", r.firstHit)
+			}
+			fmt.Fprintln(w)
+		}
+	}
 
 	// Server cache statistics
-	fmt.Println("\n💾 Server Cache Statistics:")
-	for name, srv := range servers {
+	fmt.Fprintln(w, "\n💾 Server Cache Statistics:")
+	for _, name := range sortedKeys(servers) {
+		srv := servers[name]
 		if !srv.IsHealthy() {
-			fmt.Printf("\n   Server %s: OFFLINE\n", name)
+			fmt.Fprintf(w, "\n   %s: OFFLINE\n", name)
 			continue
 		}
-		info := srv.GetCacheInfo()
-		fmt.Printf("\n   Server %s:\n", name)
-		fmt.Printf("     L1 Cache: %d/%d\n", info.L1Size, info.L1Capacity)
-		fmt.Printf("     L2 Cache: %d/%d\n", info.L2Size, info.L2Capacity)
-		fmt.Printf("     Cache Hits: %d (L1: %d, L2: %d)\n",
+		info := warm.cacheInfo(name, srv)
+		fmt.Fprintf(w, "\n   %s:\n", name)
+		fmt.Fprintf(w, "     L1 Cache: %d/%d\n", info.L1Size, info.L1Capacity)
+		fmt.Fprintf(w, "     L2 Cache: %d/%d\n", info.L2Size, info.L2Capacity)
+		fmt.Fprintf(w, "     Cache Hits: %d (L1: %d, L2: %d)\n",
 			info.Stats.CacheHits, info.Stats.L1Hits, info.Stats.L2Hits)
-		fmt.Printf("     Cache Misses: %d\n", info.Stats.CacheMisses)
-		fmt.Printf("     Demotions: %d, Evictions: %d\n",
+		fmt.Fprintf(w, "     Cache Misses: %d\n", info.Stats.CacheMisses)
+		fmt.Fprintf(w, "     Demotions: %d, Evictions: %d\n",
 			info.Stats.Demotions, info.Stats.Evictions)
 	}
 
-	fmt.Println()
-	fmt.Println(strings.Repeat("=", 60))
-	fmt.Println("✨ Simulation Complete!")
-	fmt.Println()
+	if run.Report != "" {
+		report := buildReport(run, sendStart, sendTime, stats, phases, latencies, servers, warm)
+		if err := writeReport(run.Report, report); err != nil {
+			fatal("Failed to write the report", err)
+		}
+		fmt.Fprintf(w, "\n📝 Report written to %s\n", run.Report)
+	}
+
+	w = out.at(normal)
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, strings.Repeat("=", 60))
+	fmt.Fprintln(w, "✨ Simulation Complete!")
+	fmt.Fprintln(w)
+
+	if dashboardDone != nil {
+		<-dashboardDone // Keep the servers up until the user quits
+	}
 }
 
-// startServers creates and starts all server instances
-func startServers() map[string]*server.ChatServer {
-	servers := make(map[string]*server.ChatServer)
+// chaosScenario is what CHAOS=1 does to the client's calls while Server B
+// is killed: Server A gets slow, then a third of the calls to Server C are
+// lost, then the network recovers
+var chaosScenario = []chaos.Step{
+	{At: 1 * time.Second, Name: "slow Server A", Do: chaos.AddRule(chaos.Rule{
+		Name: "slow-a", From: "client", To: "Server-A", Latency: 150 * time.Millisecond, Jitter: 100 * time.Millisecond,
+	})},
+	{At: 3 * time.Second, Name: "lossy Server C", Do: chaos.AddRule(chaos.Rule{
+		Name: "lossy-c", From: "client", To: "Server-C", DropRate: 0.3,
+	})},
+	{At: 6 * time.Second, Name: "recover Server A", Do: chaos.RemoveRule("slow-a")},
+	{At: 6 * time.Second, Name: "recover Server C", Do: chaos.RemoveRule("lossy-c")},
+}
 
-	// Server A - Standard capacity
-	serverA := server.NewChatServer(server.ServerConfig{
-		ServerID:   "Server-A",
-		Port:       serverAPort,
-		L1Capacity: l1Capacity,
-		L2Capacity: l2Capacity,
-	})
-	if err := serverA.Start(); err != nil {
-		log.Fatalf("Failed to start Server A: %v", err)
+// environment is what the servers and client run on: the system clock and
+// fresh randomness, or with a seed (-seed) a simulation's virtual clock and
+// seeded streams, which make the run repeat exactly for the same seed
+type environment struct {
+	clock    clock.Clock
+	rand     *sim.Rand // The simulation's own choices (senders)
+	keys     *sim.Rand // The chats messages go to
+	client   *sim.Rand
+	injector *chaos.Injector // CHAOS=1 or NETWORK_LATENCY only
+	logs     io.Writer       // Where serverd processes log
+	chaos    bool            // Run chaosScenario
+}
+
+// newEnvironment sets up the run from its seed, CHAOS and NETWORK_LATENCY
+func newEnvironment(run experiment.Settings) environment {
+	env := environment{
+		clock:  clock.System(),
+		rand:   sim.NewRand(time.Now().UnixNano()),
+		client: sim.NewRand(time.Now().UnixNano() + 1),
+		keys:   sim.NewRand(time.Now().UnixNano() + 2),
 	}
-	servers["A"] = serverA
 
-	// Server B - Higher capacity (will be killed)
-	serverB := server.NewChatServer(server.ServerConfig{
-		ServerID:   "Server-B",
-		Port:       serverBPort,
-		L1Capacity: l1Capacity,
-		L2Capacity: l2Capacity,
-	})
-	if err := serverB.Start(); err != nil {
-		log.Fatalf("Failed to start Server B: %v", err)
+	// CHAOS=1 injects latency and dropped calls while the messages are sent
+	if os.Getenv("CHAOS") != "" {
+		env.injector = chaos.New()
+		env.chaos = true
 	}
-	servers["B"] = serverB
 
-	// Server C - Standard capacity
-	serverC := server.NewChatServer(server.ServerConfig{
-		ServerID:   "Server-C",
-		Port:       serverCPort,
-		L1Capacity: l1Capacity,
-		L2Capacity: l2Capacity,
-	})
-	if err := serverC.Start(); err != nil {
-		log.Fatalf("Failed to start Server C: %v", err)
+	// NETWORK_LATENCY=2ms delays every call, client to server and server
+	// to server, by about that much, with the odd slow call of a real
+	// network, so cache hits and misses cost what they would in production
+	if value := os.Getenv("NETWORK_LATENCY"); value != "" {
+		latency, err := time.ParseDuration(value)
+		if err != nil {
+			fatal("Invalid NETWORK_LATENCY", err)
+		}
+		if env.injector == nil {
+			env.injector = chaos.New()
+		}
+		env.injector.Add(chaos.Rule{
+			Name: "network", Latency: latency, Jitter: latency / 4, Distribution: chaos.Pareto,
+		})
+	}
+
+	// A seed replays its run: simulated time only moves when the demo
+	// waits, so waits take no real time and timing can't vary between runs
+	if run.Seed != nil {
+		seed := *run.Seed
+		simulation := sim.New(seed)
+		env.clock = simulation.Clock
+		env.rand = simulation.Rand("main")
+		env.client = simulation.Rand("client")
+		env.keys = simulation.Rand("keys")
+		if env.injector != nil {
+			env.injector.SetRand(simulation.Rand("chaos"))
+			env.injector.SetClock(simulation.Clock)
+		}
+		fmt.Fprintf(out.at(normal), "🎲 Deterministic simulation, seed %d (replay with -seed %d)\n\n", seed, seed)
+	}
+	return env
+}
+
+// verbosity is how much of the demo's progress is printed
+type verbosity int
+
+const (
+	quiet   verbosity = iota // A summary of each phase and the final statistics (-quiet)
+	normal                   // Also the setup, each event in detail and failed messages
+	verbose                  // Also a line for every message (-verbose)
+)
+
+// printer writes the demo's output to stdout, leaving out what is above its
+// verbosity, and emoji if plain (-no-emoji) so the output reads well in
+// other tools. On a terminal it can keep a status block under the output,
+// moving it down as lines are printed.
+type printer struct {
+	level verbosity
+	plain bool
+	live  bool // Show a status block while messages are sent (unless -no-live)
+
+	mu     sync.Mutex
+	status func() []string // The status block's lines, nil if none
+	drawn  int             // Lines of the status block on the terminal
+}
+
+// out is where the demo prints, as the flags ask
+var out = &printer{level: normal, live: true}
+
+// emoji matches the emoji the demo prints, with the spaces after them
+var emoji = regexp.MustCompile(`[\x{1F000}-\x{1FAFF}\x{2600}-\x{27BF}\x{2B00}-\x{2BFF}\x{23E9}-\x{23FA}\x{FE0F}\x{200D}]+ *`)
+
+func (p *printer) Write(b []byte) (int, error) {
+	if p.plain {
+		if _, err := p.writeTo(os.Stdout, emoji.ReplaceAll(b, nil)); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+	return p.writeTo(os.Stdout, b)
+}
+
+// writeTo writes b to w above the status block, if any, which is drawn
+// again once b ends a line
+func (p *printer) writeTo(w io.Writer, b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.clear()
+	n, err := w.Write(b)
+	if bytes.HasSuffix(b, []byte("\n")) {
+		p.draw()
+	}
+	return n, err
+}
+
+// beside returns a writer to w, for output sharing the terminal with the
+// demo's (the logs), that keeps clear of the status block
+func (p *printer) beside(w io.Writer) io.Writer {
+	return writerFunc(func(b []byte) (int, error) { return p.writeTo(w, b) })
+}
+
+// show keeps the lines status returns under the output, on a terminal,
+// until hidden; redraw shows them again as they change
+func (p *printer) show(status func() []string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.status = status
+	p.draw()
+}
+
+// hide removes the status block
+func (p *printer) hide() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.clear()
+	p.status = nil
+}
+
+// redraw draws the status block again
+func (p *printer) redraw() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.clear()
+	p.draw()
+}
+
+// draw prints the status block below the cursor. p.mu is held.
+func (p *printer) draw() {
+	if p.status == nil {
+		return
+	}
+	lines := p.status()
+	block := strings.Join(lines, "\n") + "\n"
+	if p.plain {
+		block = emoji.ReplaceAllString(block, "")
+	}
+	os.Stdout.WriteString(block)
+	p.drawn = len(lines)
+}
+
+// clear erases the status block, leaving the cursor where it began. p.mu
+// is held.
+func (p *printer) clear() {
+	if p.drawn > 0 {
+		fmt.Fprintf(os.Stdout, "\x1b[%dF\x1b[J", p.drawn)
+		p.drawn = 0
+	}
+}
+
+// terminal reports whether stdout is a terminal, so a status block can be
+// redrawn in place
+func (p *printer) terminal() bool {
+	info, err := os.Stdout.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// writerFunc is a function that is an io.Writer
+type writerFunc func([]byte) (int, error)
+
+func (f writerFunc) Write(b []byte) (int, error) { return f(b) }
+
+// at returns p if output at level is printed, else a writer discarding it
+func (p *printer) at(level verbosity) io.Writer {
+	if level > p.level {
+		return io.Discard
+	}
+	return p
+}
+
+// sortedKeys returns m's keys in order, so output doesn't depend on map
+// iteration order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
 	}
-	servers["C"] = serverC
+	sort.Strings(keys)
+	return keys
+}
 
+// startServers creates and starts all server instances, keyed by ID
+func startServers(env environment, run experiment.Settings) map[string]node {
+	servers := make(map[string]node)
+	for _, spec := range run.Servers {
+		servers[spec.ID] = startServer(env, run, spec)
+	}
 	return servers
 }
 
+// startServer creates and starts one server
+func startServer(env environment, run experiment.Settings, spec experiment.Server) node {
+	if run.Processes {
+		p, err := startProcess(env, run, spec)
+		if err != nil {
+			fatal("Failed to start "+spec.ID, err)
+		}
+		return p
+	}
+
+	srv := server.NewChatServer(server.ServerConfig{
+		ServerID:   spec.ID,
+		Port:       spec.Port,
+		AdminPort:  spec.Port + experiment.AdminPortOffset,
+		L1Capacity: run.L1Capacity,
+		L2Capacity: run.L2Capacity,
+		Clock:      env.clock,
+		Chaos:      env.injector,
+	})
+	if err := srv.Start(); err != nil {
+		fatal("Failed to start "+spec.ID, err)
+	}
+	return inProcess{srv}
+}
+
+// node is a server the simulation runs: a ChatServer in this process, or
+// a serverd process of its own (-processes)
+type node interface {
+	IsHealthy() bool
+	GetCacheInfo() cache.CacheInfo
+	Drain(reason string) // Stop taking new messages
+	Kill()               // Fail, as in a crash
+	Stop()               // Shut down gracefully
+}
+
+// inProcess is a server running in the simulation's process. It can only
+// be stopped gracefully, so killing it stops it.
+type inProcess struct {
+	*server.ChatServer
+}
+
+func (s inProcess) Kill() { s.Stop() }
+
+// serverProcess is a server running as a serverd process, reached over
+// TCP like any remote server. Killing it sends SIGKILL: a real crash, with
+// no graceful shutdown.
+type serverProcess struct {
+	cmd    *exec.Cmd
+	exited chan struct{} // Closed once the process has exited
+	conn   *grpc.ClientConn
+	admin  pb.AdminServiceClient
+}
+
+// startProcess starts serverd for spec and waits until it serves
+func startProcess(env environment, run experiment.Settings, spec experiment.Server) (*serverProcess, error) {
+	cmd := exec.Command(run.Serverd,
+		"-id", spec.ID,
+		"-port", strconv.Itoa(spec.Port),
+		"-admin-port", strconv.Itoa(spec.Port+experiment.AdminPortOffset),
+		"-l1", strconv.Itoa(run.L1Capacity),
+		"-l2", strconv.Itoa(run.L2Capacity))
+	cmd.Stdout, cmd.Stderr = env.logs, env.logs
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	p := &serverProcess{cmd: cmd, exited: make(chan struct{})}
+	go func() {
+		cmd.Wait()
+		close(p.exited)
+	}()
+
+	conn, err := grpc.Dial(fmt.Sprintf("localhost:%d", spec.Port+experiment.AdminPortOffset),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		p.Kill()
+		return nil, err
+	}
+	p.conn, p.admin = conn, pb.NewAdminServiceClient(conn)
+
+	// It serves once its admin port answers, which opens after the chat port.
+	// Processes run in real time, whatever the simulation's clock.
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		_, err := p.admin.GetStatsSnapshot(ctx, &pb.StatsSnapshotRequest{})
+		cancel()
+		switch {
+		case err == nil:
+			return p, nil
+		case !p.IsHealthy():
+			p.conn.Close()
+			return nil, fmt.Errorf("serverd exited: %v", cmd.ProcessState)
+		case time.Now().After(deadline):
+			p.Kill()
+			return nil, fmt.Errorf("not serving after 10s: %w", err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// IsHealthy reports whether the process is still running
+func (p *serverProcess) IsHealthy() bool {
+	select {
+	case <-p.exited:
+		return false
+	default:
+		return true
+	}
+}
+
+// GetCacheInfo reads the server's cache statistics from its admin port
+// (without the chats in each tier)
+func (p *serverProcess) GetCacheInfo() cache.CacheInfo {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	s, err := p.admin.GetStatsSnapshot(ctx, &pb.StatsSnapshotRequest{})
+	if err != nil {
+		return cache.CacheInfo{}
+	}
+	return cache.CacheInfo{
+		L1Size:     int(s.L1Size),
+		L1Capacity: int(s.L1Capacity),
+		L2Size:     int(s.L2Size),
+		L2Capacity: int(s.L2Capacity),
+		Stats: cache.CacheStats{
+			TotalRequests: s.TotalRequests,
+			CacheHits:     s.CacheHits,
+			CacheMisses:   s.CacheMisses,
+			L1Hits:        s.L1Hits,
+			L2Hits:        s.L2Hits,
+			Evictions:     s.Evictions,
+			Demotions:     s.Demotions,
+		},
+	}
+}
+
+// Drain asks the server to stop taking new messages through its admin
+// port
+func (p *serverProcess) Drain(reason string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if _, err := p.admin.Drain(ctx, &pb.DrainRequest{Reason: reason}); err != nil {
+		slog.Warn("Failed to drain serverd", "pid", p.cmd.Process.Pid, logging.Err(err))
+	}
+}
+
+// Kill sends the process SIGKILL and waits for it to die
+func (p *serverProcess) Kill() {
+	p.cmd.Process.Kill()
+	<-p.exited
+	if p.conn != nil {
+		p.conn.Close()
+	}
+}
+
+// Stop asks the process to shut down gracefully, killing it if it hasn't
+// within 5 seconds
+func (p *serverProcess) Stop() {
+	p.cmd.Process.Signal(syscall.SIGTERM)
+	select {
+	case <-p.exited:
+		p.conn.Close()
+	case <-time.After(5 * time.Second):
+		p.Kill()
+	}
+}
+
+// buildServerd builds cmd/serverd for -processes without -serverd,
+// returning its path and a function removing it
+func buildServerd() (string, func()) {
+	w := out.at(normal)
+	dir, err := os.MkdirTemp("", "districhat-serverd-")
+	if err != nil {
+		fatal("Failed to build serverd", err)
+	}
+	path := filepath.Join(dir, "serverd")
+	fmt.Fprintln(w, "🔨 Building serverd (-serverd skips this)...")
+	build := exec.Command("go", "build", "-o", path, "./cmd/serverd")
+	build.Stdout, build.Stderr = os.Stderr, os.Stderr
+	if err := build.Run(); err != nil {
+		os.RemoveAll(dir)
+		fatal("Failed to build serverd", err)
+	}
+	return path, func() { os.RemoveAll(dir) }
+}
+
+// killServer stops the server called id and shows where its chats fail
+// over to
+func killServer(id string, servers map[string]node, smartClient *client.SmartClient,
+	chatAssignments map[string]string, run experiment.Settings) {
+	w := out.at(normal)
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "💥 PHASE 4: SIMULATING SERVER FAILURE!")
+	fmt.Fprintln(w, strings.Repeat("=", 60))
+	fmt.Fprintf(w, "🔥 Killing %s (port %d)...\n", id, run.Server(id).Port)
+
+	// Stop the server: a crash for a serverd process
+	if p, ok := servers[id].(*serverProcess); ok {
+		fmt.Fprintf(w, "   Sending SIGKILL to serverd (pid %d)\n", p.cmd.Process.Pid)
+	}
+	servers[id].Kill()
+
+	// Mark as down in client
+	smartClient.MarkServerDown(id)
+
+	// Show which chats were on the server and will need failover
+	affectedChats := 0
+	for _, chatID := range sortedKeys(chatAssignments) {
+		if chatAssignments[chatID] == id {
+			affectedChats++
+			newTarget, _, _ := smartClient.GetActiveServer(chatID)
+			fmt.Fprintf(w, "   📍 %s: %s → %s (failover)\n", chatID, id, newTarget)
+		}
+	}
+	fmt.Fprintf(w, "\n   Total affected chats: %d\n", affectedChats)
+	fmt.Fprintln(w, strings.Repeat("=", 60))
+	fmt.Fprintln(w)
+}
+
+// restartServer starts the killed server called id again, with empty
+// caches, and shows its chats moving back to it from where they failed
+// over to
+func restartServer(id string, env environment, smartClient *client.SmartClient,
+	chatAssignments map[string]string, run experiment.Settings) node {
+	w := out.at(normal)
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "🩺 PHASE 4: SIMULATING SERVER RECOVERY!")
+	fmt.Fprintln(w, strings.Repeat("=", 60))
+	fmt.Fprintf(w, "🔄 Restarting %s (port %d) with empty caches...\n", id, run.Server(id).Port)
+
+	// Where the server's chats went while it was down
+	failedOver := make(map[string]string)
+	for chatID, owner := range chatAssignments {
+		if owner == id {
+			failedOver[chatID], _, _ = smartClient.GetActiveServer(chatID)
+		}
+	}
+
+	srv := startServer(env, run, run.Server(id))
+	smartClient.MarkServerUp(id)
+
+	// Show the chats returning to their owner
+	returned := 0
+	for _, chatID := range sortedKeys(failedOver) {
+		target, _, _ := smartClient.GetActiveServer(chatID)
+		fmt.Fprintf(w, "   📍 %s: %s → %s (back to its owner)\n", chatID, failedOver[chatID], target)
+		if target == id {
+			returned++
+		}
+	}
+	fmt.Fprintf(w, "\n   Chats returned: %d of %d; their first messages miss the empty caches\n",
+		returned, len(failedOver))
+	fmt.Fprintln(w, strings.Repeat("=", 60))
+	fmt.Fprintln(w)
+	return srv
+}
+
+// addServer starts a new server and adds it to the client's ring, showing
+// which chats move to it and which stay put: consistent hashing should
+// move only the new server's share, about 1/N of them for N servers
+func addServer(spec experiment.Server, env environment, smartClient *client.SmartClient,
+	chatAssignments map[string]string, run experiment.Settings) node {
+	w := out.at(normal)
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "📈 PHASE 4: SCALING OUT!")
+	fmt.Fprintln(w, strings.Repeat("=", 60))
+	fmt.Fprintf(w, "➕ Adding %s (port %d, capacity: %d)...\n", spec.ID, spec.Port, spec.Capacity)
+
+	// Owners of every chat in the workload, sent to yet or not
+	before := make(map[string]string, run.Workload.Chats)
+	for n := 0; n < run.Workload.Chats; n++ {
+		before[chatName(n)], _, _ = smartClient.GetTargetServer(chatName(n))
+	}
+
+	srv := startServer(env, run, spec)
+	smartClient.AddServer(spec.ID, fmt.Sprintf("localhost:%d", spec.Port), spec.Capacity)
+
+	var moved, elsewhere int
+	var stayed []string
+	for _, chatID := range sortedKeys(before) {
+		owner, _, _ := smartClient.GetTargetServer(chatID)
+		if owner == before[chatID] {
+			stayed = append(stayed, chatID)
+			continue
+		}
+		moved++
+		if owner != spec.ID {
+			elsewhere++
+		}
+		if _, seen := chatAssignments[chatID]; seen {
+			chatAssignments[chatID] = owner
+		}
+		fmt.Fprintf(w, "   📍 %s: %s → %s (moved)\n", chatID, before[chatID], owner)
+	}
+	indent := 14 // Under the first line, the pin being two columns wide
+	if out.plain {
+		indent = 11
+	}
+	for start := 0; start < len(stayed); start += 8 {
+		label, end := "   📌 Stayed: ", min(start+8, len(stayed))
+		if start > 0 {
+			label = strings.Repeat(" ", indent)
+		}
+		line := strings.Join(stayed[start:end], ", ")
+		if end < len(stayed) {
+			line += ","
+		}
+		fmt.Fprintln(w, label+line)
+	}
+
+	// Each server owns its share of the virtual nodes
+	state := smartClient.RingState()
+	total := 0
+	for _, node := range state.Nodes {
+		total += node.Capacity
+	}
+	n := len(state.Nodes)
+	fmt.Fprintf(w, "\n   Chats moved: %d of %d (%.1f%%), %d stayed\n",
+		moved, len(before), 100*float64(moved)/float64(len(before)), len(stayed))
+	fmt.Fprintf(w, "   Expected: 1/N = %.1f%% for N = %d servers, %.1f%% by virtual nodes (%d of %d)\n",
+		100/float64(n), n, 100*float64(spec.Capacity)/float64(total), spec.Capacity, total)
+	if elsewhere > 0 {
+		fmt.Fprintf(w, "   ⚠️  %d chats moved between existing servers\n", elsewhere)
+	} else {
+		fmt.Fprintf(w, "   Every moved chat went to %s; none moved between existing servers\n", spec.ID)
+	}
+	fmt.Fprintln(w, strings.Repeat("=", 60))
+	fmt.Fprintln(w)
+	return srv
+}
+
+// rollout is a rolling restart under way. Each step restarts the server
+// drained by the step before, hands its sessions back and drains the next,
+// without waiting for the messages in flight.
+type rollout struct {
+	order []string // Servers to restart, in turn
+	next  int      // Index in order of the server draining
+	every int      // Messages between steps
+	after int      // Messages handed out before it began
+	phase int      // Index of its phase
+
+	env         environment
+	run         experiment.Settings
+	servers     map[string]node
+	smartClient *client.SmartClient
+	mover       *rebalance.GRPCMover
+}
+
+// startRollout begins a rolling restart of the servers up, in ID order,
+// by draining the first
+func startRollout(r experiment.RollingRestart, after, phase int, env environment, run experiment.Settings,
+	servers map[string]node, smartClient *client.SmartClient) *rollout {
+	w := out.at(normal)
+	ro := &rollout{
+		every:       r.Spacing(),
+		after:       after,
+		phase:       phase,
+		env:         env,
+		run:         run,
+		servers:     servers,
+		smartClient: smartClient,
+		mover:       rebalance.NewGRPCMover(),
+	}
+	for _, id := range sortedKeys(servers) {
+		if servers[id].IsHealthy() {
+			ro.order = append(ro.order, id)
+		}
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "🔁 PHASE 4: ROLLING RESTART!")
+	fmt.Fprintln(w, strings.Repeat("=", 60))
+	fmt.Fprintf(w, "Restarting %s in turn, a step every %d messages, while messages keep flowing\n",
+		strings.Join(ro.order, ", "), ro.every)
+	ro.drain(ro.order[0])
+	fmt.Fprintln(w, strings.Repeat("=", 60))
+	fmt.Fprintln(w)
+	return ro
+}
+
+// due reports whether a step is due once i messages have been handed out
+func (ro *rollout) due(i int) bool {
+	return i > ro.after && (i-ro.after)%ro.every == 0
+}
+
+// step restarts the drained server and drains the next, returning the ID
+// of the server restarted and whether that was the last
+func (ro *rollout) step() (string, bool) {
+	w := out.at(normal)
+	id := ro.order[ro.next]
+	ro.next++
+
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "🔁 Rolling restart, step %d of %d\n", ro.next, len(ro.order))
+	ro.rejoin(id)
+	done := ro.next == len(ro.order)
+	if !done {
+		ro.drain(ro.order[ro.next])
+	}
+	fmt.Fprintln(w)
+	return id, done
+}
+
+// drain stops the server called id taking messages, so the client fails
+// its chats over, and hands its sessions to the servers taking them
+func (ro *rollout) drain(id string) {
+	w := out.at(normal)
+	full := ro.ring("")
+	fmt.Fprintf(w, "   🚰 Draining %s\n", id)
+	ro.servers[id].Drain("rolling restart")
+	moved := ro.handOff(full, ro.ring(id))
+	fmt.Fprintf(w, "   Handed off %d sessions from %s\n", moved, id)
+}
+
+// rejoin restarts the drained server called id and hands its sessions
+// back to it before the client routes to it again
+func (ro *rollout) rejoin(id string) {
+	w := out.at(normal)
+	without := ro.ring(id)
+	fmt.Fprintf(w, "   🔄 Restarting %s (port %d)\n", id, ro.run.Server(id).Port)
+	ro.servers[id].Stop()
+	ro.servers[id] = startServer(ro.env, ro.run, ro.run.Server(id))
+	moved := ro.handOff(without, ro.ring(""))
+	ro.smartClient.MarkServerUp(id)
+	fmt.Fprintf(w, "   Handed back %d sessions; %s rejoined\n", moved, id)
+}
+
+// ring is the client's ring with only the servers up, less the one called
+// without (if any)
+func (ro *rollout) ring(without string) ring.RingState {
+	state := ro.smartClient.RingState()
+	nodes := make([]ring.NodeSpec, 0, len(state.Nodes))
+	for _, n := range state.Nodes {
+		if srv := ro.servers[n.NodeID]; n.NodeID != without && srv != nil && srv.IsHealthy() {
+			nodes = append(nodes, n)
+		}
+	}
+	state.Nodes = nodes
+	return state
+}
+
+// handOff moves the sessions of the chats that change owner between two
+// rings to their new owners, returning how many moved
+func (ro *rollout) handOff(from, to ring.RingState) int64 {
+	w := out.at(normal)
+	var sessions int64
+	for _, transfer := range rebalance.Plan(from, to) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		result, err := ro.mover.Move(ctx, transfer, rebalance.NewThrottle(0))
+		cancel()
+		if err != nil {
+			fmt.Fprintf(w, "   ⚠️  Handing off %s → %s failed: %v\n", transfer.From, transfer.To, err)
+			continue
+		}
+		sessions += result.Sessions
+		fmt.Fprintf(w, "   📦 %s → %s: %d sessions, %d messages\n",
+			transfer.From, transfer.To, result.Sessions, result.Messages)
+	}
+	return sessions
+}
+
+// finish reports the messages sent during the rolling restart, once all
+// have been answered, and whether none failed
+func (ro *rollout) finish(latencies *latencyLog) bool {
+	w := out.at(quiet)
+	ro.mover.Close()
+	var messages []experiment.Message
+	for _, s := range latencies.since(0) {
+		messages = append(messages, experiment.Message{Phase: s.phase, Failed: s.outcome == "Failed"})
+	}
+	sent, err := experiment.CheckRollingRestart(messages, ro.phase)
+
+	fmt.Fprintln(w, strings.Repeat("=", 60))
+	fmt.Fprintf(w, "🔁 Rolling restart done: %d servers restarted, %d messages sent meanwhile\n", len(ro.order), sent)
+	if err != nil {
+		fmt.Fprintf(w, "   ❌ %v\n", err)
+	} else {
+		fmt.Fprintln(w, "   ✅ No messages failed")
+	}
+	fmt.Fprintln(w, strings.Repeat("=", 60))
+	fmt.Fprintln(w)
+	return err == nil
+}
+
+// printDistribution shows how the workload's chats spread over the ring
+// before any event, and the share of the messages they should get, next
+// to each server's share of the virtual nodes, and how far the busiest
+// server is above an even spread
+func printDistribution(smartClient *client.SmartClient, keys *keyspace.Keys, run experiment.Settings) {
+	w := out.at(normal)
+	owned := make(map[string]int)
+	traffic := make(map[string]float64)
+	for n := 0; n < run.Workload.Chats; n++ {
+		owner, _, _ := smartClient.GetTargetServer(chatName(n))
+		owned[owner]++
+		traffic[owner] += keys.Probability(n)
+	}
+	total := 0
+	for _, spec := range run.Servers {
+		total += spec.Capacity
+	}
+
+	fmt.Fprintf(w, "⚖️  Load Distribution (%s keys):\n", keys.Config().Distribution)
+	fmt.Fprintf(w, "   %-12s %8s %8s %10s %10s\n", "", "CHATS", "SHARE", "MESSAGES", "EXPECTED")
+	busiest := 0
+	for _, spec := range run.Servers {
+		chats := owned[spec.ID]
+		busiest = max(busiest, chats)
+		fmt.Fprintf(w, "   %-12s %8d %7.1f%% %9.1f%% %9.1f%%\n", spec.ID, chats,
+			100*float64(chats)/float64(run.Workload.Chats), 100*traffic[spec.ID],
+			100*float64(spec.Capacity)/float64(total))
+	}
+	mean := float64(run.Workload.Chats) / float64(len(run.Servers))
+	fmt.Fprintf(w, "   Busiest server: %d chats, %.2fx the mean of %.1f\n", busiest, float64(busiest)/mean, mean)
+	fmt.Fprintln(w)
+}
+
+// chatName returns the ID of the simulation's nth chat
+func chatName(n int) string {
+	return fmt.Sprintf("chat-%03d", n)
+}
+
+// outgoing is a message handed to a sender worker
+type outgoing struct {
+	n                      int // Position in the workload, from 1
+	phase                  int // Index of the phase it was sent in
+	warmup                 bool
+	chatID, senderID, text string
+}
+
+// send sends the message and prints where it went, recording its round
+// trip and counting it towards the re-warming of the restarted server that
+// answered, if any
+func (m outgoing) send(smartClient *client.SmartClient, restarted map[string]*rewarm, latencies *latencyLog) {
+	start := time.Now()
+	resp, err := smartClient.SendMessage(m.chatID, m.senderID, m.text)
+	if m.warmup {
+		return // Quietly: it only fills the caches
+	}
+	latencies.record(m.phase, resp, time.Since(start))
+	if err != nil {
+		fmt.Fprintf(out.at(normal), "❌ Message %d failed: %v\n", m.n, err)
+		return
+	}
+	cacheIndicator := getCacheIndicator(resp.CacheLocation.String())
+	fmt.Fprintf(out.at(verbose), "✅ Message %d → Server %s | %s | Chat: %s (msgs: %d)\n",
+		m.n, resp.ServerId, cacheIndicator, m.chatID, resp.MessageCount)
+	if w := restarted[resp.ServerId]; w != nil {
+		w.record(m.n, resp.CacheLocation)
+	}
+}
+
+// latencyLog collects the round trips of the simulation's messages, with
+// the phase each was sent in, the server that answered and where its
+// session was found. Injected network latency is included: it takes real
+// time even on a virtual clock.
+type latencyLog struct {
+	mu      sync.Mutex
+	samples []sample
+}
+
+// sample is one message's round trip
+type sample struct {
+	phase   int    // Index into the run's phases
+	server  string // "" if the message failed
+	outcome string // cacheOutcome, or "Failed"
+	elapsed time.Duration
+}
+
+func newLatencyLog() *latencyLog {
+	return &latencyLog{}
+}
+
+// record adds the round trip of a message sent in phase and answered with
+// resp, nil if it failed
+func (l *latencyLog) record(phase int, resp *pb.ChatResponse, elapsed time.Duration) {
+	s := sample{phase: phase, outcome: "Failed", elapsed: elapsed}
+	if resp != nil {
+		s.server = resp.ServerId
+		s.outcome = cacheOutcome(resp.CacheLocation)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.samples = append(l.samples, s)
+}
+
+// where returns the round trips of the samples keep accepts
+func (l *latencyLog) where(keep func(sample) bool) []time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var latencies []time.Duration
+	for _, s := range l.samples {
+		if keep(s) {
+			latencies = append(latencies, s.elapsed)
+		}
+	}
+	return latencies
+}
+
+// since returns the samples recorded after the first n
+func (l *latencyLog) since(n int) []sample {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]sample(nil), l.samples[min(n, len(l.samples)):]...)
+}
+
+// servers returns the IDs of the servers that answered, sorted
+func (l *latencyLog) servers() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	ids := make(map[string]bool)
+	for _, s := range l.samples {
+		if s.server != "" {
+			ids[s.server] = true
+		}
+	}
+	return sortedKeys(ids)
+}
+
+// latencyGroup is a labelled set of round trips
+type latencyGroup struct {
+	label     string
+	latencies []time.Duration
+}
+
+// groups splits the round trips of the successful messages accepted by
+// keep overall, by server and by cache outcome, then adds the failed ones.
+// Empty groups are left out.
+func (l *latencyLog) groups(keep func(sample) bool) []latencyGroup {
+	var groups []latencyGroup
+	add := func(label string, accept func(sample) bool) {
+		latencies := l.where(func(s sample) bool { return keep(s) && accept(s) })
+		if len(latencies) > 0 {
+			groups = append(groups, latencyGroup{label, latencies})
+		}
+	}
+
+	add("All", func(s sample) bool { return s.server != "" })
+	for _, id := range l.servers() {
+		add(id, func(s sample) bool { return s.server == id })
+	}
+	for _, outcome := range []string{"L1 hit", "L2 hit", "Miss", "Archive", "Unknown", "Failed"} {
+		add(outcome, func(s sample) bool { return s.outcome == outcome })
+	}
+	return groups
+}
+
+// everything keeps every sample
+func everything(sample) bool { return true }
+
+// cacheOutcome names where a message's session was found
+func cacheOutcome(location pb.CacheLocation) string {
+	switch location {
+	case pb.CacheLocation_CACHE_L1:
+		return "L1 hit"
+	case pb.CacheLocation_CACHE_L2:
+		return "L2 hit"
+	case pb.CacheLocation_CACHE_MISS:
+		return "Miss"
+	case pb.CacheLocation_CACHE_ARCHIVE:
+		return "Archive"
+	default:
+		return "Unknown"
+	}
+}
+
+// latencySummary is the distribution of a set of round trips
+type latencySummary struct {
+	Count         int
+	P50, P95, P99 time.Duration
+}
+
+// summarize returns the percentiles of latencies, nearest rank
+func summarize(latencies []time.Duration) latencySummary {
+	if len(latencies) == 0 {
+		return latencySummary{}
+	}
+	sorted := append([]time.Duration(nil), latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	at := func(q float64) time.Duration {
+		return sorted[int(q*float64(len(sorted)-1))]
+	}
+	return latencySummary{Count: len(sorted), P50: at(0.50), P95: at(0.95), P99: at(0.99)}
+}
+
+// printLatencies prints the percentiles of the round trips in l
+func printLatencies(l *latencyLog) {
+	w := out.at(quiet)
+	fmt.Fprintln(w, "\n⏱️  Round-Trip Latency:")
+	fmt.Fprintf(w, "   %-16s %6s %9s %9s %9s\n", "", "COUNT", "P50", "P95", "P99")
+	for _, group := range l.groups(everything) {
+		s := summarize(group.latencies)
+		fmt.Fprintf(w, "   %-16s %6d %9s %9s %9s\n", group.label, s.Count, formatLatency(s.P50),
+			formatLatency(s.P95), formatLatency(s.P99))
+	}
+}
+
+// formatLatency prints d in milliseconds
+func formatLatency(d time.Duration) string {
+	return fmt.Sprintf("%.2fms", float64(d)/float64(time.Millisecond))
+}
+
+// phase is a stretch of the run between cluster events
+type phase struct {
+	name      string // "start", or the event that began it
+	after     int    // Messages handed out before it began
+	failovers int64  // Client failovers during it
+}
+
+// printPhase prints a line summing up p, the run's phase at index, which
+// ended once until messages had been handed out
+func printPhase(p phase, index, until int, l *latencyLog) {
+	in := func(outcome string) int {
+		return len(l.where(func(s sample) bool { return s.phase == index && s.outcome == outcome }))
+	}
+	answered := l.where(func(s sample) bool { return s.phase == index && s.server != "" })
+	failed := in("Failed")
+
+	span := "no messages"
+	if until > p.after {
+		span = fmt.Sprintf("messages %d-%d", p.after+1, until)
+	}
+	fmt.Fprintf(out.at(quiet), "📋 Phase %d, %s (%s): %d sent, %d failed, %d failovers, %d L1 hits, %d L2 hits, %d misses, p50 %s\n",
+		index+1, p.name, span, len(answered)+failed, failed, p.failovers, in("L1 hit"), in("L2 hit"), in("Miss"),
+		formatLatency(summarize(answered).P50))
+}
+
+// liveStats is the status block shown under the output while messages are
+// sent, so an event's effect shows as it happens: the messages per second,
+// each server's share of them and the hit rate over the last second, and
+// the failovers so far
+type liveStats struct {
+	smartClient *client.SmartClient
+	latencies   *latencyLog
+	failovers   int64 // Before the messages, from the warm-up
+
+	mu    sync.Mutex
+	seen  int // Samples counted by the last tick
+	last  time.Time
+	block []string
+}
+
+// liveServersShown caps the servers listed in the status block, so it fits
+// on the terminal
+const liveServersShown = 10
+
+// showLiveStats shows live statistics under the output, every second, on a
+// terminal unless -quiet or -no-live. The returned function removes them.
+func showLiveStats(smartClient *client.SmartClient, latencies *latencyLog, failovers int64) func() {
+	if out.level == quiet || !out.live || !out.terminal() {
+		return func() {}
+	}
+	live := &liveStats{smartClient: smartClient, latencies: latencies, failovers: failovers, last: time.Now()}
+	live.tick(time.Now())
+	out.show(live.lines)
+
+	ticker := time.NewTicker(time.Second)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case now := <-ticker.C:
+				live.tick(now)
+				out.redraw()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			ticker.Stop()
+			close(done)
+			out.hide()
+		})
+	}
+}
+
+// tick works out the statistics of the messages answered since the last
+func (s *liveStats) tick(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	samples := s.latencies.since(s.seen)
+	s.seen += len(samples)
+	elapsed := now.Sub(s.last)
+	s.last = now
+
+	served := make(map[string]int)
+	answered, hits := 0, 0
+	for _, sample := range samples {
+		if sample.server == "" {
+			continue
+		}
+		answered++
+		served[sample.server]++
+		if sample.outcome == "L1 hit" || sample.outcome == "L2 hit" {
+			hits++
+		}
+	}
+	rate, hitRate := 0.0, "-"
+	if elapsed > 0 {
+		rate = float64(len(samples)) / elapsed.Seconds()
+	}
+	if answered > 0 {
+		hitRate = fmt.Sprintf("%.1f%%", 100*float64(hits)/float64(answered))
+	}
+
+	failovers := s.smartClient.GetStats().FailoverCount - s.failovers
+	s.block = []string{fmt.Sprintf("📡 Last second: %.1f msgs/s, hit rate %s, %d failed; %d failovers so far",
+		rate, hitRate, len(samples)-answered, failovers)}
+	nodes := s.smartClient.RingState().Nodes // In ID order
+	for i, n := range nodes {
+		if i == liveServersShown {
+			s.block = append(s.block, fmt.Sprintf("   ... and %d more", len(nodes)-i))
+			break
+		}
+		share := 0.0
+		if answered > 0 {
+			share = float64(served[n.NodeID]) / float64(answered)
+		}
+		bar := int(math.Round(20 * share))
+		s.block = append(s.block, fmt.Sprintf("   %-12s %s%s %5.1f%%", n.NodeID,
+			strings.Repeat("█", bar), strings.Repeat("░", 20-bar), 100*share))
+	}
+}
+
+// lines returns the status block as of the last tick
+func (s *liveStats) lines() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.block
+}
+
+// runReport is the machine-readable record of a run (-report), for
+// archiving runs and comparing them programmatically
+type runReport struct {
+	Started  time.Time           `json:"started"`
+	Settings experiment.Settings `json:"settings"`
+	Client   clientReport        `json:"client"`
+	Phases   []phaseReport       `json:"phases"`
+	Servers  []serverReport      `json:"servers"`
+	Latency  []latencyReport     `json:"latency"` // Overall, by server and by cache outcome
+}
+
+// clientReport is the client's view of the run
+type clientReport struct {
+	Requests    int64   `json:"requests"`
+	Successful  int64   `json:"successful"`
+	Failed      int64   `json:"failed"`
+	PrimaryHits int64   `json:"primary_hits"`
+	Failovers   int64   `json:"failovers"`
+	DurationMs  float64 `json:"duration_ms"` // Sending the workload, events included
+	Throughput  float64 `json:"throughput"`  // Messages per second
+}
+
+// phaseReport is what happened between two cluster events
+type phaseReport struct {
+	Name      string        `json:"name"`
+	After     int           `json:"after"` // Messages sent before it began
+	Messages  int           `json:"messages"`
+	Failed    int           `json:"failed"`
+	Failovers int64         `json:"failovers"`
+	L1Hits    int           `json:"l1_hits"`
+	L2Hits    int           `json:"l2_hits"`
+	Misses    int           `json:"misses"`
+	Latency   latencyReport `json:"latency"` // Of its successful messages
+}
+
+// serverReport is a server's cache at the end of the run
+type serverReport struct {
+	ID         string `json:"id"`
+	Online     bool   `json:"online"`
+	L1Size     int    `json:"l1_size"`
+	L1Capacity int    `json:"l1_capacity"`
+	L2Size     int    `json:"l2_size"`
+	L2Capacity int    `json:"l2_capacity"`
+	Hits       int64  `json:"hits"`
+	L1Hits     int64  `json:"l1_hits"`
+	L2Hits     int64  `json:"l2_hits"`
+	Misses     int64  `json:"misses"`
+	Demotions  int64  `json:"demotions"`
+	Evictions  int64  `json:"evictions"`
+}
+
+// latencyReport is the distribution of a group of round trips
+type latencyReport struct {
+	Group string  `json:"group"`
+	Count int     `json:"count"`
+	P50Ms float64 `json:"p50_ms"`
+	P95Ms float64 `json:"p95_ms"`
+	P99Ms float64 `json:"p99_ms"`
+}
+
+// newLatencyReport summarizes latencies as group
+func newLatencyReport(group string, latencies []time.Duration) latencyReport {
+	s := summarize(latencies)
+	ms := func(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+	return latencyReport{Group: group, Count: s.Count, P50Ms: ms(s.P50), P95Ms: ms(s.P95), P99Ms: ms(s.P99)}
+}
+
+// buildReport gathers the run's outcome
+func buildReport(run experiment.Settings, started time.Time, sendTime time.Duration, stats client.ClientStats,
+	phases []phase, latencies *latencyLog, servers map[string]node, warm warmState) runReport {
+	report := runReport{
+		Started:  started,
+		Settings: run,
+		Client: clientReport{
+			Requests:    stats.TotalRequests,
+			Successful:  stats.SuccessRequests,
+			Failed:      stats.FailedRequests,
+			PrimaryHits: stats.PrimaryHits,
+			Failovers:   stats.FailoverCount,
+			DurationMs:  float64(sendTime) / float64(time.Millisecond),
+			Throughput:  float64(run.Workload.Messages) / sendTime.Seconds(),
+		},
+	}
+
+	for i, p := range phases {
+		inPhase := func(s sample) bool { return s.phase == i }
+		count := func(outcome string) int {
+			return len(latencies.where(func(s sample) bool { return inPhase(s) && s.outcome == outcome }))
+		}
+		report.Phases = append(report.Phases, phaseReport{
+			Name:      p.name,
+			After:     p.after,
+			Messages:  len(latencies.where(inPhase)),
+			Failed:    count("Failed"),
+			Failovers: p.failovers,
+			L1Hits:    count("L1 hit"),
+			L2Hits:    count("L2 hit"),
+			Misses:    count("Miss"),
+			Latency: newLatencyReport("All", latencies.where(func(s sample) bool {
+				return inPhase(s) && s.server != ""
+			})),
+		})
+	}
+
+	for _, id := range sortedKeys(servers) {
+		srv := servers[id]
+		entry := serverReport{ID: id, Online: srv.IsHealthy()}
+		if entry.Online {
+			info := warm.cacheInfo(id, srv)
+			entry.L1Size, entry.L1Capacity = info.L1Size, info.L1Capacity
+			entry.L2Size, entry.L2Capacity = info.L2Size, info.L2Capacity
+			entry.Hits, entry.L1Hits, entry.L2Hits = info.Stats.CacheHits, info.Stats.L1Hits, info.Stats.L2Hits
+			entry.Misses, entry.Demotions, entry.Evictions = info.Stats.CacheMisses, info.Stats.Demotions, info.Stats.Evictions
+		}
+		report.Servers = append(report.Servers, entry)
+	}
+
+	for _, group := range latencies.groups(everything) {
+		report.Latency = append(report.Latency, newLatencyReport(group.label, group.latencies))
+	}
+	return report
+}
+
+// writeReport writes the report to path: as CSV if it ends in .csv, else
+// as JSON
+func writeReport(path string, report runReport) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		err = report.writeCSV(f)
+	} else {
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(report)
+	}
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// writeCSV writes the report in long form, one value per row: section
+// (settings, client, phase, server or latency), case (the phase, server
+// or latency group), metric, value. Rows from different runs can be
+// joined on the first three columns.
+func (r runReport) writeCSV(w io.Writer) error {
+	out := csv.NewWriter(w)
+	out.Write([]string{"section", "case", "metric", "value"})
+
+	row := func(section, name, metric string, value any) {
+		out.Write([]string{section, name, metric, fmt.Sprint(value)})
+	}
+	s := r.Settings
+	row("settings", "", "messages", s.Workload.Messages)
+	row("settings", "", "chats", s.Workload.Chats)
+	row("settings", "", "delay_ms", float64(s.Workload.Delay)/float64(time.Millisecond))
+	row("settings", "", "workers", s.Workload.Workers)
+	row("settings", "", "qps", s.Workload.QPS)
+	row("settings", "", "l1", s.L1Capacity)
+	row("settings", "", "l2", s.L2Capacity)
+	for _, srv := range s.Servers {
+		row("settings", srv.ID, "port", srv.Port)
+		row("settings", srv.ID, "capacity", srv.Capacity)
+	}
+	for _, e := range s.Events {
+		row("settings", e.String(), "after", e.After)
+	}
+
+	c := r.Client
+	row("client", "", "requests", c.Requests)
+	row("client", "", "successful", c.Successful)
+	row("client", "", "failed", c.Failed)
+	row("client", "", "primary_hits", c.PrimaryHits)
+	row("client", "", "failovers", c.Failovers)
+	row("client", "", "duration_ms", c.DurationMs)
+	row("client", "", "throughput", c.Throughput)
+
+	for _, p := range r.Phases {
+		name := fmt.Sprintf("%s@%d", p.Name, p.After)
+		row("phase", name, "messages", p.Messages)
+		row("phase", name, "failed", p.Failed)
+		row("phase", name, "failovers", p.Failovers)
+		row("phase", name, "l1_hits", p.L1Hits)
+		row("phase", name, "l2_hits", p.L2Hits)
+		row("phase", name, "misses", p.Misses)
+		row("phase", name, "p50_ms", p.Latency.P50Ms)
+		row("phase", name, "p95_ms", p.Latency.P95Ms)
+		row("phase", name, "p99_ms", p.Latency.P99Ms)
+	}
+
+	for _, srv := range r.Servers {
+		row("server", srv.ID, "online", srv.Online)
+		row("server", srv.ID, "l1_size", srv.L1Size)
+		row("server", srv.ID, "l2_size", srv.L2Size)
+		row("server", srv.ID, "hits", srv.Hits)
+		row("server", srv.ID, "l1_hits", srv.L1Hits)
+		row("server", srv.ID, "l2_hits", srv.L2Hits)
+		row("server", srv.ID, "misses", srv.Misses)
+		row("server", srv.ID, "demotions", srv.Demotions)
+		row("server", srv.ID, "evictions", srv.Evictions)
+	}
+
+	for _, l := range r.Latency {
+		row("latency", l.Group, "count", l.Count)
+		row("latency", l.Group, "p50_ms", l.P50Ms)
+		row("latency", l.Group, "p95_ms", l.P95Ms)
+		row("latency", l.Group, "p99_ms", l.P99Ms)
+	}
+
+	out.Flush()
+	return out.Error()
+}
+
+// runComparison runs the experiment twice, each in a child process so the
+// runs share no ports or state, the second time with run.Compare's flags
+// added, and prints the two side by side. Both runs get the same seed, so
+// they send the same traffic.
+func runComparison(run experiment.Settings) {
+	w := out.at(quiet)
+	runs, seed := experiment.ComparisonArgs(os.Args[1:], run, time.Now().UnixNano()%1000000)
+
+	dir, err := os.MkdirTemp("", "districhat-compare-")
+	if err != nil {
+		fatal("Failed to compare", err)
+	}
+	defer os.RemoveAll(dir)
+
+	fmt.Fprintf(w, "⚖️  Comparing two runs with seed %d\n", seed)
+	var reports [2]runReport
+	for i, runArgs := range runs {
+		name := string(rune('A' + i))
+		fmt.Fprintf(w, "   %s: go run main.go %s\n", name, strings.Join(runArgs, " "))
+		path := filepath.Join(dir, name+".json")
+		reports[i], err = runChild(append(append([]string(nil), runArgs...), "-report", path), path)
+		if err != nil {
+			os.RemoveAll(dir)
+			fatal("Run "+name+" failed", err)
+		}
+	}
+	fmt.Fprintln(w)
+	printComparison(reports[0], reports[1])
+}
+
+// runChild runs the simulation with args in a child process, its output
+// discarded, and reads the report it writes to path
+func runChild(args []string, path string) (runReport, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return runReport{}, err
+	}
+	cmd := exec.Command(exe, args...)
+	var env []string
+	for _, kv := range os.Environ() {
+		name, _, _ := strings.Cut(kv, "=")
+		if name != "COMPARE" && name != "DASHBOARD" && name != "REPORT" {
+			env = append(env, kv)
+		}
+	}
+	cmd.Env = env
+	var logs bytes.Buffer
+	cmd.Stderr = &logs
+	if err := cmd.Run(); err != nil {
+		// The last lines say why
+		lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+		return runReport{}, fmt.Errorf("%w: %s", err, strings.Join(lines[max(len(lines)-3, 0):], "\n"))
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return runReport{}, err
+	}
+	var report runReport
+	return report, json.Unmarshal(data, &report)
+}
+
+// comparedMetric is a row of the comparison table
+type comparedMetric struct {
+	name   string
+	format string // For its values
+	value  func(r runReport) float64
+}
+
+// comparedMetrics are what a comparison shows
+var comparedMetrics = []comparedMetric{
+	{"Hit rate", "%.1f%%", func(r runReport) float64 { return 100 * r.hitRate(func(s serverReport) int64 { return s.Hits }) }},
+	{"L1 hit rate", "%.1f%%", func(r runReport) float64 { return 100 * r.hitRate(func(s serverReport) int64 { return s.L1Hits }) }},
+	{"Evictions", "%.0f", func(r runReport) float64 {
+		total := 0.0
+		for _, s := range r.Servers {
+			total += float64(s.Evictions)
+		}
+		return total
+	}},
+	{"Imbalance", "%.2fx", runReport.imbalance},
+	{"Failed", "%.0f", func(r runReport) float64 { return float64(r.Client.Failed) }},
+	{"Failovers", "%.0f", func(r runReport) float64 { return float64(r.Client.Failovers) }},
+	{"Latency p50", "%.2fms", func(r runReport) float64 { return r.latency("All").P50Ms }},
+	{"Latency p95", "%.2fms", func(r runReport) float64 { return r.latency("All").P95Ms }},
+	{"Latency p99", "%.2fms", func(r runReport) float64 { return r.latency("All").P99Ms }},
+	{"Throughput", "%.1f/s", func(r runReport) float64 { return r.Client.Throughput }},
+}
+
+// printComparison prints the runs' metrics side by side, with the change
+// from a to b
+func printComparison(a, b runReport) {
+	w := out.at(quiet)
+	fmt.Fprintln(w, "📊 Comparison:")
+	fmt.Fprintf(w, "   %-14s %12s %12s %20s\n", "", "A", "B", "CHANGE")
+	for _, m := range comparedMetrics {
+		va, vb := m.value(a), m.value(b)
+		change := fmt.Sprintf(m.format, vb-va)
+		if vb >= va {
+			change = "+" + change
+		}
+		if va != 0 {
+			change += fmt.Sprintf(" (%+.0f%%)", 100*(vb-va)/va)
+		}
+		fmt.Fprintf(w, "   %-14s %12s %12s %20s\n", m.name, fmt.Sprintf(m.format, va), fmt.Sprintf(m.format, vb), change)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "   Cache statistics are of the servers still up at the end; imbalance is")
+	fmt.Fprintln(w, "   the busiest server's messages over an even share.")
+}
+
+// hitRate returns the share of the servers' lookups counted by hits
+func (r runReport) hitRate(hits func(serverReport) int64) float64 {
+	var hit, lookups int64
+	for _, s := range r.Servers {
+		hit += hits(s)
+		lookups += s.Hits + s.Misses
+	}
+	if lookups == 0 {
+		return 0
+	}
+	return float64(hit) / float64(lookups)
+}
+
+// imbalance returns the busiest server's messages over an even share of
+// them across the run's servers
+func (r runReport) imbalance() float64 {
+	answered := make(map[string]int)
+	for _, l := range r.Latency {
+		answered[l.Group] = l.Count
+	}
+	total, busiest := 0, 0
+	for _, s := range r.Servers {
+		total += answered[s.ID]
+		busiest = max(busiest, answered[s.ID])
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(busiest) / (float64(total) / float64(len(r.Servers)))
+}
+
+// latency returns the run's latency group called group
+func (r runReport) latency(group string) latencyReport {
+	for _, l := range r.Latency {
+		if l.Group == group {
+			return l
+		}
+	}
+	return latencyReport{Group: group}
+}
+
+// warmState is the statistics the warm-up left behind, taken off the
+// final ones so they count only the measured messages
+type warmState struct {
+	client client.ClientStats
+	caches map[string]cache.CacheStats // By server, until it restarts
+	total  cache.CacheStats            // Hits and misses across the servers
+}
+
+// newWarmState is the state without a warm-up
+func newWarmState() warmState {
+	return warmState{caches: make(map[string]cache.CacheStats)}
+}
+
+// takeWarmState reads the statistics once the warm-up is done
+func takeWarmState(smartClient *client.SmartClient, servers map[string]node) warmState {
+	w := newWarmState()
+	w.client = smartClient.GetStats()
+	for id, srv := range servers {
+		stats := srv.GetCacheInfo().Stats
+		w.caches[id] = stats
+		w.total.CacheHits += stats.CacheHits
+		w.total.CacheMisses += stats.CacheMisses
+	}
+	return w
+}
+
+// clientStats returns the client's statistics since the warm-up
+func (w warmState) clientStats(now client.ClientStats) client.ClientStats {
+	return client.ClientStats{
+		TotalRequests:   now.TotalRequests - w.client.TotalRequests,
+		SuccessRequests: now.SuccessRequests - w.client.SuccessRequests,
+		FailedRequests:  now.FailedRequests - w.client.FailedRequests,
+		FailoverCount:   now.FailoverCount - w.client.FailoverCount,
+		PrimaryHits:     now.PrimaryHits - w.client.PrimaryHits,
+	}
+}
+
+// cacheInfo returns the server's cache, its statistics since the warm-up
+func (w warmState) cacheInfo(id string, srv node) cache.CacheInfo {
+	info := srv.GetCacheInfo()
+	before := w.caches[id]
+	s := &info.Stats
+	s.TotalRequests -= before.TotalRequests
+	s.CacheHits -= before.CacheHits
+	s.CacheMisses -= before.CacheMisses
+	s.L1Hits -= before.L1Hits
+	s.L2Hits -= before.L2Hits
+	s.SharedHits -= before.SharedHits
+	s.Archived -= before.Archived
+	s.ArchiveHits -= before.ArchiveHits
+	s.Evictions -= before.Evictions
+	s.Demotions -= before.Demotions
+	s.Duplicates -= before.Duplicates
+	return info
+}
+
+// rewarm follows a restarted server's caches filling up again
+type rewarm struct {
+	after int // Messages sent when the server restarted
+
+	mu       sync.Mutex // Workers record concurrently
+	misses   int
+	hits     int
+	firstHit int // Message that first hit its caches (0: none yet)
+}
+
+// record counts message number i answered by the server from location
+func (w *rewarm) record(i int, location pb.CacheLocation) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	switch location {
+	case pb.CacheLocation_CACHE_L1, pb.CacheLocation_CACHE_L2:
+		w.hits++
+		if w.firstHit == 0 || i < w.firstHit {
+			w.firstHit = i
+		}
+	default:
+		w.misses++
+	}
+}
+
 // stopServers gracefully stops all servers
-func stopServers(servers map[string]*server.ChatServer) {
-	fmt.Println("\n🛑 Stopping all servers...")
-	for name, srv := range servers {
+func stopServers(servers map[string]node) {
+	w := out.at(normal)
+	fmt.Fprintln(w, "\n🛑 Stopping all servers...")
+	for _, name := range sortedKeys(servers) {
+		srv := servers[name]
 		if srv.IsHealthy() {
 			srv.Stop()
-			fmt.Printf("   ✓ Server %s stopped\n", name)
+			fmt.Fprintf(w, "   ✓ %s stopped\n", name)
 		}
 	}
 }
 
 // initializeClient creates and configures the smart client
-func initializeClient(servers map[string]*server.ChatServer) *client.SmartClient {
+func initializeClient(servers map[string]node, env environment, run experiment.Settings) *client.SmartClient {
+	w := out.at(normal)
 	config := client.DefaultClientConfig()
 	config.VirtualNodes = 100
+	config.Clock = env.clock
+	config.Rand = env.client
+	config.Chaos = env.injector
 
 	smartClient := client.NewSmartClient(config)
 
 	// Add all servers to the client's hash ring
-	smartClient.AddServer("Server-A", fmt.Sprintf("localhost:%d", serverAPort), serverACapacity)
-	smartClient.AddServer("Server-B", fmt.Sprintf("localhost:%d", serverBPort), serverBCapacity)
-	smartClient.AddServer("Server-C", fmt.Sprintf("localhost:%d", serverCPort), serverCCapacity)
-
-	fmt.Printf("   ✓ Added Server-A (capacity: %d)\n", serverACapacity)
-	fmt.Printf("   ✓ Added Server-B (capacity: %d) - WILL BE KILLED\n", serverBCapacity)
-	fmt.Printf("   ✓ Added Server-C (capacity: %d)\n", serverCCapacity)
+	for _, spec := range run.Servers {
+		smartClient.AddServer(spec.ID, fmt.Sprintf("localhost:%d", spec.Port), spec.Capacity)
+		if run.Killed(spec.ID) {
+			fmt.Fprintf(w, "   ✓ Added %s (capacity: %d) - WILL BE KILLED\n", spec.ID, spec.Capacity)
+		} else {
+			fmt.Fprintf(w, "   ✓ Added %s (capacity: %d)\n", spec.ID, spec.Capacity)
+		}
+	}
 
 	return smartClient
 }
 
+// startDashboard takes over the terminal with a dashboard of the servers,
+// discarding the demo output from now on. Quitting the dashboard stops the
+// simulation like a signal would. The returned channel is closed once the
+// dashboard has exited.
+func startDashboard(smartClient *client.SmartClient, stop chan<- os.Signal, run experiment.Settings) <-chan struct{} {
+	terminal := os.Stdout
+	if devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0); err == nil {
+		os.Stdout = devNull
+	}
+
+	var targets []dashboard.Target
+	for _, spec := range run.Servers {
+		targets = append(targets, dashboard.Target{
+			Name:         spec.ID,
+			AdminAddress: fmt.Sprintf("localhost:%d", spec.Port+experiment.AdminPortOffset),
+		})
+	}
+
+	dash := dashboard.NewDashboard(dashboard.DashboardConfig{
+		Servers: targets,
+		// The servers run without a coordinator, so only the client has
+		// the ring
+		RingState: smartClient.RingState,
+		Output:    terminal,
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := dash.Run(context.Background()); err != nil {
+			fmt.Fprintf(os.Stderr, "Dashboard failed: %v\n", err)
+		}
+		select {
+		case stop <- os.Interrupt:
+		default:
+		}
+	}()
+	return done
+}
+
 // generateMessage creates a sample message for testing
 func generateMessage(index int) string {
 	messages := []string{
@@ -298,11 +2010,72 @@ func getCacheIndicator(cacheStatus string) string {
 		return "💨 L2-HIT"
 	case strings.Contains(cacheStatus, "MISS"):
 		return "❄️  MISS"
+	case strings.Contains(cacheStatus, "ARCHIVE"):
+		return "🧊 ARCHIVE"
 	default:
 		return "❓ UNKNOWN"
 	}
 }
 
+// runBenchmarks runs the standard benchmark suite and writes its report to
+// stdout in format, for comparing against earlier releases
+func runBenchmarks(format string) {
+	logging.Setup(logging.Config{Output: io.Discard})
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	report, err := bench.Run(ctx, bench.DefaultConfig())
+	if err != nil {
+		fatal("Benchmark failed", err)
+	}
+	if err := report.Write(os.Stdout, format); err != nil {
+		fatal("Failed to write benchmark report", err)
+	}
+}
+
+// runScenario plays the scenario in the YAML file at path, exiting with
+// status 1 if it fails to run or an assertion fails
+func runScenario(path string) {
+	logging.Setup(logging.Config{Output: os.Stderr, Format: os.Getenv("LOG_FORMAT")})
+
+	s, err := scenario.Load(path)
+	if err != nil {
+		fatal("Invalid scenario", err)
+	}
+	w := out.at(normal)
+	fmt.Fprintf(w, "🎬 %s\n", s.Name)
+	if s.Description != "" {
+		fmt.Fprintln(w, s.Description)
+	}
+	fmt.Fprintln(w, strings.Repeat("-", 40))
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	report, err := scenario.Run(ctx, s, w)
+	if err != nil {
+		fatal("Scenario failed", err)
+	}
+
+	w = out.at(quiet)
+	m := report.Metrics
+	fmt.Fprintln(w, strings.Repeat("-", 40))
+	fmt.Fprintf(w, "Sent %d, delivered %d, %d errors, %d failovers, hit rate %.1f%% (%v)\n",
+		m.Sent, m.Delivered, m.Errors, m.Failovers, m.HitRate*100, report.Elapsed)
+	if !report.Passed() {
+		fmt.Fprintln(w, "❌ Assertions failed")
+		os.Exit(1)
+	}
+	fmt.Fprintln(w, "✅ All assertions passed")
+}
+
+// fatal logs err and exits
+func fatal(msg string, err error) {
+	slog.Error(msg, logging.Err(err))
+	os.Exit(1)
+}
+
 const banner = `
 ╔═══════════════════════════════════════════════════════════════╗
 ║                                                               ║
@@ -0,0 +1,487 @@
+// Package archive is the cold tier below the cache. Chats evicted from L2
+// or idle past a threshold are written to object storage (S3, GCS or any
+// S3-compatible service) and read back the next time they're accessed.
+//
+// Each chat is stored as fixed-size segments of messages, one object per
+// segment, plus a manifest describing them:
+//
+//	<prefix><chat>/manifest.json
+//	<prefix><chat>/000000-<generation>.json
+//	<prefix><chat>/000001-<generation>.json
+//
+// Re-archiving a chat only uploads the segments that changed, each under a
+// new generation, so a long history that gains a few messages writes its
+// last segment and the manifest rather than the whole chat. Segments the
+// new manifest no longer names are deleted once it's written. Replicas
+// archive the same chats, so a copy is merged with the archived one, or
+// skipped if the archived one is ahead, and the manifest is only replaced
+// if nobody else replaced it since it was read.
+//
+// With Config.Encrypter set, every object is encrypted before it leaves
+// the process, so the bucket never holds chat history in plaintext.
+package archive
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/pkg/cache"
+	"github.com/sh4shv4t/DistriChat/pkg/clock"
+	"github.com/sh4shv4t/DistriChat/pkg/encryption"
+)
+
+const manifestName = "manifest.json"
+
+// Config contains configuration for an archive
+type Config struct {
+	// Prefix of every object key (default: "chats/")
+	Prefix string
+
+	// Messages per segment object (default: 500)
+	SegmentSize int
+
+	// Deadline for archiving or restoring one chat (default: 30s), within
+	// the caller's
+	Timeout time.Duration
+
+	// Encrypter, if set, encrypts every object written, segments and
+	// manifests alike, and decrypts them on reads. Objects written without
+	// it can't be read with it, and the other way around.
+	Encrypter *encryption.Encrypter
+}
+
+// Archive stores chat sessions in an object store. It implements
+// cache.ColdTier.
+type Archive struct {
+	mu     sync.Mutex
+	store  ObjectStore
+	config Config
+
+	// Manifests of the archived chats known to this archive
+	manifests map[string]*manifest
+}
+
+// manifest describes one archived chat
+type manifest struct {
+	ChatID     string
+	CreatedAt  time.Time
+	ArchivedAt time.Time
+	LastSeq    uint64
+	Floor      uint64 `json:",omitempty"`
+	Version    clock.VersionVector
+	Segments   []segment
+}
+
+// segment identifies the contents of one segment object
+type segment struct {
+	Key    string `json:",omitempty"` // Object name under the chat's prefix
+	Count  int
+	Digest uint64
+}
+
+// Open creates an archive over store and loads the manifests already in it
+func Open(ctx context.Context, store ObjectStore, config Config) (*Archive, error) {
+	if config.Prefix == "" {
+		config.Prefix = "chats/"
+	}
+	if config.SegmentSize <= 0 {
+		config.SegmentSize = 500
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 30 * time.Second
+	}
+	if config.Encrypter != nil {
+		store = &encryptedStore{store: store, encrypter: config.Encrypter}
+	}
+
+	a := &Archive{
+		store:     store,
+		config:    config,
+		manifests: make(map[string]*manifest),
+	}
+	if err := a.Refresh(ctx); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Refresh reloads the manifests in the store, picking up chats archived by
+// other servers since Open
+func (a *Archive) Refresh(ctx context.Context) error {
+	keys, err := a.store.List(ctx, a.config.Prefix)
+	if err != nil {
+		return err
+	}
+
+	loaded := make(map[string]*manifest)
+	for _, key := range keys {
+		if !strings.HasSuffix(key, "/"+manifestName) {
+			continue
+		}
+		m, err := a.readManifest(ctx, key)
+		if err != nil {
+			return err
+		}
+		loaded[m.ChatID] = m
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for chatID, m := range loaded {
+		if current, ok := a.manifests[chatID]; !ok || m.ArchivedAt.After(current.ArchivedAt) {
+			a.manifests[chatID] = m
+		}
+	}
+	return nil
+}
+
+// archiveAttempts bounds how many times Archive retries a manifest write
+// that lost a race with another server's
+const archiveAttempts = 5
+
+// Archive writes a session, uploading the segments that differ from its
+// archived copy and then its manifest.
+//
+// The archived copy is read from the store first, since other replicas
+// archive the chat too: if it already covers every write the session has
+// seen, the session is a lagging copy and nothing is written; if each has
+// writes the other lacks, they are merged. The manifest is written only if
+// it's still the one read, so two servers archiving at once can't mix
+// their segments: the loser retries on top of the winner's copy.
+func (a *Archive) Archive(ctx context.Context, session *cache.ChatSession) error {
+	ctx, cancel := context.WithTimeout(ctx, a.config.Timeout)
+	defer cancel()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	key := a.chatPrefix(session.ChatID) + manifestName
+	for attempt := 1; ; attempt++ {
+		stored, version, err := a.readManifestVersion(ctx, key)
+		if err != nil {
+			return err
+		}
+
+		local := session
+		if stored != nil {
+			switch session.Version.Compare(stored.Version) {
+			case clock.Before:
+				a.manifests[session.ChatID] = stored // The archived copy is ahead
+				return nil
+			case clock.Concurrent:
+				archived, err := a.readSegments(ctx, stored)
+				if err != nil {
+					return err
+				}
+				local = mergeSessions(session, stored, archived)
+			}
+		}
+
+		m, written, err := a.writeSegments(ctx, local, stored)
+		if err != nil {
+			return err
+		}
+		data, err := json.Marshal(m)
+		if err != nil {
+			return err
+		}
+		err = a.store.PutIf(ctx, key, data, version)
+		if errors.Is(err, ErrConflict) {
+			// Another server archived the chat since it was read: the
+			// segments written for this attempt are named by no manifest
+			if err := a.deleteSegments(ctx, written); err != nil {
+				return err
+			}
+			if attempt == archiveAttempts {
+				return fmt.Errorf("failed to archive %s: %w %d times in a row", session.ChatID, err, attempt)
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		a.manifests[session.ChatID] = m
+
+		// Segments of the replaced copy are no longer named by the manifest
+		if stored != nil {
+			return a.deleteSegments(ctx, a.orphaned(stored, m))
+		}
+		return nil
+	}
+}
+
+// writeSegments uploads the segments of session that stored (the archived
+// manifest, if any) doesn't hold, returning the new manifest and the keys
+// it uploaded. Each upload goes to a fresh key, so it never overwrites a
+// segment another manifest names.
+func (a *Archive) writeSegments(ctx context.Context, session *cache.ChatSession, stored *manifest) (*manifest, []string, error) {
+	m := &manifest{
+		ChatID:     session.ChatID,
+		CreatedAt:  session.CreatedAt,
+		ArchivedAt: time.Now(),
+		LastSeq:    session.LastSeq,
+		Floor:      session.Floor,
+		Version:    session.Version.Copy(),
+	}
+	generation, err := newGeneration()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var written []string
+	size := a.config.SegmentSize
+	for i, start := 0, 0; start < len(session.Messages); i, start = i+1, start+size {
+		end := start + size
+		if end > len(session.Messages) {
+			end = len(session.Messages)
+		}
+		messages := session.Messages[start:end]
+
+		seg := segment{Count: len(messages), Digest: digest(messages)}
+		if stored != nil && i < len(stored.Segments) && stored.Segments[i].Count == seg.Count &&
+			stored.Segments[i].Digest == seg.Digest {
+			m.Segments = append(m.Segments, stored.Segments[i]) // Unchanged since the last archive
+			continue
+		}
+
+		data, err := json.Marshal(messages)
+		if err != nil {
+			return nil, written, err
+		}
+		seg.Key = fmt.Sprintf("%06d-%s.json", i, generation)
+		if err := a.store.Put(ctx, a.chatPrefix(session.ChatID)+seg.Key, data); err != nil {
+			return nil, written, err
+		}
+		written = append(written, a.chatPrefix(session.ChatID)+seg.Key)
+		m.Segments = append(m.Segments, seg)
+	}
+	return m, written, nil
+}
+
+// deleteSegments removes segment objects by key
+func (a *Archive) deleteSegments(ctx context.Context, keys []string) error {
+	for _, key := range keys {
+		if err := a.store.Delete(ctx, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// orphaned returns the keys of previous's segments that m doesn't name
+func (a *Archive) orphaned(previous, m *manifest) []string {
+	named := make(map[string]bool, len(m.Segments))
+	for i, seg := range m.Segments {
+		named[a.segmentKey(m.ChatID, i, seg)] = true
+	}
+	var keys []string
+	for i, seg := range previous.Segments {
+		if key := a.segmentKey(previous.ChatID, i, seg); !named[key] {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// mergeSessions unions a session with a concurrent archived copy of it,
+// the way replicas merge: by message ID, keeping the copy MessageLess
+// orders first, in MessageLess order
+func mergeSessions(session *cache.ChatSession, stored *manifest, archived []cache.Message) *cache.ChatSession {
+	merged := *session
+	merged.Messages = append([]cache.Message(nil), session.Messages...)
+	seen := make(map[string]int, len(merged.Messages))
+	for i, msg := range merged.Messages {
+		seen[msg.ID] = i
+	}
+	for _, msg := range archived {
+		if i, ok := seen[msg.ID]; ok && msg.ID != "" {
+			if cache.MessageLess(msg, merged.Messages[i]) {
+				merged.Messages[i] = msg
+			}
+			continue
+		}
+		merged.Messages = append(merged.Messages, msg)
+	}
+	sort.SliceStable(merged.Messages, func(i, j int) bool {
+		return cache.MessageLess(merged.Messages[i], merged.Messages[j])
+	})
+
+	merged.MessageCount = len(merged.Messages)
+	merged.Version = session.Version.Copy()
+	merged.Version.Merge(stored.Version)
+	if stored.LastSeq > merged.LastSeq {
+		merged.LastSeq = stored.LastSeq
+	}
+	if stored.Floor > merged.Floor {
+		merged.Floor = stored.Floor
+	}
+	if !stored.CreatedAt.IsZero() && stored.CreatedAt.Before(merged.CreatedAt) {
+		merged.CreatedAt = stored.CreatedAt
+	}
+	return &merged
+}
+
+// newGeneration returns a random name for the segments one archive writes
+func newGeneration() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to name segments: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Restore reads an archived session. Chats this archive has never seen
+// (archived elsewhere since the last Refresh) are reported as not archived
+// without touching the store.
+func (a *Archive) Restore(ctx context.Context, chatID string) (*cache.ChatSession, bool, error) {
+	a.mu.Lock()
+	_, known := a.manifests[chatID]
+	a.mu.Unlock()
+	if !known {
+		return nil, false, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, a.config.Timeout)
+	defer cancel()
+
+	// Re-read the manifest in case another server archived a newer copy
+	m, err := a.readManifest(ctx, a.chatPrefix(chatID)+manifestName)
+	if errors.Is(err, ErrNotFound) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	messages, err := a.readSegments(ctx, m)
+	if err != nil {
+		return nil, false, err
+	}
+
+	version := m.Version
+	if version == nil {
+		version = make(clock.VersionVector)
+	}
+
+	a.mu.Lock()
+	a.manifests[chatID] = m
+	a.mu.Unlock()
+
+	return &cache.ChatSession{
+		ChatID:       chatID,
+		Messages:     messages,
+		LastAccessed: m.ArchivedAt,
+		CreatedAt:    m.CreatedAt,
+		MessageCount: len(messages),
+		LastSeq:      m.LastSeq,
+		Version:      version,
+		Floor:        m.Floor,
+	}, true, nil
+}
+
+// Archived returns the IDs of the archived chats known to this archive, sorted
+func (a *Archive) Archived() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	chatIDs := make([]string, 0, len(a.manifests))
+	for chatID := range a.manifests {
+		chatIDs = append(chatIDs, chatID)
+	}
+	sort.Strings(chatIDs)
+	return chatIDs
+}
+
+// readSegments reads the messages of an archived chat's segments
+func (a *Archive) readSegments(ctx context.Context, m *manifest) ([]cache.Message, error) {
+	messages := make([]cache.Message, 0)
+	for i, seg := range m.Segments {
+		data, err := a.store.Get(ctx, a.segmentKey(m.ChatID, i, seg))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read segment %d of %s: %w", i, m.ChatID, err)
+		}
+		var part []cache.Message
+		if err := json.Unmarshal(data, &part); err != nil {
+			return nil, fmt.Errorf("corrupt segment %d of %s: %w", i, m.ChatID, err)
+		}
+		if len(part) != seg.Count {
+			return nil, fmt.Errorf("segment %d of %s has %d messages, expected %d",
+				i, m.ChatID, len(part), seg.Count)
+		}
+		messages = append(messages, part...)
+	}
+	return messages, nil
+}
+
+// readManifestVersion reads a chat's manifest with its version, or returns
+// nil and no version if the chat isn't archived
+func (a *Archive) readManifestVersion(ctx context.Context, key string) (*manifest, string, error) {
+	data, version, err := a.store.GetVersion(ctx, key)
+	if errors.Is(err, ErrNotFound) {
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	m := &manifest{}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, "", fmt.Errorf("corrupt manifest %s: %w", key, err)
+	}
+	return m, version, nil
+}
+
+// readManifest reads and parses one manifest object
+func (a *Archive) readManifest(ctx context.Context, key string) (*manifest, error) {
+	data, err := a.store.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	m := &manifest{}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("corrupt manifest %s: %w", key, err)
+	}
+	return m, nil
+}
+
+// chatPrefix returns the key prefix of a chat's objects. Chat IDs are
+// escaped so one containing "/" can't reach into another chat's objects.
+func (a *Archive) chatPrefix(chatID string) string {
+	return a.config.Prefix + url.PathEscape(chatID) + "/"
+}
+
+// segmentKey returns the key of a chat's i-th segment. Segments archived
+// before they were named in the manifest are found by position.
+func (a *Archive) segmentKey(chatID string, i int, seg segment) string {
+	if seg.Key != "" {
+		return a.chatPrefix(chatID) + seg.Key
+	}
+	return fmt.Sprintf("%s%06d.json", a.chatPrefix(chatID), i)
+}
+
+// digest fingerprints a segment's messages by identity and position
+func digest(messages []cache.Message) uint64 {
+	h := fnv.New64a()
+	buf := make([]byte, 8)
+	for _, msg := range messages {
+		h.Write([]byte(msg.ID))
+		binary.BigEndian.PutUint64(buf, msg.Seq)
+		h.Write(buf)
+		binary.BigEndian.PutUint64(buf, uint64(msg.HLC.WallTime))
+		h.Write(buf)
+		binary.BigEndian.PutUint64(buf, uint64(msg.HLC.Logical))
+		h.Write(buf)
+		h.Write([]byte(msg.Content))
+	}
+	return h.Sum64()
+}
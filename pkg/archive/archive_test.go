@@ -0,0 +1,274 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/pkg/cache"
+	"github.com/sh4shv4t/DistriChat/pkg/clock"
+	"github.com/sh4shv4t/DistriChat/pkg/encryption"
+)
+
+func newSession(chatID string, messages int) *cache.ChatSession {
+	session := &cache.ChatSession{
+		ChatID:    chatID,
+		CreatedAt: time.Now(),
+		Version:   clock.VersionVector{"server-a": uint64(messages)},
+	}
+	for i := 1; i <= messages; i++ {
+		session.Messages = append(session.Messages, cache.Message{
+			ID:      fmt.Sprintf("%s-m%d", chatID, i),
+			Seq:     uint64(i),
+			Content: fmt.Sprintf("message %d", i),
+		})
+	}
+	session.MessageCount = messages
+	session.LastSeq = uint64(messages)
+	return session
+}
+
+func TestArchiveRestore(t *testing.T) {
+	a, err := Open(context.Background(), NewMemoryStore(), Config{SegmentSize: 4})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	if err := a.Archive(context.Background(), newSession("chat/1", 10)); err != nil {
+		t.Fatalf("Archive failed: %v", err)
+	}
+
+	session, ok, err := a.Restore(context.Background(), "chat/1")
+	if err != nil || !ok {
+		t.Fatalf("Expected chat/1 to be restored, got %v, %v", ok, err)
+	}
+	if len(session.Messages) != 10 || session.MessageCount != 10 || session.LastSeq != 10 {
+		t.Errorf("Expected 10 messages up to seq 10, got %d (seq %d)", len(session.Messages), session.LastSeq)
+	}
+	if session.Messages[9].ID != "chat/1-m10" {
+		t.Errorf("Expected messages in order, got %s last", session.Messages[9].ID)
+	}
+	if session.Version["server-a"] != 10 {
+		t.Errorf("Expected the version vector to survive, got %v", session.Version)
+	}
+
+	if _, ok, _ := a.Restore(context.Background(), "chat-2"); ok {
+		t.Error("Expected an unarchived chat not to be restored")
+	}
+}
+
+func TestArchiveRewritesOnlyChangedSegments(t *testing.T) {
+	store := NewMemoryStore()
+	a, _ := Open(context.Background(), store, Config{SegmentSize: 4})
+
+	a.Archive(context.Background(), newSession("chat-1", 10)) // 3 segments + manifest
+	if store.Puts() != 4 {
+		t.Fatalf("Expected 4 writes, got %d", store.Puts())
+	}
+
+	a.Archive(context.Background(), newSession("chat-1", 11)) // Last segment grows
+	if store.Puts() != 6 {
+		t.Errorf("Expected only the last segment and manifest rewritten, got %d writes", store.Puts()-4)
+	}
+
+	session, _, _ := a.Restore(context.Background(), "chat-1")
+	if len(session.Messages) != 11 {
+		t.Errorf("Expected 11 messages, got %d", len(session.Messages))
+	}
+}
+
+func TestArchiveDeletesSurplusSegments(t *testing.T) {
+	store := NewMemoryStore()
+	a, _ := Open(context.Background(), store, Config{SegmentSize: 4})
+	a.Archive(context.Background(), newSession("chat-1", 10)) // 3 segments + manifest
+
+	// The oldest 7 messages purged
+	session := newSession("chat-1", 10)
+	session.Messages = session.Messages[7:]
+	session.MessageCount = 3
+	session.Floor = 7
+	if err := a.Archive(context.Background(), session); err != nil {
+		t.Fatalf("Archive failed: %v", err)
+	}
+
+	keys, _ := store.List(context.Background(), "chats/chat-1/")
+	if len(keys) != 2 {
+		t.Errorf("Expected one segment and the manifest left, got %v", keys)
+	}
+	restored, _, _ := a.Restore(context.Background(), "chat-1")
+	if len(restored.Messages) != 3 || restored.Messages[0].Seq != 8 || restored.Floor != 7 {
+		t.Errorf("Expected messages 8 to 10 above floor 7, got %d messages (floor %d)", len(restored.Messages), restored.Floor)
+	}
+}
+
+func TestEncryptedArchive(t *testing.T) {
+	keyring, err := encryption.NewKeyring("k1", map[string][]byte{"k1": bytes.Repeat([]byte{1}, encryption.DataKeySize)})
+	if err != nil {
+		t.Fatalf("NewKeyring failed: %v", err)
+	}
+	store := NewMemoryStore()
+	config := Config{SegmentSize: 4, Encrypter: encryption.New(keyring)}
+	a, _ := Open(context.Background(), store, config)
+	if err := a.Archive(context.Background(), newSession("chat-1", 10)); err != nil {
+		t.Fatalf("Archive failed: %v", err)
+	}
+
+	keys, _ := store.List(context.Background(), "")
+	for _, key := range keys {
+		data, _ := store.Get(context.Background(), key)
+		if !encryption.IsEncrypted(data) || bytes.Contains(data, []byte("message")) {
+			t.Errorf("Expected %s to be stored encrypted", key)
+		}
+	}
+
+	// Another server with the key finds and restores the chat
+	reopened, err := Open(context.Background(), store, config)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	session, ok, err := reopened.Restore(context.Background(), "chat-1")
+	if err != nil || !ok || len(session.Messages) != 10 {
+		t.Fatalf("Expected the 10 messages restored, got %v, %v", ok, err)
+	}
+
+	// A segment moved under another chat's name doesn't decrypt
+	segments, _ := store.List(context.Background(), "chats/chat-1/0")
+	segment, _ := store.Get(context.Background(), segments[0])
+	store.Put(context.Background(), segments[1], segment)
+	if _, _, err := reopened.Restore(context.Background(), "chat-1"); !errors.Is(err, encryption.ErrDecrypt) {
+		t.Errorf("Expected ErrDecrypt for a moved segment, got %v", err)
+	}
+
+	if _, err := Open(context.Background(), store, Config{}); err == nil {
+		t.Errorf("Expected the encrypted manifests unreadable without the key")
+	}
+}
+
+func TestOpenLoadsExistingArchive(t *testing.T) {
+	store := NewMemoryStore()
+	first, _ := Open(context.Background(), store, Config{})
+	first.Archive(context.Background(), newSession("chat-1", 3))
+
+	second, err := Open(context.Background(), store, Config{})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if archived := second.Archived(); len(archived) != 1 || archived[0] != "chat-1" {
+		t.Errorf("Expected [chat-1] archived, got %v", archived)
+	}
+	if session, ok, _ := second.Restore(context.Background(), "chat-1"); !ok || len(session.Messages) != 3 {
+		t.Error("Expected chat-1 to be restored by another archive over the same store")
+	}
+
+	first.Archive(context.Background(), newSession("chat-2", 1))
+	if _, ok, _ := second.Restore(context.Background(), "chat-2"); ok {
+		t.Error("Expected chat-2 unknown before Refresh")
+	}
+	second.Refresh(context.Background())
+	if _, ok, _ := second.Restore(context.Background(), "chat-2"); !ok {
+		t.Error("Expected chat-2 restored after Refresh")
+	}
+}
+
+func TestArchiveAsColdTier(t *testing.T) {
+	a, _ := Open(context.Background(), NewMemoryStore(), Config{})
+	c := cache.NewHierarchicalCache("test", 5, 20)
+	c.SetColdTier(a)
+
+	c.AddMessage("chat-1", cache.Message{ID: "m1", Content: "hello"})
+	time.Sleep(5 * time.Millisecond)
+	if archived := c.ArchiveIdle(time.Millisecond); len(archived) != 1 {
+		t.Fatalf("Expected chat-1 archived, got %v", archived)
+	}
+
+	session, level := c.GetOrCreate("chat-1")
+	if level != cache.LevelArchive || len(session.Messages) != 1 {
+		t.Errorf("Expected chat-1 restored with its message, got %v with %d", level, len(session.Messages))
+	}
+}
+
+// racingStore runs race before its first conditional write, as another
+// server archiving the same chat at that moment would
+type racingStore struct {
+	ObjectStore
+	race func()
+}
+
+func (s *racingStore) PutIf(ctx context.Context, key string, data []byte, version string) error {
+	if race := s.race; race != nil {
+		s.race = nil
+		race()
+	}
+	return s.ObjectStore.PutIf(ctx, key, data, version)
+}
+
+// withWrites adds messages accepted by origin to a copy of session
+func withWrites(session *cache.ChatSession, origin string, ids ...string) *cache.ChatSession {
+	out := *session
+	out.Messages = append([]cache.Message(nil), session.Messages...)
+	out.Version = session.Version.Copy()
+	for _, id := range ids {
+		out.LastSeq++
+		out.Messages = append(out.Messages, cache.Message{ID: id, Seq: out.LastSeq, Origin: origin,
+			Counter: out.Version.Increment(origin), Content: id})
+	}
+	out.MessageCount = len(out.Messages)
+	return &out
+}
+
+func TestTwoArchives(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	first, _ := Open(ctx, store, Config{SegmentSize: 2})
+	second, _ := Open(ctx, store, Config{SegmentSize: 2})
+
+	base := withWrites(&cache.ChatSession{ChatID: "chat-1", Version: clock.VersionVector{}}, "server-a", "a1", "a2", "a3")
+	if err := first.Archive(ctx, withWrites(base, "server-a", "a4", "a5")); err != nil {
+		t.Fatalf("Archive failed: %v", err)
+	}
+
+	// A lagging replica doesn't replace the longer history
+	if err := second.Archive(ctx, base); err != nil {
+		t.Fatalf("Archive failed: %v", err)
+	}
+	if session, _, _ := second.Restore(ctx, "chat-1"); len(session.Messages) != 5 {
+		t.Errorf("Expected the 5 archived messages kept, got %d", len(session.Messages))
+	}
+
+	// A copy with writes the archive lacks, and lacking some of its own, is merged
+	if err := second.Archive(ctx, withWrites(base, "server-b", "b1")); err != nil {
+		t.Fatalf("Archive failed: %v", err)
+	}
+	session, _, _ := first.Restore(ctx, "chat-1")
+	if len(session.Messages) != 6 || session.Version["server-a"] != 5 || session.Version["server-b"] != 1 {
+		t.Errorf("Expected the 6 messages of both copies, got %d (version %v)", len(session.Messages), session.Version)
+	}
+
+	// Archiving at the same time: the second manifest write finds the first's
+	// and retries on top of it
+	racing, _ := Open(ctx, &racingStore{ObjectStore: store, race: func() {
+		if err := second.Archive(ctx, withWrites(session, "server-b", "b2")); err != nil {
+			t.Errorf("Archive failed: %v", err)
+		}
+	}}, Config{SegmentSize: 2})
+	if err := racing.Archive(ctx, withWrites(session, "server-a", "a6")); err != nil {
+		t.Fatalf("Archive failed: %v", err)
+	}
+	session, _, _ = first.Restore(ctx, "chat-1")
+	var ids []string
+	for _, msg := range session.Messages {
+		ids = append(ids, msg.ID)
+	}
+	if len(ids) != 8 {
+		t.Errorf("Expected the 8 messages of both writers, got %v", ids)
+	}
+
+	// Only the manifest and the segments it names are left
+	keys, _ := store.List(ctx, "chats/chat-1/")
+	if want := len(session.Messages)/2 + 1; len(keys) != want {
+		t.Errorf("Expected %d objects, got %v", want, keys)
+	}
+}
@@ -0,0 +1,65 @@
+package archive
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sh4shv4t/DistriChat/pkg/encryption"
+)
+
+// encryptedStore seals objects before they reach the store underneath and
+// opens them on the way back. Each object is bound to its key, so an
+// object copied over another's name fails to open instead of restoring
+// the wrong chat.
+type encryptedStore struct {
+	store     ObjectStore
+	encrypter *encryption.Encrypter
+}
+
+func (s *encryptedStore) Put(ctx context.Context, key string, data []byte) error {
+	sealed, err := s.encrypter.Seal(ctx, data, []byte(key))
+	if err != nil {
+		return fmt.Errorf("failed to encrypt %s: %w", key, err)
+	}
+	return s.store.Put(ctx, key, sealed)
+}
+
+func (s *encryptedStore) Get(ctx context.Context, key string) ([]byte, error) {
+	sealed, err := s.store.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := s.encrypter.Open(ctx, sealed, []byte(key))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt %s: %w", key, err)
+	}
+	return data, nil
+}
+
+func (s *encryptedStore) GetVersion(ctx context.Context, key string) ([]byte, string, error) {
+	sealed, version, err := s.store.GetVersion(ctx, key)
+	if err != nil {
+		return nil, "", err
+	}
+	data, err := s.encrypter.Open(ctx, sealed, []byte(key))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decrypt %s: %w", key, err)
+	}
+	return data, version, nil
+}
+
+func (s *encryptedStore) PutIf(ctx context.Context, key string, data []byte, version string) error {
+	sealed, err := s.encrypter.Seal(ctx, data, []byte(key))
+	if err != nil {
+		return fmt.Errorf("failed to encrypt %s: %w", key, err)
+	}
+	return s.store.PutIf(ctx, key, sealed, version)
+}
+
+func (s *encryptedStore) List(ctx context.Context, prefix string) ([]string, error) {
+	return s.store.List(ctx, prefix)
+}
+
+func (s *encryptedStore) Delete(ctx context.Context, key string) error {
+	return s.store.Delete(ctx, key)
+}
@@ -0,0 +1,140 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// S3Config contains configuration for an S3-compatible object store. GCS
+// works through its interoperability endpoint (storage.googleapis.com) with
+// HMAC keys.
+type S3Config struct {
+	Endpoint  string // e.g. "s3.amazonaws.com" or "storage.googleapis.com"
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+
+	// Insecure connects over plain HTTP (e.g. a local MinIO)
+	Insecure bool
+}
+
+// S3Store keeps objects in one bucket of an S3-compatible service
+type S3Store struct {
+	bucket string
+	client *minio.Client
+}
+
+// NewS3Store creates a store for config.Bucket. The bucket must exist.
+func NewS3Store(config S3Config) (*S3Store, error) {
+	if config.Endpoint == "" || config.Bucket == "" {
+		return nil, fmt.Errorf("endpoint and bucket are required")
+	}
+
+	client, err := minio.New(config.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(config.AccessKey, config.SecretKey, ""),
+		Secure: !config.Insecure,
+		Region: config.Region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client for %s: %w", config.Endpoint, err)
+	}
+
+	return &S3Store{bucket: config.Bucket, client: client}, nil
+}
+
+// Put uploads an object
+func (s *S3Store) Put(ctx context.Context, key string, data []byte) error {
+	_, err := s.client.PutObject(ctx, s.bucket, key, bytes.NewReader(data), int64(len(data)),
+		minio.PutObjectOptions{ContentType: "application/json"})
+	if err != nil {
+		return fmt.Errorf("failed to upload %s: %w", key, err)
+	}
+	return nil
+}
+
+// Get downloads an object
+func (s *S3Store) Get(ctx context.Context, key string) ([]byte, error) {
+	object, err := s.client.GetObject(ctx, s.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", key, err)
+	}
+	defer object.Close()
+
+	data, err := io.ReadAll(object)
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to download %s: %w", key, err)
+	}
+	return data, nil
+}
+
+// GetVersion downloads an object, returning its ETag as the version
+func (s *S3Store) GetVersion(ctx context.Context, key string) ([]byte, string, error) {
+	object, err := s.client.GetObject(ctx, s.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to download %s: %w", key, err)
+	}
+	defer object.Close()
+
+	info, err := object.Stat()
+	if err == nil {
+		var data []byte
+		if data, err = io.ReadAll(object); err == nil {
+			return data, info.ETag, nil
+		}
+	}
+	if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+		return nil, "", ErrNotFound
+	}
+	return nil, "", fmt.Errorf("failed to download %s: %w", key, err)
+}
+
+// PutIf uploads an object with If-Match on version, or If-None-Match if
+// version is empty
+func (s *S3Store) PutIf(ctx context.Context, key string, data []byte, version string) error {
+	opts := minio.PutObjectOptions{ContentType: "application/json"}
+	if version == "" {
+		opts.SetMatchETagExcept("*")
+	} else {
+		opts.SetMatchETag(version)
+	}
+	_, err := s.client.PutObject(ctx, s.bucket, key, bytes.NewReader(data), int64(len(data)), opts)
+	if minio.ToErrorResponse(err).Code == "PreconditionFailed" {
+		return ErrConflict
+	}
+	if err != nil {
+		return fmt.Errorf("failed to upload %s: %w", key, err)
+	}
+	return nil
+}
+
+// List returns the keys starting with prefix
+func (s *S3Store) List(ctx context.Context, prefix string) ([]string, error) {
+	keys := make([]string, 0)
+	for object := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{
+		Prefix:    prefix,
+		Recursive: true,
+	}) {
+		if object.Err != nil {
+			return nil, fmt.Errorf("failed to list %s: %w", prefix, object.Err)
+		}
+		keys = append(keys, object.Key)
+	}
+	return keys, nil
+}
+
+// Delete removes an object
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	if err := s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("failed to delete %s: %w", key, err)
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package archive
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrNotFound is returned when reading an object that doesn't exist
+var ErrNotFound = errors.New("object not found")
+
+// ErrConflict is returned by PutIf when the object changed since it was read
+var ErrConflict = errors.New("object changed")
+
+// ObjectStore is a flat store of named objects, such as an S3 bucket
+type ObjectStore interface {
+	Put(ctx context.Context, key string, data []byte) error
+
+	// Get returns ErrNotFound if the object doesn't exist
+	Get(ctx context.Context, key string) ([]byte, error)
+
+	// GetVersion is Get, also returning the object's current version for
+	// PutIf
+	GetVersion(ctx context.Context, key string) ([]byte, string, error)
+
+	// PutIf stores data under key only if the object is still at version
+	// ("": only if there is no object), or returns ErrConflict
+	PutIf(ctx context.Context, key string, data []byte, version string) error
+
+	// List returns the keys starting with prefix
+	List(ctx context.Context, prefix string) ([]string, error)
+
+	// Delete removes an object. Deleting one that doesn't exist succeeds.
+	Delete(ctx context.Context, key string) error
+}
+
+// MemoryStore is an in-process ObjectStore for tests and demos
+type MemoryStore struct {
+	mu       sync.RWMutex
+	objects  map[string][]byte
+	versions map[string]string
+	puts     int
+}
+
+// NewMemoryStore creates an empty in-memory object store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{objects: make(map[string][]byte), versions: make(map[string]string)}
+}
+
+// Put stores a copy of data under key
+func (s *MemoryStore) Put(ctx context.Context, key string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.put(key, data)
+	return nil
+}
+
+// PutIf stores a copy of data under key if the object is still at version
+func (s *MemoryStore) PutIf(ctx context.Context, key string, data []byte, version string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.versions[key] != version {
+		return ErrConflict
+	}
+	s.put(key, data)
+	return nil
+}
+
+// put stores data as the object's next version (must be called with the
+// lock held). The write count numbers versions, so none is ever reused.
+func (s *MemoryStore) put(key string, data []byte) {
+	s.objects[key] = append([]byte(nil), data...)
+	s.puts++
+	s.versions[key] = strconv.Itoa(s.puts)
+}
+
+// Get returns a copy of the object under key
+func (s *MemoryStore) Get(ctx context.Context, key string) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	data, ok := s.objects[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return append([]byte(nil), data...), nil
+}
+
+// GetVersion returns a copy of the object under key and its version
+func (s *MemoryStore) GetVersion(ctx context.Context, key string) ([]byte, string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	data, ok := s.objects[key]
+	if !ok {
+		return nil, "", ErrNotFound
+	}
+	return append([]byte(nil), data...), s.versions[key], nil
+}
+
+// List returns the keys starting with prefix, sorted
+func (s *MemoryStore) List(ctx context.Context, prefix string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]string, 0)
+	for key := range s.objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// Delete removes the object under key
+func (s *MemoryStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.objects, key)
+	delete(s.versions, key)
+	return nil
+}
+
+// Puts returns the number of writes made so far
+func (s *MemoryStore) Puts() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.puts
+}
@@ -0,0 +1,114 @@
+// Package audit records security and administrative events: every admin
+// call, rejected credentials, calls refused access to chats, drains and
+// decommissions, cache purges, node removals and configuration changes. The audit log is kept apart from the operational
+// logs, which are sampled, levelled and rotated for debugging; audit events
+// are appended in order, never rewritten, and can be queried back (e.g. by
+// AdminService.QueryAuditLog) for compliance reviews.
+//
+// FileLog is the durable backend; MemoryLog serves tests and demos.
+package audit
+
+import (
+	"errors"
+	"time"
+)
+
+// Actions recorded by servers
+const (
+	ActionAuthFailure   = "auth_failure"    // A call presented bad credentials
+	ActionDrain         = "drain"           // The server stopped taking messages
+	ActionDecommission  = "decommission"    // The server was shut down for good
+	ActionCachePurge    = "cache_purge"     // Cached sessions were dropped
+	ActionConfigChange  = "config_change"   // A runtime setting was changed
+	ActionNodeRemoved   = "node_removed"    // A member was deleted from the ring
+	ActionAccessDenied  = "access_denied"   // A caller was refused a chat it isn't a member of
+	ActionAPIKeyCreated = "api_key_created" // An API key was issued
+	ActionAPIKeyRevoked = "api_key_revoked" // An API key was revoked
+	ActionAdminCall     = "admin_call"      // An AdminService method was called
+	ActionUserPurge     = "user_purge"      // A user's messages were purged from the cluster
+)
+
+// Outcomes of an action
+const (
+	OutcomeOK       = "ok"
+	OutcomeDenied   = "denied"   // Refused for lack of authorization
+	OutcomeRejected = "rejected" // Refused as invalid
+	OutcomeFailed   = "failed"   // Attempted, but it failed
+)
+
+// ErrClosed is returned when using a closed log
+var ErrClosed = errors.New("audit log is closed")
+
+// Event is one audited action
+type Event struct {
+	Seq  uint64    `json:"seq"`  // Position in the log, from 1; assigned by Append
+	Time time.Time `json:"time"` // Set by Append if zero
+
+	Server string `json:"server"` // Server the action happened on
+	Actor  string `json:"actor"`  // Who did it: a caller's address, or "system"
+	Action string `json:"action"`
+	Target string `json:"target,omitempty"` // What it was done to (a method, node, setting...)
+
+	Outcome string            `json:"outcome"`
+	Reason  string            `json:"reason,omitempty"`  // Given by the actor, if any
+	Details map[string]string `json:"details,omitempty"` // e.g. old and new values
+}
+
+// Log is an append-only audit log
+type Log interface {
+	// Append records e, returning it with its sequence number and time
+	Append(e Event) (Event, error)
+
+	// Query returns the events matching q, oldest first
+	Query(q Query) ([]Event, error)
+
+	Close() error
+}
+
+// Query selects events. Zero fields match everything.
+type Query struct {
+	Since    time.Time // At or after
+	Until    time.Time // Before
+	AfterSeq uint64    // Sequence numbers above, for paging
+	Actions  []string  // Any of
+	Actor    string
+	Target   string
+	Outcome  string
+	Limit    int // At most this many, the oldest matching
+}
+
+// Matches reports whether e is selected by q, ignoring Limit
+func (q Query) Matches(e Event) bool {
+	if e.Seq <= q.AfterSeq {
+		return false
+	}
+	if !q.Since.IsZero() && e.Time.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && !e.Time.Before(q.Until) {
+		return false
+	}
+	if q.Actor != "" && e.Actor != q.Actor {
+		return false
+	}
+	if q.Target != "" && e.Target != q.Target {
+		return false
+	}
+	if q.Outcome != "" && e.Outcome != q.Outcome {
+		return false
+	}
+	if len(q.Actions) == 0 {
+		return true
+	}
+	for _, action := range q.Actions {
+		if e.Action == action {
+			return true
+		}
+	}
+	return false
+}
+
+// full reports whether n results satisfy q's limit
+func (q Query) full(n int) bool {
+	return q.Limit > 0 && n >= q.Limit
+}
@@ -0,0 +1,161 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var start = time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+// record appends a drain, an auth failure and a config change, a minute
+// apart
+func record(t *testing.T, l Log) {
+	t.Helper()
+	events := []Event{
+		{Time: start, Server: "server-1", Actor: "10.0.0.1:5000", Action: ActionDrain, Reason: "maintenance"},
+		{Time: start.Add(time.Minute), Server: "server-1", Actor: "10.0.0.9:6000", Action: ActionAuthFailure,
+			Target: "/chat.AdminService/Drain", Outcome: OutcomeDenied},
+		{Time: start.Add(2 * time.Minute), Server: "server-1", Actor: "10.0.0.1:5000", Action: ActionConfigChange,
+			Target: "cache", Details: map[string]string{"l1_capacity": "5 -> 10"}},
+	}
+	for i, e := range events {
+		got, err := l.Append(e)
+		if err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+		if got.Seq != uint64(i+1) {
+			t.Errorf("Expected sequence number %d, got %d", i+1, got.Seq)
+		}
+	}
+}
+
+func testQueries(t *testing.T, l Log) {
+	tests := []struct {
+		name  string
+		query Query
+		want  []uint64
+	}{
+		{"all", Query{}, []uint64{1, 2, 3}},
+		{"action", Query{Actions: []string{ActionAuthFailure}}, []uint64{2}},
+		{"actions", Query{Actions: []string{ActionDrain, ActionConfigChange}}, []uint64{1, 3}},
+		{"actor", Query{Actor: "10.0.0.1:5000"}, []uint64{1, 3}},
+		{"target", Query{Target: "cache"}, []uint64{3}},
+		{"outcome", Query{Outcome: OutcomeDenied}, []uint64{2}},
+		{"since", Query{Since: start.Add(time.Minute)}, []uint64{2, 3}},
+		{"until", Query{Until: start.Add(time.Minute)}, []uint64{1}},
+		{"after", Query{AfterSeq: 1}, []uint64{2, 3}},
+		{"limit", Query{Limit: 2}, []uint64{1, 2}},
+		{"none", Query{Actions: []string{ActionNodeRemoved}}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, err := l.Query(tt.query)
+			if err != nil {
+				t.Fatalf("Query failed: %v", err)
+			}
+			var got []uint64
+			for _, e := range events {
+				got = append(got, e.Seq)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Expected events %v, got %v", tt.want, got)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("Expected events %v, got %v", tt.want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryLog(t *testing.T) {
+	l := NewMemoryLog()
+	record(t, l)
+	testQueries(t, l)
+
+	// Recorded events can't be changed through a query result
+	events, _ := l.Query(Query{AfterSeq: 2})
+	events[0].Details["l1_capacity"] = "tampered"
+	events, _ = l.Query(Query{AfterSeq: 2})
+	if got := events[0].Details["l1_capacity"]; got != "5 -> 10" {
+		t.Errorf("Expected the recorded details unchanged, got %q", got)
+	}
+
+	l.Close()
+	if _, err := l.Append(Event{Action: ActionDrain}); err != ErrClosed {
+		t.Errorf("Expected ErrClosed, got %v", err)
+	}
+}
+
+func TestFileLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	l, err := OpenFile(path)
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	record(t, l)
+	testQueries(t, l)
+	l.Close()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("Expected the log readable by its owner only, got %v", perm)
+	}
+
+	// Reopening continues the numbering and keeps the events
+	l, err = OpenFile(path)
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	defer l.Close()
+	e, err := l.Append(Event{Action: ActionCachePurge})
+	if err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+	if e.Seq != 4 {
+		t.Errorf("Expected sequence number 4 after reopening, got %d", e.Seq)
+	}
+	events, _ := l.Query(Query{Actions: []string{ActionConfigChange}})
+	if len(events) != 1 || events[0].Details["l1_capacity"] != "5 -> 10" || !events[0].Time.Equal(start.Add(2*time.Minute)) {
+		t.Errorf("Expected the config change to survive reopening, got %+v", events)
+	}
+}
+
+func TestFileLogTornWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	l, err := OpenFile(path)
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	record(t, l)
+	l.Close()
+
+	// A crash mid-write leaves half a line
+	f, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	f.WriteString(`{"seq":4,"action":"dra`)
+	f.Close()
+
+	l, err = OpenFile(path)
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	defer l.Close()
+	if _, err := l.Append(Event{Action: ActionDrain}); err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+
+	events, err := l.Query(Query{})
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if len(events) != 4 || events[3].Seq != 4 || events[3].Action != ActionDrain {
+		t.Errorf("Expected the torn event skipped and the new one as 4, got %+v", events)
+	}
+}
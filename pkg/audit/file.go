@@ -0,0 +1,154 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// FileLog is a Log kept in a file of JSON lines, one event per line. The
+// file is only ever appended to, and each event is synced to disk before
+// Append returns, so a recorded event survives a crash. Opening an
+// existing file continues its numbering.
+type FileLog struct {
+	mu     sync.Mutex
+	path   string
+	file   *os.File
+	seq    uint64
+	closed bool
+}
+
+// OpenFile opens the log at path, creating it if needed. The file is
+// readable only by its owner.
+func OpenFile(path string) (*FileLog, error) {
+	l := &FileLog{path: path}
+	if err := l.scan(func(e Event) bool {
+		l.seq = e.Seq
+		return true
+	}); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	l.file = file
+
+	// Terminate a torn last line so the next event starts on its own
+	if torn, err := endsTorn(path); err != nil || torn {
+		if err == nil {
+			_, err = file.Write([]byte("\n"))
+		}
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to repair audit log: %w", err)
+		}
+	}
+	return l, nil
+}
+
+// endsTorn reports whether the file at path is non-empty and doesn't end
+// in a newline
+func endsTorn(path string) (bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil || info.Size() == 0 {
+		return false, err
+	}
+	last := make([]byte, 1)
+	if _, err := file.ReadAt(last, info.Size()-1); err != nil {
+		return false, err
+	}
+	return last[0] != '\n', nil
+}
+
+// Append writes e to the end of the file and syncs it
+func (l *FileLog) Append(e Event) (Event, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return Event{}, ErrClosed
+	}
+	e.Seq = l.seq + 1
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	e.Time = e.Time.UTC()
+
+	line, err := json.Marshal(e)
+	if err != nil {
+		return Event{}, err
+	}
+	if _, err := l.file.Write(append(line, '\n')); err != nil {
+		return Event{}, fmt.Errorf("failed to write audit event: %w", err)
+	}
+	if err := l.file.Sync(); err != nil {
+		return Event{}, fmt.Errorf("failed to sync audit log: %w", err)
+	}
+	l.seq = e.Seq
+	return e, nil
+}
+
+// Query reads the file from the start, returning the matching events
+func (l *FileLog) Query(q Query) ([]Event, error) {
+	l.mu.Lock()
+	closed := l.closed
+	l.mu.Unlock()
+	if closed {
+		return nil, ErrClosed
+	}
+
+	var result []Event
+	err := l.scan(func(e Event) bool {
+		if q.Matches(e) {
+			result = append(result, e)
+		}
+		return !q.full(len(result))
+	})
+	return result, err
+}
+
+// scan calls fn with each event in the file until fn returns false. A
+// torn last line, left by a crash mid-write, is skipped.
+func (l *FileLog) scan(fn func(Event) bool) error {
+	file, err := os.Open(l.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for scanner.Scan() {
+		var e Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			continue
+		}
+		if !fn(e) {
+			return nil
+		}
+	}
+	return scanner.Err()
+}
+
+// Close closes the file
+func (l *FileLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return nil
+	}
+	l.closed = true
+	return l.file.Close()
+}
@@ -0,0 +1,84 @@
+package audit
+
+import (
+	"sync"
+	"time"
+)
+
+// MemoryLog is an in-process Log. Its events are lost when the process
+// exits, so it suits tests and demos rather than compliance.
+type MemoryLog struct {
+	mu     sync.RWMutex
+	events []Event
+	closed bool
+}
+
+// NewMemoryLog creates an empty in-memory log
+func NewMemoryLog() *MemoryLog {
+	return &MemoryLog{}
+}
+
+// Append records e
+func (l *MemoryLog) Append(e Event) (Event, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return Event{}, ErrClosed
+	}
+	e.Seq = uint64(len(l.events)) + 1
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	e.Details = copyDetails(e.Details)
+	l.events = append(l.events, e)
+	return e, nil
+}
+
+// Query returns the matching events, oldest first
+func (l *MemoryLog) Query(q Query) ([]Event, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if l.closed {
+		return nil, ErrClosed
+	}
+	var result []Event
+	for _, e := range l.events {
+		if q.full(len(result)) {
+			break
+		}
+		if q.Matches(e) {
+			e.Details = copyDetails(e.Details)
+			result = append(result, e)
+		}
+	}
+	return result, nil
+}
+
+// Len returns the number of events in the log
+func (l *MemoryLog) Len() int {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return len(l.events)
+}
+
+// Close makes further use of the log fail
+func (l *MemoryLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.closed = true
+	return nil
+}
+
+// copyDetails copies details so callers can't change a recorded event
+func copyDetails(details map[string]string) map[string]string {
+	if details == nil {
+		return nil
+	}
+	copied := make(map[string]string, len(details))
+	for k, v := range details {
+		copied[k] = v
+	}
+	return copied
+}
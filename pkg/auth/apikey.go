@@ -0,0 +1,376 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/pkg/chaterr"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+)
+
+// APIKeyHeader is the metadata key clients send their API key under
+const APIKeyHeader = "x-api-key"
+
+// API key scopes
+const (
+	ScopeRead  = "chat:read"  // Read chat histories
+	ScopeWrite = "chat:write" // Post messages
+)
+
+// keyPrefix starts every API key, so leaked keys are easy to search for
+const keyPrefix = "dck_"
+
+// ErrKeyNotFound is returned for an API key ID the store doesn't hold
+var ErrKeyNotFound = errors.New("auth: API key not found")
+
+// APIKey is a stored API key. The secret itself is only returned by
+// KeyStore.Create; the store keeps its hash.
+type APIKey struct {
+	ID          string    `json:"id"`
+	Tenant      string    `json:"tenant"`    // Namespace calls with the key may use ("" the default one)
+	Principal   string    `json:"principal"` // Who calls with the key are made as
+	Scopes      []string  `json:"scopes"`
+	Description string    `json:"description,omitempty"`
+	Created     time.Time `json:"created"`
+	Revoked     time.Time `json:"revoked"` // Zero while the key is valid
+	Hash        []byte    `json:"hash"`    // SHA-256 of the key
+}
+
+// Valid reports whether the key hasn't been revoked
+func (k APIKey) Valid() bool {
+	return k.Revoked.IsZero()
+}
+
+// KeyStore holds the API keys a server accepts
+type KeyStore interface {
+	// Create stores a new key with key's tenant, principal, scopes and
+	// description, returning it with its ID and the key to hand out
+	Create(key APIKey) (APIKey, string, error)
+
+	// Revoke invalidates a key, returning it; revoking it again is a no-op
+	Revoke(id string) (APIKey, error)
+
+	// Get returns a key, revoked or not
+	Get(id string) (APIKey, error)
+
+	// List returns the keys of a tenant, or of every tenant if all is
+	// set, oldest first
+	List(tenant string, all bool) ([]APIKey, error)
+}
+
+// APIKeys authenticates callers by the API key in their APIKeyHeader
+// metadata. The principal is the key's, limited to its tenant and scopes.
+func APIKeys(store KeyStore) Authenticator {
+	return AuthenticatorFunc(func(ctx context.Context) (Principal, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		values := md.Get(APIKeyHeader)
+		if len(values) == 0 {
+			return Principal{}, fmt.Errorf("%w: no API key", chaterr.ErrUnauthenticated)
+		}
+		key, err := VerifyKey(store, values[0])
+		if err != nil {
+			return Principal{}, err
+		}
+		// Not nil even without scopes, so the key allows nothing
+		scopes := append([]string{}, key.Scopes...)
+		return Principal{ID: key.Principal, KeyID: key.ID, Tenant: key.Tenant, Scopes: scopes}, nil
+	})
+}
+
+// APIKeyCredentials returns per-call credentials sending key in the
+// APIKeyHeader metadata, for grpc.WithPerRPCCredentials. They are sent on
+// plaintext connections too, so without TLS the key is only as secret as
+// the network.
+func APIKeyCredentials(key string) credentials.PerRPCCredentials {
+	return apiKeyCredentials(key)
+}
+
+type apiKeyCredentials string
+
+func (k apiKeyCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{APIKeyHeader: string(k)}, nil
+}
+
+func (k apiKeyCredentials) RequireTransportSecurity() bool {
+	return false
+}
+
+// VerifyKey returns the stored key a client's key is, or an error wrapping
+// chaterr.ErrUnauthenticated if it is unknown, wrong or revoked
+func VerifyKey(store KeyStore, secret string) (APIKey, error) {
+	id, ok := keyID(secret)
+	if !ok {
+		return APIKey{}, fmt.Errorf("%w: malformed API key", chaterr.ErrUnauthenticated)
+	}
+	key, err := store.Get(id)
+	if errors.Is(err, ErrKeyNotFound) {
+		return APIKey{}, fmt.Errorf("%w: unknown API key %s", chaterr.ErrUnauthenticated, id)
+	}
+	if err != nil {
+		return APIKey{}, err
+	}
+	hash := sha256.Sum256([]byte(secret))
+	if subtle.ConstantTimeCompare(hash[:], key.Hash) != 1 {
+		return APIKey{}, fmt.Errorf("%w: wrong API key %s", chaterr.ErrUnauthenticated, id)
+	}
+	if !key.Valid() {
+		return APIKey{}, fmt.Errorf("%w: API key %s was revoked", chaterr.ErrUnauthenticated, id)
+	}
+	return key, nil
+}
+
+// newKey fills in a new key's ID, hash and creation time, returning the
+// key to hand out: the prefix, the ID and a random secret
+func newKey(key APIKey) (APIKey, string, error) {
+	var id [8]byte
+	var secret [24]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return APIKey{}, "", fmt.Errorf("auth: %w", err)
+	}
+	if _, err := rand.Read(secret[:]); err != nil {
+		return APIKey{}, "", fmt.Errorf("auth: %w", err)
+	}
+	key.ID = hex.EncodeToString(id[:])
+	plain := keyPrefix + key.ID + "_" + hex.EncodeToString(secret[:])
+	hash := sha256.Sum256([]byte(plain))
+	key.Hash = hash[:]
+	key.Created = time.Now()
+	key.Revoked = time.Time{}
+	return key, plain, nil
+}
+
+// keyID extracts the ID from a key handed out by newKey
+func keyID(secret string) (string, bool) {
+	rest, ok := strings.CutPrefix(secret, keyPrefix)
+	if !ok {
+		return "", false
+	}
+	id, _, ok := strings.Cut(rest, "_")
+	return id, ok && id != ""
+}
+
+// MemoryKeyStore is a KeyStore in memory, lost on exit
+type MemoryKeyStore struct {
+	mu   sync.RWMutex
+	keys map[string]APIKey
+}
+
+// NewMemoryKeyStore creates an empty in-memory key store
+func NewMemoryKeyStore() *MemoryKeyStore {
+	return &MemoryKeyStore{keys: make(map[string]APIKey)}
+}
+
+func (m *MemoryKeyStore) Create(key APIKey) (APIKey, string, error) {
+	key, plain, err := newKey(key)
+	if err != nil {
+		return APIKey{}, "", err
+	}
+	m.mu.Lock()
+	m.keys[key.ID] = key
+	m.mu.Unlock()
+	return key, plain, nil
+}
+
+func (m *MemoryKeyStore) Revoke(id string) (APIKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key, ok := m.keys[id]
+	if !ok {
+		return APIKey{}, ErrKeyNotFound
+	}
+	if key.Valid() {
+		key.Revoked = time.Now()
+		m.keys[id] = key
+	}
+	return key, nil
+}
+
+func (m *MemoryKeyStore) Get(id string) (APIKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	key, ok := m.keys[id]
+	if !ok {
+		return APIKey{}, ErrKeyNotFound
+	}
+	return key, nil
+}
+
+func (m *MemoryKeyStore) List(tenant string, all bool) ([]APIKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var keys []APIKey
+	for _, key := range m.keys {
+		if all || key.Tenant == tenant {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if !keys[i].Created.Equal(keys[j].Created) {
+			return keys[i].Created.Before(keys[j].Created)
+		}
+		return keys[i].ID < keys[j].ID
+	})
+	return keys, nil
+}
+
+// FileKeyStore is a KeyStore kept in a JSON file readable only by its
+// owner. Changes are written to a temporary file renamed over the old one,
+// so a crash leaves either version. Keys changed in the file by another
+// process (e.g. another server sharing it) are picked up within
+// FileKeyStore's check interval.
+type FileKeyStore struct {
+	path     string
+	interval time.Duration
+
+	mu      sync.Mutex
+	keys    *MemoryKeyStore
+	file    os.FileInfo // The file as last read; every save replaces it
+	checked time.Time
+}
+
+// DefaultKeyCheckInterval is how often a FileKeyStore looks for changes
+// made to its file by others
+const DefaultKeyCheckInterval = 10 * time.Second
+
+// OpenKeyStore opens the key store in path, creating it if it doesn't
+// exist
+func OpenKeyStore(path string) (*FileKeyStore, error) {
+	f := &FileKeyStore{path: path, interval: DefaultKeyCheckInterval, keys: NewMemoryKeyStore()}
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		if err := f.save(); err != nil {
+			return nil, err
+		}
+	}
+	if err := f.load(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *FileKeyStore) Create(key APIKey) (APIKey, string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.load(); err != nil {
+		return APIKey{}, "", err
+	}
+	key, plain, err := f.keys.Create(key)
+	if err != nil {
+		return APIKey{}, "", err
+	}
+	if err := f.save(); err != nil {
+		return APIKey{}, "", err
+	}
+	return key, plain, nil
+}
+
+func (f *FileKeyStore) Revoke(id string) (APIKey, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.load(); err != nil {
+		return APIKey{}, err
+	}
+	key, err := f.keys.Revoke(id)
+	if err != nil {
+		return APIKey{}, err
+	}
+	return key, f.save()
+}
+
+func (f *FileKeyStore) Get(id string) (APIKey, error) {
+	return f.current().Get(id)
+}
+
+func (f *FileKeyStore) List(tenant string, all bool) ([]APIKey, error) {
+	return f.current().List(tenant, all)
+}
+
+// current returns the keys, first rereading the file if it is due a check
+// and was changed. On error the keys already loaded are kept.
+func (f *FileKeyStore) current() *MemoryKeyStore {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if time.Since(f.checked) >= f.interval {
+		f.load()
+	}
+	return f.keys
+}
+
+// load reads the file if it changed since it was last read; f.mu must be
+// held, or f not yet shared
+func (f *FileKeyStore) load() error {
+	f.checked = time.Now()
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return fmt.Errorf("auth: %w", err)
+	}
+	if f.file != nil && os.SameFile(info, f.file) && info.ModTime().Equal(f.file.ModTime()) {
+		return nil
+	}
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return fmt.Errorf("auth: %w", err)
+	}
+	var keys []APIKey
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return fmt.Errorf("auth: %s: %w", f.path, err)
+	}
+
+	loaded := NewMemoryKeyStore()
+	for _, key := range keys {
+		loaded.keys[key.ID] = key
+	}
+	f.keys, f.file = loaded, info
+	return nil
+}
+
+// save writes the keys to the file; f.mu must be held
+func (f *FileKeyStore) save() error {
+	keys, _ := f.keys.List("", true)
+	if keys == nil {
+		keys = []APIKey{}
+	}
+	data, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return fmt.Errorf("auth: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("auth: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0o600)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), f.path)
+	}
+	if err != nil {
+		return fmt.Errorf("auth: %w", err)
+	}
+
+	if info, err := os.Stat(f.path); err == nil {
+		f.file = info
+	}
+	return nil
+}
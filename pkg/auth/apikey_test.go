@@ -0,0 +1,101 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/pkg/chaterr"
+	"google.golang.org/grpc/metadata"
+)
+
+func withKey(secret string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs(APIKeyHeader, secret))
+}
+
+func testKeyStore(t *testing.T, store KeyStore) {
+	t.Helper()
+	key, secret, err := store.Create(APIKey{Tenant: "premium", Principal: "bot", Scopes: []string{ScopeWrite}})
+	if err != nil {
+		t.Fatalf("Expected the key created, got %v", err)
+	}
+
+	authenticator := APIKeys(store)
+	p, err := authenticator.Authenticate(withKey(secret))
+	if err != nil {
+		t.Fatalf("Expected the key accepted, got %v", err)
+	}
+	if p.ID != "bot" || p.KeyID != key.ID || p.Tenant != "premium" || !p.Allows(ScopeWrite, "premium") {
+		t.Errorf("Expected bot limited to writes in premium, got %+v", p)
+	}
+
+	for name, ctx := range map[string]context.Context{
+		"no key":    context.Background(),
+		"malformed": withKey("hunter2"),
+		"unknown":   withKey(keyPrefix + "0000000000000000_abc"),
+		"wrong":     withKey(keyPrefix + key.ID + "_abc"),
+	} {
+		if _, err := authenticator.Authenticate(ctx); !errors.Is(err, chaterr.ErrUnauthenticated) {
+			t.Errorf("Expected ErrUnauthenticated for %s, got %v", name, err)
+		}
+	}
+
+	_, unscoped, err := store.Create(APIKey{Tenant: "free", Principal: "other"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p, err := authenticator.Authenticate(withKey(unscoped)); err != nil || p.Allows(ScopeRead, "free") {
+		t.Errorf("Expected a key without scopes to allow nothing, got %+v, %v", p, err)
+	}
+	if keys, _ := store.List("premium", false); len(keys) != 1 || keys[0].ID != key.ID {
+		t.Errorf("Expected premium's key listed, got %v", keys)
+	}
+	if keys, _ := store.List("", true); len(keys) != 2 {
+		t.Errorf("Expected 2 keys across tenants, got %d", len(keys))
+	}
+
+	revoked, err := store.Revoke(key.ID)
+	if err != nil || revoked.Valid() {
+		t.Fatalf("Expected the key revoked, got %+v, %v", revoked, err)
+	}
+	if _, err := authenticator.Authenticate(withKey(secret)); !errors.Is(err, chaterr.ErrUnauthenticated) {
+		t.Errorf("Expected a revoked key refused, got %v", err)
+	}
+	if _, err := store.Revoke("missing"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Expected ErrKeyNotFound, got %v", err)
+	}
+}
+
+func TestMemoryKeyStore(t *testing.T) {
+	testKeyStore(t, NewMemoryKeyStore())
+}
+
+func TestFileKeyStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	store, err := OpenKeyStore(path)
+	if err != nil {
+		t.Fatalf("Expected the store opened, got %v", err)
+	}
+	testKeyStore(t, store)
+
+	// A second process sharing the file sees the keys, and keys it issues
+	// reach the first once it checks the file again
+	other, err := OpenKeyStore(path)
+	if err != nil {
+		t.Fatalf("Expected the store reopened, got %v", err)
+	}
+	if keys, _ := other.List("", true); len(keys) != 2 {
+		t.Errorf("Expected 2 keys after reopening, got %d", len(keys))
+	}
+	key, secret, err := other.Create(APIKey{Principal: "carol", Scopes: []string{ScopeRead}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.interval = time.Millisecond
+	time.Sleep(2 * time.Millisecond)
+	if got, err := VerifyKey(store, secret); err != nil || got.ID != key.ID {
+		t.Errorf("Expected the other process's key accepted, got %v", err)
+	}
+}
@@ -0,0 +1,107 @@
+// Package auth identifies the principal behind a call. A server's
+// Authenticator turns a call's credentials (a client certificate, a token
+// in its metadata...) into a Principal, which the server checks against the
+// chat's members before serving it.
+//
+//	srv := server.NewChatServer(server.ServerConfig{
+//		TLS:           provider,
+//		AccessControl: &server.AccessControl{Admins: []string{"server-1", "ops"}},
+//	})
+//
+// With TLS set and no Authenticator, servers authenticate callers by their
+// certificate's common name (TLSIdentity).
+package auth
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sh4shv4t/DistriChat/pkg/chaterr"
+	"github.com/sh4shv4t/DistriChat/pkg/mtls"
+)
+
+// Principal is an authenticated caller
+type Principal struct {
+	ID    string // Who the caller is, e.g. a user or server ID
+	Admin bool   // May read and write every chat
+
+	// KeyID is the API key the caller authenticated with, if any
+	KeyID string
+
+	// Scopes, if not nil, are all the caller may do, and only in the
+	// Tenant namespace (e.g. an API key's)
+	Scopes []string
+	Tenant string
+}
+
+// Allows reports whether p may do scope in the tenant namespace
+func (p Principal) Allows(scope, tenant string) bool {
+	if p.Scopes == nil {
+		return true
+	}
+	if p.Tenant != tenant {
+		return false
+	}
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// FirstOf authenticates callers with the first of authenticators that
+// accepts them, e.g. API keys for clients and certificates for peers. It
+// returns the last error if none do.
+func FirstOf(authenticators ...Authenticator) Authenticator {
+	return AuthenticatorFunc(func(ctx context.Context) (Principal, error) {
+		err := fmt.Errorf("%w: no authenticator", chaterr.ErrUnauthenticated)
+		for _, a := range authenticators {
+			var p Principal
+			if p, err = a.Authenticate(ctx); err == nil {
+				return p, nil
+			}
+		}
+		return Principal{}, err
+	})
+}
+
+// Authenticator identifies the caller of a call from its context (peer,
+// metadata), returning an error wrapping chaterr.ErrUnauthenticated if it
+// can't
+type Authenticator interface {
+	Authenticate(ctx context.Context) (Principal, error)
+}
+
+// AuthenticatorFunc adapts a function to Authenticator
+type AuthenticatorFunc func(ctx context.Context) (Principal, error)
+
+// Authenticate calls f(ctx)
+func (f AuthenticatorFunc) Authenticate(ctx context.Context) (Principal, error) {
+	return f(ctx)
+}
+
+// TLSIdentity authenticates callers by the common name of their verified
+// client certificate (see mtls.Identity)
+func TLSIdentity() Authenticator {
+	return AuthenticatorFunc(func(ctx context.Context) (Principal, error) {
+		id := mtls.Identity(ctx)
+		if id == "" {
+			return Principal{}, fmt.Errorf("%w: no verified client certificate", chaterr.ErrUnauthenticated)
+		}
+		return Principal{ID: id}, nil
+	})
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying p
+func NewContext(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, contextKey{}, p)
+}
+
+// FromContext returns the principal ctx carries, if any
+func FromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(contextKey{}).(Principal)
+	return p, ok
+}
@@ -0,0 +1,57 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/sh4shv4t/DistriChat/pkg/chaterr"
+)
+
+func TestContext(t *testing.T) {
+	if _, ok := FromContext(context.Background()); ok {
+		t.Errorf("Expected no principal in an empty context")
+	}
+
+	ctx := NewContext(context.Background(), Principal{ID: "alice", Admin: true})
+	p, ok := FromContext(ctx)
+	if !ok || p.ID != "alice" || !p.Admin {
+		t.Errorf("Expected admin alice, got %+v", p)
+	}
+}
+
+func TestTLSIdentityWithoutCertificate(t *testing.T) {
+	_, err := TLSIdentity().Authenticate(context.Background())
+	if !errors.Is(err, chaterr.ErrUnauthenticated) {
+		t.Errorf("Expected ErrUnauthenticated for a call without a certificate, got %v", err)
+	}
+}
+
+func TestPrincipalAllows(t *testing.T) {
+	if !(Principal{ID: "server-1"}).Allows(ScopeWrite, "premium") {
+		t.Errorf("Expected a principal without scopes to be allowed everything")
+	}
+	p := Principal{ID: "bot", Tenant: "premium", Scopes: []string{ScopeRead}}
+	if !p.Allows(ScopeRead, "premium") {
+		t.Errorf("Expected reads in the key's tenant allowed")
+	}
+	if p.Allows(ScopeWrite, "premium") || p.Allows(ScopeRead, "") {
+		t.Errorf("Expected writes and other tenants refused")
+	}
+}
+
+func TestFirstOf(t *testing.T) {
+	refuse := AuthenticatorFunc(func(context.Context) (Principal, error) {
+		return Principal{}, chaterr.ErrUnauthenticated
+	})
+	accept := AuthenticatorFunc(func(context.Context) (Principal, error) {
+		return Principal{ID: "alice"}, nil
+	})
+
+	if p, err := FirstOf(refuse, accept).Authenticate(context.Background()); err != nil || p.ID != "alice" {
+		t.Errorf("Expected alice from the second authenticator, got %+v, %v", p, err)
+	}
+	if _, err := FirstOf(refuse, refuse).Authenticate(context.Background()); !errors.Is(err, chaterr.ErrUnauthenticated) {
+		t.Errorf("Expected ErrUnauthenticated when none accept, got %v", err)
+	}
+}
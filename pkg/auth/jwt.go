@@ -0,0 +1,461 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	_ "crypto/sha256" // SHA-256 for RS256, PS256 and ES256
+	_ "crypto/sha512" // SHA-384 and SHA-512 for the others
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/pkg/chaterr"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+)
+
+// AuthorizationHeader is the metadata key clients send bearer tokens under
+const AuthorizationHeader = "authorization"
+
+// JWT verification defaults
+const (
+	DefaultJWKSRefresh    = time.Hour
+	DefaultJWKSMinRefresh = time.Minute
+	DefaultJWTLeeway      = time.Minute
+)
+
+// JWTConfig describes the tokens a JWTVerifier accepts and how their
+// claims map to a Principal
+type JWTConfig struct {
+	// JWKSURL serves the identity provider's signing keys, e.g.
+	// https://idp.example.com/.well-known/jwks.json
+	JWKSURL string
+
+	// Issuer and Audience, if set, must match the iss and aud claims
+	Issuer   string
+	Audience string
+
+	// SubjectClaim names the claim holding the principal's ID, the sender
+	// identity (default: "sub")
+	SubjectClaim string
+
+	// TenantClaim, if set, names the claim holding the tenant (namespace)
+	// the token is limited to. The token is then also limited to the chat
+	// scopes in ScopesClaim (default: "scope", a space-separated string or
+	// a list), or to reads and writes if it carries no such claim.
+	TenantClaim string
+	ScopesClaim string
+
+	// AdminClaim, if set, names a boolean claim marking admins
+	AdminClaim string
+
+	// Leeway allowed between the provider's clock and ours when checking
+	// exp, nbf and iat (default: a minute)
+	Leeway time.Duration
+
+	// Refresh is how often the keys are refetched (default: an hour).
+	// Tokens signed by a key not yet fetched refetch them sooner, at most
+	// once per MinRefresh (default: a minute), so rotated keys are picked
+	// up without a flood of fetches for forged key IDs.
+	Refresh    time.Duration
+	MinRefresh time.Duration
+
+	// Client fetches the keys (default: http.DefaultClient)
+	Client *http.Client
+}
+
+// JWTVerifier checks JSON Web Tokens signed by keys from a JWKS endpoint,
+// caching the keys. It is safe for concurrent use.
+type JWTVerifier struct {
+	config JWTConfig
+	now    func() time.Time
+
+	mu      sync.Mutex
+	keys    map[string]crypto.PublicKey // By key ID
+	fetched time.Time                   // Last fetch attempt
+	loaded  time.Time                   // Last successful fetch
+}
+
+// NewJWTVerifier creates a verifier for config. Keys are fetched when the
+// first token is checked, so the identity provider needn't be up first.
+func NewJWTVerifier(config JWTConfig) (*JWTVerifier, error) {
+	if config.JWKSURL == "" {
+		return nil, errors.New("auth: JWKSURL is required")
+	}
+	if config.SubjectClaim == "" {
+		config.SubjectClaim = "sub"
+	}
+	if config.ScopesClaim == "" {
+		config.ScopesClaim = "scope"
+	}
+	if config.Leeway == 0 {
+		config.Leeway = DefaultJWTLeeway
+	}
+	if config.Refresh <= 0 {
+		config.Refresh = DefaultJWKSRefresh
+	}
+	if config.MinRefresh <= 0 {
+		config.MinRefresh = DefaultJWKSMinRefresh
+	}
+	if config.Client == nil {
+		config.Client = http.DefaultClient
+	}
+	return &JWTVerifier{config: config, now: time.Now, keys: make(map[string]crypto.PublicKey)}, nil
+}
+
+// JWT authenticates callers by the bearer token in their
+// AuthorizationHeader metadata
+func JWT(v *JWTVerifier) Authenticator {
+	return AuthenticatorFunc(func(ctx context.Context) (Principal, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		values := md.Get(AuthorizationHeader)
+		if len(values) == 0 {
+			return Principal{}, fmt.Errorf("%w: no bearer token", chaterr.ErrUnauthenticated)
+		}
+		token, ok := strings.CutPrefix(values[0], "Bearer ")
+		if !ok {
+			return Principal{}, fmt.Errorf("%w: authorization is not a bearer token", chaterr.ErrUnauthenticated)
+		}
+		return v.Verify(ctx, token)
+	})
+}
+
+// BearerToken returns per-call credentials sending the token source
+// returns (e.g. refreshed from an identity provider) as a bearer token in
+// the AuthorizationHeader metadata. Like APIKeyCredentials, they are sent
+// on plaintext connections too.
+func BearerToken(source func(ctx context.Context) (string, error)) credentials.PerRPCCredentials {
+	return bearerToken(source)
+}
+
+type bearerToken func(ctx context.Context) (string, error)
+
+func (b bearerToken) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	token, err := b(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{AuthorizationHeader: "Bearer " + token}, nil
+}
+
+func (b bearerToken) RequireTransportSecurity() bool {
+	return false
+}
+
+// Verify checks a token's signature and claims, returning the principal
+// its claims describe, or an error wrapping chaterr.ErrUnauthenticated
+func (v *JWTVerifier) Verify(ctx context.Context, token string) (Principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Principal{}, fmt.Errorf("%w: malformed token", chaterr.ErrUnauthenticated)
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return Principal{}, err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Principal{}, fmt.Errorf("%w: malformed signature", chaterr.ErrUnauthenticated)
+	}
+
+	key, err := v.key(ctx, header.Kid)
+	if err != nil {
+		return Principal{}, err
+	}
+	if err := verifySignature(header.Alg, key, parts[0]+"."+parts[1], signature); err != nil {
+		return Principal{}, err
+	}
+
+	var claims map[string]any
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return Principal{}, err
+	}
+	if err := v.checkClaims(claims); err != nil {
+		return Principal{}, err
+	}
+	return v.principal(claims)
+}
+
+// checkClaims checks the token's validity period, issuer and audience
+func (v *JWTVerifier) checkClaims(claims map[string]any) error {
+	now := v.now()
+	exp, ok := numericClaim(claims, "exp")
+	if !ok {
+		return fmt.Errorf("%w: token has no expiry", chaterr.ErrUnauthenticated)
+	}
+	if now.After(exp.Add(v.config.Leeway)) {
+		return fmt.Errorf("%w: token expired at %s", chaterr.ErrUnauthenticated, exp.Format(time.RFC3339))
+	}
+	if nbf, ok := numericClaim(claims, "nbf"); ok && now.Add(v.config.Leeway).Before(nbf) {
+		return fmt.Errorf("%w: token not valid before %s", chaterr.ErrUnauthenticated, nbf.Format(time.RFC3339))
+	}
+	if iat, ok := numericClaim(claims, "iat"); ok && now.Add(v.config.Leeway).Before(iat) {
+		return fmt.Errorf("%w: token issued in the future", chaterr.ErrUnauthenticated)
+	}
+
+	if v.config.Issuer != "" && claims["iss"] != v.config.Issuer {
+		return fmt.Errorf("%w: token issued by %v, not %s", chaterr.ErrUnauthenticated, claims["iss"], v.config.Issuer)
+	}
+	audience, ok := claims["aud"].([]any)
+	if !ok {
+		audience = []any{claims["aud"]}
+	}
+	if v.config.Audience != "" && !containsAny(audience, v.config.Audience) {
+		return fmt.Errorf("%w: token is not for audience %s", chaterr.ErrUnauthenticated, v.config.Audience)
+	}
+	return nil
+}
+
+// principal maps the token's claims to the caller
+func (v *JWTVerifier) principal(claims map[string]any) (Principal, error) {
+	id, _ := claims[v.config.SubjectClaim].(string)
+	if id == "" {
+		return Principal{}, fmt.Errorf("%w: token has no %s claim", chaterr.ErrUnauthenticated, v.config.SubjectClaim)
+	}
+	p := Principal{ID: id}
+	if v.config.AdminClaim != "" {
+		p.Admin, _ = claims[v.config.AdminClaim].(bool)
+	}
+
+	if v.config.TenantClaim != "" {
+		tenant, ok := claims[v.config.TenantClaim].(string)
+		if !ok {
+			return Principal{}, fmt.Errorf("%w: token has no %s claim", chaterr.ErrUnauthenticated, v.config.TenantClaim)
+		}
+		p.Tenant = tenant
+		p.Scopes = []string{ScopeRead, ScopeWrite}
+		if raw, ok := claims[v.config.ScopesClaim]; ok {
+			p.Scopes = []string{}
+			for _, scope := range stringsClaim(raw) {
+				if scope == ScopeRead || scope == ScopeWrite {
+					p.Scopes = append(p.Scopes, scope)
+				}
+			}
+		}
+	}
+	return p, nil
+}
+
+// key returns the signing key called kid, fetching the keys when they are
+// due a refresh or kid is unknown. Fetch failures keep the cached keys.
+func (v *JWTVerifier) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	now := v.now()
+	_, known := v.keys[kid]
+	stale := now.Sub(v.loaded) >= v.config.Refresh
+	if (stale || !known) && now.Sub(v.fetched) >= v.config.MinRefresh {
+		v.fetched = now
+		keys, err := v.fetch(ctx)
+		if err == nil {
+			v.keys, v.loaded = keys, now
+		} else if len(v.keys) == 0 {
+			return nil, err
+		}
+	}
+
+	if kid == "" && len(v.keys) == 1 {
+		for _, key := range v.keys {
+			return key, nil
+		}
+	}
+	key, ok := v.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown signing key %q", chaterr.ErrUnauthenticated, kid)
+	}
+	return key, nil
+}
+
+// jsonWebKey is the part of a JWK (RFC 7517) describing RSA and EC public
+// keys
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// fetch downloads the key set, skipping keys it can't use
+func (v *JWTVerifier) fetch(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.config.JWKSURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("auth: %w", err)
+	}
+	resp, err := v.config.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("auth: failed to fetch signing keys: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("auth: failed to fetch signing keys: %s", resp.Status)
+	}
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("auth: malformed signing keys: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey)
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		if key, err := jwk.publicKey(); err == nil {
+			keys[jwk.Kid] = key
+		}
+	}
+	return keys, nil
+}
+
+// publicKey decodes an RSA or EC public key
+func (k jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err1 := base64.RawURLEncoding.DecodeString(k.N)
+		e, err2 := base64.RawURLEncoding.DecodeString(k.E)
+		if err1 != nil || err2 != nil || len(e) > 4 {
+			return nil, errors.New("malformed RSA key")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %s", k.Crv)
+		}
+		x, err1 := base64.RawURLEncoding.DecodeString(k.X)
+		y, err2 := base64.RawURLEncoding.DecodeString(k.Y)
+		if err1 != nil || err2 != nil {
+			return nil, errors.New("malformed EC key")
+		}
+		key := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(key.X, key.Y) {
+			return nil, errors.New("EC key is not on its curve")
+		}
+		return key, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %s", k.Kty)
+	}
+}
+
+// verifySignature checks a signature made with alg, which must suit the
+// key's type ("none" and HMAC are refused)
+func verifySignature(alg string, key crypto.PublicKey, signed string, signature []byte) error {
+	var hash crypto.Hash
+	switch alg[min(2, len(alg)):] {
+	case "256":
+		hash = crypto.SHA256
+	case "384":
+		hash = crypto.SHA384
+	case "512":
+		hash = crypto.SHA512
+	default:
+		return fmt.Errorf("%w: unsupported algorithm %q", chaterr.ErrUnauthenticated, alg)
+	}
+	h := hash.New()
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+
+	var err error
+	switch pub := key.(type) {
+	case *rsa.PublicKey:
+		switch alg[:2] {
+		case "RS":
+			err = rsa.VerifyPKCS1v15(pub, hash, digest, signature)
+		case "PS":
+			err = rsa.VerifyPSS(pub, hash, digest, signature, nil)
+		default:
+			err = errors.New("algorithm doesn't suit an RSA key")
+		}
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if alg[:2] != "ES" || len(signature) != 2*size {
+			err = errors.New("algorithm or signature doesn't suit an EC key")
+			break
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(pub, digest, r, s) {
+			err = errors.New("bad signature")
+		}
+	default:
+		err = errors.New("unsupported key")
+	}
+	if err != nil {
+		return fmt.Errorf("%w: token signature: %v", chaterr.ErrUnauthenticated, err)
+	}
+	return nil
+}
+
+// decodeSegment decodes a base64url JSON segment of a token into v
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err == nil {
+		err = json.Unmarshal(data, v)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: malformed token", chaterr.ErrUnauthenticated)
+	}
+	return nil
+}
+
+// numericClaim returns a NumericDate claim (seconds since the epoch)
+func numericClaim(claims map[string]any, name string) (time.Time, bool) {
+	seconds, ok := claims[name].(float64)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(seconds), 0), true
+}
+
+// stringsClaim returns a claim that is a string, a space-separated list in
+// one string, or a list of strings
+func stringsClaim(raw any) []string {
+	switch v := raw.(type) {
+	case string:
+		return strings.Fields(v)
+	case []any:
+		var out []string
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	default:
+		return nil
+	}
+}
+
+func containsAny(values []any, want string) bool {
+	for _, v := range values {
+		if s, ok := v.(string); ok && s == want {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,245 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/pkg/chaterr"
+	"google.golang.org/grpc/metadata"
+)
+
+// testIdP serves a JWKS and signs tokens with its keys
+type testIdP struct {
+	mu      sync.Mutex
+	keys    map[string]crypto.Signer
+	fetches int
+	server  *httptest.Server
+}
+
+func newTestIdP(t *testing.T) *testIdP {
+	t.Helper()
+	idp := &testIdP{keys: make(map[string]crypto.Signer)}
+	idp.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		idp.mu.Lock()
+		defer idp.mu.Unlock()
+		idp.fetches++
+		var set struct {
+			Keys []jsonWebKey `json:"keys"`
+		}
+		for kid, key := range idp.keys {
+			jwk := jsonWebKey{Kid: kid, Use: "sig"}
+			switch pub := key.Public().(type) {
+			case *rsa.PublicKey:
+				jwk.Kty = "RSA"
+				jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+				jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+			case *ecdsa.PublicKey:
+				jwk.Kty, jwk.Crv = "EC", "P-256"
+				jwk.X = base64.RawURLEncoding.EncodeToString(pub.X.FillBytes(make([]byte, 32)))
+				jwk.Y = base64.RawURLEncoding.EncodeToString(pub.Y.FillBytes(make([]byte, 32)))
+			}
+			set.Keys = append(set.Keys, jwk)
+		}
+		json.NewEncoder(w).Encode(set)
+	}))
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+func (idp *testIdP) addKey(t *testing.T, kid string, ec bool) {
+	t.Helper()
+	var key crypto.Signer
+	var err error
+	if ec {
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	} else {
+		key, err = rsa.GenerateKey(rand.Reader, 2048)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp.mu.Lock()
+	idp.keys[kid] = key
+	idp.mu.Unlock()
+}
+
+func (idp *testIdP) fetchCount() int {
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	return idp.fetches
+}
+
+// sign makes a token with the key kid over claims
+func (idp *testIdP) sign(t *testing.T, kid string, claims map[string]any) string {
+	t.Helper()
+	idp.mu.Lock()
+	key := idp.keys[kid]
+	idp.mu.Unlock()
+
+	alg := "RS256"
+	if _, ok := key.(*ecdsa.PrivateKey); ok {
+		alg = "ES256"
+	}
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+
+	var signature []byte
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		signature, _ = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func claims(extra map[string]any) map[string]any {
+	c := map[string]any{
+		"iss": "https://idp.example.com",
+		"aud": "districhat",
+		"sub": "alice",
+		"exp": time.Now().Add(time.Hour).Unix(),
+		"iat": time.Now().Unix(),
+	}
+	for k, v := range extra {
+		c[k] = v
+	}
+	return c
+}
+
+func TestJWTVerify(t *testing.T) {
+	idp := newTestIdP(t)
+	idp.addKey(t, "rsa-1", false)
+	idp.addKey(t, "ec-1", true)
+	v, err := NewJWTVerifier(JWTConfig{
+		JWKSURL:     idp.server.URL,
+		Issuer:      "https://idp.example.com",
+		Audience:    "districhat",
+		TenantClaim: "tenant",
+		AdminClaim:  "chat_admin",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := v.Verify(context.Background(), idp.sign(t, "rsa-1", claims(map[string]any{"tenant": "premium"})))
+	if err != nil {
+		t.Fatalf("Expected the RS256 token accepted, got %v", err)
+	}
+	if p.ID != "alice" || p.Tenant != "premium" || !p.Allows(ScopeWrite, "premium") || p.Allows(ScopeRead, "") {
+		t.Errorf("Expected alice limited to premium, got %+v", p)
+	}
+
+	p, err = v.Verify(context.Background(), idp.sign(t, "ec-1", claims(map[string]any{
+		"tenant": "premium", "scope": "openid chat:read", "aud": []string{"other", "districhat"}, "chat_admin": true,
+	})))
+	if err != nil {
+		t.Fatalf("Expected the ES256 token accepted, got %v", err)
+	}
+	if !p.Admin || !p.Allows(ScopeRead, "premium") || p.Allows(ScopeWrite, "premium") {
+		t.Errorf("Expected an admin limited to reads, got %+v", p)
+	}
+
+	tampered := idp.sign(t, "rsa-1", claims(map[string]any{"tenant": "premium"}))
+	tampered = tampered[:len(tampered)-4] + "AAAA"
+	for name, token := range map[string]string{
+		"expired":      idp.sign(t, "rsa-1", claims(map[string]any{"tenant": "premium", "exp": time.Now().Add(-time.Hour).Unix()})),
+		"not yet":      idp.sign(t, "rsa-1", claims(map[string]any{"tenant": "premium", "nbf": time.Now().Add(time.Hour).Unix()})),
+		"issuer":       idp.sign(t, "rsa-1", claims(map[string]any{"tenant": "premium", "iss": "https://evil.example.com"})),
+		"audience":     idp.sign(t, "rsa-1", claims(map[string]any{"tenant": "premium", "aud": "other"})),
+		"no tenant":    idp.sign(t, "rsa-1", claims(nil)),
+		"tampered":     tampered,
+		"malformed":    "not.a.token",
+		"unsigned":     base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`)) + ".e30.",
+		"no such key":  "eyJhbGciOiJSUzI1NiIsImtpZCI6Im1pc3NpbmcifQ.e30.AAAA", // {"alg":"RS256","kid":"missing"}
+		"wrong family": "eyJhbGciOiJFUzI1NiIsImtpZCI6InJzYS0xIn0.e30.AAAA",    // {"alg":"ES256","kid":"rsa-1"}
+	} {
+		if _, err := v.Verify(context.Background(), token); !errors.Is(err, chaterr.ErrUnauthenticated) {
+			t.Errorf("Expected the %s token refused as unauthenticated, got %v", name, err)
+		}
+	}
+}
+
+func TestJWTKeyRotation(t *testing.T) {
+	idp := newTestIdP(t)
+	idp.addKey(t, "key-1", true)
+	v, err := NewJWTVerifier(JWTConfig{JWKSURL: idp.server.URL, Leeway: 5 * time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	v.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if _, err := v.Verify(context.Background(), idp.sign(t, "key-1", claims(nil))); err != nil {
+			t.Fatalf("Expected the token accepted, got %v", err)
+		}
+	}
+	if got := idp.fetchCount(); got != 1 {
+		t.Errorf("Expected the keys fetched once and cached, got %d fetches", got)
+	}
+
+	// A token signed by a rotated-in key refetches the keys, at most once
+	// a minute: another unknown key right after isn't looked for
+	idp.addKey(t, "key-2", true)
+	now = now.Add(2 * time.Minute)
+	if _, err := v.Verify(context.Background(), idp.sign(t, "key-2", claims(nil))); err != nil {
+		t.Fatalf("Expected the token signed by the new key accepted, got %v", err)
+	}
+	idp.addKey(t, "key-3", true)
+	if _, err := v.Verify(context.Background(), idp.sign(t, "key-3", claims(nil))); !errors.Is(err, chaterr.ErrUnauthenticated) {
+		t.Errorf("Expected a second unknown key refused until MinRefresh, got %v", err)
+	}
+	if got := idp.fetchCount(); got != 2 {
+		t.Errorf("Expected 2 fetches, got %d", got)
+	}
+
+	// The cached keys outlive the provider
+	idp.server.Close()
+	now = now.Add(2 * time.Hour)
+	if _, err := v.Verify(context.Background(), idp.sign(t, "key-1", claims(nil))); err != nil {
+		t.Errorf("Expected the cached key used while the provider is down, got %v", err)
+	}
+}
+
+func TestJWTAuthenticator(t *testing.T) {
+	idp := newTestIdP(t)
+	idp.addKey(t, "key-1", false)
+	v, err := NewJWTVerifier(JWTConfig{JWKSURL: idp.server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := idp.sign(t, "key-1", claims(nil))
+
+	md, err := BearerToken(func(context.Context) (string, error) { return token, nil }).GetRequestMetadata(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.New(md))
+	if p, err := JWT(v).Authenticate(ctx); err != nil || p.ID != "alice" || p.Scopes != nil {
+		t.Errorf("Expected unrestricted alice, got %+v, %v", p, err)
+	}
+	if _, err := JWT(v).Authenticate(context.Background()); !errors.Is(err, chaterr.ErrUnauthenticated) {
+		t.Errorf("Expected a call without a token refused, got %v", err)
+	}
+}
@@ -1,19 +1,42 @@
 // Package cache implements a hierarchical L1/L2 cache system
 // that simulates GPU VRAM (L1) and system RAM (L2) constraints.
 //
-// The cache uses LRU (Least Recently Used) eviction policy:
+// The cache uses LRU (Least Recently Used) eviction policy by default:
 // - When L1 is full, the LRU entry is demoted to L2
 // - When L2 is full, the LRU entry is evicted entirely
+//
+// WithEvictionPolicy(FIFO) instead demotes and evicts the entry that
+// entered the tier first, however recently it was used.
+//
+// L2 can instead be backed by a SharedTier (e.g. Redis), in which case
+// demoted sessions are written there and the local L2 only tracks their LRU
+// order.
+//
+// Below L2, a ColdTier (see SetColdTier) receives sessions evicted from L2
+// or idle past a threshold, and gives them back when they're accessed again.
 package cache
 
 import (
 	"container/list"
+	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"sort"
 	"sync"
 	"time"
+
+	"github.com/sh4shv4t/DistriChat/pkg/clock"
+	"github.com/sh4shv4t/DistriChat/pkg/events"
+	"github.com/sh4shv4t/DistriChat/pkg/logging"
+	"github.com/sh4shv4t/DistriChat/pkg/metrics"
+	"github.com/sh4shv4t/DistriChat/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
 )
 
+// tracer records cache operations that are given a traced context
+var tracer = tracing.Tracer("github.com/sh4shv4t/DistriChat/pkg/cache")
+
 // CacheLevel represents where data is stored
 type CacheLevel int
 
@@ -22,6 +45,7 @@ const (
 	LevelL1                 // Hot cache (GPU VRAM simulation)
 	LevelL2                 // Warm cache (RAM simulation)
 	LevelMiss               // Not in cache
+	LevelArchive            // Restored from the cold tier
 )
 
 func (l CacheLevel) String() string {
@@ -32,16 +56,101 @@ func (l CacheLevel) String() string {
 		return "L2 (RAM)"
 	case LevelMiss:
 		return "MISS"
+	case LevelArchive:
+		return "ARCHIVE (cold)"
 	default:
 		return "UNKNOWN"
 	}
 }
 
+// EvictionPolicy decides which session a full tier gives up
+type EvictionPolicy int
+
+const (
+	LRU  EvictionPolicy = iota // Least recently used (default)
+	FIFO                       // First in: accesses don't reorder a tier
+)
+
+func (p EvictionPolicy) String() string {
+	switch p {
+	case LRU:
+		return "LRU"
+	case FIFO:
+		return "FIFO"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// ContentType identifies the kind of payload a message carries
+type ContentType int
+
+const (
+	ContentText        ContentType = iota // Plain text (Content holds the text)
+	ContentAttachment                     // Attachment reference (see Message.Attachment)
+	ContentSystemEvent                    // System event (see Message.Event)
+)
+
+func (t ContentType) String() string {
+	switch t {
+	case ContentText:
+		return "TEXT"
+	case ContentAttachment:
+		return "ATTACHMENT"
+	case ContentSystemEvent:
+		return "SYSTEM_EVENT"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// Attachment references binary content stored outside the cache
+type Attachment struct {
+	URL       string
+	MimeType  string
+	SizeBytes int64
+	Filename  string
+}
+
+// System event types that change a chat's members (see Members)
+const (
+	EventChatCreated  = "SYSTEM_EVENT_CHAT_CREATED"
+	EventMemberJoined = "SYSTEM_EVENT_MEMBER_JOINED"
+	EventMemberLeft   = "SYSTEM_EVENT_MEMBER_LEFT"
+
+	// MemberDetail is the event detail naming the member who joined or
+	// left, when it isn't the actor (e.g. an invitation)
+	MemberDetail = "member_id"
+)
+
+// SystemEvent describes a system-generated chat message
+type SystemEvent struct {
+	Type    string
+	ActorID string
+	Details map[string]string
+}
+
 // Message represents a single chat message
 type Message struct {
+	ID        string // Unique message ID (empty for unreplicated messages)
+	Seq       uint64 // Position in the chat, assigned when the message is added
+	HLC       clock.Timestamp
+	Origin    string // Server that accepted the message
+	Counter   uint64 // Origin's update counter for the chat (the message's dot)
 	Content   string
 	SenderID  string
 	Timestamp time.Time
+	Federated string // Cluster a federation bridge relayed the message from
+
+	// Type selects which payload is set. The zero value is ContentText,
+	// so plain text messages only need Content.
+	Type       ContentType
+	Attachment *Attachment
+	Event      *SystemEvent
+
+	// Annotations set by the transform stages of the server that accepted
+	// the message, e.g. the kinds of data redacted
+	Annotations map[string]string
 }
 
 // ChatSession represents a cached chat conversation
@@ -51,12 +160,18 @@ type ChatSession struct {
 	LastAccessed time.Time
 	CreatedAt    time.Time
 	MessageCount int
+	LastSeq      uint64              // Highest sequence number held
+	Version      clock.VersionVector // Updates held, per origin
+	Floor        uint64              // Highest sequence number purged (see Purge)
 }
 
 // cacheEntry wraps a ChatSession with list element reference for LRU
 type cacheEntry struct {
 	session *ChatSession
 	element *list.Element
+
+	// Brought back from the cold tier and not yet accessed
+	restored bool
 }
 
 // HierarchicalCache implements a two-level cache with LRU eviction
@@ -73,11 +188,32 @@ type HierarchicalCache struct {
 	l2List     *list.List
 	l2Capacity int
 
+	// Which session a full tier gives up
+	policy EvictionPolicy
+
+	// External store backing L2 (nil keeps L2 sessions in local memory)
+	tier SharedTier
+
+	// Cold tier below L2 (nil drops evicted sessions). Sessions being
+	// written to it stay in archiving until the write completes; archiveMu
+	// orders the writes.
+	cold      ColdTier
+	archiving map[string]*ChatSession
+	archiveMu sync.Mutex
+
 	// Statistics
-	stats CacheStats
+	stats   CacheStats
+	metrics cacheMetrics
 
-	// Server ID for logging
+	// Clock session access times are read from
+	clock clock.Clock
+
+	// Bus evictions and demotions are published on
+	events *events.Bus
+
+	// Server the cache belongs to, named in its events
 	serverID string
+	log      *slog.Logger
 }
 
 // CacheStats tracks cache performance metrics
@@ -87,78 +223,527 @@ type CacheStats struct {
 	CacheMisses   int64
 	L1Hits        int64
 	L2Hits        int64
+	SharedHits    int64 // L2 hits on sessions another server stored in the shared tier
+	Archived      int64 // Sessions written to the cold tier
+	ArchiveHits   int64 // Misses served by restoring from the cold tier
 	Evictions     int64
 	Demotions     int64
+	Duplicates    int64 // Messages collapsed into a copy already held under the same ID
+}
+
+// ErrDuplicateMessage is returned by AppendMessage when the chat already
+// holds a message with the same ID, e.g. a client retry after failover
+var ErrDuplicateMessage = errors.New("message already stored")
+
+// Option adjusts a cache as NewHierarchicalCache creates it
+type Option func(*HierarchicalCache)
+
+// WithEvictionPolicy makes full tiers give up sessions by policy (default:
+// LRU)
+func WithEvictionPolicy(policy EvictionPolicy) Option {
+	return func(c *HierarchicalCache) { c.policy = policy }
+}
+
+// WithLogger makes the cache log to logger. By default it logs nothing,
+// so programs embedding it don't get its records on their output.
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *HierarchicalCache) { c.log = logger }
 }
 
 // NewHierarchicalCache creates a new two-level cache
-func NewHierarchicalCache(serverID string, l1Capacity, l2Capacity int) *HierarchicalCache {
-	return &HierarchicalCache{
+func NewHierarchicalCache(serverID string, l1Capacity, l2Capacity int, opts ...Option) *HierarchicalCache {
+	c := &HierarchicalCache{
 		l1Cache:    make(map[string]*cacheEntry),
 		l1List:     list.New(),
 		l1Capacity: l1Capacity,
 		l2Cache:    make(map[string]*cacheEntry),
 		l2List:     list.New(),
 		l2Capacity: l2Capacity,
+		metrics:    newCacheMetrics(metrics.Nop()),
+		clock:      clock.System(),
+		events:     events.Default(),
 		serverID:   serverID,
+		log:        logging.Nop(),
+	}
+	for _, opt := range opts {
+		opt(c)
 	}
+	return c
+}
+
+// NewSharedL2Cache creates a two-level cache whose L2 tier lives in a
+// shared external store. l2Capacity still bounds how many sessions this
+// cache keeps tracked in L2.
+func NewSharedL2Cache(serverID string, l1Capacity, l2Capacity int, tier SharedTier, opts ...Option) *HierarchicalCache {
+	c := NewHierarchicalCache(serverID, l1Capacity, l2Capacity, opts...)
+	c.tier = tier
+	return c
+}
+
+// SetClock makes the cache time session accesses, and so decide which
+// sessions are idle, by c (default: the system clock). It must be called
+// before the cache is used.
+func (c *HierarchicalCache) SetClock(clk clock.Clock) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.clock = clk
+}
+
+// SetEvents publishes the cache's evictions and demotions on bus (default:
+// events.Default()). It must be called before the cache is used.
+func (c *HierarchicalCache) SetEvents(bus *events.Bus) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.events = bus
 }
 
 // GetOrCreate retrieves a chat session from cache or creates a new one
 // Returns the session and which cache level it was found at
 func (c *HierarchicalCache) GetOrCreate(chatID string) (*ChatSession, CacheLevel) {
-	c.mu.Lock()
+	session, level, _ := c.getOrCreate(context.Background(), chatID)
+	return session, level
+}
+
+// getOrCreate is GetOrCreate, recording the lookup in ctx's trace and
+// reading the cold and shared tiers with ctx. If ctx ends during those
+// reads it returns ctx's error and leaves the chat as it was, rather than
+// take the chat for a miss and create it afresh.
+func (c *HierarchicalCache) getOrCreate(ctx context.Context, chatID string) (*ChatSession, CacheLevel, error) {
+	start := time.Now()
+	if err := c.restoreArchived(ctx, chatID); err != nil {
+		return nil, LevelMiss, err
+	}
+
+	c.lock(ctx)
 	defer c.mu.Unlock()
 
+	// Timed up to the return, so promotion, shared tier reads, restoring
+	// and creation count toward the tier that served the lookup. Lookups
+	// abandoned with ctx aren't counted.
+	var served string
+	defer func() {
+		if served == "" {
+			return
+		}
+		c.metrics.observeLookup(served, start)
+		TraceFrom(ctx).served(served)
+	}()
+
 	c.stats.TotalRequests++
 
 	// Check L1 first
 	if entry, ok := c.l1Cache[chatID]; ok {
+		if entry.restored {
+			entry.restored = false
+			c.stats.CacheMisses++
+			c.stats.ArchiveHits++
+			served = LevelArchive.Label()
+			entry.session.LastAccessed = c.clock.Now()
+			c.touch(entry)
+			return entry.session, LevelArchive, nil
+		}
+
 		c.stats.CacheHits++
 		c.stats.L1Hits++
-		entry.session.LastAccessed = time.Now()
-		c.l1List.MoveToFront(entry.element)
-		return entry.session, LevelL1
+		served = LevelL1.Label()
+		entry.session.LastAccessed = c.clock.Now()
+		c.touch(entry)
+		return entry.session, LevelL1, nil
 	}
 
 	// Check L2
 	if entry, ok := c.l2Cache[chatID]; ok {
-		c.stats.CacheHits++
-		c.stats.L2Hits++
-		entry.session.LastAccessed = time.Now()
-
-		// Promote from L2 to L1
-		c.promoteToL1(chatID, entry)
-		return entry.session, LevelL2
+		if session, ok := c.l2Session(ctx, chatID, entry); ok {
+			c.stats.CacheHits++
+			c.stats.L2Hits++
+			served = LevelL2.Label()
+			entry.session = session
+			entry.session.LastAccessed = c.clock.Now()
+
+			// Promote from L2 to L1
+			c.promoteToL1(chatID, entry)
+			return entry.session, LevelL2, nil
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, LevelMiss, err
+		}
+
+		// Expired from (or unreachable in) the shared tier
+		c.l2List.Remove(entry.element)
+		delete(c.l2Cache, chatID)
+	} else if c.tier != nil {
+		// Demoted by another server replicating the chat
+		if session, ok := c.loadShared(ctx, chatID); ok {
+			c.stats.CacheHits++
+			c.stats.L2Hits++
+			c.stats.SharedHits++
+			served = "shared"
+			session.LastAccessed = c.clock.Now()
+
+			c.addToL1(chatID, session)
+			c.log.DebugContext(ctx, "Loaded session from the shared L2 tier", logging.ChatID(chatID))
+			return session, LevelL2, nil
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, LevelMiss, err
+		}
 	}
 
 	// Cache miss - create new session
 	c.stats.CacheMisses++
+	served = LevelMiss.Label()
+	now := c.clock.Now()
 	session := &ChatSession{
 		ChatID:       chatID,
 		Messages:     make([]Message, 0),
-		LastAccessed: time.Now(),
-		CreatedAt:    time.Now(),
+		LastAccessed: now,
+		CreatedAt:    now,
 		MessageCount: 0,
+		Version:      make(clock.VersionVector),
 	}
 
 	// Add to L1
 	c.addToL1(chatID, session)
-	return session, LevelMiss
+	return session, LevelMiss, nil
 }
 
 // AddMessage adds a message to a chat session
 func (c *HierarchicalCache) AddMessage(chatID string, msg Message) (*ChatSession, CacheLevel, error) {
-	session, level := c.GetOrCreate(chatID)
+	_, session, level, err := c.AppendMessage(chatID, msg)
+	return session, level, err
+}
 
-	c.mu.Lock()
+// AppendMessage adds a locally accepted message, assigning it the next
+// sequence number and, if it has an Origin, the origin's next counter.
+// Returns the message as stored. If msg has an ID the chat already holds,
+// nothing is added and the held message is returned with
+// ErrDuplicateMessage.
+func (c *HierarchicalCache) AppendMessage(chatID string, msg Message) (Message, *ChatSession, CacheLevel, error) {
+	return c.AppendMessageContext(context.Background(), chatID, msg)
+}
+
+// AppendMessageContext is AppendMessage recorded as a span of the trace in
+// ctx, noting the tier the chat was found in
+func (c *HierarchicalCache) AppendMessageContext(ctx context.Context, chatID string, msg Message) (Message, *ChatSession, CacheLevel, error) {
+	_, span := tracer.Start(ctx, "cache.AppendMessage")
+	defer span.End()
+
+	session, level, err := c.getOrCreate(ctx, chatID)
+	if err != nil {
+		return Message{}, nil, LevelMiss, err
+	}
+	span.SetAttributes(
+		attribute.String("chat.id", chatID),
+		attribute.String("cache.level", level.String()),
+	)
+
+	c.lock(ctx)
 	defer c.mu.Unlock()
 
-	session.Messages = append(session.Messages, msg)
+	if msg.ID != "" {
+		if i := indexOf(session, msg.ID); i >= 0 {
+			c.stats.Duplicates++
+			c.metrics.duplicates.Inc()
+			return session.Messages[i], session, level, ErrDuplicateMessage
+		}
+	}
+
+	session.LastSeq++
+	msg.Seq = session.LastSeq
+	if msg.Origin != "" {
+		msg.Counter = session.Version.Increment(msg.Origin)
+	}
+	c.insertMessage(session, msg)
+
+	return msg, session, level, nil
+}
+
+// ApplyMessage stores a message that already has an ID and sequence number,
+// as received from another replica. Returns whether it was added.
+//
+// A message whose ID is already held is not added again. If it is a
+// different copy (the same write accepted by two servers, e.g. a client
+// retrying after failover), the copy ordered first by MessageLess is kept,
+// so every replica collapses the duplicate the same way.
+func (c *HierarchicalCache) ApplyMessage(chatID string, msg Message) (bool, error) {
+	return c.ApplyMessageContext(context.Background(), chatID, msg)
+}
+
+// ApplyMessageContext is ApplyMessage, recording the lookup and lock wait
+// in ctx's trace
+func (c *HierarchicalCache) ApplyMessageContext(ctx context.Context, chatID string, msg Message) (bool, error) {
+	if msg.ID == "" || msg.Seq == 0 {
+		return false, fmt.Errorf("replicated message requires an ID and sequence number")
+	}
+
+	session, _, err := c.getOrCreate(ctx, chatID)
+	if err != nil {
+		return false, err
+	}
+
+	c.lock(ctx)
+	defer c.mu.Unlock()
+
+	if i := indexOf(session, msg.ID); i >= 0 {
+		existing := session.Messages[i]
+		if existing.Origin == msg.Origin && existing.Counter == msg.Counter {
+			return false, nil // Same copy
+		}
+
+		c.stats.Duplicates++
+		c.metrics.duplicates.Inc()
+		if msg.Origin != "" {
+			session.Version.Observe(msg.Origin, msg.Counter)
+		}
+		if MessageLess(msg, existing) {
+			session.Messages = append(session.Messages[:i], session.Messages[i+1:]...)
+			session.MessageCount--
+			c.insertMessage(session, msg)
+		}
+		return false, nil
+	}
+
+	if msg.Seq > session.LastSeq {
+		session.LastSeq = msg.Seq
+	}
+	if msg.Origin != "" {
+		session.Version.Observe(msg.Origin, msg.Counter)
+	}
+	c.insertMessage(session, msg)
+
+	return true, nil
+}
+
+// indexOf returns the position of the message with the given ID, or -1
+// (must be called with lock held)
+func indexOf(session *ChatSession, id string) int {
+	for i, msg := range session.Messages {
+		if msg.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// insertMessage places msg in the session's ordering (must be called with
+// lock held). Local appends normally land at the end.
+func (c *HierarchicalCache) insertMessage(session *ChatSession, msg Message) {
+	idx := sort.Search(len(session.Messages), func(i int) bool {
+		return MessageLess(msg, session.Messages[i])
+	})
+	session.Messages = append(session.Messages, Message{})
+	copy(session.Messages[idx+1:], session.Messages[idx:])
+	session.Messages[idx] = msg
+
 	session.MessageCount++
-	session.LastAccessed = time.Now()
+	session.LastAccessed = c.clock.Now()
+}
+
+// MessageLess is the deterministic order every replica keeps messages in:
+// by hybrid timestamp, then sequence number, then ID. Concurrent writes
+// accepted by different replicas (e.g. during a partition) interleave the
+// same way everywhere instead of one overwriting the other.
+func MessageLess(a, b Message) bool {
+	if cmp := a.HLC.Compare(b.HLC); cmp != 0 {
+		return cmp < 0
+	}
+	if a.Seq != b.Seq {
+		return a.Seq < b.Seq
+	}
+	return a.ID < b.ID
+}
+
+// Version returns a copy of the chat's version vector (nil if not cached)
+func (c *HierarchicalCache) Version(chatID string) clock.VersionVector {
+	return c.VersionContext(context.Background(), chatID)
+}
+
+// VersionContext is Version, reading the cold and shared tiers with ctx
+// (nil if ctx ends first)
+func (c *HierarchicalCache) VersionContext(ctx context.Context, chatID string) clock.VersionVector {
+	if c.restoreArchived(ctx, chatID) != nil {
+		return nil
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if session, _, ok := c.peek(ctx, chatID); ok {
+		return session.Version.Copy()
+	}
+	return nil
+}
+
+// Members returns the chat's members, replaying its system events in
+// order: the creator of the chat is a member, and members join and leave
+// with MEMBER_JOINED and MEMBER_LEFT events. It is nil if the chat isn't
+// cached, and empty if no one joined it. It does not count as an access.
+func (c *HierarchicalCache) Members(chatID string) map[string]bool {
+	return c.MembersContext(context.Background(), chatID)
+}
+
+// MembersContext is Members, reading the cold and shared tiers with ctx
+// (nil if ctx ends first)
+func (c *HierarchicalCache) MembersContext(ctx context.Context, chatID string) map[string]bool {
+	if c.restoreArchived(ctx, chatID) != nil {
+		return nil
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	session, _, ok := c.peek(ctx, chatID)
+	if !ok {
+		return nil
+	}
+	members := make(map[string]bool)
+	for _, msg := range session.Messages {
+		if msg.Type != ContentSystemEvent || msg.Event == nil {
+			continue
+		}
+		member := msg.Event.ActorID
+		if id := msg.Event.Details[MemberDetail]; id != "" {
+			member = id
+		}
+		switch msg.Event.Type {
+		case EventChatCreated:
+			members[msg.Event.ActorID] = true
+		case EventMemberJoined:
+			members[member] = true
+		case EventMemberLeft:
+			delete(members, member)
+		}
+	}
+	return members
+}
+
+// History returns a copy of the most recent limit messages of a chat in
+// sequence order (all of them if limit <= 0). It does not count as an
+// access, though an archived chat is restored first.
+func (c *HierarchicalCache) History(chatID string, limit int) []Message {
+	return c.HistoryContext(context.Background(), chatID, limit)
+}
+
+// HistoryContext is History, recording the tier read and lock wait in
+// ctx's trace and reading the cold and shared tiers with ctx (nil if ctx
+// ends first)
+func (c *HierarchicalCache) HistoryContext(ctx context.Context, chatID string, limit int) []Message {
+	if c.restoreArchived(ctx, chatID) != nil {
+		return nil
+	}
+
+	c.rlock(ctx)
+	defer c.mu.RUnlock()
+
+	session, level, ok := c.peek(ctx, chatID)
+	TraceFrom(ctx).served(level.Label())
+	if !ok {
+		return nil
+	}
+
+	messages := session.Messages
+	if limit > 0 && len(messages) > limit {
+		messages = messages[len(messages)-limit:]
+	}
+	out := make([]Message, len(messages))
+	copy(out, messages)
+	return out
+}
+
+// Watermarks returns the highest sequence number up to which a chat's copy
+// is complete, and the highest one it holds. A gap between them means the
+// copy missed messages (e.g. writes that didn't replicate to it); messages
+// purged up to the session's Floor don't count as missed. It does not
+// count as an access.
+func (c *HierarchicalCache) Watermarks(chatID string) (contiguous, highest uint64) {
+	return c.WatermarksContext(context.Background(), chatID)
+}
+
+// WatermarksContext is Watermarks, reading the cold and shared tiers with
+// ctx (zeros if ctx ends first)
+func (c *HierarchicalCache) WatermarksContext(ctx context.Context, chatID string) (contiguous, highest uint64) {
+	if c.restoreArchived(ctx, chatID) != nil {
+		return 0, 0
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	session, _, ok := c.peek(ctx, chatID)
+	if !ok {
+		return 0, 0
+	}
+
+	held := make(map[uint64]bool, len(session.Messages))
+	for _, msg := range session.Messages {
+		held[msg.Seq] = true
+	}
+	// Purged messages aren't missing
+	contiguous = session.Floor
+	for held[contiguous+1] {
+		contiguous++
+	}
+	return contiguous, session.LastSeq
+}
 
-	return session, level, nil
+// HoldsMessage reports whether the chat's copy in L1 or L2 holds the
+// message with the given ID. Archived chats aren't loaded to check.
+func (c *HierarchicalCache) HoldsMessage(ctx context.Context, chatID, id string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	session, _, ok := c.peek(ctx, chatID)
+	return ok && indexOf(session, id) >= 0
+}
+
+// peek finds a session without counting an access or moving it between
+// tiers (must be called with lock held)
+func (c *HierarchicalCache) peek(ctx context.Context, chatID string) (*ChatSession, CacheLevel, bool) {
+	if entry, ok := c.l1Cache[chatID]; ok {
+		return entry.session, LevelL1, true
+	}
+	if entry, ok := c.l2Cache[chatID]; ok {
+		if session, ok := c.l2Session(ctx, chatID, entry); ok {
+			return session, LevelL2, true
+		}
+	} else if c.tier != nil {
+		if session, ok := c.loadShared(ctx, chatID); ok {
+			return session, LevelL2, true
+		}
+	}
+	return nil, LevelMiss, false
+}
+
+// l2Session returns an L2 entry's session, reading it from the shared tier
+// if it lives there (must be called with lock held)
+func (c *HierarchicalCache) l2Session(ctx context.Context, chatID string, entry *cacheEntry) (*ChatSession, bool) {
+	if entry.session != nil {
+		return entry.session, true
+	}
+	return c.loadShared(ctx, chatID)
+}
+
+// loadShared reads a session from the shared tier. Failures are logged and
+// reported as absent, so a broken tier degrades to cache misses; callers
+// check ctx to tell a read abandoned with it from a miss.
+func (c *HierarchicalCache) loadShared(ctx context.Context, chatID string) (*ChatSession, bool) {
+	session, ok, err := c.tier.Load(ctx, chatID)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, false
+		}
+		c.log.WarnContext(ctx, "Failed to load session from the shared L2 tier", logging.ChatID(chatID), logging.Err(err))
+		return nil, false
+	}
+	return session, ok
+}
+
+// touch records an access to an L1 entry in its eviction order: under LRU
+// it moves to the front, under FIFO it stays put (must be called with lock
+// held)
+func (c *HierarchicalCache) touch(entry *cacheEntry) {
+	if c.policy == LRU {
+		c.l1List.MoveToFront(entry.element)
+	}
 }
 
 // promoteToL1 moves an entry from L2 to L1 (must be called with lock held)
@@ -170,7 +755,7 @@ func (c *HierarchicalCache) promoteToL1(chatID string, entry *cacheEntry) {
 	// Add to L1
 	c.addToL1(chatID, entry.session)
 
-	log.Printf("[CACHE:%s] Promoted %s from L2 to L1", c.serverID, chatID)
+	c.log.Debug("Promoted session from L2 to L1", logging.ChatID(chatID))
 }
 
 // addToL1 adds a session to L1, potentially evicting/demoting existing entries
@@ -204,11 +789,13 @@ func (c *HierarchicalCache) demoteFromL1() {
 	delete(c.l1Cache, chatID)
 
 	c.stats.Demotions++
+	c.metrics.demotions.Inc()
+	c.events.Publish(events.Demotion{Time: c.clock.Now(), Server: c.serverID, ChatID: chatID})
 
 	// Add to L2
 	c.addToL2(chatID, entry.session)
 
-	log.Printf("[CACHE:%s] Demoted %s from L1 to L2", c.serverID, chatID)
+	c.log.Debug("Demoted session from L1 to L2", logging.ChatID(chatID))
 }
 
 // addToL2 adds a session to L2, potentially evicting existing entries
@@ -218,6 +805,21 @@ func (c *HierarchicalCache) addToL2(chatID string, session *ChatSession) {
 		c.evictFromL2()
 	}
 
+	// With a shared tier the session lives there and the local entry only
+	// tracks LRU order; if the write fails, the session stays local. The
+	// write isn't tied to the request whose lookup demoted the session.
+	if c.tier != nil {
+		start := time.Now()
+		err := c.tier.Store(context.Background(), session)
+		c.metrics.observeWrite("shared", start)
+		if err != nil {
+			c.log.Warn("Failed to store session in the shared L2 tier, keeping it local",
+				logging.ChatID(chatID), logging.Err(err))
+		} else {
+			session = nil
+		}
+	}
+
 	// Add to L2
 	elem := c.l2List.PushFront(chatID)
 	c.l2Cache[chatID] = &cacheEntry{
@@ -234,12 +836,22 @@ func (c *HierarchicalCache) evictFromL2() {
 	}
 
 	chatID := back.Value.(string)
+	entry := c.l2Cache[chatID]
 
 	c.l2List.Remove(back)
 	delete(c.l2Cache, chatID)
 	c.stats.Evictions++
-
-	log.Printf("[CACHE:%s] Evicted %s from L2 (to disk - simulated)", c.serverID, chatID)
+	c.metrics.evictions.Inc()
+
+	// A shared copy is left to the tier's own expiry, since other servers
+	// may still be using it
+	archived := c.cold != nil && entry.session != nil
+	if archived {
+		c.archiving[chatID] = entry.session
+		go c.archiveEvicted(chatID, entry.session)
+	}
+	c.events.Publish(events.CacheEviction{Time: c.clock.Now(), Server: c.serverID, ChatID: chatID, Archived: archived})
+	c.log.Debug("Evicted session from L2", logging.ChatID(chatID), "archived", archived)
 }
 
 // GetStats returns current cache statistics
@@ -286,21 +898,58 @@ type CacheInfo struct {
 	Stats      CacheStats
 }
 
-// GetSession retrieves a specific session if it exists
-func (c *HierarchicalCache) GetSession(chatID string) (*ChatSession, CacheLevel, bool) {
+// ResidentSession describes a session held in L1 or L2
+type ResidentSession struct {
+	ChatID       string
+	Level        CacheLevel
+	LastAccessed time.Time // Zero for sessions held in the shared tier
+	Messages     int
+	LastSeq      uint64
+	Shared       bool // Held in the shared L2 tier, not in local memory
+}
+
+// Resident lists the sessions in L1 and then L2, most recently used (under
+// FIFO, most recently added) first in each, without counting as accesses or reading the shared tier
+func (c *HierarchicalCache) Resident() []ResidentSession {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
-	if entry, ok := c.l1Cache[chatID]; ok {
-		return entry.session, LevelL1, true
+	resident := make([]ResidentSession, 0, len(c.l1Cache)+len(c.l2Cache))
+	tiers := []struct {
+		level   CacheLevel
+		list    *list.List
+		entries map[string]*cacheEntry
+	}{
+		{LevelL1, c.l1List, c.l1Cache},
+		{LevelL2, c.l2List, c.l2Cache},
 	}
-	if entry, ok := c.l2Cache[chatID]; ok {
-		return entry.session, LevelL2, true
+	for _, tier := range tiers {
+		for elem := tier.list.Front(); elem != nil; elem = elem.Next() {
+			chatID := elem.Value.(string)
+			s := ResidentSession{ChatID: chatID, Level: tier.level, Shared: true}
+			if session := tier.entries[chatID].session; session != nil {
+				s.LastAccessed = session.LastAccessed
+				s.Messages = len(session.Messages)
+				s.LastSeq = session.LastSeq
+				s.Shared = false
+			}
+			resident = append(resident, s)
+		}
 	}
-	return nil, LevelMiss, false
+	return resident
+}
+
+// GetSession retrieves a specific session if it exists. Sessions read from
+// a shared L2 tier are copies.
+func (c *HierarchicalCache) GetSession(chatID string) (*ChatSession, CacheLevel, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.peek(context.Background(), chatID)
 }
 
-// Clear empties both cache levels
+// Clear empties both cache levels. Sessions in a shared tier are left for
+// other servers.
 func (c *HierarchicalCache) Clear() {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -310,7 +959,30 @@ func (c *HierarchicalCache) Clear() {
 	c.l2Cache = make(map[string]*cacheEntry)
 	c.l2List = list.New()
 
-	log.Printf("[CACHE:%s] Cache cleared", c.serverID)
+	c.log.Info("Cache cleared")
+}
+
+// Resize changes the tier capacities at runtime. Shrinking L1 demotes its
+// least recently used sessions to L2; shrinking L2 evicts them.
+// Non-positive values leave the corresponding capacity unchanged.
+func (c *HierarchicalCache) Resize(l1Capacity, l2Capacity int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if l2Capacity > 0 {
+		c.l2Capacity = l2Capacity
+		for len(c.l2Cache) > c.l2Capacity {
+			c.evictFromL2()
+		}
+	}
+	if l1Capacity > 0 {
+		c.l1Capacity = l1Capacity
+		for len(c.l1Cache) > c.l1Capacity {
+			c.demoteFromL1()
+		}
+	}
+
+	c.log.Info("Resized", "l1_capacity", c.l1Capacity, "l2_capacity", c.l2Capacity)
 }
 
 // DebugPrint prints cache state for debugging
@@ -334,5 +1006,6 @@ func (c *HierarchicalCache) DebugPrint() {
 	fmt.Printf("Stats: Hits=%d (L1:%d, L2:%d), Misses=%d, Demotions=%d, Evictions=%d\n",
 		c.stats.CacheHits, c.stats.L1Hits, c.stats.L2Hits,
 		c.stats.CacheMisses, c.stats.Demotions, c.stats.Evictions)
-	fmt.Println("===========================\n")
+	fmt.Println("===========================")
+	fmt.Println()
 }
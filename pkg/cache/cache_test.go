@@ -1,9 +1,18 @@
 package cache
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"sync"
 	"testing"
 	"time"
+
+	"github.com/sh4shv4t/DistriChat/pkg/clock"
+	"github.com/sh4shv4t/DistriChat/pkg/events"
+	"github.com/sh4shv4t/DistriChat/pkg/metrics"
 )
 
 func TestNewHierarchicalCache(t *testing.T) {
@@ -71,6 +80,228 @@ func TestAddMessage(t *testing.T) {
 	}
 }
 
+func TestAppendAssignsSequence(t *testing.T) {
+	cache := NewHierarchicalCache("test", 5, 20)
+
+	for i := 1; i <= 3; i++ {
+		msg, _, _, err := cache.AppendMessage("chat-1", Message{Content: fmt.Sprintf("msg-%d", i)})
+		if err != nil {
+			t.Fatalf("AppendMessage failed: %v", err)
+		}
+		if msg.Seq != uint64(i) {
+			t.Errorf("Expected seq %d, got %d", i, msg.Seq)
+		}
+	}
+}
+
+func TestApplyMessage(t *testing.T) {
+	cache := NewHierarchicalCache("test", 5, 20)
+
+	// Replicated messages may arrive out of order and more than once
+	for _, seq := range []uint64{2, 3, 1, 2} {
+		msg := Message{ID: fmt.Sprintf("m-%d", seq), Seq: seq, Content: fmt.Sprintf("msg-%d", seq)}
+		if _, err := cache.ApplyMessage("chat-1", msg); err != nil {
+			t.Fatalf("ApplyMessage failed: %v", err)
+		}
+	}
+
+	history := cache.History("chat-1", 0)
+	if len(history) != 3 {
+		t.Fatalf("Expected 3 messages after deduplication, got %d", len(history))
+	}
+	for i, msg := range history {
+		if msg.Seq != uint64(i+1) {
+			t.Errorf("Expected seq %d at position %d, got %d", i+1, i, msg.Seq)
+		}
+	}
+
+	// Local appends continue after the highest replicated sequence
+	msg, _, _, _ := cache.AppendMessage("chat-1", Message{Content: "next"})
+	if msg.Seq != 4 {
+		t.Errorf("Expected seq 4, got %d", msg.Seq)
+	}
+
+	if _, err := cache.ApplyMessage("chat-1", Message{Content: "no id"}); err == nil {
+		t.Error("Expected error for message without ID")
+	}
+}
+
+func TestDuplicateWritesCollapse(t *testing.T) {
+	a := NewHierarchicalCache("a", 5, 20)
+	b := NewHierarchicalCache("b", 5, 20)
+
+	// A retried write lands on the same server again
+	first, _, _, err := a.AppendMessage("chat-1", Message{ID: "client-1", Origin: "a", HLC: clock.Timestamp{WallTime: 10}})
+	if err != nil {
+		t.Fatalf("AppendMessage failed: %v", err)
+	}
+	again, _, _, err := a.AppendMessage("chat-1", Message{ID: "client-1", Origin: "a", HLC: clock.Timestamp{WallTime: 20}})
+	if !errors.Is(err, ErrDuplicateMessage) || again.Seq != first.Seq {
+		t.Errorf("Expected the held message back with ErrDuplicateMessage, got seq %d, %v", again.Seq, err)
+	}
+
+	// The same write was also accepted by b after failover
+	other, _, _, _ := b.AppendMessage("chat-1", Message{ID: "client-1", Origin: "b", HLC: clock.Timestamp{WallTime: 15}})
+	a.ApplyMessage("chat-1", other)
+	b.ApplyMessage("chat-1", first)
+
+	historyA, historyB := a.History("chat-1", 0), b.History("chat-1", 0)
+	if len(historyA) != 1 || len(historyB) != 1 {
+		t.Fatalf("Expected one copy on both sides, got %d and %d", len(historyA), len(historyB))
+	}
+	if historyA[0].Origin != "a" || historyB[0].Origin != "a" {
+		t.Errorf("Expected both sides to keep the earlier copy, got %s and %s", historyA[0].Origin, historyB[0].Origin)
+	}
+	if a.GetStats().Duplicates != 2 || b.GetStats().Duplicates != 1 {
+		t.Errorf("Expected 2 and 1 duplicates, got %d and %d", a.GetStats().Duplicates, b.GetStats().Duplicates)
+	}
+}
+
+func TestWatermarks(t *testing.T) {
+	cache := NewHierarchicalCache("test", 5, 20)
+
+	if served, known := cache.Watermarks("chat-1"); served != 0 || known != 0 {
+		t.Errorf("Expected 0/0 for an unknown chat, got %d/%d", served, known)
+	}
+
+	// Messages 3 and 4 never arrived
+	for _, seq := range []uint64{1, 2, 5, 6} {
+		cache.ApplyMessage("chat-1", Message{ID: fmt.Sprintf("m-%d", seq), Seq: seq})
+	}
+	if served, known := cache.Watermarks("chat-1"); served != 2 || known != 6 {
+		t.Errorf("Expected complete up to 2 of 6, got %d/%d", served, known)
+	}
+
+	for _, seq := range []uint64{3, 4} {
+		cache.ApplyMessage("chat-1", Message{ID: fmt.Sprintf("m-%d", seq), Seq: seq})
+	}
+	if served, known := cache.Watermarks("chat-1"); served != 6 || known != 6 {
+		t.Errorf("Expected complete up to 6 of 6 after repair, got %d/%d", served, known)
+	}
+}
+
+func TestConcurrentWritesConverge(t *testing.T) {
+	a := NewHierarchicalCache("a", 5, 20)
+	b := NewHierarchicalCache("b", 5, 20)
+
+	// Both sides of a partition accept writes with the same sequence numbers
+	var fromA, fromB []Message
+	for i := 1; i <= 2; i++ {
+		msg, _, _, _ := a.AppendMessage("chat-1", Message{
+			ID: fmt.Sprintf("a-%d", i), Origin: "a", HLC: clock.Timestamp{WallTime: int64(10 * i)},
+		})
+		fromA = append(fromA, msg)
+		msg, _, _, _ = b.AppendMessage("chat-1", Message{
+			ID: fmt.Sprintf("b-%d", i), Origin: "b", HLC: clock.Timestamp{WallTime: int64(10*i + 5)},
+		})
+		fromB = append(fromB, msg)
+	}
+
+	if order := a.Version("chat-1").Compare(b.Version("chat-1")); order != clock.Concurrent {
+		t.Errorf("Expected concurrent versions during the partition, got %s", order)
+	}
+
+	// Heal: each side applies the other's writes
+	for _, msg := range fromB {
+		a.ApplyMessage("chat-1", msg)
+	}
+	for _, msg := range fromA {
+		b.ApplyMessage("chat-1", msg)
+	}
+
+	historyA, historyB := a.History("chat-1", 0), b.History("chat-1", 0)
+	if len(historyA) != 4 || len(historyB) != 4 {
+		t.Fatalf("Expected 4 messages on both sides, got %d and %d", len(historyA), len(historyB))
+	}
+	for i := range historyA {
+		if historyA[i].ID != historyB[i].ID {
+			t.Errorf("Histories diverge at %d: %s vs %s", i, historyA[i].ID, historyB[i].ID)
+		}
+	}
+	if historyA[0].ID != "a-1" || historyA[1].ID != "b-1" {
+		t.Errorf("Expected writes interleaved by timestamp, got %s, %s", historyA[0].ID, historyA[1].ID)
+	}
+	if order := a.Version("chat-1").Compare(b.Version("chat-1")); order != clock.Equal {
+		t.Errorf("Expected equal versions after healing, got %s", order)
+	}
+}
+
+func TestHistoryLimit(t *testing.T) {
+	cache := NewHierarchicalCache("test", 5, 20)
+	for i := 0; i < 5; i++ {
+		cache.AddMessage("chat-1", Message{Content: fmt.Sprintf("msg-%d", i)})
+	}
+
+	history := cache.History("chat-1", 2)
+	if len(history) != 2 {
+		t.Fatalf("Expected 2 messages, got %d", len(history))
+	}
+	if history[0].Content != "msg-3" || history[1].Content != "msg-4" {
+		t.Errorf("Expected the two most recent messages, got %q and %q", history[0].Content, history[1].Content)
+	}
+	if cache.History("missing", 0) != nil {
+		t.Error("Expected nil history for unknown chat")
+	}
+}
+
+func TestMembers(t *testing.T) {
+	cache := NewHierarchicalCache("test", 5, 20)
+	event := func(eventType, actor, member string) Message {
+		msg := Message{Type: ContentSystemEvent, Event: &SystemEvent{Type: eventType, ActorID: actor}}
+		if member != "" {
+			msg.Event.Details = map[string]string{MemberDetail: member}
+		}
+		return msg
+	}
+
+	cache.AddMessage("chat-1", event(EventChatCreated, "alice", ""))
+	cache.AddMessage("chat-1", event(EventMemberJoined, "alice", "bob"))
+	cache.AddMessage("chat-1", event(EventMemberJoined, "carol", ""))
+	cache.AddMessage("chat-1", Message{Content: "hello", SenderID: "carol"})
+	cache.AddMessage("chat-1", event(EventMemberLeft, "alice", ""))
+
+	members := cache.Members("chat-1")
+	if len(members) != 2 || !members["bob"] || !members["carol"] {
+		t.Errorf("Expected members bob and carol, got %v", members)
+	}
+
+	cache.AddMessage("chat-2", Message{Content: "no events"})
+	if members := cache.Members("chat-2"); members == nil || len(members) != 0 {
+		t.Errorf("Expected no members of a chat without events, got %v", members)
+	}
+	if cache.Members("missing") != nil {
+		t.Error("Expected nil members for unknown chat")
+	}
+}
+
+func TestAddMessageAttachment(t *testing.T) {
+	cache := NewHierarchicalCache("test", 5, 20)
+
+	msg := Message{
+		SenderID:  "user-1",
+		Timestamp: time.Now(),
+		Type:      ContentAttachment,
+		Attachment: &Attachment{
+			URL:       "https://files.example.com/cat.png",
+			MimeType:  "image/png",
+			SizeBytes: 2048,
+		},
+	}
+
+	session, _, err := cache.AddMessage("chat-1", msg)
+	if err != nil {
+		t.Fatalf("AddMessage failed: %v", err)
+	}
+
+	stored := session.Messages[0]
+	if stored.Type != ContentAttachment {
+		t.Errorf("Expected attachment content type, got %v", stored.Type)
+	}
+	if stored.Attachment == nil || stored.Attachment.MimeType != "image/png" {
+		t.Errorf("Expected attachment metadata to be preserved, got %+v", stored.Attachment)
+	}
+}
+
 func TestL1Demotion(t *testing.T) {
 	cache := NewHierarchicalCache("test", 3, 10)
 
@@ -100,6 +331,26 @@ func TestL1Demotion(t *testing.T) {
 	}
 }
 
+func TestFIFOEviction(t *testing.T) {
+	for _, policy := range []EvictionPolicy{LRU, FIFO} {
+		cache := NewHierarchicalCache("test", 2, 10, WithEvictionPolicy(policy))
+		cache.GetOrCreate("chat-0")
+		cache.GetOrCreate("chat-1")
+
+		// Under LRU the access saves chat-0; under FIFO it is still first in
+		cache.GetOrCreate("chat-0")
+		cache.GetOrCreate("chat-2")
+
+		demoted := "chat-1"
+		if policy == FIFO {
+			demoted = "chat-0"
+		}
+		if _, level, _ := cache.GetSession(demoted); level != LevelL2 {
+			t.Errorf("Expected %s demoted to L2 under %s, got %s", demoted, policy, level)
+		}
+	}
+}
+
 func TestL2Eviction(t *testing.T) {
 	cache := NewHierarchicalCache("test", 2, 3)
 
@@ -129,6 +380,71 @@ func TestL2Eviction(t *testing.T) {
 	}
 }
 
+func TestResident(t *testing.T) {
+	cache := NewHierarchicalCache("test", 2, 5)
+	for i := 0; i < 4; i++ {
+		cache.AddMessage(fmt.Sprintf("chat-%d", i), Message{Content: "hello"})
+	}
+
+	resident := cache.Resident()
+	want := []struct {
+		chatID string
+		level  CacheLevel
+	}{{"chat-3", LevelL1}, {"chat-2", LevelL1}, {"chat-1", LevelL2}, {"chat-0", LevelL2}}
+	if len(resident) != len(want) {
+		t.Fatalf("Expected %d resident sessions, got %+v", len(want), resident)
+	}
+	for i, w := range want {
+		s := resident[i]
+		if s.ChatID != w.chatID || s.Level != w.level {
+			t.Errorf("Expected %s in %v at %d, got %s in %v", w.chatID, w.level, i, s.ChatID, s.Level)
+		}
+		if s.Messages != 1 || s.LastAccessed.IsZero() || s.Shared {
+			t.Errorf("Expected %s's details, got %+v", w.chatID, s)
+		}
+	}
+	if stats := cache.GetStats(); stats.TotalRequests != 4 {
+		t.Errorf("Expected listing not to count as requests, got %d", stats.TotalRequests)
+	}
+}
+
+func TestEvictionEvents(t *testing.T) {
+	bus := events.NewBus()
+	var published []events.Event
+	sub := bus.Subscribe(func(e events.Event) { published = append(published, e) })
+
+	cache := NewHierarchicalCache("test", 1, 1)
+	cache.SetEvents(bus)
+	for i := 0; i < 3; i++ {
+		cache.GetOrCreate(fmt.Sprintf("chat-%d", i))
+	}
+	sub.Close()
+	<-sub.Done()
+
+	// chat-0 is demoted for chat-1, then chat-1 for chat-2, evicting chat-0
+	want := []events.Event{
+		events.Demotion{Server: "test", ChatID: "chat-0"},
+		events.Demotion{Server: "test", ChatID: "chat-1"},
+		events.CacheEviction{Server: "test", ChatID: "chat-0"},
+	}
+	if len(published) != len(want) {
+		t.Fatalf("Expected %d events, got %+v", len(want), published)
+	}
+	for i, e := range published {
+		switch e := e.(type) {
+		case events.Demotion:
+			e.Time = time.Time{}
+			published[i] = e
+		case events.CacheEviction:
+			e.Time = time.Time{}
+			published[i] = e
+		}
+		if published[i] != want[i] {
+			t.Errorf("Expected event %d to be %+v, got %+v", i, want[i], published[i])
+		}
+	}
+}
+
 func TestL2Promotion(t *testing.T) {
 	cache := NewHierarchicalCache("test", 2, 10)
 
@@ -198,6 +514,73 @@ func TestCacheStats(t *testing.T) {
 	}
 }
 
+func TestCacheMetrics(t *testing.T) {
+	cache := NewHierarchicalCache("test", 1, 1)
+	reg := metrics.NewPrometheus(nil)
+	cache.SetMetrics(reg)
+
+	cache.GetOrCreate("chat-1") // Miss
+	cache.GetOrCreate("chat-1") // L1 hit
+	cache.GetOrCreate("chat-2") // Miss, demotes chat-1
+	cache.GetOrCreate("chat-1") // L2 hit, demotes chat-2
+
+	var buf bytes.Buffer
+	if err := reg.Write(&buf); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	out := buf.String()
+
+	for _, want := range []string{
+		`districhat_cache_lookups_total{cache_level="miss"} 2`,
+		`districhat_cache_lookups_total{cache_level="l1"} 1`,
+		`districhat_cache_lookups_total{cache_level="l2"} 1`,
+		`districhat_cache_demotions_total 2`,
+		`districhat_cache_l1_sessions 1`,
+		`districhat_cache_l2_sessions 1`,
+		`districhat_cache_l1_capacity 1`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %q in output, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestCacheLatencyMetrics(t *testing.T) {
+	cold := &memoryCold{sessions: make(map[string]*ChatSession)}
+	cache := NewHierarchicalCache("test", 1, 1)
+	cache.SetColdTier(cold)
+	reg := metrics.NewPrometheus(nil)
+	cache.SetMetrics(reg)
+
+	cache.GetOrCreate("chat-1") // Miss
+	cache.GetOrCreate("chat-1") // L1 hit
+	cache.GetOrCreate("chat-2") // Miss, demotes chat-1
+	cache.GetOrCreate("chat-3") // Miss, evicts chat-1 to the cold tier
+
+	deadline := time.Now().Add(time.Second)
+	for cache.GetStats().Archived == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	cache.GetOrCreate("chat-1") // Restored from the cold tier
+
+	var buf bytes.Buffer
+	if err := reg.Write(&buf); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	out := buf.String()
+
+	for _, want := range []string{
+		`districhat_cache_lookup_duration_seconds_count{cache_level="miss"} 3`,
+		`districhat_cache_lookup_duration_seconds_count{cache_level="l1"} 1`,
+		`districhat_cache_lookup_duration_seconds_count{cache_level="archive"} 1`,
+		`districhat_cache_write_duration_seconds_count{tier="cold"} `,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %q in output, got:\n%s", want, out)
+		}
+	}
+}
+
 func TestClear(t *testing.T) {
 	cache := NewHierarchicalCache("test", 5, 20)
 
@@ -241,6 +624,34 @@ func TestGetSession(t *testing.T) {
 	}
 }
 
+func TestResize(t *testing.T) {
+	cache := NewHierarchicalCache("test", 4, 4)
+
+	for i := 0; i < 8; i++ {
+		cache.GetOrCreate(fmt.Sprintf("chat-%d", i))
+	}
+
+	cache.Resize(2, 3)
+
+	info := cache.GetCacheInfo()
+	if info.L1Capacity != 2 || info.L2Capacity != 3 {
+		t.Errorf("Expected capacities 2/3, got %d/%d", info.L1Capacity, info.L2Capacity)
+	}
+	if info.L1Size != 2 {
+		t.Errorf("Expected L1 size 2 after shrink, got %d", info.L1Size)
+	}
+	if info.L2Size != 3 {
+		t.Errorf("Expected L2 size 3 after shrink, got %d", info.L2Size)
+	}
+
+	// Most recently used sessions must survive in L1
+	for _, chatID := range []string{"chat-6", "chat-7"} {
+		if _, level, _ := cache.GetSession(chatID); level != LevelL1 {
+			t.Errorf("Expected %s in L1, got %v", chatID, level)
+		}
+	}
+}
+
 func BenchmarkGetOrCreate(b *testing.B) {
 	cache := NewHierarchicalCache("test", 5, 20)
 
@@ -266,3 +677,275 @@ func BenchmarkAddMessage(b *testing.B) {
 		cache.AddMessage(chatID, msg)
 	}
 }
+
+// failingTier is a SharedTier that is always unreachable
+type failingTier struct{}
+
+func (failingTier) Load(context.Context, string) (*ChatSession, bool, error) {
+	return nil, false, fmt.Errorf("unreachable")
+}
+
+func (failingTier) Store(context.Context, *ChatSession) error {
+	return fmt.Errorf("unreachable")
+}
+
+func TestSharedL2Tier(t *testing.T) {
+	tier := NewMemoryTier()
+	a := NewSharedL2Cache("a", 1, 10, tier)
+	b := NewSharedL2Cache("b", 1, 10, tier)
+
+	a.AddMessage("chat-1", Message{ID: "m1", Content: "hello"})
+	a.GetOrCreate("chat-2") // Demotes chat-1 into the shared tier
+
+	if tier.Len() != 1 {
+		t.Fatalf("Expected chat-1 in the shared tier, got %d sessions", tier.Len())
+	}
+	if history := a.History("chat-1", 0); len(history) != 1 || history[0].Content != "hello" {
+		t.Errorf("Expected chat-1's history from the shared tier, got %v", history)
+	}
+
+	// Another server finds the session warm
+	session, level := b.GetOrCreate("chat-1")
+	if level != LevelL2 {
+		t.Errorf("Expected L2 hit from the shared tier, got %v", level)
+	}
+	if len(session.Messages) != 1 || session.Version == nil {
+		t.Errorf("Expected the stored session, got %+v", session)
+	}
+	if stats := b.GetStats(); stats.SharedHits != 1 || stats.CacheMisses != 0 {
+		t.Errorf("Expected 1 shared hit and no misses, got %+v", stats)
+	}
+
+	// The demoting server promotes it back from the tier too
+	if _, level := a.GetOrCreate("chat-1"); level != LevelL2 {
+		t.Errorf("Expected L2 hit on promotion, got %v", level)
+	}
+}
+
+func TestSharedL2TierFailureKeepsSessionsLocal(t *testing.T) {
+	cache := NewSharedL2Cache("test", 1, 10, failingTier{})
+
+	cache.AddMessage("chat-1", Message{ID: "m1", Content: "hello"})
+	cache.GetOrCreate("chat-2")
+
+	session, level := cache.GetOrCreate("chat-1")
+	if level != LevelL2 {
+		t.Errorf("Expected local L2 hit, got %v", level)
+	}
+	if len(session.Messages) != 1 {
+		t.Errorf("Expected the session to survive the failed write, got %d messages", len(session.Messages))
+	}
+
+	if _, level := cache.GetOrCreate("chat-3"); level != LevelMiss {
+		t.Errorf("Expected a miss when the tier is unreachable, got %v", level)
+	}
+}
+
+// memoryCold is an in-memory ColdTier
+type memoryCold struct {
+	mu       sync.Mutex
+	sessions map[string]*ChatSession
+}
+
+func (m *memoryCold) Archive(ctx context.Context, session *ChatSession) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessions[session.ChatID] = copySession(session)
+	return nil
+}
+
+func (m *memoryCold) Restore(ctx context.Context, chatID string) (*ChatSession, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, false, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	session, ok := m.sessions[chatID]
+	if !ok {
+		return nil, false, nil
+	}
+	return copySession(session), true, nil
+}
+
+func TestEvictionArchivesToColdTier(t *testing.T) {
+	cold := &memoryCold{sessions: make(map[string]*ChatSession)}
+	cache := NewHierarchicalCache("test", 1, 1)
+	cache.SetColdTier(cold)
+
+	cache.AddMessage("chat-1", Message{ID: "m1", Content: "hello"})
+	cache.GetOrCreate("chat-2")
+	cache.GetOrCreate("chat-3") // Evicts chat-1 from L2
+
+	deadline := time.Now().Add(time.Second)
+	for cache.GetStats().Archived == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if cache.GetStats().Archived != 1 {
+		t.Fatal("Expected the evicted chat to be archived")
+	}
+
+	session, level := cache.GetOrCreate("chat-1")
+	if level != LevelArchive {
+		t.Errorf("Expected chat-1 restored from the cold tier, got %v", level)
+	}
+	if len(session.Messages) != 1 || session.Messages[0].Content != "hello" {
+		t.Errorf("Expected chat-1's message back, got %v", session.Messages)
+	}
+	if _, level := cache.GetOrCreate("chat-1"); level != LevelL1 {
+		t.Errorf("Expected an L1 hit after the restore, got %v", level)
+	}
+	if stats := cache.GetStats(); stats.ArchiveHits != 1 {
+		t.Errorf("Expected 1 archive hit, got %d", stats.ArchiveHits)
+	}
+}
+
+func TestArchiveIdleKeepsActiveChats(t *testing.T) {
+	cold := &memoryCold{sessions: make(map[string]*ChatSession)}
+	cache := NewHierarchicalCache("test", 5, 20)
+	cache.SetColdTier(cold)
+
+	cache.AddMessage("idle", Message{ID: "m1"})
+	time.Sleep(10 * time.Millisecond)
+	cache.AddMessage("active", Message{ID: "m2"})
+
+	archived := cache.ArchiveIdle(5 * time.Millisecond)
+	if len(archived) != 1 || archived[0] != "idle" {
+		t.Errorf("Expected only the idle chat archived, got %v", archived)
+	}
+	if _, _, ok := cache.GetSession("idle"); ok {
+		t.Error("Expected the archived chat to leave the cache")
+	}
+	if history := cache.History("idle", 0); len(history) != 1 {
+		t.Errorf("Expected History to restore the archived chat, got %v", history)
+	}
+}
+
+func TestArchiveIdleFunc(t *testing.T) {
+	clk := clock.NewVirtual(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	cache := NewHierarchicalCache("test", 5, 20)
+	cache.SetClock(clk)
+	cache.SetColdTier(&memoryCold{sessions: make(map[string]*ChatSession)})
+
+	cache.AddMessage("short", Message{ID: "m1"})
+	cache.AddMessage("long", Message{ID: "m2"})
+	clk.Sleep(time.Hour)
+
+	archived := cache.ArchiveIdleFunc(context.Background(), func(chatID string) time.Duration {
+		if chatID == "short" {
+			return time.Minute
+		}
+		return 24 * time.Hour
+	})
+	if len(archived) != 1 || archived[0] != "short" {
+		t.Errorf("Expected only the chat with the short idle time archived, got %v", archived)
+	}
+}
+
+func TestPurgeBefore(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cold := &memoryCold{sessions: make(map[string]*ChatSession)}
+	cache := NewHierarchicalCache("test", 5, 20)
+	cache.SetColdTier(cold)
+
+	for _, chatID := range []string{"cached", "archived"} {
+		cache.AddMessage(chatID, Message{ID: "created", Type: ContentSystemEvent, Timestamp: start,
+			Event: &SystemEvent{Type: EventChatCreated, ActorID: "alice"}})
+		for i := 1; i <= 4; i++ {
+			cache.AddMessage(chatID, Message{ID: fmt.Sprintf("m%d", i), Timestamp: start.Add(time.Duration(i) * time.Hour)})
+		}
+	}
+	time.Sleep(5 * time.Millisecond)
+	cache.ArchiveIdle(time.Millisecond)
+	cache.AddMessage("cached", Message{ID: "m5", Timestamp: start.Add(5 * time.Hour)}) // Restored, archived copy kept
+
+	cutoff := start.Add(3 * time.Hour)
+	lastSeq := map[string]uint64{"cached": 6, "archived": 5}
+	for _, chatID := range []string{"cached", "archived"} {
+		removed, err := cache.PurgeBefore(context.Background(), chatID, cutoff)
+		if err != nil || len(removed) != 2 {
+			t.Fatalf("Expected m1 and m2 purged from %s, got %v, %v", chatID, removed, err)
+		}
+		if !cache.Members(chatID)["alice"] {
+			t.Errorf("Expected %s to keep its members", chatID)
+		}
+		if contiguous, _ := cache.Watermarks(chatID); contiguous != lastSeq[chatID] {
+			t.Errorf("Expected %s's purged messages not to count as missing, got contiguous %d", chatID, contiguous)
+		}
+		if session := cold.sessions[chatID]; len(session.Messages) != 3 || session.Floor != 3 {
+			t.Errorf("Expected %s's archived copy purged too, got %d messages (floor %d)", chatID, len(session.Messages), session.Floor)
+		}
+	}
+	if history := cache.History("cached", 0); len(history) != 4 || history[1].ID != "m3" {
+		t.Errorf("Expected the event, m3, m4 and m5 left, got %v", history)
+	}
+	if removed, _ := cache.PurgeBefore(context.Background(), "cached", cutoff); len(removed) != 0 {
+		t.Errorf("Expected nothing left to purge, got %v", removed)
+	}
+	if removed, err := cache.PurgeBefore(context.Background(), "unknown", cutoff); len(removed) != 0 || err != nil {
+		t.Errorf("Expected an unknown chat left alone, got %v, %v", removed, err)
+	}
+}
+
+func TestCanceledLookupLeavesChatsAlone(t *testing.T) {
+	cold := &memoryCold{sessions: make(map[string]*ChatSession)}
+	tier := NewMemoryTier()
+	cache := NewSharedL2Cache("test", 1, 10, tier)
+	cache.SetColdTier(cold)
+
+	cache.AddMessage("archived", Message{ID: "m1", Content: "hello"})
+	time.Sleep(5 * time.Millisecond)
+	cache.ArchiveIdle(time.Millisecond)
+	cache.AddMessage("shared", Message{ID: "m2", Content: "hi"})
+	cache.GetOrCreate("other") // Demotes shared into the shared tier
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, chatID := range []string{"archived", "shared"} {
+		if _, _, _, err := cache.AppendMessageContext(ctx, chatID, Message{ID: "m3"}); !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected context.Canceled appending to %s, got %v", chatID, err)
+		}
+		if history := cache.History(chatID, 0); len(history) != 1 {
+			t.Errorf("Expected %s's message kept, not a new empty chat, got %v", chatID, history)
+		}
+	}
+	if archived := cache.ArchiveIdleContext(ctx, 0); len(archived) != 0 {
+		t.Errorf("Expected nothing archived after cancellation, got %v", archived)
+	}
+}
+
+func TestTrace(t *testing.T) {
+	cache := NewHierarchicalCache("test", 5, 20)
+
+	ctx, trace := WithTrace(context.Background())
+	if _, _, _, err := cache.AppendMessageContext(ctx, "chat-1", Message{Content: "hello"}); err != nil {
+		t.Fatalf("AppendMessageContext failed: %v", err)
+	}
+	cache.HistoryContext(ctx, "chat-1", 0)
+	cache.HistoryContext(ctx, "chat-2", 0)
+	if path := trace.Path(); path != "miss,l1,miss" {
+		t.Errorf("Expected path miss,l1,miss, got %q", path)
+	}
+
+	// Waiting on another holder of the lock is counted
+	cache.mu.Lock()
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cache.mu.Unlock()
+	}()
+	ctx, trace = WithTrace(context.Background())
+	if _, err := cache.ApplyMessageContext(ctx, "chat-1", Message{ID: "m9", Seq: 9}); err != nil {
+		t.Fatalf("ApplyMessageContext failed: %v", err)
+	}
+	if wait := trace.LockWait(); wait < 15*time.Millisecond {
+		t.Errorf("Expected a lock wait of about 20ms, got %v", wait)
+	}
+
+	// Untraced calls and a nil trace are fine
+	cache.History("chat-1", 0)
+	if path := TraceFrom(context.Background()).Path(); path != "" {
+		t.Errorf("Expected an empty path without a trace, got %q", path)
+	}
+}
@@ -0,0 +1,235 @@
+package cache
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/pkg/logging"
+)
+
+// ColdTier is long-term storage below L2 (e.g. object storage). Sessions
+// evicted from L2 or idle past a threshold are archived there and restored
+// into L1 the next time they're accessed.
+//
+// Calls give up when ctx ends: restores with the request that needed the
+// chat, archiving of idle chats and purges with the ctx they were given.
+type ColdTier interface {
+	// Archive writes a session, replacing any archived copy
+	Archive(ctx context.Context, session *ChatSession) error
+
+	// Restore reads an archived session, or returns false if the chat
+	// isn't archived
+	Restore(ctx context.Context, chatID string) (*ChatSession, bool, error)
+}
+
+// SetColdTier attaches a cold tier below L2. It must be called before the
+// cache is used.
+func (c *HierarchicalCache) SetColdTier(cold ColdTier) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.cold = cold
+	c.archiving = make(map[string]*ChatSession)
+}
+
+// ArchiveIdle moves sessions not accessed for longer than idle to the cold
+// tier and returns their chat IDs. A session accessed while it is being
+// written stays cached. Sessions held in a shared L2 tier are left there.
+func (c *HierarchicalCache) ArchiveIdle(idle time.Duration) []string {
+	return c.ArchiveIdleContext(context.Background(), idle)
+}
+
+// ArchiveIdleContext is ArchiveIdle, stopping when ctx ends (e.g. on
+// shutdown). Sessions not yet written stay cached.
+func (c *HierarchicalCache) ArchiveIdleContext(ctx context.Context, idle time.Duration) []string {
+	return c.ArchiveIdleFunc(ctx, func(string) time.Duration { return idle })
+}
+
+// ArchiveIdleFunc is ArchiveIdleContext with each chat's idle time given by
+// idle, e.g. from a per-chat retention policy
+func (c *HierarchicalCache) ArchiveIdleFunc(ctx context.Context, idle func(chatID string) time.Duration) []string {
+	if c.cold == nil {
+		return nil
+	}
+	now := c.clock.Now()
+	isIdle := func(chatID string, session *ChatSession) bool {
+		return session.LastAccessed.Before(now.Add(-idle(chatID)))
+	}
+
+	// Snapshot candidates so the writes happen without the cache locked
+	c.mu.Lock()
+	candidates := make(map[string]*ChatSession)
+	for chatID, entry := range c.l1Cache {
+		if isIdle(chatID, entry.session) {
+			candidates[chatID] = copySession(entry.session)
+		}
+	}
+	for chatID, entry := range c.l2Cache {
+		if entry.session != nil && isIdle(chatID, entry.session) {
+			candidates[chatID] = copySession(entry.session)
+		}
+	}
+	c.mu.Unlock()
+
+	chatIDs := make([]string, 0, len(candidates))
+	for chatID := range candidates {
+		chatIDs = append(chatIDs, chatID)
+	}
+	sort.Strings(chatIDs)
+
+	archived := make([]string, 0, len(chatIDs))
+	for _, chatID := range chatIDs {
+		if ctx.Err() != nil {
+			break
+		}
+		snapshot := candidates[chatID]
+
+		c.archiveMu.Lock()
+		start := time.Now()
+		err := c.cold.Archive(ctx, snapshot)
+		c.archiveMu.Unlock()
+
+		c.mu.Lock()
+		c.metrics.observeWrite("cold", start)
+		if err != nil {
+			c.mu.Unlock()
+			c.log.Warn("Failed to archive session", logging.ChatID(chatID), logging.Err(err))
+			continue
+		}
+		if c.removeIfUnchanged(chatID, snapshot) {
+			c.stats.Archived++
+			c.metrics.archived.Inc()
+			archived = append(archived, chatID)
+			c.log.Info("Archived idle session", logging.ChatID(chatID), "messages", snapshot.MessageCount)
+		}
+		c.mu.Unlock()
+	}
+	return archived
+}
+
+// removeIfUnchanged drops a cached session unless it was accessed after
+// snapshot was taken (must be called with lock held)
+func (c *HierarchicalCache) removeIfUnchanged(chatID string, snapshot *ChatSession) bool {
+	if entry, ok := c.l1Cache[chatID]; ok {
+		if !entry.session.LastAccessed.Equal(snapshot.LastAccessed) {
+			return false
+		}
+		c.l1List.Remove(entry.element)
+		delete(c.l1Cache, chatID)
+		return true
+	}
+	if entry, ok := c.l2Cache[chatID]; ok && entry.session != nil {
+		if !entry.session.LastAccessed.Equal(snapshot.LastAccessed) {
+			return false
+		}
+		c.l2List.Remove(entry.element)
+		delete(c.l2Cache, chatID)
+		return true
+	}
+	return false
+}
+
+// archiveEvicted writes a session evicted from L2 to the cold tier. It is
+// skipped if the chat was restored or evicted again before its turn. The
+// write isn't tied to the request whose lookup evicted the session, which
+// may end first.
+func (c *HierarchicalCache) archiveEvicted(chatID string, session *ChatSession) {
+	c.archiveMu.Lock()
+	defer c.archiveMu.Unlock()
+
+	c.mu.RLock()
+	current := c.archiving[chatID]
+	c.mu.RUnlock()
+	if current != session {
+		return
+	}
+
+	start := time.Now()
+	err := c.cold.Archive(context.Background(), session)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.metrics.observeWrite("cold", start)
+	if c.archiving[chatID] == session {
+		delete(c.archiving, chatID)
+	}
+	if err != nil {
+		c.log.Warn("Failed to archive evicted session", logging.ChatID(chatID), logging.Err(err))
+		return
+	}
+	c.stats.Archived++
+	c.metrics.archived.Inc()
+}
+
+// restoreArchived brings an archived chat back into L1 if it isn't cached.
+// The cold tier is read without the cache locked. Failures are logged and
+// the chat treated as not archived, unless ctx ended: then ctx's error is
+// returned, so the caller doesn't create the chat afresh over its archive.
+func (c *HierarchicalCache) restoreArchived(ctx context.Context, chatID string) error {
+	if c.cold == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	if c.holds(chatID) {
+		c.mu.Unlock()
+		return nil
+	}
+	// An evicted session still being written is taken back directly
+	if pending, ok := c.archiving[chatID]; ok {
+		delete(c.archiving, chatID)
+		c.admitRestored(chatID, copySession(pending))
+		c.mu.Unlock()
+		return nil
+	}
+	c.mu.Unlock()
+
+	session, ok, err := c.cold.Restore(ctx, chatID)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		c.log.WarnContext(ctx, "Failed to restore session from the cold tier", logging.ChatID(chatID), logging.Err(err))
+		return nil
+	}
+	if !ok {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.holds(chatID) {
+		return nil // Created or restored by another caller meanwhile
+	}
+	c.admitRestored(chatID, session)
+	c.log.InfoContext(ctx, "Restored session from the cold tier", logging.ChatID(chatID), "messages", session.MessageCount)
+	return nil
+}
+
+// holds reports whether a chat is in L1 or tracked in L2 (must be called
+// with lock held)
+func (c *HierarchicalCache) holds(chatID string) bool {
+	if _, ok := c.l1Cache[chatID]; ok {
+		return true
+	}
+	_, ok := c.l2Cache[chatID]
+	return ok
+}
+
+// admitRestored adds a restored session to L1 (must be called with lock held)
+func (c *HierarchicalCache) admitRestored(chatID string, session *ChatSession) {
+	session.LastAccessed = c.clock.Now()
+	c.addToL1(chatID, session)
+	c.l1Cache[chatID].restored = true
+}
+
+// copySession copies a session deeply enough to be read while the original
+// keeps changing. Messages are never modified once stored.
+func copySession(session *ChatSession) *ChatSession {
+	copied := *session
+	copied.Messages = make([]Message, len(session.Messages))
+	copy(copied.Messages, session.Messages)
+	copied.Version = session.Version.Copy()
+	return &copied
+}
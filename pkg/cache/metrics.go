@@ -0,0 +1,85 @@
+package cache
+
+import (
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/pkg/metrics"
+)
+
+// cacheMetrics holds a cache's series. They mirror CacheStats, which stays
+// the in-process view.
+type cacheMetrics struct {
+	lookups       metrics.CounterVec
+	lookupLatency metrics.HistogramVec
+	writeLatency  metrics.HistogramVec
+	demotions     metrics.Counter
+	evictions     metrics.Counter
+	archived      metrics.Counter
+	duplicates    metrics.Counter
+}
+
+// newCacheMetrics creates a cache's series in reg
+func newCacheMetrics(reg metrics.Registry) cacheMetrics {
+	return cacheMetrics{
+		lookups: reg.Counter("districhat_cache_lookups_total", "Session lookups by the tier that served them", "cache_level"),
+		lookupLatency: reg.Histogram("districhat_cache_lookup_duration_seconds",
+			"Time to serve session lookups, including promotion, loading or creation, by the tier that served them",
+			metrics.CacheBuckets, "cache_level"),
+		writeLatency: reg.Histogram("districhat_cache_write_duration_seconds",
+			"Time to write sessions to persistent tiers (shared L2 or cold), failed writes included",
+			metrics.CacheBuckets, "tier"),
+		demotions:  reg.Counter("districhat_cache_demotions_total", "Sessions demoted from L1 to L2").With(),
+		evictions:  reg.Counter("districhat_cache_evictions_total", "Sessions evicted from L2").With(),
+		archived:   reg.Counter("districhat_cache_archived_total", "Sessions written to the cold tier").With(),
+		duplicates: reg.Counter("districhat_cache_duplicates_total", "Messages collapsed into a copy already held").With(),
+	}
+}
+
+// observeLookup records a lookup served by the tier labelled level
+func (m cacheMetrics) observeLookup(level string, start time.Time) {
+	m.lookups.With(level).Inc()
+	m.lookupLatency.With(level).Observe(time.Since(start).Seconds())
+}
+
+// observeWrite records a write to the persistent tier labelled tier
+func (m cacheMetrics) observeWrite(tier string, start time.Time) {
+	m.writeLatency.With(tier).Observe(time.Since(start).Seconds())
+}
+
+// SetMetrics reports the cache's lookups per tier ("cache_level": l1, l2,
+// shared, archive or miss) with their latencies, its tier movements,
+// persistent tier write latencies and its sizes to reg from now on
+func (c *HierarchicalCache) SetMetrics(reg metrics.Registry) {
+	c.mu.Lock()
+	c.metrics = newCacheMetrics(reg)
+	c.mu.Unlock()
+
+	reg.GaugeFunc("districhat_cache_l1_sessions", "Sessions in L1", func() float64 {
+		return float64(c.GetCacheInfo().L1Size)
+	})
+	reg.GaugeFunc("districhat_cache_l2_sessions", "Sessions in L2", func() float64 {
+		return float64(c.GetCacheInfo().L2Size)
+	})
+	reg.GaugeFunc("districhat_cache_l1_capacity", "L1 capacity in sessions", func() float64 {
+		return float64(c.GetCacheInfo().L1Capacity)
+	})
+	reg.GaugeFunc("districhat_cache_l2_capacity", "L2 capacity in sessions", func() float64 {
+		return float64(c.GetCacheInfo().L2Capacity)
+	})
+}
+
+// Label returns the level's "cache_level" label value
+func (l CacheLevel) Label() string {
+	switch l {
+	case LevelL1:
+		return "l1"
+	case LevelL2:
+		return "l2"
+	case LevelMiss:
+		return "miss"
+	case LevelArchive:
+		return "archive"
+	default:
+		return "unknown"
+	}
+}
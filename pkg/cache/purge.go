@@ -0,0 +1,111 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// Purge removes the messages of a chat that match, from the cache and the
+// cold tier, and returns them. A chat held in neither is left alone. The
+// session keeps its sequence numbers and version vector, so replicas that
+// purge the same messages still agree and don't repair them back into each
+// other; its Floor rises to the highest sequence number purged.
+//
+// The archived copy is rewritten when the chat isn't cached, or when the
+// cached copy lost messages, so it can't bring them back on a restore. A
+// session evicted from L2 and still being written to the cold tier may
+// keep them until the next purge.
+func (c *HierarchicalCache) Purge(ctx context.Context, chatID string, match func(Message) bool) ([]Message, error) {
+	c.lock(ctx)
+	removed, cached, err := c.purgeCached(ctx, chatID, match)
+	c.mu.Unlock()
+	if err != nil || c.cold == nil || (cached && len(removed) == 0) {
+		return removed, err
+	}
+
+	c.archiveMu.Lock()
+	defer c.archiveMu.Unlock()
+
+	session, ok, err := c.cold.Restore(ctx, chatID)
+	if err != nil || !ok {
+		return removed, err
+	}
+	archived := purgeSession(session, match)
+	if len(archived) == 0 {
+		return removed, nil
+	}
+	start := time.Now()
+	err = c.cold.Archive(ctx, session)
+	c.mu.Lock()
+	c.metrics.observeWrite("cold", start)
+	c.mu.Unlock()
+	if err != nil {
+		return removed, err
+	}
+	if !cached {
+		removed = archived
+	}
+	return removed, nil
+}
+
+// PurgeBefore purges a chat's messages sent before cutoff (see Purge).
+// System events are kept, as the chat's members are derived from them.
+func (c *HierarchicalCache) PurgeBefore(ctx context.Context, chatID string, cutoff time.Time) ([]Message, error) {
+	return c.Purge(ctx, chatID, func(msg Message) bool {
+		return msg.Type != ContentSystemEvent && msg.Timestamp.Before(cutoff)
+	})
+}
+
+// purgeCached purges a chat's cached session, writing it back if it lives
+// in the shared tier, and reports whether the chat is cached (must be
+// called with lock held)
+func (c *HierarchicalCache) purgeCached(ctx context.Context, chatID string, match func(Message) bool) ([]Message, bool, error) {
+	if entry, ok := c.l1Cache[chatID]; ok {
+		return purgeSession(entry.session, match), true, nil
+	}
+	entry, ok := c.l2Cache[chatID]
+	if !ok {
+		return nil, false, nil
+	}
+	if entry.session != nil {
+		return purgeSession(entry.session, match), true, nil
+	}
+
+	session, ok := c.loadShared(ctx, chatID)
+	if !ok {
+		return nil, false, ctx.Err()
+	}
+	removed := purgeSession(session, match)
+	if len(removed) > 0 {
+		start := time.Now()
+		err := c.tier.Store(ctx, session)
+		c.metrics.observeWrite("shared", start)
+		if err != nil {
+			return nil, true, err
+		}
+	}
+	return removed, true, nil
+}
+
+// purgeSession removes the messages that match from session and returns
+// them. The kept messages go into a new slice, as copies of the session
+// may share the old one.
+func purgeSession(session *ChatSession, match func(Message) bool) []Message {
+	var removed []Message
+	kept := make([]Message, 0, len(session.Messages))
+	for _, msg := range session.Messages {
+		if !match(msg) {
+			kept = append(kept, msg)
+			continue
+		}
+		removed = append(removed, msg)
+		if msg.Seq > session.Floor {
+			session.Floor = msg.Seq
+		}
+	}
+	if len(removed) > 0 {
+		session.Messages = kept
+		session.MessageCount = len(kept)
+	}
+	return removed
+}
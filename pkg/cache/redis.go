@@ -0,0 +1,102 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisConfig contains configuration for a Redis-backed shared tier
+type RedisConfig struct {
+	Addr     string
+	Password string
+	DB       int
+
+	// Prefix of every session key (default: "districhat:l2:")
+	KeyPrefix string
+
+	// How long a stored session lives without being rewritten (default: 24h)
+	TTL time.Duration
+
+	// Deadline for each Redis call (default: 500ms), within the caller's.
+	// Calls run while the cache is locked, so this bounds how long a slow
+	// Redis stalls it.
+	Timeout time.Duration
+}
+
+// RedisTier stores L2 sessions in Redis, one key per chat
+type RedisTier struct {
+	config RedisConfig
+	client *redis.Client
+}
+
+// NewRedisTier connects to Redis and checks that it answers
+func NewRedisTier(config RedisConfig) (*RedisTier, error) {
+	if config.Addr == "" {
+		return nil, fmt.Errorf("redis address is required")
+	}
+	if config.KeyPrefix == "" {
+		config.KeyPrefix = "districhat:l2:"
+	}
+	if config.TTL <= 0 {
+		config.TTL = 24 * time.Hour
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 500 * time.Millisecond
+	}
+
+	client := redis.NewClient(&redis.Options{
+		Addr:     config.Addr,
+		Password: config.Password,
+		DB:       config.DB,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.Timeout)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to reach redis at %s: %w", config.Addr, err)
+	}
+
+	return &RedisTier{config: config, client: client}, nil
+}
+
+// Load reads a chat's session
+func (t *RedisTier) Load(ctx context.Context, chatID string) (*ChatSession, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.config.Timeout)
+	defer cancel()
+
+	data, err := t.client.Get(ctx, t.config.KeyPrefix+chatID).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	session, err := decodeSession(data)
+	if err != nil {
+		return nil, false, fmt.Errorf("corrupt session for %s: %w", chatID, err)
+	}
+	return session, true, nil
+}
+
+// Store writes a session and resets its TTL
+func (t *RedisTier) Store(ctx context.Context, session *ChatSession) error {
+	data, err := encodeSession(session)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, t.config.Timeout)
+	defer cancel()
+	return t.client.Set(ctx, t.config.KeyPrefix+session.ChatID, data, t.config.TTL).Err()
+}
+
+// Close closes the Redis connection
+func (t *RedisTier) Close() error {
+	return t.client.Close()
+}
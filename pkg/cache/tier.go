@@ -0,0 +1,101 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/sh4shv4t/DistriChat/pkg/clock"
+)
+
+// SharedTier is an external store backing the L2 tier, shared by every
+// server that points at it. Servers replicating the same chats read each
+// other's demoted sessions from it, so a server taking over a chat after a
+// failover finds it warm instead of missing.
+//
+// Sessions are keyed by chat ID. Entries are never deleted by the cache;
+// backends expire them on their own (e.g. a Redis TTL). Calls give up when
+// ctx ends.
+type SharedTier interface {
+	// Load returns the stored session, or false if the chat isn't stored
+	Load(ctx context.Context, chatID string) (*ChatSession, bool, error)
+
+	// Store writes a session, replacing any stored copy
+	Store(ctx context.Context, session *ChatSession) error
+}
+
+// encodeSession serializes a session for a shared tier
+func encodeSession(session *ChatSession) ([]byte, error) {
+	return json.Marshal(session)
+}
+
+// decodeSession parses a session written by encodeSession
+func decodeSession(data []byte) (*ChatSession, error) {
+	session := &ChatSession{}
+	if err := json.Unmarshal(data, session); err != nil {
+		return nil, err
+	}
+	if session.Messages == nil {
+		session.Messages = make([]Message, 0)
+	}
+	if session.Version == nil {
+		session.Version = make(clock.VersionVector)
+	}
+	return session, nil
+}
+
+// MemoryTier is an in-process SharedTier. Caches given the same instance
+// share it the way servers share a Redis instance.
+type MemoryTier struct {
+	mu       sync.RWMutex
+	sessions map[string][]byte
+}
+
+// NewMemoryTier creates an empty in-memory shared tier
+func NewMemoryTier() *MemoryTier {
+	return &MemoryTier{sessions: make(map[string][]byte)}
+}
+
+// Load returns a copy of the stored session
+func (t *MemoryTier) Load(ctx context.Context, chatID string) (*ChatSession, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, false, err
+	}
+
+	t.mu.RLock()
+	data, ok := t.sessions[chatID]
+	t.mu.RUnlock()
+	if !ok {
+		return nil, false, nil
+	}
+
+	session, err := decodeSession(data)
+	if err != nil {
+		return nil, false, err
+	}
+	return session, true, nil
+}
+
+// Store saves a copy of the session
+func (t *MemoryTier) Store(ctx context.Context, session *ChatSession) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	data, err := encodeSession(session)
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.sessions[session.ChatID] = data
+	return nil
+}
+
+// Len returns the number of stored sessions
+func (t *MemoryTier) Len() int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return len(t.sessions)
+}
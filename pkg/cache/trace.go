@@ -0,0 +1,100 @@
+package cache
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Trace records what the cache did for one request: the tiers its lookups
+// were served from and how long it waited for the cache lock. A request
+// opts in by carrying one in its context (WithTrace) into the Context
+// variants of the cache's methods; the server's slow-request log reports
+// it.
+type Trace struct {
+	mu       sync.Mutex
+	path     []string
+	lockWait time.Duration
+}
+
+type traceKey struct{}
+
+// WithTrace returns a context carrying a new, empty trace
+func WithTrace(ctx context.Context) (context.Context, *Trace) {
+	t := &Trace{}
+	return context.WithValue(ctx, traceKey{}, t), t
+}
+
+// TraceFrom returns the trace carried by ctx, or nil
+func TraceFrom(ctx context.Context) *Trace {
+	t, _ := ctx.Value(traceKey{}).(*Trace)
+	return t
+}
+
+// Path returns the tiers the request's lookups were served from in order,
+// e.g. "l2" or "l1,miss" ("" if it made none)
+func (t *Trace) Path() string {
+	if t == nil {
+		return ""
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return strings.Join(t.path, ",")
+}
+
+// LockWait returns the total time the request waited for the cache lock
+func (t *Trace) LockWait() time.Duration {
+	if t == nil {
+		return 0
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.lockWait
+}
+
+// served records a lookup served from tier
+func (t *Trace) served(tier string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.path = append(t.path, tier)
+}
+
+// waited adds to the time spent waiting for the lock
+func (t *Trace) waited(d time.Duration) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.lockWait += d
+}
+
+// lock takes the cache lock for writing, timing the wait if ctx carries a
+// trace
+func (c *HierarchicalCache) lock(ctx context.Context) {
+	t := TraceFrom(ctx)
+	if t == nil {
+		c.mu.Lock()
+		return
+	}
+	start := time.Now()
+	c.mu.Lock()
+	t.waited(time.Since(start))
+}
+
+// rlock takes the cache lock for reading, timing the wait if ctx carries
+// a trace
+func (c *HierarchicalCache) rlock(ctx context.Context) {
+	t := TraceFrom(ctx)
+	if t == nil {
+		c.mu.RLock()
+		return
+	}
+	start := time.Now()
+	c.mu.RLock()
+	t.waited(time.Since(start))
+}
@@ -0,0 +1,315 @@
+// Package chaos injects faults into DistriChat's gRPC transport so failure
+// scenarios beyond a clean server shutdown can be exercised: slow links,
+// lossy links, network partitions between specific nodes, and servers
+// killed mid-run.
+//
+// An Injector holds the active fault rules. Servers and clients configured
+// with one tag their outgoing calls with their node name (DialOptions), and
+// servers apply the rules to every call they receive (ServerOptions), so a
+// rule can match both ends of a call. Callers that know whom they are
+// calling can apply the rules themselves instead (ClientOptions), which
+// also reaches servers running without the injector. Rules are added and
+// removed at any time, by hand or on a schedule (Run, Schedule).
+package chaos
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/pkg/clock"
+	"github.com/sh4shv4t/DistriChat/pkg/logging"
+	"github.com/sh4shv4t/DistriChat/pkg/sim"
+)
+
+// Rule is one fault applied to calls between two nodes
+type Rule struct {
+	// Identifies the rule for Remove; adding a rule with the name of an
+	// existing one replaces it
+	Name string
+
+	// Nodes the rule applies between: calls from From to To ("" matches
+	// any node, and calls from callers that don't name themselves)
+	From string
+	To   string
+
+	// Delay added to each call, plus a random extra drawn from
+	// Distribution (default: Uniform) on the scale of Jitter
+	Latency      time.Duration
+	Jitter       time.Duration
+	Distribution Distribution
+
+	// Fraction of calls failed as unreachable, from 0 to 1. With Stall,
+	// dropped calls hang until their deadline instead, like requests lost
+	// on the wire rather than refused; calls without one still fail at once.
+	DropRate float64
+	Stall    bool
+}
+
+// Distribution is how the random extra delay of a rule is drawn
+type Distribution string
+
+const (
+	// Uniform adds up to Jitter, every extra equally likely
+	Uniform Distribution = "uniform"
+	// Normal spreads delays around Latency with a standard deviation of
+	// Jitter, never below zero
+	Normal Distribution = "normal"
+	// Exponential adds Jitter on average: mostly a little, now and then
+	// several times as much
+	Exponential Distribution = "exponential"
+	// Pareto adds Jitter on average with a heavy tail, as on congested
+	// links: the odd call takes many times longer (at most 100 Jitters)
+	Pareto Distribution = "pareto"
+)
+
+// paretoCap bounds Pareto extras, in Jitters
+const paretoCap = 100
+
+// ParseDistribution returns the distribution called name ("" is Uniform)
+func ParseDistribution(name string) (Distribution, error) {
+	switch d := Distribution(name); d {
+	case "":
+		return Uniform, nil
+	case Uniform, Normal, Exponential, Pareto:
+		return d, nil
+	}
+	return "", fmt.Errorf("unknown latency distribution %q (want uniform, normal, exponential or pareto)", name)
+}
+
+// delay draws the delay the rule adds to one call
+func (r Rule) delay(rnd *sim.Rand) time.Duration {
+	if r.Jitter <= 0 {
+		return r.Latency
+	}
+
+	var extra float64 // In Jitters
+	switch r.Distribution {
+	case Normal:
+		// Box-Muller
+		u := 1 - rnd.Float64()
+		extra = math.Sqrt(-2*math.Log(u)) * math.Cos(2*math.Pi*rnd.Float64())
+	case Exponential:
+		extra = -math.Log(1 - rnd.Float64())
+	case Pareto:
+		// Lomax with shape 2, whose mean is its scale
+		extra = math.Min(math.Pow(1-rnd.Float64(), -0.5)-1, paretoCap)
+	default:
+		extra = rnd.Float64()
+	}
+	return max(r.Latency+time.Duration(extra*float64(r.Jitter)), 0)
+}
+
+// matches reports whether the rule applies to a call from from to to
+func (r Rule) matches(from, to string) bool {
+	return (r.From == "" || r.From == from) && (r.To == "" || r.To == to)
+}
+
+// Stats counts the faults injected
+type Stats struct {
+	Delayed int64
+	Dropped int64
+	Killed  int64
+}
+
+// Injector decides which calls are delayed or dropped, and kills nodes
+type Injector struct {
+	mu    sync.RWMutex
+	rules map[string]Rule
+	kills map[string]func()
+
+	rand  *sim.Rand
+	clock clock.Clock
+
+	delayed atomic.Int64
+	dropped atomic.Int64
+	killed  atomic.Int64
+
+	log *slog.Logger
+}
+
+// New creates an injector with no rules
+func New() *Injector {
+	return &Injector{
+		rules: make(map[string]Rule),
+		kills: make(map[string]func()),
+		rand:  sim.NewRand(time.Now().UnixNano()),
+		clock: clock.System(),
+		log:   logging.Logger("chaos"),
+	}
+}
+
+// SetRand makes the injector draw from r, e.g. a simulation's stream, so
+// the same calls are dropped on every run
+func (in *Injector) SetRand(r *sim.Rand) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	in.rand = r
+}
+
+// SetClock makes Schedule wait on c (default: the system clock)
+func (in *Injector) SetClock(c clock.Clock) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	in.clock = c
+}
+
+// Add installs rule, replacing any rule of the same name
+func (in *Injector) Add(rule Rule) {
+	in.mu.Lock()
+	in.rules[rule.Name] = rule
+	in.mu.Unlock()
+
+	in.log.Info("Fault rule added", "rule", rule.Name, "from", rule.From, "to", rule.To,
+		"latency", rule.Latency, "jitter", rule.Jitter, "distribution", rule.Distribution,
+		"drop_rate", rule.DropRate, "stall", rule.Stall)
+}
+
+// Remove uninstalls the rule called name
+func (in *Injector) Remove(name string) {
+	in.mu.Lock()
+	_, ok := in.rules[name]
+	delete(in.rules, name)
+	in.mu.Unlock()
+
+	if ok {
+		in.log.Info("Fault rule removed", "rule", name)
+	}
+}
+
+// Clear uninstalls every rule
+func (in *Injector) Clear() {
+	in.mu.Lock()
+	in.rules = make(map[string]Rule)
+	in.mu.Unlock()
+
+	in.log.Info("Fault rules cleared")
+}
+
+// Rules returns the installed rules, sorted by name
+func (in *Injector) Rules() []Rule {
+	in.mu.RLock()
+	defer in.mu.RUnlock()
+
+	rules := make([]Rule, 0, len(in.rules))
+	for _, rule := range in.rules {
+		rules = append(rules, rule)
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].Name < rules[j].Name })
+	return rules
+}
+
+// Partition cuts a and b off from each other: every call between them, in
+// either direction, fails until Heal
+func (in *Injector) Partition(a, b string) {
+	in.Add(Rule{Name: partitionName(a, b), From: a, To: b, DropRate: 1})
+	in.Add(Rule{Name: partitionName(b, a), From: b, To: a, DropRate: 1})
+}
+
+// Heal undoes Partition(a, b)
+func (in *Injector) Heal(a, b string) {
+	in.Remove(partitionName(a, b))
+	in.Remove(partitionName(b, a))
+}
+
+// partitionName names the rule dropping calls from one node to another
+func partitionName(from, to string) string {
+	return fmt.Sprintf("partition %s->%s", from, to)
+}
+
+// Register makes Kill(node) call kill, e.g. a server's Stop
+func (in *Injector) Register(node string, kill func()) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	in.kills[node] = kill
+}
+
+// Kill stops node through the function it registered. Each node is
+// killed at most once; returns whether this call killed it.
+func (in *Injector) Kill(node string) bool {
+	in.mu.Lock()
+	kill, ok := in.kills[node]
+	delete(in.kills, node)
+	in.mu.Unlock()
+
+	if !ok {
+		in.log.Warn("Cannot kill unregistered node", logging.NodeID(node))
+		return false
+	}
+	in.log.Warn("Killing node", logging.NodeID(node))
+	in.killed.Add(1)
+	kill()
+	return true
+}
+
+// Stats returns the number of faults injected so far
+func (in *Injector) Stats() Stats {
+	return Stats{
+		Delayed: in.delayed.Load(),
+		Dropped: in.dropped.Load(),
+		Killed:  in.killed.Load(),
+	}
+}
+
+// fault is what the rules do to one call
+type fault struct {
+	delay time.Duration
+	drop  bool
+	stall bool   // Hang the dropped call until its deadline
+	rule  string // Rule that dropped the call
+}
+
+// decide rolls the dice for a call from from to to. The delays of all
+// matching rules add up; any matching rule can drop the call. Rules are
+// consulted in name order, so a seeded injector decides the same way on
+// every run.
+func (in *Injector) decide(from, to string) fault {
+	in.mu.RLock()
+	r := in.rand
+	in.mu.RUnlock()
+
+	var f fault
+	for _, rule := range in.Rules() {
+		if !rule.matches(from, to) {
+			continue
+		}
+		f.delay += rule.delay(r)
+		if !f.drop && rule.DropRate > 0 && r.Float64() < rule.DropRate {
+			f.drop, f.stall, f.rule = true, rule.Stall, rule.Name
+		}
+	}
+	return f
+}
+
+// apply delays the call from from to to and reports whether to drop it.
+// A delay cut short by ctx returns ctx's error, as does a stalled drop
+// once ctx expires.
+func (in *Injector) apply(ctx context.Context, from, to, method string) (drop bool, rule string, err error) {
+	f := in.decide(from, to)
+	if f.delay > 0 {
+		in.delayed.Add(1)
+		timer := time.NewTimer(f.delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return false, "", ctx.Err()
+		}
+	}
+	if !f.drop {
+		return false, "", nil
+	}
+
+	in.dropped.Add(1)
+	in.log.DebugContext(ctx, "Dropped call", "rule", f.rule, "from", from, "to", to, "method", method)
+	if _, ok := ctx.Deadline(); ok && f.stall {
+		<-ctx.Done()
+		return true, f.rule, ctx.Err()
+	}
+	return true, f.rule, nil
+}
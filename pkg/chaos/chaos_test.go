@@ -0,0 +1,334 @@
+package chaos
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/pkg/clock"
+	"github.com/sh4shv4t/DistriChat/pkg/sim"
+	pb "github.com/sh4shv4t/DistriChat/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// okServer answers every PostMessage
+type okServer struct {
+	pb.UnimplementedChatServiceServer
+}
+
+func (okServer) PostMessage(ctx context.Context, req *pb.ChatRequest) (*pb.ChatResponse, error) {
+	return &pb.ChatResponse{Success: true}, nil
+}
+
+// serve starts a ChatService running as node and returns a client dialing
+// it as caller
+func serve(t *testing.T, in *Injector, node, caller string) pb.ChatServiceClient {
+	t.Helper()
+	return serveWith(t, ServerOptions(in, node), DialOptions(in, caller))
+}
+
+// serveWith starts a ChatService with serverOpts and returns a client
+// dialing it with dialOpts
+func serveWith(t *testing.T, serverOpts []grpc.ServerOption, dialOpts []grpc.DialOption) pb.ChatServiceClient {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	server := grpc.NewServer(serverOpts...)
+	pb.RegisterChatServiceServer(server, okServer{})
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	opts := append([]grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}, dialOpts...)
+	conn, err := grpc.Dial(listener.Addr().String(), opts...)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return pb.NewChatServiceClient(conn)
+}
+
+func post(client pb.ChatServiceClient) error {
+	_, err := client.PostMessage(context.Background(), &pb.ChatRequest{ChatId: "chat-1"})
+	return err
+}
+
+func TestDropRule(t *testing.T) {
+	in := New()
+	client := serve(t, in, "server-1", "client")
+
+	if err := post(client); err != nil {
+		t.Fatalf("Expected success without rules, got %v", err)
+	}
+
+	in.Add(Rule{Name: "drop", To: "server-1", DropRate: 1})
+	if err := post(client); status.Code(err) != codes.Unavailable {
+		t.Errorf("Expected Unavailable, got %v", err)
+	}
+	if got := in.Stats().Dropped; got != 1 {
+		t.Errorf("Expected 1 dropped call, got %d", got)
+	}
+
+	in.Remove("drop")
+	if err := post(client); err != nil {
+		t.Errorf("Expected success after Remove, got %v", err)
+	}
+}
+
+func TestPartition(t *testing.T) {
+	in := New()
+	aToB := serve(t, in, "b", "a")
+	bToA := serve(t, in, "a", "b")
+	cToB := serve(t, in, "b", "c")
+
+	in.Partition("a", "b")
+	if err := post(aToB); status.Code(err) != codes.Unavailable {
+		t.Errorf("Expected a->b to fail, got %v", err)
+	}
+	if err := post(bToA); status.Code(err) != codes.Unavailable {
+		t.Errorf("Expected b->a to fail, got %v", err)
+	}
+	if err := post(cToB); err != nil {
+		t.Errorf("Expected c->b to succeed, got %v", err)
+	}
+
+	in.Heal("a", "b")
+	if len(in.Rules()) != 0 {
+		t.Errorf("Expected no rules after Heal, got %v", in.Rules())
+	}
+	if err := post(aToB); err != nil {
+		t.Errorf("Expected a->b to succeed after Heal, got %v", err)
+	}
+}
+
+func TestLatency(t *testing.T) {
+	in := New()
+	client := serve(t, in, "server-1", "client")
+
+	in.Add(Rule{Name: "slow", From: "client", Latency: 50 * time.Millisecond})
+	start := time.Now()
+	if err := post(client); err != nil {
+		t.Fatalf("PostMessage failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("Expected at least 50ms, got %v", elapsed)
+	}
+
+	// A deadline shorter than the delay fails the call
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := client.PostMessage(ctx, &pb.ChatRequest{ChatId: "chat-1"})
+	if status.Code(err) != codes.DeadlineExceeded {
+		t.Errorf("Expected DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestClientOptions(t *testing.T) {
+	in := New()
+	in.Add(Rule{Name: "drop", From: "client", To: "server-1", DropRate: 1})
+
+	// The caller applies the rules to a server running without them
+	bare := serveWith(t, nil, ClientOptions(in, "client", "server-1"))
+	if err := post(bare); status.Code(err) != codes.Unavailable {
+		t.Errorf("Expected Unavailable, got %v", err)
+	}
+
+	// A server sharing the injector doesn't apply them a second time
+	in.Add(Rule{Name: "drop", From: "client", To: "server-1", Latency: time.Millisecond})
+	shared := serveWith(t, ServerOptions(in, "server-1"), ClientOptions(in, "client", "server-1"))
+	if err := post(shared); err != nil {
+		t.Fatalf("Expected success, got %v", err)
+	}
+	if got := in.Stats(); got.Delayed != 1 || got.Dropped != 1 {
+		t.Errorf("Expected 1 delayed and 1 dropped call, got %+v", got)
+	}
+
+	// Rules for other targets don't apply
+	other := serveWith(t, nil, ClientOptions(in, "client", "server-2"))
+	if err := post(other); err != nil {
+		t.Errorf("Expected success to server-2, got %v", err)
+	}
+}
+
+func TestStall(t *testing.T) {
+	in := New()
+	client := serve(t, in, "server-1", "client")
+	in.Add(Rule{Name: "lost", To: "server-1", DropRate: 1, Stall: true})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := client.PostMessage(ctx, &pb.ChatRequest{ChatId: "chat-1"})
+	if status.Code(err) != codes.DeadlineExceeded {
+		t.Errorf("Expected DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("Expected the call to hang until its deadline, returned after %v", elapsed)
+	}
+
+	// Without a deadline the call fails at once
+	if err := post(client); status.Code(err) != codes.Unavailable {
+		t.Errorf("Expected Unavailable, got %v", err)
+	}
+}
+
+func TestDistributions(t *testing.T) {
+	rnd := sim.New(1).Rand("chaos")
+	const samples = 20000
+
+	tests := []struct {
+		distribution Distribution
+		mean         time.Duration // Expected, within 5%
+		min          time.Duration
+	}{
+		{Uniform, 25 * time.Millisecond, 20 * time.Millisecond},
+		{Normal, 20 * time.Millisecond, 0},
+		{Exponential, 30 * time.Millisecond, 20 * time.Millisecond},
+		{Pareto, 30 * time.Millisecond, 20 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.distribution), func(t *testing.T) {
+			rule := Rule{Latency: 20 * time.Millisecond, Jitter: 10 * time.Millisecond, Distribution: tt.distribution}
+			var total, longest time.Duration
+			for i := 0; i < samples; i++ {
+				d := rule.delay(rnd)
+				if d < tt.min {
+					t.Fatalf("Expected at least %v, got %v", tt.min, d)
+				}
+				total += d
+				longest = max(longest, d)
+			}
+
+			mean := total / samples
+			if mean < tt.mean*95/100 || mean > tt.mean*105/100 {
+				t.Errorf("Expected a mean of about %v, got %v", tt.mean, mean)
+			}
+			if tt.distribution == Pareto && longest > rule.Latency+paretoCap*rule.Jitter {
+				t.Errorf("Expected the tail capped, got %v", longest)
+			}
+		})
+	}
+}
+
+func TestParseDistribution(t *testing.T) {
+	if d, err := ParseDistribution(""); err != nil || d != Uniform {
+		t.Errorf("Expected uniform by default, got %q, %v", d, err)
+	}
+	if d, err := ParseDistribution("pareto"); err != nil || d != Pareto {
+		t.Errorf("Expected pareto, got %q, %v", d, err)
+	}
+	if _, err := ParseDistribution("zipf"); err == nil {
+		t.Errorf("Expected an error for an unknown distribution")
+	}
+}
+
+func TestNilInjector(t *testing.T) {
+	client := serve(t, nil, "server-1", "client")
+	if err := post(client); err != nil {
+		t.Errorf("Expected success without an injector, got %v", err)
+	}
+}
+
+func TestKill(t *testing.T) {
+	in := New()
+	kills := 0
+	in.Register("server-1", func() { kills++ })
+
+	if !in.Kill("server-1") {
+		t.Errorf("Expected the first Kill to kill")
+	}
+	if in.Kill("server-1") {
+		t.Errorf("Expected the second Kill to do nothing")
+	}
+	if in.Kill("server-2") {
+		t.Errorf("Expected an unregistered node not to be killed")
+	}
+	if kills != 1 {
+		t.Errorf("Expected 1 kill, got %d", kills)
+	}
+}
+
+func TestScheduleOnVirtualClock(t *testing.T) {
+	in := New()
+	v := clock.NewVirtual(time.Unix(0, 0))
+	in.SetClock(v)
+
+	cancel := in.Schedule([]Step{
+		{At: time.Second, Name: "partition", Do: Partition("a", "b")},
+		{At: 2 * time.Second, Name: "heal", Do: Heal("a", "b")},
+		{At: 3 * time.Second, Name: "slow", Do: AddRule(Rule{Name: "slow", Latency: time.Second})},
+	})
+
+	v.Advance(1500 * time.Millisecond)
+	if got := len(in.Rules()); got != 2 {
+		t.Errorf("Expected the partition in place after 1.5s, got %d rules", got)
+	}
+	v.Advance(time.Second)
+	if got := len(in.Rules()); got != 0 {
+		t.Errorf("Expected the partition healed after 2.5s, got %d rules", got)
+	}
+
+	cancel()
+	v.Advance(time.Minute)
+	if got := len(in.Rules()); got != 0 {
+		t.Errorf("Expected cancelled steps not to run, got %d rules", got)
+	}
+}
+
+func TestSeededDrops(t *testing.T) {
+	drops := func() []bool {
+		in := New()
+		in.SetRand(sim.New(7).Rand("chaos"))
+		in.Add(Rule{Name: "lossy", DropRate: 0.5})
+		in.Add(Rule{Name: "lossier", DropRate: 0.5})
+		var got []bool
+		for i := 0; i < 20; i++ {
+			got = append(got, in.decide("client", "server").drop)
+		}
+		return got
+	}
+
+	first, second := drops(), drops()
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("Expected the same drops from the same seed, got %v and %v", first, second)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	in := New()
+	var order []string
+	in.Register("server-1", func() { order = append(order, "kill") })
+
+	err := in.Run(context.Background(), []Step{
+		{At: 20 * time.Millisecond, Name: "kill", Do: Kill("server-1")},
+		{At: 0, Name: "partition", Do: func(in *Injector) {
+			in.Partition("a", "b")
+			order = append(order, "partition")
+		}},
+		{At: 30 * time.Millisecond, Name: "heal", Do: Heal("a", "b")},
+	})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if len(order) != 2 || order[0] != "partition" || order[1] != "kill" {
+		t.Errorf("Expected partition then kill, got %v", order)
+	}
+	if len(in.Rules()) != 0 {
+		t.Errorf("Expected the partition healed, got %v", in.Rules())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := in.Run(ctx, []Step{{At: time.Hour, Do: Clear()}}); err != context.Canceled {
+		t.Errorf("Expected Canceled, got %v", err)
+	}
+}
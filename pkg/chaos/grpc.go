@@ -0,0 +1,121 @@
+package chaos
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// fromKey is the gRPC metadata key carrying the calling node's name
+const fromKey = "x-chaos-from"
+
+// appliedKey marks calls whose caller already applied the rules
+const appliedKey = "x-chaos-applied"
+
+// DialOptions tag the calls made on a connection with the caller's node
+// name, so in's rules can match them by From. A nil injector needs none.
+func DialOptions(in *Injector, node string) []grpc.DialOption {
+	if in == nil {
+		return nil
+	}
+	return []grpc.DialOption{
+		grpc.WithChainUnaryInterceptor(func(ctx context.Context, method string, req, reply any,
+			cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+			return invoker(metadata.AppendToOutgoingContext(ctx, fromKey, node), method, req, reply, cc, opts...)
+		}),
+		grpc.WithChainStreamInterceptor(func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn,
+			method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+			return streamer(metadata.AppendToOutgoingContext(ctx, fromKey, node), desc, cc, method, opts...)
+		}),
+	}
+}
+
+// ClientOptions apply in's rules to the calls made on a connection from
+// node to target at the caller, before they are sent: calls are delayed,
+// and dropped ones fail with codes.Unavailable without reaching target.
+// This injects faults on links to servers not running with the injector.
+// Calls are tagged as by DialOptions and marked as faulted, so servers
+// sharing in don't apply the rules to them again. Streams are subject to
+// the rules when they open. A nil injector needs none.
+func ClientOptions(in *Injector, node, target string) []grpc.DialOption {
+	if in == nil {
+		return nil
+	}
+	tag := func(ctx context.Context) context.Context {
+		return metadata.AppendToOutgoingContext(ctx, fromKey, node, appliedKey, "1")
+	}
+	return []grpc.DialOption{
+		grpc.WithChainUnaryInterceptor(func(ctx context.Context, method string, req, reply any,
+			cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+			if err := in.fail(ctx, node, target, method); err != nil {
+				return err
+			}
+			return invoker(tag(ctx), method, req, reply, cc, opts...)
+		}),
+		grpc.WithChainStreamInterceptor(func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn,
+			method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+			if err := in.fail(ctx, node, target, method); err != nil {
+				return nil, err
+			}
+			return streamer(tag(ctx), desc, cc, method, opts...)
+		}),
+	}
+}
+
+// ServerOptions apply in's rules to every call a server running as node
+// receives: calls are delayed before their handler runs, and dropped ones
+// fail with codes.Unavailable as if the server were unreachable. Calls
+// already faulted by their caller (ClientOptions) pass untouched. Streams
+// are subject to the rules when they open. A nil injector needs none.
+func ServerOptions(in *Injector, node string) []grpc.ServerOption {
+	if in == nil {
+		return nil
+	}
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(func(ctx context.Context, req any, info *grpc.UnaryServerInfo,
+			handler grpc.UnaryHandler) (any, error) {
+			if err := in.intercept(ctx, node, info.FullMethod); err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.ChainStreamInterceptor(func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo,
+			handler grpc.StreamHandler) error {
+			if err := in.intercept(ss.Context(), node, info.FullMethod); err != nil {
+				return err
+			}
+			return handler(srv, ss)
+		}),
+	}
+}
+
+// intercept applies the rules to an incoming call, returning the error to
+// fail it with
+func (in *Injector) intercept(ctx context.Context, node, method string) error {
+	var from string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if len(md.Get(appliedKey)) > 0 {
+			return nil
+		}
+		if values := md.Get(fromKey); len(values) > 0 {
+			from = values[0]
+		}
+	}
+	return in.fail(ctx, from, node, method)
+}
+
+// fail applies the rules to a call from from to to, returning the error to
+// fail it with
+func (in *Injector) fail(ctx context.Context, from, to, method string) error {
+	drop, rule, err := in.apply(ctx, from, to, method)
+	if err != nil {
+		return status.FromContextError(err).Err()
+	}
+	if drop {
+		return status.Errorf(codes.Unavailable, "chaos: call dropped by rule %q", rule)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package chaos
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// Step is one action of a failure scenario
+type Step struct {
+	At   time.Duration // Offset from the start of the scenario
+	Name string        // Logged when the step runs
+	Do   func(in *Injector)
+}
+
+// Run plays steps in order of At, starting now, and returns once the last
+// has run. Cancelling ctx stops the scenario; the faults already injected
+// stay in place.
+func (in *Injector) Run(ctx context.Context, steps []Step) error {
+	steps = append([]Step(nil), steps...)
+	sort.SliceStable(steps, func(i, j int) bool { return steps[i].At < steps[j].At })
+
+	start := time.Now()
+	for _, step := range steps {
+		timer := time.NewTimer(time.Until(start.Add(step.At)))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+
+		in.log.Info("Running chaos step", "step", step.Name, "at", step.At)
+		step.Do(in)
+	}
+	return nil
+}
+
+// Schedule arranges for steps to run at their offsets from now on the
+// injector's clock, without waiting for them, and returns a function
+// cancelling the steps not yet run. On a virtual clock the steps run as the
+// clock is advanced past them, in the advancing goroutine, so they land at
+// the same point of a simulation on every run.
+func (in *Injector) Schedule(steps []Step) (cancel func()) {
+	in.mu.RLock()
+	c := in.clock
+	in.mu.RUnlock()
+
+	stops := make([]func() bool, 0, len(steps))
+	for _, step := range steps {
+		step := step
+		stops = append(stops, c.AfterFunc(step.At, func() {
+			in.log.Info("Running chaos step", "step", step.Name, "at", step.At)
+			step.Do(in)
+		}))
+	}
+	return func() {
+		for _, stop := range stops {
+			stop()
+		}
+	}
+}
+
+// Kill is a step action killing node
+func Kill(node string) func(*Injector) {
+	return func(in *Injector) { in.Kill(node) }
+}
+
+// AddRule is a step action installing rule
+func AddRule(rule Rule) func(*Injector) {
+	return func(in *Injector) { in.Add(rule) }
+}
+
+// RemoveRule is a step action uninstalling the rule called name
+func RemoveRule(name string) func(*Injector) {
+	return func(in *Injector) { in.Remove(name) }
+}
+
+// Partition is a step action cutting a and b off from each other
+func Partition(a, b string) func(*Injector) {
+	return func(in *Injector) { in.Partition(a, b) }
+}
+
+// Heal is a step action undoing Partition(a, b)
+func Heal(a, b string) func(*Injector) {
+	return func(in *Injector) { in.Heal(a, b) }
+}
+
+// Clear is a step action uninstalling every rule
+func Clear() func(*Injector) {
+	return func(in *Injector) { in.Clear() }
+}
@@ -0,0 +1,122 @@
+// Package chaterr defines the errors the client and server return for the
+// failures callers branch on. They are matched with errors.Is, and a
+// server's refusal of a request is a *Rejection, read with errors.As:
+//
+//	resp, err := c.SendMessage("chat-1", "alice", "Hello")
+//	switch {
+//	case errors.Is(err, chaterr.ErrRateLimited):
+//		// Slow down; another server won't help
+//	case errors.Is(err, chaterr.ErrAllReplicasFailed):
+//		// Every server the chat could go to failed
+//	}
+package chaterr
+
+import (
+	"errors"
+	"fmt"
+
+	pb "github.com/sh4shv4t/DistriChat/proto"
+)
+
+var (
+	// ErrNoServers is returned when the ring has no server for a chat
+	ErrNoServers = errors.New("no servers available")
+
+	// ErrAllReplicasFailed is returned when the owner of a chat and every
+	// successor tried failed or refused; it wraps the last failure
+	ErrAllReplicasFailed = errors.New("all servers exhausted")
+
+	// ErrNotOwner is returned when a server doesn't own the chat it was
+	// sent: routed with a stale ring, in another namespace, or outside its
+	// ownership lease
+	ErrNotOwner = errors.New("server does not own the chat")
+
+	// ErrRateLimited is returned when a sender is over its quota
+	ErrRateLimited = errors.New("sender is rate limited")
+
+	// ErrDraining is returned when a server is draining or shutting down
+	// and accepts no writes
+	ErrDraining = errors.New("server is not accepting writes")
+
+	// ErrUnauthenticated is returned when a server can't tell who is
+	// calling: no credentials, or invalid ones
+	ErrUnauthenticated = errors.New("caller is not authenticated")
+
+	// ErrPermissionDenied is returned when the caller isn't allowed into
+	// the chat
+	ErrPermissionDenied = errors.New("caller is not allowed in the chat")
+
+	// ErrPolicyRejected is returned when a message policy refuses a
+	// message as spam or abuse
+	ErrPolicyRejected = errors.New("message rejected by policy")
+
+	// ErrSlowConsumer ends a subscription that fell too far behind the
+	// chat's messages; resubscribing after the last one received resumes it
+	ErrSlowConsumer = errors.New("subscriber fell too far behind")
+)
+
+// Rejection is a server's refusal of a request: the error code and details
+// of its response. It matches the sentinel for its code under errors.Is.
+type Rejection struct {
+	ServerID string
+	Op       string // What was refused, e.g. "request" or "history read"
+	Code     pb.ErrorCode
+	Details  string
+}
+
+func (r *Rejection) Error() string {
+	return fmt.Sprintf("server %s rejected %s: %s: %s", r.ServerID, r.Op, r.Code, r.Details)
+}
+
+// Unwrap returns the sentinel for the rejection's code, or nil
+func (r *Rejection) Unwrap() error {
+	return ForCode(r.Code)
+}
+
+// ForCode returns the sentinel for a response's error code, or nil for
+// codes without one
+func ForCode(code pb.ErrorCode) error {
+	switch code {
+	case pb.ErrorCode_ERROR_NOT_OWNER, pb.ErrorCode_ERROR_NO_LEASE:
+		return ErrNotOwner
+	case pb.ErrorCode_ERROR_RATE_LIMITED:
+		return ErrRateLimited
+	case pb.ErrorCode_ERROR_DRAINING:
+		return ErrDraining
+	case pb.ErrorCode_ERROR_UNAUTHENTICATED:
+		return ErrUnauthenticated
+	case pb.ErrorCode_ERROR_PERMISSION_DENIED:
+		return ErrPermissionDenied
+	case pb.ErrorCode_ERROR_POLICY_REJECTED:
+		return ErrPolicyRejected
+	case pb.ErrorCode_ERROR_SLOW_CONSUMER:
+		return ErrSlowConsumer
+	default:
+		return nil
+	}
+}
+
+// Code returns the response error code for err: ERROR_NOT_OWNER,
+// ERROR_RATE_LIMITED, ERROR_DRAINING, ERROR_UNAUTHENTICATED,
+// ERROR_PERMISSION_DENIED, ERROR_POLICY_REJECTED or ERROR_SLOW_CONSUMER for
+// errors wrapping their sentinels, and fallback for the rest
+func Code(err error, fallback pb.ErrorCode) pb.ErrorCode {
+	switch {
+	case errors.Is(err, ErrNotOwner):
+		return pb.ErrorCode_ERROR_NOT_OWNER
+	case errors.Is(err, ErrRateLimited):
+		return pb.ErrorCode_ERROR_RATE_LIMITED
+	case errors.Is(err, ErrDraining):
+		return pb.ErrorCode_ERROR_DRAINING
+	case errors.Is(err, ErrUnauthenticated):
+		return pb.ErrorCode_ERROR_UNAUTHENTICATED
+	case errors.Is(err, ErrPermissionDenied):
+		return pb.ErrorCode_ERROR_PERMISSION_DENIED
+	case errors.Is(err, ErrPolicyRejected):
+		return pb.ErrorCode_ERROR_POLICY_REJECTED
+	case errors.Is(err, ErrSlowConsumer):
+		return pb.ErrorCode_ERROR_SLOW_CONSUMER
+	default:
+		return fallback
+	}
+}
@@ -0,0 +1,54 @@
+package chaterr
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	pb "github.com/sh4shv4t/DistriChat/proto"
+)
+
+func TestRejectionMatchesItsSentinel(t *testing.T) {
+	err := fmt.Errorf("%w: %w", ErrAllReplicasFailed, &Rejection{
+		ServerID: "server-a",
+		Op:       "request",
+		Code:     pb.ErrorCode_ERROR_RATE_LIMITED,
+		Details:  "sender alice exceeded 10 messages/s",
+	})
+
+	if !errors.Is(err, ErrAllReplicasFailed) || !errors.Is(err, ErrRateLimited) {
+		t.Errorf("Expected the error to match ErrAllReplicasFailed and ErrRateLimited, got %v", err)
+	}
+	if errors.Is(err, ErrDraining) {
+		t.Errorf("Expected the error not to match ErrDraining")
+	}
+
+	var rejection *Rejection
+	if !errors.As(err, &rejection) || rejection.ServerID != "server-a" {
+		t.Errorf("Expected the rejection by server-a, got %v", rejection)
+	}
+}
+
+func TestCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		want pb.ErrorCode
+	}{
+		{fmt.Errorf("%w: stale ring epoch", ErrNotOwner), pb.ErrorCode_ERROR_NOT_OWNER},
+		{fmt.Errorf("%w: server is draining", ErrDraining), pb.ErrorCode_ERROR_DRAINING},
+		{ErrRateLimited, pb.ErrorCode_ERROR_RATE_LIMITED},
+		{fmt.Errorf("%w: no client certificate", ErrUnauthenticated), pb.ErrorCode_ERROR_UNAUTHENTICATED},
+		{fmt.Errorf("%w: alice is not a member of chat-1", ErrPermissionDenied), pb.ErrorCode_ERROR_PERMISSION_DENIED},
+		{fmt.Errorf("%w: banned content", ErrPolicyRejected), pb.ErrorCode_ERROR_POLICY_REJECTED},
+		{fmt.Errorf("%w: 256 messages behind", ErrSlowConsumer), pb.ErrorCode_ERROR_SLOW_CONSUMER},
+		{errors.New("disk full"), pb.ErrorCode_ERROR_INTERNAL},
+	}
+	for _, tt := range tests {
+		if got := Code(tt.err, pb.ErrorCode_ERROR_INTERNAL); got != tt.want {
+			t.Errorf("Expected %s for %q, got %s", tt.want, tt.err, got)
+		}
+		if sentinel := ForCode(tt.want); tt.want != pb.ErrorCode_ERROR_INTERNAL && !errors.Is(tt.err, sentinel) {
+			t.Errorf("Expected %q to match the sentinel of %s", tt.err, tt.want)
+		}
+	}
+}
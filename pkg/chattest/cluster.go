@@ -0,0 +1,162 @@
+// Package chattest runs DistriChat clusters in memory for tests. Servers
+// serve on bufconn listeners instead of TCP ports, and clients and peers
+// dial them through the same in-memory network, so integration tests are
+// hermetic and can run in parallel without port conflicts or races on
+// listeners.
+package chattest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/sh4shv4t/DistriChat/pkg/client"
+	"github.com/sh4shv4t/DistriChat/pkg/ring"
+	"github.com/sh4shv4t/DistriChat/pkg/server"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// bufferSize is each in-memory connection's buffer
+const bufferSize = 1 << 20
+
+// ErrNoListener is returned when dialing an address nothing listens on
+var ErrNoListener = errors.New("no listener at address")
+
+// Network is an in-memory network of listeners addressed by name
+type Network struct {
+	mu        sync.Mutex
+	listeners map[string]*bufconn.Listener
+}
+
+// NewNetwork creates an empty network
+func NewNetwork() *Network {
+	return &Network{listeners: make(map[string]*bufconn.Listener)}
+}
+
+// Listen creates the listener at address, replacing any earlier one (e.g.
+// of a server being restarted)
+func (n *Network) Listen(address string) net.Listener {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	l := bufconn.Listen(bufferSize)
+	n.listeners[address] = l
+	return l
+}
+
+// Dial connects to the listener at address. It has the signature of
+// ServerConfig.Dialer and ClientConfig.Dialer.
+func (n *Network) Dial(ctx context.Context, address string) (net.Conn, error) {
+	n.mu.Lock()
+	l, ok := n.listeners[address]
+	n.mu.Unlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w %s", ErrNoListener, address)
+	}
+	return l.DialContext(ctx)
+}
+
+// ClusterConfig describes an in-memory cluster
+type ClusterConfig struct {
+	Servers  int // Number of servers (default: 3)
+	Capacity int // Virtual node weight of each server (default: 100)
+
+	// Server, if set, adjusts each server's config before it starts. The
+	// listener, dialer and address are set by the cluster.
+	Server func(config *server.ServerConfig)
+
+	// Client is the cluster client's config; its Dialer is set by the
+	// cluster
+	Client client.ClientConfig
+}
+
+// Cluster is a set of servers sharing a ring, and a client routing to
+// them, all connected in memory
+type Cluster struct {
+	Network *Network
+	Servers []*server.ChatServer
+	Client  *client.SmartClient
+
+	state ring.RingState
+	t     testing.TB
+}
+
+// NewCluster starts a cluster whose servers are named server-1, server-2
+// and so on (their IDs and addresses alike). Everything is stopped when t
+// finishes.
+func NewCluster(t testing.TB, config ClusterConfig) *Cluster {
+	t.Helper()
+
+	if config.Servers <= 0 {
+		config.Servers = 3
+	}
+	if config.Capacity <= 0 {
+		config.Capacity = 100
+	}
+
+	c := &Cluster{Network: NewNetwork(), t: t}
+	c.state.Epoch = 1
+	for i := 1; i <= config.Servers; i++ {
+		id := fmt.Sprintf("server-%d", i)
+		c.state.Nodes = append(c.state.Nodes, ring.NodeSpec{NodeID: id, Address: id, Capacity: config.Capacity})
+	}
+
+	for _, node := range c.state.Nodes {
+		serverConfig := server.ServerConfig{ServerID: node.NodeID}
+		if config.Server != nil {
+			config.Server(&serverConfig)
+		}
+		serverConfig.AdvertiseAddress = node.Address
+		serverConfig.Listener = c.Network.Listen(node.Address)
+		serverConfig.Dialer = c.Network.Dial
+
+		srv := server.NewChatServer(serverConfig)
+		if err := srv.Start(); err != nil {
+			t.Fatalf("Failed to start %s: %v", node.NodeID, err)
+		}
+		t.Cleanup(srv.Stop)
+		srv.SetRingState(c.state)
+		c.Servers = append(c.Servers, srv)
+	}
+
+	c.Client = c.NewClient(config.Client)
+	return c
+}
+
+// NewClient creates another client of the cluster, closed when the test
+// finishes
+func (c *Cluster) NewClient(config client.ClientConfig) *client.SmartClient {
+	config.Dialer = c.Network.Dial
+	cl := client.NewSmartClient(config)
+	cl.ApplyRingState(c.state)
+	c.t.Cleanup(cl.Close)
+	return cl
+}
+
+// Server returns the server called id, or nil
+func (c *Cluster) Server(id string) *server.ChatServer {
+	for _, srv := range c.Servers {
+		if srv.GetServerID() == id {
+			return srv
+		}
+	}
+	return nil
+}
+
+// Kill stops the server called id; calls to it fail from now on
+func (c *Cluster) Kill(id string) {
+	srv := c.Server(id)
+	if srv == nil {
+		c.t.Fatalf("No server %s in the cluster", id)
+	}
+	srv.Stop()
+}
+
+// RingState returns the ring the cluster was started with
+func (c *Cluster) RingState() ring.RingState {
+	return c.state
+}
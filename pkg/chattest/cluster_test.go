@@ -0,0 +1,1755 @@
+package chattest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/pkg/archive"
+	"github.com/sh4shv4t/DistriChat/pkg/audit"
+	"github.com/sh4shv4t/DistriChat/pkg/auth"
+	"github.com/sh4shv4t/DistriChat/pkg/chaos"
+	"github.com/sh4shv4t/DistriChat/pkg/chaterr"
+	"github.com/sh4shv4t/DistriChat/pkg/client"
+	"github.com/sh4shv4t/DistriChat/pkg/dedup"
+	"github.com/sh4shv4t/DistriChat/pkg/importer"
+	"github.com/sh4shv4t/DistriChat/pkg/msglog"
+	"github.com/sh4shv4t/DistriChat/pkg/notify"
+	"github.com/sh4shv4t/DistriChat/pkg/outbox"
+	"github.com/sh4shv4t/DistriChat/pkg/policy"
+	"github.com/sh4shv4t/DistriChat/pkg/rebalance"
+	"github.com/sh4shv4t/DistriChat/pkg/ring"
+	"github.com/sh4shv4t/DistriChat/pkg/search"
+	"github.com/sh4shv4t/DistriChat/pkg/server"
+	"github.com/sh4shv4t/DistriChat/pkg/transform"
+	pb "github.com/sh4shv4t/DistriChat/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestClusterRoutesAndFailsOver(t *testing.T) {
+	t.Parallel()
+	c := NewCluster(t, ClusterConfig{Client: client.ClientConfig{ConnectTimeout: time.Second}})
+
+	resp, err := c.Client.SendMessage("chat-1", "alice", "hello")
+	if err != nil {
+		t.Fatalf("SendMessage failed: %v", err)
+	}
+	owner, _, _ := c.Client.GetTargetServer("chat-1")
+	if resp.ServerId != owner {
+		t.Errorf("Expected %s to serve chat-1, got %s", owner, resp.ServerId)
+	}
+
+	c.Kill(owner)
+	resp, err = c.Client.SendMessage("chat-1", "alice", "still there?")
+	if err != nil {
+		t.Fatalf("Expected failover, got %v", err)
+	}
+	if resp.ServerId == owner {
+		t.Errorf("Expected another server than the killed %s", owner)
+	}
+	if got := c.Client.GetStats().FailoverCount; got == 0 {
+		t.Errorf("Expected a failover to be counted")
+	}
+}
+
+func TestClusterReplicatesInMemory(t *testing.T) {
+	t.Parallel()
+	c := NewCluster(t, ClusterConfig{
+		Server: func(config *server.ServerConfig) {
+			config.Replication = server.ReplicationConfig{N: 3, W: 3}
+		},
+		Client: client.ClientConfig{ReplicationFactor: 3},
+	})
+
+	if _, err := c.Client.SendMessage("chat-1", "alice", "hello"); err != nil {
+		t.Fatalf("SendMessage failed: %v", err)
+	}
+	for _, srv := range c.Servers {
+		if got := srv.GetCacheInfo().L1Size + srv.GetCacheInfo().L2Size; got != 1 {
+			t.Errorf("Expected %s to hold chat-1, got %d sessions", srv.GetServerID(), got)
+		}
+	}
+}
+
+func TestClusterReadRepair(t *testing.T) {
+	t.Parallel()
+	injector := chaos.New()
+	c := NewCluster(t, ClusterConfig{
+		Server: func(config *server.ServerConfig) {
+			config.Replication = server.ReplicationConfig{N: 3, W: 2, R: 3}
+			config.Chaos = injector
+		},
+		Client: client.ClientConfig{ReplicationFactor: 3},
+	})
+	local := func(id string) int {
+		history, _ := c.Server(id).GetHistory(context.Background(), &pb.HistoryRequest{ChatId: "chat-1", Local: true})
+		return len(history.GetMessages())
+	}
+
+	// A replica cut off from the coordinator misses writes the others take
+	owner, _, _ := c.Client.GetTargetServer("chat-1")
+	stale := "server-1"
+	if owner == stale {
+		stale = "server-2"
+	}
+	injector.Partition(owner, stale)
+	for i := 1; i <= 3; i++ {
+		if _, err := c.Client.SendMessage("chat-1", "alice", fmt.Sprintf("hello %d", i)); err != nil {
+			t.Fatalf("SendMessage failed: %v", err)
+		}
+	}
+	injector.Heal(owner, stale)
+	if n := local(stale); n != 0 {
+		t.Fatalf("Expected %s to have missed the writes, it holds %d messages", stale, n)
+	}
+
+	// A quorum read answers from the merged copies and repairs the stale one
+	history, err := c.Client.GetHistory("chat-1", 0)
+	if err != nil || len(history.Messages) != 3 {
+		t.Fatalf("Expected 3 messages read, got %v (%v)", history.GetMessages(), err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for local(stale) != 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %s repaired to 3 messages, it holds %d", stale, local(stale))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestClusterConsistencyLevels(t *testing.T) {
+	t.Parallel()
+	injector := chaos.New()
+	c := NewCluster(t, ClusterConfig{
+		Server: func(config *server.ServerConfig) {
+			config.Replication = server.ReplicationConfig{N: 3}
+			config.Chaos = injector
+		},
+		Client: client.ClientConfig{ReplicationFactor: 3},
+	})
+	if _, err := c.Client.SendMessage("chat-1", "alice", "before the partition"); err != nil {
+		t.Fatalf("SendMessage failed: %v", err)
+	}
+
+	// One replica is cut off from the other two, so whichever server
+	// coordinates reaches at most two of the three
+	owner, _, _ := c.Client.GetTargetServer("chat-1")
+	partitioned := "server-1"
+	if owner == partitioned {
+		partitioned = "server-2"
+	}
+	for _, srv := range c.Servers {
+		if id := srv.GetServerID(); id != partitioned {
+			injector.Partition(partitioned, id)
+		}
+	}
+
+	tests := []struct {
+		level pb.ConsistencyLevel
+		ok    bool
+	}{
+		{pb.ConsistencyLevel_CONSISTENCY_ONE, true},
+		{pb.ConsistencyLevel_CONSISTENCY_QUORUM, true},
+		{pb.ConsistencyLevel_CONSISTENCY_ALL, false},
+	}
+	quorumFailed := func(err error) bool {
+		var rejection *chaterr.Rejection
+		return errors.As(err, &rejection) && rejection.Code == pb.ErrorCode_ERROR_QUORUM_FAILED
+	}
+	for _, tt := range tests {
+		t.Run(tt.level.String(), func(t *testing.T) {
+			_, err := c.Client.SendMessage("chat-1", "alice", "hello", client.WithConsistency(tt.level))
+			if tt.ok && err != nil {
+				t.Errorf("Expected the write to succeed, got %v", err)
+			}
+			if !tt.ok && !quorumFailed(err) {
+				t.Errorf("Expected the write to fail its quorum, got %v", err)
+			}
+
+			history, err := c.Client.GetHistory("chat-1", 0, client.WithConsistency(tt.level))
+			if tt.ok && (err != nil || len(history.Messages) == 0) {
+				t.Errorf("Expected the read to succeed, got %v (%v)", history.GetMessages(), err)
+			}
+			if !tt.ok && !quorumFailed(err) {
+				t.Errorf("Expected the read to fail its quorum, got %v", err)
+			}
+		})
+	}
+}
+
+func TestClusterDedupSurvivesRestart(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "dedup.log")
+	start := func() (*Cluster, *dedup.FileStore) {
+		store, err := dedup.OpenFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { store.Close() })
+		return NewCluster(t, ClusterConfig{
+			Servers: 1,
+			Server: func(config *server.ServerConfig) {
+				config.DedupStore = store
+			},
+		}), store
+	}
+	request := func() *pb.ChatRequest {
+		return &pb.ChatRequest{
+			ChatId:    "chat-1",
+			SenderId:  "alice",
+			MessageId: "m-retried",
+			Content:   &pb.ChatRequest_Text{Text: "hello"},
+		}
+	}
+
+	c, store := start()
+	first, err := c.Client.SendRequest(request())
+	if err != nil || first.Duplicate {
+		t.Fatalf("Expected the message stored, got %v (%v)", first, err)
+	}
+	c.Kill("server-1")
+	store.Close()
+
+	// The restarted server has lost its cache but not the message's ID
+	c, _ = start()
+	retry, err := c.Client.SendRequest(request())
+	if err != nil {
+		t.Fatalf("SendRequest failed: %v", err)
+	}
+	if !retry.Duplicate || retry.Seq != first.Seq {
+		t.Errorf("Expected the retry answered as a duplicate at seq %d, got %v", first.Seq, retry)
+	}
+	if history, _ := c.Client.GetHistory("chat-1", 0); len(history.GetMessages()) != 0 {
+		t.Errorf("Expected the retry not stored again, got %v", history.GetMessages())
+	}
+	if _, err := c.Client.SendMessage("chat-1", "alice", "new"); err != nil {
+		t.Errorf("Expected new messages still accepted, got %v", err)
+	}
+}
+
+// failingDedupStore fails to record messages while failing is set
+type failingDedupStore struct {
+	*dedup.MemoryStore
+	failing atomic.Bool
+}
+
+func (s *failingDedupStore) Put(entry dedup.Entry) error {
+	if s.failing.Load() {
+		return errors.New("disk full")
+	}
+	return s.MemoryStore.Put(entry)
+}
+
+func TestClusterDedupFailureFailsWrite(t *testing.T) {
+	t.Parallel()
+	store := &failingDedupStore{MemoryStore: dedup.NewMemoryStore()}
+	c := NewCluster(t, ClusterConfig{
+		Servers: 1,
+		Server:  func(config *server.ServerConfig) { config.DedupStore = store },
+	})
+	request := &pb.ChatRequest{ChatId: "chat-1", SenderId: "alice", MessageId: "m-1",
+		Content: &pb.ChatRequest_Text{Text: "hello"}}
+
+	store.failing.Store(true)
+	if _, err := c.Client.SendRequest(request); err == nil {
+		t.Fatal("Expected the write failed while its ID can't be recorded")
+	}
+
+	// The retry is collapsed by the cache and records the message
+	store.failing.Store(false)
+	retry, err := c.Client.SendRequest(request)
+	if err != nil || retry.Seq != 1 {
+		t.Fatalf("Expected the retry to succeed at seq 1, got %v (%v)", retry, err)
+	}
+	if entry, ok, _ := store.Get("chat-1", "m-1"); !ok || entry.Seq != 1 {
+		t.Errorf("Expected m-1 recorded at seq 1, got %+v", entry)
+	}
+}
+
+func TestClusterTinyExpiryWindows(t *testing.T) {
+	t.Parallel()
+	// Expiry loops tick at least once a second however short the window
+	c := NewCluster(t, ClusterConfig{
+		Servers: 1,
+		Server: func(config *server.ServerConfig) {
+			config.DedupWindow = 5 * time.Nanosecond
+			config.CursorTTL = 5 * time.Nanosecond
+		},
+	})
+	if _, err := c.Client.SendMessage("chat-1", "alice", "hello"); err != nil {
+		t.Fatalf("SendMessage failed: %v", err)
+	}
+}
+
+func TestClusterOutboxDeliversAfterCrash(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "outbox.log")
+
+	// A message accepted and committed just before the server crashed
+	crashed, err := outbox.OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crashed.Add(&pb.StoredMessage{
+		MessageId: "m-before-crash",
+		Seq:       1,
+		Request: &pb.ChatRequest{
+			ChatId:    "chat-1",
+			SenderId:  "alice",
+			Timestamp: time.Now().Unix(),
+			Content:   &pb.ChatRequest_Text{Text: "accepted before the crash"},
+		},
+	}, time.Now())
+	crashed.Close()
+
+	store, err := outbox.OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	messageLog := msglog.NewMemoryLog(1)
+	c := NewCluster(t, ClusterConfig{
+		Servers: 1,
+		Server: func(config *server.ServerConfig) {
+			config.Outbox = store
+			config.MessageLog = messageLog
+		},
+	})
+	waitFor := func(what string, done func() bool) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for !done() {
+			if time.Now().After(deadline) {
+				t.Fatalf("Timed out waiting for %s", what)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	history, err := c.Client.GetHistory("chat-1", 0)
+	if err != nil || len(history.Messages) != 1 || history.Messages[0].MessageId != "m-before-crash" {
+		t.Fatalf("Expected the committed message restored, got %v (%v)", history.GetMessages(), err)
+	}
+	waitFor("the committed message published", func() bool { return messageLog.Len() == 1 && store.Len() == 0 })
+
+	// New messages go through the outbox too
+	sub, err := c.Client.Subscribe("chat-1", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+	if _, err := c.Client.SendMessage("chat-1", "bob", "after the restart"); err != nil {
+		t.Fatalf("SendMessage failed: %v", err)
+	}
+	select {
+	case msg := <-sub.Messages():
+		if msg.GetRequest().GetText() != "after the restart" {
+			t.Errorf("Expected the new message delivered, got %v", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("New message never delivered")
+	}
+	waitFor("the new message published", func() bool { return messageLog.Len() == 2 && store.Len() == 0 })
+}
+
+func TestClusterImport(t *testing.T) {
+	t.Parallel()
+	c := NewCluster(t, ClusterConfig{
+		Servers: 3,
+		Server: func(config *server.ServerConfig) {
+			config.Replication = server.ReplicationConfig{N: 2, W: 2}
+		},
+	})
+
+	export := `{"chat": "general", "sender": "bob", "timestamp": "2024-01-01T10:02:00Z", "text": "welcome aboard"}
+{"chat": "random", "sender": "carol", "timestamp": "2024-01-01T10:00:00Z", "text": "lunch?"}
+{"chat": "general", "sender": "alice", "timestamp": "2024-01-01T10:01:00Z", "text": "first!"}
+{"chat": "general", "sender": "alice", "timestamp": "2024-01-01T10:03:00Z", "text": "thanks"}
+`
+	reader, _ := importer.NewReader("jsonl", strings.NewReader(export))
+	records, err := importer.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessions := importer.Sessions(records)
+	im := importer.New(importer.Config{Replicas: 2}, grpc.WithContextDialer(c.Network.Dial))
+	defer im.Close()
+
+	result, err := im.Import(context.Background(), c.RingState(), sessions)
+	if err != nil || result.Sessions != 2 || result.Messages != 8 {
+		t.Fatalf("Expected 2 chats imported to 2 replicas each, got %+v (%v)", result, err)
+	}
+
+	// Both replicas hold the history, in the export's time order
+	replicas := 0
+	for _, srv := range c.Servers {
+		history, _ := srv.GetHistory(context.Background(), &pb.HistoryRequest{ChatId: "general", Local: true})
+		if messages := history.GetMessages(); len(messages) > 0 {
+			replicas++
+			if len(messages) != 3 || messages[0].Request.GetText() != "first!" || messages[2].Request.GetText() != "thanks" {
+				t.Errorf("Expected the imported history on %s, got %v", srv.GetServerID(), messages)
+			}
+		}
+	}
+	if replicas != 2 {
+		t.Errorf("Expected general on 2 replicas, found it on %d", replicas)
+	}
+
+	// Rerunning the import adds nothing
+	if result, err := im.Import(context.Background(), c.RingState(), sessions); err != nil || result.Messages != 0 {
+		t.Errorf("Expected a rerun to add nothing, got %+v (%v)", result, err)
+	}
+
+	// New messages follow the imported ones
+	resp, err := c.Client.SendMessage("general", "bob", "back to the present")
+	if err != nil || resp.Seq != 4 {
+		t.Fatalf("Expected the new message numbered after the import, got %v (%v)", resp, err)
+	}
+	history, err := c.Client.GetHistory("general", 0)
+	if err != nil || len(history.Messages) != 4 || history.Messages[3].Request.GetText() != "back to the present" {
+		t.Errorf("Expected 4 messages, the new one last, got %v (%v)", history.GetMessages(), err)
+	}
+
+	// A chat written to is refused, since imported numbers would collide
+	// with its own; the rest of the export is imported
+	result, err = im.Import(context.Background(), c.RingState(), importer.Sessions(append(records,
+		importer.Record{ChatID: "later", SenderID: "dave", Timestamp: time.Unix(1704103200, 0), Text: "new chat"})))
+	if err == nil || len(result.Refused) != 1 || result.Refused[0] != "general" || result.Messages != 2 {
+		t.Errorf("Expected general refused and later imported to 2 replicas, got %+v (%v)", result, err)
+	}
+	if history, _ := c.Client.GetHistory("general", 0); len(history.GetMessages()) != 4 {
+		t.Errorf("Expected general left with its 4 messages, got %v", history.GetMessages())
+	}
+}
+
+func TestClusterAccessControl(t *testing.T) {
+	t.Parallel()
+	c := NewCluster(t, ClusterConfig{
+		Servers: 1,
+		Server: func(config *server.ServerConfig) {
+			config.AccessControl = &server.AccessControl{Admins: []string{"ops"}}
+			config.Authenticator = auth.AuthenticatorFunc(func(ctx context.Context) (auth.Principal, error) {
+				md, _ := metadata.FromIncomingContext(ctx)
+				if ids := md.Get("x-principal"); len(ids) > 0 {
+					return auth.Principal{ID: ids[0]}, nil
+				}
+				return auth.Principal{}, chaterr.ErrUnauthenticated
+			})
+		},
+	})
+
+	conn, err := grpc.Dial("server-1", grpc.WithContextDialer(c.Network.Dial),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	chat := pb.NewChatServiceClient(conn)
+	as := func(principal string) context.Context {
+		return metadata.AppendToOutgoingContext(context.Background(), "x-principal", principal)
+	}
+	post := func(ctx context.Context, req *pb.ChatRequest) pb.ErrorCode {
+		req.ChatId = "chat-1"
+		resp, err := chat.PostMessage(ctx, req)
+		if err != nil {
+			t.Fatalf("PostMessage failed: %v", err)
+		}
+		return resp.ErrorCode
+	}
+	event := func(eventType pb.SystemEventType, actor, member string) *pb.ChatRequest {
+		e := &pb.SystemEvent{Type: eventType, ActorId: actor}
+		if member != "" {
+			e.Details = map[string]string{"member_id": member}
+		}
+		return &pb.ChatRequest{SenderId: actor, Content: &pb.ChatRequest_SystemEvent{SystemEvent: e}}
+	}
+	text := func(sender string) *pb.ChatRequest {
+		return &pb.ChatRequest{SenderId: sender, Content: &pb.ChatRequest_Text{Text: "hello"}}
+	}
+
+	if got := post(context.Background(), text("alice")); got != pb.ErrorCode_ERROR_UNAUTHENTICATED {
+		t.Errorf("Expected an anonymous post refused as unauthenticated, got %s", got)
+	}
+	if got := post(as("alice"), text("alice")); got != pb.ErrorCode_ERROR_PERMISSION_DENIED {
+		t.Errorf("Expected a post to a chat without members refused, got %s", got)
+	}
+	if got := post(as("alice"), event(pb.SystemEventType_SYSTEM_EVENT_CHAT_CREATED, "alice", "")); got != pb.ErrorCode_ERROR_NONE {
+		t.Fatalf("Expected alice to create the chat, got %s", got)
+	}
+	if got := post(as("mallory"), event(pb.SystemEventType_SYSTEM_EVENT_MEMBER_JOINED, "alice", "mallory")); got != pb.ErrorCode_ERROR_PERMISSION_DENIED {
+		t.Errorf("Expected mallory refused posting events as alice, got %s", got)
+	}
+	if got := post(as("alice"), event(pb.SystemEventType_SYSTEM_EVENT_MEMBER_JOINED, "alice", "bob")); got != pb.ErrorCode_ERROR_NONE {
+		t.Fatalf("Expected alice to add bob, got %s", got)
+	}
+	if got := post(as("bob"), text("bob")); got != pb.ErrorCode_ERROR_NONE {
+		t.Errorf("Expected member bob to post, got %s", got)
+	}
+	if got := post(as("mallory"), text("mallory")); got != pb.ErrorCode_ERROR_PERMISSION_DENIED {
+		t.Errorf("Expected non-member mallory refused, got %s", got)
+	}
+
+	history := func(ctx context.Context, local bool) *pb.HistoryResponse {
+		resp, err := chat.GetHistory(ctx, &pb.HistoryRequest{ChatId: "chat-1", Local: local})
+		if err != nil {
+			t.Fatalf("GetHistory failed: %v", err)
+		}
+		return resp
+	}
+	if resp := history(as("bob"), false); !resp.Success || len(resp.Messages) != 3 {
+		t.Errorf("Expected bob to read 3 messages, got %v (%s)", len(resp.Messages), resp.ErrorDetails)
+	}
+	if resp := history(as("mallory"), false); resp.ErrorCode != pb.ErrorCode_ERROR_PERMISSION_DENIED || len(resp.Messages) != 0 {
+		t.Errorf("Expected mallory's read refused, got %s with %d messages", resp.ErrorCode, len(resp.Messages))
+	}
+	if resp := history(as("bob"), true); resp.ErrorCode != pb.ErrorCode_ERROR_PERMISSION_DENIED {
+		t.Errorf("Expected bob's local read refused, got %s", resp.ErrorCode)
+	}
+	if resp := history(as("ops"), true); !resp.Success {
+		t.Errorf("Expected admin ops to read the local copy, got %s", resp.ErrorDetails)
+	}
+}
+
+func TestClusterPeerCallsRequirePeers(t *testing.T) {
+	t.Parallel()
+	c := NewCluster(t, ClusterConfig{
+		Servers: 2,
+		Server: func(config *server.ServerConfig) {
+			config.AdminToken = "secret"
+			config.Replication = server.ReplicationConfig{N: 2, W: 2}
+		},
+	})
+
+	// Servers replicate to each other with the token...
+	if _, err := c.Client.SendMessage("chat-1", "alice", "hello"); err != nil {
+		t.Fatalf("Expected the write replicated, got %v", err)
+	}
+
+	// ...but a client without it can't act as one
+	conn, err := grpc.Dial("server-1", grpc.WithContextDialer(c.Network.Dial),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	forged := &pb.ReplicateRequest{Message: &pb.StoredMessage{MessageId: "forged", Seq: 99,
+		Request: &pb.ChatRequest{ChatId: "chat-1", SenderId: "alice"}}}
+	if _, err := pb.NewChatServiceClient(conn).Replicate(context.Background(), forged); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("Expected Replicate refused without the token, got %v", err)
+	}
+	ack, err := pb.NewChatServiceClient(conn).AckMessages(context.Background(),
+		&pb.AckRequest{ChatId: "chat-1", SubscriberId: "bob", Seq: 1, RelayedBy: "server-2"})
+	if err != nil || ack.ErrorCode != pb.ErrorCode_ERROR_PERMISSION_DENIED {
+		t.Errorf("Expected a forged relayed ack refused, got %v (%v)", ack, err)
+	}
+
+	purge := &pb.PurgeSenderRequest{SenderId: "alice", BeforeMs: time.Now().UnixMilli()}
+	if _, err := pb.NewPeerServiceClient(conn).PurgeSender(context.Background(), purge); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("Expected PurgeSender refused without the token, got %v", err)
+	}
+
+	migration := pb.NewMigrationServiceClient(conn)
+	export, err := migration.ExportSession(context.Background(), &pb.ExportSessionRequest{})
+	if err == nil {
+		_, err = export.Recv()
+	}
+	if status.Code(err) != codes.PermissionDenied {
+		t.Errorf("Expected ExportSession refused without the token, got %v", err)
+	}
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-admin-token", "secret")
+	im := importer.New(importer.Config{}, grpc.WithContextDialer(c.Network.Dial))
+	defer im.Close()
+	sessions := importer.Sessions([]importer.Record{{ChatID: "imported", SenderID: "bob", Timestamp: time.Unix(1, 0), Text: "old"}})
+	result, err := im.Import(context.Background(), c.RingState(), sessions)
+	for _, failure := range result.Failed {
+		if status.Code(failure) != codes.PermissionDenied {
+			t.Errorf("Expected PermissionDenied, got %v", failure)
+		}
+	}
+	if err == nil {
+		t.Error("Expected an import without the token refused")
+	}
+	if result, err := im.Import(ctx, c.RingState(), sessions); err != nil || result.Messages != 1 {
+		t.Errorf("Expected an import with the token accepted, got %+v (%v)", result, err)
+	}
+
+	// Over TLS, a certificate is a peer's only if it names a server of
+	// the ring: a client's verified certificate isn't enough
+	certs := newCertificates(t, "server-1", "server-2", "client")
+	tc := NewCluster(t, ClusterConfig{
+		Servers: 2,
+		Server: func(config *server.ServerConfig) {
+			config.TLS = certs[config.ServerID]
+			config.Replication = server.ReplicationConfig{N: 2, W: 2}
+		},
+		Client: client.ClientConfig{TLS: certs["client"]},
+	})
+	if _, err := tc.Client.SendMessage("chat-1", "alice", "hello"); err != nil {
+		t.Fatalf("Expected the write replicated over TLS, got %v", err)
+	}
+	tlsConn, err := grpc.Dial("server-1", grpc.WithContextDialer(tc.Network.Dial),
+		grpc.WithTransportCredentials(certs["client"].ClientCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tlsConn.Close()
+	if _, err := pb.NewChatServiceClient(tlsConn).Replicate(context.Background(), forged); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("Expected Replicate refused with a client's certificate, got %v", err)
+	}
+	if _, err := pb.NewPeerServiceClient(tlsConn).PurgeSender(context.Background(), purge); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("Expected PurgeSender refused with a client's certificate, got %v", err)
+	}
+}
+
+func TestClusterAPIKeys(t *testing.T) {
+	t.Parallel()
+	c := NewCluster(t, ClusterConfig{
+		Servers: 1,
+		Server: func(config *server.ServerConfig) {
+			config.APIKeys = auth.NewMemoryKeyStore()
+		},
+	})
+	admin := server.NewAdminServer(c.Servers[0])
+
+	conn, err := grpc.Dial("server-1", grpc.WithContextDialer(c.Network.Dial),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	chat := pb.NewChatServiceClient(conn)
+	withKey := func(key string) context.Context {
+		return metadata.AppendToOutgoingContext(context.Background(), auth.APIKeyHeader, key)
+	}
+	post := func(ctx context.Context) pb.ErrorCode {
+		resp, err := chat.PostMessage(ctx, &pb.ChatRequest{ChatId: "chat-1", SenderId: "bot",
+			Content: &pb.ChatRequest_Text{Text: "hello"}})
+		if err != nil {
+			t.Fatalf("PostMessage failed: %v", err)
+		}
+		return resp.ErrorCode
+	}
+
+	created, err := admin.CreateAPIKey(context.Background(), &pb.CreateAPIKeyRequest{
+		Principal: "bot", Scopes: []string{auth.ScopeWrite}})
+	if err != nil {
+		t.Fatalf("Expected the key issued, got %v", err)
+	}
+	if got := post(context.Background()); got != pb.ErrorCode_ERROR_UNAUTHENTICATED {
+		t.Errorf("Expected a post without a key refused, got %s", got)
+	}
+	bot := c.NewClient(client.ClientConfig{APIKey: created.Secret})
+	if _, err := bot.SendMessage("chat-1", "bot", "hello"); err != nil {
+		t.Errorf("Expected a client with the key to post, got %v", err)
+	}
+	resp, err := chat.GetHistory(withKey(created.Secret), &pb.HistoryRequest{ChatId: "chat-1"})
+	if err != nil || resp.ErrorCode != pb.ErrorCode_ERROR_PERMISSION_DENIED {
+		t.Errorf("Expected a read with a write-only key refused, got %v, %v", resp.GetErrorCode(), err)
+	}
+
+	if _, err := admin.RevokeAPIKey(context.Background(), &pb.RevokeAPIKeyRequest{Id: created.Key.Id}); err != nil {
+		t.Fatalf("Expected the key revoked, got %v", err)
+	}
+	if got := post(withKey(created.Secret)); got != pb.ErrorCode_ERROR_UNAUTHENTICATED {
+		t.Errorf("Expected a post with the revoked key refused, got %s", got)
+	}
+	listed, err := admin.ListAPIKeys(context.Background(), &pb.ListAPIKeysRequest{})
+	if err != nil || len(listed.Keys) != 1 || listed.Keys[0].RevokedMs == 0 {
+		t.Errorf("Expected the revoked key listed, got %v, %v", listed, err)
+	}
+}
+
+func TestClusterMessagePolicies(t *testing.T) {
+	t.Parallel()
+	c := NewCluster(t, ClusterConfig{
+		Servers: 1,
+		Server: func(config *server.ServerConfig) {
+			config.MessagePolicies = []policy.Policy{
+				policy.BannedContent(func(ctx context.Context, msg policy.Message) string {
+					if strings.Contains(msg.Content, "cheap pills") {
+						return "advertising"
+					}
+					return ""
+				}),
+			}
+		},
+	})
+
+	if _, err := c.Client.SendMessage("chat-1", "alice", "hello"); err != nil {
+		t.Errorf("Expected a clean message accepted, got %v", err)
+	}
+	_, err := c.Client.SendMessage("chat-1", "mallory", "buy cheap pills")
+	if !errors.Is(err, chaterr.ErrPolicyRejected) {
+		t.Errorf("Expected the spam refused by policy, got %v", err)
+	}
+
+	stats, err := server.NewAdminServer(c.Servers[0]).GetStatsSnapshot(context.Background(), &pb.StatsSnapshotRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.PolicyRejected != 1 || stats.PolicyFlagged != 0 {
+		t.Errorf("Expected 1 message rejected, got %d rejected and %d flagged", stats.PolicyRejected, stats.PolicyFlagged)
+	}
+}
+
+func TestClusterTransforms(t *testing.T) {
+	t.Parallel()
+	c := NewCluster(t, ClusterConfig{
+		Servers: 2,
+		Server: func(config *server.ServerConfig) {
+			config.Replication = server.ReplicationConfig{N: 2, W: 2}
+			config.Transforms = []transform.Stage{
+				transform.New("no_shouting", func(ctx context.Context, msg *transform.Message) error {
+					if msg.Text != "" && msg.Text == strings.ToUpper(msg.Text) {
+						return transform.Refuse("all caps")
+					}
+					return nil
+				}),
+				transform.RedactPII(),
+				transform.NormalizeEmoji(nil),
+			}
+		},
+	})
+
+	if _, err := c.Client.SendMessage("chat-1", "alice", "mail me at alice@example.com :wave:"); err != nil {
+		t.Fatalf("SendMessage failed: %v", err)
+	}
+	if _, err := c.Client.SendMessage("chat-1", "mallory", "BUY NOW"); !errors.Is(err, chaterr.ErrPolicyRejected) {
+		t.Errorf("Expected the shouting refused by a transform stage, got %v", err)
+	}
+
+	// Annotations sent by clients aren't kept
+	owner, _, _ := c.Client.GetTargetServer("chat-1")
+	resp, err := c.Server(owner).PostMessage(context.Background(), &pb.ChatRequest{
+		ChatId:      "chat-1",
+		SenderId:    "mallory",
+		Content:     &pb.ChatRequest_Text{Text: "hi"},
+		Annotations: map[string]string{"redacted": "none"},
+	})
+	if err != nil || !resp.Success {
+		t.Fatalf("PostMessage failed: %v %v", resp, err)
+	}
+
+	// Every replica holds the transformed message and its annotations
+	for _, srv := range c.Servers {
+		history, _ := srv.GetHistory(context.Background(), &pb.HistoryRequest{ChatId: "chat-1", Local: true})
+		messages := history.GetMessages()
+		if len(messages) != 2 {
+			t.Fatalf("Expected 2 messages on %s, got %v", srv.GetServerID(), messages)
+		}
+		first := messages[0].Request
+		if first.GetText() != "mail me at [email] 👋" || first.Annotations["redacted"] != "email" {
+			t.Errorf("Expected the address redacted and annotated on %s, got %q %v",
+				srv.GetServerID(), first.GetText(), first.Annotations)
+		}
+		if len(messages[1].Request.Annotations) != 0 {
+			t.Errorf("Expected the client's annotations dropped, got %v", messages[1].Request.Annotations)
+		}
+	}
+}
+
+func TestClusterAttachments(t *testing.T) {
+	t.Parallel()
+	store := archive.NewMemoryStore()
+	c := NewCluster(t, ClusterConfig{
+		Servers: 2,
+		Server: func(config *server.ServerConfig) {
+			config.Replication = server.ReplicationConfig{N: 2, W: 2}
+			config.Attachments = &server.AttachmentConfig{Store: store, MaxSize: 1 << 20}
+		},
+	})
+
+	// Several chunks' worth, streamed to a replica and kept off the chat
+	content := bytes.Repeat([]byte("0123456789abcdef"), 20000)
+	attachment, err := c.Client.UploadAttachment("chat-1", "alice", "notes.txt", "", bytes.NewReader(content))
+	if err != nil {
+		t.Fatalf("UploadAttachment failed: %v", err)
+	}
+	if attachment.SizeBytes != int64(len(content)) || attachment.Filename != "notes.txt" ||
+		!strings.HasPrefix(attachment.MimeType, "text/plain") {
+		t.Errorf("Unexpected attachment %v", attachment)
+	}
+	if _, err := c.Client.SendAttachment("chat-1", "alice", attachment); err != nil {
+		t.Fatalf("SendAttachment failed: %v", err)
+	}
+
+	history, err := c.Client.GetHistory("chat-1", 0)
+	if err != nil {
+		t.Fatalf("GetHistory failed: %v", err)
+	}
+	posted := history.Messages[0].Request.GetAttachment()
+	var downloaded bytes.Buffer
+	if _, err := c.Client.DownloadAttachment("chat-1", posted.GetUrl(), &downloaded); err != nil {
+		t.Fatalf("DownloadAttachment failed: %v", err)
+	}
+	if !bytes.Equal(downloaded.Bytes(), content) {
+		t.Errorf("Expected the content back, got %d bytes", downloaded.Len())
+	}
+
+	// An attachment is only reached through its own chat
+	var rejection *chaterr.Rejection
+	_, err = c.Client.DownloadAttachment("chat-2", posted.GetUrl(), io.Discard)
+	if !errors.As(err, &rejection) || rejection.Code != pb.ErrorCode_ERROR_VALIDATION_FAILED {
+		t.Errorf("Expected the attachment not found in another chat, got %v", err)
+	}
+	_, err = c.Client.UploadAttachment("chat-1", "alice", "big.bin", "", bytes.NewReader(make([]byte, 2<<20)))
+	if !errors.As(err, &rejection) || rejection.Code != pb.ErrorCode_ERROR_VALIDATION_FAILED {
+		t.Errorf("Expected an attachment over MaxSize refused, got %v", err)
+	}
+}
+
+func TestClusterAuditsAdminCalls(t *testing.T) {
+	t.Parallel()
+	network := NewNetwork()
+	NewCluster(t, ClusterConfig{
+		Servers: 1,
+		Server: func(config *server.ServerConfig) {
+			config.AdminToken = "secret"
+			config.AdminListener = network.Listen("admin-1")
+		},
+	})
+
+	conn, err := grpc.Dial("admin-1", grpc.WithContextDialer(network.Dial),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	admin := pb.NewAdminServiceClient(conn)
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-admin-token", "secret", "x-admin-actor", "ops-alice")
+
+	if _, err := admin.Drain(ctx, &pb.DrainRequest{Reason: "maintenance"}); err != nil {
+		t.Fatalf("Drain failed: %v", err)
+	}
+	if _, err := admin.ReloadConfig(ctx, &pb.ReloadConfigRequest{L1Capacity: -1}); err == nil {
+		t.Fatalf("Expected a negative capacity refused")
+	}
+	if _, err := admin.GetStatsSnapshot(context.Background(), &pb.StatsSnapshotRequest{}); err == nil {
+		t.Fatalf("Expected a call without the token refused")
+	}
+
+	resp, err := admin.QueryAuditLog(ctx, &pb.AuditLogQuery{Actions: []string{audit.ActionAdminCall}})
+	if err != nil {
+		t.Fatalf("QueryAuditLog failed: %v", err)
+	}
+	var calls []string
+	for _, e := range resp.Events {
+		calls = append(calls, e.Target+":"+e.Outcome)
+		if e.Actor != "ops-alice" || e.ServerId != "server-1" || e.Details["address"] == "" {
+			t.Errorf("Expected calls by ops-alice on server-1 from an address, got %+v", e)
+		}
+	}
+	if want := "Drain:ok ReloadConfig:rejected"; strings.Join(calls, " ") != want {
+		t.Errorf("Expected admin calls %s, got %v", want, calls)
+	}
+
+	drains, err := admin.QueryAuditLog(ctx, &pb.AuditLogQuery{Target: "server-1", Actor: "ops-alice"})
+	if err != nil || len(drains.Events) != 1 || drains.Events[0].Action != audit.ActionDrain {
+		t.Errorf("Expected ops-alice's drain of server-1, got %v, %v", drains, err)
+	}
+}
+
+func TestClusterSubscribeStats(t *testing.T) {
+	t.Parallel()
+	network := NewNetwork()
+	c := NewCluster(t, ClusterConfig{
+		Servers: 1,
+		Server: func(config *server.ServerConfig) {
+			config.AdminListener = network.Listen("admin-1")
+		},
+	})
+
+	conn, err := grpc.Dial("admin-1", grpc.WithContextDialer(network.Dial),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream, err := pb.NewAdminServiceClient(conn).SubscribeStats(ctx, &pb.SubscribeStatsRequest{IntervalMs: 100})
+	if err != nil {
+		t.Fatalf("SubscribeStats failed: %v", err)
+	}
+
+	// The first snapshot comes at once, and later ones see new requests
+	first, err := stream.Recv()
+	if err != nil || first.ServerId != "server-1" {
+		t.Fatalf("Expected a snapshot of server-1, got %v (%v)", first, err)
+	}
+	if _, err := c.Client.SendMessage("chat-1", "alice", "hello"); err != nil {
+		t.Fatalf("SendMessage failed: %v", err)
+	}
+	start := time.Now()
+	next, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Expected a second snapshot, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected snapshots every 100ms, waited %v", elapsed)
+	}
+	for next.TotalRequests == first.TotalRequests {
+		if next, err = stream.Recv(); err != nil {
+			t.Fatalf("Expected the message counted in a snapshot, got %v", err)
+		}
+	}
+
+	// Cancelling ends the stream
+	cancel()
+	for {
+		if _, err = stream.Recv(); err != nil {
+			break
+		}
+	}
+	if status.Code(err) != codes.Canceled {
+		t.Errorf("Expected the stream cancelled, got %v", err)
+	}
+}
+
+func TestClusterSubscribe(t *testing.T) {
+	t.Parallel()
+	c := NewCluster(t, ClusterConfig{
+		Server: func(config *server.ServerConfig) {
+			config.Replication = server.ReplicationConfig{N: 3, W: 3}
+		},
+		Client: client.ClientConfig{ReplicationFactor: 3, ConnectTimeout: time.Second},
+	})
+	first, err := c.Client.SendMessage("chat-1", "alice", "before")
+	if err != nil {
+		t.Fatalf("SendMessage failed: %v", err)
+	}
+
+	// Replaying from before the first message delivers it too
+	sub, err := c.Client.Subscribe("chat-1", first.Seq-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+	next := func() string {
+		select {
+		case msg, ok := <-sub.Messages():
+			if !ok {
+				t.Fatalf("Subscription ended: %v", sub.Err())
+			}
+			return msg.GetRequest().GetText()
+		case <-time.After(5 * time.Second):
+			t.Fatalf("No message received")
+		}
+		return ""
+	}
+	if got := next(); got != "before" {
+		t.Fatalf("Expected the replayed message, got %q", got)
+	}
+
+	owner, _, _ := c.Client.GetTargetServer("chat-1")
+	if _, err := c.Client.SendMessage("chat-1", "bob", "live"); err != nil {
+		t.Fatalf("SendMessage failed: %v", err)
+	}
+	if got := next(); got != "live" {
+		t.Fatalf("Expected the live message, got %q", got)
+	}
+	stats, _ := server.NewAdminServer(c.Server(owner)).GetStatsSnapshot(context.Background(), &pb.StatsSnapshotRequest{})
+	if stats.GetSubscribers() != 1 {
+		t.Errorf("Expected 1 subscriber on %s, got %d", owner, stats.GetSubscribers())
+	}
+
+	// The subscription moves to a successor when the owner goes away
+	c.Kill(owner)
+	if _, err := c.Client.SendMessage("chat-1", "alice", "after failover",
+		client.WithConsistency(pb.ConsistencyLevel_CONSISTENCY_QUORUM)); err != nil {
+		t.Fatalf("Expected failover, got %v", err)
+	}
+	if got := next(); got != "after failover" {
+		t.Fatalf("Expected the message sent after failover, got %q", got)
+	}
+
+	sub.Close()
+	for range sub.Messages() {
+	}
+	if sub.Err() != nil {
+		t.Errorf("Expected a closed subscription without error, got %v", sub.Err())
+	}
+}
+
+func TestClusterDurableSubscribe(t *testing.T) {
+	t.Parallel()
+	c := NewCluster(t, ClusterConfig{
+		Server: func(config *server.ServerConfig) {
+			config.Replication = server.ReplicationConfig{N: 3, W: 3}
+		},
+		Client: client.ClientConfig{ReplicationFactor: 3, ConnectTimeout: time.Second},
+	})
+	var sent []*pb.ChatResponse
+	for _, text := range []string{"one", "two", "three"} {
+		resp, err := c.Client.SendMessage("chat-1", "bob", text)
+		if err != nil {
+			t.Fatalf("SendMessage failed: %v", err)
+		}
+		sent = append(sent, resp)
+	}
+	subscribe := func() (*client.Subscription, func() *pb.StoredMessage) {
+		sub, err := c.Client.Subscribe("chat-1", 0, client.WithDurable(), client.WithSubscriber("alice"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(sub.Close)
+		return sub, func() *pb.StoredMessage {
+			select {
+			case msg, ok := <-sub.Messages():
+				if !ok {
+					t.Fatalf("Subscription ended: %v", sub.Err())
+				}
+				return msg
+			case <-time.After(5 * time.Second):
+				t.Fatalf("No message received")
+			}
+			return nil
+		}
+	}
+
+	// A durable subscription needs someone to keep the position for
+	anonymous, err := c.Client.Subscribe("chat-1", 0, client.WithDurable())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range anonymous.Messages() {
+	}
+	var rejection *chaterr.Rejection
+	if !errors.As(anonymous.Err(), &rejection) || rejection.Code != pb.ErrorCode_ERROR_VALIDATION_FAILED {
+		t.Errorf("Expected an anonymous durable subscription refused, got %v", anonymous.Err())
+	}
+
+	sub, next := subscribe()
+	for _, want := range []string{"one", "two", "three"} {
+		if got := next().GetRequest().GetText(); got != want {
+			t.Fatalf("Expected %q, got %q", want, got)
+		}
+	}
+	if err := sub.Ack(sent[1].Seq); err != nil {
+		t.Fatalf("Ack failed: %v", err)
+	}
+	sub.Close()
+
+	// What was received but not acknowledged is delivered again
+	sub, next = subscribe()
+	if got := next().GetRequest().GetText(); got != "three" {
+		t.Fatalf("Expected the unacknowledged message redelivered, got %q", got)
+	}
+	if err := sub.Ack(sent[2].Seq); err != nil {
+		t.Fatalf("Ack failed: %v", err)
+	}
+	sub.Close()
+
+	// Acks reach every replica, so the cursor survives the owner
+	owner, _, _ := c.Client.GetTargetServer("chat-1")
+	deadline := time.Now().Add(5 * time.Second)
+	for _, srv := range c.Servers {
+		for {
+			resp, _ := srv.AckMessages(context.Background(), &pb.AckRequest{ChatId: "chat-1", SubscriberId: "alice", RelayedBy: "test"})
+			if resp.GetSeq() == sent[2].Seq {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("Expected the ack relayed to %s, its cursor is at %d", resp.GetServerId(), resp.GetSeq())
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	c.Kill(owner)
+	if _, err := c.Client.SendMessage("chat-1", "bob", "four",
+		client.WithConsistency(pb.ConsistencyLevel_CONSISTENCY_QUORUM)); err != nil {
+		t.Fatalf("Expected failover, got %v", err)
+	}
+	_, next = subscribe()
+	if got := next().GetRequest().GetText(); got != "four" {
+		t.Fatalf("Expected to resume after the last ack on a successor, got %q", got)
+	}
+}
+
+func TestClusterNotifiesOfflineMembers(t *testing.T) {
+	t.Parallel()
+	notifications := make(chan notify.Notification, 10)
+	c := NewCluster(t, ClusterConfig{
+		Servers: 1,
+		Server: func(config *server.ServerConfig) {
+			config.Notifier = notify.Func(func(ctx context.Context, n notify.Notification) error {
+				notifications <- n
+				return nil
+			})
+		},
+	})
+	for _, e := range []*pb.SystemEvent{
+		{Type: pb.SystemEventType_SYSTEM_EVENT_CHAT_CREATED, ActorId: "alice"},
+		{Type: pb.SystemEventType_SYSTEM_EVENT_MEMBER_JOINED, ActorId: "alice", Details: map[string]string{"member_id": "bob"}},
+		{Type: pb.SystemEventType_SYSTEM_EVENT_MEMBER_JOINED, ActorId: "alice", Details: map[string]string{"member_id": "carol"}},
+	} {
+		if _, err := c.Client.SendSystemEvent("chat-1", e); err != nil {
+			t.Fatalf("SendSystemEvent failed: %v", err)
+		}
+	}
+
+	// Bob follows the chat, so only carol is told of alice's message
+	sub, err := c.Client.Subscribe("chat-1", 0, client.WithSubscriber("bob"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+	for i := 0; i < 3; i++ {
+		<-sub.Messages()
+	}
+	if _, err := c.Client.SendMessage("chat-1", "alice", "lunch?"); err != nil {
+		t.Fatalf("SendMessage failed: %v", err)
+	}
+
+	select {
+	case n := <-notifications:
+		if strings.Join(n.Recipients, ",") != "carol" || n.SenderID != "alice" || n.Preview != "lunch?" {
+			t.Errorf("Expected carol told of alice's message, got %+v", n)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("No notification sent")
+	}
+	select {
+	case n := <-notifications:
+		t.Errorf("Expected one notification, got another: %+v", n)
+	default:
+	}
+
+	// The notification is counted once the notifier returns
+	admin := server.NewAdminServer(c.Servers[0])
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		stats, _ := admin.GetStatsSnapshot(context.Background(), &pb.StatsSnapshotRequest{})
+		if stats.GetNotificationsSent() == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected 1 notification counted, got %d", stats.GetNotificationsSent())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestClusterPresence(t *testing.T) {
+	t.Parallel()
+	c := NewCluster(t, ClusterConfig{Servers: 3})
+	watch, err := c.Client.WatchPresence([]string{"alice", "bob"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watch.Close()
+	await := func(userID string, status pb.PresenceStatus) *pb.Presence {
+		t.Helper()
+		timeout := time.After(5 * time.Second)
+		for {
+			select {
+			case p := <-watch.Updates():
+				if p.UserId == userID && p.Status == status {
+					return p
+				}
+			case <-timeout:
+				t.Fatalf("Expected %s to become %v", userID, status)
+			}
+		}
+	}
+
+	// Alice's heartbeat makes her online wherever it lands
+	if err := c.Client.Heartbeat("alice", time.Minute); err != nil {
+		t.Fatalf("Heartbeat failed: %v", err)
+	}
+	await("alice", pb.PresenceStatus_PRESENCE_ONLINE)
+	got, err := c.Client.GetPresence([]string{"alice", "bob"})
+	if err != nil {
+		t.Fatalf("GetPresence failed: %v", err)
+	}
+	statuses := map[string]pb.PresenceStatus{}
+	for _, p := range got {
+		statuses[p.UserId] = p.Status
+	}
+	if statuses["alice"] != pb.PresenceStatus_PRESENCE_ONLINE || statuses["bob"] != pb.PresenceStatus_PRESENCE_UNKNOWN {
+		t.Errorf("Expected alice online and bob unknown, got %v", got)
+	}
+	var online int64
+	for _, srv := range c.Servers {
+		stats, _ := server.NewAdminServer(srv).GetStatsSnapshot(context.Background(), &pb.StatsSnapshotRequest{})
+		online += stats.GetUsersOnline()
+	}
+	if online != 1 {
+		t.Errorf("Expected 1 user online across the cluster, got %d", online)
+	}
+
+	// Bob is online while he follows a chat, on whichever server holds it
+	sub, err := c.Client.Subscribe("chat-1", 0, client.WithSubscriber("bob"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	await("bob", pb.PresenceStatus_PRESENCE_ONLINE)
+	sub.Close()
+	if p := await("bob", pb.PresenceStatus_PRESENCE_OFFLINE); p.LastSeenMs == 0 {
+		t.Errorf("Expected bob's last seen time set, got %+v", p)
+	}
+
+	if err := c.Client.SignOff("alice"); err != nil {
+		t.Fatalf("SignOff failed: %v", err)
+	}
+	await("alice", pb.PresenceStatus_PRESENCE_OFFLINE)
+}
+
+func TestClusterSearch(t *testing.T) {
+	t.Parallel()
+	c := NewCluster(t, ClusterConfig{
+		Servers: 1,
+		Server: func(config *server.ServerConfig) {
+			config.Search = &search.Config{}
+			config.L1Capacity, config.L2Capacity = 1, 1
+		},
+	})
+	for _, m := range []struct{ chat, sender, text string }{
+		{"chat-1", "alice", "standup moved to ten"},
+		{"chat-1", "bob", "standup standup standup!"},
+		{"chat-2", "carol", "is the standup today?"},
+	} {
+		if _, err := c.Client.SendMessage(m.chat, m.sender, m.text); err != nil {
+			t.Fatalf("SendMessage failed: %v", err)
+		}
+	}
+	srv := c.Servers[0]
+	query := func(req *pb.SearchRequest) *pb.SearchResponse {
+		t.Helper()
+		resp, err := srv.SearchMessages(context.Background(), req)
+		if err != nil || !resp.Success {
+			t.Fatalf("SearchMessages failed: %v %v", err, resp.GetErrorDetails())
+		}
+		return resp
+	}
+
+	resp := query(&pb.SearchRequest{Query: "STANDUP"})
+	if resp.Total != 3 || resp.Hits[0].SenderId != "bob" {
+		t.Errorf("Expected 3 matches led by bob's, got %v", resp.Hits)
+	}
+	resp = query(&pb.SearchRequest{Query: "standup", ChatIds: []string{"chat-1"}, SenderId: "alice"})
+	if resp.Total != 1 || resp.Hits[0].Text != "standup moved to ten" {
+		t.Errorf("Expected alice's message in chat-1, got %v", resp.Hits)
+	}
+
+	// A chat evicted without an archive leaves the index
+	if _, err := c.Client.SendMessage("chat-3", "dave", "no meetings"); err != nil {
+		t.Fatalf("SendMessage failed: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for query(&pb.SearchRequest{Query: "standup"}).Total != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected chat-1 unindexed once evicted, got %v", query(&pb.SearchRequest{Query: "standup"}).Hits)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Cache purges empty it
+	if _, err := server.NewAdminServer(srv).ClearCache(context.Background(), &pb.ClearCacheRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	stats, _ := server.NewAdminServer(srv).GetStatsSnapshot(context.Background(), &pb.StatsSnapshotRequest{})
+	if stats.GetIndexedMessages() != 0 {
+		t.Errorf("Expected an empty index after a purge, got %d messages", stats.GetIndexedMessages())
+	}
+	if resp, _ := srv.SearchMessages(context.Background(), &pb.SearchRequest{Query: "?!"}); resp.ErrorCode != pb.ErrorCode_ERROR_VALIDATION_FAILED {
+		t.Errorf("Expected a query without words refused, got %v", resp)
+	}
+}
+
+func TestClusterSearchScatterGather(t *testing.T) {
+	t.Parallel()
+	c := NewCluster(t, ClusterConfig{
+		Servers: 3,
+		Server: func(config *server.ServerConfig) {
+			config.Search = &search.Config{}
+			config.Replication = server.ReplicationConfig{N: 2, W: 2}
+		},
+		Client: client.ClientConfig{ConnectTimeout: time.Second, RequestTimeout: time.Second},
+	})
+	for i := 1; i <= 6; i++ {
+		if _, err := c.Client.SendMessage(fmt.Sprintf("chat-%d", i), "alice", fmt.Sprintf("deploy %d is out", i)); err != nil {
+			t.Fatalf("SendMessage failed: %v", err)
+		}
+	}
+
+	// Every chat is found once, though two servers hold each
+	result, err := c.Client.Search(client.SearchQuery{Text: "deploy"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Hits) != 6 || result.Servers != 3 || result.Partial() {
+		t.Errorf("Expected 6 hits from 3 servers, got %d from %d (failed: %v)", len(result.Hits), result.Servers, result.Failed)
+	}
+	result, _ = c.Client.Search(client.SearchQuery{Text: "deploy", Limit: 2})
+	if len(result.Hits) != 2 {
+		t.Errorf("Expected the limit to apply to merged hits, got %d", len(result.Hits))
+	}
+
+	// With a server down the replicas still answer, and the result says so
+	c.Kill("server-2")
+	result, err = c.Client.Search(client.SearchQuery{Text: "deploy"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Hits) != 6 || !result.Partial() || result.Failed["server-2"] == nil {
+		t.Errorf("Expected 6 hits and server-2 reported failed, got %d (failed: %v)", len(result.Hits), result.Failed)
+	}
+
+	var rejection *chaterr.Rejection
+	if _, err := c.Client.Search(client.SearchQuery{Text: "..."}); !errors.As(err, &rejection) || rejection.Code != pb.ErrorCode_ERROR_VALIDATION_FAILED {
+		t.Errorf("Expected a query without words refused, got %v", err)
+	}
+}
+
+func TestClusterRetention(t *testing.T) {
+	t.Parallel()
+	messageLog := msglog.NewMemoryLog(1)
+	c := NewCluster(t, ClusterConfig{
+		Servers: 2,
+		Server: func(config *server.ServerConfig) {
+			config.Replication = server.ReplicationConfig{N: 2, W: 2}
+			config.Search = &search.Config{}
+			config.MessageLog = messageLog
+			config.Retention = &server.RetentionConfig{
+				Default:  server.RetentionPolicy{Retain: time.Second},
+				Chats:    map[string]server.RetentionPolicy{"keep": {}},
+				Interval: time.Hour, // Enforced by hand below
+			}
+		},
+	})
+	send := func(chatID, text string) {
+		t.Helper()
+		if _, err := c.Client.SendMessage(chatID, "alice", text); err != nil {
+			t.Fatalf("SendMessage failed: %v", err)
+		}
+	}
+	send("old", "stale news")
+	send("old", "more stale news")
+	send("keep", "stale but kept")
+	time.Sleep(2100 * time.Millisecond) // Timestamps have whole seconds
+	send("old", "fresh news")
+
+	for _, srv := range c.Servers {
+		if purged := srv.EnforceRetention(context.Background()); purged != 2 {
+			t.Errorf("Expected each replica to purge 2 messages, got %d", purged)
+		}
+		stats, _ := server.NewAdminServer(srv).GetStatsSnapshot(context.Background(), &pb.StatsSnapshotRequest{})
+		if stats.GetMessagesPurged() != 2 {
+			t.Errorf("Expected 2 purged messages in the stats, got %d", stats.GetMessagesPurged())
+		}
+		resp, _ := srv.SearchMessages(context.Background(), &pb.SearchRequest{Query: "news"})
+		if resp.GetTotal() != 1 {
+			t.Errorf("Expected only the fresh message indexed, got %v", resp.GetHits())
+		}
+	}
+
+	history, err := c.Client.GetHistory("old", 0)
+	if err != nil {
+		t.Fatalf("GetHistory failed: %v", err)
+	}
+	if len(history.Messages) != 1 || history.Messages[0].Request.GetText() != "fresh news" {
+		t.Errorf("Expected only the fresh message left, got %v", history.Messages)
+	}
+	if history, _ := c.Client.GetHistory("keep", 0); len(history.GetMessages()) != 1 {
+		t.Errorf("Expected the chat kept forever to keep its message, got %v", history.GetMessages())
+	}
+	if messageLog.Len() != 2 {
+		t.Errorf("Expected the message log compacted to 2 messages, got %d", messageLog.Len())
+	}
+}
+
+func TestClusterPurgeUser(t *testing.T) {
+	t.Parallel()
+	c := NewCluster(t, ClusterConfig{
+		Servers: 3,
+		Server: func(config *server.ServerConfig) {
+			config.Replication = server.ReplicationConfig{N: 2, W: 2}
+			config.Search = &search.Config{}
+		},
+	})
+	for i := 1; i <= 4; i++ {
+		chatID := fmt.Sprintf("chat-%d", i)
+		for _, sender := range []string{"alice", "bob"} {
+			if _, err := c.Client.SendMessage(chatID, sender, sender+" says hello"); err != nil {
+				t.Fatalf("SendMessage failed: %v", err)
+			}
+		}
+	}
+
+	admin := server.NewAdminServer(c.Servers[0])
+	ctx := context.Background()
+	if _, err := admin.PurgeUser(ctx, &pb.PurgeUserRequest{}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument without a user, got %v", err)
+	}
+	job, err := admin.PurgeUser(ctx, &pb.PurgeUserRequest{UserId: "alice", Reason: "erasure request"})
+	if err != nil {
+		t.Fatalf("PurgeUser failed: %v", err)
+	}
+	if len(job.Servers) != 3 {
+		t.Errorf("Expected every server in the job, got %v", job.Servers)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for job.State == pb.PurgeJobState_PURGE_JOB_RUNNING && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		job, _ = admin.GetPurgeJob(ctx, &pb.GetPurgeJobRequest{JobId: job.Id})
+	}
+	// Each chat's 2 replicas lose alice's message
+	if job.State != pb.PurgeJobState_PURGE_JOB_DONE || job.ServersDone != 3 || job.MessagesPurged != 8 {
+		t.Fatalf("Expected the job done with 8 messages purged, got %v", job)
+	}
+
+	for i := 1; i <= 4; i++ {
+		history, err := c.Client.GetHistory(fmt.Sprintf("chat-%d", i), 0)
+		if err != nil {
+			t.Fatalf("GetHistory failed: %v", err)
+		}
+		if len(history.Messages) != 1 || history.Messages[0].Request.GetSenderId() != "bob" {
+			t.Errorf("Expected only bob's message left, got %v", history.Messages)
+		}
+	}
+	for _, srv := range c.Servers {
+		resp, _ := srv.SearchMessages(ctx, &pb.SearchRequest{Query: "hello", SenderId: "alice"})
+		if resp.GetTotal() != 0 {
+			t.Errorf("Expected none of alice's messages indexed, got %v", resp.GetHits())
+		}
+	}
+
+	// Resuming a finished job leaves it as it was
+	again, err := admin.PurgeUser(ctx, &pb.PurgeUserRequest{JobId: job.Id})
+	if err != nil || again.State != pb.PurgeJobState_PURGE_JOB_DONE || again.UpdatedMs != job.UpdatedMs {
+		t.Errorf("Expected the done job back unchanged, got %v (%v)", again, err)
+	}
+	if _, err := admin.GetPurgeJob(ctx, &pb.GetPurgeJobRequest{JobId: "purge-unknown"}); status.Code(err) != codes.NotFound {
+		t.Errorf("Expected NotFound for an unknown job, got %v", err)
+	}
+}
+
+func TestClusterGraphQL(t *testing.T) {
+	t.Parallel()
+	c := NewCluster(t, ClusterConfig{
+		Servers: 1,
+		Server:  func(config *server.ServerConfig) { config.AdminToken = "secret" },
+	})
+	for i := 1; i <= 3; i++ {
+		if _, err := c.Client.SendMessage("chat-1", "alice", fmt.Sprintf("hello %d", i)); err != nil {
+			t.Fatalf("SendMessage failed: %v", err)
+		}
+	}
+	api := httptest.NewServer(c.Servers[0].GraphQLHandler())
+	defer api.Close()
+
+	query := func(token, q string, variables map[string]any) (*http.Response, map[string]any) {
+		body, _ := json.Marshal(map[string]any{"query": q, "variables": variables})
+		req, _ := http.NewRequest(http.MethodPost, api.URL, bytes.NewReader(body))
+		req.Header.Set("X-Admin-Token", token)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var result map[string]any
+		json.NewDecoder(resp.Body).Decode(&result)
+		return resp, result
+	}
+
+	if resp, _ := query("wrong", "{ server { id } }", nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected a query without the token refused, got %s", resp.Status)
+	}
+
+	const page = `query($after: String) {
+		server { id stats { totalRequests } }
+		chats { totalCount nodes { id tier } }
+		chat(id: "chat-1") { messages(first: 2, after: $after) {
+			totalCount nodes { seq text senderId } pageInfo { hasNextPage endCursor }
+		} }
+	}`
+	var texts []string
+	var after any
+	for pages := 0; pages < 3; pages++ {
+		_, result := query("secret", page, map[string]any{"after": after})
+		if result["errors"] != nil {
+			t.Fatalf("Query failed: %v", result["errors"])
+		}
+		data := result["data"].(map[string]any)
+		if pages == 0 {
+			srv := data["server"].(map[string]any)
+			chats := data["chats"].(map[string]any)
+			if srv["id"] != "server-1" || chats["totalCount"].(float64) != 1 {
+				t.Errorf("Expected server-1 holding one chat, got %v and %v", srv, chats)
+			}
+		}
+		messages := data["chat"].(map[string]any)["messages"].(map[string]any)
+		for _, node := range messages["nodes"].([]any) {
+			texts = append(texts, node.(map[string]any)["text"].(string))
+		}
+		info := messages["pageInfo"].(map[string]any)
+		if info["hasNextPage"] != true {
+			break
+		}
+		after = info["endCursor"]
+	}
+	if want := "hello 1,hello 2,hello 3"; strings.Join(texts, ",") != want {
+		t.Errorf("Expected the pages to hold %s, got %v", want, texts)
+	}
+}
+
+func TestClusterDebugVars(t *testing.T) {
+	t.Parallel()
+	injector := chaos.New()
+	c := NewCluster(t, ClusterConfig{
+		Servers: 2,
+		Server: func(config *server.ServerConfig) {
+			config.Replication = server.ReplicationConfig{N: 2, W: 2}
+			config.Chaos = injector
+		},
+		Client: client.ClientConfig{ReplicationFactor: 2},
+	})
+	owner, _, _ := c.Client.GetTargetServer("chat-1")
+	vars := httptest.NewServer(c.Server(owner).VarsHandler())
+	defer vars.Close()
+
+	// read decodes the server's values from /debug/vars
+	read := func() map[string]any {
+		t.Helper()
+		resp, err := http.Get(vars.URL)
+		if err != nil {
+			t.Fatalf("GET /debug/vars failed: %v", err)
+		}
+		defer resp.Body.Close()
+		var all map[string]json.RawMessage
+		if err := json.NewDecoder(resp.Body).Decode(&all); err != nil {
+			t.Fatalf("Expected expvar JSON, got %v", err)
+		}
+		if _, ok := all["memstats"]; !ok {
+			t.Errorf("Expected the process-wide variables, got %d keys", len(all))
+		}
+		var own map[string]any
+		if err := json.Unmarshal(all["districhat"], &own); err != nil {
+			t.Fatalf("Expected the server's values under districhat, got %v", err)
+		}
+		return own
+	}
+	// clientInFlight reads the client's in_flight var
+	clientInFlight := func() float64 {
+		var own map[string]any
+		if err := json.Unmarshal([]byte(c.Client.Vars().String()), &own); err != nil {
+			t.Fatalf("Expected the client's vars as JSON, got %v", err)
+		}
+		return own["in_flight"].(float64)
+	}
+
+	own := read()
+	for _, key := range []string{"server_id", "ring_epoch", "ring_nodes", "cache", "in_flight", "stale_reads", "rate_limited", "healthy", "draining"} {
+		if _, ok := own[key]; !ok {
+			t.Errorf("Expected %s in the server's vars, got %v", key, own)
+		}
+	}
+	if own["server_id"] != owner || own["ring_nodes"] != float64(2) || own["in_flight"] != float64(0) {
+		t.Errorf("Expected an idle %s with 2 nodes, got %v", owner, own)
+	}
+
+	// Slow replication keeps a write in flight on the owner and the client
+	injector.Add(chaos.Rule{Name: "slow", From: owner, Latency: 300 * time.Millisecond})
+	done := make(chan error, 1)
+	go func() {
+		_, err := c.Client.SendMessage("chat-1", "alice", "hello")
+		done <- err
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for read()["in_flight"] != float64(1) || clientInFlight() != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the write in flight, got %v and %v", read()["in_flight"], clientInFlight())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("SendMessage failed: %v", err)
+	}
+	if got := read()["in_flight"]; got != float64(0) {
+		t.Errorf("Expected the server's in_flight back to 0, got %v", got)
+	}
+	if got := clientInFlight(); got != 0 {
+		t.Errorf("Expected the client's in_flight back to 0, got %v", got)
+	}
+}
+
+func TestClusterRebalanceWithHasher(t *testing.T) {
+	t.Parallel()
+	hasher := func(key string) uint32 {
+		h := fnv.New32a()
+		h.Write([]byte(key))
+		return h.Sum32()
+	}
+	c := NewCluster(t, ClusterConfig{
+		Servers:  3,
+		Capacity: 10,
+		Server: func(config *server.ServerConfig) {
+			config.Hasher = hasher
+			config.L2Capacity = 100 // Keep every chat to export
+		},
+		Client: client.ClientConfig{Hasher: hasher},
+	})
+
+	// Start on the first two servers, then add the third
+	all := c.RingState()
+	before := ring.RingState{Epoch: 2, Nodes: all.Nodes[:2]}
+	after := ring.RingState{Epoch: 3, Nodes: all.Nodes}
+	for _, srv := range c.Servers {
+		srv.SetRingState(before)
+	}
+	c.Client.ApplyRingState(before)
+	for i := 0; i < 40; i++ {
+		if _, err := c.Client.SendMessage(fmt.Sprintf("chat-%d", i), "alice", "hello"); err != nil {
+			t.Fatalf("SendMessage failed: %v", err)
+		}
+	}
+
+	rebalancer := rebalance.New(rebalance.Config{Hasher: hasher},
+		rebalance.NewGRPCMover(grpc.WithContextDialer(c.Network.Dial)))
+	defer rebalancer.Stop()
+	rebalancer.Apply(before)
+	rebalancer.Apply(after)
+	if stats := rebalancer.Stats(); stats.Transfers == 0 || stats.FailedTransfers != 0 {
+		t.Fatalf("Expected transfers to server-3, got %+v", stats)
+	}
+
+	for _, srv := range c.Servers {
+		srv.SetRingState(after)
+	}
+	c.Client.ApplyRingState(after)
+	placement := ring.NewHashRing(0, ring.WithHasher(hasher))
+	placement.Replace(after)
+	moved := 0
+	for i := 0; i < 40; i++ {
+		chatID := fmt.Sprintf("chat-%d", i)
+		if owner, _, _ := placement.GetNode(chatID); owner != "server-3" {
+			continue
+		}
+		moved++
+		resp, err := c.Client.GetHistory(chatID, 0)
+		if err != nil || len(resp.Messages) != 1 {
+			t.Errorf("Expected %s's message moved to server-3, got %v (%v)", chatID, resp, err)
+		}
+	}
+	if moved == 0 {
+		t.Error("Expected some chats to move to server-3")
+	}
+}
+
+func TestClusterFollowServers(t *testing.T) {
+	t.Parallel()
+	c := NewCluster(t, ClusterConfig{})
+
+	// A client seeded with one server learns the ring from it, without
+	// AddServer
+	cl := client.NewSmartClient(client.ClientConfig{Dialer: c.Network.Dial})
+	t.Cleanup(cl.Close)
+	if err := cl.FollowServers("server-1"); err != nil {
+		t.Fatalf("FollowServers failed: %v", err)
+	}
+	if state := cl.RingState(); state.Epoch != 1 || len(state.Nodes) != 3 {
+		t.Fatalf("Expected the 3-server ring at epoch 1, got %+v", state)
+	}
+
+	// owned finds a chat the ring gives to id
+	owned := func(state ring.RingState, id string) string {
+		placement := ring.NewHashRing(0)
+		placement.Replace(state)
+		for i := 0; ; i++ {
+			if owner, _, _ := placement.GetNode(fmt.Sprintf("chat-%d", i)); owner == id {
+				return fmt.Sprintf("chat-%d", i)
+			}
+		}
+	}
+	// change installs state on every server, and waits for the client to
+	// get it over the topology stream
+	change := func(state ring.RingState, servers ...*server.ChatServer) {
+		t.Helper()
+		for _, srv := range servers {
+			srv.SetRingState(state)
+		}
+		deadline := time.Now().Add(5 * time.Second)
+		for cl.RingState().Epoch != state.Epoch {
+			if time.Now().After(deadline) {
+				t.Fatalf("Expected the client to follow the ring to epoch %d, it has %d", state.Epoch, cl.RingState().Epoch)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	// A server joining gets its chats routed to it
+	joined := server.NewChatServer(server.ServerConfig{
+		ServerID:         "server-4",
+		AdvertiseAddress: "server-4",
+		Listener:         c.Network.Listen("server-4"),
+		Dialer:           c.Network.Dial,
+	})
+	if err := joined.Start(); err != nil {
+		t.Fatalf("Failed to start server-4: %v", err)
+	}
+	t.Cleanup(joined.Stop)
+	servers := append(append([]*server.ChatServer{}, c.Servers...), joined)
+	grown := ring.RingState{Epoch: 2, Nodes: append(append([]ring.NodeSpec{}, c.RingState().Nodes...),
+		ring.NodeSpec{NodeID: "server-4", Address: "server-4", Capacity: 100})}
+	change(grown, servers...)
+
+	chatID := owned(grown, "server-4")
+	if resp, err := cl.SendMessage(chatID, "alice", "hello"); err != nil || resp.ServerId != "server-4" {
+		t.Errorf("Expected %s stored by server-4, got %v (%v)", chatID, resp, err)
+	}
+
+	// A server leaving has its chats routed to the new owners
+	shrunk := ring.RingState{Epoch: 3, Nodes: grown.Nodes[1:]}
+	change(shrunk, servers...)
+
+	chatID = owned(grown, "server-1")
+	owner, _, _ := cl.GetTargetServer(chatID)
+	if owner == "server-1" {
+		t.Fatalf("Expected %s routed away from server-1", chatID)
+	}
+	if resp, err := cl.SendMessage(chatID, "alice", "hello"); err != nil || resp.ServerId != owner {
+		t.Errorf("Expected %s stored by %s, got %v (%v)", chatID, owner, resp, err)
+	}
+	if failovers := cl.GetStats().FailoverCount; failovers != 0 {
+		t.Errorf("Expected requests routed without failovers, got %d", failovers)
+	}
+}
+
+// Clusters use the same server names without sharing anything
+func TestClustersRunInParallel(t *testing.T) {
+	for i := 0; i < 4; i++ {
+		i := i
+		t.Run(fmt.Sprintf("cluster-%d", i), func(t *testing.T) {
+			t.Parallel()
+			c := NewCluster(t, ClusterConfig{Servers: 2})
+			for j := 0; j < 10; j++ {
+				if _, err := c.Client.SendMessage(fmt.Sprintf("chat-%d", j), "alice", "hello"); err != nil {
+					t.Fatalf("SendMessage failed: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestNetworkDialUnknown(t *testing.T) {
+	if _, err := NewNetwork().Dial(context.Background(), "nowhere"); !errors.Is(err, ErrNoListener) {
+		t.Errorf("Expected ErrNoListener, got %v", err)
+	}
+}
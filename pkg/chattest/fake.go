@@ -0,0 +1,248 @@
+package chattest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/pkg/client"
+	pb "github.com/sh4shv4t/DistriChat/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Reply is one scripted answer of a FakeServer. The zero Reply succeeds at
+// once.
+type Reply struct {
+	// Wait before answering; a caller whose deadline passes first gets
+	// DeadlineExceeded
+	Delay time.Duration
+
+	// Fail the call with this error, e.g. Unavailable()
+	Err error
+
+	// Reject the request with this code (Success false) when not
+	// ERROR_NONE, e.g. ERROR_DRAINING to make the client fail over
+	Reject  pb.ErrorCode
+	Details string
+}
+
+// Unavailable is a reply failing the call as if the server were down
+func Unavailable() Reply {
+	return Reply{Err: status.Error(codes.Unavailable, "fake server unavailable")}
+}
+
+// Rejected is a reply rejecting the request with code
+func Rejected(code pb.ErrorCode) Reply {
+	return Reply{Reject: code, Details: "rejected by fake server"}
+}
+
+// Delayed is a successful reply sent after d
+func Delayed(d time.Duration) Reply {
+	return Reply{Delay: d}
+}
+
+// FakeServer is a ChatService whose answers are scripted, for testing code
+// built on SmartClient without real servers. PostMessage and GetHistory
+// take the next scripted reply, then the one set with Always; successful
+// posts are stored and returned by GetHistory.
+type FakeServer struct {
+	pb.UnimplementedChatServiceServer
+
+	id string
+
+	mu       sync.Mutex
+	script   []Reply
+	always   Reply
+	requests []*pb.ChatRequest
+	messages map[string][]*pb.StoredMessage
+}
+
+// NewFakeServer creates a fake answering as server id, succeeding until
+// scripted otherwise
+func NewFakeServer(id string) *FakeServer {
+	return &FakeServer{id: id, messages: make(map[string][]*pb.StoredMessage)}
+}
+
+// Script queues replies for the next calls, in order
+func (f *FakeServer) Script(replies ...Reply) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.script = append(f.script, replies...)
+}
+
+// Always sets the reply used once the script runs out
+func (f *FakeServer) Always(reply Reply) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.always = reply
+}
+
+// Requests returns the posts received so far, including failed ones
+func (f *FakeServer) Requests() []*pb.ChatRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]*pb.ChatRequest(nil), f.requests...)
+}
+
+// next takes the reply for a call
+func (f *FakeServer) next() Reply {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if len(f.script) == 0 {
+		return f.always
+	}
+	reply := f.script[0]
+	f.script = f.script[1:]
+	return reply
+}
+
+// play waits out reply's delay and returns its error
+func play(ctx context.Context, reply Reply) error {
+	if reply.Delay > 0 {
+		timer := time.NewTimer(reply.Delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return status.FromContextError(ctx.Err()).Err()
+		}
+	}
+	return reply.Err
+}
+
+// PostMessage answers with the next reply, storing the message on success
+func (f *FakeServer) PostMessage(ctx context.Context, req *pb.ChatRequest) (*pb.ChatResponse, error) {
+	f.mu.Lock()
+	f.requests = append(f.requests, req)
+	f.mu.Unlock()
+
+	reply := f.next()
+	if err := play(ctx, reply); err != nil {
+		return nil, err
+	}
+	if reply.Reject != pb.ErrorCode_ERROR_NONE {
+		return &pb.ChatResponse{ServerId: f.id, ErrorCode: reply.Reject, ErrorDetails: reply.Details}, nil
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	seq := uint64(len(f.messages[req.ChatId]) + 1)
+	id := req.MessageId
+	if id == "" {
+		id = fmt.Sprintf("%s-%d", f.id, len(f.requests))
+	}
+	f.messages[req.ChatId] = append(f.messages[req.ChatId], &pb.StoredMessage{
+		MessageId: id,
+		Seq:       seq,
+		Request:   req,
+		Origin:    f.id,
+	})
+	return &pb.ChatResponse{
+		Success:       true,
+		ServerId:      f.id,
+		CacheLocation: pb.CacheLocation_CACHE_L1,
+		MessageCount:  int32(seq),
+		MessageId:     id,
+		Seq:           seq,
+		ReplicasAcked: 1,
+	}, nil
+}
+
+// GetHistory answers with the next reply and the chat's stored messages
+func (f *FakeServer) GetHistory(ctx context.Context, req *pb.HistoryRequest) (*pb.HistoryResponse, error) {
+	reply := f.next()
+	if err := play(ctx, reply); err != nil {
+		return nil, err
+	}
+	if reply.Reject != pb.ErrorCode_ERROR_NONE {
+		return &pb.HistoryResponse{ServerId: f.id, ErrorCode: reply.Reject, ErrorDetails: reply.Details}, nil
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	messages := f.messages[req.ChatId]
+	if req.Limit > 0 && len(messages) > int(req.Limit) {
+		messages = messages[len(messages)-int(req.Limit):]
+	}
+	return &pb.HistoryResponse{
+		Success:      true,
+		ServerId:     f.id,
+		Messages:     append([]*pb.StoredMessage(nil), messages...),
+		ReplicasRead: 1,
+	}, nil
+}
+
+// HealthCheck reports the fake healthy
+func (f *FakeServer) HealthCheck(ctx context.Context, req *pb.HealthRequest) (*pb.HealthResponse, error) {
+	return &pb.HealthResponse{Healthy: true, ServerId: f.id}, nil
+}
+
+// FakeCluster is a set of fake servers, served in memory, and a client
+// routing to them
+type FakeCluster struct {
+	Network *Network
+	Fakes   []*FakeServer
+	Client  *client.SmartClient
+
+	servers map[string]*grpc.Server
+	t       testing.TB
+}
+
+// NewFakeCluster serves a fake for each of ids (also their addresses) and
+// creates a client with config routing to them at equal weight. Everything
+// is stopped when t finishes.
+func NewFakeCluster(t testing.TB, config client.ClientConfig, ids ...string) *FakeCluster {
+	t.Helper()
+
+	c := &FakeCluster{Network: NewNetwork(), servers: make(map[string]*grpc.Server), t: t}
+	config.Dialer = c.Network.Dial
+	c.Client = client.NewSmartClient(config)
+	t.Cleanup(c.Client.Close)
+
+	for _, id := range ids {
+		fake := NewFakeServer(id)
+		server := grpc.NewServer()
+		pb.RegisterChatServiceServer(server, fake)
+		go server.Serve(c.Network.Listen(id))
+		t.Cleanup(server.Stop)
+
+		c.Fakes = append(c.Fakes, fake)
+		c.servers[id] = server
+		if err := c.Client.AddServer(id, id, 100); err != nil {
+			t.Fatalf("Failed to add %s: %v", id, err)
+		}
+	}
+	return c
+}
+
+// Fake returns the fake called id, or nil
+func (c *FakeCluster) Fake(id string) *FakeServer {
+	for _, fake := range c.Fakes {
+		if fake.id == id {
+			return fake
+		}
+	}
+	return nil
+}
+
+// Owner returns the fake the client sends chatID to first
+func (c *FakeCluster) Owner(chatID string) *FakeServer {
+	id, _, _ := c.Client.GetTargetServer(chatID)
+	return c.Fake(id)
+}
+
+// Kill stops serving the fake called id, closing its connections
+func (c *FakeCluster) Kill(id string) {
+	server, ok := c.servers[id]
+	if !ok {
+		c.t.Fatalf("No fake %s in the cluster", id)
+	}
+	server.Stop()
+}
@@ -0,0 +1,156 @@
+package chattest
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/pkg/client"
+	"github.com/sh4shv4t/DistriChat/pkg/events"
+	pb "github.com/sh4shv4t/DistriChat/proto"
+)
+
+// others returns the fakes other than skip
+func others(c *FakeCluster, skip *FakeServer) []*FakeServer {
+	var rest []*FakeServer
+	for _, fake := range c.Fakes {
+		if fake != skip {
+			rest = append(rest, fake)
+		}
+	}
+	return rest
+}
+
+func TestFakeFailsOverOnScriptedErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		reply Reply
+	}{
+		{"unavailable", Unavailable()},
+		{"draining", Rejected(pb.ErrorCode_ERROR_DRAINING)},
+		{"overloaded", Rejected(pb.ErrorCode_ERROR_OVERLOADED)},
+		{"timeout", Delayed(time.Second)},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			c := NewFakeCluster(t, client.ClientConfig{RequestTimeout: 100 * time.Millisecond}, "a", "b", "c")
+			owner := c.Owner("chat-1")
+			owner.Script(tt.reply)
+
+			resp, err := c.Client.SendMessage("chat-1", "alice", "hello")
+			if err != nil {
+				t.Fatalf("Expected failover, got %v", err)
+			}
+			if resp.ServerId == owner.id {
+				t.Errorf("Expected another server than %s to answer", owner.id)
+			}
+			if got := c.Client.GetStats().FailoverCount; got != 1 {
+				t.Errorf("Expected 1 failover, got %d", got)
+			}
+			if got := len(owner.Requests()); got != 1 {
+				t.Errorf("Expected the owner to be tried once, got %d", got)
+			}
+		})
+	}
+}
+
+func TestFakeFailoverEvents(t *testing.T) {
+	t.Parallel()
+	bus := events.NewBus()
+	received := make(chan events.Event, 10)
+	sub := bus.Subscribe(func(e events.Event) { received <- e }, events.KindFailover, events.KindNodeDown)
+	defer sub.Close()
+
+	c := NewFakeCluster(t, client.ClientConfig{Events: bus}, "a", "b", "c")
+	owner := c.Owner("chat-1")
+	owner.Script(Rejected(pb.ErrorCode_ERROR_DRAINING))
+
+	resp, err := c.Client.SendMessage("chat-1", "alice", "hello")
+	if err != nil {
+		t.Fatalf("Expected failover, got %v", err)
+	}
+
+	next := func() events.Event {
+		select {
+		case e := <-received:
+			return e
+		case <-time.After(time.Second):
+			t.Fatal("Expected another event")
+			return nil
+		}
+	}
+	if down, ok := next().(events.NodeDown); !ok || down.Node != owner.id || down.Reason != "draining" {
+		t.Errorf("Expected %s reported down as draining, got %+v", owner.id, down)
+	}
+	failover, ok := next().(events.Failover)
+	if !ok || failover.ChatID != "chat-1" || failover.From != owner.id || failover.To != resp.ServerId {
+		t.Errorf("Expected a failover of chat-1 from %s to %s, got %+v", owner.id, resp.ServerId, failover)
+	}
+}
+
+func TestFakeNonRetryableRejection(t *testing.T) {
+	t.Parallel()
+	c := NewFakeCluster(t, client.ClientConfig{}, "a", "b")
+	owner := c.Owner("chat-1")
+	owner.Script(Rejected(pb.ErrorCode_ERROR_RATE_LIMITED))
+
+	_, err := c.Client.SendMessage("chat-1", "alice", "hello")
+	if err == nil || !strings.Contains(err.Error(), "ERROR_RATE_LIMITED") {
+		t.Fatalf("Expected a rate limit error, got %v", err)
+	}
+	for _, fake := range others(c, owner) {
+		if got := len(fake.Requests()); got != 0 {
+			t.Errorf("Expected %s not to be tried, got %d requests", fake.id, got)
+		}
+	}
+
+	// The script is used up: the next post succeeds on the owner
+	resp, err := c.Client.SendMessage("chat-1", "alice", "hello again")
+	if err != nil || resp.ServerId != owner.id {
+		t.Errorf("Expected %s to accept the retry, got %v, %v", owner.id, resp, err)
+	}
+}
+
+func TestFakeHistoryAndKill(t *testing.T) {
+	t.Parallel()
+	c := NewFakeCluster(t, client.ClientConfig{ConnectTimeout: 100 * time.Millisecond}, "a", "b")
+	owner := c.Owner("chat-1")
+
+	for _, text := range []string{"one", "two", "three"} {
+		if _, err := c.Client.SendMessage("chat-1", "alice", text); err != nil {
+			t.Fatalf("SendMessage failed: %v", err)
+		}
+	}
+	history, err := c.Client.GetHistory("chat-1", 2)
+	if err != nil {
+		t.Fatalf("GetHistory failed: %v", err)
+	}
+	if len(history.Messages) != 2 || history.Messages[1].Request.GetText() != "three" {
+		t.Errorf("Expected the last two messages, got %v", history.Messages)
+	}
+
+	c.Kill(owner.id)
+	resp, err := c.Client.SendMessage("chat-1", "alice", "four")
+	if err != nil {
+		t.Fatalf("Expected failover after the kill, got %v", err)
+	}
+	if resp.ServerId == owner.id {
+		t.Errorf("Expected another server than the killed %s", owner.id)
+	}
+}
+
+func TestFakeAlways(t *testing.T) {
+	t.Parallel()
+	c := NewFakeCluster(t, client.ClientConfig{}, "a", "b")
+	for _, fake := range c.Fakes {
+		fake.Always(Unavailable())
+	}
+
+	if _, err := c.Client.SendMessage("chat-1", "alice", "hello"); err == nil {
+		t.Errorf("Expected an error with every server unavailable")
+	}
+}
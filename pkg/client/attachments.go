@@ -0,0 +1,224 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/pkg/chaterr"
+	"github.com/sh4shv4t/DistriChat/pkg/logging"
+	pb "github.com/sh4shv4t/DistriChat/proto"
+)
+
+// attachmentChunkSize is the content UploadAttachment sends per message
+const attachmentChunkSize = 64 << 10
+
+// UploadAttachment streams content to one of the chat's replicas, which
+// stores it, and returns the reference to post with SendAttachment.
+// mimeType may be empty for the server to detect it. A server that makes
+// no progress for the request timeout is given up on; content is sent
+// again to the next replica only if it is an io.Seeker. Its errors are
+// SendMessage's.
+func (c *SmartClient) UploadAttachment(chatID, senderID, filename, mimeType string, content io.Reader, opts ...CallOption) (*pb.Attachment, error) {
+	options := applyOptions(opts)
+	namespace := c.config.Namespace
+	if options.namespace != nil {
+		namespace = *options.namespace
+	}
+	ctx := logging.With(context.Background(), logging.ChatID(chatID))
+	first := &pb.AttachmentChunk{
+		ChatId:    chatID,
+		Namespace: namespace,
+		SenderId:  senderID,
+		Filename:  filename,
+		MimeType:  mimeType,
+	}
+
+	nodes := c.routeNodes(namespace, chatID)
+	if len(nodes) == 0 {
+		return nil, chaterr.ErrNoServers
+	}
+	var lastErr error
+	for i, node := range nodes {
+		if i > 0 {
+			seeker, ok := content.(io.Seeker)
+			if !ok {
+				break
+			}
+			if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+				return nil, err
+			}
+		}
+		client, err := c.serverClient(node.Address)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		resp, err := c.uploadTo(ctx, client, first, content)
+		if err != nil {
+			lastErr = err
+			c.log.WarnContext(ctx, "Failed to upload attachment", logging.NodeID(node.NodeID), logging.Err(err))
+			c.recordFailure(node.Address)
+			continue
+		}
+		c.recordSuccess(node.Address)
+		if resp.Success {
+			return resp.Attachment, nil
+		}
+
+		lastErr = &chaterr.Rejection{ServerID: node.NodeID, Op: "attachment upload", Code: resp.ErrorCode, Details: resp.ErrorDetails}
+		if !shouldFailover(resp.ErrorCode) {
+			return nil, lastErr
+		}
+	}
+	if lastErr == nil {
+		return nil, chaterr.ErrNoServers
+	}
+	return nil, fmt.Errorf("%w: %w", chaterr.ErrAllReplicasFailed, lastErr)
+}
+
+// uploadTo streams content to one server, first chunk first
+func (c *SmartClient) uploadTo(ctx context.Context, client pb.ChatServiceClient, first *pb.AttachmentChunk, content io.Reader) (*pb.UploadAttachmentResponse, error) {
+	ctx, progress, cancel := c.progressContext(ctx)
+	defer cancel()
+	stream, err := client.UploadAttachment(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, attachmentChunkSize)
+	chunk := first
+	for {
+		n, readErr := io.ReadFull(content, buf)
+		if readErr != nil && !errors.Is(readErr, io.EOF) && !errors.Is(readErr, io.ErrUnexpectedEOF) {
+			stream.CloseSend()
+			return nil, readErr
+		}
+		// The first chunk goes even if empty, to name the chat
+		if n > 0 || chunk == first {
+			chunk.Data = buf[:n]
+			if err := stream.Send(chunk); errors.Is(err, io.EOF) {
+				break // The server ended the upload; its response says why
+			} else if err != nil {
+				return nil, err
+			}
+			progress()
+		}
+		if readErr != nil {
+			break
+		}
+		chunk = &pb.AttachmentChunk{}
+	}
+	return stream.CloseAndRecv()
+}
+
+// DownloadAttachment writes the content of an attachment posted to the
+// chat to w, from one of the chat's replicas, and returns its description.
+// A server that fails before sending any content is failed over like
+// GetHistory; one failing midway fails the download, as w already holds
+// part of the content.
+func (c *SmartClient) DownloadAttachment(chatID, url string, w io.Writer, opts ...CallOption) (*pb.Attachment, error) {
+	options := applyOptions(opts)
+	namespace := c.config.Namespace
+	if options.namespace != nil {
+		namespace = *options.namespace
+	}
+	ctx := logging.With(context.Background(), logging.ChatID(chatID))
+	req := &pb.DownloadAttachmentRequest{ChatId: chatID, Namespace: namespace, Url: url}
+
+	nodes := c.routeNodes(namespace, chatID)
+	if len(nodes) == 0 {
+		return nil, chaterr.ErrNoServers
+	}
+	var lastErr error
+	for _, node := range nodes {
+		client, err := c.serverClient(node.Address)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		head, written, err := c.downloadFrom(ctx, client, req, w)
+		if err != nil {
+			c.log.WarnContext(ctx, "Failed to download attachment", logging.NodeID(node.NodeID), logging.Err(err))
+			c.recordFailure(node.Address)
+			if written {
+				return nil, err
+			}
+			lastErr = err
+			continue
+		}
+		c.recordSuccess(node.Address)
+		if head.ErrorCode == pb.ErrorCode_ERROR_NONE {
+			return head.Attachment, nil
+		}
+
+		lastErr = &chaterr.Rejection{ServerID: node.NodeID, Op: "attachment download", Code: head.ErrorCode, Details: head.ErrorDetails}
+		if !shouldFailover(head.ErrorCode) {
+			return nil, lastErr
+		}
+	}
+	if lastErr == nil {
+		return nil, chaterr.ErrNoServers
+	}
+	return nil, fmt.Errorf("%w: %w", chaterr.ErrAllReplicasFailed, lastErr)
+}
+
+// downloadFrom copies an attachment from one server to w, returning the
+// stream's first response, which describes the attachment or carries the
+// server's refusal, and whether any content was written
+func (c *SmartClient) downloadFrom(ctx context.Context, client pb.ChatServiceClient, req *pb.DownloadAttachmentRequest, w io.Writer) (*pb.AttachmentData, bool, error) {
+	ctx, progress, cancel := c.progressContext(ctx)
+	defer cancel()
+	stream, err := client.DownloadAttachment(ctx, req)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var head *pb.AttachmentData
+	written := false
+	for {
+		data, err := stream.Recv()
+		if errors.Is(err, io.EOF) && head != nil {
+			return head, written, nil
+		}
+		if errors.Is(err, io.EOF) {
+			return nil, written, errors.New("download ended before the attachment")
+		}
+		if err != nil {
+			return nil, written, err
+		}
+		progress()
+		if head == nil {
+			head = data
+		}
+		if data.ErrorCode != pb.ErrorCode_ERROR_NONE {
+			if written {
+				return nil, written, fmt.Errorf("%s: %s", data.ErrorCode, data.ErrorDetails)
+			}
+			return data, written, nil
+		}
+		if len(data.Data) > 0 {
+			written = true
+			if _, err := w.Write(data.Data); err != nil {
+				return nil, written, err
+			}
+		}
+	}
+}
+
+// progressContext returns a context canceled once the returned progress
+// function hasn't been called for the request timeout, so a stream is
+// bounded by its stalls rather than its length
+func (c *SmartClient) progressContext(ctx context.Context) (context.Context, func(), context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	timeout := c.config.RequestTimeout
+	timer := time.AfterFunc(timeout, cancel)
+	return ctx, func() { timer.Reset(timeout) }, func() {
+		timer.Stop()
+		cancel()
+	}
+}
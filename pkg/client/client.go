@@ -0,0 +1,1100 @@
+// Package client implements a smart client that uses consistent hashing
+// to route chat messages to the appropriate server, with automatic
+// failover when the primary server is unavailable.
+package client
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/internal/phi"
+	"github.com/sh4shv4t/DistriChat/internal/requestid"
+	"github.com/sh4shv4t/DistriChat/internal/topology"
+	"github.com/sh4shv4t/DistriChat/pkg/auth"
+	"github.com/sh4shv4t/DistriChat/pkg/chaos"
+	"github.com/sh4shv4t/DistriChat/pkg/chaterr"
+	"github.com/sh4shv4t/DistriChat/pkg/clock"
+	"github.com/sh4shv4t/DistriChat/pkg/events"
+	"github.com/sh4shv4t/DistriChat/pkg/logging"
+	"github.com/sh4shv4t/DistriChat/pkg/metrics"
+	"github.com/sh4shv4t/DistriChat/pkg/mtls"
+	"github.com/sh4shv4t/DistriChat/pkg/ring"
+	"github.com/sh4shv4t/DistriChat/pkg/sim"
+	"github.com/sh4shv4t/DistriChat/pkg/tracing"
+	pb "github.com/sh4shv4t/DistriChat/proto"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// tracer records each call as a trace: the route decision, then one RPC
+// span per attempt, continued by the servers
+var tracer = tracing.Tracer("github.com/sh4shv4t/DistriChat/pkg/client")
+
+// SmartClient routes chat messages using consistent hashing with failover support
+type SmartClient struct {
+	mu sync.RWMutex
+
+	// Hash ring for routing decisions
+	ring *ring.HashRing
+
+	// Connection pool - maps server address to gRPC client
+	connections map[string]*serverConnection
+
+	// Configuration
+	config ClientConfig
+
+	// Statistics
+	stats    ClientStats
+	metrics  clientMetrics
+	inFlight atomic.Int64 // Calls in progress
+
+	// Control-plane connection and topology watcher (nil unless following a coordinator)
+	coordinatorConn *grpc.ClientConn
+	watcher         *topology.Watcher
+
+	// Device this client's heartbeats are sent as, so a user's other
+	// clients keep it online after this one signs off
+	device string
+
+	log *slog.Logger
+}
+
+// serverConnection represents a connection to a single server
+type serverConnection struct {
+	address string
+	conn    *grpc.ClientConn
+	client  pb.ChatServiceClient
+
+	// Set when the server is marked down or the cluster declares it dead;
+	// the client never routes to it until it is marked up again
+	down bool
+
+	// Successful calls are heartbeats for the detector. Silence only counts
+	// against the server once a call has failed since the last success, so
+	// an idle client doesn't suspect everyone.
+	detector *phi.Detector
+	failing  bool
+
+	// Suspicion level the cluster's gossip last reported for the server
+	reported float64
+}
+
+// newServerConnection creates an unconnected entry for address
+func newServerConnection(address string) *serverConnection {
+	return &serverConnection{
+		address:  address,
+		detector: phi.NewDetector(phi.Config{}),
+	}
+}
+
+// suspicion returns how likely the server is to have failed, as a phi
+// level: the higher of the cluster's report and the client's own detector.
+// A server that failed without ever answering is infinitely suspect.
+func (s *serverConnection) suspicion(now time.Time) float64 {
+	level := s.reported
+	if s.failing {
+		if s.detector.Last().IsZero() {
+			return math.Inf(1)
+		}
+		level = math.Max(level, s.detector.Phi(now))
+	}
+	return level
+}
+
+// ClientConfig contains configuration for the smart client
+type ClientConfig struct {
+	// Number of virtual nodes per server (default: 100)
+	VirtualNodes int
+
+	// Maximum retry attempts for failover (default: 3)
+	MaxRetries int
+
+	// Connection timeout (default: 5 seconds)
+	ConnectTimeout time.Duration
+
+	// Request timeout (default: 10 seconds)
+	RequestTimeout time.Duration
+
+	// Region the client runs in. Requests go to the chat's owner in this
+	// region first, then to other nodes here, then to NearbyRegions in
+	// order, then anywhere else. Empty is the default region.
+	Region        string
+	NearbyRegions []string
+
+	// Metrics receives the client's and its ring's series (default: none)
+	Metrics metrics.Registry
+
+	// Namespace chats are sent to unless a call passes WithNamespace. Each
+	// namespace is a logical ring with its own servers, e.g. one per tenant
+	// tier. Empty is the default namespace.
+	Namespace string
+
+	// Copies the servers keep of each chat per region (their Replication.N,
+	// default: 1). Stale history reads are spread across that many nodes.
+	ReplicationFactor int
+
+	// Suspicion level (phi) at which the client stops routing to a server
+	// (default: 8). Kept below the servers' gossip DeadThreshold so
+	// requests move to a successor before the cluster evicts the server.
+	SuspicionThreshold float64
+
+	// Chaos, if set, applies its fault rules to the client's calls as they
+	// are made, from ChaosName (default: "client") to the ID of the server
+	// called, so the rules can single out this client's traffic
+	Chaos     *chaos.Injector
+	ChaosName string
+
+	// Dialer, if set, connects to servers in place of TCP, e.g. over
+	// in-memory bufconn listeners in tests
+	Dialer func(ctx context.Context, address string) (net.Conn, error)
+
+	// Clock heartbeats, suspicion and message timestamps are read from,
+	// and Rand the source of message IDs and replica choices (defaults:
+	// the system clock and a randomly seeded stream). A simulation sets
+	// both so the client makes the same choices on every run.
+	Clock clock.Clock
+	Rand  *sim.Rand
+
+	// Events receives the client's failovers and the servers it marks
+	// down (default: events.Default())
+	Events *events.Bus
+
+	// Logger the client and its ring log to, tagged with their components
+	// (default: slog's default logger)
+	Logger *slog.Logger
+
+	// Hasher places chats on the ring (default: CRC32). It must match the
+	// servers' hasher.
+	Hasher ring.Hasher
+
+	// TLS, if set, connects to servers over mutual TLS, presenting its
+	// certificate and requiring theirs to chain to its CAs. Rotated files
+	// are used by the connections made after the change.
+	TLS *mtls.Provider
+
+	// APIKey, if set, is sent with every call for servers requiring API
+	// keys (see server.ServerConfig.APIKeys)
+	APIKey string
+
+	// Credentials, if set, are sent with every call, e.g. an identity
+	// provider's tokens with auth.BearerToken
+	Credentials credentials.PerRPCCredentials
+}
+
+// Option adjusts a client's configuration as NewSmartClient creates it,
+// after the ClientConfig it is given, so callers can set a few fields
+// without spelling out the rest
+type Option func(*ClientConfig)
+
+// WithTimeout sets the timeout of each request (ClientConfig.RequestTimeout)
+func WithTimeout(d time.Duration) Option {
+	return func(c *ClientConfig) { c.RequestTimeout = d }
+}
+
+// WithLogger sets the logger the client and its ring log to
+// (ClientConfig.Logger)
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *ClientConfig) { c.Logger = logger }
+}
+
+// WithHasher sets the hasher placing chats on the ring (ClientConfig.Hasher)
+func WithHasher(h ring.Hasher) Option {
+	return func(c *ClientConfig) { c.Hasher = h }
+}
+
+// WithTLS connects to servers over mutual TLS (ClientConfig.TLS)
+func WithTLS(provider *mtls.Provider) Option {
+	return func(c *ClientConfig) { c.TLS = provider }
+}
+
+// WithAPIKey sends an API key with every call (ClientConfig.APIKey)
+func WithAPIKey(key string) Option {
+	return func(c *ClientConfig) { c.APIKey = key }
+}
+
+// WithCredentials sends per-call credentials with every call
+// (ClientConfig.Credentials)
+func WithCredentials(creds credentials.PerRPCCredentials) Option {
+	return func(c *ClientConfig) { c.Credentials = creds }
+}
+
+// DefaultClientConfig returns sensible default configuration
+func DefaultClientConfig() ClientConfig {
+	return ClientConfig{
+		VirtualNodes:       100,
+		MaxRetries:         3,
+		ConnectTimeout:     5 * time.Second,
+		RequestTimeout:     10 * time.Second,
+		SuspicionThreshold: 8,
+	}
+}
+
+// ClientStats tracks client routing statistics
+type ClientStats struct {
+	TotalRequests   int64
+	SuccessRequests int64
+	FailedRequests  int64
+	FailoverCount   int64
+	PrimaryHits     int64
+}
+
+// NewSmartClient creates a new smart client with consistent hash routing
+func NewSmartClient(config ClientConfig, opts ...Option) *SmartClient {
+	for _, opt := range opts {
+		opt(&config)
+	}
+	if config.VirtualNodes <= 0 {
+		config.VirtualNodes = 100
+	}
+	if config.MaxRetries <= 0 {
+		config.MaxRetries = 3
+	}
+	if config.ConnectTimeout <= 0 {
+		config.ConnectTimeout = 5 * time.Second
+	}
+	if config.RequestTimeout <= 0 {
+		config.RequestTimeout = 10 * time.Second
+	}
+	if config.ReplicationFactor <= 0 {
+		config.ReplicationFactor = 1
+	}
+	if config.SuspicionThreshold <= 0 {
+		config.SuspicionThreshold = 8
+	}
+
+	if config.Metrics == nil {
+		config.Metrics = metrics.Nop()
+	}
+	if config.ChaosName == "" {
+		config.ChaosName = "client"
+	}
+	if config.Clock == nil {
+		config.Clock = clock.System()
+	}
+	if config.Rand == nil {
+		config.Rand = sim.NewRand(time.Now().UnixNano())
+	}
+	if config.Events == nil {
+		config.Events = events.Default()
+	}
+
+	log, ringLog := logging.Logger("client"), logging.Logger("ring")
+	if config.Logger != nil {
+		log = config.Logger.With(logging.Component("client"))
+		ringLog = config.Logger.With(logging.Component("ring"))
+	}
+	ringOpts := []ring.Option{ring.WithLogger(ringLog)}
+	if config.Hasher != nil {
+		ringOpts = append(ringOpts, ring.WithHasher(config.Hasher))
+	}
+
+	c := &SmartClient{
+		ring:        ring.NewHashRing(config.VirtualNodes, ringOpts...),
+		connections: make(map[string]*serverConnection),
+		config:      config,
+		metrics:     newClientMetrics(config.Metrics),
+		device:      fmt.Sprintf("d-%016x", config.Rand.Uint64()),
+		log:         log,
+	}
+	c.ring.SetMetrics(config.Metrics)
+	return c
+}
+
+// AddServer adds a server to the client's routing table
+func (c *SmartClient) AddServer(serverID string, address string, capacity int) error {
+	return c.AddServerInRegion(serverID, address, capacity, "")
+}
+
+// AddServerInRegion adds a server running in the given region to the
+// client's routing table
+func (c *SmartClient) AddServerInRegion(serverID string, address string, capacity int, region string) error {
+	return c.AddServerInNamespace(serverID, address, capacity, region, "")
+}
+
+// AddServerInNamespace adds a server running in the given region and
+// serving the given namespace to the client's routing table
+func (c *SmartClient) AddServerInNamespace(serverID string, address string, capacity int, region, namespace string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Add to hash ring
+	c.ring.AddNodeInNamespace(serverID, capacity, address, region, namespace)
+
+	// Establish connection
+	entry := newServerConnection(address)
+	c.connections[address] = entry
+	conn, err := c.connectToServer(address)
+	if err != nil {
+		c.log.Warn("Could not connect to server", logging.NodeID(serverID), "address", address, logging.Err(err))
+		// Still add to ring, connection will be retried later
+		entry.failing = true
+		return nil
+	}
+	entry.conn = conn
+	entry.client = pb.NewChatServiceClient(conn)
+	entry.detector.Heartbeat(c.config.Clock.Now())
+
+	c.log.Info("Added server", logging.NodeID(serverID), "address", address, "capacity", capacity)
+	return nil
+}
+
+// RemoveServer removes a server from the routing table
+func (c *SmartClient) RemoveServer(serverID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Get address before removing from ring
+	addr, ok := c.ring.GetNodeAddress(serverID)
+	if ok {
+		// Close and remove connection
+		if conn, exists := c.connections[addr]; exists {
+			if conn.conn != nil {
+				conn.conn.Close()
+			}
+			delete(c.connections, addr)
+		}
+	}
+
+	// Remove from ring
+	c.ring.RemoveNode(serverID)
+	c.log.Info("Removed server", logging.NodeID(serverID))
+}
+
+// MarkServerDown stops routing to a server until it is marked up (for
+// simulation, or when the cluster declares it dead)
+func (c *SmartClient) MarkServerDown(serverID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	addr, ok := c.ring.GetNodeAddress(serverID)
+	if !ok {
+		return
+	}
+
+	if conn, exists := c.connections[addr]; exists {
+		c.setDown(conn, serverID, "marked down")
+		c.log.Warn("Marked server down", logging.NodeID(serverID))
+	}
+}
+
+// MarkServerUp resumes routing to a server, clearing any suspicion, and
+// reconnects to it at once rather than after the connection's backoff
+func (c *SmartClient) MarkServerUp(serverID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	addr, ok := c.ring.GetNodeAddress(serverID)
+	if !ok {
+		return
+	}
+
+	if conn, exists := c.connections[addr]; exists {
+		conn.down = false
+		conn.failing = false
+		conn.reported = 0
+		if conn.conn != nil {
+			conn.conn.ResetConnectBackoff()
+		}
+		c.log.Info("Marked server up", logging.NodeID(serverID))
+	}
+}
+
+// CallOption adjusts a single SendMessage or GetHistory call
+type CallOption func(*callOptions)
+
+// callOptions holds the per-call settings
+type callOptions struct {
+	consistency pb.ConsistencyLevel
+	allowStale  bool
+	namespace   *string
+	requestID   string
+	subscriber  string
+	durable     bool
+}
+
+// WithConsistency sets how many replicas the call must reach. Without it the
+// server's configured W (writes) or R (reads) applies.
+func WithConsistency(level pb.ConsistencyLevel) CallOption {
+	return func(o *callOptions) {
+		o.consistency = level
+	}
+}
+
+// WithAllowStale lets any of the chat's replicas answer a GetHistory call
+// from its own copy, taking read load off the owner. The response reports
+// the replica's lag (KnownSeq - ServedSeq).
+func WithAllowStale() CallOption {
+	return func(o *callOptions) {
+		o.allowStale = true
+	}
+}
+
+// WithNamespace sends the call to a namespace other than the client's
+// configured one, e.g. for a client serving tenants of several tiers
+func WithNamespace(namespace string) CallOption {
+	return func(o *callOptions) {
+		o.namespace = &namespace
+	}
+}
+
+// WithSubscriber names the user a Subscribe call follows the chat for, so
+// the server doesn't push them notifications of messages they already see.
+// Servers that authenticate callers use the principal instead.
+func WithSubscriber(userID string) CallOption {
+	return func(o *callOptions) {
+		o.subscriber = userID
+	}
+}
+
+// WithDurable makes a Subscribe call's position the server's to keep: the
+// subscription resumes after the last message acknowledged with
+// Subscription.Ack, delivering again those received but not acknowledged,
+// rather than after the last received. Messages are then delivered at
+// least once, and may repeat. It needs WithSubscriber or an authenticated
+// principal.
+func WithDurable() CallOption {
+	return func(o *callOptions) {
+		o.durable = true
+	}
+}
+
+// WithRequestID makes the call carry id rather than a new request ID, e.g.
+// to tie it to the request that caused it
+func WithRequestID(id string) CallOption {
+	return func(o *callOptions) {
+		o.requestID = id
+	}
+}
+
+// applyOptions folds opts into callOptions
+func applyOptions(opts []CallOption) callOptions {
+	var o callOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// requestIDOrNew returns the request ID set by WithRequestID, or a new one
+func (o callOptions) requestIDOrNew() string {
+	if o.requestID != "" {
+		return o.requestID
+	}
+	return requestid.New()
+}
+
+// SendMessage routes a plain text chat message to the appropriate server with failover.
+// Its errors match the pkg/chaterr sentinels: ErrNoServers for an empty
+// ring, a *chaterr.Rejection (ErrRateLimited, say) for a refusal another
+// server wouldn't change, and ErrAllReplicasFailed once every candidate
+// failed, wrapping the last failure.
+func (c *SmartClient) SendMessage(chatID, senderID, message string, opts ...CallOption) (*pb.ChatResponse, error) {
+	return c.send(&pb.ChatRequest{
+		ChatId:    chatID,
+		SenderId:  senderID,
+		Timestamp: c.config.Clock.Now().Unix(),
+		Content:   &pb.ChatRequest_Text{Text: message},
+	}, opts)
+}
+
+// SendAttachment routes an attachment reference to the chat's server with failover
+func (c *SmartClient) SendAttachment(chatID, senderID string, attachment *pb.Attachment, opts ...CallOption) (*pb.ChatResponse, error) {
+	return c.send(&pb.ChatRequest{
+		ChatId:    chatID,
+		SenderId:  senderID,
+		Timestamp: c.config.Clock.Now().Unix(),
+		Content:   &pb.ChatRequest_Attachment{Attachment: attachment},
+	}, opts)
+}
+
+// SendSystemEvent routes a system event to the chat's server with failover
+func (c *SmartClient) SendSystemEvent(chatID string, event *pb.SystemEvent, opts ...CallOption) (*pb.ChatResponse, error) {
+	return c.send(&pb.ChatRequest{
+		ChatId:    chatID,
+		SenderId:  event.GetActorId(),
+		Timestamp: c.config.Clock.Now().Unix(),
+		Content:   &pb.ChatRequest_SystemEvent{SystemEvent: event},
+	}, opts)
+}
+
+// SendRequest routes a fully built request, for components such as the
+// federation bridge that forward existing messages. The ring epoch and
+// consistency level are set by the client, and a message ID if it has none.
+func (c *SmartClient) SendRequest(req *pb.ChatRequest, opts ...CallOption) (*pb.ChatResponse, error) {
+	return c.send(req, opts)
+}
+
+// send routes a prepared request using the ring, walking to successors on failure
+func (c *SmartClient) send(req *pb.ChatRequest, opts []CallOption) (resp *pb.ChatResponse, err error) {
+	chatID := req.ChatId
+	options := applyOptions(opts)
+	req.Consistency = options.consistency
+	if options.namespace != nil {
+		req.Namespace = *options.namespace
+	} else if req.Namespace == "" {
+		req.Namespace = c.config.Namespace
+	}
+
+	// Every attempt carries the same ID, so if a server we gave up on
+	// accepted the message anyway, replicas collapse the two copies
+	if req.MessageId == "" {
+		req.MessageId = c.newMessageID()
+	}
+
+	// Likewise one request ID for every attempt, so the servers' logs of
+	// each show up under one ID
+	requestID := options.requestIDOrNew()
+
+	ctx, span := tracer.Start(context.Background(), "client.send", trace.WithAttributes(
+		attribute.String("chat.id", chatID),
+		attribute.String("message.id", req.MessageId),
+		attribute.String("chat.namespace", req.Namespace),
+		attribute.String("request.id", requestID),
+	))
+	defer func() { endSpan(span, resp.GetServerId(), err) }()
+	ctx = requestid.With(ctx, requestID)
+	ctx = logging.With(ctx, logging.ChatID(chatID))
+
+	outcome := "failed"
+	c.inFlight.Add(1)
+	defer func(start time.Time) {
+		c.inFlight.Add(-1)
+		c.observeCall("SendMessage", req.Namespace, outcome, start)
+	}(time.Now())
+
+	c.mu.Lock()
+	c.stats.TotalRequests++
+	c.mu.Unlock()
+
+	// Get ordered list of servers for this chat ID (for failover)
+	nodes := c.route(ctx, req.Namespace, chatID)
+	if len(nodes) == 0 {
+		c.mu.Lock()
+		c.stats.FailedRequests++
+		c.mu.Unlock()
+		return nil, chaterr.ErrNoServers
+	}
+
+	// Stamp the request with the ring view used for routing
+	req.RingEpoch = c.ring.Epoch()
+
+	// Try primary server first, then failover to subsequent servers
+	var lastErr error
+	rerouted := false
+	for i := 0; i < len(nodes); i++ {
+		node := nodes[i]
+		c.log.DebugContext(ctx, "Routing message", logging.NodeID(node.NodeID),
+			"attempt", i+1, "candidates", len(nodes))
+
+		resp, err := c.sendToServer(ctx, node.Address, req)
+		if err == nil && resp.ErrorCode != pb.ErrorCode_ERROR_DRAINING {
+			c.recordSuccess(node.Address)
+		}
+		if err == nil && resp.RingEpoch > req.RingEpoch {
+			// The server knows a newer topology - adopt it for future routing
+			synced, syncErr := c.SyncRing(node.Address)
+			if syncErr != nil {
+				c.log.WarnContext(ctx, "Ring sync failed", logging.NodeID(node.NodeID), logging.Err(syncErr))
+			}
+
+			// Routed with a stale view: start over once with the new one
+			if synced && !resp.Success && resp.ErrorCode == pb.ErrorCode_ERROR_NOT_OWNER && !rerouted {
+				rerouted = true
+				c.metrics.reroutes.Inc()
+				nodes = c.route(ctx, req.Namespace, chatID)
+				req.RingEpoch = c.ring.Epoch()
+				i = -1
+				continue
+			}
+		}
+		if err == nil && resp.Success {
+			c.mu.Lock()
+			c.stats.SuccessRequests++
+			if i == 0 {
+				c.stats.PrimaryHits++
+				outcome = "primary"
+			} else {
+				c.stats.FailoverCount++
+				outcome = "failover"
+				c.log.InfoContext(ctx, "Failover successful", logging.NodeID(node.NodeID))
+				c.config.Events.Publish(events.Failover{
+					Time:   c.config.Clock.Now(),
+					ChatID: chatID,
+					From:   nodes[0].NodeID,
+					To:     node.NodeID,
+				})
+			}
+			c.mu.Unlock()
+			return resp, nil
+		}
+
+		if err != nil {
+			lastErr = err
+			c.log.WarnContext(ctx, "Failed to reach server", logging.NodeID(node.NodeID), logging.Err(err))
+
+			// Count the silence against this server from now on
+			c.recordFailure(node.Address)
+			continue
+		}
+
+		lastErr = &chaterr.Rejection{ServerID: node.NodeID, Op: "request", Code: resp.ErrorCode, Details: resp.ErrorDetails}
+		c.log.WarnContext(ctx, "Server rejected request", logging.NodeID(node.NodeID),
+			"code", resp.ErrorCode.String(), "details", resp.ErrorDetails)
+
+		if !shouldFailover(resp.ErrorCode) {
+			// The request itself is the problem - another server won't help
+			c.mu.Lock()
+			c.stats.FailedRequests++
+			c.mu.Unlock()
+			return nil, lastErr
+		}
+
+		if resp.ErrorCode == pb.ErrorCode_ERROR_DRAINING {
+			c.markConnectionDown(node.NodeID, node.Address, "draining")
+		}
+	}
+
+	c.mu.Lock()
+	c.stats.FailedRequests++
+	c.mu.Unlock()
+
+	return nil, fmt.Errorf("%w: %w", chaterr.ErrAllReplicasFailed, lastErr)
+}
+
+// shouldFailover reports whether a rejection with the given code is worth
+// retrying on the next server in the ring
+func shouldFailover(code pb.ErrorCode) bool {
+	switch code {
+	case pb.ErrorCode_ERROR_NOT_OWNER,
+		pb.ErrorCode_ERROR_DRAINING,
+		pb.ErrorCode_ERROR_OVERLOADED,
+		pb.ErrorCode_ERROR_NO_LEASE:
+		return true
+	default:
+		return false
+	}
+}
+
+// GetHistory reads a chat's recent messages (all of them if limit <= 0)
+// from its owner, failing over to successors like SendMessage, with the
+// same errors. Stale reads start at a random replica instead of the owner.
+func (c *SmartClient) GetHistory(chatID string, limit int, opts ...CallOption) (resp *pb.HistoryResponse, err error) {
+	options := applyOptions(opts)
+	namespace := c.config.Namespace
+	if options.namespace != nil {
+		namespace = *options.namespace
+	}
+
+	requestID := options.requestIDOrNew()
+
+	ctx, span := tracer.Start(context.Background(), "client.GetHistory", trace.WithAttributes(
+		attribute.String("chat.id", chatID),
+		attribute.String("chat.namespace", namespace),
+		attribute.String("request.id", requestID),
+	))
+	defer func() { endSpan(span, resp.GetServerId(), err) }()
+	ctx = requestid.With(ctx, requestID)
+	ctx = logging.With(ctx, logging.ChatID(chatID))
+
+	outcome := "failed"
+	c.inFlight.Add(1)
+	defer func(start time.Time) {
+		c.inFlight.Add(-1)
+		c.observeCall("GetHistory", namespace, outcome, start)
+	}(time.Now())
+
+	nodes := c.route(ctx, namespace, chatID)
+	if len(nodes) == 0 {
+		return nil, chaterr.ErrNoServers
+	}
+
+	req := &pb.HistoryRequest{
+		ChatId:      chatID,
+		Limit:       int32(limit),
+		Consistency: options.consistency,
+		AllowStale:  options.allowStale,
+		Namespace:   namespace,
+	}
+	if options.allowStale {
+		replicas := c.config.ReplicationFactor
+		if replicas > len(nodes) {
+			replicas = len(nodes)
+		}
+		start := c.config.Rand.Intn(replicas)
+		rotated := append([]ring.NodeInfo(nil), nodes[start:replicas]...)
+		rotated = append(rotated, nodes[:start]...)
+		nodes = append(rotated, nodes[replicas:]...)
+	}
+
+	var lastErr error
+	for i, node := range nodes {
+		client, err := c.serverClient(node.Address)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, c.config.RequestTimeout)
+		resp, err := client.GetHistory(ctx, req)
+		cancel()
+
+		if err != nil {
+			lastErr = err
+			c.log.WarnContext(ctx, "Failed to read history", logging.NodeID(node.NodeID), logging.Err(err))
+			c.recordFailure(node.Address)
+			continue
+		}
+		c.recordSuccess(node.Address)
+		if resp.Success {
+			outcome = "primary"
+			if i > 0 {
+				outcome = "failover"
+			}
+			return resp, nil
+		}
+
+		lastErr = &chaterr.Rejection{ServerID: node.NodeID, Op: "history read", Code: resp.ErrorCode, Details: resp.ErrorDetails}
+		if !shouldFailover(resp.ErrorCode) {
+			return nil, lastErr
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %w", chaterr.ErrAllReplicasFailed, lastErr)
+}
+
+// newMessageID returns a random ID for a message sent by this client
+func (c *SmartClient) newMessageID() string {
+	return fmt.Sprintf("m-%016x%016x", c.config.Rand.Uint64(), c.config.Rand.Uint64())
+}
+
+// routeNodes returns the servers to try for a chat in a namespace, nearest
+// region first. Only the namespace's servers are candidates.
+func (c *SmartClient) routeNodes(namespace, chatID string) []ring.NodeInfo {
+	regions := append([]string{c.config.Region}, c.config.NearbyRegions...)
+	return c.ring.Namespace(namespace).GetNodesNear(chatID, c.config.MaxRetries, regions...)
+}
+
+// route is routeNodes recorded as a span of the trace in ctx
+func (c *SmartClient) route(ctx context.Context, namespace, chatID string) []ring.NodeInfo {
+	_, span := tracer.Start(ctx, "client.route")
+	defer span.End()
+
+	nodes := c.routeNodes(namespace, chatID)
+	candidates := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		candidates = append(candidates, node.NodeID)
+	}
+	span.SetAttributes(
+		attribute.Int64("ring.epoch", int64(c.ring.Epoch())),
+		attribute.StringSlice("route.candidates", candidates),
+	)
+	return nodes
+}
+
+// endSpan closes a call's span, recording the server that answered or the
+// error that ended it
+func endSpan(span trace.Span, serverID string, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	} else {
+		span.SetAttributes(attribute.String("server.id", serverID))
+	}
+	span.End()
+}
+
+// sendToServer sends a request to a specific server
+func (c *SmartClient) sendToServer(ctx context.Context, address string, req *pb.ChatRequest) (*pb.ChatResponse, error) {
+	client, err := c.serverClient(address)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.config.RequestTimeout)
+	defer cancel()
+
+	return client.PostMessage(ctx, req)
+}
+
+// serverClient returns the ChatService client for a server the client
+// still routes to, reconnecting if the connection was never established
+func (c *SmartClient) serverClient(address string) (pb.ChatServiceClient, error) {
+	c.mu.RLock()
+	conn, exists := c.connections[address]
+	var down bool
+	var level float64
+	if exists {
+		down = conn.down
+		level = conn.suspicion(c.config.Clock.Now())
+	}
+	c.mu.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("no connection to %s", address)
+	}
+
+	if down {
+		return nil, fmt.Errorf("server %s is marked as down", address)
+	}
+	if level >= c.config.SuspicionThreshold {
+		return nil, fmt.Errorf("server %s is suspected down (phi %.1f)", address, level)
+	}
+
+	if conn.client == nil {
+		// Try to reconnect
+		c.mu.Lock()
+		grpcConn, err := c.connectToServer(address)
+		if err != nil {
+			c.mu.Unlock()
+			return nil, err
+		}
+		conn.conn = grpcConn
+		conn.client = pb.NewChatServiceClient(grpcConn)
+		c.mu.Unlock()
+	}
+
+	return conn.client, nil
+}
+
+// connectToServer establishes a gRPC connection to a server
+func (c *SmartClient) connectToServer(address string) (*grpc.ClientConn, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.config.ConnectTimeout)
+	defer cancel()
+
+	creds := insecure.NewCredentials()
+	if c.config.TLS != nil {
+		creds = c.config.TLS.ClientCredentials()
+	}
+	opts := append([]grpc.DialOption{
+		grpc.WithTransportCredentials(creds),
+		grpc.WithBlock(),
+		tracing.DialOption(),
+		requestid.DialOption(),
+	}, chaos.ClientOptions(c.config.Chaos, c.config.ChaosName, c.chaosTarget(address))...)
+	if c.config.Dialer != nil {
+		opts = append(opts, grpc.WithContextDialer(c.config.Dialer))
+	}
+	if c.config.APIKey != "" {
+		opts = append(opts, grpc.WithPerRPCCredentials(auth.APIKeyCredentials(c.config.APIKey)))
+	}
+	if c.config.Credentials != nil {
+		opts = append(opts, grpc.WithPerRPCCredentials(c.config.Credentials))
+	}
+	conn, err := grpc.DialContext(ctx, address, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", address, err)
+	}
+
+	return conn, nil
+}
+
+// chaosTarget names the server at address for fault rules: its ID if it is
+// in the ring, else the address (e.g. a coordinator's)
+func (c *SmartClient) chaosTarget(address string) string {
+	for _, id := range c.ring.GetAllNodes() {
+		if nodeAddress, ok := c.ring.GetNodeAddress(id); ok && nodeAddress == address {
+			return id
+		}
+	}
+	return address
+}
+
+// recordSuccess counts an answer from the server at address as a heartbeat
+func (c *SmartClient) recordSuccess(address string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if conn, exists := c.connections[address]; exists {
+		conn.detector.Heartbeat(c.config.Clock.Now())
+		conn.failing = false
+	}
+}
+
+// recordFailure notes a failed call to the server at address, so its
+// silence since the last answer starts to count as suspicion
+func (c *SmartClient) recordFailure(address string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if conn, exists := c.connections[address]; exists {
+		conn.failing = true
+	}
+}
+
+// markConnectionDown stops routing to serverID at address until it is
+// marked up
+func (c *SmartClient) markConnectionDown(serverID, address, reason string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if conn, exists := c.connections[address]; exists {
+		c.setDown(conn, serverID, reason)
+	}
+}
+
+// setDown marks conn down, publishing a NodeDown event if it was up (must
+// be called with c.mu held)
+func (c *SmartClient) setDown(conn *serverConnection, serverID, reason string) {
+	if conn.down {
+		return
+	}
+	conn.down = true
+	c.config.Events.Publish(events.NodeDown{
+		Time:     c.config.Clock.Now(),
+		Node:     serverID,
+		Reporter: "client",
+		Reason:   reason,
+	})
+}
+
+// Suspicion returns the client's current suspicion level (phi) for a
+// server. The client stops routing to it at SuspicionThreshold.
+func (c *SmartClient) Suspicion(serverID string) float64 {
+	addr, ok := c.ring.GetNodeAddress(serverID)
+	if !ok {
+		return math.Inf(1)
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	conn, exists := c.connections[addr]
+	if !exists {
+		return math.Inf(1)
+	}
+	return conn.suspicion(c.config.Clock.Now())
+}
+
+// GetStats returns current client statistics
+func (c *SmartClient) GetStats() ClientStats {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.stats
+}
+
+// GetTargetServer returns which server would handle a given chat ID in the
+// client's namespace
+func (c *SmartClient) GetTargetServer(chatID string) (string, string, bool) {
+	nodes := c.routeNodes(c.config.Namespace, chatID)
+	if len(nodes) == 0 {
+		return "", "", false
+	}
+	return nodes[0].NodeID, nodes[0].Address, true
+}
+
+// GetActiveServer returns which server a given chat ID's messages go to
+// now: the first server in its failover order that the client doesn't
+// consider down
+func (c *SmartClient) GetActiveServer(chatID string) (string, string, bool) {
+	for _, node := range c.routeNodes(c.config.Namespace, chatID) {
+		if c.isServerUp(node.NodeID) {
+			return node.NodeID, node.Address, true
+		}
+	}
+	return "", "", false
+}
+
+// GetServerCount returns the number of servers in the routing table
+func (c *SmartClient) GetServerCount() int {
+	return c.ring.GetNodeCount()
+}
+
+// Close closes all connections
+func (c *SmartClient) Close() {
+	if c.watcher != nil {
+		c.watcher.Stop()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.coordinatorConn != nil {
+		c.coordinatorConn.Close()
+		c.coordinatorConn = nil
+	}
+
+	for addr, conn := range c.connections {
+		if conn.conn != nil {
+			conn.conn.Close()
+			c.log.Debug("Closed connection", "address", addr)
+		}
+	}
+	c.connections = make(map[string]*serverConnection)
+}
+
+// HealthCheck checks if a specific server is healthy. Servers marked down
+// are not contacted. An answer counts as a heartbeat, so probing a
+// suspected server is how the client clears its suspicion.
+func (c *SmartClient) HealthCheck(serverID string) (bool, error) {
+	addr, ok := c.ring.GetNodeAddress(serverID)
+	if !ok {
+		return false, fmt.Errorf("server %s not found", serverID)
+	}
+
+	c.mu.RLock()
+	conn, exists := c.connections[addr]
+	var client pb.ChatServiceClient
+	var down bool
+	if exists {
+		client = conn.client
+		down = conn.down
+	}
+	c.mu.RUnlock()
+
+	if client == nil || down {
+		return false, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	resp, err := client.HealthCheck(ctx, &pb.HealthRequest{})
+	if err != nil {
+		c.recordFailure(addr)
+		return false, err
+	}
+	c.recordSuccess(addr)
+
+	return resp.Healthy, nil
+}
+
+// DebugPrint prints client state for debugging
+func (c *SmartClient) DebugPrint() {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	fmt.Println("\n=== Smart Client State ===")
+	fmt.Printf("Connected Servers: %d\n", len(c.connections))
+	now := c.config.Clock.Now()
+	for addr, conn := range c.connections {
+		level := conn.suspicion(now)
+		status := "UP"
+		if conn.down {
+			status = "DOWN"
+		} else if level >= c.config.SuspicionThreshold {
+			status = "SUSPECT"
+		}
+		fmt.Printf("  - %s [%s, phi %.1f]\n", addr, status, level)
+	}
+
+	stats := c.stats
+	fmt.Printf("\nStatistics:\n")
+	fmt.Printf("  Total Requests:   %d\n", stats.TotalRequests)
+	fmt.Printf("  Success Requests: %d\n", stats.SuccessRequests)
+	fmt.Printf("  Failed Requests:  %d\n", stats.FailedRequests)
+	fmt.Printf("  Primary Hits:     %d\n", stats.PrimaryHits)
+	fmt.Printf("  Failovers:        %d\n", stats.FailoverCount)
+	fmt.Println("===========================")
+	fmt.Println()
+
+	c.ring.DebugPrint()
+}
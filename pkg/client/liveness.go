@@ -0,0 +1,94 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/pkg/gossip"
+	"github.com/sh4shv4t/DistriChat/pkg/logging"
+	pb "github.com/sh4shv4t/DistriChat/proto"
+)
+
+// SyncLiveness asks the server at address for its gossip membership view
+// and marks ring servers down or up accordingly. Any gossiping server can
+// answer, so the client learns about failures it has not hit itself. The
+// server's suspicion level for each member is kept too, and the client
+// routes around members whose level reaches SuspicionThreshold even while
+// the cluster still has them SUSPECT. If the server's ring view is newer
+// than the client's, the client fetches the server's ring as well.
+func (c *SmartClient) SyncLiveness(address string) error {
+	c.mu.RLock()
+	conn, exists := c.connections[address]
+	c.mu.RUnlock()
+
+	if !exists || conn.conn == nil {
+		return fmt.Errorf("no connection to %s", address)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	resp, err := pb.NewGossipServiceClient(conn.conn).Members(ctx, &pb.MembersRequest{})
+	if err != nil {
+		return fmt.Errorf("failed to fetch members from %s: %w", address, err)
+	}
+
+	local := c.ring.State()
+	switch {
+	case resp.RingEpoch > local.Epoch:
+		if _, err := c.SyncRing(address); err != nil {
+			c.log.Warn("Ring sync failed", "address", address, logging.Err(err))
+		}
+	case resp.RingEpoch == local.Epoch && resp.RingDigest != "" && resp.RingDigest != local.Digest():
+		c.log.Warn("Server ring view conflicts with ours", "address", address, logging.Epoch(local.Epoch))
+	}
+
+	for _, m := range resp.Members {
+		member := gossip.MemberFromProto(m)
+		if !c.ring.NodeExists(member.ID) {
+			continue
+		}
+		c.setReportedSuspicion(member.ID, resp.Suspicion[member.ID])
+
+		switch member.State {
+		case gossip.StateAlive:
+			if !c.isServerUp(member.ID) {
+				c.MarkServerUp(member.ID)
+			}
+		case gossip.StateDead, gossip.StateLeft:
+			if c.isServerUp(member.ID) {
+				c.MarkServerDown(member.ID)
+			}
+		}
+	}
+
+	return nil
+}
+
+// isServerUp reports whether the client currently routes to serverID
+func (c *SmartClient) isServerUp(serverID string) bool {
+	addr, ok := c.ring.GetNodeAddress(serverID)
+	if !ok {
+		return false
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	conn, exists := c.connections[addr]
+	return exists && !conn.down && conn.suspicion(c.config.Clock.Now()) < c.config.SuspicionThreshold
+}
+
+// setReportedSuspicion records the cluster's suspicion level for serverID
+func (c *SmartClient) setReportedSuspicion(serverID string, level float64) {
+	addr, ok := c.ring.GetNodeAddress(serverID)
+	if !ok {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if conn, exists := c.connections[addr]; exists {
+		conn.reported = level
+	}
+}
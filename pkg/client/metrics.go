@@ -0,0 +1,34 @@
+package client
+
+import (
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/pkg/metrics"
+)
+
+// clientMetrics holds the client's series. ClientStats stays the
+// in-process view.
+type clientMetrics struct {
+	requests metrics.CounterVec
+	latency  metrics.HistogramVec
+	reroutes metrics.Counter
+}
+
+// newClientMetrics creates the client's series in reg
+func newClientMetrics(reg metrics.Registry) clientMetrics {
+	return clientMetrics{
+		requests: reg.Counter("districhat_client_requests_total",
+			"Calls made, by method, tenant and outcome (primary, failover or failed)", "method", "tenant", "outcome"),
+		latency: reg.Histogram("districhat_client_request_duration_seconds",
+			"Time to complete calls, including failover", metrics.LatencyBuckets, "method", "tenant"),
+		reroutes: reg.Counter("districhat_client_reroutes_total",
+			"Sends restarted after a server reported a newer ring").With(),
+	}
+}
+
+// observeCall records a call's outcome and latency. The tenant is the
+// call's namespace.
+func (c *SmartClient) observeCall(method, tenant, outcome string, start time.Time) {
+	c.metrics.requests.With(method, tenant, outcome).Inc()
+	c.metrics.latency.With(method, tenant).Observe(time.Since(start).Seconds())
+}
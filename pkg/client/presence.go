@@ -0,0 +1,224 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/pkg/chaterr"
+	"github.com/sh4shv4t/DistriChat/pkg/logging"
+	"github.com/sh4shv4t/DistriChat/pkg/ring"
+	pb "github.com/sh4shv4t/DistriChat/proto"
+)
+
+// Heartbeat keeps userID online for ttl (the server's presence TTL if
+// ttl <= 0) as seen from this client. Send it again well before ttl runs
+// out; a client that stops is taken offline once it does. It goes to the
+// server holding the user's presence, failing over to successors, which
+// relay it there.
+func (c *SmartClient) Heartbeat(userID string, ttl time.Duration, opts ...CallOption) error {
+	return c.updatePresence(&pb.PresenceUpdate{UserId: userID, TtlMs: ttl.Milliseconds(), Device: c.device}, opts)
+}
+
+// SignOff takes userID offline as seen from this client, at once. The user
+// stays online while another of its clients or subscriptions is live.
+func (c *SmartClient) SignOff(userID string, opts ...CallOption) error {
+	return c.updatePresence(&pb.PresenceUpdate{UserId: userID, Offline: true, Device: c.device}, opts)
+}
+
+// updatePresence sends a heartbeat or sign-off to the user's owner, or the
+// first of its successors to take it
+func (c *SmartClient) updatePresence(req *pb.PresenceUpdate, opts []CallOption) error {
+	ctx := context.Background()
+	var lastErr error
+	for _, node := range c.presenceNodes(req.UserId, opts) {
+		client, err := c.serverClient(node.Address)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		callCtx, cancel := context.WithTimeout(ctx, c.config.RequestTimeout)
+		resp, err := client.UpdatePresence(callCtx, req)
+		cancel()
+		if err != nil {
+			lastErr = err
+			c.log.WarnContext(ctx, "Failed to update presence", logging.NodeID(node.NodeID), logging.Err(err))
+			c.recordFailure(node.Address)
+			continue
+		}
+		c.recordSuccess(node.Address)
+		if resp.Success {
+			return nil
+		}
+
+		lastErr = &chaterr.Rejection{ServerID: node.NodeID, Op: "presence update", Code: resp.ErrorCode, Details: resp.ErrorDetails}
+		if !shouldFailover(resp.ErrorCode) {
+			return lastErr
+		}
+	}
+	if lastErr == nil {
+		return chaterr.ErrNoServers
+	}
+	return fmt.Errorf("%w: %w", chaterr.ErrAllReplicasFailed, lastErr)
+}
+
+// GetPresence returns the presence of each of userIDs, asking the servers
+// holding it. Users whose server can't be reached are returned with
+// PRESENCE_UNKNOWN.
+func (c *SmartClient) GetPresence(userIDs []string, opts ...CallOption) ([]*pb.Presence, error) {
+	if len(userIDs) == 0 {
+		return nil, nil
+	}
+	ctx := context.Background()
+	var lastErr error
+	for _, node := range c.presenceNodes(userIDs[0], opts) {
+		client, err := c.serverClient(node.Address)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		callCtx, cancel := context.WithTimeout(ctx, c.config.RequestTimeout)
+		resp, err := client.GetPresence(callCtx, &pb.GetPresenceRequest{UserIds: userIDs})
+		cancel()
+		if err != nil {
+			lastErr = err
+			c.log.WarnContext(ctx, "Failed to read presence", logging.NodeID(node.NodeID), logging.Err(err))
+			c.recordFailure(node.Address)
+			continue
+		}
+		c.recordSuccess(node.Address)
+		if resp.Success {
+			return resp.Presence, nil
+		}
+
+		lastErr = &chaterr.Rejection{ServerID: node.NodeID, Op: "presence read", Code: resp.ErrorCode, Details: resp.ErrorDetails}
+		if !shouldFailover(resp.ErrorCode) {
+			return nil, lastErr
+		}
+	}
+	if lastErr == nil {
+		return nil, chaterr.ErrNoServers
+	}
+	return nil, fmt.Errorf("%w: %w", chaterr.ErrAllReplicasFailed, lastErr)
+}
+
+// presenceNodes returns the servers to send a user's presence calls to, in
+// the order they are tried
+func (c *SmartClient) presenceNodes(userID string, opts []CallOption) []ring.NodeInfo {
+	options := applyOptions(opts)
+	namespace := c.config.Namespace
+	if options.namespace != nil {
+		namespace = *options.namespace
+	}
+	return c.routeNodes(namespace, userID)
+}
+
+// PresenceWatch follows some users' presence
+type PresenceWatch struct {
+	updates chan *pb.Presence
+	cancel  context.CancelFunc
+
+	mu  sync.Mutex
+	err error
+}
+
+// Updates delivers the users' current presence, then every change; a watch
+// reopened after its stream broke delivers their current presence again.
+// It is closed when the watch ends; Err then says why.
+func (w *PresenceWatch) Updates() <-chan *pb.Presence {
+	return w.updates
+}
+
+// Err returns why the watch ended: nil if it was closed, or
+// ErrAllReplicasFailed once no server would take it, wrapping the last
+// failure
+func (w *PresenceWatch) Err() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+// Close ends the watch
+func (w *PresenceWatch) Close() {
+	w.cancel()
+}
+
+// WatchPresence follows userIDs' presence. The watch is opened on the
+// first user's owner, failing over to successors, and reopened when its
+// stream breaks, like Subscribe.
+func (c *SmartClient) WatchPresence(userIDs []string, opts ...CallOption) (*PresenceWatch, error) {
+	if len(userIDs) == 0 || len(c.presenceNodes(userIDs[0], opts)) == 0 {
+		return nil, chaterr.ErrNoServers
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &PresenceWatch{updates: make(chan *pb.Presence, len(userIDs)+subscriptionBuffer), cancel: cancel}
+	go c.watchPresence(ctx, w, userIDs, opts)
+	return w, nil
+}
+
+// watchPresence keeps w open until it is closed, or a round of every
+// server delivers nothing
+func (c *SmartClient) watchPresence(ctx context.Context, w *PresenceWatch, userIDs []string, opts []CallOption) {
+	defer close(w.updates)
+
+	for {
+		var lastErr error
+		progressed := false
+		for _, node := range c.presenceNodes(userIDs[0], opts) {
+			delivered, err := c.watchPresenceOn(ctx, node, userIDs, w.updates)
+			if ctx.Err() != nil {
+				return
+			}
+			lastErr = err
+			c.log.InfoContext(ctx, "Presence watch ended, reopening", logging.NodeID(node.NodeID), logging.Err(err))
+			if delivered {
+				progressed = true
+				break
+			}
+		}
+		if !progressed {
+			w.mu.Lock()
+			w.err = fmt.Errorf("%w: %w", chaterr.ErrAllReplicasFailed, lastErr)
+			w.mu.Unlock()
+			return
+		}
+
+		select {
+		case <-time.After(resubscribeDelay):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// watchPresenceOn streams the users' presence from one server into out
+// until the stream ends, reporting whether anything was delivered
+func (c *SmartClient) watchPresenceOn(ctx context.Context, node ring.NodeInfo, userIDs []string, out chan<- *pb.Presence) (bool, error) {
+	client, err := c.serverClient(node.Address)
+	if err != nil {
+		return false, err
+	}
+	stream, err := client.WatchPresence(ctx, &pb.WatchPresenceRequest{UserIds: userIDs})
+	if err != nil {
+		c.recordFailure(node.Address)
+		return false, err
+	}
+
+	delivered := false
+	for {
+		update, err := stream.Recv()
+		if err != nil {
+			return delivered, err
+		}
+		select {
+		case out <- update:
+		case <-ctx.Done():
+			return delivered, ctx.Err()
+		}
+		delivered = true
+	}
+}
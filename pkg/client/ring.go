@@ -0,0 +1,241 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/internal/topology"
+	"github.com/sh4shv4t/DistriChat/pkg/logging"
+	"github.com/sh4shv4t/DistriChat/pkg/ring"
+	pb "github.com/sh4shv4t/DistriChat/proto"
+)
+
+// RingEpoch returns the epoch of the client's ring view
+func (c *SmartClient) RingEpoch() uint64 {
+	return c.ring.Epoch()
+}
+
+// RingState returns the client's current ring view
+func (c *SmartClient) RingState() ring.RingState {
+	return c.ring.State()
+}
+
+// SyncRing fetches the ring view held by the server at address and adopts it
+// if it is newer than the client's. Returns whether the view changed.
+func (c *SmartClient) SyncRing(address string) (bool, error) {
+	c.mu.RLock()
+	conn, exists := c.connections[address]
+	c.mu.RUnlock()
+
+	if !exists || conn.client == nil {
+		return false, fmt.Errorf("no connection to %s", address)
+	}
+
+	local := c.ring.State()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	resp, err := conn.client.GetRingState(ctx, &pb.RingStateRequest{
+		KnownEpoch:  local.Epoch,
+		KnownDigest: local.Digest(),
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to fetch ring state from %s: %w", address, err)
+	}
+	if resp.InSync {
+		return false, nil
+	}
+
+	return c.ApplyRingState(resp.State.ToRing()), nil
+}
+
+// FollowCoordinator adopts the coordinator's authoritative ring and keeps it
+// current by watching topology updates in the background until Close.
+// Servers no longer need to be added by hand once the client follows a coordinator.
+func (c *SmartClient) FollowCoordinator(address string) error {
+	conn, err := c.connectToServer(address)
+	if err != nil {
+		return err
+	}
+	coordinator := pb.NewCoordinatorServiceClient(conn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.config.RequestTimeout)
+	defer cancel()
+
+	resp, err := coordinator.GetRing(ctx, &pb.RingStateRequest{})
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to fetch ring from coordinator %s: %w", address, err)
+	}
+	c.ApplyRingState(resp.State.ToRing())
+
+	source := func(ctx context.Context, knownEpoch uint64) (topology.Stream, error) {
+		return coordinator.WatchTopology(ctx, &pb.WatchTopologyRequest{KnownEpoch: knownEpoch})
+	}
+
+	c.mu.Lock()
+	c.coordinatorConn = conn
+	c.watcher = topology.NewWatcher("CLIENT", source, c.ring.Epoch, c.ApplyRingState)
+	c.mu.Unlock()
+
+	c.watcher.Start()
+
+	c.log.Info("Following coordinator", "coordinator", address, logging.Epoch(c.ring.Epoch()))
+	return nil
+}
+
+// LocateChats asks the followed coordinator's chat directory where chats
+// live, instead of computing it from the client's own (possibly older) ring
+func (c *SmartClient) LocateChats(chatIDs ...string) (*pb.LocateChatsResponse, error) {
+	c.mu.RLock()
+	conn := c.coordinatorConn
+	c.mu.RUnlock()
+	if conn == nil {
+		return nil, fmt.Errorf("chat directory requires following a coordinator")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.config.RequestTimeout)
+	defer cancel()
+
+	return pb.NewCoordinatorServiceClient(conn).LocateChats(ctx, &pb.LocateChatsRequest{
+		ChatIds:   chatIDs,
+		Namespace: c.config.Namespace,
+	})
+}
+
+// FollowServers keeps the client's ring current by watching the topology
+// stream of any reachable server, so no coordinator is needed. The seeds are
+// tried first; after that the client reconnects through whichever servers
+// are in its ring, moving on to the next one whenever a stream breaks.
+func (c *SmartClient) FollowServers(seeds ...string) error {
+	if len(seeds) == 0 {
+		return fmt.Errorf("no servers to follow")
+	}
+
+	c.mu.Lock()
+	if c.watcher != nil {
+		c.mu.Unlock()
+		return fmt.Errorf("already following a topology source")
+	}
+	for _, seed := range seeds {
+		if _, exists := c.connections[seed]; !exists {
+			c.connections[seed] = newServerConnection(seed)
+		}
+	}
+	c.mu.Unlock()
+
+	if err := c.syncFromAny(seeds); err != nil {
+		return err
+	}
+
+	next := 0 // Only touched by the watcher goroutine
+	source := func(ctx context.Context, knownEpoch uint64) (topology.Stream, error) {
+		candidates := c.topologyCandidates(seeds)
+		var lastErr error
+		for range candidates {
+			address := candidates[next%len(candidates)]
+			next++
+
+			client, err := c.serverClient(address)
+			if err != nil {
+				lastErr = err
+				continue
+			}
+			stream, err := client.WatchTopology(ctx, &pb.WatchTopologyRequest{KnownEpoch: knownEpoch})
+			if err != nil {
+				lastErr = err
+				continue
+			}
+			return stream, nil
+		}
+		return nil, fmt.Errorf("no server reachable for topology: %w", lastErr)
+	}
+
+	c.mu.Lock()
+	c.watcher = topology.NewWatcher("CLIENT", source, c.ring.Epoch, c.ApplyRingState)
+	c.mu.Unlock()
+
+	c.watcher.Start()
+
+	c.log.Info("Following server topology", "seeds", seeds, logging.Epoch(c.ring.Epoch()))
+	return nil
+}
+
+// syncFromAny adopts the ring view of the first seed that answers
+func (c *SmartClient) syncFromAny(seeds []string) error {
+	var lastErr error
+	for _, seed := range seeds {
+		client, err := c.serverClient(seed)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), c.config.RequestTimeout)
+		resp, err := client.GetRingState(ctx, &pb.RingStateRequest{})
+		cancel()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		c.ApplyRingState(resp.State.ToRing())
+		return nil
+	}
+	return fmt.Errorf("failed to fetch ring from any of %v: %w", seeds, lastErr)
+}
+
+// topologyCandidates lists servers that can serve a topology stream: the
+// ring's members, then any seeds outside the ring
+func (c *SmartClient) topologyCandidates(seeds []string) []string {
+	seen := make(map[string]bool)
+	var candidates []string
+	for _, node := range c.ring.State().Nodes {
+		if !seen[node.Address] {
+			seen[node.Address] = true
+			candidates = append(candidates, node.Address)
+		}
+	}
+	for _, seed := range seeds {
+		if !seen[seed] {
+			seen[seed] = true
+			candidates = append(candidates, seed)
+		}
+	}
+	return candidates
+}
+
+// ApplyRingState replaces the client's ring view with a newer one, opening
+// lazy connections to new servers and closing those that left the ring.
+// Views that are not newer than the current one are ignored.
+func (c *SmartClient) ApplyRingState(state ring.RingState) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.ring.Replace(state) {
+		return false
+	}
+
+	wanted := make(map[string]bool, len(state.Nodes))
+	for _, node := range state.Nodes {
+		wanted[node.Address] = true
+		if _, exists := c.connections[node.Address]; !exists {
+			// Connected lazily on first use by sendToServer
+			c.connections[node.Address] = newServerConnection(node.Address)
+		}
+	}
+
+	for addr, conn := range c.connections {
+		if wanted[addr] {
+			continue
+		}
+		if conn.conn != nil {
+			conn.conn.Close()
+		}
+		delete(c.connections, addr)
+	}
+
+	c.log.Info("Adopted ring", logging.Epoch(state.Epoch), "servers", len(state.Nodes))
+	return true
+}
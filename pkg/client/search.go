@@ -0,0 +1,163 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/sh4shv4t/DistriChat/pkg/chaterr"
+	"github.com/sh4shv4t/DistriChat/pkg/logging"
+	pb "github.com/sh4shv4t/DistriChat/proto"
+)
+
+// SearchQuery is a full-text query over the cluster's messages
+type SearchQuery struct {
+	Text     string   // Words every match must contain
+	ChatIDs  []string // Chats to search (empty: every chat the caller may read)
+	SenderID string   // Only messages from this sender
+	Limit    int      // Best matches to return (0: the servers' default)
+}
+
+// SearchResult is a cluster-wide query's best matches and the servers that
+// answered it
+type SearchResult struct {
+	// Hits are the best matches, best first, each message once however
+	// many of its replicas found it
+	Hits []*pb.SearchHit
+
+	// Servers is how many servers answered
+	Servers int
+
+	// Failed holds the servers that didn't answer, by ID, and why. Their
+	// chats' messages are missing from Hits unless a replica answered.
+	Failed map[string]error
+}
+
+// Partial reports whether some server failed to answer
+func (r *SearchResult) Partial() bool {
+	return len(r.Failed) > 0
+}
+
+// Search runs a query on every server of the namespace at once, as each
+// only searches the chats it holds, and merges their matches by score.
+// Scores are relative to each server's own index, so the ranking across
+// servers is approximate. Servers that fail are reported in the result's
+// Failed; Search only fails if none answered.
+func (c *SmartClient) Search(q SearchQuery, opts ...CallOption) (*SearchResult, error) {
+	options := applyOptions(opts)
+	namespace := c.config.Namespace
+	if options.namespace != nil {
+		namespace = *options.namespace
+	}
+	placement := c.ring.Namespace(namespace)
+	nodes := placement.GetAllNodes()
+	if len(nodes) == 0 {
+		return nil, chaterr.ErrNoServers
+	}
+
+	req := &pb.SearchRequest{
+		Query:     q.Text,
+		ChatIds:   q.ChatIDs,
+		SenderId:  q.SenderID,
+		Limit:     int32(q.Limit),
+		Namespace: namespace,
+	}
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	result := &SearchResult{Failed: make(map[string]error)}
+	best := make(map[string]*pb.SearchHit)
+	for _, nodeID := range nodes {
+		address, _ := placement.GetNodeAddress(nodeID)
+		wg.Add(1)
+		go func(nodeID, address string) {
+			defer wg.Done()
+			resp, err := c.searchOn(ctx, nodeID, address, req)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				result.Failed[nodeID] = err
+				return
+			}
+			result.Servers++
+			for _, hit := range resp.Hits {
+				key := hit.ChatId + "\x00" + hit.MessageId
+				if hit.MessageId == "" {
+					key += "#" + strconv.FormatUint(hit.Seq, 10)
+				}
+				if prev, ok := best[key]; !ok || hit.Score > prev.Score {
+					best[key] = hit
+				}
+			}
+		}(nodeID, address)
+	}
+	wg.Wait()
+
+	if result.Servers == 0 {
+		return nil, searchFailure(result.Failed)
+	}
+	for _, hit := range best {
+		result.Hits = append(result.Hits, hit)
+	}
+	sort.Slice(result.Hits, func(i, j int) bool {
+		a, b := result.Hits[i], result.Hits[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if a.Timestamp != b.Timestamp {
+			return a.Timestamp > b.Timestamp
+		}
+		if a.ChatId != b.ChatId {
+			return a.ChatId < b.ChatId
+		}
+		return a.Seq > b.Seq
+	})
+	if q.Limit > 0 && len(result.Hits) > q.Limit {
+		result.Hits = result.Hits[:q.Limit]
+	}
+	if result.Partial() {
+		c.log.Warn("Search answered by some servers only", "answered", result.Servers, "failed", len(result.Failed))
+	}
+	return result, nil
+}
+
+// searchOn runs a query on one server
+func (c *SmartClient) searchOn(ctx context.Context, nodeID, address string, req *pb.SearchRequest) (*pb.SearchResponse, error) {
+	client, err := c.serverClient(address)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.config.RequestTimeout)
+	defer cancel()
+	resp, err := client.SearchMessages(ctx, req)
+	if err != nil {
+		c.log.WarnContext(ctx, "Failed to search", logging.NodeID(nodeID), logging.Err(err))
+		c.recordFailure(address)
+		return nil, err
+	}
+	c.recordSuccess(address)
+	if !resp.Success {
+		return nil, &chaterr.Rejection{ServerID: nodeID, Op: "search", Code: resp.ErrorCode, Details: resp.ErrorDetails}
+	}
+	return resp, nil
+}
+
+// searchFailure explains a search no server answered: a refusal every
+// server would repeat (a malformed query, say) if there is one, else the
+// servers' failures wrapped in ErrAllReplicasFailed
+func searchFailure(failed map[string]error) error {
+	var last error
+	for _, err := range failed {
+		var rejection *chaterr.Rejection
+		if errors.As(err, &rejection) && !shouldFailover(rejection.Code) {
+			return err
+		}
+		last = err
+	}
+	return fmt.Errorf("%w: %w", chaterr.ErrAllReplicasFailed, last)
+}
@@ -0,0 +1,250 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/pkg/chaterr"
+	"github.com/sh4shv4t/DistriChat/pkg/logging"
+	"github.com/sh4shv4t/DistriChat/pkg/ring"
+	pb "github.com/sh4shv4t/DistriChat/proto"
+)
+
+// subscriptionBuffer is how many messages a subscription holds for its
+// reader before it stops reading from the server
+const subscriptionBuffer = 256
+
+// resubscribeDelay spaces out the rounds of a subscription that keeps
+// breaking
+const resubscribeDelay = 100 * time.Millisecond
+
+// Subscription follows a chat's messages
+type Subscription struct {
+	messages chan *pb.StoredMessage
+	cancel   context.CancelFunc
+
+	// For acknowledging a durable subscription's messages, and the highest
+	// sequence acknowledged
+	client     *SmartClient
+	chatID     string
+	namespace  string
+	subscriber string
+	durable    bool
+	acked      atomic.Uint64
+
+	mu  sync.Mutex
+	err error
+}
+
+// Messages delivers the chat's messages. It is closed when the
+// subscription ends; Err then says why.
+func (s *Subscription) Messages() <-chan *pb.StoredMessage {
+	return s.messages
+}
+
+// Err returns why the subscription ended: nil if it was closed, a
+// *chaterr.Rejection (ErrPermissionDenied, say) for a refusal another
+// server wouldn't change, or ErrAllReplicasFailed once no replica would
+// take it, wrapping the last failure
+func (s *Subscription) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Close ends the subscription
+func (s *Subscription) Close() {
+	s.cancel()
+}
+
+// Ack tells the chat's replicas that every message up to seq was handled,
+// so a durable subscription resumed later, by this client or another
+// following the chat for the same user, starts after it. It fails over
+// like SendMessage, and its errors are SendMessage's.
+func (s *Subscription) Ack(seq uint64) error {
+	if !s.durable {
+		return errors.New("only durable subscriptions are acknowledged")
+	}
+	c := s.client
+	ctx := logging.With(context.Background(), logging.ChatID(s.chatID))
+	req := &pb.AckRequest{ChatId: s.chatID, Namespace: s.namespace, SubscriberId: s.subscriber, Seq: seq}
+
+	nodes := c.routeNodes(s.namespace, s.chatID)
+	if len(nodes) == 0 {
+		return chaterr.ErrNoServers
+	}
+	var lastErr error
+	for _, node := range nodes {
+		client, err := c.serverClient(node.Address)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		callCtx, cancel := context.WithTimeout(ctx, c.config.RequestTimeout)
+		resp, err := client.AckMessages(callCtx, req)
+		cancel()
+		if err != nil {
+			lastErr = err
+			c.log.WarnContext(ctx, "Failed to acknowledge messages", logging.NodeID(node.NodeID), logging.Err(err))
+			c.recordFailure(node.Address)
+			continue
+		}
+		c.recordSuccess(node.Address)
+		if resp.Success {
+			for acked := s.acked.Load(); resp.Seq > acked && !s.acked.CompareAndSwap(acked, resp.Seq); {
+				acked = s.acked.Load()
+			}
+			return nil
+		}
+
+		lastErr = &chaterr.Rejection{ServerID: node.NodeID, Op: "ack", Code: resp.ErrorCode, Details: resp.ErrorDetails}
+		if !shouldFailover(resp.ErrorCode) {
+			return lastErr
+		}
+	}
+	return fmt.Errorf("%w: %w", chaterr.ErrAllReplicasFailed, lastErr)
+}
+
+// Subscribe follows a chat's messages as its replicas store them, after
+// replaying those past afterSeq: 0 for the whole history, or the last
+// sequence the caller has seen. It subscribes on the
+// chat's owner, failing over to successors like SendMessage. A stream that
+// breaks, or that the server ends because the reader fell behind, is
+// resumed after the last message received, replaying what the reader
+// missed from the new server's copy. With WithDurable it resumes after the
+// last message acknowledged instead, as kept by the servers.
+func (c *SmartClient) Subscribe(chatID string, afterSeq uint64, opts ...CallOption) (*Subscription, error) {
+	options := applyOptions(opts)
+	namespace := c.config.Namespace
+	if options.namespace != nil {
+		namespace = *options.namespace
+	}
+	if len(c.routeNodes(namespace, chatID)) == 0 {
+		return nil, chaterr.ErrNoServers
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ctx = logging.With(ctx, logging.ChatID(chatID))
+	sub := &Subscription{
+		messages:   make(chan *pb.StoredMessage, subscriptionBuffer),
+		cancel:     cancel,
+		client:     c,
+		chatID:     chatID,
+		namespace:  namespace,
+		subscriber: options.subscriber,
+		durable:    options.durable,
+	}
+	go c.follow(ctx, sub, &pb.SubscribeRequest{
+		ChatId:       chatID,
+		Namespace:    namespace,
+		AfterSeq:     afterSeq,
+		SubscriberId: options.subscriber,
+		Durable:      options.durable,
+	})
+	return sub, nil
+}
+
+// follow keeps sub subscribed until it is closed or every replica refuses
+// it. Each round starts at the owner; a round in which no server delivered
+// anything ends the subscription.
+func (c *SmartClient) follow(ctx context.Context, sub *Subscription, req *pb.SubscribeRequest) {
+	defer close(sub.messages)
+	fail := func(err error) {
+		sub.mu.Lock()
+		sub.err = err
+		sub.mu.Unlock()
+	}
+
+	for {
+		var lastErr error
+		progressed := false
+		for _, node := range c.routeNodes(req.Namespace, req.ChatId) {
+			// A server that lost the cursor starts after the last ack seen
+			if acked := sub.acked.Load(); req.Durable && acked > req.AfterSeq {
+				req.AfterSeq = acked
+			}
+			delivered, err := c.followOn(ctx, node, req, sub.messages)
+			if ctx.Err() != nil {
+				return
+			}
+			lastErr = err
+			if !resubscribable(err) {
+				fail(err)
+				return
+			}
+			c.log.InfoContext(ctx, "Subscription ended, resubscribing", logging.NodeID(node.NodeID),
+				"after_seq", req.AfterSeq, logging.Err(err))
+			if delivered {
+				progressed = true
+				break
+			}
+		}
+		if !progressed {
+			fail(fmt.Errorf("%w: %w", chaterr.ErrAllReplicasFailed, lastErr))
+			return
+		}
+
+		select {
+		case <-time.After(resubscribeDelay):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// followOn streams the chat from one server into out until the stream
+// ends, advancing req.AfterSeq past every message delivered unless the
+// subscription is durable, leaving the server to resume it. It reports
+// whether anything was delivered, and the error that ended the stream.
+func (c *SmartClient) followOn(ctx context.Context, node ring.NodeInfo, req *pb.SubscribeRequest, out chan<- *pb.StoredMessage) (bool, error) {
+	client, err := c.serverClient(node.Address)
+	if err != nil {
+		return false, err
+	}
+	stream, err := client.Subscribe(ctx, req)
+	if err != nil {
+		c.recordFailure(node.Address)
+		return false, err
+	}
+
+	delivered := false
+	for {
+		resp, err := stream.Recv()
+		if err != nil {
+			if ctx.Err() == nil {
+				c.recordFailure(node.Address)
+			}
+			return delivered, err
+		}
+		if resp.ErrorCode != pb.ErrorCode_ERROR_NONE {
+			return delivered, &chaterr.Rejection{ServerID: node.NodeID, Op: "subscription",
+				Code: resp.ErrorCode, Details: resp.ErrorDetails}
+		}
+
+		select {
+		case out <- resp.Message:
+		case <-ctx.Done():
+			return delivered, ctx.Err()
+		}
+		if !req.Durable {
+			req.AfterSeq = resp.Message.Seq
+		}
+		delivered = true
+	}
+}
+
+// resubscribable reports whether a subscription that ended with err may be
+// resumed on the same or another server: any failure but a refusal another
+// server would repeat
+func resubscribable(err error) bool {
+	var rejection *chaterr.Rejection
+	if !errors.As(err, &rejection) {
+		return true
+	}
+	return shouldFailover(rejection.Code) || rejection.Code == pb.ErrorCode_ERROR_SLOW_CONSUMER
+}
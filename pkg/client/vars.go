@@ -0,0 +1,25 @@
+package client
+
+import "expvar"
+
+// Vars returns the client's live values (ring epoch and size, calls in
+// flight, request and failover counts) as an expvar variable. Publish it
+// to serve it on /debug/vars:
+//
+//	expvar.Publish("client", c.Vars())
+func (c *SmartClient) Vars() expvar.Var {
+	return expvar.Func(func() any {
+		stats := c.GetStats()
+		return map[string]any{
+			"namespace":        c.config.Namespace,
+			"ring_epoch":       c.ring.Epoch(),
+			"ring_nodes":       len(c.ring.GetAllNodes()),
+			"in_flight":        c.inFlight.Load(),
+			"total_requests":   stats.TotalRequests,
+			"success_requests": stats.SuccessRequests,
+			"failed_requests":  stats.FailedRequests,
+			"primary_hits":     stats.PrimaryHits,
+			"failover_count":   stats.FailoverCount,
+		}
+	})
+}
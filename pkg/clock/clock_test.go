@@ -0,0 +1,130 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+// fixedHLC returns a clock whose wall time is read from *wall
+func fixedHLC(wall *int64) *HLC {
+	return &HLC{now: func() int64 { return *wall }}
+}
+
+func TestHLCMonotonic(t *testing.T) {
+	wall := int64(100)
+	c := fixedHLC(&wall)
+
+	first := c.Now()
+	second := c.Now()
+	if !first.Less(second) {
+		t.Errorf("Expected %s before %s with a stalled wall clock", first, second)
+	}
+
+	// Wall time going backwards must not move the clock backwards
+	wall = 50
+	third := c.Now()
+	if !second.Less(third) {
+		t.Errorf("Expected %s before %s after the wall clock stepped back", second, third)
+	}
+}
+
+func TestHLCUpdate(t *testing.T) {
+	wall := int64(100)
+	c := fixedHLC(&wall)
+
+	remote := Timestamp{WallTime: 500, Logical: 3}
+	got := c.Update(remote)
+	if !remote.Less(got) {
+		t.Errorf("Expected %s after remote %s", got, remote)
+	}
+
+	next := c.Now()
+	if !got.Less(next) {
+		t.Errorf("Expected local reading %s after %s", next, got)
+	}
+
+	wall = 1000
+	if got := c.Update(remote); got != (Timestamp{WallTime: 1000}) {
+		t.Errorf("Expected wall time to win once it passes both clocks, got %s", got)
+	}
+}
+
+func TestVirtualClock(t *testing.T) {
+	start := time.Unix(1000, 0)
+	v := NewVirtual(start)
+
+	var fired []string
+	v.AfterFunc(2*time.Second, func() { fired = append(fired, "b") })
+	v.AfterFunc(time.Second, func() { fired = append(fired, "a") })
+	stop := v.AfterFunc(time.Second, func() { fired = append(fired, "stopped") })
+	v.AfterFunc(3*time.Second, func() {
+		fired = append(fired, "c")
+		if got := v.Now(); !got.Equal(start.Add(3 * time.Second)) {
+			t.Errorf("Expected the clock at its due time, got %v", got)
+		}
+		// Scheduled from a callback, still due within this Advance
+		v.AfterFunc(time.Second, func() { fired = append(fired, "d") })
+	})
+	if !stop() {
+		t.Errorf("Expected stop to cancel a pending call")
+	}
+
+	v.Advance(1500 * time.Millisecond)
+	if len(fired) != 1 || fired[0] != "a" {
+		t.Errorf("Expected [a] after 1.5s, got %v", fired)
+	}
+
+	v.Sleep(5 * time.Second)
+	if got := v.Now(); !got.Equal(start.Add(6500 * time.Millisecond)) {
+		t.Errorf("Expected 6.5s after start, got %v", got)
+	}
+	want := []string{"a", "b", "c", "d"}
+	if len(fired) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, fired)
+	}
+	for i := range want {
+		if fired[i] != want[i] {
+			t.Errorf("Expected %v, got %v", want, fired)
+			break
+		}
+	}
+
+	hlc := NewHLCFrom(v)
+	if got := hlc.Now(); got.WallTime != v.Now().UnixNano() {
+		t.Errorf("Expected the HLC to read the virtual clock, got %s", got)
+	}
+}
+
+func TestVersionVectorCompare(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b VersionVector
+		want Ordering
+	}{
+		{"equal", VersionVector{"a": 1, "b": 2}, VersionVector{"a": 1, "b": 2}, Equal},
+		{"empty equal", VersionVector{}, VersionVector{"a": 0}, Equal},
+		{"before", VersionVector{"a": 1}, VersionVector{"a": 1, "b": 1}, Before},
+		{"after", VersionVector{"a": 2, "b": 1}, VersionVector{"a": 1, "b": 1}, After},
+		{"concurrent", VersionVector{"a": 2}, VersionVector{"a": 1, "b": 1}, Concurrent},
+	}
+
+	for _, tt := range tests {
+		if got := tt.a.Compare(tt.b); got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestVersionVectorMerge(t *testing.T) {
+	a := VersionVector{"a": 3, "b": 1}
+	b := VersionVector{"b": 4, "c": 2}
+
+	a.Merge(b)
+	want := VersionVector{"a": 3, "b": 4, "c": 2}
+	if a.Compare(want) != Equal {
+		t.Errorf("Expected %s, got %s", want, a)
+	}
+	if !a.Contains("c", 2) || a.Contains("c", 3) {
+		t.Errorf("Expected %s to contain c:2 but not c:3", a)
+	}
+}
@@ -0,0 +1,112 @@
+package clock
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Ordering is the causal relationship between two version vectors
+type Ordering int
+
+const (
+	Equal      Ordering = iota // Both have seen exactly the same updates
+	Before                     // The first has seen a subset of the second's updates
+	After                      // The first has seen a superset of the second's updates
+	Concurrent                 // Each has seen updates the other hasn't
+)
+
+func (o Ordering) String() string {
+	switch o {
+	case Equal:
+		return "EQUAL"
+	case Before:
+		return "BEFORE"
+	case After:
+		return "AFTER"
+	case Concurrent:
+		return "CONCURRENT"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// VersionVector counts, per origin node, how many updates have been seen.
+// Each update is identified by its dot: the origin and that origin's counter.
+type VersionVector map[string]uint64
+
+// Increment records a new local update by origin and returns its counter
+func (v VersionVector) Increment(origin string) uint64 {
+	v[origin]++
+	return v[origin]
+}
+
+// Observe records that the update (origin, counter) has been seen
+func (v VersionVector) Observe(origin string, counter uint64) {
+	if counter > v[origin] {
+		v[origin] = counter
+	}
+}
+
+// Contains reports whether the update (origin, counter) is covered by v
+func (v VersionVector) Contains(origin string, counter uint64) bool {
+	return counter <= v[origin]
+}
+
+// Merge raises every entry of v to at least the corresponding entry of other
+func (v VersionVector) Merge(other VersionVector) {
+	for origin, counter := range other {
+		v.Observe(origin, counter)
+	}
+}
+
+// Copy returns an independent copy of v
+func (v VersionVector) Copy() VersionVector {
+	out := make(VersionVector, len(v))
+	for origin, counter := range v {
+		out[origin] = counter
+	}
+	return out
+}
+
+// Compare returns how v relates causally to other
+func (v VersionVector) Compare(other VersionVector) Ordering {
+	less, greater := false, false
+	for origin, counter := range v {
+		if counter > other[origin] {
+			greater = true
+		} else if counter < other[origin] {
+			less = true
+		}
+	}
+	for origin, counter := range other {
+		if _, ok := v[origin]; !ok && counter > 0 {
+			less = true
+		}
+	}
+
+	switch {
+	case less && greater:
+		return Concurrent
+	case less:
+		return Before
+	case greater:
+		return After
+	default:
+		return Equal
+	}
+}
+
+func (v VersionVector) String() string {
+	origins := make([]string, 0, len(v))
+	for origin := range v {
+		origins = append(origins, origin)
+	}
+	sort.Strings(origins)
+
+	parts := make([]string, 0, len(origins))
+	for _, origin := range origins {
+		parts = append(parts, fmt.Sprintf("%s:%d", origin, v[origin]))
+	}
+	return "{" + strings.Join(parts, ", ") + "}"
+}
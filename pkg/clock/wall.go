@@ -0,0 +1,134 @@
+package clock
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock reads and waits on physical time. System is the real clock;
+// a Virtual clock only moves when advanced, so a simulation driven by one
+// sees the same times on every run.
+type Clock interface {
+	Now() time.Time
+	Sleep(d time.Duration)
+
+	// AfterFunc calls f once d has passed; stop cancels the call,
+	// reporting whether it was still pending
+	AfterFunc(d time.Duration, f func()) (stop func() bool)
+}
+
+// System returns the real clock
+func System() Clock {
+	return systemClock{}
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time        { return time.Now() }
+func (systemClock) Sleep(d time.Duration) { time.Sleep(d) }
+
+func (systemClock) AfterFunc(d time.Duration, f func()) func() bool {
+	return time.AfterFunc(d, f).Stop
+}
+
+// Virtual is a clock that stands still until advanced. Functions waiting
+// on it run inside Advance, in the goroutine advancing it, in order of
+// their due times (then of registration), so the order of events is fixed.
+type Virtual struct {
+	mu     sync.Mutex
+	now    time.Time
+	seq    uint64
+	timers []*virtualTimer
+}
+
+type virtualTimer struct {
+	at      time.Time
+	seq     uint64
+	f       func()
+	stopped bool
+}
+
+// NewVirtual creates a virtual clock reading start
+func NewVirtual(start time.Time) *Virtual {
+	return &Virtual{now: start}
+}
+
+// Now returns the clock's current time
+func (v *Virtual) Now() time.Time {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.now
+}
+
+// Sleep advances the clock by d: the caller is the one driving time
+func (v *Virtual) Sleep(d time.Duration) {
+	v.Advance(d)
+}
+
+// AfterFunc schedules f for when the clock has been advanced by d
+func (v *Virtual) AfterFunc(d time.Duration, f func()) func() bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.seq++
+	t := &virtualTimer{at: v.now.Add(d), seq: v.seq, f: f}
+	v.timers = append(v.timers, t)
+	return func() bool {
+		v.mu.Lock()
+		defer v.mu.Unlock()
+		if t.stopped {
+			return false
+		}
+		t.stopped = true
+		return true
+	}
+}
+
+// Advance moves the clock forward by d, running every function due by
+// then with the clock reading its due time
+func (v *Virtual) Advance(d time.Duration) {
+	v.mu.Lock()
+	target := v.now.Add(d)
+	for {
+		t := v.next(target)
+		if t == nil {
+			break
+		}
+		t.stopped = true
+		v.now = t.at
+		v.mu.Unlock()
+		t.f()
+		v.mu.Lock()
+	}
+	if target.After(v.now) {
+		v.now = target
+	}
+	v.mu.Unlock()
+}
+
+// next removes and returns the earliest pending timer due by target, or
+// nil (must be called with lock held)
+func (v *Virtual) next(target time.Time) *virtualTimer {
+	pending := v.timers[:0]
+	for _, t := range v.timers {
+		if !t.stopped {
+			pending = append(pending, t)
+		}
+	}
+	v.timers = pending
+	if len(pending) == 0 {
+		return nil
+	}
+
+	sort.Slice(pending, func(i, j int) bool {
+		if !pending[i].at.Equal(pending[j].at) {
+			return pending[i].at.Before(pending[j].at)
+		}
+		return pending[i].seq < pending[j].seq
+	})
+	if pending[0].at.After(target) {
+		return nil
+	}
+	return pending[0]
+}
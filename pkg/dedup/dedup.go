@@ -0,0 +1,98 @@
+// Package dedup remembers the IDs of the messages a server stored, for a
+// window of time, independently of the cache. A client retrying a write,
+// after a timeout, a failover or a server restart, is then told its message
+// is already stored even if the message has since left the cache, been
+// archived or been deleted, so every message ID is appended to its chat at
+// most once.
+package dedup
+
+import (
+	"sync"
+	"time"
+)
+
+// Entry records one stored message
+type Entry struct {
+	ChatID    string    `json:"chat_id"`
+	MessageID string    `json:"message_id"`
+	Seq       uint64    `json:"seq"`    // The message's sequence in the chat
+	Stored    time.Time `json:"stored"` // When it was stored; the window counts from here
+}
+
+// Store keeps the entries of the messages stored within the window
+type Store interface {
+	// Get returns the entry for a chat's message ID, or false if the
+	// message wasn't stored or its entry expired
+	Get(chatID, messageID string) (Entry, bool, error)
+
+	// Put records a stored message, replacing any entry for its ID
+	Put(entry Entry) error
+
+	// Expire drops the entries stored before cutoff, returning how many
+	Expire(cutoff time.Time) (int, error)
+
+	// Len returns how many entries are held
+	Len() int
+}
+
+// key identifies an entry
+type key struct {
+	chatID    string
+	messageID string
+}
+
+// MemoryStore is a Store in process memory: it outlives cache evictions
+// and deletions but not a restart
+type MemoryStore struct {
+	mu      sync.RWMutex
+	entries map[key]Entry
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[key]Entry)}
+}
+
+func (s *MemoryStore) Get(chatID, messageID string) (Entry, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	entry, ok := s.entries[key{chatID, messageID}]
+	return entry, ok, nil
+}
+
+func (s *MemoryStore) Put(entry Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[key{entry.ChatID, entry.MessageID}] = entry
+	return nil
+}
+
+func (s *MemoryStore) Expire(cutoff time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	expired := 0
+	for k, entry := range s.entries {
+		if entry.Stored.Before(cutoff) {
+			delete(s.entries, k)
+			expired++
+		}
+	}
+	return expired, nil
+}
+
+func (s *MemoryStore) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.entries)
+}
+
+// all returns every entry held
+func (s *MemoryStore) all() []Entry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	entries := make([]Entry, 0, len(s.entries))
+	for _, entry := range s.entries {
+		entries = append(entries, entry)
+	}
+	return entries
+}
@@ -0,0 +1,76 @@
+package dedup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMemoryStore(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := NewMemoryStore()
+	s.Put(Entry{ChatID: "chat-1", MessageID: "m-1", Seq: 1, Stored: start})
+	s.Put(Entry{ChatID: "chat-1", MessageID: "m-2", Seq: 2, Stored: start.Add(time.Hour)})
+
+	if entry, ok, _ := s.Get("chat-1", "m-1"); !ok || entry.Seq != 1 {
+		t.Errorf("Expected m-1 at seq 1, got %+v (%v)", entry, ok)
+	}
+	if _, ok, _ := s.Get("chat-2", "m-1"); ok {
+		t.Error("Expected message IDs scoped to their chat")
+	}
+	if n, _ := s.Expire(start.Add(time.Minute)); n != 1 || s.Len() != 1 {
+		t.Errorf("Expected 1 entry expired and 1 left, got %d and %d", n, s.Len())
+	}
+	if _, ok, _ := s.Get("chat-1", "m-1"); ok {
+		t.Error("Expected the expired entry forgotten")
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	path := filepath.Join(t.TempDir(), "dedup.log")
+	s, err := OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, id := range []string{"m-1", "m-2", "m-3"} {
+		if err := s.Put(Entry{ChatID: "chat-1", MessageID: id, Seq: uint64(i + 1), Stored: start.Add(time.Duration(i) * time.Hour)}); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+	if n, err := s.Expire(start.Add(time.Minute)); n != 1 || err != nil {
+		t.Errorf("Expected 1 entry expired, got %d (%v)", n, err)
+	}
+	s.Close()
+
+	// A crash mid-write leaves a torn last entry, which is dropped
+	file, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	file.WriteString(`{"chat_id":"chat-1","message_id":"m-4"`)
+	file.Close()
+
+	s, err = OpenFile(path)
+	if err != nil {
+		t.Fatalf("Reopening failed: %v", err)
+	}
+	defer s.Close()
+	if s.Len() != 2 {
+		t.Errorf("Expected 2 entries after reopening, got %d", s.Len())
+	}
+	if entry, ok, _ := s.Get("chat-1", "m-3"); !ok || entry.Seq != 3 {
+		t.Errorf("Expected m-3 kept across restarts, got %+v (%v)", entry, ok)
+	}
+	if _, ok, _ := s.Get("chat-1", "m-1"); ok {
+		t.Error("Expected the expired entry gone after reopening")
+	}
+	info, _ := os.Stat(path)
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("Expected the file readable only by its owner, got %v", info.Mode().Perm())
+	}
+
+	// Corruption before the last line is an error, not silently dropped
+	os.WriteFile(path, []byte("garbage\n{}\n"), 0o600)
+	if _, err := OpenFile(path); err == nil {
+		t.Error("Expected a corrupt store refused")
+	}
+}
@@ -0,0 +1,154 @@
+package dedup
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// FileStore is a Store that survives restarts: each entry is appended to a
+// file readable only by its owner, and synced before Put returns, so a
+// message acknowledged before a crash is still known after it. Expire
+// rewrites the file with the entries left, through a temporary file renamed
+// over the old one, so a crash leaves either version. The file belongs to
+// one server.
+type FileStore struct {
+	path string
+
+	mu      sync.Mutex
+	file    *os.File
+	entries *MemoryStore
+}
+
+// OpenFile opens the store in path, creating it if it doesn't exist.
+// A last entry cut short by a crash is dropped.
+func OpenFile(path string) (*FileStore, error) {
+	f := &FileStore{path: path, entries: NewMemoryStore()}
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("dedup: %w", err)
+	}
+
+	// Every entry ends with a newline, so a crash mid-write leaves the
+	// last line unterminated
+	lines := bytes.Split(data, []byte("\n"))
+	for i, line := range lines[:len(lines)-1] {
+		if len(line) == 0 {
+			continue
+		}
+		var entry Entry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return nil, fmt.Errorf("dedup: %s line %d: %w", path, i+1, err)
+		}
+		f.entries.Put(entry)
+	}
+
+	// Rewriting drops a torn entry and superseded ones
+	if err := f.rewrite(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *FileStore) Get(chatID, messageID string) (Entry, bool, error) {
+	return f.entries.Get(chatID, messageID)
+}
+
+func (f *FileStore) Put(entry Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return fmt.Errorf("dedup: %s is closed", f.path)
+	}
+	if _, err := f.file.Write(data); err != nil {
+		return fmt.Errorf("dedup: %w", err)
+	}
+	if err := f.file.Sync(); err != nil {
+		return fmt.Errorf("dedup: %w", err)
+	}
+	f.entries.Put(entry)
+	return nil
+}
+
+func (f *FileStore) Expire(cutoff time.Time) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	expired, _ := f.entries.Expire(cutoff)
+	if expired == 0 {
+		return 0, nil
+	}
+	return expired, f.rewrite()
+}
+
+func (f *FileStore) Len() int {
+	return f.entries.Len()
+}
+
+// Close closes the file; the store takes no more entries
+func (f *FileStore) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+// rewrite replaces the file with the entries held, oldest first, and
+// reopens it for appending; f.mu must be held, or f not yet shared
+func (f *FileStore) rewrite() error {
+	entries := f.entries.all()
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Stored.Before(entries[j].Stored) })
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, entry := range entries {
+		if err := enc.Encode(entry); err != nil {
+			return err
+		}
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("dedup: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return fmt.Errorf("dedup: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("dedup: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("dedup: %w", err)
+	}
+
+	if f.file != nil {
+		f.file.Close()
+		f.file = nil
+	}
+	if err := os.Rename(tmp.Name(), f.path); err != nil {
+		return fmt.Errorf("dedup: %w", err)
+	}
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("dedup: %w", err)
+	}
+	f.file = file
+	return nil
+}
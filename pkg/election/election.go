@@ -0,0 +1,182 @@
+// Package election runs singleton duties - work such as rebalancing that
+// must happen on exactly one node - on whichever node currently leads the
+// consensus layer. Leadership itself comes from a Source (normally the Raft
+// metadata store), so there is no second election to disagree with the
+// first. Duties start when this node gains leadership and are cancelled,
+// and waited for, as soon as it loses it.
+package election
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/pkg/logging"
+)
+
+// Source reports leadership from the underlying consensus layer.
+// *metadata.Store implements it.
+type Source interface {
+	IsLeader() bool
+
+	// LeadershipChanges delivers the new leadership flag after every
+	// transition. Repeated values are tolerated.
+	LeadershipChanges() <-chan bool
+}
+
+// Duty is singleton work. It runs while this node leads and must return
+// promptly once ctx is cancelled.
+type Duty func(ctx context.Context)
+
+// Event describes a leadership transition
+type Event struct {
+	Leader bool      // Whether this node now leads
+	Term   uint64    // Number of transitions seen so far
+	At     time.Time // When the transition was observed
+}
+
+// Election tracks this node's leadership and runs duties while it leads
+type Election struct {
+	name   string
+	source Source
+	log    *slog.Logger
+
+	mu        sync.Mutex
+	leader    bool
+	term      uint64
+	duties    map[string]Duty
+	running   map[string]context.CancelFunc
+	observers []func(Event)
+
+	wg         sync.WaitGroup // Running duties
+	shutdownCh chan struct{}
+	done       chan struct{}
+	stopOnce   sync.Once
+}
+
+// New creates an election following source. Call Start to begin.
+func New(name string, source Source) *Election {
+	return &Election{
+		name:       name,
+		source:     source,
+		log:        logging.Logger("election").With("election", name),
+		duties:     make(map[string]Duty),
+		running:    make(map[string]context.CancelFunc),
+		shutdownCh: make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+}
+
+// Run registers a duty under name. If this node already leads, the duty
+// starts immediately.
+func (e *Election) Run(name string, duty Duty) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.duties[name] = duty
+	if e.leader {
+		e.startDuty(name, duty)
+	}
+}
+
+// Observe registers fn to be called after every leadership transition.
+// Callbacks run on the election goroutine and must not block.
+func (e *Election) Observe(fn func(Event)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.observers = append(e.observers, fn)
+}
+
+// IsLeader reports whether this node currently runs the duties
+func (e *Election) IsLeader() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.leader
+}
+
+// Start begins following leadership changes
+func (e *Election) Start() {
+	go e.loop()
+}
+
+// Stop cancels running duties, waits for them to return and stops following
+// leadership
+func (e *Election) Stop() {
+	e.stopOnce.Do(func() {
+		close(e.shutdownCh)
+		<-e.done
+	})
+}
+
+// loop applies leadership transitions until Stop
+func (e *Election) loop() {
+	defer close(e.done)
+	defer e.set(false)
+
+	e.set(e.source.IsLeader())
+
+	changes := e.source.LeadershipChanges()
+	for {
+		select {
+		case leader := <-changes:
+			e.set(leader)
+		case <-e.shutdownCh:
+			return
+		}
+	}
+}
+
+// set moves to the given leadership state, starting or stopping duties.
+// Duties are fully stopped before set returns, so this node never overlaps
+// with the next leader's duties longer than the consensus layer does.
+func (e *Election) set(leader bool) {
+	e.mu.Lock()
+	if e.leader == leader {
+		e.mu.Unlock()
+		return
+	}
+	e.leader = leader
+	e.term++
+	event := Event{Leader: leader, Term: e.term, At: time.Now()}
+	duties := len(e.duties)
+
+	if leader {
+		for name, duty := range e.duties {
+			e.startDuty(name, duty)
+		}
+	} else {
+		for name, cancel := range e.running {
+			cancel()
+			delete(e.running, name)
+		}
+	}
+	observers := append([]func(Event){}, e.observers...)
+	e.mu.Unlock()
+
+	if leader {
+		e.log.Info("Gained leadership", "duties", duties)
+	} else {
+		e.wg.Wait()
+		e.log.Info("Lost leadership, duties stopped")
+	}
+
+	for _, fn := range observers {
+		fn(event)
+	}
+}
+
+// startDuty runs a duty until leadership is lost (must be called with lock held)
+func (e *Election) startDuty(name string, duty Duty) {
+	if cancel, ok := e.running[name]; ok {
+		cancel()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	e.running[name] = cancel
+
+	e.wg.Add(1)
+	go func() {
+		defer e.wg.Done()
+		duty(ctx)
+	}()
+}
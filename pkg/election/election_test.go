@@ -0,0 +1,109 @@
+package election
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeSource is a leadership source driven by the test
+type fakeSource struct {
+	leader  atomic.Bool
+	changes chan bool
+}
+
+func newFakeSource(leader bool) *fakeSource {
+	s := &fakeSource{changes: make(chan bool, 1)}
+	s.leader.Store(leader)
+	return s
+}
+
+func (s *fakeSource) IsLeader() bool                 { return s.leader.Load() }
+func (s *fakeSource) LeadershipChanges() <-chan bool { return s.changes }
+
+func (s *fakeSource) set(leader bool) {
+	s.leader.Store(leader)
+	s.changes <- leader
+}
+
+// waitFor polls cond until it holds or the timeout passes
+func waitFor(cond func() bool, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return true
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return false
+}
+
+func TestDutiesFollowLeadership(t *testing.T) {
+	source := newFakeSource(false)
+	e := New("test", source)
+
+	var running atomic.Int32
+	var starts atomic.Int32
+	e.Run("duty", func(ctx context.Context) {
+		starts.Add(1)
+		running.Add(1)
+		<-ctx.Done()
+		running.Add(-1)
+	})
+
+	events := make(chan Event, 10)
+	e.Observe(func(event Event) { events <- event })
+
+	e.Start()
+	defer e.Stop()
+
+	time.Sleep(20 * time.Millisecond)
+	if running.Load() != 0 {
+		t.Error("Expected duty not to run on a follower")
+	}
+
+	source.set(true)
+	if !waitFor(func() bool { return running.Load() == 1 }, time.Second) {
+		t.Fatal("Expected duty to start after gaining leadership")
+	}
+	if event := <-events; !event.Leader || event.Term != 1 {
+		t.Errorf("Expected leadership gained in term 1, got %+v", event)
+	}
+
+	source.set(true) // Repeated notification
+	source.set(false)
+	if event := <-events; event.Leader {
+		t.Errorf("Expected leadership lost, got %+v", event)
+	}
+	// Duties are stopped before observers are told
+	if running.Load() != 0 {
+		t.Error("Expected duty to stop after losing leadership")
+	}
+	if starts.Load() != 1 {
+		t.Errorf("Expected duty to start once, got %d", starts.Load())
+	}
+}
+
+func TestRunWhileLeader(t *testing.T) {
+	source := newFakeSource(true)
+	e := New("test", source)
+	e.Start()
+
+	if !waitFor(e.IsLeader, time.Second) {
+		t.Fatal("Expected initial leadership to be picked up")
+	}
+
+	stopped := make(chan struct{})
+	e.Run("late", func(ctx context.Context) {
+		<-ctx.Done()
+		close(stopped)
+	})
+
+	e.Stop()
+	select {
+	case <-stopped:
+	default:
+		t.Error("Expected Stop to cancel and wait for duties")
+	}
+}
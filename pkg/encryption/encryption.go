@@ -0,0 +1,266 @@
+// Package encryption encrypts data at rest with envelope encryption: each
+// piece of data is sealed with AES-256-GCM under a data key, and the data
+// key is stored beside it wrapped by a KMS, which holds the key encryption
+// keys and never hands them out. A KMS can be a cloud key service behind
+// the KMS interface, or a Keyring of local keys.
+//
+//	keyring, err := encryption.NewKeyring("2024-06", map[string][]byte{"2024-06": key})
+//	if err != nil {
+//		return err
+//	}
+//	archive.Open(ctx, store, archive.Config{Encrypter: encryption.New(keyring)})
+//
+// Rotating a key encryption key means making a new one current and keeping
+// the old ones for unwrapping: data sealed before the rotation stays
+// readable, and data written after it is sealed under the new key.
+package encryption
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	// DataKeySize is the length of data keys and Keyring keys (AES-256)
+	DataKeySize = 32
+
+	// DefaultDataKeyLifetime is how long an Encrypter seals with one data
+	// key before asking the KMS for a new one
+	DefaultDataKeyLifetime = time.Hour
+
+	// Unwrapped data keys kept so reads don't call the KMS every time
+	maxCachedKeys = 1024
+)
+
+// magic starts every sealed value, naming the format's version
+var magic = []byte("DCE1")
+
+var (
+	// ErrNotEncrypted is returned when opening data that wasn't sealed
+	ErrNotEncrypted = errors.New("encryption: data is not encrypted")
+
+	// ErrUnknownKey is returned when data was sealed under a key the KMS
+	// doesn't have
+	ErrUnknownKey = errors.New("encryption: unknown key")
+
+	// ErrDecrypt is returned when sealed data was modified, or opened with
+	// other associated data than it was sealed with
+	ErrDecrypt = errors.New("encryption: data failed authentication")
+)
+
+// KMS wraps the data keys data is sealed with under key encryption keys it
+// holds, e.g. a cloud KMS or an HSM
+type KMS interface {
+	// WrapKey encrypts a data key under the current key encryption key,
+	// returning that key's ID and the wrapped data key
+	WrapKey(ctx context.Context, dataKey []byte) (keyID string, wrapped []byte, err error)
+
+	// UnwrapKey decrypts a data key wrapped under the key called keyID,
+	// returning ErrUnknownKey if there is no such key
+	UnwrapKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error)
+}
+
+// Option adjusts an Encrypter as New creates it
+type Option func(*Encrypter)
+
+// WithDataKeyLifetime sets how long one data key seals data before a new
+// one is generated and wrapped (default: an hour)
+func WithDataKeyLifetime(d time.Duration) Option {
+	return func(e *Encrypter) { e.lifetime = d }
+}
+
+// Encrypter seals and opens data, getting its data keys wrapped and
+// unwrapped by a KMS. It is safe for concurrent use.
+type Encrypter struct {
+	kms      KMS
+	lifetime time.Duration
+
+	mu      sync.Mutex
+	current *dataKey            // Key new data is sealed with
+	opened  map[string]*dataKey // Unwrapped keys, by header
+}
+
+// dataKey is a data key, its cipher, and the header naming it in sealed data
+type dataKey struct {
+	aead    cipher.AEAD
+	header  []byte
+	created time.Time
+}
+
+// New creates an encrypter whose data keys are wrapped by kms
+func New(kms KMS, opts ...Option) *Encrypter {
+	e := &Encrypter{kms: kms, lifetime: DefaultDataKeyLifetime, opened: make(map[string]*dataKey)}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// Seal encrypts plaintext. The associated data (e.g. the object's name)
+// isn't stored, but Open must be given the same, so sealed data moved to
+// another name doesn't open.
+func (e *Encrypter) Seal(ctx context.Context, plaintext, associated []byte) ([]byte, error) {
+	key, err := e.currentKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, key.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("encryption: %w", err)
+	}
+	sealed := make([]byte, 0, len(key.header)+len(nonce)+len(plaintext)+key.aead.Overhead())
+	sealed = append(sealed, key.header...)
+	sealed = append(sealed, nonce...)
+	return key.aead.Seal(sealed, nonce, plaintext, additionalData(key.header, associated)), nil
+}
+
+// Open decrypts data sealed by Seal with the same associated data
+func (e *Encrypter) Open(ctx context.Context, sealed, associated []byte) ([]byte, error) {
+	header, keyID, wrapped, err := parseHeader(sealed)
+	if err != nil {
+		return nil, err
+	}
+	key, err := e.openKey(ctx, header, keyID, wrapped)
+	if err != nil {
+		return nil, err
+	}
+
+	rest := sealed[len(header):]
+	if len(rest) < key.aead.NonceSize() {
+		return nil, ErrDecrypt
+	}
+	nonce, ciphertext := rest[:key.aead.NonceSize()], rest[key.aead.NonceSize():]
+	plaintext, err := key.aead.Open(nil, nonce, ciphertext, additionalData(header, associated))
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
+
+// IsEncrypted reports whether data starts like sealed data
+func IsEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, magic)
+}
+
+// currentKey returns the data key to seal with, generating and wrapping a
+// new one when the current one has expired
+func (e *Encrypter) currentKey(ctx context.Context) (*dataKey, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.current != nil && time.Since(e.current.created) < e.lifetime {
+		return e.current, nil
+	}
+
+	raw := make([]byte, DataKeySize)
+	if _, err := io.ReadFull(rand.Reader, raw); err != nil {
+		return nil, fmt.Errorf("encryption: %w", err)
+	}
+	keyID, wrapped, err := e.kms.WrapKey(ctx, raw)
+	if err != nil {
+		return nil, fmt.Errorf("encryption: failed to wrap data key: %w", err)
+	}
+	if len(keyID) > 255 || len(wrapped) > 65535 {
+		return nil, fmt.Errorf("encryption: key ID or wrapped key too long")
+	}
+	aead, err := newAEAD(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 0, len(magic)+1+len(keyID)+2+len(wrapped))
+	header = append(header, magic...)
+	header = append(header, byte(len(keyID)))
+	header = append(header, keyID...)
+	header = binary.BigEndian.AppendUint16(header, uint16(len(wrapped)))
+	header = append(header, wrapped...)
+
+	e.current = &dataKey{aead: aead, header: header, created: time.Now()}
+	e.cache(e.current)
+	return e.current, nil
+}
+
+// openKey returns the data key named by header, unwrapping it with the
+// KMS the first time it is seen
+func (e *Encrypter) openKey(ctx context.Context, header []byte, keyID string, wrapped []byte) (*dataKey, error) {
+	e.mu.Lock()
+	key, ok := e.opened[string(header)]
+	e.mu.Unlock()
+	if ok {
+		return key, nil
+	}
+
+	raw, err := e.kms.UnwrapKey(ctx, keyID, wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("encryption: failed to unwrap data key: %w", err)
+	}
+	aead, err := newAEAD(raw)
+	if err != nil {
+		return nil, err
+	}
+	key = &dataKey{aead: aead, header: append([]byte(nil), header...)}
+
+	e.mu.Lock()
+	e.cache(key)
+	e.mu.Unlock()
+	return key, nil
+}
+
+// cache remembers an unwrapped key, forgetting the others when full; e.mu
+// must be held
+func (e *Encrypter) cache(key *dataKey) {
+	if len(e.opened) >= maxCachedKeys {
+		e.opened = make(map[string]*dataKey)
+	}
+	e.opened[string(key.header)] = key
+}
+
+// parseHeader splits the header off sealed data: the magic, the key ID and
+// the wrapped data key
+func parseHeader(sealed []byte) (header []byte, keyID string, wrapped []byte, err error) {
+	if !IsEncrypted(sealed) {
+		return nil, "", nil, ErrNotEncrypted
+	}
+	rest := sealed[len(magic):]
+	if len(rest) < 1 {
+		return nil, "", nil, ErrDecrypt
+	}
+	idLen := int(rest[0])
+	rest = rest[1:]
+	if len(rest) < idLen+2 {
+		return nil, "", nil, ErrDecrypt
+	}
+	keyID = string(rest[:idLen])
+	wrappedLen := int(binary.BigEndian.Uint16(rest[idLen:]))
+	rest = rest[idLen+2:]
+	if len(rest) < wrappedLen {
+		return nil, "", nil, ErrDecrypt
+	}
+	size := len(magic) + 1 + idLen + 2 + wrappedLen
+	return sealed[:size], keyID, rest[:wrappedLen], nil
+}
+
+// additionalData authenticates the header and the caller's associated
+// data along with the ciphertext
+func additionalData(header, associated []byte) []byte {
+	return append(append([]byte(nil), header...), associated...)
+}
+
+// newAEAD returns AES-GCM under key
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("encryption: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
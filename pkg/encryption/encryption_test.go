@@ -0,0 +1,162 @@
+package encryption
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, DataKeySize)
+}
+
+// countingKMS counts the calls to the KMS it wraps
+type countingKMS struct {
+	KMS
+	wraps, unwraps int
+}
+
+func (k *countingKMS) WrapKey(ctx context.Context, dataKey []byte) (string, []byte, error) {
+	k.wraps++
+	return k.KMS.WrapKey(ctx, dataKey)
+}
+
+func (k *countingKMS) UnwrapKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	k.unwraps++
+	return k.KMS.UnwrapKey(ctx, keyID, wrapped)
+}
+
+func TestSealOpen(t *testing.T) {
+	keyring, err := NewKeyring("k1", map[string][]byte{"k1": testKey(1)})
+	if err != nil {
+		t.Fatalf("NewKeyring failed: %v", err)
+	}
+	e := New(keyring)
+	ctx := context.Background()
+
+	sealed, err := e.Seal(ctx, []byte("hello, chat"), []byte("chats/chat-1/000000.json"))
+	if err != nil {
+		t.Fatalf("Seal failed: %v", err)
+	}
+	if bytes.Contains(sealed, []byte("hello")) {
+		t.Errorf("Expected no plaintext in the sealed data")
+	}
+	if !IsEncrypted(sealed) {
+		t.Errorf("Expected sealed data to be recognized")
+	}
+
+	plaintext, err := e.Open(ctx, sealed, []byte("chats/chat-1/000000.json"))
+	if err != nil || string(plaintext) != "hello, chat" {
+		t.Errorf("Expected the plaintext back, got %q, %v", plaintext, err)
+	}
+
+	// Another encrypter over the same KMS opens it too
+	if _, err := New(keyring).Open(ctx, sealed, []byte("chats/chat-1/000000.json")); err != nil {
+		t.Errorf("Expected a fresh encrypter to open it, got %v", err)
+	}
+
+	if _, err := e.Open(ctx, sealed, []byte("chats/chat-2/000000.json")); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("Expected ErrDecrypt under other associated data, got %v", err)
+	}
+	tampered := append([]byte(nil), sealed...)
+	tampered[len(tampered)-1] ^= 1
+	if _, err := e.Open(ctx, tampered, []byte("chats/chat-1/000000.json")); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("Expected ErrDecrypt for modified data, got %v", err)
+	}
+	if _, err := e.Open(ctx, []byte(`{"plain":true}`), nil); !errors.Is(err, ErrNotEncrypted) {
+		t.Errorf("Expected ErrNotEncrypted for plaintext, got %v", err)
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	ctx := context.Background()
+	old, _ := NewKeyring("k1", map[string][]byte{"k1": testKey(1)})
+	before, _ := New(old).Seal(ctx, []byte("before"), nil)
+
+	rotated, err := NewKeyring("k2", map[string][]byte{"k1": testKey(1), "k2": testKey(2)})
+	if err != nil {
+		t.Fatalf("NewKeyring failed: %v", err)
+	}
+	e := New(rotated)
+	after, _ := e.Seal(ctx, []byte("after"), nil)
+
+	for _, sealed := range [][]byte{before, after} {
+		if _, err := e.Open(ctx, sealed, nil); err != nil {
+			t.Errorf("Expected data from before and after the rotation to open, got %v", err)
+		}
+	}
+
+	retired, _ := NewKeyring("k2", map[string][]byte{"k2": testKey(2)})
+	if _, err := New(retired).Open(ctx, before, nil); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Expected ErrUnknownKey once k1 is gone, got %v", err)
+	}
+}
+
+func TestDataKeysReused(t *testing.T) {
+	keyring, _ := NewKeyring("k1", map[string][]byte{"k1": testKey(1)})
+	kms := &countingKMS{KMS: keyring}
+	e := New(kms, WithDataKeyLifetime(time.Hour))
+	ctx := context.Background()
+
+	var sealed [][]byte
+	for i := 0; i < 5; i++ {
+		data, err := e.Seal(ctx, []byte("message"), nil)
+		if err != nil {
+			t.Fatalf("Seal failed: %v", err)
+		}
+		sealed = append(sealed, data)
+	}
+	if kms.wraps != 1 {
+		t.Errorf("Expected one data key wrapped for 5 seals, got %d", kms.wraps)
+	}
+
+	reader := New(kms)
+	for _, data := range sealed {
+		if _, err := reader.Open(ctx, data, nil); err != nil {
+			t.Fatalf("Open failed: %v", err)
+		}
+	}
+	if kms.unwraps != 1 {
+		t.Errorf("Expected the data key unwrapped once for 5 opens, got %d", kms.unwraps)
+	}
+
+	expiring := New(kms, WithDataKeyLifetime(time.Nanosecond))
+	expiring.Seal(ctx, nil, nil)
+	time.Sleep(time.Millisecond)
+	expiring.Seal(ctx, nil, nil)
+	if kms.wraps != 3 {
+		t.Errorf("Expected a new data key after the lifetime, got %d wraps", kms.wraps-1)
+	}
+}
+
+func TestNewKeyringErrors(t *testing.T) {
+	if _, err := NewKeyring("missing", map[string][]byte{"k1": testKey(1)}); err == nil {
+		t.Errorf("Expected an error for a current key not in the keyring")
+	}
+	if _, err := NewKeyring("short", map[string][]byte{"short": []byte("too short")}); err == nil {
+		t.Errorf("Expected an error for a key of the wrong size")
+	}
+}
+
+func TestLoadKeyring(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	key := base64.StdEncoding.EncodeToString(testKey(1))
+	os.WriteFile(path, []byte(`{"current": "k1", "keys": {"k1": "`+key+`"}}`), 0o600)
+	keyring, err := LoadKeyring(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, _, err := keyring.WrapKey(context.Background(), testKey(2)); err != nil || id != "k1" {
+		t.Errorf("Expected data keys wrapped under k1, got %q (%v)", id, err)
+	}
+
+	os.WriteFile(path, []byte(`{"current": "k2", "keys": {"k1": "`+key+`"}}`), 0o600)
+	if _, err := LoadKeyring(path); err == nil {
+		t.Errorf("Expected an error for a current key not in the file")
+	}
+}
@@ -0,0 +1,115 @@
+package events
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// subscriptionBuffer is how many events a subscriber may fall behind by
+// before new ones are dropped for it
+const subscriptionBuffer = 1024
+
+// Bus delivers published events to its subscribers. Publishing never
+// blocks: each subscriber has its own queue and goroutine, so a slow
+// handler delays only itself, and drops events once its queue is full.
+type Bus struct {
+	mu   sync.RWMutex
+	subs map[*Subscription]struct{}
+}
+
+// NewBus creates a bus without subscribers
+func NewBus() *Bus {
+	return &Bus{subs: make(map[*Subscription]struct{})}
+}
+
+var defaultBus = NewBus()
+
+// Default returns the process-wide bus
+func Default() *Bus {
+	return defaultBus
+}
+
+// Publish hands e to every subscriber of its kind. It is safe to call
+// while holding locks: handlers run on their subscribers' goroutines.
+func (b *Bus) Publish(e Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for sub := range b.subs {
+		if !sub.wants(e.Kind()) {
+			continue
+		}
+		select {
+		case sub.queue <- e:
+		default:
+			sub.dropped.Add(1)
+		}
+	}
+}
+
+// Subscribe calls handler with every event of the given kinds (all kinds
+// if none are given) published from now on, one at a time, in publishing
+// order, until the subscription is closed
+func (b *Bus) Subscribe(handler func(Event), kinds ...Kind) *Subscription {
+	sub := &Subscription{
+		bus:   b,
+		queue: make(chan Event, subscriptionBuffer),
+		done:  make(chan struct{}),
+	}
+	if len(kinds) > 0 {
+		sub.kinds = make(map[Kind]bool, len(kinds))
+		for _, kind := range kinds {
+			sub.kinds[kind] = true
+		}
+	}
+
+	b.mu.Lock()
+	b.subs[sub] = struct{}{}
+	b.mu.Unlock()
+
+	go func() {
+		defer close(sub.done)
+		for e := range sub.queue {
+			handler(e)
+		}
+	}()
+	return sub
+}
+
+// Subscription is a handler registered with a bus
+type Subscription struct {
+	bus     *Bus
+	kinds   map[Kind]bool // nil: every kind
+	queue   chan Event
+	done    chan struct{}
+	dropped atomic.Int64
+	closed  sync.Once
+}
+
+// wants reports whether the subscription takes events of kind
+func (s *Subscription) wants(kind Kind) bool {
+	return s.kinds == nil || s.kinds[kind]
+}
+
+// Close stops the subscription. Events already queued are still handled;
+// Done is closed after the last one.
+func (s *Subscription) Close() {
+	s.closed.Do(func() {
+		s.bus.mu.Lock()
+		delete(s.bus.subs, s)
+		s.bus.mu.Unlock()
+		close(s.queue)
+	})
+}
+
+// Done is closed once the subscription is closed and its handler has
+// returned for the last time
+func (s *Subscription) Done() <-chan struct{} {
+	return s.done
+}
+
+// Dropped returns how many events were dropped because the handler fell
+// too far behind
+func (s *Subscription) Dropped() int64 {
+	return s.dropped.Load()
+}
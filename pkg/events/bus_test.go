@@ -0,0 +1,72 @@
+package events
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSubscribe(t *testing.T) {
+	bus := NewBus()
+
+	var all, failovers []Event
+	subAll := bus.Subscribe(func(e Event) { all = append(all, e) })
+	subFailovers := bus.Subscribe(func(e Event) { failovers = append(failovers, e) }, KindFailover)
+
+	bus.Publish(Demotion{ChatID: "chat-1"})
+	bus.Publish(Failover{ChatID: "chat-2", From: "Server-A", To: "Server-B"})
+	bus.Publish(CacheEviction{ChatID: "chat-3"})
+
+	subAll.Close()
+	subFailovers.Close()
+	<-subAll.Done()
+	<-subFailovers.Done()
+
+	if len(all) != 3 || all[0].Kind() != KindDemotion || all[2].Kind() != KindCacheEviction {
+		t.Errorf("Expected every event in publishing order, got %v", all)
+	}
+	if len(failovers) != 1 || failovers[0].(Failover).To != "Server-B" {
+		t.Errorf("Expected only the failover, got %v", failovers)
+	}
+
+	// Closed subscriptions get nothing more
+	bus.Publish(Failover{ChatID: "chat-4"})
+	if len(failovers) != 1 {
+		t.Errorf("Expected no events after Close, got %v", failovers)
+	}
+}
+
+func TestSlowSubscriberDrops(t *testing.T) {
+	bus := NewBus()
+
+	release := make(chan struct{})
+	handled := 0
+	sub := bus.Subscribe(func(Event) {
+		<-release
+		handled++
+	})
+
+	// Publishing doesn't wait for the blocked handler
+	published := make(chan struct{})
+	go func() {
+		for i := 0; i < subscriptionBuffer+10; i++ {
+			bus.Publish(NodeDown{Node: "Server-A"})
+		}
+		close(published)
+	}()
+	select {
+	case <-published:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected Publish not to block on a slow subscriber")
+	}
+
+	close(release)
+	sub.Close()
+	<-sub.Done()
+
+	if got := int64(handled) + sub.Dropped(); got != subscriptionBuffer+10 {
+		t.Errorf("Expected every event handled or dropped, got %d", got)
+	}
+	if sub.Dropped() < 9 {
+		t.Errorf("Expected the overflow dropped, got %d dropped", sub.Dropped())
+	}
+}
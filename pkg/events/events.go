@@ -0,0 +1,79 @@
+// Package events is a process-wide bus for the conditions DistriChat's
+// components detect: cache evictions and demotions, client failovers,
+// nodes going down and ring changes. The code that notices a condition
+// publishes a typed event; whatever reacts to it (an alert, a replication
+// trigger, a test waiting for a failover) subscribes, without either side
+// knowing about the other.
+//
+// Caches, servers and clients publish to Default unless configured with
+// another bus.
+package events
+
+import "time"
+
+// Kind names an event type, for subscribing to some kinds only
+type Kind string
+
+// Event kinds
+const (
+	KindCacheEviction Kind = "cache_eviction"
+	KindDemotion      Kind = "demotion"
+	KindFailover      Kind = "failover"
+	KindNodeDown      Kind = "node_down"
+	KindRingChanged   Kind = "ring_changed"
+)
+
+// Event is one of the event types below
+type Event interface {
+	Kind() Kind
+}
+
+// CacheEviction is published when a cache drops a session from L2, its
+// last tier in memory
+type CacheEviction struct {
+	Time     time.Time
+	Server   string
+	ChatID   string
+	Archived bool // Written to the cold tier rather than lost
+}
+
+// Demotion is published when a cache moves a session from L1 to L2 to
+// make room
+type Demotion struct {
+	Time   time.Time
+	Server string
+	ChatID string
+}
+
+// Failover is published when a client's request succeeds on a server
+// other than the chat's primary
+type Failover struct {
+	Time   time.Time
+	ChatID string
+	From   string // The primary the client tried first
+	To     string // The server that answered
+}
+
+// NodeDown is published when a component stops considering a node alive:
+// a client marking a server down, or a server's gossip declaring a member
+// dead
+type NodeDown struct {
+	Time     time.Time
+	Node     string
+	Reporter string // Who noticed: a server ID, or "client"
+	Reason   string
+}
+
+// RingChanged is published when a server installs a newer ring view
+type RingChanged struct {
+	Time   time.Time
+	Server string
+	Epoch  uint64
+	Nodes  []string
+}
+
+func (CacheEviction) Kind() Kind { return KindCacheEviction }
+func (Demotion) Kind() Kind      { return KindDemotion }
+func (Failover) Kind() Kind      { return KindFailover }
+func (NodeDown) Kind() Kind      { return KindNodeDown }
+func (RingChanged) Kind() Kind   { return KindRingChanged }
@@ -0,0 +1,117 @@
+package fanout
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/pkg/clock"
+)
+
+// CursorKey names a durable subscriber's position in one chat
+type CursorKey struct {
+	ChatID     string
+	Subscriber string
+}
+
+// cursor is one subscriber's acknowledged position, and when it lapses
+type cursor struct {
+	seq     uint64
+	expires time.Time
+}
+
+// Cursors remember how far durable subscribers have acknowledged each
+// chat, so a subscriber that reconnects is sent again everything it
+// received but didn't acknowledge. A cursor neither acknowledged nor
+// resumed for the TTL is forgotten, and its subscriber starts over from
+// the position it asks for.
+type Cursors struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	clock   clock.Clock
+	cursors map[CursorKey]cursor
+}
+
+// NewCursors creates an empty cursor store whose cursors last ttl
+// (default: 24h), read from clk (default: the system clock)
+func NewCursors(ttl time.Duration, clk clock.Clock) *Cursors {
+	if ttl <= 0 {
+		ttl = 24 * time.Hour
+	}
+	if clk == nil {
+		clk = clock.System()
+	}
+	return &Cursors{ttl: ttl, clock: clk, cursors: make(map[CursorKey]cursor)}
+}
+
+// TTL returns how long a cursor lasts without use
+func (c *Cursors) TTL() time.Duration {
+	return c.ttl
+}
+
+// Get returns a subscriber's acknowledged position, or false if it has
+// none or it lapsed
+func (c *Cursors) Get(key CursorKey) (uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cur, ok := c.cursors[key]
+	if !ok || !c.clock.Now().Before(cur.expires) {
+		return 0, false
+	}
+	return cur.seq, true
+}
+
+// Resume returns a subscriber's position to continue from, its cursor's
+// or else afterSeq, and restarts the cursor's TTL
+func (c *Cursors) Resume(key CursorKey, afterSeq uint64) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.clock.Now()
+	cur, ok := c.cursors[key]
+	if !ok || !now.Before(cur.expires) {
+		cur = cursor{seq: afterSeq}
+	}
+	cur.expires = now.Add(c.ttl)
+	c.cursors[key] = cur
+	return cur.seq
+}
+
+// Ack moves a subscriber's cursor up to seq and restarts its TTL,
+// returning the new position. Acknowledgements are cumulative, so one
+// arriving late or twice never moves the cursor back.
+func (c *Cursors) Ack(key CursorKey, seq uint64) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.clock.Now()
+	cur, ok := c.cursors[key]
+	if !ok || !now.Before(cur.expires) {
+		cur = cursor{}
+	}
+	if seq > cur.seq {
+		cur.seq = seq
+	}
+	cur.expires = now.Add(c.ttl)
+	c.cursors[key] = cur
+	return cur.seq
+}
+
+// Expire forgets lapsed cursors, returning how many. Call it periodically.
+func (c *Cursors) Expire() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.clock.Now()
+	expired := 0
+	for key, cur := range c.cursors {
+		if !now.Before(cur.expires) {
+			delete(c.cursors, key)
+			expired++
+		}
+	}
+	return expired
+}
+
+// Len returns how many cursors are held, lapsed or not
+func (c *Cursors) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.cursors)
+}
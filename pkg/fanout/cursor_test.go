@@ -0,0 +1,42 @@
+package fanout
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/pkg/clock"
+)
+
+func TestCursors(t *testing.T) {
+	clk := clock.NewVirtual(time.Unix(1700000000, 0))
+	c := NewCursors(time.Hour, clk)
+	key := CursorKey{ChatID: "chat-1", Subscriber: "alice"}
+
+	if seq := c.Resume(key, 3); seq != 3 {
+		t.Errorf("Expected a new cursor to start where asked, got %d", seq)
+	}
+	if seq := c.Ack(key, 7); seq != 7 {
+		t.Errorf("Expected the cursor at 7, got %d", seq)
+	}
+	if seq := c.Ack(key, 5); seq != 7 {
+		t.Errorf("Expected a late ack not to move the cursor back, got %d", seq)
+	}
+	if seq := c.Resume(key, 0); seq != 7 {
+		t.Errorf("Expected to resume from the acknowledged position, got %d", seq)
+	}
+
+	clk.Advance(59 * time.Minute)
+	c.Ack(CursorKey{ChatID: "chat-2", Subscriber: "alice"}, 1)
+	clk.Advance(2 * time.Minute)
+	if _, ok := c.Get(key); ok {
+		t.Error("Expected the unused cursor to lapse after its TTL")
+	}
+	if seq := c.Ack(key, 2); seq != 2 {
+		t.Errorf("Expected a lapsed cursor to start over, got %d", seq)
+	}
+
+	clk.Advance(2 * time.Hour)
+	if n := c.Expire(); n != 2 || c.Len() != 0 {
+		t.Errorf("Expected both cursors expired, got %d with %d left", n, c.Len())
+	}
+}
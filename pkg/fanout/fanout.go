@@ -0,0 +1,365 @@
+// Package fanout delivers a server's accepted messages to everything
+// following a chat on it: client streams, gateway connections and replica
+// feeds. Publishing never blocks; each subscriber has a bounded queue, and
+// one that falls behind is disconnected or loses its oldest messages, as
+// it chose, without slowing the others.
+package fanout
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+
+	pb "github.com/sh4shv4t/DistriChat/proto"
+)
+
+// Kind is what a subscriber delivers messages to
+type Kind int
+
+const (
+	// Stream is a client's own subscription
+	Stream Kind = iota
+	// Gateway is a connection relaying messages to many clients, e.g. a
+	// websocket or federation gateway
+	Gateway
+	// Replica is a feed to another server
+	Replica
+)
+
+// kinds lists every kind, for per-kind statistics
+var kinds = []Kind{Stream, Gateway, Replica}
+
+// String returns the kind's metric label
+func (k Kind) String() string {
+	switch k {
+	case Stream:
+		return "stream"
+	case Gateway:
+		return "gateway"
+	case Replica:
+		return "replica"
+	default:
+		return "unknown"
+	}
+}
+
+// SlowPolicy is what happens to a subscriber whose queue is full when a
+// message is published
+type SlowPolicy int
+
+const (
+	// Disconnect closes the subscriber with ErrSlowConsumer; it resumes
+	// from the last message it received, reading the gap from history
+	Disconnect SlowPolicy = iota
+	// DropOldest discards the oldest queued message to make room, for
+	// subscribers that only care about what is recent
+	DropOldest
+)
+
+var (
+	// ErrSlowConsumer ends a subscription that fell a whole queue behind
+	ErrSlowConsumer = errors.New("fanout: subscriber fell too far behind")
+
+	// ErrClosed ends subscriptions when their hub closes
+	ErrClosed = errors.New("fanout: hub closed")
+)
+
+// Config configures a hub
+type Config struct {
+	// Buffer is how many messages a subscriber may fall behind by before
+	// its SlowPolicy applies (default: 256)
+	Buffer int
+
+	// MaxSubscribers caps the subscribers per chat (default: unlimited)
+	MaxSubscribers int
+}
+
+// withDefaults fills in unset fields
+func (c Config) withDefaults() Config {
+	if c.Buffer <= 0 {
+		c.Buffer = 256
+	}
+	return c
+}
+
+// ErrTooManySubscribers refuses a subscription to a chat already at
+// MaxSubscribers
+var ErrTooManySubscribers = errors.New("fanout: too many subscribers to chat")
+
+// Options describe one subscriber
+type Options struct {
+	Kind Kind
+
+	// Name identifies the subscriber: the user it follows the chat for,
+	// or its peer address
+	Name string
+
+	// Buffer overrides the hub's queue length for this subscriber
+	Buffer int
+
+	// OnSlow is what happens when the queue is full (default: Disconnect)
+	OnSlow SlowPolicy
+}
+
+// Stats are a hub's counters
+type Stats struct {
+	Chats        int          // Chats with at least one subscriber
+	Subscribers  map[Kind]int // Subscribers, by kind
+	Published    int64        // Messages published
+	Delivered    int64        // Messages queued for a subscriber
+	Dropped      int64        // Messages discarded by DropOldest subscribers
+	Disconnected int64        // Subscribers closed as slow consumers
+}
+
+// Hub tracks the subscribers of every chat on a server
+type Hub struct {
+	mu     sync.RWMutex
+	config Config
+	chats  map[string]map[*Subscriber]struct{}
+	closed bool
+
+	published    atomic.Int64
+	delivered    atomic.Int64
+	dropped      atomic.Int64
+	disconnected atomic.Int64
+}
+
+// NewHub creates a hub without subscribers
+func NewHub(config Config) *Hub {
+	return &Hub{
+		config: config.withDefaults(),
+		chats:  make(map[string]map[*Subscriber]struct{}),
+	}
+}
+
+// Subscribe registers a subscriber for the messages published to chatID
+// from now on. The caller reads them from C until it is closed, then
+// checks Err.
+func (h *Hub) Subscribe(chatID string, opts Options) (*Subscriber, error) {
+	buffer := opts.Buffer
+	if buffer <= 0 {
+		buffer = h.config.Buffer
+	}
+	sub := &Subscriber{
+		hub:    h,
+		chatID: chatID,
+		kind:   opts.Kind,
+		name:   opts.Name,
+		onSlow: opts.OnSlow,
+		ch:     make(chan *pb.StoredMessage, buffer),
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return nil, ErrClosed
+	}
+	subs := h.chats[chatID]
+	if h.config.MaxSubscribers > 0 && len(subs) >= h.config.MaxSubscribers {
+		return nil, ErrTooManySubscribers
+	}
+	if subs == nil {
+		subs = make(map[*Subscriber]struct{})
+		h.chats[chatID] = subs
+	}
+	subs[sub] = struct{}{}
+	return sub, nil
+}
+
+// Publish queues msg for every subscriber of chatID, returning how many
+// took it. It never blocks, so it is safe to call on the write path.
+func (h *Hub) Publish(chatID string, msg *pb.StoredMessage) int {
+	h.published.Add(1)
+
+	var delivered int
+	var slow []*Subscriber
+	h.mu.RLock()
+	for sub := range h.chats[chatID] {
+		if sub.deliver(msg) {
+			delivered++
+		} else {
+			slow = append(slow, sub)
+		}
+	}
+	h.mu.RUnlock()
+	h.delivered.Add(int64(delivered))
+
+	for _, sub := range slow {
+		if sub.close(ErrSlowConsumer) {
+			h.disconnected.Add(1)
+		}
+	}
+	return delivered
+}
+
+// Subscribers returns how many subscribers follow chatID
+func (h *Hub) Subscribers(chatID string) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.chats[chatID])
+}
+
+// Names returns the names of chatID's subscribers, e.g. to tell which of
+// its members are following it
+func (h *Hub) Names(chatID string) map[string]bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	names := make(map[string]bool, len(h.chats[chatID]))
+	for sub := range h.chats[chatID] {
+		names[sub.name] = true
+	}
+	return names
+}
+
+// Stats returns the hub's counters
+func (h *Hub) Stats() Stats {
+	stats := Stats{
+		Subscribers:  make(map[Kind]int, len(kinds)),
+		Published:    h.published.Load(),
+		Delivered:    h.delivered.Load(),
+		Dropped:      h.dropped.Load(),
+		Disconnected: h.disconnected.Load(),
+	}
+	for _, kind := range kinds {
+		stats.Subscribers[kind] = 0
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	stats.Chats = len(h.chats)
+	for _, subs := range h.chats {
+		for sub := range subs {
+			stats.Subscribers[sub.kind]++
+		}
+	}
+	return stats
+}
+
+// Close ends every subscription with ErrClosed and refuses new ones
+func (h *Hub) Close() {
+	h.mu.Lock()
+	h.closed = true
+	var subs []*Subscriber
+	for _, chat := range h.chats {
+		for sub := range chat {
+			subs = append(subs, sub)
+		}
+	}
+	h.mu.Unlock()
+
+	for _, sub := range subs {
+		sub.close(ErrClosed)
+	}
+}
+
+// remove unregisters a closed subscriber
+func (h *Hub) remove(sub *Subscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if subs := h.chats[sub.chatID]; subs != nil {
+		delete(subs, sub)
+		if len(subs) == 0 {
+			delete(h.chats, sub.chatID)
+		}
+	}
+}
+
+// Subscriber is one follower of a chat
+type Subscriber struct {
+	hub    *Hub
+	chatID string
+	kind   Kind
+	name   string
+	onSlow SlowPolicy
+
+	// mu orders deliveries against close, so nothing is sent on a closed
+	// channel
+	mu      sync.Mutex
+	ch      chan *pb.StoredMessage
+	closed  bool
+	err     error
+	dropped atomic.Int64
+}
+
+// deliver queues msg, reporting false if the subscriber is too slow to
+// take it and must be disconnected
+func (s *Subscriber) deliver(msg *pb.StoredMessage) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return true
+	}
+
+	for {
+		select {
+		case s.ch <- msg:
+			return true
+		default:
+		}
+		if s.onSlow != DropOldest {
+			return false
+		}
+		select {
+		case <-s.ch:
+			s.dropped.Add(1)
+			s.hub.dropped.Add(1)
+		default:
+		}
+	}
+}
+
+// close ends the subscription with err, reporting whether it was open
+func (s *Subscriber) close(err error) bool {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return false
+	}
+	s.closed = true
+	s.err = err
+	close(s.ch)
+	s.mu.Unlock()
+
+	s.hub.remove(s)
+	return true
+}
+
+// C delivers the chat's messages in publishing order. It is closed when
+// the subscription ends; messages queued before then are still delivered.
+func (s *Subscriber) C() <-chan *pb.StoredMessage {
+	return s.ch
+}
+
+// Err returns why the subscription ended: nil if the subscriber closed it,
+// ErrSlowConsumer or ErrClosed otherwise
+func (s *Subscriber) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Close ends the subscription
+func (s *Subscriber) Close() {
+	s.close(nil)
+}
+
+// ChatID returns the chat the subscriber follows
+func (s *Subscriber) ChatID() string {
+	return s.chatID
+}
+
+// Kind returns what the subscriber delivers to
+func (s *Subscriber) Kind() Kind {
+	return s.kind
+}
+
+// Name returns the name the subscriber was registered with
+func (s *Subscriber) Name() string {
+	return s.name
+}
+
+// Dropped returns how many messages were discarded for this subscriber
+// under DropOldest
+func (s *Subscriber) Dropped() int64 {
+	return s.dropped.Load()
+}
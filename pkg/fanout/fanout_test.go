@@ -0,0 +1,98 @@
+package fanout
+
+import (
+	"errors"
+	"testing"
+
+	pb "github.com/sh4shv4t/DistriChat/proto"
+)
+
+func message(seq uint64) *pb.StoredMessage {
+	return &pb.StoredMessage{Seq: seq}
+}
+
+func TestHubDelivers(t *testing.T) {
+	h := NewHub(Config{})
+	a, _ := h.Subscribe("chat-1", Options{Kind: Stream})
+	b, _ := h.Subscribe("chat-1", Options{Kind: Gateway})
+	other, _ := h.Subscribe("chat-2", Options{Kind: Replica})
+
+	if n := h.Publish("chat-1", message(1)); n != 2 {
+		t.Errorf("Expected the message delivered to 2 subscribers, got %d", n)
+	}
+	for _, sub := range []*Subscriber{a, b} {
+		if msg := <-sub.C(); msg.Seq != 1 {
+			t.Errorf("Expected message 1, got %d", msg.Seq)
+		}
+	}
+	select {
+	case msg := <-other.C():
+		t.Errorf("Expected nothing for chat-2, got %v", msg)
+	default:
+	}
+
+	stats := h.Stats()
+	if stats.Chats != 2 || stats.Subscribers[Stream] != 1 || stats.Subscribers[Replica] != 1 || stats.Delivered != 2 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+
+	a.Close()
+	if _, ok := <-a.C(); ok || a.Err() != nil {
+		t.Errorf("Expected a closed subscription without error, got %v", a.Err())
+	}
+	if n := h.Subscribers("chat-1"); n != 1 {
+		t.Errorf("Expected 1 subscriber left, got %d", n)
+	}
+}
+
+func TestHubSlowConsumers(t *testing.T) {
+	h := NewHub(Config{Buffer: 2})
+	slow, _ := h.Subscribe("chat-1", Options{Name: "slow"})
+	recent, _ := h.Subscribe("chat-1", Options{OnSlow: DropOldest})
+	fast, _ := h.Subscribe("chat-1", Options{Buffer: 10})
+
+	for seq := uint64(1); seq <= 5; seq++ {
+		h.Publish("chat-1", message(seq))
+	}
+
+	// The slow subscriber keeps what it was sent before it was cut off
+	var got []uint64
+	for msg := range slow.C() {
+		got = append(got, msg.Seq)
+	}
+	if len(got) != 2 || !errors.Is(slow.Err(), ErrSlowConsumer) {
+		t.Errorf("Expected 2 messages then ErrSlowConsumer, got %v, %v", got, slow.Err())
+	}
+
+	if a, b := <-recent.C(), <-recent.C(); a.Seq != 4 || b.Seq != 5 || recent.Dropped() != 3 {
+		t.Errorf("Expected the 2 most recent messages, got %d and %d with %d dropped", a.Seq, b.Seq, recent.Dropped())
+	}
+	if len(fast.C()) != 5 {
+		t.Errorf("Expected the fast subscriber unaffected, got %d messages", len(fast.C()))
+	}
+
+	stats := h.Stats()
+	if stats.Disconnected != 1 || stats.Dropped != 3 || h.Subscribers("chat-1") != 2 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+}
+
+func TestHubLimitsAndClose(t *testing.T) {
+	h := NewHub(Config{MaxSubscribers: 1})
+	sub, err := h.Subscribe("chat-1", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.Subscribe("chat-1", Options{}); !errors.Is(err, ErrTooManySubscribers) {
+		t.Errorf("Expected ErrTooManySubscribers, got %v", err)
+	}
+
+	h.Close()
+	if _, ok := <-sub.C(); ok || !errors.Is(sub.Err(), ErrClosed) {
+		t.Errorf("Expected the subscription ended with ErrClosed, got %v", sub.Err())
+	}
+	if _, err := h.Subscribe("chat-2", Options{}); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected subscriptions refused once closed, got %v", err)
+	}
+	h.Publish("chat-1", message(1))
+}
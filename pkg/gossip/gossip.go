@@ -0,0 +1,707 @@
+// Package gossip implements SWIM-style failure detection and membership
+// dissemination among servers.
+//
+// Every protocol period each node probes one member directly. If the probe
+// times out it asks a few other members to probe indirectly; if those fail
+// too the member becomes SUSPECT. Every message heard from a member feeds a
+// phi accrual failure detector, and a SUSPECT member is declared DEAD once
+// its suspicion level passes DeadThreshold without a refutation. Membership
+// changes are piggybacked on probe traffic and retransmitted O(log N)
+// times, so the cluster converges on who is alive without any central
+// health authority.
+package gossip
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/internal/phi"
+	"github.com/sh4shv4t/DistriChat/pkg/logging"
+)
+
+// ErrUnreachable is returned by transports when a node cannot be contacted
+var ErrUnreachable = errors.New("gossip: node unreachable")
+
+// Transport delivers a message to the node at address and returns its reply
+type Transport interface {
+	Send(ctx context.Context, address string, msg Message) (Message, error)
+}
+
+// Config contains configuration for a gossip node
+type Config struct {
+	// Identity of the local node
+	ID      string
+	Address string
+
+	// Time between probes (default: 1s)
+	ProtocolPeriod time.Duration
+
+	// How long to wait for a direct or indirect ack (default: ProtocolPeriod / 3)
+	PingTimeout time.Duration
+
+	// Number of members asked to probe indirectly (default: 3)
+	IndirectChecks int
+
+	// Suspicion level (phi) at which a SUSPECT member is declared DEAD
+	// (default: 12). Clients stop routing to a server at a lower level, so
+	// traffic moves away well before the cluster evicts it.
+	DeadThreshold float64
+
+	// How long a member this node has never heard from stays SUSPECT
+	// before being declared DEAD (default: 5 * ProtocolPeriod)
+	SuspicionTimeout time.Duration
+
+	// Updates are retransmitted RetransmitMult * log2(N+1) times (default: 3)
+	RetransmitMult int
+
+	// Maximum updates piggybacked on one message (default: 8)
+	MaxPiggyback int
+}
+
+// Node is a single participant in the gossip protocol
+type Node struct {
+	mu sync.Mutex
+
+	config    Config
+	transport Transport
+	log       *slog.Logger
+
+	self    Member
+	members map[string]*memberState
+
+	// Heartbeat history of each member, fed by every message heard from it
+	detector *phi.Monitor
+
+	// Pending updates to piggyback, with their transmit counts
+	broadcasts []*broadcast
+
+	// Round-robin probe order, reshuffled after each pass
+	probeOrder []string
+	probeIdx   int
+
+	seq uint64
+	rng *rand.Rand
+
+	// Change listeners
+	listeners []func(Member)
+
+	// Local ring view stamped on every message, and the listeners told
+	// when a member's message carries a different one
+	ringView      RingView
+	ringListeners []func(Member, RingView)
+
+	stopCh   chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// memberState tracks a remote member
+type memberState struct {
+	Member
+	suspectSince time.Time
+}
+
+// broadcast is an update waiting to be piggybacked
+type broadcast struct {
+	update    Member
+	transmits int
+}
+
+// New creates a gossip node. It does not contact anyone until Join/Start.
+func New(config Config, transport Transport) *Node {
+	if config.ProtocolPeriod <= 0 {
+		config.ProtocolPeriod = time.Second
+	}
+	if config.PingTimeout <= 0 {
+		config.PingTimeout = config.ProtocolPeriod / 3
+	}
+	if config.IndirectChecks <= 0 {
+		config.IndirectChecks = 3
+	}
+	if config.DeadThreshold <= 0 {
+		config.DeadThreshold = 12
+	}
+	if config.SuspicionTimeout <= 0 {
+		config.SuspicionTimeout = 5 * config.ProtocolPeriod
+	}
+	if config.RetransmitMult <= 0 {
+		config.RetransmitMult = 3
+	}
+	if config.MaxPiggyback <= 0 {
+		config.MaxPiggyback = 8
+	}
+
+	return &Node{
+		config:    config,
+		transport: transport,
+		log:       logging.Logger("gossip").With(logging.ServerID(config.ID)),
+		self: Member{
+			ID:      config.ID,
+			Address: config.Address,
+			State:   StateAlive,
+		},
+		members:  make(map[string]*memberState),
+		detector: phi.NewMonitor(phi.Config{FirstInterval: config.ProtocolPeriod}),
+		rng:      rand.New(rand.NewSource(time.Now().UnixNano())),
+		stopCh:   make(chan struct{}),
+	}
+}
+
+// Start begins the probe loop
+func (n *Node) Start() {
+	n.wg.Add(1)
+	go n.run()
+	n.log.Info("Started", "period", n.config.ProtocolPeriod)
+}
+
+// Stop ends the probe loop without announcing departure
+func (n *Node) Stop() {
+	n.stopOnce.Do(func() {
+		close(n.stopCh)
+	})
+	n.wg.Wait()
+}
+
+// Join contacts the seed addresses and merges their full membership view.
+// It succeeds if at least one seed answered.
+func (n *Node) Join(seeds []string) error {
+	var lastErr error
+	joined := 0
+
+	for _, addr := range seeds {
+		if addr == n.config.Address {
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), n.config.ProtocolPeriod)
+		reply, err := n.transport.Send(ctx, addr, Message{
+			Kind:    KindSync,
+			From:    n.config.ID,
+			Updates: n.fullState(),
+			Ring:    n.RingView(),
+		})
+		cancel()
+
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		n.merge(reply.Updates)
+		n.compareRing(reply)
+		joined++
+	}
+
+	if joined == 0 && lastErr != nil {
+		return lastErr
+	}
+
+	n.log.Info("Joined the cluster", "seeds", joined, "members", len(n.Members()))
+	return nil
+}
+
+// Leave announces a voluntary departure to a few members, then stops
+func (n *Node) Leave() {
+	n.mu.Lock()
+	n.self.State = StateLeft
+	n.self.Incarnation++
+	leave := n.self
+	targets := n.sampleLocked(n.liveMembersLocked(), n.config.IndirectChecks)
+	n.mu.Unlock()
+
+	for _, m := range targets {
+		ctx, cancel := context.WithTimeout(context.Background(), n.config.PingTimeout)
+		n.transport.Send(ctx, m.Address, Message{
+			Kind:    KindPing,
+			From:    n.config.ID,
+			Updates: []Member{leave},
+		})
+		cancel()
+	}
+
+	n.Stop()
+	n.log.Info("Left the cluster")
+}
+
+// Handle processes an incoming message and returns the reply. Transports
+// call this on the receiving side.
+func (n *Node) Handle(ctx context.Context, msg Message) (Message, error) {
+	n.heard(msg.From)
+	n.merge(msg.Updates)
+	n.compareRing(msg)
+
+	switch msg.Kind {
+	case KindPing:
+		return n.reply(KindAck, msg.Seq), nil
+
+	case KindPingReq:
+		if err := n.probe(ctx, msg.Target.Address); err != nil {
+			return Message{}, err
+		}
+		return n.reply(KindAck, msg.Seq), nil
+
+	case KindSync:
+		return Message{
+			Kind:    KindAck,
+			Seq:     msg.Seq,
+			From:    n.config.ID,
+			Updates: n.fullState(),
+			Ring:    n.RingView(),
+		}, nil
+
+	default:
+		return n.reply(KindAck, msg.Seq), nil
+	}
+}
+
+// OnChange registers a listener called (outside the node lock) whenever a
+// member's state changes
+func (n *Node) OnChange(fn func(Member)) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.listeners = append(n.listeners, fn)
+}
+
+// SetRingView sets the ring view stamped on the node's messages. Call it
+// whenever the local ring changes.
+func (n *Node) SetRingView(view RingView) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.ringView = view
+}
+
+// RingView returns the local ring view
+func (n *Node) RingView() RingView {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.ringView
+}
+
+// OnRingView registers a listener called (outside the node lock) for every
+// message from a known member whose ring view differs from the local one,
+// newer or older. Listeners pull newer rings and report conflicting ones;
+// peers holding older rings pull from this node when they see its view.
+func (n *Node) OnRingView(fn func(Member, RingView)) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.ringListeners = append(n.ringListeners, fn)
+}
+
+// Self returns the local member
+func (n *Node) Self() Member {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.self
+}
+
+// Members returns every known member including the local node, sorted by ID
+func (n *Node) Members() []Member {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	members := make([]Member, 0, len(n.members)+1)
+	members = append(members, n.self)
+	for _, m := range n.members {
+		members = append(members, m.Member)
+	}
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].ID < members[j].ID
+	})
+	return members
+}
+
+// Suspicion returns the phi suspicion level of the member with the given ID:
+// how unusual its current silence is given how often this node normally
+// hears from it. It is 0 for the local node and members never heard from.
+func (n *Node) Suspicion(id string) float64 {
+	level, _ := n.detector.Phi(id, time.Now())
+	return level
+}
+
+// Suspicions returns the suspicion level of every member this node has
+// heard from
+func (n *Node) Suspicions() map[string]float64 {
+	n.mu.Lock()
+	ids := make([]string, 0, len(n.members))
+	for id := range n.members {
+		ids = append(ids, id)
+	}
+	n.mu.Unlock()
+
+	now := time.Now()
+	levels := make(map[string]float64, len(ids))
+	for _, id := range ids {
+		if level, ok := n.detector.Phi(id, now); ok {
+			levels[id] = level
+		}
+	}
+	return levels
+}
+
+// heard records a message from the member with the given ID as a heartbeat
+func (n *Node) heard(id string) {
+	if id != "" && id != n.config.ID {
+		n.detector.Heartbeat(id, time.Now())
+	}
+}
+
+// run drives the protocol period
+func (n *Node) run() {
+	defer n.wg.Done()
+
+	ticker := time.NewTicker(n.config.ProtocolPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			n.tick()
+		case <-n.stopCh:
+			return
+		}
+	}
+}
+
+// tick runs one protocol period: probe one member and expire suspicions
+func (n *Node) tick() {
+	n.expireSuspects()
+
+	target, ok := n.nextProbeTarget()
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), n.config.PingTimeout)
+	err := n.probe(ctx, target.Address)
+	cancel()
+	if err == nil {
+		n.heard(target.ID)
+		return
+	}
+
+	if n.probeIndirect(target) {
+		n.heard(target.ID)
+		return
+	}
+
+	n.mu.Lock()
+	if m, ok := n.members[target.ID]; ok && m.State == StateAlive {
+		suspect := m.Member
+		suspect.State = StateSuspect
+		n.applyLocked(suspect)
+		n.mu.Unlock()
+		n.log.Warn("Suspecting member (no ack)", logging.NodeID(target.ID))
+		n.notify(suspect)
+		return
+	}
+	n.mu.Unlock()
+}
+
+// probe sends a direct ping and merges the ack's updates
+func (n *Node) probe(ctx context.Context, address string) error {
+	reply, err := n.transport.Send(ctx, address, n.outgoing(KindPing, Member{}))
+	if err != nil {
+		return err
+	}
+	n.merge(reply.Updates)
+	n.compareRing(reply)
+	return nil
+}
+
+// probeIndirect asks up to IndirectChecks other members to probe target
+func (n *Node) probeIndirect(target Member) bool {
+	n.mu.Lock()
+	var candidates []Member
+	for _, m := range n.liveMembersLocked() {
+		if m.ID != target.ID {
+			candidates = append(candidates, m)
+		}
+	}
+	helpers := n.sampleLocked(candidates, n.config.IndirectChecks)
+	n.mu.Unlock()
+
+	if len(helpers) == 0 {
+		return false
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), n.config.PingTimeout*2)
+	defer cancel()
+
+	acks := make(chan bool, len(helpers))
+	for _, helper := range helpers {
+		go func(helper Member) {
+			reply, err := n.transport.Send(ctx, helper.Address, n.outgoing(KindPingReq, target))
+			if err == nil {
+				n.merge(reply.Updates)
+				n.compareRing(reply)
+			}
+			acks <- err == nil
+		}(helper)
+	}
+
+	for range helpers {
+		if <-acks {
+			return true
+		}
+	}
+	return false
+}
+
+// nextProbeTarget returns the next member in the shuffled round-robin order
+func (n *Node) nextProbeTarget() (Member, bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for attempts := 0; attempts < 2; attempts++ {
+		for n.probeIdx < len(n.probeOrder) {
+			id := n.probeOrder[n.probeIdx]
+			n.probeIdx++
+			if m, ok := n.members[id]; ok && (m.State == StateAlive || m.State == StateSuspect) {
+				return m.Member, true
+			}
+		}
+
+		// Pass complete - reshuffle for the next one
+		n.probeOrder = n.probeOrder[:0]
+		for id := range n.members {
+			n.probeOrder = append(n.probeOrder, id)
+		}
+		n.rng.Shuffle(len(n.probeOrder), func(i, j int) {
+			n.probeOrder[i], n.probeOrder[j] = n.probeOrder[j], n.probeOrder[i]
+		})
+		n.probeIdx = 0
+	}
+	return Member{}, false
+}
+
+// expireSuspects declares SUSPECT members DEAD once their suspicion level
+// passes DeadThreshold, or, for members never heard from, once
+// SuspicionTimeout passes
+func (n *Node) expireSuspects() {
+	now := time.Now()
+
+	n.mu.Lock()
+	var dead []Member
+	var reasons []string
+	for _, m := range n.members {
+		if m.State != StateSuspect {
+			continue
+		}
+
+		var reason string
+		if level, ok := n.detector.Phi(m.ID, now); ok {
+			if level < n.config.DeadThreshold {
+				continue
+			}
+			reason = fmt.Sprintf("phi %.1f", level)
+		} else {
+			if now.Sub(m.suspectSince) <= n.config.SuspicionTimeout {
+				continue
+			}
+			reason = "suspicion timed out"
+		}
+
+		update := m.Member
+		update.State = StateDead
+		n.applyLocked(update)
+		dead = append(dead, update)
+		reasons = append(reasons, reason)
+	}
+	n.mu.Unlock()
+
+	for i, m := range dead {
+		n.log.Warn("Declared member dead", logging.NodeID(m.ID), "reason", reasons[i])
+		n.notify(m)
+	}
+}
+
+// merge applies incoming updates and queues accepted ones for rebroadcast
+func (n *Node) merge(updates []Member) {
+	var changed []Member
+
+	n.mu.Lock()
+	for _, u := range updates {
+		if u.ID == "" {
+			continue
+		}
+
+		if u.ID == n.config.ID {
+			// Someone thinks we're suspect, dead or gone - refute with a higher incarnation
+			if u.State != StateAlive &&
+				n.self.State == StateAlive && u.Incarnation >= n.self.Incarnation {
+				n.self.Incarnation = u.Incarnation + 1
+				n.queueLocked(n.self)
+				n.log.Info("Refuting suspicion", "state", u.State.String(), "incarnation", n.self.Incarnation)
+			}
+			continue
+		}
+
+		// Unknown members are recorded even when dead or gone, so a stale
+		// ALIVE update arriving later cannot resurrect them
+		current, known := n.members[u.ID]
+		if known && !supersedes(u, current.Member) {
+			continue
+		}
+
+		n.applyLocked(u)
+		changed = append(changed, u)
+	}
+	n.mu.Unlock()
+
+	for _, m := range changed {
+		n.notify(m)
+	}
+}
+
+// compareRing tells the ring listeners when a message from a known member
+// carries a ring view different from the local one
+func (n *Node) compareRing(msg Message) {
+	if msg.Ring.IsZero() {
+		return
+	}
+
+	n.mu.Lock()
+	m, known := n.members[msg.From]
+	if !known || msg.Ring == n.ringView {
+		n.mu.Unlock()
+		return
+	}
+	from := m.Member
+	listeners := append([]func(Member, RingView){}, n.ringListeners...)
+	n.mu.Unlock()
+
+	for _, fn := range listeners {
+		fn(from, msg.Ring)
+	}
+}
+
+// applyLocked installs an update and queues it for dissemination
+// (must be called with lock held)
+func (n *Node) applyLocked(u Member) {
+	m, ok := n.members[u.ID]
+	if !ok {
+		m = &memberState{}
+		n.members[u.ID] = m
+	}
+	if u.State == StateSuspect && m.State != StateSuspect {
+		m.suspectSince = time.Now()
+	}
+	if u.State == StateAlive && (m.State == StateDead || m.State == StateLeft) {
+		// A member that rejoins starts a fresh heartbeat history rather
+		// than one with its downtime as an interval
+		n.detector.Remove(u.ID)
+	}
+	m.Member = u
+	n.queueLocked(u)
+}
+
+// queueLocked adds an update to the broadcast queue, replacing any older
+// update about the same member (must be called with lock held)
+func (n *Node) queueLocked(u Member) {
+	for i, b := range n.broadcasts {
+		if b.update.ID == u.ID {
+			n.broadcasts[i] = &broadcast{update: u}
+			return
+		}
+	}
+	n.broadcasts = append(n.broadcasts, &broadcast{update: u})
+}
+
+// outgoing builds a message carrying piggybacked updates
+func (n *Node) outgoing(kind MessageKind, target Member) Message {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.seq++
+	return Message{
+		Kind:    kind,
+		Seq:     n.seq,
+		From:    n.config.ID,
+		Target:  target,
+		Updates: n.piggybackLocked(),
+		Ring:    n.ringView,
+	}
+}
+
+// reply builds an ack carrying piggybacked updates
+func (n *Node) reply(kind MessageKind, seq uint64) Message {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return Message{
+		Kind:    kind,
+		Seq:     seq,
+		From:    n.config.ID,
+		Updates: n.piggybackLocked(),
+		Ring:    n.ringView,
+	}
+}
+
+// piggybackLocked selects the least-transmitted updates, always including
+// the local node so peers learn of it (must be called with lock held)
+func (n *Node) piggybackLocked() []Member {
+	limit := n.config.RetransmitMult * int(math.Ceil(math.Log2(float64(len(n.members)+2))))
+
+	sort.SliceStable(n.broadcasts, func(i, j int) bool {
+		return n.broadcasts[i].transmits < n.broadcasts[j].transmits
+	})
+
+	updates := []Member{n.self}
+	kept := n.broadcasts[:0]
+	for _, b := range n.broadcasts {
+		if len(updates) < n.config.MaxPiggyback && b.update.ID != n.self.ID {
+			updates = append(updates, b.update)
+			b.transmits++
+		}
+		if b.transmits < limit {
+			kept = append(kept, b)
+		}
+	}
+	n.broadcasts = kept
+
+	return updates
+}
+
+// fullState returns every member for a join-time sync
+func (n *Node) fullState() []Member {
+	return n.Members()
+}
+
+// liveMembersLocked returns remote members not known to be dead or gone
+// (must be called with lock held)
+func (n *Node) liveMembersLocked() []Member {
+	members := make([]Member, 0, len(n.members))
+	for _, m := range n.members {
+		if m.State == StateAlive || m.State == StateSuspect {
+			members = append(members, m.Member)
+		}
+	}
+	return members
+}
+
+// sampleLocked returns up to k members chosen at random
+// (must be called with lock held; the rng is not safe for concurrent use)
+func (n *Node) sampleLocked(members []Member, k int) []Member {
+	n.rng.Shuffle(len(members), func(i, j int) { members[i], members[j] = members[j], members[i] })
+	if len(members) > k {
+		members = members[:k]
+	}
+	return members
+}
+
+// notify calls change listeners
+func (n *Node) notify(m Member) {
+	n.mu.Lock()
+	listeners := append([]func(Member){}, n.listeners...)
+	n.mu.Unlock()
+
+	for _, fn := range listeners {
+		fn(m)
+	}
+}
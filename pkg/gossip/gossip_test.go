@@ -0,0 +1,245 @@
+package gossip
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestCluster starts n nodes on an in-memory network, all joined via node-0
+func newTestCluster(t *testing.T, n int) (*MemoryNetwork, []*Node) {
+	t.Helper()
+
+	network := NewMemoryNetwork()
+	nodes := make([]*Node, n)
+	for i := 0; i < n; i++ {
+		nodes[i] = New(Config{
+			ID:               fmt.Sprintf("node-%d", i),
+			Address:          fmt.Sprintf("mem:%d", i),
+			ProtocolPeriod:   20 * time.Millisecond,
+			PingTimeout:      10 * time.Millisecond,
+			SuspicionTimeout: 100 * time.Millisecond,
+		}, network)
+		network.Register(nodes[i].config.Address, nodes[i])
+	}
+
+	for i := 1; i < n; i++ {
+		if err := nodes[i].Join([]string{"mem:0"}); err != nil {
+			t.Fatalf("node-%d failed to join: %v", i, err)
+		}
+	}
+	for _, node := range nodes {
+		node.Start()
+	}
+
+	t.Cleanup(func() {
+		for _, node := range nodes {
+			node.Stop()
+		}
+	})
+	return network, nodes
+}
+
+// waitFor polls cond until it holds or the timeout passes
+func waitFor(t *testing.T, timeout time.Duration, cond func() bool) bool {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return cond()
+}
+
+// stateOf returns how node sees the member with the given ID
+func stateOf(node *Node, id string) (State, bool) {
+	for _, m := range node.Members() {
+		if m.ID == id {
+			return m.State, true
+		}
+	}
+	return StateDead, false
+}
+
+func TestSupersedes(t *testing.T) {
+	alive := Member{ID: "a", State: StateAlive, Incarnation: 1}
+
+	tests := []struct {
+		name   string
+		update Member
+		want   bool
+	}{
+		{"suspect same incarnation", Member{ID: "a", State: StateSuspect, Incarnation: 1}, true},
+		{"suspect older incarnation", Member{ID: "a", State: StateSuspect, Incarnation: 0}, false},
+		{"alive same incarnation", Member{ID: "a", State: StateAlive, Incarnation: 1}, false},
+		{"alive newer incarnation", Member{ID: "a", State: StateAlive, Incarnation: 2}, true},
+		{"dead same incarnation", Member{ID: "a", State: StateDead, Incarnation: 1}, true},
+	}
+
+	for _, tt := range tests {
+		if got := supersedes(tt.update, alive); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestJoinConverges(t *testing.T) {
+	_, nodes := newTestCluster(t, 5)
+
+	converged := waitFor(t, 2*time.Second, func() bool {
+		for _, node := range nodes {
+			members := node.Members()
+			if len(members) != 5 {
+				return false
+			}
+			for _, m := range members {
+				if m.State != StateAlive {
+					return false
+				}
+			}
+		}
+		return true
+	})
+	if !converged {
+		t.Fatalf("Cluster did not converge: node-4 sees %v", nodes[4].Members())
+	}
+}
+
+func TestFailureDetection(t *testing.T) {
+	network, nodes := newTestCluster(t, 4)
+
+	waitFor(t, 2*time.Second, func() bool { return len(nodes[3].Members()) == 4 })
+
+	// Crash node-2
+	network.SetDown("mem:2", true)
+	nodes[2].Stop()
+
+	detected := waitFor(t, 3*time.Second, func() bool {
+		for i, node := range nodes {
+			if i == 2 {
+				continue
+			}
+			if state, _ := stateOf(node, "node-2"); state != StateDead {
+				return false
+			}
+		}
+		return true
+	})
+	if !detected {
+		state, _ := stateOf(nodes[0], "node-2")
+		t.Fatalf("Expected node-2 to be declared dead everywhere, node-0 sees %s", state)
+	}
+}
+
+func TestSuspicionLevel(t *testing.T) {
+	network, nodes := newTestCluster(t, 3)
+
+	waitFor(t, 2*time.Second, func() bool { return len(nodes[0].Suspicions()) == 2 })
+
+	if got := nodes[0].Suspicion("node-1"); got >= nodes[0].config.DeadThreshold {
+		t.Errorf("Expected live node-1 below the eviction threshold, got %f", got)
+	}
+
+	network.SetDown("mem:1", true)
+	nodes[1].Stop()
+
+	rising := waitFor(t, 2*time.Second, func() bool {
+		return nodes[0].Suspicion("node-1") >= 8
+	})
+	if !rising {
+		t.Errorf("Expected node-1's suspicion to rise after it went quiet, got %f", nodes[0].Suspicion("node-1"))
+	}
+	if got := nodes[0].Suspicion("node-2"); got >= 8 {
+		t.Errorf("Expected live node-2 to stay below 8, got %f", got)
+	}
+}
+
+func TestRefuteSuspicion(t *testing.T) {
+	_, nodes := newTestCluster(t, 3)
+
+	waitFor(t, 2*time.Second, func() bool { return len(nodes[1].Members()) == 3 })
+
+	// Tell node-1 that node-0 suspects it
+	self := nodes[1].Self()
+	nodes[1].Handle(context.Background(), Message{
+		Kind:    KindPing,
+		From:    "node-0",
+		Updates: []Member{{ID: self.ID, Address: self.Address, State: StateSuspect, Incarnation: self.Incarnation}},
+	})
+
+	if got := nodes[1].Self().Incarnation; got != self.Incarnation+1 {
+		t.Fatalf("Expected incarnation %d after refutation, got %d", self.Incarnation+1, got)
+	}
+
+	// The refutation must reach the others and keep node-1 alive
+	alive := waitFor(t, 2*time.Second, func() bool {
+		for _, i := range []int{0, 2} {
+			for _, m := range nodes[i].Members() {
+				if m.ID == "node-1" && (m.State != StateAlive || m.Incarnation < self.Incarnation+1) {
+					return false
+				}
+			}
+		}
+		return true
+	})
+	if !alive {
+		t.Error("Expected refutation to propagate to all members")
+	}
+}
+
+func TestRingViewMismatch(t *testing.T) {
+	_, nodes := newTestCluster(t, 3)
+
+	waitFor(t, 2*time.Second, func() bool { return len(nodes[2].Members()) == 3 })
+
+	old := RingView{Epoch: 1, Digest: "aaaa"}
+	for _, node := range nodes {
+		node.SetRingView(old)
+	}
+
+	var mu sync.Mutex
+	seen := make(map[string]RingView)
+	nodes[2].OnRingView(func(from Member, view RingView) {
+		mu.Lock()
+		seen[from.ID] = view
+		mu.Unlock()
+	})
+	newer := RingView{Epoch: 2, Digest: "bbbb"}
+	nodes[0].SetRingView(newer)
+
+	noticed := waitFor(t, 2*time.Second, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return seen["node-0"] == newer
+	})
+	if !noticed {
+		t.Fatal("Expected node-2 to notice node-0's newer ring view")
+	}
+
+	mu.Lock()
+	if view, ok := seen["node-1"]; ok {
+		t.Errorf("Expected no mismatch from node-1 sharing node-2's view, got %+v", view)
+	}
+	mu.Unlock()
+}
+
+func TestLeave(t *testing.T) {
+	_, nodes := newTestCluster(t, 3)
+
+	waitFor(t, 2*time.Second, func() bool { return len(nodes[0].Members()) == 3 })
+
+	nodes[2].Leave()
+
+	left := waitFor(t, 2*time.Second, func() bool {
+		state0, _ := stateOf(nodes[0], "node-2")
+		state1, _ := stateOf(nodes[1], "node-2")
+		return state0 == StateLeft && state1 == StateLeft
+	})
+	if !left {
+		t.Error("Expected node-2 to be marked LEFT by remaining members")
+	}
+}
@@ -0,0 +1,164 @@
+package gossip
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	pb "github.com/sh4shv4t/DistriChat/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// GRPCTransport sends gossip messages over the GossipService RPC,
+// caching one connection per peer address
+type GRPCTransport struct {
+	mu    sync.Mutex
+	conns map[string]*grpc.ClientConn
+	opts  []grpc.DialOption
+}
+
+// NewGRPCTransport creates a gRPC-backed transport, dialing peers with
+// opts (insecure credentials unless opts set others)
+func NewGRPCTransport(opts ...grpc.DialOption) *GRPCTransport {
+	return &GRPCTransport{
+		conns: make(map[string]*grpc.ClientConn),
+		opts:  append([]grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}, opts...),
+	}
+}
+
+// Send delivers msg to the GossipService at address
+func (t *GRPCTransport) Send(ctx context.Context, address string, msg Message) (Message, error) {
+	conn, err := t.conn(address)
+	if err != nil {
+		return Message{}, err
+	}
+
+	reply, err := pb.NewGossipServiceClient(conn).Exchange(ctx, MessageToProto(msg))
+	if err != nil {
+		return Message{}, fmt.Errorf("%w: %v", ErrUnreachable, err)
+	}
+	return MessageFromProto(reply), nil
+}
+
+// Close closes all cached connections
+func (t *GRPCTransport) Close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for addr, conn := range t.conns {
+		conn.Close()
+		delete(t.conns, addr)
+	}
+}
+
+// conn returns a cached (lazily dialed) connection to address
+func (t *GRPCTransport) conn(address string) (*grpc.ClientConn, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if conn, ok := t.conns[address]; ok {
+		return conn, nil
+	}
+
+	conn, err := grpc.Dial(address, t.opts...)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnreachable, err)
+	}
+	t.conns[address] = conn
+	return conn, nil
+}
+
+// GRPCService exposes a Node as the gRPC GossipService
+type GRPCService struct {
+	pb.UnimplementedGossipServiceServer
+
+	node *Node
+}
+
+// NewGRPCService wraps a node for registration on a gRPC server
+func NewGRPCService(node *Node) *GRPCService {
+	return &GRPCService{node: node}
+}
+
+// Exchange delivers one protocol message to the node
+func (s *GRPCService) Exchange(ctx context.Context, req *pb.GossipMessage) (*pb.GossipMessage, error) {
+	reply, err := s.node.Handle(ctx, MessageFromProto(req))
+	if err != nil {
+		return nil, err
+	}
+	return MessageToProto(reply), nil
+}
+
+// Members returns the node's membership view
+func (s *GRPCService) Members(ctx context.Context, req *pb.MembersRequest) (*pb.MembersResponse, error) {
+	members := s.node.Members()
+	resp := &pb.MembersResponse{
+		Members:   make([]*pb.GossipMember, 0, len(members)),
+		Suspicion: s.node.Suspicions(),
+	}
+	view := s.node.RingView()
+	resp.RingEpoch = view.Epoch
+	resp.RingDigest = view.Digest
+	for _, m := range members {
+		resp.Members = append(resp.Members, MemberToProto(m))
+	}
+	return resp, nil
+}
+
+// MemberToProto converts a member to its wire form
+func MemberToProto(m Member) *pb.GossipMember {
+	return &pb.GossipMember{
+		Id:          m.ID,
+		Address:     m.Address,
+		State:       pb.MemberState(m.State),
+		Incarnation: m.Incarnation,
+	}
+}
+
+// MemberFromProto converts a wire member
+func MemberFromProto(m *pb.GossipMember) Member {
+	return Member{
+		ID:          m.GetId(),
+		Address:     m.GetAddress(),
+		State:       State(m.GetState()),
+		Incarnation: m.GetIncarnation(),
+	}
+}
+
+// MessageToProto converts a message to its wire form
+func MessageToProto(msg Message) *pb.GossipMessage {
+	out := &pb.GossipMessage{
+		Kind:       pb.GossipKind(msg.Kind),
+		Seq:        msg.Seq,
+		From:       msg.From,
+		Updates:    make([]*pb.GossipMember, 0, len(msg.Updates)),
+		RingEpoch:  msg.Ring.Epoch,
+		RingDigest: msg.Ring.Digest,
+	}
+	if msg.Target.ID != "" || msg.Target.Address != "" {
+		out.Target = MemberToProto(msg.Target)
+	}
+	for _, u := range msg.Updates {
+		out.Updates = append(out.Updates, MemberToProto(u))
+	}
+	return out
+}
+
+// MessageFromProto converts a wire message
+func MessageFromProto(msg *pb.GossipMessage) Message {
+	out := Message{
+		Kind:    MessageKind(msg.GetKind()),
+		Seq:     msg.GetSeq(),
+		From:    msg.GetFrom(),
+		Updates: make([]Member, 0, len(msg.GetUpdates())),
+		Ring:    RingView{Epoch: msg.GetRingEpoch(), Digest: msg.GetRingDigest()},
+	}
+	if msg.GetTarget() != nil {
+		out.Target = MemberFromProto(msg.GetTarget())
+	}
+	for _, u := range msg.GetUpdates() {
+		out.Updates = append(out.Updates, MemberFromProto(u))
+	}
+	return out
+}
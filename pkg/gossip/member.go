@@ -0,0 +1,105 @@
+package gossip
+
+// State is a member's liveness as seen by the cluster
+type State int
+
+const (
+	StateAlive   State = iota // Responding to probes
+	StateSuspect              // Missed a probe; dead unless it refutes in time
+	StateDead                 // Confirmed failed
+	StateLeft                 // Left the cluster voluntarily
+)
+
+func (s State) String() string {
+	switch s {
+	case StateAlive:
+		return "ALIVE"
+	case StateSuspect:
+		return "SUSPECT"
+	case StateDead:
+		return "DEAD"
+	case StateLeft:
+		return "LEFT"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// Member is a node's identity and liveness. It is also the unit of
+// dissemination: every state change travels as a Member update.
+type Member struct {
+	ID          string
+	Address     string
+	State       State
+	Incarnation uint64 // Bumped only by the member itself to refute suspicion
+}
+
+// RingView summarizes a node's hash ring view. Every message carries the
+// sender's, so nodes holding different rings notice within a few protocol
+// periods.
+type RingView struct {
+	Epoch  uint64
+	Digest string
+}
+
+// IsZero reports whether the view is unset
+func (v RingView) IsZero() bool {
+	return v.Epoch == 0 && v.Digest == ""
+}
+
+// MessageKind identifies a protocol message
+type MessageKind int
+
+const (
+	KindPing    MessageKind = iota // Direct probe
+	KindAck                        // Probe response
+	KindPingReq                    // Ask a peer to probe Target on our behalf
+	KindSync                       // Full state exchange used when joining
+)
+
+func (k MessageKind) String() string {
+	switch k {
+	case KindPing:
+		return "PING"
+	case KindAck:
+		return "ACK"
+	case KindPingReq:
+		return "PING_REQ"
+	case KindSync:
+		return "SYNC"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// Message is exchanged between nodes. Updates piggyback membership changes
+// on every probe so dissemination costs no extra messages.
+type Message struct {
+	Kind    MessageKind
+	Seq     uint64
+	From    string
+	Target  Member // Probe target for KindPingReq
+	Updates []Member
+	Ring    RingView // Sender's ring view (zero if it has none)
+}
+
+// supersedes reports whether update u should replace the current view c of
+// the same member, following SWIM's incarnation ordering rules
+func supersedes(u, c Member) bool {
+	switch u.State {
+	case StateAlive:
+		return u.Incarnation > c.Incarnation
+	case StateSuspect:
+		if c.State == StateAlive {
+			return u.Incarnation >= c.Incarnation
+		}
+		return c.State == StateSuspect && u.Incarnation > c.Incarnation
+	case StateDead, StateLeft:
+		if c.State == StateDead || c.State == StateLeft {
+			return false
+		}
+		return u.Incarnation >= c.Incarnation
+	default:
+		return false
+	}
+}
@@ -0,0 +1,57 @@
+package gossip
+
+import (
+	"context"
+	"sync"
+)
+
+// Handler processes an incoming gossip message and returns the reply
+type Handler interface {
+	Handle(ctx context.Context, msg Message) (Message, error)
+}
+
+// MemoryNetwork is an in-process transport connecting nodes by address.
+// Nodes can be taken down to simulate crashes without real sockets.
+type MemoryNetwork struct {
+	mu       sync.RWMutex
+	handlers map[string]Handler
+	down     map[string]bool
+}
+
+// NewMemoryNetwork creates an empty in-process network
+func NewMemoryNetwork() *MemoryNetwork {
+	return &MemoryNetwork{
+		handlers: make(map[string]Handler),
+		down:     make(map[string]bool),
+	}
+}
+
+// Register attaches a handler (usually a *Node) at address
+func (mn *MemoryNetwork) Register(address string, h Handler) {
+	mn.mu.Lock()
+	defer mn.mu.Unlock()
+	mn.handlers[address] = h
+}
+
+// SetDown makes address unreachable (true) or reachable again (false)
+func (mn *MemoryNetwork) SetDown(address string, down bool) {
+	mn.mu.Lock()
+	defer mn.mu.Unlock()
+	mn.down[address] = down
+}
+
+// Send delivers msg to the handler at address
+func (mn *MemoryNetwork) Send(ctx context.Context, address string, msg Message) (Message, error) {
+	mn.mu.RLock()
+	h, ok := mn.handlers[address]
+	down := mn.down[address]
+	mn.mu.RUnlock()
+
+	if !ok || down {
+		return Message{}, ErrUnreachable
+	}
+	if err := ctx.Err(); err != nil {
+		return Message{}, err
+	}
+	return h.Handle(ctx, msg)
+}
@@ -0,0 +1,164 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Reader reads an export one record at a time. Read returns io.EOF after
+// the last record; a record missing its chat or sender is an error.
+type Reader interface {
+	Read() (Record, error)
+}
+
+// Formats are the export formats NewReader reads, by name
+var Formats = []string{"jsonl", "csv"}
+
+// NewReader reads an export in the named format:
+//
+//   - jsonl: one JSON object per line, {"chat", "sender", "timestamp",
+//     "text"}; blank lines are skipped
+//   - csv: a header row naming the columns chat, sender, timestamp and
+//     text, in any order, then one row per message
+//
+// Timestamps are RFC 3339 strings or Unix seconds.
+func NewReader(format string, r io.Reader) (Reader, error) {
+	switch format {
+	case "jsonl":
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(nil, 1<<20)
+		return &jsonLinesReader{scanner: scanner}, nil
+	case "csv":
+		return newCSVReader(r)
+	default:
+		return nil, fmt.Errorf("unknown export format %q (expected one of %s)", format, strings.Join(Formats, ", "))
+	}
+}
+
+// ReadAll reads every record of an export
+func ReadAll(r Reader) ([]Record, error) {
+	var records []Record
+	for {
+		rec, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return records, nil
+		}
+		if err != nil {
+			return records, err
+		}
+		records = append(records, rec)
+	}
+}
+
+// jsonLinesReader reads the jsonl format
+type jsonLinesReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func (r *jsonLinesReader) Read() (Record, error) {
+	for r.scanner.Scan() {
+		r.line++
+		line := bytes.TrimSpace(r.scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var raw struct {
+			Chat      string          `json:"chat"`
+			Sender    string          `json:"sender"`
+			Timestamp json.RawMessage `json:"timestamp"`
+			Text      string          `json:"text"`
+		}
+		if err := json.Unmarshal(line, &raw); err != nil {
+			return Record{}, fmt.Errorf("line %d: %w", r.line, err)
+		}
+		ts, err := parseTimestamp(strings.Trim(string(raw.Timestamp), `"`))
+		if err != nil {
+			return Record{}, fmt.Errorf("line %d: %w", r.line, err)
+		}
+		rec, err := newRecord(raw.Chat, raw.Sender, ts, raw.Text)
+		if err != nil {
+			return Record{}, fmt.Errorf("line %d: %w", r.line, err)
+		}
+		return rec, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return Record{}, fmt.Errorf("line %d: %w", r.line+1, err)
+	}
+	return Record{}, io.EOF
+}
+
+// csvReader reads the csv format
+type csvReader struct {
+	csv     *csv.Reader
+	columns map[string]int
+}
+
+func newCSVReader(r io.Reader) (*csvReader, error) {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("header: %w", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"chat", "sender", "timestamp", "text"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("header: no %s column", name)
+		}
+	}
+	return &csvReader{csv: reader, columns: columns}, nil
+}
+
+func (r *csvReader) Read() (Record, error) {
+	row, err := r.csv.Read()
+	if err != nil {
+		return Record{}, err // io.EOF, or a parse error naming the line
+	}
+	line, _ := r.csv.FieldPos(0)
+	ts, err := parseTimestamp(row[r.columns["timestamp"]])
+	if err != nil {
+		return Record{}, fmt.Errorf("line %d: %w", line, err)
+	}
+	rec, err := newRecord(row[r.columns["chat"]], row[r.columns["sender"]], ts, row[r.columns["text"]])
+	if err != nil {
+		return Record{}, fmt.Errorf("line %d: %w", line, err)
+	}
+	return rec, nil
+}
+
+// newRecord checks and builds a record
+func newRecord(chatID, senderID string, ts time.Time, text string) (Record, error) {
+	if chatID == "" {
+		return Record{}, fmt.Errorf("chat is required")
+	}
+	if senderID == "" {
+		return Record{}, fmt.Errorf("sender is required")
+	}
+	return Record{ChatID: chatID, SenderID: senderID, Timestamp: ts, Text: text}, nil
+}
+
+// parseTimestamp reads an RFC 3339 time or Unix seconds
+func parseTimestamp(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, fmt.Errorf("timestamp is required")
+	}
+	if secs, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(secs, 0).UTC(), nil
+	}
+	ts, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("timestamp %q is neither RFC 3339 nor Unix seconds", s)
+	}
+	return ts, nil
+}
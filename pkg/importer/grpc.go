@@ -0,0 +1,212 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sh4shv4t/DistriChat/pkg/ring"
+	pb "github.com/sh4shv4t/DistriChat/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// Config sets where an Importer sends sessions
+type Config struct {
+	// Namespace the chats are imported into ("" is the default namespace)
+	Namespace string
+
+	// Replicas is the servers' replication factor: each chat goes to this
+	// many servers in every region (default: 1, the servers' default)
+	Replicas int
+
+	// BatchSize caps the messages sent in one snapshot, so a long chat
+	// goes as several that stay under gRPC's message size limit
+	// (default: 500)
+	BatchSize int
+}
+
+// Result summarizes an import
+type Result struct {
+	Sessions int   // Chats imported
+	Servers  int   // Servers that took their chats
+	Messages int64 // Messages new to the servers, counting each replica's copy
+
+	// Refused lists the chats, sorted, that a server refused as already
+	// written to: a fresh session's numbering would collide with theirs
+	Refused []string
+
+	// Failed holds the servers whose import failed, by ID, and why. Their
+	// copies of their chats are incomplete until the import is rerun.
+	Failed map[string]error
+}
+
+// Importer sends imported sessions to the servers replicating them
+type Importer struct {
+	config Config
+
+	mu    sync.Mutex
+	conns map[string]*grpc.ClientConn
+	opts  []grpc.DialOption
+}
+
+// New creates an importer, dialing servers with opts (insecure credentials
+// unless opts set others)
+func New(config Config, opts ...grpc.DialOption) *Importer {
+	if config.Replicas <= 0 {
+		config.Replicas = 1
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 500
+	}
+	return &Importer{
+		config: config,
+		conns:  make(map[string]*grpc.ClientConn),
+		opts:   append([]grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}, opts...),
+	}
+}
+
+// Import places every session on the ring described by state and streams
+// it to each of its replicas, one stream per server, all servers at once.
+// Servers skip messages they already hold, so an import that failed on
+// some servers can simply be rerun, as long as its chats weren't written
+// to since. Import fails if any server did, or refused a chat, after the
+// others finished.
+func (im *Importer) Import(ctx context.Context, state ring.RingState, sessions []*pb.SessionSnapshot) (Result, error) {
+	result := Result{Failed: make(map[string]error)}
+	placement := ring.NewHashRing(0)
+	for _, node := range state.InNamespace(im.config.Namespace).Nodes {
+		placement.AddNodeInNamespace(node.NodeID, node.Capacity, node.Address, node.Region, node.Namespace)
+	}
+	if placement.GetNodeCount() == 0 {
+		return result, fmt.Errorf("no servers in namespace %q", im.config.Namespace)
+	}
+
+	addresses := make(map[string]string)
+	batches := make(map[string][]*pb.SessionSnapshot)
+	for _, session := range sessions {
+		for _, replicas := range placement.Place(session.ChatId, im.config.Replicas).Replicas {
+			for _, node := range replicas {
+				addresses[node.NodeID] = node.Address
+				batches[node.NodeID] = append(batches[node.NodeID], im.split(session)...)
+			}
+		}
+		result.Sessions++
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for nodeID, snapshots := range batches {
+		wg.Add(1)
+		go func(nodeID string, snapshots []*pb.SessionSnapshot) {
+			defer wg.Done()
+			resp, err := im.send(ctx, addresses[nodeID], snapshots)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				result.Failed[nodeID] = err
+				return
+			}
+			result.Servers++
+			result.Messages += int64(resp.Messages)
+			for _, chatID := range resp.Refused {
+				if !slices.Contains(result.Refused, chatID) {
+					result.Refused = append(result.Refused, chatID)
+				}
+			}
+		}(nodeID, snapshots)
+	}
+	wg.Wait()
+	sort.Strings(result.Refused)
+
+	if len(result.Failed) > 0 {
+		failed := make([]string, 0, len(result.Failed))
+		for nodeID := range result.Failed {
+			failed = append(failed, nodeID)
+		}
+		sort.Strings(failed)
+		errs := make([]error, 0, len(failed))
+		for _, nodeID := range failed {
+			errs = append(errs, fmt.Errorf("%s: %w", nodeID, result.Failed[nodeID]))
+		}
+		return result, fmt.Errorf("import failed on %d of %d servers: %w", len(failed), len(batches), errors.Join(errs...))
+	}
+	if len(result.Refused) > 0 {
+		return result, fmt.Errorf("%d chats already written to weren't imported: %s",
+			len(result.Refused), strings.Join(result.Refused, ", "))
+	}
+	return result, nil
+}
+
+// Close closes all cached connections
+func (im *Importer) Close() {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+
+	for address, conn := range im.conns {
+		conn.Close()
+		delete(im.conns, address)
+	}
+}
+
+// split cuts a session into snapshots of at most BatchSize messages. Only
+// the last carries the version vector, which covers them all.
+func (im *Importer) split(session *pb.SessionSnapshot) []*pb.SessionSnapshot {
+	if len(session.Messages) <= im.config.BatchSize {
+		return []*pb.SessionSnapshot{session}
+	}
+	var snapshots []*pb.SessionSnapshot
+	for start := 0; start < len(session.Messages); start += im.config.BatchSize {
+		end := min(start+im.config.BatchSize, len(session.Messages))
+		snapshot := &pb.SessionSnapshot{ChatId: session.ChatId, Messages: session.Messages[start:end], Fresh: session.Fresh}
+		if end == len(session.Messages) {
+			snapshot.Version = session.Version
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots
+}
+
+// send streams snapshots to the server at address, returning its summary
+func (im *Importer) send(ctx context.Context, address string, snapshots []*pb.SessionSnapshot) (*pb.ImportSessionResponse, error) {
+	conn, err := im.conn(address)
+	if err != nil {
+		return nil, err
+	}
+	stream, err := pb.NewMigrationServiceClient(conn).ImportSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, snapshot := range snapshots {
+		if err := stream.Send(snapshot); err != nil {
+			// The server's error, if it ended the stream, comes with the close
+			if _, closeErr := stream.CloseAndRecv(); closeErr != nil {
+				return nil, closeErr
+			}
+			return nil, err
+		}
+	}
+	return stream.CloseAndRecv()
+}
+
+// conn returns a cached (lazily dialed) connection to address
+func (im *Importer) conn(address string) (*grpc.ClientConn, error) {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+
+	if conn, ok := im.conns[address]; ok {
+		return conn, nil
+	}
+
+	conn, err := grpc.Dial(address, im.opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", address, err)
+	}
+	im.conns[address] = conn
+	return conn, nil
+}
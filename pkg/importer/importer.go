@@ -0,0 +1,99 @@
+// Package importer migrates history exported from other chat systems into
+// a cluster. An export is read as records, one per message (see Reader),
+// and Sessions groups them into chats. Each chat's messages are ordered by
+// time and given an ID, sequence number and hybrid timestamp derived from
+// the export alone, so importing the same export again stores nothing
+// twice and every replica orders the messages the same way. An Importer
+// then sends each chat to the servers that replicate it, in bulk, through
+// the MigrationService's ImportSession that rebalancing uses.
+//
+// Sequence numbers start at 1 in every chat, so chats can only be imported
+// before they are written to: sessions are sent as fresh, and servers
+// refuse those whose chat holds messages other than the import's (see
+// Result.Refused).
+package importer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strconv"
+	"time"
+
+	pb "github.com/sh4shv4t/DistriChat/proto"
+)
+
+// Origin stands in for the server that accepted an imported message, in
+// its version vector entry
+const Origin = "import"
+
+// Record is one message of an export
+type Record struct {
+	ChatID    string
+	SenderID  string
+	Timestamp time.Time
+	Text      string
+}
+
+// Sessions groups records into one session per chat, in order of each
+// chat's first record. A chat's messages are ordered by timestamp, those
+// with equal timestamps keeping their order in the export.
+func Sessions(records []Record) []*pb.SessionSnapshot {
+	var order []string
+	byChat := make(map[string][]Record)
+	for _, rec := range records {
+		if _, ok := byChat[rec.ChatID]; !ok {
+			order = append(order, rec.ChatID)
+		}
+		byChat[rec.ChatID] = append(byChat[rec.ChatID], rec)
+	}
+
+	sessions := make([]*pb.SessionSnapshot, 0, len(order))
+	for _, chatID := range order {
+		chat := byChat[chatID]
+		sort.SliceStable(chat, func(i, j int) bool {
+			return chat[i].Timestamp.Before(chat[j].Timestamp)
+		})
+
+		snapshot := &pb.SessionSnapshot{ChatId: chatID, Messages: make([]*pb.StoredMessage, 0, len(chat)), Fresh: true}
+		seen := make(map[string]int)
+		for i, rec := range chat {
+			seq := uint64(i + 1)
+			snapshot.Messages = append(snapshot.Messages, &pb.StoredMessage{
+				MessageId: messageID(rec, seen),
+				Seq:       seq,
+				Request: &pb.ChatRequest{
+					ChatId:    chatID,
+					SenderId:  rec.SenderID,
+					Timestamp: rec.Timestamp.Unix(),
+					Content:   &pb.ChatRequest_Text{Text: rec.Text},
+				},
+				Hlc:     &pb.HybridTimestamp{WallTime: rec.Timestamp.UnixNano()},
+				Origin:  Origin,
+				Counter: seq,
+			})
+		}
+		snapshot.Version = map[string]uint64{Origin: uint64(len(chat))}
+		sessions = append(sessions, snapshot)
+	}
+	return sessions
+}
+
+// messageID derives a record's message ID from its content, counting
+// records identical to it seen earlier in its chat so each gets its own
+func messageID(rec Record, seen map[string]int) string {
+	h := sha256.New()
+	for _, field := range []string{rec.ChatID, rec.SenderID, strconv.FormatInt(rec.Timestamp.UnixNano(), 10), rec.Text} {
+		h.Write([]byte(field))
+		h.Write([]byte{0})
+	}
+	sum := string(h.Sum(nil))
+	n := seen[sum]
+	seen[sum]++
+
+	id := "import-" + hex.EncodeToString([]byte(sum[:12]))
+	if n > 0 {
+		id += "-" + strconv.Itoa(n)
+	}
+	return id
+}
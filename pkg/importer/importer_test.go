@@ -0,0 +1,107 @@
+package importer
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	pb "github.com/sh4shv4t/DistriChat/proto"
+)
+
+func TestReaders(t *testing.T) {
+	jsonl := `{"chat": "general", "sender": "alice", "timestamp": "2024-01-01T10:00:00Z", "text": "hi"}
+
+{"chat": "general", "sender": "bob", "timestamp": 1704103260, "text": "hello"}
+`
+	csv := "sender,chat,text,timestamp\n" +
+		"alice,general,hi,2024-01-01T10:00:00Z\n" +
+		"bob,general,\"hello, alice\",1704103260\n"
+
+	for format, export := range map[string]string{"jsonl": jsonl, "csv": csv} {
+		r, err := NewReader(format, strings.NewReader(export))
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		records, err := ReadAll(r)
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if len(records) != 2 || records[0].SenderID != "alice" || records[1].ChatID != "general" ||
+			!records[1].Timestamp.Equal(time.Date(2024, 1, 1, 10, 1, 0, 0, time.UTC)) {
+			t.Errorf("%s: unexpected records %+v", format, records)
+		}
+	}
+
+	r, _ := NewReader("jsonl", strings.NewReader(`{"chat": "general", "sender": "alice", "timestamp": 1}`+"\n"+`{"chat": "general", "timestamp": 2}`))
+	if _, err := ReadAll(r); err == nil || !strings.Contains(err.Error(), "line 2: sender is required") {
+		t.Errorf("Expected a record without a sender refused, got %v", err)
+	}
+	if _, err := NewReader("csv", strings.NewReader("chat,sender,text\n")); err == nil {
+		t.Error("Expected a header without timestamps refused")
+	}
+	if _, err := NewReader("xml", strings.NewReader("")); err == nil {
+		t.Error("Expected an unknown format refused")
+	}
+}
+
+func TestSessions(t *testing.T) {
+	at := func(minute int) time.Time { return time.Date(2024, 1, 1, 10, minute, 0, 0, time.UTC) }
+	records := []Record{
+		{ChatID: "general", SenderID: "bob", Timestamp: at(2), Text: "second"},
+		{ChatID: "random", SenderID: "carol", Timestamp: at(0), Text: "elsewhere"},
+		{ChatID: "general", SenderID: "alice", Timestamp: at(1), Text: "first"},
+		{ChatID: "general", SenderID: "bob", Timestamp: at(2), Text: "second"},
+	}
+	sessions := Sessions(records)
+	if len(sessions) != 2 || sessions[0].ChatId != "general" || sessions[1].ChatId != "random" {
+		t.Fatalf("Expected general then random, got %v", sessions)
+	}
+
+	general := sessions[0].Messages
+	texts := []string{general[0].Request.GetText(), general[1].Request.GetText(), general[2].Request.GetText()}
+	if texts[0] != "first" || texts[1] != "second" || texts[2] != "second" {
+		t.Errorf("Expected messages ordered by time, got %v", texts)
+	}
+	for i, msg := range general {
+		if msg.Seq != uint64(i+1) || msg.Origin != Origin || msg.Counter != msg.Seq {
+			t.Errorf("Expected message %d numbered in order, got seq %d, %s %d", i, msg.Seq, msg.Origin, msg.Counter)
+		}
+	}
+	if general[1].MessageId == general[2].MessageId {
+		t.Errorf("Expected identical records given distinct IDs, both got %s", general[1].MessageId)
+	}
+	if !sessions[0].Fresh {
+		t.Error("Expected imported sessions marked fresh")
+	}
+	if sessions[0].Version[Origin] != 3 {
+		t.Errorf("Expected the version to cover 3 messages, got %v", sessions[0].Version)
+	}
+
+	// The same export yields the same messages
+	again := Sessions(records)
+	for i, msg := range again[0].Messages {
+		if msg.MessageId != general[i].MessageId {
+			t.Errorf("Expected message %d to keep ID %s, got %s", i, general[i].MessageId, msg.MessageId)
+		}
+	}
+}
+
+func TestSplit(t *testing.T) {
+	im := New(Config{BatchSize: 2})
+	defer im.Close()
+	session := &pb.SessionSnapshot{ChatId: "general", Version: map[string]uint64{Origin: 5}, Fresh: true}
+	for i := 0; i < 5; i++ {
+		session.Messages = append(session.Messages, &pb.StoredMessage{Seq: uint64(i + 1)})
+	}
+
+	snapshots := im.split(session)
+	if len(snapshots) != 3 || len(snapshots[2].Messages) != 1 || snapshots[2].Messages[0].Seq != 5 {
+		t.Fatalf("Expected 2+2+1 messages, got %v", snapshots)
+	}
+	if !snapshots[0].Fresh || !snapshots[2].Fresh {
+		t.Error("Expected every snapshot to stay fresh")
+	}
+	if snapshots[0].Version != nil || snapshots[2].Version[Origin] != 5 {
+		t.Errorf("Expected only the last snapshot to carry the version, got %v and %v", snapshots[0].Version, snapshots[2].Version)
+	}
+}
@@ -0,0 +1,121 @@
+package logging
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EnvLevel names the environment variable Setup takes the level from
+const EnvLevel = "LOG_LEVEL"
+
+// level is the minimum level of the handler installed by Setup. It can be
+// changed at runtime, e.g. to debug on one server during an incident.
+var level = new(slog.LevelVar)
+
+// Pending revert of a temporary level, and the level toggled away from
+var (
+	levelMu   sync.Mutex
+	revert    *time.Timer
+	revertAt  time.Time
+	base      slog.Level // Level restored by the pending revert
+	toggledTo bool       // Whether ToggleDebug switched to debug
+	toggled   slog.Level // Level ToggleDebug switched away from
+)
+
+// ParseLevel parses a level name: debug, info, warn or error, optionally
+// with an offset such as "debug-4"
+func ParseLevel(name string) (slog.Level, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(strings.TrimSpace(name))); err != nil {
+		return 0, fmt.Errorf("invalid log level %q: %w", name, err)
+	}
+	return l, nil
+}
+
+// Level returns the level records are logged at through Setup's handler
+func Level() slog.Level {
+	return level.Level()
+}
+
+// RevertsAt returns when a temporary level set by SetLevelFor ends, or
+// the zero time if the level is permanent
+func RevertsAt() time.Time {
+	levelMu.Lock()
+	defer levelMu.Unlock()
+	return revertAt
+}
+
+// SetLevel changes the level, cancelling any pending revert
+func SetLevel(l slog.Level) {
+	levelMu.Lock()
+	defer levelMu.Unlock()
+
+	cancelRevertLocked()
+	toggledTo = false
+	level.Set(l)
+}
+
+// SetLevelFor changes the level for d, then restores the level in effect
+// before the first of any overlapping temporary changes. d <= 0 is the
+// same as SetLevel. Returns when the level reverts.
+func SetLevelFor(l slog.Level, d time.Duration) time.Time {
+	if d <= 0 {
+		SetLevel(l)
+		return time.Time{}
+	}
+
+	levelMu.Lock()
+	defer levelMu.Unlock()
+
+	if revert == nil {
+		base = level.Level()
+	}
+	cancelRevertLocked()
+	level.Set(l)
+
+	restore := base
+	revertAt = time.Now().Add(d)
+	var timer *time.Timer
+	timer = time.AfterFunc(d, func() {
+		levelMu.Lock()
+		defer levelMu.Unlock()
+		if revert != timer {
+			return // Replaced since
+		}
+		revert, revertAt = nil, time.Time{}
+		level.Set(restore)
+		slog.Warn("Temporary log level expired", "level", restore.String())
+	})
+	revert = timer
+	return revertAt
+}
+
+// ToggleDebug switches to debug, or back to the level it switched from if
+// it's already on, and returns the new level
+func ToggleDebug() slog.Level {
+	levelMu.Lock()
+	defer levelMu.Unlock()
+
+	cancelRevertLocked()
+	if toggledTo {
+		toggledTo = false
+		level.Set(toggled)
+	} else {
+		toggledTo = true
+		toggled = level.Level()
+		level.Set(slog.LevelDebug)
+	}
+	return level.Level()
+}
+
+// cancelRevertLocked drops a pending revert (must be called with levelMu
+// held)
+func cancelRevertLocked() {
+	if revert != nil {
+		revert.Stop()
+		revert, revertAt = nil, time.Time{}
+	}
+}
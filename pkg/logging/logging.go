@@ -0,0 +1,177 @@
+// Package logging sets DistriChat up for structured logging with log/slog.
+// Setup installs a JSON handler as slog's default, so every component's
+// records (and anything still written through the log package) come out
+// as one JSON object per line that a log pipeline can ingest.
+//
+// Components tag records with the field names below rather than spelling
+// them out, so a chat or node can be followed across every component's
+// logs. Records logged with a context also carry the attributes attached
+// to it by With, and the trace and span IDs of its OpenTelemetry span.
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Field names shared by every component
+const (
+	KeyComponent = "component"
+	KeyServerID  = "server_id"
+	KeyChatID    = "chat_id"
+	KeyNodeID    = "node_id"
+	KeyEpoch     = "epoch"
+	KeyError     = "error"
+	KeyRequestID = "request_id"
+	KeyTraceID   = "trace_id"
+	KeySpanID    = "span_id"
+)
+
+// Component returns the attribute naming the component that logged
+func Component(name string) slog.Attr { return slog.String(KeyComponent, name) }
+
+// ServerID returns the attribute naming the server a record is about
+func ServerID(id string) slog.Attr { return slog.String(KeyServerID, id) }
+
+// ChatID returns the attribute naming the chat a record is about
+func ChatID(id string) slog.Attr { return slog.String(KeyChatID, id) }
+
+// NodeID returns the attribute naming the ring node (or peer) a record is
+// about
+func NodeID(id string) slog.Attr { return slog.String(KeyNodeID, id) }
+
+// Epoch returns the attribute holding a ring epoch
+func Epoch(epoch uint64) slog.Attr { return slog.Uint64(KeyEpoch, epoch) }
+
+// RequestID returns the attribute holding the ID of the request a record
+// was logged while handling
+func RequestID(id string) slog.Attr { return slog.String(KeyRequestID, id) }
+
+// Err returns the attribute holding an error
+func Err(err error) slog.Attr { return slog.Any(KeyError, err) }
+
+// Config contains configuration for log output
+type Config struct {
+	// Where records are written (default: stderr)
+	Output io.Writer
+
+	// Minimum level written (default: info). With Setup, LOG_LEVEL
+	// overrides it and SetLevel changes it at runtime.
+	Level slog.Level
+
+	// "json" (default) or "text", the latter for reading logs in a
+	// terminal
+	Format string
+}
+
+// Setup makes a handler for config slog's default and returns the logger
+// using it. Components take their logger from slog's default when they
+// are created, so call Setup first. Rings and caches log only through the
+// logger they are given (see Nop).
+//
+// The handler's level is the package's runtime level: config.Level, or
+// LOG_LEVEL if that is set (e.g. LOG_LEVEL=debug).
+func Setup(config Config) *slog.Logger {
+	var invalid error
+	if name := os.Getenv(EnvLevel); name != "" {
+		if l, err := ParseLevel(name); err == nil {
+			config.Level = l
+		} else {
+			invalid = err
+		}
+	}
+	SetLevel(config.Level)
+
+	logger := slog.New(newHandler(config, level))
+	slog.SetDefault(logger)
+	if invalid != nil {
+		logger.Warn("Ignoring "+EnvLevel, Err(invalid))
+	}
+	return logger
+}
+
+// NewHandler returns a handler writing records as config says, adding the
+// attributes and trace IDs carried by each record's context
+func NewHandler(config Config) slog.Handler {
+	return newHandler(config, config.Level)
+}
+
+// newHandler returns a handler for config whose minimum level is minimum
+func newHandler(config Config, minimum slog.Leveler) slog.Handler {
+	if config.Output == nil {
+		config.Output = os.Stderr
+	}
+	options := &slog.HandlerOptions{Level: minimum}
+
+	var handler slog.Handler
+	if config.Format == "text" {
+		handler = slog.NewTextHandler(config.Output, options)
+	} else {
+		handler = slog.NewJSONHandler(config.Output, options)
+	}
+	return contextHandler{handler}
+}
+
+// Logger returns slog's default logger tagged with component
+func Logger(component string) *slog.Logger {
+	return slog.Default().With(Component(component))
+}
+
+// Nop returns a logger that discards every record: the default of the
+// library packages (ring, cache), so embedding them logs nothing unless
+// the program hands them a logger
+func Nop() *slog.Logger {
+	return slog.New(nopHandler{})
+}
+
+type nopHandler struct{}
+
+func (nopHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (nopHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h nopHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h nopHandler) WithGroup(string) slog.Handler           { return h }
+
+type attrsKey struct{}
+
+// With returns a copy of ctx whose records, when logged through a handler
+// from NewHandler, carry attrs as well as those already attached
+func With(ctx context.Context, attrs ...slog.Attr) context.Context {
+	existing, _ := ctx.Value(attrsKey{}).([]slog.Attr)
+	combined := make([]slog.Attr, 0, len(existing)+len(attrs))
+	combined = append(combined, existing...)
+	combined = append(combined, attrs...)
+	return context.WithValue(ctx, attrsKey{}, combined)
+}
+
+// contextHandler adds the attributes attached by With and the current
+// span's IDs to each record
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if ctx != nil {
+		if attrs, ok := ctx.Value(attrsKey{}).([]slog.Attr); ok {
+			record.AddAttrs(attrs...)
+		}
+		if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+			record.AddAttrs(
+				slog.String(KeyTraceID, span.TraceID().String()),
+				slog.String(KeySpanID, span.SpanID().String()),
+			)
+		}
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,173 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// decode parses the single JSON record in buf
+func decode(t *testing.T, buf *bytes.Buffer) map[string]any {
+	t.Helper()
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("Expected one JSON record, got %q: %v", buf.String(), err)
+	}
+	return record
+}
+
+func TestJSONRecordFields(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewHandler(Config{Output: &buf})).With(Component("server"), ServerID("s1"))
+
+	logger.Info("Ring view updated", Epoch(7), NodeID("s2"))
+
+	record := decode(t, &buf)
+	for key, want := range map[string]any{
+		"msg":        "Ring view updated",
+		"level":      "INFO",
+		KeyComponent: "server",
+		KeyServerID:  "s1",
+		KeyNodeID:    "s2",
+		KeyEpoch:     float64(7),
+	} {
+		if record[key] != want {
+			t.Errorf("Expected %s=%v, got %v", key, want, record[key])
+		}
+	}
+}
+
+func TestContextAttrs(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewHandler(Config{Output: &buf}))
+
+	ctx := With(context.Background(), ChatID("chat-1"))
+	ctx = With(ctx, slog.String("sender_id", "alice"))
+	ctx = trace.ContextWithSpanContext(ctx, trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{1},
+		SpanID:  trace.SpanID{2},
+	}))
+
+	logger.InfoContext(ctx, "Received message")
+
+	record := decode(t, &buf)
+	if record[KeyChatID] != "chat-1" || record["sender_id"] != "alice" {
+		t.Errorf("Expected the context's attributes, got %v", record)
+	}
+	if record[KeyTraceID] != "01000000000000000000000000000000" {
+		t.Errorf("Expected the span's trace ID, got %v", record[KeyTraceID])
+	}
+	if record[KeySpanID] != "0200000000000000" {
+		t.Errorf("Expected the span's ID, got %v", record[KeySpanID])
+	}
+}
+
+func TestLevelAndTextFormat(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewHandler(Config{Output: &buf, Level: slog.LevelWarn, Format: "text"}))
+
+	logger.Info("Hidden")
+	if buf.Len() != 0 {
+		t.Errorf("Expected records below the level dropped, got %q", buf.String())
+	}
+
+	logger.Warn("Shown", ChatID("chat-1"))
+	if out := buf.String(); !strings.Contains(out, "msg=Shown") || !strings.Contains(out, "chat_id=chat-1") {
+		t.Errorf("Expected a text record, got %q", out)
+	}
+}
+
+func TestRuntimeLevel(t *testing.T) {
+	var buf bytes.Buffer
+	defer SetLevel(slog.LevelInfo)
+	t.Setenv(EnvLevel, "warn")
+	logger := Setup(Config{Output: &buf})
+	defer slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	logger.Info("Hidden")
+	if buf.Len() != 0 {
+		t.Errorf("Expected LOG_LEVEL=warn to hide info records, got %q", buf.String())
+	}
+
+	SetLevel(slog.LevelDebug)
+	logger.Debug("Shown")
+	if !strings.Contains(buf.String(), "Shown") {
+		t.Errorf("Expected debug records after SetLevel, got %q", buf.String())
+	}
+
+	if l := ToggleDebug(); l != slog.LevelDebug {
+		t.Errorf("Expected toggling from debug to stay at debug, got %v", l)
+	}
+	SetLevel(slog.LevelInfo)
+	if l := ToggleDebug(); l != slog.LevelDebug {
+		t.Errorf("Expected the toggle to switch to debug, got %v", l)
+	}
+	if l := ToggleDebug(); l != slog.LevelInfo {
+		t.Errorf("Expected the second toggle to restore info, got %v", l)
+	}
+}
+
+func TestSetLevelForReverts(t *testing.T) {
+	defer SetLevel(slog.LevelInfo)
+	SetLevel(slog.LevelWarn)
+
+	SetLevelFor(slog.LevelDebug, 20*time.Millisecond)
+	SetLevelFor(slog.LevelInfo, 20*time.Millisecond) // Reverts to warn, not debug
+	if Level() != slog.LevelInfo || RevertsAt().IsZero() {
+		t.Errorf("Expected a temporary info level, got %v until %v", Level(), RevertsAt())
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for Level() != slog.LevelWarn && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if Level() != slog.LevelWarn {
+		t.Errorf("Expected the level to revert to warn, got %v", Level())
+	}
+	if !RevertsAt().IsZero() {
+		t.Errorf("Expected no pending revert, got %v", RevertsAt())
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	var buf bytes.Buffer
+	recorder := NewRecorder(2)
+	logger := slog.New(recorder.Wrap(NewHandler(Config{Output: &buf, Level: slog.LevelError}))).With(ServerID("s1"))
+
+	ctx := With(context.Background(), ChatID("chat-1"))
+	logger.InfoContext(ctx, "Ignored")
+	logger.WarnContext(ctx, "Replica unreachable", NodeID("s2"))
+	logger.Error("Write failed", Err(io.ErrUnexpectedEOF))
+	logger.Error("Quorum lost")
+
+	records := recorder.Records()
+	if len(records) != 2 || records[0].Message != "Write failed" || records[1].Message != "Quorum lost" {
+		t.Fatalf("Expected the last two warnings or errors, oldest first, got %+v", records)
+	}
+	if got := records[0].Attrs[KeyError]; got != io.ErrUnexpectedEOF.Error() {
+		t.Errorf("Expected the error attribute, got %q", got)
+	}
+	if got := records[0].Attrs[KeyServerID]; got != "s1" {
+		t.Errorf("Expected the logger's attributes kept, got %q", got)
+	}
+
+	// Below the handler's level, the warning was kept but not written
+	if strings.Contains(buf.String(), "Replica unreachable") {
+		t.Errorf("Expected the warning filtered from the output, got %q", buf.String())
+	}
+
+	recorder = NewRecorder(5)
+	logger = slog.New(recorder.Wrap(NewHandler(Config{Output: io.Discard})))
+	logger.WarnContext(ctx, "Replica unreachable", NodeID("s2"))
+	attrs := recorder.Records()[0].Attrs
+	if attrs[KeyChatID] != "chat-1" || attrs[KeyNodeID] != "s2" {
+		t.Errorf("Expected the context's and the record's attributes, got %v", attrs)
+	}
+}
@@ -0,0 +1,113 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Recorded is a warning or error kept by a Recorder
+type Recorded struct {
+	Time    time.Time
+	Level   slog.Level
+	Message string
+	Attrs   map[string]string // Including those attached by With and Logger.With
+}
+
+// Recorder keeps the most recent warnings and errors logged through the
+// handlers it wraps, for showing a process's recent trouble on demand
+// (e.g. in a server's debug dump) without searching its logs
+type Recorder struct {
+	mu      sync.Mutex
+	records []Recorded // Circular once full; next is the oldest
+	next    int
+}
+
+// NewRecorder creates a recorder keeping the last capacity records (at
+// least one)
+func NewRecorder(capacity int) *Recorder {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &Recorder{records: make([]Recorded, 0, capacity)}
+}
+
+// Wrap returns a handler passing records to h and keeping those at warn
+// or above, whether or not h's level lets them through
+func (r *Recorder) Wrap(h slog.Handler) slog.Handler {
+	return &recordingHandler{Handler: h, recorder: r}
+}
+
+// Records returns the kept records, oldest first
+func (r *Recorder) Records() []Recorded {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	out := make([]Recorded, 0, len(r.records))
+	out = append(out, r.records[r.next:]...)
+	return append(out, r.records[:r.next]...)
+}
+
+// add keeps rec, dropping the oldest record if full
+func (r *Recorder) add(rec Recorded) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.records) < cap(r.records) {
+		r.records = append(r.records, rec)
+		return
+	}
+	r.records[r.next] = rec
+	r.next = (r.next + 1) % len(r.records)
+}
+
+// recordingHandler hands warnings and errors to its recorder on their way
+// to the wrapped handler
+type recordingHandler struct {
+	slog.Handler
+	recorder *Recorder
+	attrs    []slog.Attr // Added with WithAttrs
+}
+
+func (h *recordingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= slog.LevelWarn || h.Handler.Enabled(ctx, level)
+}
+
+func (h *recordingHandler) Handle(ctx context.Context, record slog.Record) error {
+	if record.Level >= slog.LevelWarn {
+		attrs := make(map[string]string)
+		add := func(a slog.Attr) bool {
+			attrs[a.Key] = a.Value.String()
+			return true
+		}
+		for _, a := range h.attrs {
+			add(a)
+		}
+		if ctx != nil {
+			if ctxAttrs, ok := ctx.Value(attrsKey{}).([]slog.Attr); ok {
+				for _, a := range ctxAttrs {
+					add(a)
+				}
+			}
+		}
+		record.Attrs(add)
+		h.recorder.add(Recorded{Time: record.Time, Level: record.Level, Message: record.Message, Attrs: attrs})
+	}
+
+	if !h.Handler.Enabled(ctx, record.Level) {
+		return nil
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *recordingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	combined := make([]slog.Attr, 0, len(h.attrs)+len(attrs))
+	combined = append(combined, h.attrs...)
+	combined = append(combined, attrs...)
+	return &recordingHandler{Handler: h.Handler.WithAttrs(attrs), recorder: h.recorder, attrs: combined}
+}
+
+func (h *recordingHandler) WithGroup(name string) slog.Handler {
+	return &recordingHandler{Handler: h.Handler.WithGroup(name), recorder: h.recorder, attrs: h.attrs}
+}
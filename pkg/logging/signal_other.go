@@ -0,0 +1,9 @@
+//go:build !unix
+
+package logging
+
+// HandleSignals does nothing on platforms without SIGUSR1; use SetLevel
+// or the admin API instead
+func HandleSignals() (stop func()) {
+	return func() {}
+}
@@ -0,0 +1,35 @@
+//go:build unix
+
+package logging
+
+import (
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// HandleSignals toggles debug logging on each SIGUSR1 (see ToggleDebug)
+// until the returned function is called
+func HandleSignals() (stop func()) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1)
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case <-signals:
+				l := ToggleDebug()
+				slog.Warn("Log level toggled by SIGUSR1", "level", l.String())
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
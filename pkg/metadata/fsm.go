@@ -0,0 +1,169 @@
+package metadata
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/hashicorp/raft"
+	"github.com/sh4shv4t/DistriChat/pkg/ring"
+)
+
+// commandType identifies a replicated membership change
+type commandType int
+
+const (
+	commandAddNode    commandType = iota // Add a node or replace its spec
+	commandRemoveNode                    // Remove a node
+	commandSetWeight                     // Change a node's capacity
+)
+
+// command is the payload of one Raft log entry
+type command struct {
+	Type      commandType `json:"type"`
+	NodeID    string      `json:"node_id"`
+	Address   string      `json:"address,omitempty"`
+	Capacity  int         `json:"capacity,omitempty"`
+	Region    string      `json:"region,omitempty"`
+	Namespace string      `json:"namespace,omitempty"`
+}
+
+// fsm is the replicated ring membership. Every applied change bumps the
+// epoch, so all replicas derive the same epoch for the same membership.
+type fsm struct {
+	mu    sync.RWMutex
+	epoch uint64
+	nodes map[string]ring.NodeSpec
+
+	// Called after each applied change, outside the lock
+	onChange func(ring.RingState)
+}
+
+func newFSM() *fsm {
+	return &fsm{nodes: make(map[string]ring.NodeSpec)}
+}
+
+// Apply applies one committed log entry
+func (f *fsm) Apply(entry *raft.Log) interface{} {
+	var cmd command
+	if err := json.Unmarshal(entry.Data, &cmd); err != nil {
+		return fmt.Errorf("failed to decode command: %w", err)
+	}
+
+	f.mu.Lock()
+	changed, err := f.applyLocked(cmd)
+	if err != nil || !changed {
+		f.mu.Unlock()
+		return err
+	}
+	f.epoch++
+	state := f.stateLocked()
+	onChange := f.onChange
+	f.mu.Unlock()
+
+	if onChange != nil {
+		onChange(state)
+	}
+	return nil
+}
+
+// applyLocked mutates the membership and reports whether anything changed
+func (f *fsm) applyLocked(cmd command) (bool, error) {
+	switch cmd.Type {
+	case commandAddNode:
+		spec := ring.NodeSpec{NodeID: cmd.NodeID, Address: cmd.Address, Capacity: cmd.Capacity, Region: cmd.Region, Namespace: cmd.Namespace}
+		if current, ok := f.nodes[cmd.NodeID]; ok && current == spec {
+			return false, nil
+		}
+		f.nodes[cmd.NodeID] = spec
+		return true, nil
+
+	case commandRemoveNode:
+		if _, ok := f.nodes[cmd.NodeID]; !ok {
+			return false, nil
+		}
+		delete(f.nodes, cmd.NodeID)
+		return true, nil
+
+	case commandSetWeight:
+		spec, ok := f.nodes[cmd.NodeID]
+		if !ok {
+			return false, fmt.Errorf("%w: %s", ErrUnknownNode, cmd.NodeID)
+		}
+		if spec.Capacity == cmd.Capacity {
+			return false, nil
+		}
+		spec.Capacity = cmd.Capacity
+		f.nodes[cmd.NodeID] = spec
+		return true, nil
+
+	default:
+		return false, fmt.Errorf("unknown command type %d", cmd.Type)
+	}
+}
+
+// state returns the current membership
+func (f *fsm) state() ring.RingState {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.stateLocked()
+}
+
+func (f *fsm) stateLocked() ring.RingState {
+	state := ring.RingState{Epoch: f.epoch, Nodes: make([]ring.NodeSpec, 0, len(f.nodes))}
+	for _, spec := range f.nodes {
+		state.Nodes = append(state.Nodes, spec)
+	}
+	sort.Slice(state.Nodes, func(i, j int) bool {
+		return state.Nodes[i].NodeID < state.Nodes[j].NodeID
+	})
+	return state
+}
+
+// Snapshot captures the membership for log compaction
+func (f *fsm) Snapshot() (raft.FSMSnapshot, error) {
+	return &snapshot{state: f.state()}, nil
+}
+
+// Restore replaces the membership with a snapshot
+func (f *fsm) Restore(rc io.ReadCloser) error {
+	defer rc.Close()
+
+	var state ring.RingState
+	if err := json.NewDecoder(rc).Decode(&state); err != nil {
+		return fmt.Errorf("failed to decode snapshot: %w", err)
+	}
+
+	f.mu.Lock()
+	f.epoch = state.Epoch
+	f.nodes = make(map[string]ring.NodeSpec, len(state.Nodes))
+	for _, spec := range state.Nodes {
+		f.nodes[spec.NodeID] = spec
+	}
+	onChange := f.onChange
+	f.mu.Unlock()
+
+	if onChange != nil {
+		onChange(state)
+	}
+	return nil
+}
+
+// snapshot is a point-in-time copy of the membership
+type snapshot struct {
+	state ring.RingState
+}
+
+// Persist writes the snapshot as JSON
+func (s *snapshot) Persist(sink raft.SnapshotSink) error {
+	if err := json.NewEncoder(sink).Encode(s.state); err != nil {
+		sink.Cancel()
+		return err
+	}
+	return sink.Close()
+}
+
+// Release is a no-op; the snapshot holds no resources
+func (s *snapshot) Release() {}
@@ -0,0 +1,292 @@
+// Package metadata keeps cluster membership in an embedded Raft group.
+// Ring membership, node weights and the ring epoch are changed only through
+// the replicated log, so every replica agrees on them, they survive restarts
+// and the loss of any minority of nodes, and two views can never fork.
+package metadata
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/raft"
+	raftboltdb "github.com/hashicorp/raft-boltdb/v2"
+	"github.com/sh4shv4t/DistriChat/pkg/ring"
+)
+
+var (
+	// ErrNotLeader is returned when a change is proposed to a follower
+	ErrNotLeader = errors.New("not the metadata leader")
+
+	// ErrUnknownNode is returned when changing a node that isn't in the ring
+	ErrUnknownNode = errors.New("unknown node")
+)
+
+// Config contains configuration for a metadata store replica
+type Config struct {
+	NodeID      string // Raft server ID (normally the chat server ID)
+	RaftAddress string // Host:port for Raft traffic, distinct from the chat port
+
+	// DataDir holds the Raft log and snapshots. Empty keeps everything in
+	// memory, which is only suitable for tests and demos.
+	DataDir string
+
+	// Bootstrap forms a new single-voter cluster on first start. Set it on
+	// exactly one node; the others are added with Join.
+	Bootstrap bool
+
+	ApplyTimeout time.Duration // How long a proposal may take (default: 5s)
+}
+
+// Store is one replica of the cluster metadata
+type Store struct {
+	config    Config
+	raft      *raft.Raft
+	fsm       *fsm
+	transport raft.Transport
+	closers   []func() error
+
+	// Latest leadership flag for this replica (capacity 1, latest wins)
+	leaderCh chan bool
+}
+
+// Open starts a replica, recovering any state found in config.DataDir
+func Open(config Config) (*Store, error) {
+	addr, err := net.ResolveTCPAddr("tcp", config.RaftAddress)
+	if err != nil {
+		return nil, fmt.Errorf("invalid raft address %q: %w", config.RaftAddress, err)
+	}
+	transport, err := raft.NewTCPTransport(config.RaftAddress, addr, 3, 10*time.Second, os.Stderr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", config.RaftAddress, err)
+	}
+
+	store, err := open(config, transport)
+	if err != nil {
+		transport.Close()
+		return nil, err
+	}
+	store.closers = append(store.closers, transport.Close)
+	return store, nil
+}
+
+// open starts a replica on the given transport
+func open(config Config, transport raft.Transport) (*Store, error) {
+	if config.ApplyTimeout <= 0 {
+		config.ApplyTimeout = 5 * time.Second
+	}
+
+	raftConfig := raft.DefaultConfig()
+	raftConfig.LocalID = raft.ServerID(config.NodeID)
+	raftConfig.Logger = hclog.New(&hclog.LoggerOptions{
+		Name:       "metadata:" + config.NodeID,
+		Level:      hclog.Warn,
+		JSONFormat: true, // Alongside the slog records of other components
+	})
+
+	store := &Store{
+		config:    config,
+		fsm:       newFSM(),
+		transport: transport,
+		leaderCh:  make(chan bool, 1),
+	}
+	raftConfig.NotifyCh = store.leaderCh
+
+	var (
+		logs   raft.LogStore
+		stable raft.StableStore
+		snaps  raft.SnapshotStore
+	)
+	if config.DataDir == "" {
+		inmem := raft.NewInmemStore()
+		logs, stable = inmem, inmem
+		snaps = raft.NewInmemSnapshotStore()
+	} else {
+		if err := os.MkdirAll(config.DataDir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create data dir: %w", err)
+		}
+		bolt, err := raftboltdb.NewBoltStore(filepath.Join(config.DataDir, "raft.db"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to open raft log: %w", err)
+		}
+		store.closers = append(store.closers, bolt.Close)
+		logs, stable = bolt, bolt
+
+		snaps, err = raft.NewFileSnapshotStore(config.DataDir, 2, os.Stderr)
+		if err != nil {
+			bolt.Close()
+			return nil, fmt.Errorf("failed to open snapshot store: %w", err)
+		}
+	}
+
+	if config.Bootstrap {
+		hasState, err := raft.HasExistingState(logs, stable, snaps)
+		if err != nil {
+			store.close()
+			return nil, err
+		}
+		if !hasState {
+			bootstrap := raft.Configuration{Servers: []raft.Server{{
+				ID:      raftConfig.LocalID,
+				Address: transport.LocalAddr(),
+			}}}
+			if err := raft.BootstrapCluster(raftConfig, logs, stable, snaps, transport, bootstrap); err != nil {
+				store.close()
+				return nil, fmt.Errorf("failed to bootstrap: %w", err)
+			}
+		}
+	}
+
+	r, err := raft.NewRaft(raftConfig, store.fsm, logs, stable, snaps, transport)
+	if err != nil {
+		store.close()
+		return nil, fmt.Errorf("failed to start raft: %w", err)
+	}
+	store.raft = r
+
+	return store, nil
+}
+
+// Close shuts the replica down. Other replicas keep serving as long as a
+// majority remains.
+func (s *Store) Close() error {
+	err := s.raft.Shutdown().Error()
+	if cerr := s.close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// close releases the log store and transport
+func (s *Store) close() error {
+	var err error
+	for i := len(s.closers) - 1; i >= 0; i-- {
+		if cerr := s.closers[i](); err == nil {
+			err = cerr
+		}
+	}
+	s.closers = nil
+	return err
+}
+
+// OnChange registers fn to be called with the new membership after every
+// applied change, on every replica. Set it before changes are proposed.
+func (s *Store) OnChange(fn func(ring.RingState)) {
+	s.fsm.mu.Lock()
+	defer s.fsm.mu.Unlock()
+	s.fsm.onChange = fn
+}
+
+// State returns this replica's view of the membership. Followers may lag
+// the leader briefly but never diverge from it.
+func (s *Store) State() ring.RingState {
+	return s.fsm.state()
+}
+
+// IsLeader reports whether this replica currently accepts proposals
+func (s *Store) IsLeader() bool {
+	return s.raft.State() == raft.Leader
+}
+
+// LeadershipChanges delivers true when this replica becomes leader and false
+// when it steps down. Only the latest transition is buffered, so the channel
+// must have a single consumer (see pkg/election).
+func (s *Store) LeadershipChanges() <-chan bool {
+	return s.leaderCh
+}
+
+// Leader returns the Raft address and ID of the current leader, if known
+func (s *Store) Leader() (address string, id string) {
+	addr, serverID := s.raft.LeaderWithID()
+	return string(addr), string(serverID)
+}
+
+// WaitForLeader blocks until the group has elected a leader or the timeout
+// passes
+func (s *Store) WaitForLeader(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if addr, _ := s.raft.LeaderWithID(); addr != "" {
+			return nil
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	return fmt.Errorf("no metadata leader after %v", timeout)
+}
+
+// Join adds a replica to the Raft group as a voter. Must be called on the
+// leader.
+func (s *Store) Join(nodeID, raftAddress string) error {
+	if !s.IsLeader() {
+		return ErrNotLeader
+	}
+	future := s.raft.AddVoter(raft.ServerID(nodeID), raft.ServerAddress(raftAddress), 0, s.config.ApplyTimeout)
+	if err := future.Error(); err != nil {
+		return fmt.Errorf("failed to add %s: %w", nodeID, err)
+	}
+	return nil
+}
+
+// Leave removes a replica from the Raft group. Must be called on the leader.
+func (s *Store) Leave(nodeID string) error {
+	if !s.IsLeader() {
+		return ErrNotLeader
+	}
+	future := s.raft.RemoveServer(raft.ServerID(nodeID), 0, s.config.ApplyTimeout)
+	if err := future.Error(); err != nil {
+		return fmt.Errorf("failed to remove %s: %w", nodeID, err)
+	}
+	return nil
+}
+
+// AddNode places a node on the ring, or replaces its address, capacity,
+// region and namespace
+func (s *Store) AddNode(spec ring.NodeSpec) error {
+	return s.propose(command{
+		Type:      commandAddNode,
+		NodeID:    spec.NodeID,
+		Address:   spec.Address,
+		Capacity:  spec.Capacity,
+		Region:    spec.Region,
+		Namespace: spec.Namespace,
+	})
+}
+
+// RemoveNode takes a node off the ring
+func (s *Store) RemoveNode(nodeID string) error {
+	return s.propose(command{Type: commandRemoveNode, NodeID: nodeID})
+}
+
+// SetWeight changes a node's capacity (virtual node count)
+func (s *Store) SetWeight(nodeID string, capacity int) error {
+	return s.propose(command{Type: commandSetWeight, NodeID: nodeID, Capacity: capacity})
+}
+
+// propose replicates cmd and waits until it is committed and applied
+func (s *Store) propose(cmd command) error {
+	if !s.IsLeader() {
+		return ErrNotLeader
+	}
+
+	data, err := json.Marshal(cmd)
+	if err != nil {
+		return err
+	}
+
+	future := s.raft.Apply(data, s.config.ApplyTimeout)
+	if err := future.Error(); err != nil {
+		if errors.Is(err, raft.ErrNotLeader) || errors.Is(err, raft.ErrLeadershipLost) {
+			return ErrNotLeader
+		}
+		return err
+	}
+	if err, ok := future.Response().(error); ok && err != nil {
+		return err
+	}
+	return nil
+}
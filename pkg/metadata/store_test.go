@@ -0,0 +1,165 @@
+package metadata
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/raft"
+	"github.com/sh4shv4t/DistriChat/pkg/ring"
+)
+
+// newTestGroup starts n in-memory replicas, bootstrapped on node-0 with the
+// rest joined as voters
+func newTestGroup(t *testing.T, n int) []*Store {
+	t.Helper()
+
+	transports := make([]*raft.InmemTransport, n)
+	for i := range transports {
+		_, transports[i] = raft.NewInmemTransport(raft.ServerAddress(fmt.Sprintf("node-%d", i)))
+	}
+	for i := range transports {
+		for j := range transports {
+			if i != j {
+				transports[i].Connect(transports[j].LocalAddr(), transports[j])
+			}
+		}
+	}
+
+	stores := make([]*Store, n)
+	for i := range stores {
+		store, err := open(Config{NodeID: fmt.Sprintf("node-%d", i), Bootstrap: i == 0}, transports[i])
+		if err != nil {
+			t.Fatalf("Failed to open node-%d: %v", i, err)
+		}
+		stores[i] = store
+	}
+	t.Cleanup(func() {
+		for _, store := range stores {
+			store.Close()
+		}
+	})
+
+	if err := stores[0].WaitForLeader(5 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i < n; i++ {
+		if err := stores[0].Join(fmt.Sprintf("node-%d", i), fmt.Sprintf("node-%d", i)); err != nil {
+			t.Fatalf("Failed to join node-%d: %v", i, err)
+		}
+	}
+	return stores
+}
+
+// waitForEpoch polls until the store has applied the given epoch
+func waitForEpoch(store *Store, epoch uint64, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if store.State().Epoch >= epoch {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return false
+}
+
+func TestReplicatedMembership(t *testing.T) {
+	stores := newTestGroup(t, 3)
+	leader := stores[0]
+
+	if err := leader.AddNode(ring.NodeSpec{NodeID: "server-a", Address: "a:1", Capacity: 100}); err != nil {
+		t.Fatalf("AddNode failed: %v", err)
+	}
+	if err := leader.AddNode(ring.NodeSpec{NodeID: "server-b", Address: "b:1", Capacity: 100}); err != nil {
+		t.Fatalf("AddNode failed: %v", err)
+	}
+	if err := leader.SetWeight("server-b", 50); err != nil {
+		t.Fatalf("SetWeight failed: %v", err)
+	}
+
+	want := leader.State()
+	if want.Epoch != 3 {
+		t.Errorf("Expected epoch 3 after three changes, got %d", want.Epoch)
+	}
+
+	for i, store := range stores {
+		if !waitForEpoch(store, want.Epoch, 2*time.Second) {
+			t.Fatalf("node-%d did not reach epoch %d", i, want.Epoch)
+		}
+		if got := store.State(); got.Digest() != want.Digest() {
+			t.Errorf("node-%d diverged: expected %v, got %v", i, want.Nodes, got.Nodes)
+		}
+	}
+}
+
+func TestNoOpChangeKeepsEpoch(t *testing.T) {
+	stores := newTestGroup(t, 1)
+	spec := ring.NodeSpec{NodeID: "server-a", Address: "a:1", Capacity: 100}
+
+	stores[0].AddNode(spec)
+	stores[0].AddNode(spec)
+	stores[0].RemoveNode("server-missing")
+
+	if epoch := stores[0].State().Epoch; epoch != 1 {
+		t.Errorf("Expected epoch 1, got %d", epoch)
+	}
+	if err := stores[0].SetWeight("server-missing", 10); !errors.Is(err, ErrUnknownNode) {
+		t.Errorf("Expected ErrUnknownNode, got %v", err)
+	}
+}
+
+func TestFollowerRejectsProposals(t *testing.T) {
+	stores := newTestGroup(t, 2)
+
+	err := stores[1].AddNode(ring.NodeSpec{NodeID: "server-a", Address: "a:1", Capacity: 100})
+	if !errors.Is(err, ErrNotLeader) {
+		t.Errorf("Expected ErrNotLeader, got %v", err)
+	}
+}
+
+func TestStateSurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+	config := Config{NodeID: "node-0", DataDir: dir, Bootstrap: true}
+
+	_, transport := raft.NewInmemTransport("node-0")
+	store, err := open(config, transport)
+	if err != nil {
+		t.Fatalf("Failed to open: %v", err)
+	}
+	if err := store.WaitForLeader(5 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.AddNode(ring.NodeSpec{NodeID: "server-a", Address: "a:1", Capacity: 100}); err != nil {
+		t.Fatalf("AddNode failed: %v", err)
+	}
+	want := store.State()
+	store.Close()
+
+	_, transport = raft.NewInmemTransport("node-0")
+	store, err = open(config, transport)
+	if err != nil {
+		t.Fatalf("Failed to reopen: %v", err)
+	}
+	defer store.Close()
+
+	if !waitForEpoch(store, want.Epoch, 5*time.Second) {
+		t.Fatalf("Expected epoch %d after restart, got %d", want.Epoch, store.State().Epoch)
+	}
+	if got := store.State(); got.Digest() != want.Digest() {
+		t.Errorf("Expected %v after restart, got %v", want.Nodes, got.Nodes)
+	}
+}
+
+func TestLeadershipChanges(t *testing.T) {
+	stores := newTestGroup(t, 1)
+
+	select {
+	case leader := <-stores[0].LeadershipChanges():
+		if !leader {
+			t.Error("Expected bootstrapped node to report leadership")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected a leadership notification")
+	}
+}
@@ -0,0 +1,98 @@
+// Package metrics is the instrumentation interface shared by the ring, the
+// cache, the client and the server. Components take a Registry and create
+// their labelled series from it once; the Prometheus implementation turns
+// them into scrapeable series, while Nop discards everything so components
+// can record unconditionally.
+//
+// Series are created by name: asking a registry twice for the same name
+// returns the same series, so several components (or several instances of
+// one) can share a registry.
+package metrics
+
+// Counter is a value that only goes up
+type Counter interface {
+	Inc()
+	Add(delta float64)
+}
+
+// Gauge is a value that goes up and down
+type Gauge interface {
+	Set(value float64)
+	Inc()
+	Dec()
+	Add(delta float64)
+}
+
+// Histogram samples observations into buckets
+type Histogram interface {
+	Observe(value float64)
+}
+
+// CounterVec is a family of counters partitioned by label values
+type CounterVec interface {
+	// With returns the counter for the given label values, in the order
+	// the labels were declared
+	With(labelValues ...string) Counter
+}
+
+// GaugeVec is a family of gauges partitioned by label values
+type GaugeVec interface {
+	With(labelValues ...string) Gauge
+}
+
+// HistogramVec is a family of histograms partitioned by label values
+type HistogramVec interface {
+	With(labelValues ...string) Histogram
+}
+
+// Registry creates series. Names should carry the "districhat_" prefix and
+// follow Prometheus conventions (_total for counters, base units).
+type Registry interface {
+	Counter(name, help string, labels ...string) CounterVec
+	Gauge(name, help string, labels ...string) GaugeVec
+	Histogram(name, help string, buckets []float64, labels ...string) HistogramVec
+
+	// GaugeFunc reports fn's result whenever the registry is read, for
+	// values the component already tracks (e.g. a cache's size). A second
+	// call with the same name replaces the function.
+	GaugeFunc(name, help string, fn func() float64)
+}
+
+// LatencyBuckets are histogram buckets in seconds suited to request
+// latencies, from 0.5ms to 10s
+var LatencyBuckets = []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// CacheBuckets are histogram buckets in seconds suited to cache operations,
+// from 1µs for in-memory hits to 1s for slow storage writes
+var CacheBuckets = []float64{.000001, .0000025, .000005, .00001, .000025, .00005, .0001, .00025, .0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1}
+
+// Nop returns a registry whose series discard every update
+func Nop() Registry {
+	return nopRegistry{}
+}
+
+type nopRegistry struct{}
+
+func (nopRegistry) Counter(string, string, ...string) CounterVec { return nopCounters{} }
+func (nopRegistry) Gauge(string, string, ...string) GaugeVec     { return nopGauges{} }
+func (nopRegistry) Histogram(string, string, []float64, ...string) HistogramVec {
+	return nopHistograms{}
+}
+func (nopRegistry) GaugeFunc(string, string, func() float64) {}
+
+type nopCounters struct{}
+type nopGauges struct{}
+type nopHistograms struct{}
+
+func (nopCounters) With(...string) Counter     { return nopMetric{} }
+func (nopGauges) With(...string) Gauge         { return nopMetric{} }
+func (nopHistograms) With(...string) Histogram { return nopMetric{} }
+
+// nopMetric serves as every kind of series
+type nopMetric struct{}
+
+func (nopMetric) Inc()            {}
+func (nopMetric) Dec()            {}
+func (nopMetric) Add(float64)     {}
+func (nopMetric) Set(float64)     {}
+func (nopMetric) Observe(float64) {}
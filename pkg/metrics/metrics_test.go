@@ -0,0 +1,84 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPrometheusSeries(t *testing.T) {
+	p := NewPrometheus(map[string]string{"server_id": "server-a"})
+
+	requests := p.Counter("districhat_test_requests_total", "Requests", "tenant")
+	requests.With("gold").Inc()
+	requests.With("gold").Add(2)
+	requests.With("").Inc()
+
+	p.Gauge("districhat_test_sessions", "Sessions", "cache_level").With("l1").Set(7)
+	p.Histogram("districhat_test_latency_seconds", "Latency", LatencyBuckets).With().Observe(0.003)
+
+	size := 4.0
+	p.GaugeFunc("districhat_test_size", "Size", func() float64 { return size })
+
+	var buf bytes.Buffer
+	if err := p.Write(&buf); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	out := buf.String()
+
+	for _, want := range []string{
+		`districhat_test_requests_total{server_id="server-a",tenant="gold"} 3`,
+		`districhat_test_requests_total{server_id="server-a",tenant=""} 1`,
+		`districhat_test_sessions{cache_level="l1",server_id="server-a"} 7`,
+		`districhat_test_latency_seconds_bucket{server_id="server-a",le="0.005"} 1`,
+		`districhat_test_latency_seconds_count{server_id="server-a"} 1`,
+		`districhat_test_size{server_id="server-a"} 4`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %q in output, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestPrometheusSharesSeriesByName(t *testing.T) {
+	p := NewPrometheus(nil)
+
+	p.Counter("districhat_test_total", "Test", "kind").With("a").Inc()
+	p.Counter("districhat_test_total", "Test", "kind").With("a").Inc()
+
+	// Replacing a gauge function must not fail on the duplicate name
+	p.GaugeFunc("districhat_test_gauge", "Test", func() float64 { return 1 })
+	p.GaugeFunc("districhat_test_gauge", "Test", func() float64 { return 2 })
+
+	rec := httptest.NewRecorder()
+	p.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	out := rec.Body.String()
+
+	if !strings.Contains(out, `districhat_test_total{kind="a"} 2`) {
+		t.Errorf("Expected both increments on one series, got:\n%s", out)
+	}
+	if !strings.Contains(out, "districhat_test_gauge 2") {
+		t.Errorf("Expected the replacement gauge function, got:\n%s", out)
+	}
+}
+
+func TestPrometheusKindMismatch(t *testing.T) {
+	p := NewPrometheus(nil)
+	p.Counter("districhat_test_total", "Test")
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected a panic when reusing a counter's name for a gauge")
+		}
+	}()
+	p.Gauge("districhat_test_total", "Test")
+}
+
+func TestNop(t *testing.T) {
+	r := Nop()
+	r.Counter("c", "c", "l").With("v").Inc()
+	r.Gauge("g", "g").With().Set(1)
+	r.Histogram("h", "h", nil).With().Observe(1)
+	r.GaugeFunc("f", "f", func() float64 { return 0 })
+}
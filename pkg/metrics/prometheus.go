@@ -0,0 +1,144 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/common/expfmt"
+)
+
+// Prometheus is a Registry backed by its own Prometheus registry. It is
+// safe for concurrent use.
+type Prometheus struct {
+	mu          sync.Mutex
+	registry    *prometheus.Registry
+	constLabels prometheus.Labels
+	collectors  map[string]prometheus.Collector
+}
+
+// NewPrometheus creates an empty registry. constLabels are added to every
+// series, e.g. {"server_id": "server-a"}.
+func NewPrometheus(constLabels map[string]string) *Prometheus {
+	return &Prometheus{
+		registry:    prometheus.NewRegistry(),
+		constLabels: constLabels,
+		collectors:  make(map[string]prometheus.Collector),
+	}
+}
+
+// Counter returns the counter family with the given name, creating it on
+// first use
+func (p *Prometheus) Counter(name, help string, labels ...string) CounterVec {
+	c := p.getOrCreate(name, func() prometheus.Collector {
+		return prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: name, Help: help, ConstLabels: p.constLabels,
+		}, labels)
+	})
+	vec, ok := c.(*prometheus.CounterVec)
+	if !ok {
+		panic(fmt.Sprintf("metrics: %s is already registered as a %T", name, c))
+	}
+	return promCounters{vec}
+}
+
+// Gauge returns the gauge family with the given name, creating it on first
+// use
+func (p *Prometheus) Gauge(name, help string, labels ...string) GaugeVec {
+	c := p.getOrCreate(name, func() prometheus.Collector {
+		return prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: name, Help: help, ConstLabels: p.constLabels,
+		}, labels)
+	})
+	vec, ok := c.(*prometheus.GaugeVec)
+	if !ok {
+		panic(fmt.Sprintf("metrics: %s is already registered as a %T", name, c))
+	}
+	return promGauges{vec}
+}
+
+// Histogram returns the histogram family with the given name, creating it
+// on first use (nil buckets use Prometheus' defaults)
+func (p *Prometheus) Histogram(name, help string, buckets []float64, labels ...string) HistogramVec {
+	c := p.getOrCreate(name, func() prometheus.Collector {
+		return prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name: name, Help: help, ConstLabels: p.constLabels, Buckets: buckets,
+		}, labels)
+	})
+	vec, ok := c.(*prometheus.HistogramVec)
+	if !ok {
+		panic(fmt.Sprintf("metrics: %s is already registered as a %T", name, c))
+	}
+	return promHistograms{vec}
+}
+
+// GaugeFunc registers fn as the value of the named gauge, replacing any
+// function registered under that name before
+func (p *Prometheus) GaugeFunc(name, help string, fn func() float64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if old, ok := p.collectors[name]; ok {
+		p.registry.Unregister(old)
+	}
+	c := prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: name, Help: help, ConstLabels: p.constLabels,
+	}, fn)
+	p.registry.MustRegister(c)
+	p.collectors[name] = c
+}
+
+// getOrCreate returns the collector registered under name, registering the
+// one create builds if there is none
+func (p *Prometheus) getOrCreate(name string, create func() prometheus.Collector) prometheus.Collector {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if c, ok := p.collectors[name]; ok {
+		return c
+	}
+	c := create()
+	p.registry.MustRegister(c)
+	p.collectors[name] = c
+	return c
+}
+
+// Handler serves the registry in the Prometheus exposition format
+func (p *Prometheus) Handler() http.Handler {
+	return promhttp.HandlerFor(p.registry, promhttp.HandlerOpts{})
+}
+
+// Write writes the registry's current values in the Prometheus text
+// format, for handlers that add series of their own
+func (p *Prometheus) Write(w io.Writer) error {
+	families, err := p.registry.Gather()
+	if err != nil {
+		return fmt.Errorf("failed to gather metrics: %w", err)
+	}
+	encoder := expfmt.NewEncoder(w, expfmt.FmtText)
+	for _, family := range families {
+		if err := encoder.Encode(family); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type promCounters struct{ vec *prometheus.CounterVec }
+type promGauges struct{ vec *prometheus.GaugeVec }
+type promHistograms struct{ vec *prometheus.HistogramVec }
+
+func (v promCounters) With(labelValues ...string) Counter {
+	return v.vec.WithLabelValues(labelValues...)
+}
+
+func (v promGauges) With(labelValues ...string) Gauge {
+	return v.vec.WithLabelValues(labelValues...)
+}
+
+func (v promHistograms) With(labelValues ...string) Histogram {
+	return v.vec.WithLabelValues(labelValues...)
+}
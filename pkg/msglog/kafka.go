@@ -0,0 +1,141 @@
+package msglog
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	pb "github.com/sh4shv4t/DistriChat/proto"
+	"google.golang.org/protobuf/proto"
+)
+
+// KafkaConfig contains configuration for a Kafka-backed log
+type KafkaConfig struct {
+	Brokers []string
+	Topic   string
+
+	// How long the writer waits to fill a batch (default: 10ms). Publish
+	// blocks at most this long before the batch is sent.
+	BatchTimeout time.Duration
+}
+
+// KafkaLog publishes messages to a Kafka topic, keyed and partitioned by
+// chat ID, so every chat's messages stay ordered within one partition
+type KafkaLog struct {
+	config KafkaConfig
+	writer *kafka.Writer
+}
+
+// NewKafkaLog creates a log writing to config.Topic. Brokers are contacted
+// lazily on the first publish or replay.
+func NewKafkaLog(config KafkaConfig) (*KafkaLog, error) {
+	if len(config.Brokers) == 0 {
+		return nil, fmt.Errorf("at least one broker is required")
+	}
+	if config.Topic == "" {
+		return nil, fmt.Errorf("topic is required")
+	}
+	if config.BatchTimeout <= 0 {
+		config.BatchTimeout = 10 * time.Millisecond
+	}
+
+	return &KafkaLog{
+		config: config,
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(config.Brokers...),
+			Topic:        config.Topic,
+			Balancer:     &kafka.CRC32Balancer{}, // Same placement as Partition
+			BatchTimeout: config.BatchTimeout,
+			RequiredAcks: kafka.RequireAll,
+		},
+	}, nil
+}
+
+// Publish appends a message and waits for all in-sync replicas to have it
+func (l *KafkaLog) Publish(ctx context.Context, msg *pb.StoredMessage) error {
+	value, err := proto.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	err = l.writer.WriteMessages(ctx, kafka.Message{
+		Key:   []byte(msg.GetRequest().GetChatId()),
+		Value: value,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to publish to %s: %w", l.config.Topic, err)
+	}
+	return nil
+}
+
+// Replay reads every partition of the topic from its first offset up to the
+// offset it had when the replay started
+func (l *KafkaLog) Replay(ctx context.Context, fn func(*pb.StoredMessage) error) error {
+	conn, err := kafka.DialContext(ctx, "tcp", l.config.Brokers[0])
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", l.config.Brokers[0], err)
+	}
+	partitions, err := conn.ReadPartitions(l.config.Topic)
+	conn.Close()
+	if err != nil {
+		return fmt.Errorf("failed to list partitions of %s: %w", l.config.Topic, err)
+	}
+
+	for _, partition := range partitions {
+		if err := l.replayPartition(ctx, partition.ID, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replayPartition reads one partition from its first to its current last offset
+func (l *KafkaLog) replayPartition(ctx context.Context, partition int, fn func(*pb.StoredMessage) error) error {
+	leader, err := kafka.DialLeader(ctx, "tcp", l.config.Brokers[0], l.config.Topic, partition)
+	if err != nil {
+		return fmt.Errorf("failed to reach leader of partition %d: %w", partition, err)
+	}
+	first, last, err := leader.ReadOffsets()
+	leader.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read offsets of partition %d: %w", partition, err)
+	}
+	if first >= last {
+		return nil // Empty
+	}
+
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:   l.config.Brokers,
+		Topic:     l.config.Topic,
+		Partition: partition,
+		MaxBytes:  10 << 20,
+	})
+	defer reader.Close()
+
+	if err := reader.SetOffset(first); err != nil {
+		return err
+	}
+
+	for offset := first; offset < last; {
+		record, err := reader.ReadMessage(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to read partition %d at offset %d: %w", partition, offset, err)
+		}
+		offset = record.Offset + 1
+
+		msg := &pb.StoredMessage{}
+		if err := proto.Unmarshal(record.Value, msg); err != nil {
+			return fmt.Errorf("corrupt record at partition %d offset %d: %w", partition, record.Offset, err)
+		}
+		if err := fn(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close flushes pending messages and closes the writer
+func (l *KafkaLog) Close() error {
+	return l.writer.Close()
+}
@@ -0,0 +1,44 @@
+// Package msglog is an external, durable log of accepted chat messages.
+// Servers append every message they accept; the log keeps one partition per
+// slice of chat IDs, so a chat's messages stay in order, and it can be
+// replayed from the beginning to rebuild a server's state or to feed
+// downstream consumers.
+//
+// Kafka is the production backend; MemoryLog serves tests and demos.
+package msglog
+
+import (
+	"context"
+	"hash/crc32"
+
+	pb "github.com/sh4shv4t/DistriChat/proto"
+)
+
+// Log is an append-only, replayable message log
+type Log interface {
+	// Publish appends a message, keyed by its chat ID
+	Publish(ctx context.Context, msg *pb.StoredMessage) error
+
+	// Replay calls fn for every message present when Replay starts, in
+	// order within each chat. Returning an error from fn stops the replay.
+	Replay(ctx context.Context, fn func(*pb.StoredMessage) error) error
+
+	Close() error
+}
+
+// Compactor is implemented by logs that can drop messages in place, for
+// retention on a backend that doesn't expire them itself (a Kafka topic
+// does, through its retention.ms)
+type Compactor interface {
+	// Compact removes the messages expired returns true for, returning how
+	// many there were
+	Compact(ctx context.Context, expired func(*pb.StoredMessage) bool) (int, error)
+}
+
+// Partition returns the partition, out of n, holding a chat's messages
+func Partition(chatID string, n int) int {
+	if n <= 1 {
+		return 0
+	}
+	return int(crc32.ChecksumIEEE([]byte(chatID)) % uint32(n))
+}
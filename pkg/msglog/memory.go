@@ -0,0 +1,113 @@
+package msglog
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	pb "github.com/sh4shv4t/DistriChat/proto"
+	"google.golang.org/protobuf/proto"
+)
+
+// ErrClosed is returned when using a closed log
+var ErrClosed = errors.New("message log is closed")
+
+// MemoryLog is an in-process Log with the same partitioning as Kafka. It is
+// shared by every server given the same instance, like a topic would be.
+type MemoryLog struct {
+	mu         sync.RWMutex
+	partitions [][]*pb.StoredMessage
+	closed     bool
+}
+
+// NewMemoryLog creates an in-memory log with the given number of partitions
+func NewMemoryLog(partitions int) *MemoryLog {
+	if partitions < 1 {
+		partitions = 1
+	}
+	return &MemoryLog{partitions: make([][]*pb.StoredMessage, partitions)}
+}
+
+// Publish appends a message to its chat's partition
+func (l *MemoryLog) Publish(ctx context.Context, msg *pb.StoredMessage) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return ErrClosed
+	}
+	p := Partition(msg.GetRequest().GetChatId(), len(l.partitions))
+	l.partitions[p] = append(l.partitions[p], proto.Clone(msg).(*pb.StoredMessage))
+	return nil
+}
+
+// Replay reads every partition from the beginning
+func (l *MemoryLog) Replay(ctx context.Context, fn func(*pb.StoredMessage) error) error {
+	// Snapshot the partition lengths so the replay ends
+	l.mu.RLock()
+	if l.closed {
+		l.mu.RUnlock()
+		return ErrClosed
+	}
+	snapshot := make([][]*pb.StoredMessage, len(l.partitions))
+	for i, partition := range l.partitions {
+		snapshot[i] = partition[:len(partition):len(partition)]
+	}
+	l.mu.RUnlock()
+
+	for _, partition := range snapshot {
+		for _, msg := range partition {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := fn(proto.Clone(msg).(*pb.StoredMessage)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Compact removes the messages expired returns true for from every
+// partition. Replays under way keep reading what was there.
+func (l *MemoryLog) Compact(ctx context.Context, expired func(*pb.StoredMessage) bool) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return 0, ErrClosed
+	}
+	removed := 0
+	for i, partition := range l.partitions {
+		kept := make([]*pb.StoredMessage, 0, len(partition))
+		for _, msg := range partition {
+			if expired(msg) {
+				continue
+			}
+			kept = append(kept, msg)
+		}
+		removed += len(partition) - len(kept)
+		l.partitions[i] = kept
+	}
+	return removed, nil
+}
+
+// Len returns the number of messages in the log
+func (l *MemoryLog) Len() int {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	n := 0
+	for _, partition := range l.partitions {
+		n += len(partition)
+	}
+	return n
+}
+
+// Close marks the log closed
+func (l *MemoryLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.closed = true
+	return nil
+}
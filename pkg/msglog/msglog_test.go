@@ -0,0 +1,131 @@
+package msglog
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	pb "github.com/sh4shv4t/DistriChat/proto"
+)
+
+func stored(chatID, id string) *pb.StoredMessage {
+	return &pb.StoredMessage{
+		MessageId: id,
+		Request:   &pb.ChatRequest{ChatId: chatID},
+	}
+}
+
+func TestPartition(t *testing.T) {
+	if p := Partition("chat-1", 1); p != 0 {
+		t.Errorf("Expected partition 0 with one partition, got %d", p)
+	}
+	for i := 0; i < 100; i++ {
+		chatID := fmt.Sprintf("chat-%d", i)
+		p := Partition(chatID, 8)
+		if p < 0 || p >= 8 {
+			t.Fatalf("Expected a partition in [0, 8), got %d", p)
+		}
+		if Partition(chatID, 8) != p {
+			t.Errorf("Expected a stable partition for %s", chatID)
+		}
+	}
+}
+
+func TestMemoryLogReplayKeepsChatOrder(t *testing.T) {
+	l := NewMemoryLog(4)
+	ctx := context.Background()
+
+	for i := 0; i < 20; i++ {
+		chatID := fmt.Sprintf("chat-%d", i%3)
+		if err := l.Publish(ctx, stored(chatID, fmt.Sprintf("%s/%d", chatID, i))); err != nil {
+			t.Fatalf("Expected publish to succeed, got %v", err)
+		}
+	}
+	if l.Len() != 20 {
+		t.Errorf("Expected 20 messages, got %d", l.Len())
+	}
+
+	last := make(map[string]int)
+	count := 0
+	err := l.Replay(ctx, func(msg *pb.StoredMessage) error {
+		var i int
+		fmt.Sscanf(msg.MessageId[len(msg.Request.ChatId)+1:], "%d", &i)
+		if prev, ok := last[msg.Request.ChatId]; ok && i <= prev {
+			t.Errorf("Expected %s in order, got %d after %d", msg.Request.ChatId, i, prev)
+		}
+		last[msg.Request.ChatId] = i
+		count++
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Expected replay to succeed, got %v", err)
+	}
+	if count != 20 {
+		t.Errorf("Expected 20 replayed messages, got %d", count)
+	}
+}
+
+func TestMemoryLogReplayStops(t *testing.T) {
+	l := NewMemoryLog(1)
+	ctx := context.Background()
+	for i := 0; i < 5; i++ {
+		l.Publish(ctx, stored("chat", fmt.Sprintf("m%d", i)))
+	}
+
+	stop := fmt.Errorf("stop")
+	count := 0
+	err := l.Replay(ctx, func(msg *pb.StoredMessage) error {
+		count++
+		if count == 2 {
+			return stop
+		}
+		return nil
+	})
+	if err != stop || count != 2 {
+		t.Errorf("Expected replay to stop after 2 messages with fn's error, got %d, %v", count, err)
+	}
+}
+
+func TestMemoryLogCompact(t *testing.T) {
+	l := NewMemoryLog(2)
+	ctx := context.Background()
+	for i := 0; i < 4; i++ {
+		for _, chatID := range []string{"old", "other"} {
+			msg := stored(chatID, fmt.Sprintf("%s/%d", chatID, i))
+			msg.Request.Timestamp = int64(i)
+			l.Publish(ctx, msg)
+		}
+	}
+
+	n, err := l.Compact(ctx, func(msg *pb.StoredMessage) bool {
+		return msg.Request.ChatId == "old" && msg.Request.Timestamp < 2
+	})
+	if err != nil || n != 2 {
+		t.Fatalf("Expected 2 messages compacted, got %d, %v", n, err)
+	}
+	var ids []string
+	l.Replay(ctx, func(msg *pb.StoredMessage) error {
+		if msg.Request.ChatId == "old" {
+			ids = append(ids, msg.MessageId)
+		}
+		return nil
+	})
+	if fmt.Sprint(ids) != "[old/2 old/3]" {
+		t.Errorf("Expected old/2 and old/3 left, got %v", ids)
+	}
+	if l.Len() != 6 {
+		t.Errorf("Expected the other chat untouched, leaving 6 messages, got %d", l.Len())
+	}
+}
+
+func TestMemoryLogClosed(t *testing.T) {
+	l := NewMemoryLog(2)
+	l.Close()
+
+	if err := l.Publish(context.Background(), stored("chat", "m1")); err != ErrClosed {
+		t.Errorf("Expected ErrClosed from publish, got %v", err)
+	}
+	if err := l.Replay(context.Background(), func(*pb.StoredMessage) error { return nil }); err != ErrClosed {
+		t.Errorf("Expected ErrClosed from replay, got %v", err)
+	}
+}
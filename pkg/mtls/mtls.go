@@ -0,0 +1,255 @@
+// Package mtls provides the credentials for mutual TLS between clients and
+// servers. A Provider holds this side's certificate and the CAs the other
+// side's certificate must chain to, loaded from PEM files, and reloads them
+// when the files change, so certificates rotate without a restart:
+// connections already open keep their session, and handshakes after the
+// change use the new files.
+//
+//	provider, err := mtls.NewProvider(mtls.Config{
+//		CertFile: "/etc/districhat/node.crt",
+//		KeyFile:  "/etc/districhat/node.key",
+//		CAFile:   "/etc/districhat/ca.crt",
+//	})
+//	srv := server.NewChatServer(config, server.WithTLS(provider))
+//
+// Servers dial each other with their own certificate, so a server's
+// certificate must allow both server and client authentication.
+package mtls
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/pkg/logging"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// DefaultCheckInterval is how often handshakes look for changed files
+// unless Config.CheckInterval says otherwise
+const DefaultCheckInterval = 10 * time.Second
+
+// Config names the files a Provider loads
+type Config struct {
+	CertFile string // This side's certificate chain, PEM
+	KeyFile  string // Its private key, PEM
+	CAFile   string // CAs the other side's certificate must chain to, PEM
+
+	// ServerName is checked against servers' certificates when dialing
+	// (default: the host dialed)
+	ServerName string
+
+	// CheckInterval is how often a handshake rereads the files to pick up
+	// rotated certificates (default: 10 seconds). Negative never does,
+	// leaving it to Reload.
+	CheckInterval time.Duration
+}
+
+// Option adjusts a Provider as NewProvider creates it
+type Option func(*Provider)
+
+// WithLogger sets the logger reloads are reported to (default: none)
+func WithLogger(logger *slog.Logger) Option {
+	return func(p *Provider) { p.log = logger }
+}
+
+// Provider serves the current certificate and CAs to TLS handshakes. It is
+// safe for concurrent use.
+type Provider struct {
+	config Config
+	log    *slog.Logger
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	roots   *x509.CertPool
+	files   [3][]byte // Contents cert and roots were parsed from
+	checked time.Time // Last time the files were read
+}
+
+// NewProvider loads config's files, failing if they don't hold a usable
+// certificate, key and CA bundle
+func NewProvider(config Config, opts ...Option) (*Provider, error) {
+	if config.CertFile == "" || config.KeyFile == "" || config.CAFile == "" {
+		return nil, errors.New("mtls: CertFile, KeyFile and CAFile are required")
+	}
+	if config.CheckInterval == 0 {
+		config.CheckInterval = DefaultCheckInterval
+	}
+
+	p := &Provider{config: config, log: logging.Nop()}
+	for _, opt := range opts {
+		opt(p)
+	}
+	if err := p.Reload(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Reload rereads the files, switching to their certificate and CAs if they
+// changed. On error, e.g. while the files are half rewritten, the provider
+// keeps what it had.
+func (p *Provider) Reload() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.reload()
+}
+
+// reload is Reload with p.mu held
+func (p *Provider) reload() error {
+	p.checked = time.Now()
+
+	var files [3][]byte
+	for i, name := range []string{p.config.CertFile, p.config.KeyFile, p.config.CAFile} {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return fmt.Errorf("mtls: %w", err)
+		}
+		files[i] = data
+	}
+	if p.cert != nil && bytes.Equal(files[0], p.files[0]) && bytes.Equal(files[1], p.files[1]) &&
+		bytes.Equal(files[2], p.files[2]) {
+		return nil
+	}
+
+	cert, err := tls.X509KeyPair(files[0], files[1])
+	if err != nil {
+		return fmt.Errorf("mtls: %s: %w", p.config.CertFile, err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return fmt.Errorf("mtls: %s: %w", p.config.CertFile, err)
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(files[2]) {
+		return fmt.Errorf("mtls: %s holds no certificates", p.config.CAFile)
+	}
+
+	first := p.cert == nil
+	p.cert, p.roots, p.files = &cert, roots, files
+	if !first {
+		p.log.Info("Reloaded TLS certificate", "subject", leaf.Subject.CommonName,
+			"not_after", leaf.NotAfter)
+	}
+	return nil
+}
+
+// current returns the certificate and CAs, first rereading the files if
+// they are due a check
+func (p *Provider) current() (*tls.Certificate, *x509.CertPool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.config.CheckInterval > 0 && time.Since(p.checked) >= p.config.CheckInterval {
+		if err := p.reload(); err != nil {
+			p.log.Warn("Keeping the current TLS certificate", logging.Err(err))
+		}
+	}
+	return p.cert, p.roots
+}
+
+// ServerConfig returns the TLS configuration of a server requiring mutual
+// TLS: each handshake presents the current certificate and requires a
+// client certificate chaining to the current CAs
+func (p *Provider) ServerConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			cert, roots := p.current()
+			return &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*cert},
+				ClientAuth:   tls.RequireAndVerifyClientCert,
+				ClientCAs:    roots,
+			}, nil
+		},
+	}
+}
+
+// ClientConfig returns the TLS configuration of a client dialing
+// serverName (the provider's ServerName, if set): it presents the current
+// certificate and requires the server's to chain to the current CAs. The
+// configuration is a snapshot; ClientCredentials takes a new one for
+// every handshake.
+func (p *Provider) ClientConfig(serverName string) *tls.Config {
+	cert, roots := p.current()
+	if p.config.ServerName != "" {
+		serverName = p.config.ServerName
+	}
+	return &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{*cert},
+		RootCAs:      roots,
+		ServerName:   serverName,
+	}
+}
+
+// ServerCredentials returns gRPC server credentials requiring mutual TLS
+func (p *Provider) ServerCredentials() credentials.TransportCredentials {
+	return credentials.NewTLS(p.ServerConfig())
+}
+
+// ClientCredentials returns gRPC dial credentials presenting the current
+// certificate at every handshake
+func (p *Provider) ClientCredentials() credentials.TransportCredentials {
+	return &clientCredentials{provider: p}
+}
+
+// clientCredentials makes a TLS handshake with the provider's certificate
+// and CAs as they are at the time of the handshake
+type clientCredentials struct {
+	provider   *Provider
+	serverName string // Set by OverrideServerName
+}
+
+func (c *clientCredentials) ClientHandshake(ctx context.Context, authority string, conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	serverName := c.serverName
+	if serverName == "" {
+		serverName = authority
+		if host, _, err := net.SplitHostPort(authority); err == nil {
+			serverName = host
+		}
+	}
+	return credentials.NewTLS(c.provider.ClientConfig(serverName)).ClientHandshake(ctx, authority, conn)
+}
+
+func (c *clientCredentials) ServerHandshake(conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	return nil, nil, errors.New("mtls: client credentials can't accept connections")
+}
+
+func (c *clientCredentials) Info() credentials.ProtocolInfo {
+	return credentials.ProtocolInfo{SecurityProtocol: "tls", SecurityVersion: "1.2"}
+}
+
+func (c *clientCredentials) Clone() credentials.TransportCredentials {
+	clone := *c
+	return &clone
+}
+
+func (c *clientCredentials) OverrideServerName(serverName string) error {
+	c.serverName = serverName
+	return nil
+}
+
+// Identity returns the common name of the verified client certificate a
+// call was made with, or "" for a call without one
+func Identity(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.VerifiedChains) == 0 || len(info.State.VerifiedChains[0]) == 0 {
+		return ""
+	}
+	return info.State.VerifiedChains[0][0].Subject.CommonName
+}
@@ -0,0 +1,257 @@
+package mtls
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// testCA signs certificates for the tests
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// write writes a certificate for name, valid for localhost as a server and
+// a client, and the CA to dir, returning the provider config naming them
+func (ca *testCA) write(t *testing.T, dir, name string) Config {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := Config{
+		CertFile: filepath.Join(dir, "node.crt"),
+		KeyFile:  filepath.Join(dir, "node.key"),
+		CAFile:   filepath.Join(dir, "ca.crt"),
+	}
+	writeFile(t, config.CertFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	writeFile(t, config.KeyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+	writeFile(t, config.CAFile, ca.pem)
+	return config
+}
+
+func writeFile(t *testing.T, name string, data []byte) {
+	t.Helper()
+	if err := os.WriteFile(name, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// serve starts a health service requiring mutual TLS from provider,
+// recording the identity of each caller
+func serve(t *testing.T, provider *Provider) (string, func() []string) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	var callers []string
+	srv := grpc.NewServer(grpc.Creds(provider.ServerCredentials()),
+		grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+			handler grpc.UnaryHandler) (interface{}, error) {
+			mu.Lock()
+			callers = append(callers, Identity(ctx))
+			mu.Unlock()
+			return handler(ctx, req)
+		}))
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+	go srv.Serve(listener)
+	t.Cleanup(srv.Stop)
+
+	return listener.Addr().String(), func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), callers...)
+	}
+}
+
+// check makes one call to address on a new connection
+func check(address string, creds credentials.TransportCredentials) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := grpc.DialContext(ctx, address, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	return err
+}
+
+func TestMutualTLS(t *testing.T) {
+	ca := newTestCA(t)
+	serverProvider, err := NewProvider(ca.write(t, t.TempDir(), "server-a"))
+	if err != nil {
+		t.Fatalf("Expected the server provider, got %v", err)
+	}
+	clientProvider, err := NewProvider(ca.write(t, t.TempDir(), "alice"))
+	if err != nil {
+		t.Fatalf("Expected the client provider, got %v", err)
+	}
+	address, callers := serve(t, serverProvider)
+
+	if err := check(address, clientProvider.ClientCredentials()); err != nil {
+		t.Fatalf("Expected a call with a client certificate to succeed, got %v", err)
+	}
+	if got := callers(); len(got) != 1 || got[0] != "alice" {
+		t.Errorf("Expected the server to see caller alice, got %v", got)
+	}
+
+	// Trusting the server isn't enough without a certificate of its own
+	noCert := credentials.NewTLS(&tls.Config{RootCAs: clientProvider.ClientConfig("").RootCAs, ServerName: "127.0.0.1"})
+	if err := check(address, noCert); err == nil {
+		t.Errorf("Expected a call without a client certificate to fail")
+	}
+
+	// A certificate from another CA is refused
+	stranger, err := NewProvider(newTestCA(t).write(t, t.TempDir(), "mallory"))
+	if err != nil {
+		t.Fatalf("Expected the stranger's provider, got %v", err)
+	}
+	if err := check(address, stranger.ClientCredentials()); err == nil {
+		t.Errorf("Expected a call with another CA's certificate to fail")
+	}
+}
+
+func TestRotation(t *testing.T) {
+	ca := newTestCA(t)
+	serverProvider, err := NewProvider(ca.write(t, t.TempDir(), "server-a"))
+	if err != nil {
+		t.Fatalf("Expected the server provider, got %v", err)
+	}
+	dir := t.TempDir()
+	config := ca.write(t, dir, "alice-1")
+	config.CheckInterval = -1
+	clientProvider, err := NewProvider(config)
+	if err != nil {
+		t.Fatalf("Expected the client provider, got %v", err)
+	}
+	address, callers := serve(t, serverProvider)
+	creds := clientProvider.ClientCredentials()
+
+	if err := check(address, creds); err != nil {
+		t.Fatalf("Expected the first call to succeed, got %v", err)
+	}
+
+	// A half-written rotation is refused and the old certificate kept
+	writeFile(t, config.CertFile, []byte("not a certificate"))
+	if err := clientProvider.Reload(); err == nil {
+		t.Errorf("Expected Reload to fail on a broken certificate")
+	}
+	if err := check(address, creds); err != nil {
+		t.Fatalf("Expected the old certificate to keep working, got %v", err)
+	}
+
+	ca.write(t, dir, "alice-2")
+	if err := clientProvider.Reload(); err != nil {
+		t.Fatalf("Expected the rotated certificate loaded, got %v", err)
+	}
+	if err := check(address, creds); err != nil {
+		t.Fatalf("Expected a call with the rotated certificate to succeed, got %v", err)
+	}
+
+	want := []string{"alice-1", "alice-1", "alice-2"}
+	got := callers()
+	if len(got) != len(want) {
+		t.Fatalf("Expected callers %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Expected caller %d to be %s, got %s", i, want[i], got[i])
+		}
+	}
+}
+
+func TestRotationPickedUpByHandshakes(t *testing.T) {
+	ca := newTestCA(t)
+	dir := t.TempDir()
+	config := ca.write(t, dir, "alice-1")
+	config.CheckInterval = time.Millisecond
+	provider, err := NewProvider(config)
+	if err != nil {
+		t.Fatalf("Expected the provider, got %v", err)
+	}
+
+	ca.write(t, dir, "alice-2")
+	time.Sleep(2 * time.Millisecond)
+	cert, _ := provider.current()
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if leaf.Subject.CommonName != "alice-2" {
+		t.Errorf("Expected the rewritten certificate picked up, got %s", leaf.Subject.CommonName)
+	}
+}
+
+func TestNewProviderErrors(t *testing.T) {
+	if _, err := NewProvider(Config{CertFile: "a.crt", KeyFile: "a.key"}); err == nil {
+		t.Errorf("Expected an error without a CA file")
+	}
+	if _, err := NewProvider(Config{CertFile: "missing.crt", KeyFile: "missing.key", CAFile: "missing.crt"}); err == nil {
+		t.Errorf("Expected an error for missing files")
+	}
+}
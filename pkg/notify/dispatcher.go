@@ -0,0 +1,159 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrQueueFull is reported for notifications dropped because the
+// dispatcher's queue was full
+var ErrQueueFull = errors.New("notify: queue full")
+
+// DispatcherConfig configures a Dispatcher
+type DispatcherConfig struct {
+	// Workers send notifications concurrently (default: 4)
+	Workers int
+
+	// Queue is how many notifications may wait for a worker; beyond it
+	// new ones are dropped (default: 1024)
+	Queue int
+
+	Retry RetryConfig
+
+	// OnDone, if set, is called with every notification's outcome: nil,
+	// the last error once retries ran out, or ErrQueueFull
+	OnDone func(n Notification, err error)
+}
+
+// withDefaults fills in unset fields
+func (c DispatcherConfig) withDefaults() DispatcherConfig {
+	if c.Workers <= 0 {
+		c.Workers = 4
+	}
+	if c.Queue <= 0 {
+		c.Queue = 1024
+	}
+	c.Retry = c.Retry.withDefaults()
+	return c
+}
+
+// DispatcherStats are a dispatcher's counters
+type DispatcherStats struct {
+	Sent    int64 // Notifications delivered
+	Failed  int64 // Notifications given up on
+	Dropped int64 // Notifications refused with a full queue
+	Pending int   // Notifications waiting for a worker
+}
+
+// Dispatcher sends notifications through a Notifier in the background
+type Dispatcher struct {
+	notifier Notifier
+	config   DispatcherConfig
+	queue    chan Notification
+	ctx      context.Context
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+
+	mu     sync.RWMutex
+	closed bool
+
+	sent    atomic.Int64
+	failed  atomic.Int64
+	dropped atomic.Int64
+}
+
+// NewDispatcher starts a dispatcher's workers
+func NewDispatcher(notifier Notifier, config DispatcherConfig) *Dispatcher {
+	config = config.withDefaults()
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &Dispatcher{
+		notifier: notifier,
+		config:   config,
+		queue:    make(chan Notification, config.Queue),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+	for i := 0; i < config.Workers; i++ {
+		d.wg.Add(1)
+		go d.work()
+	}
+	return d
+}
+
+// Enqueue queues n for sending without waiting, reporting false if it was
+// dropped because the queue is full or the dispatcher closed
+func (d *Dispatcher) Enqueue(n Notification) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.closed {
+		return false
+	}
+	select {
+	case d.queue <- n:
+		return true
+	default:
+		d.dropped.Add(1)
+		d.done(n, ErrQueueFull)
+		return false
+	}
+}
+
+// work sends queued notifications until the queue is closed
+func (d *Dispatcher) work() {
+	defer d.wg.Done()
+	for n := range d.queue {
+		err := Retry(d.ctx, d.notifier, d.config.Retry, n)
+		if err == nil {
+			d.sent.Add(1)
+		} else {
+			d.failed.Add(1)
+		}
+		d.done(n, err)
+	}
+}
+
+// done reports a notification's outcome
+func (d *Dispatcher) done(n Notification, err error) {
+	if d.config.OnDone != nil {
+		d.config.OnDone(n, err)
+	}
+}
+
+// Stats returns the dispatcher's counters
+func (d *Dispatcher) Stats() DispatcherStats {
+	return DispatcherStats{
+		Sent:    d.sent.Load(),
+		Failed:  d.failed.Load(),
+		Dropped: d.dropped.Load(),
+		Pending: len(d.queue),
+	}
+}
+
+// Close stops taking notifications and waits for the queued ones to be
+// sent, until ctx ends; then retries are abandoned and the rest are
+// failed. It returns ctx's error if it gave up.
+func (d *Dispatcher) Close(ctx context.Context) error {
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		close(d.queue)
+	}
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		d.cancel()
+		return nil
+	case <-ctx.Done():
+		d.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,136 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultFCMEndpoint is the FCM HTTP v1 send URL, formatted with the
+// project ID
+const DefaultFCMEndpoint = "https://fcm.googleapis.com/v1/projects/%s/messages:send"
+
+// DeviceTokens looks up the push tokens of a user's devices
+type DeviceTokens interface {
+	Tokens(ctx context.Context, userID string) ([]string, error)
+}
+
+// DeviceTokensFunc adapts a function to DeviceTokens
+type DeviceTokensFunc func(ctx context.Context, userID string) ([]string, error)
+
+// Tokens calls f
+func (f DeviceTokensFunc) Tokens(ctx context.Context, userID string) ([]string, error) {
+	return f(ctx, userID)
+}
+
+// FCMConfig configures an FCM notifier
+type FCMConfig struct {
+	// ProjectID names the project messages are sent for
+	ProjectID string
+
+	// Endpoint overrides DefaultFCMEndpoint, e.g. for a test server; %s
+	// is replaced by ProjectID
+	Endpoint string
+
+	// AccessToken returns the OAuth2 bearer token requests are sent with,
+	// e.g. from a service account's token source
+	AccessToken func(ctx context.Context) (string, error)
+
+	// Devices finds the tokens of each recipient's devices
+	Devices DeviceTokens
+
+	// Client sends the requests (default: one with a 10s timeout)
+	Client *http.Client
+}
+
+// FCM pushes notifications to recipients' devices through Firebase Cloud
+// Messaging's HTTP v1 API, or any service speaking it: one request per
+// device, titled with the sender and carrying the message's IDs as data.
+type FCM struct {
+	config FCMConfig
+}
+
+// NewFCM creates an FCM notifier
+func NewFCM(config FCMConfig) *FCM {
+	if config.Endpoint == "" {
+		config.Endpoint = DefaultFCMEndpoint
+	}
+	if config.Client == nil {
+		config.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &FCM{config: config}
+}
+
+// fcmMessage is the body of an FCM v1 send request
+type fcmMessage struct {
+	Message struct {
+		Token        string            `json:"token"`
+		Notification map[string]string `json:"notification"`
+		Data         map[string]string `json:"data"`
+	} `json:"message"`
+}
+
+// Notify pushes n to every recipient's devices. Devices already sent to
+// are sent again when a retry follows a partial failure; the message ID in
+// the data lets apps ignore the repeat.
+func (f *FCM) Notify(ctx context.Context, n Notification) error {
+	token, err := f.config.AccessToken(ctx)
+	if err != nil {
+		return fmt.Errorf("notify: access token: %w", err)
+	}
+	url := f.config.Endpoint
+	if strings.Contains(url, "%s") {
+		url = fmt.Sprintf(url, f.config.ProjectID)
+	}
+
+	var errs []error
+	for _, recipient := range n.Recipients {
+		devices, err := f.config.Devices.Tokens(ctx, recipient)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("notify: devices of %s: %w", recipient, err))
+			continue
+		}
+		for _, device := range devices {
+			var msg fcmMessage
+			msg.Message.Token = device
+			msg.Message.Notification = map[string]string{"title": n.SenderID, "body": n.Preview}
+			msg.Message.Data = map[string]string{
+				"chat_id":    n.ChatID,
+				"namespace":  n.Namespace,
+				"message_id": n.MessageID,
+				"seq":        strconv.FormatUint(n.Seq, 10),
+			}
+			body, err := json.Marshal(msg)
+			if err != nil {
+				return Permanent(err)
+			}
+			req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+			if err != nil {
+				return Permanent(err)
+			}
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", "Bearer "+token)
+			if err := send(f.config.Client, req); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	// Retry unless every failure was permanent
+	err = errors.Join(errs...)
+	for _, e := range errs {
+		if !IsPermanent(e) {
+			return err
+		}
+	}
+	if err != nil {
+		return Permanent(err)
+	}
+	return nil
+}
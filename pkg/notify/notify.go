@@ -0,0 +1,116 @@
+// Package notify tells chat members who aren't following a chat that a
+// message arrived for them, through an outbound Notifier: a webhook, a
+// push service such as FCM, or anything implementing Notify. A Dispatcher
+// sends notifications in the background, retrying failures with backoff,
+// so the write path never waits on them.
+package notify
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+)
+
+// Notification is one message, announced to the chat members who missed
+// it
+type Notification struct {
+	ChatID     string    `json:"chat_id"`
+	Namespace  string    `json:"namespace,omitempty"`
+	MessageID  string    `json:"message_id"`
+	Seq        uint64    `json:"seq"`
+	SenderID   string    `json:"sender_id"`
+	Recipients []string  `json:"recipients"`
+	Preview    string    `json:"preview,omitempty"` // Start of the text, or what kind of message it is
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// Notifier delivers notifications. Errors wrapped with Permanent are not
+// retried.
+type Notifier interface {
+	Notify(ctx context.Context, n Notification) error
+}
+
+// Func adapts a function to Notifier
+type Func func(ctx context.Context, n Notification) error
+
+// Notify calls f
+func (f Func) Notify(ctx context.Context, n Notification) error {
+	return f(ctx, n)
+}
+
+// permanent marks an error retrying won't fix
+type permanent struct {
+	err error
+}
+
+func (p permanent) Error() string { return p.err.Error() }
+func (p permanent) Unwrap() error { return p.err }
+
+// Permanent wraps err so the notification is given up on at once, e.g.
+// when the endpoint refuses it as malformed
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanent{err}
+}
+
+// IsPermanent reports whether err was wrapped with Permanent
+func IsPermanent(err error) bool {
+	var p permanent
+	return errors.As(err, &p)
+}
+
+// RetryConfig spaces out the attempts to deliver a notification
+type RetryConfig struct {
+	// Attempts is how many times to try in all (default: 5)
+	Attempts int
+
+	// InitialBackoff is the wait after the first failure, doubling after
+	// each one up to MaxBackoff (defaults: 500ms and 30s). Waits are
+	// jittered by up to half, so failed notifications don't retry in step.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// withDefaults fills in unset fields
+func (c RetryConfig) withDefaults() RetryConfig {
+	if c.Attempts <= 0 {
+		c.Attempts = 5
+	}
+	if c.InitialBackoff <= 0 {
+		c.InitialBackoff = 500 * time.Millisecond
+	}
+	if c.MaxBackoff <= 0 {
+		c.MaxBackoff = 30 * time.Second
+	}
+	return c
+}
+
+// backoff returns the wait after the given number of failures
+func (c RetryConfig) backoff(failures int) time.Duration {
+	d := c.InitialBackoff
+	for i := 1; i < failures && d < c.MaxBackoff; i++ {
+		d *= 2
+	}
+	d = min(d, c.MaxBackoff)
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// Retry calls n until it succeeds, fails permanently, runs out of attempts
+// or ctx ends, returning the last error
+func Retry(ctx context.Context, n Notifier, config RetryConfig, notification Notification) error {
+	config = config.withDefaults()
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = n.Notify(ctx, notification); err == nil || IsPermanent(err) || attempt >= config.Attempts {
+			return err
+		}
+		select {
+		case <-time.After(config.backoff(attempt)):
+		case <-ctx.Done():
+			return errors.Join(err, ctx.Err())
+		}
+	}
+}
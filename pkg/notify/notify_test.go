@@ -0,0 +1,158 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var quickRetry = RetryConfig{Attempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond}
+
+func TestRetry(t *testing.T) {
+	var calls int
+	flaky := Func(func(ctx context.Context, n Notification) error {
+		calls++
+		if calls < 3 {
+			return errors.New("unavailable")
+		}
+		return nil
+	})
+	if err := Retry(context.Background(), flaky, quickRetry, Notification{}); err != nil || calls != 3 {
+		t.Errorf("Expected success on the third attempt, got %v after %d", err, calls)
+	}
+
+	calls = 0
+	refused := Func(func(ctx context.Context, n Notification) error {
+		calls++
+		return Permanent(errors.New("bad request"))
+	})
+	if err := Retry(context.Background(), refused, quickRetry, Notification{}); !IsPermanent(err) || calls != 1 {
+		t.Errorf("Expected a permanent failure given up on at once, got %v after %d", err, calls)
+	}
+
+	calls = 0
+	down := Func(func(ctx context.Context, n Notification) error {
+		calls++
+		return errors.New("unavailable")
+	})
+	if err := Retry(context.Background(), down, quickRetry, Notification{}); err == nil || calls != 3 {
+		t.Errorf("Expected failure after 3 attempts, got %v after %d", err, calls)
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	var attempts atomic.Int32
+	var got Notification
+	var signature string
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) == 1 {
+			http.Error(w, "try later", http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		signature = r.Header.Get(SignatureHeader)
+		if signature != Sign("secret", body) {
+			http.Error(w, "bad signature", http.StatusUnauthorized)
+			return
+		}
+		json.Unmarshal(body, &got)
+	}))
+	defer endpoint.Close()
+
+	hook := NewWebhook(WebhookConfig{URL: endpoint.URL, Secret: "secret"})
+	n := Notification{ChatID: "chat-1", MessageID: "m-1", SenderID: "alice", Recipients: []string{"bob"}}
+	if err := Retry(context.Background(), hook, quickRetry, n); err != nil {
+		t.Fatalf("Expected the webhook delivered on retry, got %v", err)
+	}
+	if got.MessageID != "m-1" || len(got.Recipients) != 1 || attempts.Load() != 2 {
+		t.Errorf("Expected m-1 delivered on the second attempt, got %+v after %d", got, attempts.Load())
+	}
+
+	wrong := NewWebhook(WebhookConfig{URL: endpoint.URL, Secret: "guess"})
+	if err := wrong.Notify(context.Background(), n); !IsPermanent(err) {
+		t.Errorf("Expected a 401 to be permanent, got %v", err)
+	}
+}
+
+func TestFCM(t *testing.T) {
+	var mu sync.Mutex
+	sent := map[string]string{}
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/projects/chat-app/messages:send" || r.Header.Get("Authorization") != "Bearer token" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		var msg fcmMessage
+		json.NewDecoder(r.Body).Decode(&msg)
+		mu.Lock()
+		sent[msg.Message.Token] = msg.Message.Data["message_id"]
+		mu.Unlock()
+	}))
+	defer endpoint.Close()
+
+	fcm := NewFCM(FCMConfig{
+		ProjectID:   "chat-app",
+		Endpoint:    endpoint.URL + "/v1/projects/%s/messages:send",
+		AccessToken: func(ctx context.Context) (string, error) { return "token", nil },
+		Devices: DeviceTokensFunc(func(ctx context.Context, userID string) ([]string, error) {
+			return map[string][]string{"bob": {"phone", "tablet"}, "carol": {"laptop"}}[userID], nil
+		}),
+	})
+	err := fcm.Notify(context.Background(), Notification{ChatID: "chat-1", MessageID: "m-1", Recipients: []string{"bob", "carol"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sent) != 3 || sent["tablet"] != "m-1" {
+		t.Errorf("Expected m-1 pushed to 3 devices, got %v", sent)
+	}
+}
+
+func TestDispatcher(t *testing.T) {
+	release := make(chan struct{})
+	var outcomes sync.Map
+	d := NewDispatcher(Func(func(ctx context.Context, n Notification) error {
+		<-release
+		if n.MessageID == "fails" {
+			return Permanent(errors.New("refused"))
+		}
+		return nil
+	}), DispatcherConfig{
+		Workers: 1,
+		Queue:   2,
+		Retry:   quickRetry,
+		OnDone:  func(n Notification, err error) { outcomes.Store(n.MessageID, err) },
+	})
+
+	// One notification is being sent, two wait, and the fourth is dropped
+	d.Enqueue(Notification{MessageID: "first"})
+	for d.Stats().Pending != 0 {
+		time.Sleep(time.Millisecond)
+	}
+	d.Enqueue(Notification{MessageID: "fails"})
+	d.Enqueue(Notification{MessageID: "third"})
+	if d.Enqueue(Notification{MessageID: "dropped"}) {
+		t.Errorf("Expected a full queue to drop the notification")
+	}
+
+	close(release)
+	if err := d.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	stats := d.Stats()
+	if stats.Sent != 2 || stats.Failed != 1 || stats.Dropped != 1 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+	if err, _ := outcomes.Load("dropped"); !errors.Is(err.(error), ErrQueueFull) {
+		t.Errorf("Expected the dropped notification reported, got %v", err)
+	}
+	if d.Enqueue(Notification{}) {
+		t.Errorf("Expected a closed dispatcher to refuse notifications")
+	}
+}
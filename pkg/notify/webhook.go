@@ -0,0 +1,96 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// SignatureHeader carries a webhook body's HMAC-SHA256 under the shared
+// secret, hex encoded, so receivers can tell the notification came from
+// the cluster
+const SignatureHeader = "X-DistriChat-Signature"
+
+// WebhookConfig configures a Webhook
+type WebhookConfig struct {
+	// URL is POSTed every notification as JSON
+	URL string
+
+	// Secret, if set, signs each body in SignatureHeader
+	Secret string
+
+	// Headers are added to every request, e.g. an Authorization header
+	Headers map[string]string
+
+	// Client sends the requests (default: one with a 10s timeout)
+	Client *http.Client
+}
+
+// Webhook POSTs notifications as JSON to a URL. 5xx answers, 429 and
+// network errors are retried; other 4xx answers are permanent.
+type Webhook struct {
+	config WebhookConfig
+}
+
+// NewWebhook creates a webhook notifier
+func NewWebhook(config WebhookConfig) *Webhook {
+	if config.Client == nil {
+		config.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &Webhook{config: config}
+}
+
+// Notify POSTs n to the webhook's URL
+func (w *Webhook) Notify(ctx context.Context, n Notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return Permanent(err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return Permanent(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range w.config.Headers {
+		req.Header.Set(k, v)
+	}
+	if w.config.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign(w.config.Secret, body))
+	}
+	return send(w.config.Client, req)
+}
+
+// Sign returns body's HMAC-SHA256 under secret, hex encoded, as sent in
+// SignatureHeader
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// send sends req, classifying the answer: 2xx is success, 429 and 5xx are
+// worth retrying, and other 4xx are permanent
+func send(client *http.Client, req *http.Request) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+
+	switch {
+	case resp.StatusCode/100 == 2:
+		return nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return fmt.Errorf("notify: %s answered %s: %s", req.URL.Host, resp.Status, bytes.TrimSpace(detail))
+	default:
+		return Permanent(fmt.Errorf("notify: %s answered %s: %s", req.URL.Host, resp.Status, bytes.TrimSpace(detail)))
+	}
+}
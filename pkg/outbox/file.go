@@ -0,0 +1,290 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/pkg/encryption"
+	pb "github.com/sh4shv4t/DistriChat/proto"
+	"google.golang.org/protobuf/proto"
+)
+
+// compactAfter is how many records beyond twice the pending entries a
+// file may hold before it is rewritten
+const compactAfter = 1024
+
+// record is one line of a FileStore: an entry added, with its message, or
+// a change to one
+type record struct {
+	Op        string    `json:"op"` // add, delivered, failed or done
+	ID        uint64    `json:"id"`
+	Message   []byte    `json:"message,omitempty"` // The StoredMessage, in protobuf, sealed if encrypted
+	Created   time.Time `json:"created,omitempty"`
+	Delivered bool      `json:"delivered,omitempty"`
+	Attempts  int       `json:"attempts,omitempty"`
+}
+
+// FileStore is a Store kept in an append-only file readable only by its
+// owner. An added entry is synced before Add returns, so a message
+// acknowledged before a crash is delivered after it; changes to entries
+// aren't, and one lost to a crash only repeats a delivery step. The file
+// is rewritten with the pending entries when opened and as done entries
+// pile up, through a temporary file renamed over the old one. It belongs
+// to one server.
+type FileStore struct {
+	path      string
+	encrypter *encryption.Encrypter
+
+	mu      sync.Mutex
+	file    *os.File
+	records int
+	entries *MemoryStore
+}
+
+// Option adjusts a FileStore as OpenFile opens it
+type Option func(*FileStore)
+
+// WithEncrypter encrypts the messages written to the file, each bound to
+// its entry, and decrypts them when the file is opened again. A file
+// written without an encrypter can't be opened with one, and the other way
+// around.
+func WithEncrypter(e *encryption.Encrypter) Option {
+	return func(f *FileStore) { f.encrypter = e }
+}
+
+// OpenFile opens the store in path, creating it if it doesn't exist. A
+// last record cut short by a crash is dropped.
+func OpenFile(path string, opts ...Option) (*FileStore, error) {
+	f := &FileStore{path: path, entries: NewMemoryStore()}
+	for _, opt := range opts {
+		opt(f)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("outbox: %w", err)
+	}
+
+	// Every record ends with a newline, so a crash mid-write leaves the
+	// last line unterminated
+	lines := bytes.Split(data, []byte("\n"))
+	for i, line := range lines[:len(lines)-1] {
+		if len(line) == 0 {
+			continue
+		}
+		var rec record
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return nil, fmt.Errorf("outbox: %s line %d: %w", path, i+1, err)
+		}
+		if err := f.replay(rec); err != nil {
+			return nil, fmt.Errorf("outbox: %s line %d: %w", path, i+1, err)
+		}
+	}
+
+	if err := f.rewrite(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// replay applies a record read from the file
+func (f *FileStore) replay(rec record) error {
+	switch rec.Op {
+	case "add":
+		msg, err := f.open(rec.ID, rec.Message)
+		if err != nil {
+			return err
+		}
+		f.entries.restore(Entry{ID: rec.ID, Message: msg, Created: rec.Created,
+			Delivered: rec.Delivered, Attempts: rec.Attempts})
+	case "delivered":
+		f.entries.MarkDelivered(rec.ID)
+	case "failed":
+		f.entries.MarkFailed(rec.ID)
+	case "done":
+		f.entries.Done(rec.ID)
+	default:
+		return fmt.Errorf("unknown operation %q", rec.Op)
+	}
+	return nil
+}
+
+func (f *FileStore) Add(msg *pb.StoredMessage, now time.Time) (Entry, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	entry := Entry{ID: f.entries.lastID() + 1, Message: msg, Created: now}
+	data, err := f.seal(entry.ID, msg)
+	if err != nil {
+		return Entry{}, err
+	}
+	if err := f.append(record{Op: "add", ID: entry.ID, Message: data, Created: now}, true); err != nil {
+		return Entry{}, err
+	}
+	f.entries.restore(entry)
+	return entry, nil
+}
+
+func (f *FileStore) MarkDelivered(id uint64) error {
+	return f.change(record{Op: "delivered", ID: id}, f.entries.MarkDelivered)
+}
+
+func (f *FileStore) MarkFailed(id uint64) error {
+	return f.change(record{Op: "failed", ID: id}, f.entries.MarkFailed)
+}
+
+func (f *FileStore) Done(id uint64) error {
+	return f.change(record{Op: "done", ID: id}, f.entries.Done)
+}
+
+func (f *FileStore) Pending() ([]Entry, error) {
+	return f.entries.Pending()
+}
+
+func (f *FileStore) Len() int {
+	return f.entries.Len()
+}
+
+// Close closes the file; the store takes no more changes
+func (f *FileStore) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+// change records a change to an entry and applies it, compacting the file
+// if it holds mostly done entries
+func (f *FileStore) change(rec record, apply func(uint64) error) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.append(rec, false); err != nil {
+		return err
+	}
+	apply(rec.ID)
+	if f.records > 2*f.entries.Len()+compactAfter {
+		return f.rewrite()
+	}
+	return nil
+}
+
+// append writes a record to the file, syncing it if asked; f.mu must be
+// held
+func (f *FileStore) append(rec record, sync bool) error {
+	if f.file == nil {
+		return fmt.Errorf("outbox: %s is closed", f.path)
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	if _, err := f.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("outbox: %w", err)
+	}
+	if sync {
+		if err := f.file.Sync(); err != nil {
+			return fmt.Errorf("outbox: %w", err)
+		}
+	}
+	f.records++
+	return nil
+}
+
+// rewrite replaces the file with the pending entries and reopens it for
+// appending; f.mu must be held, or f not yet shared
+func (f *FileStore) rewrite() error {
+	pending, _ := f.entries.Pending()
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, entry := range pending {
+		data, err := f.seal(entry.ID, entry.Message)
+		if err != nil {
+			return err
+		}
+		rec := record{Op: "add", ID: entry.ID, Message: data, Created: entry.Created,
+			Delivered: entry.Delivered, Attempts: entry.Attempts}
+		if err := enc.Encode(rec); err != nil {
+			return err
+		}
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("outbox: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return fmt.Errorf("outbox: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("outbox: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("outbox: %w", err)
+	}
+
+	if f.file != nil {
+		f.file.Close()
+		f.file = nil
+	}
+	if err := os.Rename(tmp.Name(), f.path); err != nil {
+		return fmt.Errorf("outbox: %w", err)
+	}
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("outbox: %w", err)
+	}
+	f.file = file
+	f.records = len(pending)
+	return nil
+}
+
+// seal encodes an entry's message for the file, encrypting it if the store
+// has an encrypter
+func (f *FileStore) seal(id uint64, msg *pb.StoredMessage) ([]byte, error) {
+	data, err := proto.Marshal(msg)
+	if err != nil || f.encrypter == nil {
+		return data, err
+	}
+	sealed, err := f.encrypter.Seal(context.Background(), data, entryKey(id))
+	if err != nil {
+		return nil, fmt.Errorf("outbox: failed to encrypt entry %d: %w", id, err)
+	}
+	return sealed, nil
+}
+
+// open decodes a message sealed by seal
+func (f *FileStore) open(id uint64, data []byte) (*pb.StoredMessage, error) {
+	if f.encrypter != nil {
+		opened, err := f.encrypter.Open(context.Background(), data, entryKey(id))
+		if err != nil {
+			return nil, err
+		}
+		data = opened
+	} else if encryption.IsEncrypted(data) {
+		return nil, fmt.Errorf("entry %d is encrypted, but the store has no encrypter", id)
+	}
+	msg := &pb.StoredMessage{}
+	if err := proto.Unmarshal(data, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// entryKey is the associated data an entry's message is sealed with, so a
+// message copied into another entry fails to open
+func entryKey(id uint64) []byte {
+	return []byte("outbox/" + strconv.FormatUint(id, 10))
+}
@@ -0,0 +1,138 @@
+// Package outbox holds the messages a server accepted until they are
+// delivered. Accepting a message and recording it here are one step: the
+// record carries the message itself, so once it is committed the message
+// is both persisted and due for delivery, and a crash before delivery
+// finished leaves the record for the server to deliver on restart. A
+// message is never persisted but left undelivered.
+//
+// Delivery has two stages. Handing a message to the server's subscribers,
+// other regions and notifications can't fail and is done once; publishing
+// it to the message log can, and is retried until it succeeds.
+package outbox
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	pb "github.com/sh4shv4t/DistriChat/proto"
+)
+
+// Entry is one message awaiting delivery
+type Entry struct {
+	ID      uint64
+	Message *pb.StoredMessage
+	Created time.Time
+
+	// Delivered is set once the message went to subscribers, other regions
+	// and notifications; only publishing it to the message log is left
+	Delivered bool
+
+	// Attempts counts failed publications to the message log
+	Attempts int
+}
+
+// Store keeps the entries not yet delivered
+type Store interface {
+	// Add commits a message for delivery, returning its entry
+	Add(msg *pb.StoredMessage, now time.Time) (Entry, error)
+
+	// MarkDelivered records that an entry's message was delivered to
+	// everything but the message log
+	MarkDelivered(id uint64) error
+
+	// MarkFailed counts a failed publication of an entry's message
+	MarkFailed(id uint64) error
+
+	// Done removes an entry whose delivery is complete
+	Done(id uint64) error
+
+	// Pending returns the entries not yet done, oldest first
+	Pending() ([]Entry, error)
+
+	// Len returns how many entries are pending
+	Len() int
+}
+
+// MemoryStore is a Store in process memory, for tests and servers whose
+// accepted messages needn't survive a restart
+type MemoryStore struct {
+	mu      sync.Mutex
+	next    uint64
+	entries map[uint64]Entry
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[uint64]Entry)}
+}
+
+func (s *MemoryStore) Add(msg *pb.StoredMessage, now time.Time) (Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.next++
+	entry := Entry{ID: s.next, Message: msg, Created: now}
+	s.entries[entry.ID] = entry
+	return entry, nil
+}
+
+func (s *MemoryStore) MarkDelivered(id uint64) error {
+	s.update(id, func(e *Entry) { e.Delivered = true })
+	return nil
+}
+
+func (s *MemoryStore) MarkFailed(id uint64) error {
+	s.update(id, func(e *Entry) { e.Attempts++ })
+	return nil
+}
+
+func (s *MemoryStore) Done(id uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, id)
+	return nil
+}
+
+func (s *MemoryStore) Pending() ([]Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entries := make([]Entry, 0, len(s.entries))
+	for _, entry := range s.entries {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ID < entries[j].ID })
+	return entries, nil
+}
+
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.entries)
+}
+
+// update changes an entry if it is still pending
+func (s *MemoryStore) update(id uint64, change func(*Entry)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if entry, ok := s.entries[id]; ok {
+		change(&entry)
+		s.entries[id] = entry
+	}
+}
+
+// restore puts back an entry read from a file, keeping its ID
+func (s *MemoryStore) restore(entry Entry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[entry.ID] = entry
+	if entry.ID > s.next {
+		s.next = entry.ID
+	}
+}
+
+// lastID returns the highest ID handed out
+func (s *MemoryStore) lastID() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.next
+}
@@ -0,0 +1,120 @@
+package outbox
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/pkg/encryption"
+	pb "github.com/sh4shv4t/DistriChat/proto"
+)
+
+func message(id string) *pb.StoredMessage {
+	return &pb.StoredMessage{MessageId: id, Request: &pb.ChatRequest{ChatId: "chat-1"}}
+}
+
+func TestMemoryStore(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := NewMemoryStore()
+	a, _ := s.Add(message("m-1"), now)
+	b, _ := s.Add(message("m-2"), now)
+
+	s.MarkDelivered(a.ID)
+	s.MarkFailed(a.ID)
+	s.Done(b.ID)
+	pending, _ := s.Pending()
+	if len(pending) != 1 || pending[0].ID != a.ID || !pending[0].Delivered || pending[0].Attempts != 1 {
+		t.Errorf("Expected m-1 left, delivered with 1 failed attempt, got %+v", pending)
+	}
+	s.Done(a.ID)
+	if s.Len() != 0 {
+		t.Errorf("Expected an empty outbox, got %d entries", s.Len())
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	path := filepath.Join(t.TempDir(), "outbox.log")
+	s, err := OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []uint64
+	for i := 1; i <= 3; i++ {
+		entry, err := s.Add(message(fmt.Sprintf("m-%d", i)), now)
+		if err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+		ids = append(ids, entry.ID)
+	}
+	s.Done(ids[0])
+	s.MarkDelivered(ids[1])
+	s.Close()
+
+	// A crash mid-write leaves a torn last record, which is dropped
+	file, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	file.WriteString(`{"op":"done","id":`)
+	file.Close()
+
+	s, err = OpenFile(path)
+	if err != nil {
+		t.Fatalf("Reopening failed: %v", err)
+	}
+	defer s.Close()
+	pending, _ := s.Pending()
+	if len(pending) != 2 || pending[0].Message.MessageId != "m-2" || !pending[0].Delivered || pending[1].Delivered {
+		t.Fatalf("Expected m-2 delivered and m-3 pending after a restart, got %+v", pending)
+	}
+	if entry, _ := s.Add(message("m-4"), now); entry.ID <= ids[2] {
+		t.Errorf("Expected new IDs past those pending, got %d", entry.ID)
+	}
+
+	// Done entries are compacted away as they pile up
+	for i := 0; i < 2*compactAfter; i++ {
+		entry, _ := s.Add(message("bulk"), now)
+		s.Done(entry.ID)
+	}
+	s.mu.Lock()
+	records := s.records
+	s.mu.Unlock()
+	if records > 2*s.Len()+compactAfter {
+		t.Errorf("Expected the file compacted, it holds %d records for %d entries", records, s.Len())
+	}
+}
+
+func TestFileStoreEncrypted(t *testing.T) {
+	keyring, err := encryption.NewKeyring("k1", map[string][]byte{"k1": bytes.Repeat([]byte{1}, encryption.DataKeySize)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypter := encryption.New(keyring)
+	path := filepath.Join(t.TempDir(), "outbox.log")
+	s, err := OpenFile(path, WithEncrypter(encrypter))
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := message("m-1")
+	msg.Request.Content = &pb.ChatRequest_Text{Text: "the secret plan"}
+	if _, err := s.Add(msg, time.Now()); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	s.Close()
+
+	if data, _ := os.ReadFile(path); bytes.Contains(data, []byte("the secret plan")) || bytes.Contains(data, []byte("m-1")) {
+		t.Errorf("Expected the message encrypted in the file, got %s", data)
+	}
+	if _, err := OpenFile(path); err == nil {
+		t.Errorf("Expected the file refused without the encrypter")
+	}
+	s, err = OpenFile(path, WithEncrypter(encrypter))
+	if err != nil {
+		t.Fatalf("Reopening failed: %v", err)
+	}
+	defer s.Close()
+	if pending, _ := s.Pending(); len(pending) != 1 || pending[0].Message.Request.GetText() != "the secret plan" {
+		t.Errorf("Expected the message back after a restart, got %+v", pending)
+	}
+}
@@ -0,0 +1,123 @@
+// Package policy screens messages for spam and abuse before a server
+// accepts them. A server runs each message past its Policies in order: the
+// first to reject it refuses it with ERROR_POLICY_REJECTED, and those that
+// flag it have it logged and counted but still accepted.
+//
+//	reputation := policy.NewReputation(policy.ReputationConfig{})
+//	srv := server.NewChatServer(server.ServerConfig{
+//		MessagePolicies: []policy.Policy{
+//			policy.NewRateAnomaly(policy.RateAnomalyConfig{}),
+//			policy.BannedContent(blocklist.Match),
+//			reputation,
+//		},
+//	})
+//
+// Policies implementing Observer learn every message's outcome, so a
+// sender's reputation drops with each flag or rejection, whichever policy
+// made it.
+package policy
+
+import (
+	"context"
+	"time"
+)
+
+// Action is what a policy decides to do with a message
+type Action int
+
+const (
+	Allow  Action = iota // Accept the message
+	Flag                 // Accept it, but log and count it as suspect
+	Reject               // Refuse it
+)
+
+// String returns the action's metric label
+func (a Action) String() string {
+	switch a {
+	case Allow:
+		return "allow"
+	case Flag:
+		return "flag"
+	case Reject:
+		return "reject"
+	default:
+		return "unknown"
+	}
+}
+
+// Message is what policies see of a message being posted
+type Message struct {
+	ChatID    string
+	Namespace string
+	SenderID  string
+	Principal string // Authenticated caller, if any
+	Content   string // Text, or a summary of an attachment or event
+	Timestamp time.Time
+}
+
+// Decision is a policy's verdict on a message
+type Decision struct {
+	Action Action
+	Policy string // Name of the policy, its metric label
+	Reason string // Why it flagged or rejected the message
+}
+
+// Policy checks a message before it is accepted. It is called for every
+// message a server is posted, concurrently, so it must be quick and safe
+// for concurrent use.
+type Policy interface {
+	Check(ctx context.Context, msg Message) Decision
+}
+
+// Observer is implemented by policies that learn from the outcome of every
+// message checked: the decision of the policy that rejected it, or of the
+// first that flagged it, or Allow
+type Observer interface {
+	Observe(msg Message, outcome Decision)
+}
+
+// Func adapts a function to Policy
+type Func func(ctx context.Context, msg Message) Decision
+
+// Check calls f(ctx, msg)
+func (f Func) Check(ctx context.Context, msg Message) Decision {
+	return f(ctx, msg)
+}
+
+// Evaluate runs msg past policies in order, stopping at the first that
+// rejects it. It returns every decision other than Allow, and the outcome:
+// the rejection, or else the first flag, or else Allow. Observers among
+// policies are told the outcome.
+func Evaluate(ctx context.Context, policies []Policy, msg Message) (outcome Decision, decisions []Decision) {
+	for _, p := range policies {
+		d := p.Check(ctx, msg)
+		if d.Action == Allow {
+			continue
+		}
+		decisions = append(decisions, d)
+		if d.Action == Reject || outcome.Action == Allow {
+			outcome = d
+		}
+		if d.Action == Reject {
+			break
+		}
+	}
+	for _, p := range policies {
+		if o, ok := p.(Observer); ok {
+			o.Observe(msg, outcome)
+		}
+	}
+	return outcome, decisions
+}
+
+// BannedContent rejects messages match finds banned content in, e.g. a
+// blocklist or an external moderation service. match returns why the
+// content is banned, or "" if it isn't.
+func BannedContent(match func(ctx context.Context, msg Message) string) Policy {
+	return Func(func(ctx context.Context, msg Message) Decision {
+		if reason := match(ctx, msg); reason != "" {
+			return Decision{Action: Reject, Policy: "banned_content", Reason: reason}
+		}
+		return Decision{}
+	})
+}
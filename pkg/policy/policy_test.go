@@ -0,0 +1,119 @@
+package policy
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEvaluate(t *testing.T) {
+	flag := Func(func(ctx context.Context, msg Message) Decision {
+		return Decision{Action: Flag, Policy: "flagger", Reason: "suspect"}
+	})
+	banned := BannedContent(func(ctx context.Context, msg Message) string {
+		if strings.Contains(msg.Content, "spam") {
+			return "contains spam"
+		}
+		return ""
+	})
+	var checked bool
+	after := Func(func(ctx context.Context, msg Message) Decision {
+		checked = true
+		return Decision{}
+	})
+	policies := []Policy{flag, banned, after}
+
+	outcome, decisions := Evaluate(context.Background(), policies, Message{SenderID: "alice", Content: "hello"})
+	if outcome.Action != Flag || outcome.Policy != "flagger" || len(decisions) != 1 || !checked {
+		t.Errorf("Expected the message flagged after every policy ran, got %+v, %+v", outcome, decisions)
+	}
+
+	checked = false
+	outcome, decisions = Evaluate(context.Background(), policies, Message{SenderID: "alice", Content: "buy spam"})
+	if outcome.Action != Reject || outcome.Policy != "banned_content" || len(decisions) != 2 {
+		t.Errorf("Expected the banned content rejected, got %+v, %+v", outcome, decisions)
+	}
+	if checked {
+		t.Errorf("Expected no policy run after the rejection")
+	}
+}
+
+func TestRateAnomaly(t *testing.T) {
+	r := NewRateAnomaly(RateAnomalyConfig{Window: 10 * time.Second, Baseline: time.Minute, MinMessages: 5})
+	now := time.Now()
+	r.now = func() time.Time { return now }
+	post := func(sender string) Decision {
+		return r.Check(context.Background(), Message{SenderID: sender})
+	}
+
+	// A new sender may burst up to MinMessages
+	for i := 0; i < 5; i++ {
+		if d := post("alice"); d.Action != Allow {
+			t.Fatalf("Expected message %d allowed, got %+v", i, d)
+		}
+	}
+	if d := post("alice"); d.Action != Flag || d.Policy != "rate_anomaly" {
+		t.Errorf("Expected a burst from a quiet sender flagged, got %+v", d)
+	}
+
+	// A sender steadily posting 2 messages a second isn't anomalous once
+	// its usual rate is learnt...
+	for i := 0; i < 600; i++ {
+		now = now.Add(500 * time.Millisecond)
+		if d := post("bob"); d.Action != Allow && i > 100 {
+			t.Fatalf("Expected bob's usual rate allowed at message %d, got %+v", i, d)
+		}
+	}
+	// ...but twenty times that is
+	var flagged bool
+	for i := 0; i < 200; i++ {
+		now = now.Add(25 * time.Millisecond)
+		flagged = flagged || post("bob").Action == Flag
+	}
+	if !flagged {
+		t.Errorf("Expected bob flagged at twenty times his usual rate")
+	}
+}
+
+func TestReputation(t *testing.T) {
+	r := NewReputation(ReputationConfig{FlagBelow: 0.7, RejectBelow: 0.5, RejectPenalty: 0.2})
+	now := time.Now()
+	r.now = func() time.Time { return now }
+	msg := Message{SenderID: "mallory"}
+	rejected := Decision{Action: Reject, Policy: "banned_content"}
+
+	r.Observe(msg, rejected)
+	if d := r.Check(context.Background(), msg); d.Action != Allow {
+		t.Errorf("Expected one rejection forgiven, got %+v", d)
+	}
+	r.Observe(msg, rejected)
+	if d := r.Check(context.Background(), msg); d.Action != Flag {
+		t.Errorf("Expected mallory flagged at %.2f, got %+v", r.Score("mallory"), d)
+	}
+	r.Observe(msg, rejected)
+	d := r.Check(context.Background(), msg)
+	if d.Action != Reject || d.Policy != "reputation" {
+		t.Fatalf("Expected mallory rejected at %.2f, got %+v", r.Score("mallory"), d)
+	}
+
+	// Its own rejections neither sink nor restore the score
+	score := r.Score("mallory")
+	for i := 0; i < 10; i++ {
+		r.Observe(msg, d)
+	}
+	if got := r.Score("mallory"); got != score {
+		t.Errorf("Expected the score kept at %.2f, got %.2f", score, got)
+	}
+
+	// Accepted messages restore it, and idle senders start over
+	r.Observe(Message{SenderID: "alice"}, rejected)
+	r.Observe(Message{SenderID: "alice"}, Decision{})
+	if got := r.Score("alice"); got < 0.8 || got > 0.82 {
+		t.Errorf("Expected alice's score to recover to 0.81, got %.2f", got)
+	}
+	now = now.Add(25 * time.Hour)
+	if got := r.Score("mallory"); got != 1 {
+		t.Errorf("Expected an idle sender's score reset, got %.2f", got)
+	}
+}
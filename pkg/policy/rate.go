@@ -0,0 +1,140 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// RateAnomalyConfig configures a RateAnomaly policy
+type RateAnomalyConfig struct {
+	// Window is the span a sender's current rate is measured over
+	// (default: 10s)
+	Window time.Duration
+
+	// Baseline is the time constant of a sender's usual rate, a moving
+	// average of its past windows (default: 10m)
+	Baseline time.Duration
+
+	// Factor is how many times its usual rate a sender must post at to be
+	// anomalous (default: 5)
+	Factor float64
+
+	// MinMessages is how many messages a sender may post in a window
+	// before its rate is looked at, so quiet senders can chat in bursts
+	// (default: 20)
+	MinMessages int
+
+	// Action is taken on anomalous messages: Flag (the default) or Reject
+	Action Action
+
+	// IdleTTL is how long a sender may go quiet before its history is
+	// forgotten (default: an hour)
+	IdleTTL time.Duration
+}
+
+// withDefaults fills in unset fields
+func (c RateAnomalyConfig) withDefaults() RateAnomalyConfig {
+	if c.Window <= 0 {
+		c.Window = 10 * time.Second
+	}
+	if c.Baseline <= 0 {
+		c.Baseline = 10 * time.Minute
+	}
+	if c.Factor <= 0 {
+		c.Factor = 5
+	}
+	if c.MinMessages <= 0 {
+		c.MinMessages = 20
+	}
+	if c.Action == Allow {
+		c.Action = Flag
+	}
+	if c.IdleTTL <= 0 {
+		c.IdleTTL = time.Hour
+	}
+	return c
+}
+
+// senderRate is one sender's current window and usual rate
+type senderRate struct {
+	windowStart time.Time
+	count       int
+	baseline    float64 // Messages per second
+	last        time.Time
+}
+
+// RateAnomaly catches senders suddenly posting far faster than they
+// usually do, unlike a rate limit's fixed quota: a busy sender's usual
+// traffic passes, a quiet one turning into a flood doesn't. Rates are
+// tracked per server, for the messages it accepts.
+type RateAnomaly struct {
+	mu        sync.Mutex
+	config    RateAnomalyConfig
+	senders   map[string]*senderRate
+	lastPrune time.Time
+	now       func() time.Time
+}
+
+// NewRateAnomaly creates a rate anomaly policy
+func NewRateAnomaly(config RateAnomalyConfig) *RateAnomaly {
+	return &RateAnomaly{
+		config:  config.withDefaults(),
+		senders: make(map[string]*senderRate),
+		now:     time.Now,
+	}
+}
+
+// Check counts msg against its sender's window
+func (r *RateAnomaly) Check(ctx context.Context, msg Message) Decision {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	r.pruneLocked(now)
+
+	s, ok := r.senders[msg.SenderID]
+	if !ok {
+		s = &senderRate{windowStart: now}
+		r.senders[msg.SenderID] = s
+	}
+	if elapsed := now.Sub(s.windowStart); elapsed >= r.config.Window {
+		// Fold the closed window, and any quiet time since, into the
+		// usual rate
+		rate := float64(s.count) / elapsed.Seconds()
+		alpha := 1 - math.Exp(-elapsed.Seconds()/r.config.Baseline.Seconds())
+		s.baseline += alpha * (rate - s.baseline)
+		s.windowStart, s.count = now, 0
+	}
+	s.count++
+	s.last = now
+
+	if s.count <= r.config.MinMessages {
+		return Decision{}
+	}
+	rate := float64(s.count) / r.config.Window.Seconds()
+	if rate <= r.config.Factor*s.baseline {
+		return Decision{}
+	}
+	return Decision{
+		Action: r.config.Action,
+		Policy: "rate_anomaly",
+		Reason: fmt.Sprintf("sender %s posted %d messages in %s, usually %.2f/s",
+			msg.SenderID, s.count, r.config.Window, s.baseline),
+	}
+}
+
+// pruneLocked forgets senders idle for IdleTTL, at most once per IdleTTL
+func (r *RateAnomaly) pruneLocked(now time.Time) {
+	if now.Sub(r.lastPrune) < r.config.IdleTTL {
+		return
+	}
+	r.lastPrune = now
+	for sender, s := range r.senders {
+		if now.Sub(s.last) >= r.config.IdleTTL {
+			delete(r.senders, sender)
+		}
+	}
+}
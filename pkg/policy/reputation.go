@@ -0,0 +1,146 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ReputationConfig configures a Reputation policy. Scores run from 0 to 1;
+// every sender starts at 1.
+type ReputationConfig struct {
+	// FlagPenalty and RejectPenalty are taken off a sender's score for
+	// each of its messages flagged or rejected (default: 0.05 and 0.2)
+	FlagPenalty   float64
+	RejectPenalty float64
+
+	// Recovery is added back for each message accepted unflagged
+	// (default: 0.01)
+	Recovery float64
+
+	// FlagBelow and RejectBelow are the scores under which a sender's
+	// messages are flagged and rejected (default: 0.5 and 0.2)
+	FlagBelow   float64
+	RejectBelow float64
+
+	// IdleTTL is how long a sender may go quiet before its score is
+	// forgotten, starting it over at 1 (default: a day)
+	IdleTTL time.Duration
+}
+
+// withDefaults fills in unset fields
+func (c ReputationConfig) withDefaults() ReputationConfig {
+	if c.FlagPenalty <= 0 {
+		c.FlagPenalty = 0.05
+	}
+	if c.RejectPenalty <= 0 {
+		c.RejectPenalty = 0.2
+	}
+	if c.Recovery <= 0 {
+		c.Recovery = 0.01
+	}
+	if c.FlagBelow <= 0 {
+		c.FlagBelow = 0.5
+	}
+	if c.RejectBelow <= 0 {
+		c.RejectBelow = 0.2
+	}
+	if c.IdleTTL <= 0 {
+		c.IdleTTL = 24 * time.Hour
+	}
+	return c
+}
+
+// reputation is one sender's score
+type reputation struct {
+	score float64
+	last  time.Time
+}
+
+// Reputation scores senders by how their messages fared with every policy
+// and refuses those who keep abusing the chat, even once the abuse no
+// longer trips the policy that caught it. Scores are kept per server.
+type Reputation struct {
+	mu        sync.Mutex
+	config    ReputationConfig
+	senders   map[string]*reputation
+	lastPrune time.Time
+	now       func() time.Time
+}
+
+// NewReputation creates a reputation policy
+func NewReputation(config ReputationConfig) *Reputation {
+	return &Reputation{
+		config:  config.withDefaults(),
+		senders: make(map[string]*reputation),
+		now:     time.Now,
+	}
+}
+
+// Check flags or rejects msg if its sender's score is low
+func (r *Reputation) Check(ctx context.Context, msg Message) Decision {
+	score := r.Score(msg.SenderID)
+	switch {
+	case score < r.config.RejectBelow:
+		return Decision{Action: Reject, Policy: "reputation",
+			Reason: fmt.Sprintf("sender %s has reputation %.2f", msg.SenderID, score)}
+	case score < r.config.FlagBelow:
+		return Decision{Action: Flag, Policy: "reputation",
+			Reason: fmt.Sprintf("sender %s has reputation %.2f", msg.SenderID, score)}
+	default:
+		return Decision{}
+	}
+}
+
+// Observe adjusts the sender's score for the outcome of its message. The
+// policy's own verdicts leave the score as it is: a sender it rejects stays
+// rejected until it goes quiet for IdleTTL.
+func (r *Reputation) Observe(msg Message, outcome Decision) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	r.pruneLocked(now)
+
+	s, ok := r.senders[msg.SenderID]
+	if !ok || now.Sub(s.last) >= r.config.IdleTTL {
+		s = &reputation{score: 1}
+		r.senders[msg.SenderID] = s
+	}
+	s.last = now
+	switch {
+	case outcome.Policy == "reputation":
+	case outcome.Action == Reject:
+		s.score -= r.config.RejectPenalty
+	case outcome.Action == Flag:
+		s.score -= r.config.FlagPenalty
+	default:
+		s.score += r.config.Recovery
+	}
+	s.score = min(max(s.score, 0), 1)
+}
+
+// Score returns the sender's current score
+func (r *Reputation) Score(senderID string) float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if s, ok := r.senders[senderID]; ok && r.now().Sub(s.last) < r.config.IdleTTL {
+		return s.score
+	}
+	return 1
+}
+
+// pruneLocked forgets senders idle for IdleTTL, at most once per IdleTTL
+func (r *Reputation) pruneLocked(now time.Time) {
+	if now.Sub(r.lastPrune) < r.config.IdleTTL {
+		return
+	}
+	r.lastPrune = now
+	for sender, s := range r.senders {
+		if now.Sub(s.last) >= r.config.IdleTTL {
+			delete(r.senders, sender)
+		}
+	}
+}
@@ -0,0 +1,288 @@
+// Package presence tracks which users are online. A user is online while
+// any of its sources is live: a subscription stream on some server, or
+// heartbeats from one of its clients. Each source is reported with a TTL
+// and refreshed before it runs out, so a source whose reporter vanished
+// expires on its own. Once the last one goes, the user is offline, last
+// seen when it did.
+package presence
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/pkg/clock"
+)
+
+// Status is whether a user is online
+type Status int
+
+const (
+	// Unknown users were never seen by this tracker
+	Unknown Status = iota
+	Online
+	Offline
+)
+
+// String returns the status's name
+func (s Status) String() string {
+	switch s {
+	case Online:
+		return "online"
+	case Offline:
+		return "offline"
+	default:
+		return "unknown"
+	}
+}
+
+// State is a user's presence
+type State struct {
+	UserID   string
+	Status   Status
+	LastSeen time.Time // When the user was last online (now, while it is)
+}
+
+// Config configures a tracker
+type Config struct {
+	// TTL is how long a source stays live when reported without one
+	// (default: 60s)
+	TTL time.Duration
+
+	// Forget drops offline users last seen this long ago (default: 7 days)
+	Forget time.Duration
+
+	// Clock reads the time (default: the system clock)
+	Clock clock.Clock
+}
+
+// withDefaults fills in unset fields
+func (c Config) withDefaults() Config {
+	if c.TTL <= 0 {
+		c.TTL = 60 * time.Second
+	}
+	if c.Forget <= 0 {
+		c.Forget = 7 * 24 * time.Hour
+	}
+	if c.Clock == nil {
+		c.Clock = clock.System()
+	}
+	return c
+}
+
+// user is one user's live sources, and when each expires
+type user struct {
+	sources  map[string]time.Time
+	lastSeen time.Time
+}
+
+// online reports whether any source is live at now
+func (u *user) online(now time.Time) bool {
+	for _, expires := range u.sources {
+		if now.Before(expires) {
+			return true
+		}
+	}
+	return false
+}
+
+// watchBuffer is how many changes a watcher may fall behind by; beyond it,
+// a user's older pending change is replaced by its newest
+const watchBuffer = 64
+
+// Tracker holds the presence of the users routed to one server
+type Tracker struct {
+	mu       sync.Mutex
+	config   Config
+	users    map[string]*user
+	watchers map[*Watch]struct{}
+}
+
+// NewTracker creates a tracker without users
+func NewTracker(config Config) *Tracker {
+	return &Tracker{
+		config:   config.withDefaults(),
+		users:    make(map[string]*user),
+		watchers: make(map[*Watch]struct{}),
+	}
+}
+
+// TTL returns the TTL sources are given by default
+func (t *Tracker) TTL() time.Duration {
+	return t.config.TTL
+}
+
+// Touch marks source live for userID until ttl from now (the tracker's
+// TTL if ttl <= 0), returning the user's state
+func (t *Tracker) Touch(userID, source string, ttl time.Duration) State {
+	if ttl <= 0 {
+		ttl = t.config.TTL
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.config.Clock.Now()
+	u := t.users[userID]
+	if u == nil {
+		u = &user{sources: make(map[string]time.Time)}
+		t.users[userID] = u
+	}
+	wasOnline := u.online(now)
+	u.sources[source] = now.Add(ttl)
+	u.lastSeen = now
+
+	state := State{UserID: userID, Status: Online, LastSeen: now}
+	if !wasOnline {
+		t.notifyLocked(state)
+	}
+	return state
+}
+
+// Leave ends source for userID, returning the user's state: offline if it
+// was the last live one
+func (t *Tracker) Leave(userID, source string) State {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.config.Clock.Now()
+	u := t.users[userID]
+	if u == nil {
+		return State{UserID: userID}
+	}
+	wasOnline := u.online(now)
+	if wasOnline {
+		u.lastSeen = now
+	}
+	delete(u.sources, source)
+
+	state := t.stateLocked(userID, u, now)
+	if wasOnline && state.Status == Offline {
+		t.notifyLocked(state)
+	}
+	return state
+}
+
+// Get returns userID's state
+func (t *Tracker) Get(userID string) State {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.stateLocked(userID, t.users[userID], t.config.Clock.Now())
+}
+
+// stateLocked returns a user's state at now
+func (t *Tracker) stateLocked(userID string, u *user, now time.Time) State {
+	switch {
+	case u == nil:
+		return State{UserID: userID}
+	case u.online(now):
+		return State{UserID: userID, Status: Online, LastSeen: now}
+	default:
+		return State{UserID: userID, Status: Offline, LastSeen: u.lastSeen}
+	}
+}
+
+// Expire drops sources past their TTL, telling watchers of the users that
+// went offline with them, and forgets users offline for Forget. Call it
+// periodically, at a fraction of the TTL.
+func (t *Tracker) Expire() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.config.Clock.Now()
+	for userID, u := range t.users {
+		if len(u.sources) == 0 {
+			if now.Sub(u.lastSeen) >= t.config.Forget {
+				delete(t.users, userID)
+			}
+			continue
+		}
+		for source, expires := range u.sources {
+			if !now.Before(expires) {
+				delete(u.sources, source)
+				if expires.After(u.lastSeen) {
+					u.lastSeen = expires
+				}
+			}
+		}
+		if len(u.sources) == 0 {
+			t.notifyLocked(State{UserID: userID, Status: Offline, LastSeen: u.lastSeen})
+		}
+	}
+}
+
+// Online returns how many users are online
+func (t *Tracker) Online() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.config.Clock.Now()
+	n := 0
+	for _, u := range t.users {
+		if u.online(now) {
+			n++
+		}
+	}
+	return n
+}
+
+// Watch follows the given users' changes between online and offline. Their
+// current states are not sent; read them with Get.
+func (t *Tracker) Watch(userIDs ...string) *Watch {
+	w := &Watch{
+		tracker: t,
+		users:   make(map[string]bool, len(userIDs)),
+		ch:      make(chan State, watchBuffer),
+	}
+	for _, id := range userIDs {
+		w.users[id] = true
+	}
+
+	t.mu.Lock()
+	t.watchers[w] = struct{}{}
+	t.mu.Unlock()
+	return w
+}
+
+// notifyLocked hands a change to the watchers of its user. A watcher
+// that fell a whole buffer behind loses its oldest pending change.
+func (t *Tracker) notifyLocked(state State) {
+	for w := range t.watchers {
+		if !w.users[state.UserID] {
+			continue
+		}
+		for {
+			select {
+			case w.ch <- state:
+			default:
+				select {
+				case <-w.ch:
+				default:
+				}
+				continue
+			}
+			break
+		}
+	}
+}
+
+// Watch is a subscription to some users' changes
+type Watch struct {
+	tracker *Tracker
+	users   map[string]bool
+	ch      chan State
+	once    sync.Once
+}
+
+// C delivers the changes; it is closed by Close
+func (w *Watch) C() <-chan State {
+	return w.ch
+}
+
+// Close ends the watch
+func (w *Watch) Close() {
+	w.once.Do(func() {
+		w.tracker.mu.Lock()
+		delete(w.tracker.watchers, w)
+		w.tracker.mu.Unlock()
+		close(w.ch)
+	})
+}
@@ -0,0 +1,66 @@
+package presence
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/pkg/clock"
+)
+
+func TestTracker(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	clk := clock.NewVirtual(start)
+	tr := NewTracker(Config{TTL: 30 * time.Second, Clock: clk})
+	watch := tr.Watch("alice")
+	defer watch.Close()
+
+	if got := tr.Get("alice"); got.Status != Unknown {
+		t.Errorf("Expected alice unknown, got %+v", got)
+	}
+
+	// Online while either source is live
+	tr.Touch("alice", "stream:server-1", 0)
+	tr.Touch("alice", "heartbeat", 10*time.Second)
+	if got := <-watch.C(); got.Status != Online {
+		t.Errorf("Expected alice reported online, got %+v", got)
+	}
+	clk.Advance(15 * time.Second)
+	tr.Expire()
+	if got := tr.Get("alice"); got.Status != Online || tr.Online() != 1 {
+		t.Errorf("Expected alice still online on her stream, got %+v", got)
+	}
+
+	// Offline once the last source goes, last seen when it did
+	tr.Leave("alice", "stream:server-1")
+	left := clk.Now()
+	got := <-watch.C()
+	if got.Status != Offline || !got.LastSeen.Equal(left) {
+		t.Errorf("Expected alice offline, last seen %v, got %+v", left, got)
+	}
+
+	// A source nobody refreshes expires
+	tr.Touch("alice", "heartbeat", 0)
+	<-watch.C()
+	clk.Advance(time.Minute)
+	tr.Expire()
+	got = <-watch.C()
+	if got.Status != Offline || !got.LastSeen.Equal(left.Add(30*time.Second)) {
+		t.Errorf("Expected alice offline when her heartbeat expired, got %+v", got)
+	}
+
+	// Nothing is sent for users not watched
+	tr.Touch("bob", "heartbeat", 0)
+	select {
+	case got := <-watch.C():
+		t.Errorf("Expected no change for bob, got %+v", got)
+	default:
+	}
+
+	// Long-offline users are forgotten
+	clk.Advance(8 * 24 * time.Hour)
+	tr.Expire()
+	tr.Expire()
+	if got := tr.Get("alice"); got.Status != Unknown {
+		t.Errorf("Expected alice forgotten, got %+v", got)
+	}
+}
@@ -0,0 +1,167 @@
+// Package purge tracks cluster-wide deletions of a user's messages, e.g.
+// for GDPR erasure requests, as jobs that outlive the server running them.
+// A job lists every server of the cluster and records, for each, whether
+// it has purged the user's messages and how many; a job interrupted by a
+// crash, or left incomplete by servers that couldn't be reached, is
+// resumed from where it stopped, skipping the servers already done.
+package purge
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"time"
+)
+
+// ErrJobNotFound is returned for a job ID a store doesn't hold
+var ErrJobNotFound = errors.New("purge job not found")
+
+// State is where a job stands
+type State int
+
+const (
+	Running    State = iota // Servers are left to purge
+	Done                    // Every server purged the user's messages
+	Incomplete              // Some servers failed; resuming retries them
+)
+
+func (s State) String() string {
+	switch s {
+	case Running:
+		return "RUNNING"
+	case Done:
+		return "DONE"
+	case Incomplete:
+		return "INCOMPLETE"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// Progress is one server's part in a job
+type Progress struct {
+	ServerID string
+	Done     bool
+	Chats    int   // Chats that lost messages
+	Messages int64 // Messages purged
+	Attempts int
+	Err      string // Why the last attempt failed, until one succeeds
+}
+
+// Job is the purge of one user's messages from a cluster
+type Job struct {
+	ID     string
+	UserID string
+	Reason string // Given by the operator, e.g. a request reference
+
+	// Before is when the job was created: the user's messages sent up to
+	// then are purged, later ones kept
+	Before time.Time
+
+	State   State
+	Created time.Time
+	Updated time.Time
+
+	// Servers are the cluster's servers when the job was created, sorted
+	// by ID
+	Servers []Progress
+}
+
+// NewJob creates a running job purging userID's messages from servers
+func NewJob(userID, reason string, servers []string, now time.Time) Job {
+	job := Job{
+		ID:      newID(),
+		UserID:  userID,
+		Reason:  reason,
+		Before:  now,
+		State:   Running,
+		Created: now,
+		Updated: now,
+	}
+	job.Add(servers...)
+	return job
+}
+
+// newID returns a random job ID
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return "purge-" + hex.EncodeToString(b)
+}
+
+// Add includes servers that joined the cluster since the job was created,
+// as they may have received the user's messages from servers not yet
+// purged
+func (j *Job) Add(servers ...string) {
+	known := make(map[string]bool, len(j.Servers))
+	for _, p := range j.Servers {
+		known[p.ServerID] = true
+	}
+	for _, id := range servers {
+		if !known[id] {
+			known[id] = true
+			j.Servers = append(j.Servers, Progress{ServerID: id})
+		}
+	}
+	sort.Slice(j.Servers, func(a, b int) bool { return j.Servers[a].ServerID < j.Servers[b].ServerID })
+}
+
+// Pending returns the servers that haven't purged the user's messages yet
+func (j Job) Pending() []string {
+	var pending []string
+	for _, p := range j.Servers {
+		if !p.Done {
+			pending = append(pending, p.ServerID)
+		}
+	}
+	return pending
+}
+
+// ServersDone returns how many servers have purged the user's messages
+func (j Job) ServersDone() int {
+	return len(j.Servers) - len(j.Pending())
+}
+
+// Messages returns how many messages the job has purged so far
+func (j Job) Messages() int64 {
+	var n int64
+	for _, p := range j.Servers {
+		n += p.Messages
+	}
+	return n
+}
+
+// Record notes the outcome of purging one server at now: its counts if err
+// is nil, else the failure
+func (j *Job) Record(serverID string, chats int, messages int64, err error, now time.Time) {
+	for i := range j.Servers {
+		p := &j.Servers[i]
+		if p.ServerID != serverID {
+			continue
+		}
+		p.Attempts++
+		if err != nil {
+			p.Err = err.Error()
+		} else {
+			p.Done, p.Chats, p.Messages, p.Err = true, chats, messages, ""
+		}
+	}
+	j.Updated = now
+}
+
+// Finish settles a job once every pending server was attempted: Done if
+// they all succeeded, else Incomplete
+func (j *Job) Finish(now time.Time) {
+	j.State = Done
+	if len(j.Pending()) > 0 {
+		j.State = Incomplete
+	}
+	j.Updated = now
+}
+
+// copyJob copies a job so the copy's servers can change independently
+func copyJob(job Job) Job {
+	job.Servers = append([]Progress(nil), job.Servers...)
+	return job
+}
@@ -0,0 +1,74 @@
+package purge
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestJobProgress(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	job := NewJob("alice", "ticket-42", []string{"server-2", "server-1"}, start)
+	if job.State != Running || job.Servers[0].ServerID != "server-1" || !job.Before.Equal(start) {
+		t.Fatalf("Unexpected new job %+v", job)
+	}
+
+	job.Record("server-1", 2, 5, nil, start.Add(time.Second))
+	job.Record("server-2", 0, 0, errors.New("unreachable"), start.Add(time.Second))
+	job.Finish(start.Add(time.Second))
+	if job.State != Incomplete || job.ServersDone() != 1 || job.Messages() != 5 {
+		t.Errorf("Expected an incomplete job with 1 server and 5 messages done, got %+v", job)
+	}
+	if pending := job.Pending(); len(pending) != 1 || pending[0] != "server-2" || job.Servers[1].Err != "unreachable" {
+		t.Errorf("Expected server-2 pending with its error, got %v (%+v)", pending, job.Servers[1])
+	}
+
+	// Resuming retries the failed server, and servers that joined since
+	job.Add("server-1", "server-0")
+	if pending := job.Pending(); len(pending) != 2 || pending[0] != "server-0" {
+		t.Errorf("Expected server-0 added ahead of server-2, got %v", pending)
+	}
+	job.Record("server-0", 0, 0, nil, start.Add(time.Minute))
+	job.Record("server-2", 1, 3, nil, start.Add(time.Minute))
+	job.Finish(start.Add(time.Minute))
+	if job.State != Done || job.Messages() != 8 || job.Servers[2].Attempts != 2 || job.Servers[2].Err != "" {
+		t.Errorf("Expected the job done after a second attempt, got %+v", job)
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "purges.json")
+	store, err := OpenStore(path)
+	if err != nil {
+		t.Fatalf("OpenStore failed: %v", err)
+	}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	first := NewJob("alice", "", []string{"server-1"}, start)
+	second := NewJob("bob", "", []string{"server-1"}, start.Add(time.Hour))
+	store.Save(second)
+	store.Save(first)
+	first.Record("server-1", 1, 1, nil, start)
+	first.Finish(start)
+	store.Save(first)
+
+	// Reopened, as after a restart
+	store, err = OpenStore(path)
+	if err != nil {
+		t.Fatalf("OpenStore failed: %v", err)
+	}
+	jobs, _ := store.List()
+	if len(jobs) != 2 || jobs[0].ID != first.ID || jobs[0].State != Done || jobs[1].State != Running {
+		t.Errorf("Expected both jobs back, oldest first, got %+v", jobs)
+	}
+	if _, err := store.Get("purge-unknown"); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("Expected ErrJobNotFound, got %v", err)
+	}
+
+	// Stored jobs don't change with the caller's copy
+	job, _ := store.Get(second.ID)
+	job.Servers[0].Done = true
+	if again, _ := store.Get(second.ID); again.Servers[0].Done {
+		t.Error("Expected the stored job unchanged")
+	}
+}
@@ -0,0 +1,140 @@
+package purge
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// Store keeps purge jobs, so they can be followed and resumed
+type Store interface {
+	// Save creates or replaces a job
+	Save(job Job) error
+
+	// Get returns a job, or ErrJobNotFound
+	Get(id string) (Job, error)
+
+	// List returns every job, oldest first
+	List() ([]Job, error)
+}
+
+// MemoryStore is a Store in process memory, for tests and servers whose
+// jobs needn't survive a restart
+type MemoryStore struct {
+	mu   sync.RWMutex
+	jobs map[string]Job
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{jobs: make(map[string]Job)}
+}
+
+func (s *MemoryStore) Save(job Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs[job.ID] = copyJob(job)
+	return nil
+}
+
+func (s *MemoryStore) Get(id string) (Job, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	job, ok := s.jobs[id]
+	if !ok {
+		return Job{}, fmt.Errorf("%w: %s", ErrJobNotFound, id)
+	}
+	return copyJob(job), nil
+}
+
+func (s *MemoryStore) List() ([]Job, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	jobs := make([]Job, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		jobs = append(jobs, copyJob(job))
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		if !jobs[i].Created.Equal(jobs[j].Created) {
+			return jobs[i].Created.Before(jobs[j].Created)
+		}
+		return jobs[i].ID < jobs[j].ID
+	})
+	return jobs, nil
+}
+
+// FileStore is a Store kept in a JSON file readable only by its owner, so
+// a server restarted after a crash resumes its jobs. Changes are written
+// to a temporary file renamed over the old one, so a crash leaves either
+// version. The file belongs to one server.
+type FileStore struct {
+	path string
+
+	mu   sync.Mutex
+	jobs *MemoryStore
+}
+
+// OpenStore opens the job store in path, creating it if it doesn't exist
+func OpenStore(path string) (*FileStore, error) {
+	f := &FileStore{path: path, jobs: NewMemoryStore()}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return f, f.save()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("purge: %w", err)
+	}
+	var jobs []Job
+	if err := json.Unmarshal(data, &jobs); err != nil {
+		return nil, fmt.Errorf("purge: %s: %w", path, err)
+	}
+	for _, job := range jobs {
+		f.jobs.Save(job)
+	}
+	return f, nil
+}
+
+func (f *FileStore) Save(job Job) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.jobs.Save(job)
+	return f.save()
+}
+
+func (f *FileStore) Get(id string) (Job, error) {
+	return f.jobs.Get(id)
+}
+
+func (f *FileStore) List() ([]Job, error) {
+	return f.jobs.List()
+}
+
+// save writes every job to the file; f.mu must be held, or f not yet
+// shared
+func (f *FileStore) save() error {
+	jobs, _ := f.jobs.List()
+	data, err := json.MarshalIndent(jobs, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("purge: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("purge: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("purge: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), f.path); err != nil {
+		return fmt.Errorf("purge: %w", err)
+	}
+	return nil
+}
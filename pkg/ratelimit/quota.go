@@ -0,0 +1,106 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Source grants tokens from a sender's authoritative bucket
+type Source interface {
+	Acquire(ctx context.Context, key string, n int) (int, error)
+}
+
+// SourceFunc adapts a function to a Source
+type SourceFunc func(ctx context.Context, key string, n int) (int, error)
+
+// Acquire calls f
+func (f SourceFunc) Acquire(ctx context.Context, key string, n int) (int, error) {
+	return f(ctx, key, n)
+}
+
+// lease is a batch of tokens taken from a sender's owner
+type lease struct {
+	tokens  int
+	expires time.Time
+}
+
+// Quota spends tokens leased from each sender's owner, asking the owner for
+// a new lease only when the current one is used up or expired
+type Quota struct {
+	mu        sync.Mutex
+	config    Config
+	source    Source
+	leases    map[string]*lease
+	lastPrune time.Time
+	now       func() time.Time
+}
+
+// NewQuota creates a quota that leases tokens from source
+func NewQuota(config Config, source Source) *Quota {
+	return &Quota{
+		config: config.withDefaults(),
+		source: source,
+		leases: make(map[string]*lease),
+		now:    time.Now,
+	}
+}
+
+// Allow spends one of key's tokens, leasing more if needed. It returns
+// false when the sender is over its quota, and an error when no lease could
+// be obtained.
+func (q *Quota) Allow(ctx context.Context, key string) (bool, error) {
+	if q.take(key) {
+		return true, nil
+	}
+
+	granted, err := q.source.Acquire(ctx, key, q.config.Lease)
+	if err != nil {
+		return false, err
+	}
+	if granted <= 0 {
+		return false, nil
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := q.now()
+	l, ok := q.leases[key]
+	if !ok || !now.Before(l.expires) {
+		l = &lease{}
+		q.leases[key] = l
+	}
+	l.tokens += granted - 1 // One is spent now
+	l.expires = now.Add(q.config.LeaseTTL)
+	return true, nil
+}
+
+// take spends a token from key's current lease, if it has one
+func (q *Quota) take(key string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := q.now()
+	q.pruneLocked(now)
+
+	l, ok := q.leases[key]
+	if !ok || l.tokens <= 0 || !now.Before(l.expires) {
+		return false
+	}
+	l.tokens--
+	return true
+}
+
+// pruneLocked forgets expired leases, at most once per LeaseTTL
+func (q *Quota) pruneLocked(now time.Time) {
+	if now.Sub(q.lastPrune) < q.config.LeaseTTL {
+		return
+	}
+	q.lastPrune = now
+	for key, l := range q.leases {
+		if !now.Before(l.expires) {
+			delete(q.leases, key)
+		}
+	}
+}
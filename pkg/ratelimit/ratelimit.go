@@ -0,0 +1,144 @@
+// Package ratelimit enforces per-sender message quotas across a cluster.
+// Each sender's token bucket lives on one server, picked by hashing the
+// sender onto the ring. Servers accepting the sender's messages lease tokens
+// from that owner in small batches and spend them locally, so a sender who
+// spreads messages over many chats, and therefore many servers, still draws
+// from a single quota.
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Config contains configuration for a rate limiter
+type Config struct {
+	// Rate is the sustained number of messages allowed per second per sender
+	Rate float64
+
+	// Burst is the bucket size: messages a sender may send at once after
+	// being idle (default: Rate, at least 1)
+	Burst int
+
+	// Lease is the number of tokens a server takes from a sender's owner at
+	// a time (default: Burst/10, at least 1). Larger leases mean fewer
+	// round trips but let a sender briefly exceed its rate across servers.
+	Lease int
+
+	// LeaseTTL is how long leased tokens stay usable. Unused tokens are
+	// dropped afterwards rather than returned (default: 1s).
+	LeaseTTL time.Duration
+
+	// IdleTTL is how long a bucket may go unused before it is forgotten
+	// (default: 10m)
+	IdleTTL time.Duration
+}
+
+// withDefaults fills in unset fields
+func (c Config) withDefaults() Config {
+	if c.Burst <= 0 {
+		c.Burst = int(c.Rate)
+		if c.Burst < 1 {
+			c.Burst = 1
+		}
+	}
+	if c.Lease <= 0 {
+		c.Lease = c.Burst / 10
+		if c.Lease < 1 {
+			c.Lease = 1
+		}
+	}
+	if c.Lease > c.Burst {
+		c.Lease = c.Burst
+	}
+	if c.LeaseTTL <= 0 {
+		c.LeaseTTL = time.Second
+	}
+	if c.IdleTTL <= 0 {
+		c.IdleTTL = 10 * time.Minute
+	}
+	return c
+}
+
+// bucket is one sender's token bucket
+type bucket struct {
+	tokens float64
+	last   time.Time // When tokens was last refilled
+}
+
+// Limiter holds the authoritative token buckets of the senders a server
+// owns
+type Limiter struct {
+	mu        sync.Mutex
+	config    Config
+	buckets   map[string]*bucket
+	lastPrune time.Time
+	now       func() time.Time
+}
+
+// NewLimiter creates a limiter with every bucket starting full
+func NewLimiter(config Config) *Limiter {
+	return &Limiter{
+		config:  config.withDefaults(),
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// Config returns the limiter's configuration with defaults applied
+func (l *Limiter) Config() Config {
+	return l.config
+}
+
+// Acquire takes up to n tokens from key's bucket and returns how many were
+// granted (0 when the sender is over its quota)
+func (l *Limiter) Acquire(key string, n int) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.pruneLocked(now)
+
+	burst := float64(l.config.Burst)
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens += now.Sub(b.last).Seconds() * l.config.Rate
+	if b.tokens > burst {
+		b.tokens = burst
+	}
+	b.last = now
+
+	granted := n
+	if available := int(b.tokens); granted > available {
+		granted = available
+	}
+	if granted < 0 {
+		granted = 0
+	}
+	b.tokens -= float64(granted)
+	return granted
+}
+
+// Len returns the number of buckets held
+func (l *Limiter) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.buckets)
+}
+
+// pruneLocked forgets buckets idle for IdleTTL, at most once per IdleTTL.
+// An idle bucket has refilled, so forgetting it changes nothing.
+func (l *Limiter) pruneLocked(now time.Time) {
+	if now.Sub(l.lastPrune) < l.config.IdleTTL {
+		return
+	}
+	l.lastPrune = now
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= l.config.IdleTTL {
+			delete(l.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeClock is a manually advanced time source
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time { return c.t }
+
+func TestLimiterRefills(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(0, 0)}
+	limiter := NewLimiter(Config{Rate: 10, Burst: 5})
+	limiter.now = clock.now
+
+	if got := limiter.Acquire("alice", 3); got != 3 {
+		t.Errorf("Expected 3 tokens from a full bucket, got %d", got)
+	}
+	if got := limiter.Acquire("alice", 3); got != 2 {
+		t.Errorf("Expected the remaining 2 tokens, got %d", got)
+	}
+	if got := limiter.Acquire("alice", 1); got != 0 {
+		t.Errorf("Expected an empty bucket, got %d", got)
+	}
+	if got := limiter.Acquire("bob", 1); got != 1 {
+		t.Errorf("Expected senders to have separate buckets, got %d", got)
+	}
+
+	clock.t = clock.t.Add(200 * time.Millisecond) // 2 tokens at 10/s
+	if got := limiter.Acquire("alice", 5); got != 2 {
+		t.Errorf("Expected 2 refilled tokens, got %d", got)
+	}
+
+	clock.t = clock.t.Add(time.Hour)
+	if got := limiter.Acquire("alice", 10); got != 5 {
+		t.Errorf("Expected refill to stop at the burst size, got %d", got)
+	}
+}
+
+func TestLimiterForgetsIdleBuckets(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(0, 0)}
+	limiter := NewLimiter(Config{Rate: 1, IdleTTL: time.Minute})
+	limiter.now = clock.now
+
+	limiter.Acquire("alice", 1)
+	clock.t = clock.t.Add(2 * time.Minute)
+	limiter.Acquire("bob", 1)
+	if limiter.Len() != 1 {
+		t.Errorf("Expected the idle bucket to be forgotten, got %d buckets", limiter.Len())
+	}
+}
+
+func TestQuotaSharedAcrossServers(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(0, 0)}
+	config := Config{Rate: 1, Burst: 10, Lease: 2}
+	owner := NewLimiter(config)
+	owner.now = clock.now
+
+	// Two servers leasing from the same owner share one quota
+	var acquires int
+	source := SourceFunc(func(ctx context.Context, key string, n int) (int, error) {
+		acquires++
+		return owner.Acquire(key, n), nil
+	})
+	servers := []*Quota{NewQuota(config, source), NewQuota(config, source)}
+	for _, q := range servers {
+		q.now = clock.now
+	}
+
+	allowed := 0
+	for i := 0; i < 20; i++ {
+		if ok, _ := servers[i%2].Allow(context.Background(), "alice"); ok {
+			allowed++
+		}
+	}
+	if allowed != 10 {
+		t.Errorf("Expected the burst of 10 across both servers, got %d", allowed)
+	}
+	if acquires >= 20 {
+		t.Errorf("Expected leases to save round trips, got %d acquires", acquires)
+	}
+}
+
+func TestQuotaLeasesExpire(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(0, 0)}
+	config := Config{Rate: 100, Burst: 100, Lease: 10, LeaseTTL: time.Second}
+	var acquires int
+	q := NewQuota(config, SourceFunc(func(ctx context.Context, key string, n int) (int, error) {
+		acquires++
+		return n, nil
+	}))
+	q.now = clock.now
+
+	q.Allow(context.Background(), "alice")
+	q.Allow(context.Background(), "alice")
+	clock.t = clock.t.Add(2 * time.Second)
+	q.Allow(context.Background(), "alice")
+	if acquires != 2 {
+		t.Errorf("Expected a new lease after expiry only, got %d acquires", acquires)
+	}
+}
+
+func TestQuotaSourceError(t *testing.T) {
+	unreachable := errors.New("owner unreachable")
+	q := NewQuota(Config{Rate: 1}, SourceFunc(func(ctx context.Context, key string, n int) (int, error) {
+		return 0, unreachable
+	}))
+
+	ok, err := q.Allow(context.Background(), "alice")
+	if ok || !errors.Is(err, unreachable) {
+		t.Errorf("Expected the source error, got %v, %v", ok, err)
+	}
+}
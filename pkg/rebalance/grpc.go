@@ -0,0 +1,113 @@
+package rebalance
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+
+	pb "github.com/sh4shv4t/DistriChat/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/proto"
+)
+
+// GRPCMover moves sessions with the servers' MigrationService, streaming
+// ExportSession from the old owner straight into ImportSession on the new one
+type GRPCMover struct {
+	mu    sync.Mutex
+	conns map[string]*grpc.ClientConn
+	opts  []grpc.DialOption
+}
+
+// NewGRPCMover creates a gRPC-backed mover, dialing servers with opts
+// (insecure credentials unless opts set others)
+func NewGRPCMover(opts ...grpc.DialOption) *GRPCMover {
+	return &GRPCMover{
+		conns: make(map[string]*grpc.ClientConn),
+		opts:  append([]grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}, opts...),
+	}
+}
+
+// Move executes one transfer
+func (m *GRPCMover) Move(ctx context.Context, transfer Transfer, throttle *Throttle) (Result, error) {
+	var result Result
+
+	fromConn, err := m.conn(transfer.FromAddress)
+	if err != nil {
+		return result, err
+	}
+	toConn, err := m.conn(transfer.ToAddress)
+	if err != nil {
+		return result, err
+	}
+
+	req := &pb.ExportSessionRequest{Ranges: make([]*pb.HashRange, 0, len(transfer.Ranges))}
+	for _, r := range transfer.Ranges {
+		req.Ranges = append(req.Ranges, &pb.HashRange{Start: r.Start, End: r.End})
+	}
+
+	export, err := pb.NewMigrationServiceClient(fromConn).ExportSession(ctx, req)
+	if err != nil {
+		return result, fmt.Errorf("export from %s: %w", transfer.From, err)
+	}
+	imports, err := pb.NewMigrationServiceClient(toConn).ImportSession(ctx)
+	if err != nil {
+		return result, fmt.Errorf("import to %s: %w", transfer.To, err)
+	}
+
+	for {
+		snapshot, err := export.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return result, fmt.Errorf("export from %s: %w", transfer.From, err)
+		}
+
+		size := proto.Size(snapshot)
+		if err := throttle.Wait(ctx, size); err != nil {
+			return result, err
+		}
+		if err := imports.Send(snapshot); err != nil {
+			return result, fmt.Errorf("import to %s: %w", transfer.To, err)
+		}
+		result.Sessions++
+		result.Bytes += int64(size)
+	}
+
+	resp, err := imports.CloseAndRecv()
+	if err != nil {
+		return result, fmt.Errorf("import to %s: %w", transfer.To, err)
+	}
+	result.Messages = int64(resp.Messages)
+	return result, nil
+}
+
+// Close closes all cached connections
+func (m *GRPCMover) Close() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for address, conn := range m.conns {
+		conn.Close()
+		delete(m.conns, address)
+	}
+}
+
+// conn returns a cached (lazily dialed) connection to address
+func (m *GRPCMover) conn(address string) (*grpc.ClientConn, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if conn, ok := m.conns[address]; ok {
+		return conn, nil
+	}
+
+	conn, err := grpc.Dial(address, m.opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", address, err)
+	}
+	m.conns[address] = conn
+	return conn, nil
+}
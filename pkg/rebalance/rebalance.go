@@ -0,0 +1,352 @@
+// Package rebalance moves chat sessions to their new owners when the ring
+// changes. On every topology change the Rebalancer diffs the previous ring
+// against the new one, groups the resulting migration plan by old and new
+// owner, and streams the affected sessions between them under a shared
+// bandwidth and operation budget, optionally only inside maintenance windows. Without it, a node joining the ring starts with an empty
+// cache while the history it now owns stays stranded on the old owner.
+package rebalance
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/pkg/logging"
+	"github.com/sh4shv4t/DistriChat/pkg/ring"
+)
+
+// Transfer is the unit of work: every session in Ranges moves From -> To
+type Transfer struct {
+	From        string
+	FromAddress string
+	To          string
+	ToAddress   string
+	Ranges      []ring.HashRange
+}
+
+// Result summarizes one completed transfer
+type Result struct {
+	Sessions int64 // Sessions streamed
+	Messages int64 // Messages new to the receiver
+	Bytes    int64 // Encoded size of everything streamed
+}
+
+// Mover executes transfers between servers
+type Mover interface {
+	Move(ctx context.Context, transfer Transfer, throttle *Throttle) (Result, error)
+}
+
+// Config contains configuration for a rebalancer
+type Config struct {
+	// BytesPerSecond caps transfer bandwidth across all moves (default: 1 MiB/s)
+	BytesPerSecond int64
+
+	// OpsPerSecond caps sessions moved per second across all moves
+	// (default: unlimited)
+	OpsPerSecond float64
+
+	// Windows, if set, are the daily maintenance windows in Location
+	// (default: time.Local) in which transfers may start. Outside them a
+	// plan waits; a transfer already running is allowed to finish.
+	Windows  []Window
+	Location *time.Location
+
+	// Timeout bounds a single transfer (default: 1m)
+	Timeout time.Duration
+
+	// Replicas is the number of copies of each chat kept per region
+	// (default: 1). Above one, plans copy every range to the nodes newly in
+	// its replica set from a surviving replica, so the chats of a node lost
+	// for good are re-replicated.
+	Replicas int
+
+	// Hasher places chats on the ring (default: CRC32). It must match the
+	// servers' (ServerConfig.Hasher), or plans move the wrong ranges.
+	Hasher ring.Hasher
+}
+
+// Stats tracks rebalancing activity
+type Stats struct {
+	Plans           int64 // Topology changes handled
+	Transfers       int64 // Transfers completed
+	FailedTransfers int64
+	Sessions        int64
+	Messages        int64
+	Bytes           int64
+}
+
+// Progress describes the plan being executed
+type Progress struct {
+	Epoch          uint64     // Epoch the plan moves sessions to
+	Transfers      int        // Transfers in the plan
+	Completed      int        // Transfers finished successfully
+	Failed         int        // Transfers that gave up
+	Pending        []Transfer // Transfers not yet finished, the running one first
+	BytesPerSecond int64      // Current bandwidth cap
+	OpsPerSecond   float64    // Current sessions-per-second cap
+	Windows        []Window   // Maintenance windows (none: always open)
+	PausedUntil    time.Time  // When the next window opens, while waiting for one
+}
+
+// Rebalancer migrates sessions after topology changes
+type Rebalancer struct {
+	config   Config
+	mover    Mover
+	throttle *Throttle
+	schedule Schedule
+
+	// applyMu serializes Apply so plans run in epoch order
+	applyMu sync.Mutex
+
+	mu      sync.Mutex
+	current ring.RingState // Last state sessions were migrated to
+	pending []Transfer     // Transfers of the current plan not yet finished
+	planned int            // Transfers in the current plan
+	done    int            // Completed transfers of the current plan
+	failed  int            // Failed transfers of the current plan
+	paused  time.Time      // Window the plan waits for (zero when running)
+	stats   Stats
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	log    *slog.Logger
+}
+
+// New creates a rebalancer that executes transfers with mover
+func New(config Config, mover Mover) *Rebalancer {
+	if config.BytesPerSecond <= 0 {
+		config.BytesPerSecond = 1 << 20
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = time.Minute
+	}
+	if config.Replicas <= 0 {
+		config.Replicas = 1
+	}
+
+	throttle := NewThrottle(config.BytesPerSecond)
+	throttle.SetOpsRate(config.OpsPerSecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Rebalancer{
+		config:   config,
+		mover:    mover,
+		throttle: throttle,
+		schedule: Schedule{Windows: config.Windows, Location: config.Location},
+		ctx:      ctx,
+		cancel:   cancel,
+		log:      logging.Logger("rebalance"),
+	}
+}
+
+// Epoch returns the epoch of the last state handled
+func (r *Rebalancer) Epoch() uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.current.Epoch
+}
+
+// Apply migrates sessions from the owners under the last handled state to
+// the owners under state, blocking until the transfers finish. States not
+// newer than the last one are ignored. Returns whether state was handled.
+// The signature matches topology.Applier, so a Rebalancer can follow any
+// topology stream.
+func (r *Rebalancer) Apply(state ring.RingState) bool {
+	r.applyMu.Lock()
+	defer r.applyMu.Unlock()
+
+	r.mu.Lock()
+	previous := r.current
+	if state.Epoch <= previous.Epoch {
+		r.mu.Unlock()
+		return false
+	}
+	r.current = state
+	r.stats.Plans++
+	r.mu.Unlock()
+
+	var opts []ring.Option
+	if r.config.Hasher != nil {
+		opts = append(opts, ring.WithHasher(r.config.Hasher))
+	}
+	transfers := Plan(previous, state, opts...)
+	if r.config.Replicas > 1 {
+		transfers = PlanReplicas(previous, state, r.config.Replicas, opts...)
+	}
+	r.mu.Lock()
+	r.pending = append([]Transfer(nil), transfers...)
+	r.planned, r.done, r.failed = len(transfers), 0, 0
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		r.pending = nil
+		r.mu.Unlock()
+	}()
+
+	if len(transfers) > 0 {
+		r.log.Info("Planned transfers", "from_epoch", previous.Epoch, logging.Epoch(state.Epoch),
+			"transfers", len(transfers))
+	}
+
+	for _, transfer := range transfers {
+		if err := r.awaitWindow(); err != nil {
+			break
+		}
+		r.run(transfer)
+	}
+	return true
+}
+
+// awaitWindow blocks until a maintenance window is open or the rebalancer
+// is stopped
+func (r *Rebalancer) awaitWindow() error {
+	now := time.Now()
+	next := r.schedule.Next(now)
+	if !next.After(now) {
+		return r.ctx.Err()
+	}
+
+	r.mu.Lock()
+	r.paused = next
+	r.mu.Unlock()
+	r.log.Info("Outside maintenance windows, pausing", "until", next.Format(time.RFC3339))
+
+	err := r.schedule.wait(r.ctx)
+
+	r.mu.Lock()
+	r.paused = time.Time{}
+	r.mu.Unlock()
+	return err
+}
+
+// run executes one transfer and records its outcome
+func (r *Rebalancer) run(transfer Transfer) {
+	ctx, cancel := context.WithTimeout(r.ctx, r.config.Timeout)
+	defer cancel()
+
+	result, err := r.mover.Move(ctx, transfer, r.throttle)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.pending = r.pending[1:] // Transfers run in plan order
+	r.stats.Sessions += result.Sessions
+	r.stats.Messages += result.Messages
+	r.stats.Bytes += result.Bytes
+	if err != nil {
+		r.failed++
+		r.stats.FailedTransfers++
+		r.log.Warn("Transfer failed", "from", transfer.From, "to", transfer.To,
+			"sessions", result.Sessions, logging.Err(err))
+		return
+	}
+	r.done++
+	r.stats.Transfers++
+	r.log.Info("Moved sessions", "from", transfer.From, "to", transfer.To,
+		"sessions", result.Sessions, "messages", result.Messages, "bytes", result.Bytes)
+}
+
+// Pending returns the transfers of the current plan that haven't finished,
+// the running one first. Sessions in their ranges may still be on the old
+// owner.
+func (r *Rebalancer) Pending() []Transfer {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Transfer(nil), r.pending...)
+}
+
+// Replicas returns the number of copies kept per chat and region
+func (r *Rebalancer) Replicas() int {
+	return r.config.Replicas
+}
+
+// Progress reports the plan being executed, or the last one if none is
+func (r *Rebalancer) Progress() Progress {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return Progress{
+		Epoch:          r.current.Epoch,
+		Transfers:      r.planned,
+		Completed:      r.done,
+		Failed:         r.failed,
+		Pending:        append([]Transfer(nil), r.pending...),
+		BytesPerSecond: r.throttle.Rate(),
+		OpsPerSecond:   r.throttle.OpsRate(),
+		Windows:        append([]Window(nil), r.schedule.Windows...),
+		PausedUntil:    r.paused,
+	}
+}
+
+// SetBytesPerSecond changes the bandwidth cap, taking effect for the next
+// bytes reserved (<= 0 removes the cap)
+func (r *Rebalancer) SetBytesPerSecond(bytesPerSecond int64) {
+	r.throttle.SetRate(bytesPerSecond)
+	r.log.Info("Bandwidth cap set", "bytes_per_second", bytesPerSecond)
+}
+
+// SetOpsPerSecond changes the sessions-per-second cap, taking effect for the
+// next session moved (<= 0 removes the cap)
+func (r *Rebalancer) SetOpsPerSecond(opsPerSecond float64) {
+	r.throttle.SetOpsRate(opsPerSecond)
+	r.log.Info("Operation cap set", "sessions_per_second", opsPerSecond)
+}
+
+// Stop cancels in-flight and pending transfers
+func (r *Rebalancer) Stop() {
+	r.cancel()
+}
+
+// Stats returns rebalancing statistics
+func (r *Rebalancer) Stats() Stats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.stats
+}
+
+// Plan groups the ring migration plan between two states into one transfer
+// per (old owner, new owner) pair, ordered for deterministic execution.
+// opts are the routing rings' (see ring.MigrationPlan).
+func Plan(from, to ring.RingState, opts ...ring.Option) []Transfer {
+	return group(ring.MigrationPlan(from, to, opts...))
+}
+
+// PlanReplicas groups the ring replication plan for n replicas per region
+// into one transfer per (source, new replica) pair
+func PlanReplicas(from, to ring.RingState, n int, opts ...ring.Option) []Transfer {
+	return group(ring.ReplicationPlan(from, to, n, opts...))
+}
+
+// group merges moves between the same pair of nodes into one transfer,
+// ordered for deterministic execution
+func group(moves []ring.Move) []Transfer {
+	byPair := make(map[[2]string]*Transfer)
+	for _, move := range moves {
+		key := [2]string{move.From, move.To}
+		t, ok := byPair[key]
+		if !ok {
+			t = &Transfer{
+				From:        move.From,
+				FromAddress: move.FromAddress,
+				To:          move.To,
+				ToAddress:   move.ToAddress,
+			}
+			byPair[key] = t
+		}
+		t.Ranges = append(t.Ranges, move.Range)
+	}
+
+	transfers := make([]Transfer, 0, len(byPair))
+	for _, t := range byPair {
+		transfers = append(transfers, *t)
+	}
+	sort.Slice(transfers, func(i, j int) bool {
+		if transfers[i].From != transfers[j].From {
+			return transfers[i].From < transfers[j].From
+		}
+		return transfers[i].To < transfers[j].To
+	})
+	return transfers
+}
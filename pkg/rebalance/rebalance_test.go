@@ -0,0 +1,249 @@
+package rebalance
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/pkg/ring"
+)
+
+// recordingMover records transfers instead of executing them
+type recordingMover struct {
+	mu        sync.Mutex
+	transfers []Transfer
+}
+
+func (m *recordingMover) Move(ctx context.Context, transfer Transfer, throttle *Throttle) (Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.transfers = append(m.transfers, transfer)
+	return Result{Sessions: 1}, nil
+}
+
+func stateOf(epoch uint64, ids ...string) ring.RingState {
+	state := ring.RingState{Epoch: epoch}
+	for _, id := range ids {
+		state.Nodes = append(state.Nodes, ring.NodeSpec{NodeID: id, Address: id + ":1", Capacity: 10})
+	}
+	return state
+}
+
+func TestPlanGroupsByOwnerPair(t *testing.T) {
+	transfers := Plan(stateOf(1, "a", "b"), stateOf(2, "a", "b", "c"))
+	if len(transfers) != 2 {
+		t.Fatalf("Expected transfers from a and b to c, got %d", len(transfers))
+	}
+	for i, want := range []string{"a", "b"} {
+		if transfers[i].From != want || transfers[i].To != "c" || len(transfers[i].Ranges) == 0 {
+			t.Errorf("Expected %s -> c with ranges, got %+v", want, transfers[i])
+		}
+	}
+}
+
+func TestApplyFollowsEpochs(t *testing.T) {
+	mover := &recordingMover{}
+	r := New(Config{}, mover)
+	defer r.Stop()
+
+	if !r.Apply(stateOf(1, "a", "b")) {
+		t.Fatal("Expected first state to be applied")
+	}
+	if len(mover.transfers) != 0 {
+		t.Errorf("Expected no transfers from an empty ring, got %d", len(mover.transfers))
+	}
+
+	r.Apply(stateOf(2, "a", "b", "c"))
+	if len(mover.transfers) != 2 {
+		t.Errorf("Expected 2 transfers after adding c, got %d", len(mover.transfers))
+	}
+
+	if r.Apply(stateOf(2, "a")) {
+		t.Error("Expected a state with the same epoch to be ignored")
+	}
+
+	stats := r.Stats()
+	if stats.Plans != 2 || stats.Transfers != 2 || stats.Sessions != 2 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestThrottle(t *testing.T) {
+	throttle := NewThrottle(1000) // 1000 bytes/s
+	ctx := context.Background()
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		throttle.Wait(ctx, 100)
+	}
+	// The first reservation is free; the next two wait 100ms each
+	if elapsed := time.Since(start); elapsed < 180*time.Millisecond {
+		t.Errorf("Expected about 200ms of throttling, took %v", elapsed)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	throttle.Wait(ctx, 10000)
+	if err := throttle.Wait(cancelled, 1); err == nil {
+		t.Error("Expected cancelled wait to fail")
+	}
+}
+
+// blockingMover holds every transfer until released
+type blockingMover struct {
+	started chan Transfer
+	release chan struct{}
+}
+
+func (m *blockingMover) Move(ctx context.Context, transfer Transfer, throttle *Throttle) (Result, error) {
+	m.started <- transfer
+	<-m.release
+	return Result{}, nil
+}
+
+func TestPendingTracksPlan(t *testing.T) {
+	mover := &blockingMover{started: make(chan Transfer), release: make(chan struct{})}
+	r := New(Config{}, mover)
+	defer r.Stop()
+
+	r.Apply(stateOf(1, "a", "b"))
+	done := make(chan struct{})
+	go func() {
+		r.Apply(stateOf(2, "a", "b", "c"))
+		close(done)
+	}()
+
+	first := <-mover.started
+	if pending := r.Pending(); len(pending) != 2 || pending[0].From != first.From {
+		t.Errorf("Expected 2 pending transfers starting with the running one, got %+v", pending)
+	}
+	mover.release <- struct{}{}
+
+	<-mover.started
+	if pending := r.Pending(); len(pending) != 1 {
+		t.Errorf("Expected 1 pending transfer, got %d", len(pending))
+	}
+	mover.release <- struct{}{}
+
+	<-done
+	if pending := r.Pending(); len(pending) != 0 {
+		t.Errorf("Expected no pending transfers after the plan, got %d", len(pending))
+	}
+}
+
+func TestReplicasReplaceLostNode(t *testing.T) {
+	mover := &recordingMover{}
+	r := New(Config{Replicas: 2}, mover)
+	defer r.Stop()
+
+	r.Apply(stateOf(1, "a", "b", "c"))
+	r.Apply(stateOf(2, "a", "c")) // b is gone for good
+
+	if len(mover.transfers) == 0 {
+		t.Fatal("Expected b's ranges to be re-replicated")
+	}
+	for _, transfer := range mover.transfers {
+		if transfer.From == "b" || transfer.To == "b" {
+			t.Errorf("Expected only surviving nodes in transfers, got %s -> %s", transfer.From, transfer.To)
+		}
+	}
+
+	progress := r.Progress()
+	if progress.Epoch != 2 || progress.Completed != len(mover.transfers) || len(progress.Pending) != 0 {
+		t.Errorf("Expected the finished epoch 2 plan, got %+v", progress)
+	}
+
+	r.SetBytesPerSecond(4096)
+	if rate := r.Progress().BytesPerSecond; rate != 4096 {
+		t.Errorf("Expected a cap of 4096 bytes/s, got %d", rate)
+	}
+}
+
+func TestScheduleWindows(t *testing.T) {
+	night, err := ParseWindow("22:00-06:00")
+	if err != nil {
+		t.Fatalf("Failed to parse window: %v", err)
+	}
+	if night.String() != "22:00-06:00" {
+		t.Errorf("Expected 22:00-06:00, got %s", night)
+	}
+	if _, err := ParseWindow("25:00-06:00"); err == nil {
+		t.Error("Expected an out-of-range hour to be rejected")
+	}
+
+	schedule := Schedule{Windows: []Window{night}, Location: time.UTC}
+	at := func(h, m int) time.Time { return time.Date(2024, 3, 1, h, m, 0, 0, time.UTC) }
+
+	for _, tc := range []struct {
+		t    time.Time
+		open bool
+	}{
+		{at(23, 0), true},
+		{at(3, 0), true},
+		{at(6, 0), false},
+		{at(12, 0), false},
+	} {
+		if got := schedule.Open(tc.t); got != tc.open {
+			t.Errorf("Expected open=%v at %s, got %v", tc.open, tc.t.Format("15:04"), got)
+		}
+	}
+
+	if next := schedule.Next(at(12, 0)); !next.Equal(at(22, 0)) {
+		t.Errorf("Expected next window at 22:00, got %v", next)
+	}
+	if next := schedule.Next(at(3, 0)); !next.Equal(at(3, 0)) {
+		t.Errorf("Expected an open schedule to return now, got %v", next)
+	}
+	if !(Schedule{}).Open(at(12, 0)) {
+		t.Error("Expected an empty schedule to always be open")
+	}
+}
+
+func TestThrottleOps(t *testing.T) {
+	throttle := NewThrottle(0) // No byte cap
+	throttle.SetOpsRate(10)    // One operation per 100ms
+	ctx := context.Background()
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		throttle.Wait(ctx, 1)
+	}
+	if elapsed := time.Since(start); elapsed < 180*time.Millisecond {
+		t.Errorf("Expected about 200ms of throttling, took %v", elapsed)
+	}
+}
+
+func TestApplyWaitsForWindow(t *testing.T) {
+	now := time.Now().In(time.UTC)
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	opens := now.Sub(midnight) + 300*time.Millisecond
+
+	mover := &recordingMover{}
+	r := New(Config{
+		Windows:  []Window{{Start: opens, End: opens + time.Hour}},
+		Location: time.UTC,
+	}, mover)
+	defer r.Stop()
+
+	r.Apply(stateOf(1, "a", "b"))
+	done := make(chan struct{})
+	go func() {
+		r.Apply(stateOf(2, "a", "b", "c"))
+		close(done)
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+	if progress := r.Progress(); progress.PausedUntil.IsZero() || progress.Completed != 0 {
+		t.Errorf("Expected the plan to wait for the window, got %+v", progress)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the plan to run once the window opened")
+	}
+	if len(mover.transfers) != 2 {
+		t.Errorf("Expected 2 transfers inside the window, got %d", len(mover.transfers))
+	}
+}
@@ -0,0 +1,133 @@
+package rebalance
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const day = 24 * time.Hour
+
+// Window is a daily period, as offsets from midnight, in which transfers may
+// start. A window whose End is before its Start wraps past midnight; one
+// whose End equals its Start lasts all day.
+type Window struct {
+	Start time.Duration
+	End   time.Duration
+}
+
+// ParseWindow parses a window written as "HH:MM-HH:MM", e.g. "22:00-06:00"
+func ParseWindow(s string) (Window, error) {
+	var startH, startM, endH, endM int
+	if _, err := fmt.Sscanf(s, "%d:%d-%d:%d", &startH, &startM, &endH, &endM); err != nil {
+		return Window{}, fmt.Errorf("invalid window %q: expected HH:MM-HH:MM", s)
+	}
+	for _, v := range []int{startH, endH} {
+		if v < 0 || v > 23 {
+			return Window{}, fmt.Errorf("invalid window %q: hour out of range", s)
+		}
+	}
+	for _, v := range []int{startM, endM} {
+		if v < 0 || v > 59 {
+			return Window{}, fmt.Errorf("invalid window %q: minute out of range", s)
+		}
+	}
+	return Window{
+		Start: time.Duration(startH)*time.Hour + time.Duration(startM)*time.Minute,
+		End:   time.Duration(endH)*time.Hour + time.Duration(endM)*time.Minute,
+	}, nil
+}
+
+// String formats the window as "HH:MM-HH:MM"
+func (w Window) String() string {
+	clock := func(d time.Duration) string {
+		d %= day
+		return fmt.Sprintf("%02d:%02d", int(d/time.Hour), int(d%time.Hour/time.Minute))
+	}
+	return clock(w.Start) + "-" + clock(w.End)
+}
+
+// contains reports whether an offset from midnight falls in the window
+func (w Window) contains(offset time.Duration) bool {
+	start, end := w.Start%day, w.End%day
+	switch {
+	case start == end:
+		return true
+	case start < end:
+		return offset >= start && offset < end
+	default:
+		return offset >= start || offset < end
+	}
+}
+
+// Schedule is a set of daily windows in a time zone. An empty schedule is
+// always open.
+type Schedule struct {
+	Windows  []Window
+	Location *time.Location // Default: time.Local
+}
+
+// Open reports whether t falls inside one of the windows
+func (s Schedule) Open(t time.Time) bool {
+	if len(s.Windows) == 0 {
+		return true
+	}
+	offset := t.Sub(s.midnight(t))
+	for _, w := range s.Windows {
+		if w.contains(offset) {
+			return true
+		}
+	}
+	return false
+}
+
+// Next returns t if the schedule is open at t, otherwise the time the next
+// window opens
+func (s Schedule) Next(t time.Time) time.Time {
+	if s.Open(t) {
+		return t
+	}
+
+	today := s.midnight(t)
+	tomorrow := today.AddDate(0, 0, 1)
+	var next time.Time
+	for _, w := range s.Windows {
+		for _, midnight := range []time.Time{today, tomorrow} {
+			start := midnight.Add(w.Start % day)
+			if start.After(t) && (next.IsZero() || start.Before(next)) {
+				next = start
+			}
+		}
+	}
+	return next
+}
+
+// midnight returns the start of t's day in the schedule's time zone
+func (s Schedule) midnight(t time.Time) time.Time {
+	loc := s.Location
+	if loc == nil {
+		loc = time.Local
+	}
+	year, month, date := t.In(loc).Date()
+	return time.Date(year, month, date, 0, 0, 0, 0, loc)
+}
+
+// wait blocks until the schedule is open or ctx is done
+func (s Schedule) wait(ctx context.Context) error {
+	for {
+		now := time.Now()
+		next := s.Next(now)
+		if !next.After(now) {
+			return nil
+		}
+
+		timer := time.NewTimer(next.Sub(now))
+		select {
+		case <-timer.C:
+			// Re-check: the clock or time zone may have shifted
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
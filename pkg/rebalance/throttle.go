@@ -0,0 +1,94 @@
+package rebalance
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Throttle paces transfers to a byte rate and an operation rate. Callers
+// reserve each operation (one session) and its bytes before sending it; each
+// reservation is scheduled after the previous ones, so concurrent transfers
+// share the budget.
+type Throttle struct {
+	mu     sync.Mutex
+	rate   float64   // Bytes per second (<= 0 means unlimited)
+	next   time.Time // When the next byte reservation may start
+	ops    float64   // Operations per second (<= 0 means unlimited)
+	nextOp time.Time // When the next operation may start
+}
+
+// NewThrottle creates a throttle allowing bytesPerSecond
+func NewThrottle(bytesPerSecond int64) *Throttle {
+	return &Throttle{rate: float64(bytesPerSecond)}
+}
+
+// SetRate changes the allowed rate (<= 0 means unlimited). Reservations
+// already scheduled keep their place.
+func (t *Throttle) SetRate(bytesPerSecond int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rate = float64(bytesPerSecond)
+}
+
+// Rate returns the allowed rate in bytes per second
+func (t *Throttle) Rate() int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return int64(t.rate)
+}
+
+// SetOpsRate changes the allowed operations per second (<= 0 means
+// unlimited)
+func (t *Throttle) SetOpsRate(opsPerSecond float64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.ops = opsPerSecond
+}
+
+// OpsRate returns the allowed operations per second
+func (t *Throttle) OpsRate() float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.ops
+}
+
+// Wait blocks until one more operation of n bytes may be sent or ctx is done
+func (t *Throttle) Wait(ctx context.Context, n int) error {
+	t.mu.Lock()
+	now := time.Now()
+	var delay time.Duration
+	if t.rate > 0 && n > 0 {
+		delay = reserve(&t.next, now, float64(n)/t.rate)
+	}
+	if t.ops > 0 {
+		if d := reserve(&t.nextOp, now, 1/t.ops); d > delay {
+			delay = d
+		}
+	}
+	t.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// reserve books seconds of a budget whose next free slot is *next, returning
+// how long until the reservation starts
+func reserve(next *time.Time, now time.Time, seconds float64) time.Duration {
+	if next.Before(now) {
+		*next = now
+	}
+	delay := next.Sub(now)
+	*next = next.Add(time.Duration(seconds * float64(time.Second)))
+	return delay
+}
@@ -0,0 +1,33 @@
+package ring
+
+// Placement is where a key lives under one ring: its replicas in every
+// region, each region's owner first
+type Placement struct {
+	Key      string
+	Epoch    uint64
+	Replicas map[string][]NodeInfo // By region
+}
+
+// Place returns the placement of a key with up to replicas copies per
+// region. Callers that need the epoch to match the nodes must keep the ring
+// from changing meanwhile.
+func (hr *HashRing) Place(key string, replicas int) Placement {
+	p := Placement{
+		Key:      key,
+		Epoch:    hr.Epoch(),
+		Replicas: make(map[string][]NodeInfo),
+	}
+	for _, region := range hr.Regions() {
+		p.Replicas[region] = hr.GetNodesInRegion(key, replicas, region)
+	}
+	return p
+}
+
+// Owner returns the key's owner in a region
+func (p Placement) Owner(region string) (NodeInfo, bool) {
+	nodes := p.Replicas[region]
+	if len(nodes) == 0 {
+		return NodeInfo{}, false
+	}
+	return nodes[0], true
+}
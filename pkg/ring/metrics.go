@@ -0,0 +1,61 @@
+package ring
+
+import "github.com/sh4shv4t/DistriChat/pkg/metrics"
+
+// ringMetrics holds a ring's series
+type ringMetrics struct {
+	nodes        metrics.GaugeVec
+	virtualNodes metrics.Gauge
+	epoch        metrics.Gauge
+	changes      metrics.Counter
+
+	// Namespaces reported before, so one that empties drops to zero
+	seen map[string]bool
+}
+
+// SetMetrics reports the ring's membership to reg from now on: physical
+// nodes per namespace ("tenant"), virtual nodes, the epoch, and the number
+// of membership changes applied
+func (hr *HashRing) SetMetrics(reg metrics.Registry) {
+	hr.mu.Lock()
+	defer hr.mu.Unlock()
+
+	hr.metrics = &ringMetrics{
+		nodes:        reg.Gauge("districhat_ring_nodes", "Physical nodes on the ring", "tenant"),
+		virtualNodes: reg.Gauge("districhat_ring_virtual_nodes", "Virtual nodes on the ring").With(),
+		epoch:        reg.Gauge("districhat_ring_epoch", "Epoch of the ring view").With(),
+		changes:      reg.Counter("districhat_ring_changes_total", "Membership changes applied to the ring").With(),
+		seen:         make(map[string]bool),
+	}
+	hr.observeLocked()
+}
+
+// changedLocked records a membership change (must be called with lock
+// held)
+func (hr *HashRing) changedLocked() {
+	hr.views = nil
+	if hr.metrics != nil {
+		hr.metrics.changes.Inc()
+		hr.observeLocked()
+	}
+}
+
+// observeLocked reports the current membership (must be called with lock
+// held)
+func (hr *HashRing) observeLocked() {
+	m := hr.metrics
+	if m == nil {
+		return
+	}
+
+	counts := make(map[string]int)
+	for _, namespace := range hr.nodeSpace {
+		counts[namespace]++
+		m.seen[namespace] = true
+	}
+	for namespace := range m.seen {
+		m.nodes.With(namespace).Set(float64(counts[namespace]))
+	}
+	m.virtualNodes.Set(float64(len(hr.nodes)))
+	m.epoch.Set(float64(hr.epoch))
+}
@@ -0,0 +1,36 @@
+package ring
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/sh4shv4t/DistriChat/pkg/metrics"
+)
+
+func TestRingMetrics(t *testing.T) {
+	hr := newTenantRing()
+	reg := metrics.NewPrometheus(nil)
+	hr.SetMetrics(reg)
+
+	hr.RemoveNode("gold-1")
+	hr.RemoveNode("gold-2")
+
+	var buf bytes.Buffer
+	if err := reg.Write(&buf); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	out := buf.String()
+
+	for _, want := range []string{
+		`districhat_ring_nodes{tenant=""} 2`,
+		`districhat_ring_nodes{tenant="gold"} 0`,
+		`districhat_ring_virtual_nodes 20`,
+		`districhat_ring_epoch 6`,
+		`districhat_ring_changes_total 2`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %q in output, got:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,268 @@
+package ring
+
+import "sort"
+
+// KeyHash returns the ring position of a key under the default hasher
+// (CRC32); HashRing.KeyHash uses the ring's
+func KeyHash(key string) uint32 {
+	return hashKey(key)
+}
+
+// HashRange is an arc of the ring holding the keys that hash into
+// (Start, End]. An arc with Start >= End wraps past zero.
+type HashRange struct {
+	Start uint32
+	End   uint32
+}
+
+// Contains reports whether a key hash falls inside the range
+func (r HashRange) Contains(hash uint32) bool {
+	if r.Start < r.End {
+		return hash > r.Start && hash <= r.End
+	}
+	return hash > r.Start || hash <= r.End
+}
+
+// Move is one step of a migration plan: keys in Range change owner
+type Move struct {
+	Range       HashRange
+	From        string // Owner under the old state
+	FromAddress string
+	To          string // Owner under the new state
+	ToAddress   string
+}
+
+// MigrationPlan lists the ranges of the ring whose owner differs between
+// two states. Moving the sessions in each range from From to To is all that
+// is needed to make ownership match the new state. Each namespace is a ring
+// of its own, and each region in it owns its own copy of every chat, so
+// they are planned independently; nothing moves in a region that is empty
+// in either state. opts, such as WithHasher, must match the routing rings'.
+func MigrationPlan(from, to RingState, opts ...Option) []Move {
+	var moves []Move
+	for _, part := range partitions(from, to) {
+		moves = append(moves, regionPlan(part.from, part.to, opts)...)
+	}
+	return moves
+}
+
+// regionPlan computes the migration plan between two single-region states
+func regionPlan(from, to RingState, opts []Option) []Move {
+	oldRing, newRing := ringFromState(from, nil, opts...), ringFromState(to, nil, opts...)
+	if len(oldRing.nodes) == 0 || len(newRing.nodes) == 0 {
+		return nil
+	}
+
+	boundaries := arcBoundaries(oldRing, newRing)
+
+	var moves []Move
+	prev := boundaries[len(boundaries)-1] // The first arc wraps around zero
+	for _, end := range boundaries {
+		oldOwner, newOwner := oldRing.ownerOf(end), newRing.ownerOf(end)
+		if oldOwner != newOwner {
+			if n := len(moves); n > 0 && moves[n-1].Range.End == prev &&
+				moves[n-1].From == oldOwner && moves[n-1].To == newOwner {
+				moves[n-1].Range.End = end // Extend the adjacent move
+			} else {
+				moves = append(moves, Move{
+					Range:       HashRange{Start: prev, End: end},
+					From:        oldOwner,
+					FromAddress: oldRing.nodeAddress[oldOwner],
+					To:          newOwner,
+					ToAddress:   newRing.nodeAddress[newOwner],
+				})
+			}
+		}
+		prev = end
+	}
+	return moves
+}
+
+// arcBoundaries returns the virtual node positions of both rings, sorted.
+// Between consecutive boundaries, every owner and replica set is fixed.
+func arcBoundaries(a, b *HashRing) []uint32 {
+	seen := make(map[uint32]bool)
+	boundaries := make([]uint32, 0, len(a.nodes)+len(b.nodes))
+	for _, vNodes := range [][]VirtualNode{a.nodes, b.nodes} {
+		for _, vNode := range vNodes {
+			if !seen[vNode.Hash] {
+				seen[vNode.Hash] = true
+				boundaries = append(boundaries, vNode.Hash)
+			}
+		}
+	}
+	sort.Slice(boundaries, func(i, j int) bool { return boundaries[i] < boundaries[j] })
+	return boundaries
+}
+
+// ReplicationPlan lists the copies needed to turn the replica sets of one
+// state into those of another, with n replicas per key and region. Every
+// node joining a range's replica set gets a move from a replica that held
+// the range before and is still on the ring, preferring the old owner. A
+// node leaving the ring for good is thereby replaced on every range it
+// held, as long as one of its fellow replicas survives. With n = 1 the plan
+// matches MigrationPlan, minus moves whose old owner is gone.
+func ReplicationPlan(from, to RingState, n int, opts ...Option) []Move {
+	if n < 1 {
+		n = 1
+	}
+	var moves []Move
+	for _, part := range partitions(from, to) {
+		moves = append(moves, regionReplicationPlan(part.from, part.to, n, opts)...)
+	}
+	return moves
+}
+
+// regionReplicationPlan computes the replication plan between two
+// single-region states
+func regionReplicationPlan(from, to RingState, n int, opts []Option) []Move {
+	oldRing, newRing := ringFromState(from, nil, opts...), ringFromState(to, nil, opts...)
+	if len(oldRing.nodes) == 0 || len(newRing.nodes) == 0 {
+		return nil
+	}
+
+	var moves []Move
+	boundaries := arcBoundaries(oldRing, newRing)
+	prev := boundaries[len(boundaries)-1] // The first arc wraps around zero
+	for _, end := range boundaries {
+		oldSet, newSet := oldRing.replicasOf(end, n), newRing.replicasOf(end, n)
+
+		// The first old replica still on the ring supplies the copies
+		source := ""
+		for _, nodeID := range oldSet {
+			if _, ok := newRing.nodeAddress[nodeID]; ok {
+				source = nodeID
+				break
+			}
+		}
+
+		for _, nodeID := range newSet {
+			if source == "" || contains(oldSet, nodeID) {
+				continue
+			}
+			if i := lastMove(moves, prev, source, nodeID); i >= 0 {
+				moves[i].Range.End = end // Extend the adjacent move
+				continue
+			}
+			moves = append(moves, Move{
+				Range:       HashRange{Start: prev, End: end},
+				From:        source,
+				FromAddress: newRing.nodeAddress[source],
+				To:          nodeID,
+				ToAddress:   newRing.nodeAddress[nodeID],
+			})
+		}
+		prev = end
+	}
+	return moves
+}
+
+// lastMove finds a move between the same nodes ending where the next arc
+// starts, among the moves added for the previous arc
+func lastMove(moves []Move, start uint32, from, to string) int {
+	for i := len(moves) - 1; i >= 0 && moves[i].Range.End == start; i-- {
+		if moves[i].From == from && moves[i].To == to {
+			return i
+		}
+	}
+	return -1
+}
+
+// contains reports whether nodeIDs includes nodeID
+func contains(nodeIDs []string, nodeID string) bool {
+	for _, id := range nodeIDs {
+		if id == nodeID {
+			return true
+		}
+	}
+	return false
+}
+
+// ringFromState builds a standalone ring for a state without logging,
+// reusing the virtual node hashes in known (which may be nil) rather than
+// hashing those nodes again
+func ringFromState(state RingState, known map[string][]uint32, opts ...Option) *HashRing {
+	hr := NewHashRing(0, opts...)
+	for _, node := range state.Nodes {
+		if hashes, ok := known[node.NodeID]; ok {
+			hr.vnodeHashes[node.NodeID] = hashes
+		}
+	}
+	for _, node := range state.Nodes {
+		capacity := node.Capacity
+		if capacity < 1 {
+			capacity = hr.replicas
+		}
+		hr.addVirtualNodes(node.NodeID, capacity, node.Address, node.Region, node.Namespace)
+	}
+	hr.sortNodes()
+	hr.epoch = state.Epoch
+	return hr
+}
+
+// ownerOf returns the physical node owning a ring position
+func (hr *HashRing) ownerOf(hash uint32) string {
+	hr.mu.RLock()
+	defer hr.mu.RUnlock()
+
+	idx := sort.Search(len(hr.nodes), func(i int) bool {
+		return hr.nodes[i].Hash >= hash
+	})
+	if idx >= len(hr.nodes) {
+		idx = 0
+	}
+	return hr.nodes[idx].NodeID
+}
+
+// replicasOf returns up to n distinct physical nodes in ring order from a
+// position, the position's owner first
+func (hr *HashRing) replicasOf(hash uint32, n int) []string {
+	hr.mu.RLock()
+	defer hr.mu.RUnlock()
+
+	start := sort.Search(len(hr.nodes), func(i int) bool {
+		return hr.nodes[i].Hash >= hash
+	})
+
+	nodeIDs := make([]string, 0, n)
+	for i := 0; i < len(hr.nodes) && len(nodeIDs) < n; i++ {
+		nodeID := hr.nodes[(start+i)%len(hr.nodes)].NodeID
+		if !contains(nodeIDs, nodeID) {
+			nodeIDs = append(nodeIDs, nodeID)
+		}
+	}
+	return nodeIDs
+}
+
+// partition is one namespace and region of two states, planned on its own
+type partition struct {
+	from, to RingState
+}
+
+// partitions splits two states into their namespaces and, within each,
+// their regions
+func partitions(from, to RingState) []partition {
+	var parts []partition
+	for _, namespace := range union(from, to, func(n NodeSpec) string { return n.Namespace }) {
+		from, to := from.InNamespace(namespace), to.InNamespace(namespace)
+		for _, region := range union(from, to, func(n NodeSpec) string { return n.Region }) {
+			parts = append(parts, partition{from: from.InRegion(region), to: to.InRegion(region)})
+		}
+	}
+	return parts
+}
+
+// union lists the distinct values of field over the nodes of either state,
+// sorted
+func union(a, b RingState, field func(NodeSpec) string) []string {
+	seen := make(map[string]bool)
+	for _, node := range append(append([]NodeSpec{}, a.Nodes...), b.Nodes...) {
+		seen[field(node)] = true
+	}
+	values := make([]string, 0, len(seen))
+	for value := range seen {
+		values = append(values, value)
+	}
+	sort.Strings(values)
+	return values
+}
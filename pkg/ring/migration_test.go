@@ -0,0 +1,147 @@
+package ring
+
+import (
+	"fmt"
+	"testing"
+)
+
+// moveFor returns the move covering key, if any
+func moveFor(moves []Move, key string) (Move, bool) {
+	hash := KeyHash(key)
+	for _, move := range moves {
+		if move.Range.Contains(hash) {
+			return move, true
+		}
+	}
+	return Move{}, false
+}
+
+func TestHashRangeContains(t *testing.T) {
+	r := HashRange{Start: 10, End: 20}
+	if r.Contains(10) || !r.Contains(11) || !r.Contains(20) || r.Contains(21) {
+		t.Errorf("Expected %+v to hold exactly (10, 20]", r)
+	}
+
+	wrap := HashRange{Start: 4000000000, End: 5}
+	if !wrap.Contains(4000000001) || !wrap.Contains(0) || !wrap.Contains(5) || wrap.Contains(6) {
+		t.Errorf("Expected %+v to wrap past zero", wrap)
+	}
+}
+
+func TestMigrationPlanMatchesOwnership(t *testing.T) {
+	from := NewHashRing(10)
+	from.AddNode("server-a", 10, "a:1")
+	from.AddNode("server-b", 10, "b:1")
+	from.AddNode("server-c", 10, "c:1")
+
+	to := NewHashRing(10)
+	to.Replace(from.State())
+	to.AddNode("server-d", 10, "d:1")
+	to.RemoveNode("server-b")
+
+	moves := MigrationPlan(from.State(), to.State())
+	if len(moves) == 0 {
+		t.Fatal("Expected moves after a membership change")
+	}
+
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("chat-%d", i)
+		oldOwner, _, _ := from.GetNode(key)
+		newOwner, _, _ := to.GetNode(key)
+
+		move, moved := moveFor(moves, key)
+		if oldOwner == newOwner && moved {
+			t.Errorf("Key %s stays on %s but is covered by move %+v", key, oldOwner, move)
+		}
+		if oldOwner != newOwner && (!moved || move.From != oldOwner || move.To != newOwner) {
+			t.Errorf("Key %s moves %s -> %s but plan says %+v (covered: %v)", key, oldOwner, newOwner, move, moved)
+		}
+	}
+}
+
+func TestMigrationPlanAddOnlyMovesToNewNode(t *testing.T) {
+	from := NewHashRing(10)
+	from.AddNode("server-a", 10, "a:1")
+	from.AddNode("server-b", 10, "b:1")
+
+	to := NewHashRing(10)
+	to.Replace(from.State())
+	to.AddNode("server-c", 10, "c:1")
+
+	for _, move := range MigrationPlan(from.State(), to.State()) {
+		if move.To != "server-c" || move.ToAddress != "c:1" {
+			t.Errorf("Expected every move to target server-c, got %+v", move)
+		}
+	}
+
+	if moves := MigrationPlan(RingState{}, to.State()); moves != nil {
+		t.Errorf("Expected no moves from an empty ring, got %d", len(moves))
+	}
+}
+
+func TestReplicationPlanRestoresReplicas(t *testing.T) {
+	from := NewHashRing(10)
+	for _, id := range []string{"server-a", "server-b", "server-c", "server-d"} {
+		from.AddNode(id, 10, id+":1")
+	}
+	to := NewHashRing(10)
+	to.Replace(from.State())
+	to.RemoveNode("server-b") // Lost for good
+
+	moves := ReplicationPlan(from.State(), to.State(), 2)
+	if len(moves) == 0 {
+		t.Fatal("Expected copies to replace server-b")
+	}
+
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("chat-%d", i)
+		oldSet := make(map[string]bool)
+		for _, node := range from.GetNodes(key, 2) {
+			oldSet[node.NodeID] = true
+		}
+
+		hash := KeyHash(key)
+		for _, node := range to.GetNodes(key, 2) {
+			if oldSet[node.NodeID] {
+				continue
+			}
+			found := false
+			for _, move := range moves {
+				if move.To == node.NodeID && move.Range.Contains(hash) {
+					found = true
+					if !oldSet[move.From] || move.From == "server-b" {
+						t.Errorf("Expected %s to copy from a surviving replica, got %s", key, move.From)
+					}
+				}
+			}
+			if !found {
+				t.Errorf("Expected a copy of %s to new replica %s", key, node.NodeID)
+			}
+		}
+	}
+
+	for _, move := range moves {
+		if move.From == "server-b" || move.To == "server-b" {
+			t.Errorf("Expected no moves involving the lost node, got %+v", move)
+		}
+	}
+}
+
+func TestReplicationPlanSingleReplica(t *testing.T) {
+	from := NewHashRing(10)
+	from.AddNode("server-a", 10, "a:1")
+	from.AddNode("server-b", 10, "b:1")
+	to := NewHashRing(10)
+	to.Replace(from.State())
+	to.AddNode("server-c", 10, "c:1")
+
+	plan, moves := ReplicationPlan(from.State(), to.State(), 1), MigrationPlan(from.State(), to.State())
+	if len(plan) != len(moves) {
+		t.Fatalf("Expected the single-replica plan to match MigrationPlan, got %d and %d moves", len(plan), len(moves))
+	}
+	for i := range plan {
+		if plan[i] != moves[i] {
+			t.Errorf("Expected %+v, got %+v", moves[i], plan[i])
+		}
+	}
+}
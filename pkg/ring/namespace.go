@@ -0,0 +1,69 @@
+package ring
+
+import "sort"
+
+// Namespaces returns the distinct namespaces of the ring's nodes, sorted. A
+// ring built without namespaces has the single default namespace "".
+func (hr *HashRing) Namespaces() []string {
+	hr.mu.RLock()
+	defer hr.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	for _, namespace := range hr.nodeSpace {
+		seen[namespace] = true
+	}
+	namespaces := make([]string, 0, len(seen))
+	for namespace := range seen {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+	return namespaces
+}
+
+// GetNodeNamespace returns the namespace of a given node ID
+func (hr *HashRing) GetNodeNamespace(nodeID string) (string, bool) {
+	hr.mu.RLock()
+	defer hr.mu.RUnlock()
+	namespace, ok := hr.nodeSpace[nodeID]
+	return namespace, ok
+}
+
+// Namespace returns the logical ring formed by one namespace's nodes, e.g.
+// the dedicated servers of a premium tier. Keys are placed as if those nodes
+// were the whole cluster, so a namespace's chats never land on another
+// namespace's servers and adding a node to one namespace moves nothing in
+// the others. The result is a read-only snapshot at the ring's current epoch;
+// it is cached until the next membership change. An unknown namespace yields
+// an empty ring.
+func (hr *HashRing) Namespace(namespace string) *HashRing {
+	hr.mu.RLock()
+	view, ok := hr.views[namespace]
+	hr.mu.RUnlock()
+	if ok {
+		return view
+	}
+
+	hr.mu.Lock()
+	defer hr.mu.Unlock()
+	if view, ok := hr.views[namespace]; ok {
+		return view
+	}
+	view = ringFromState(hr.stateLocked().InNamespace(namespace), hr.vnodeHashes, WithHasher(hr.hash))
+	if hr.views == nil {
+		hr.views = make(map[string]*HashRing)
+	}
+	hr.views[namespace] = view
+	return view
+}
+
+// InNamespace returns the subset of a state whose nodes belong to namespace,
+// keeping the state's epoch
+func (s RingState) InNamespace(namespace string) RingState {
+	filtered := RingState{Epoch: s.Epoch}
+	for _, node := range s.Nodes {
+		if node.Namespace == namespace {
+			filtered.Nodes = append(filtered.Nodes, node)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,100 @@
+package ring
+
+import (
+	"fmt"
+	"testing"
+)
+
+// newTenantRing places std-1, std-2 in the default namespace and gold-1,
+// gold-2 in "gold"
+func newTenantRing() *HashRing {
+	hr := NewHashRing(10)
+	hr.AddNode("std-1", 10, "std1:1")
+	hr.AddNode("std-2", 10, "std2:1")
+	hr.AddNodeInNamespace("gold-1", 10, "gold1:1", "", "gold")
+	hr.AddNodeInNamespace("gold-2", 10, "gold2:1", "", "gold")
+	return hr
+}
+
+func TestNamespaceIsolation(t *testing.T) {
+	hr := newTenantRing()
+
+	if namespaces := hr.Namespaces(); len(namespaces) != 2 || namespaces[0] != "" || namespaces[1] != "gold" {
+		t.Errorf("Expected namespaces [\"\" gold], got %q", namespaces)
+	}
+	if namespace, _ := hr.GetNodeNamespace("gold-2"); namespace != "gold" {
+		t.Errorf("Expected gold-2 in gold, got %q", namespace)
+	}
+
+	gold, std := hr.Namespace("gold"), hr.Namespace("")
+	if gold.Epoch() != hr.Epoch() {
+		t.Errorf("Expected the view at epoch %d, got %d", hr.Epoch(), gold.Epoch())
+	}
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("chat-%d", i)
+		for _, node := range gold.GetNodes(key, 3) {
+			if node.NodeID != "gold-1" && node.NodeID != "gold-2" {
+				t.Errorf("Expected only gold nodes for %s, got %s", key, node.NodeID)
+			}
+		}
+		for _, node := range std.GetNodes(key, 3) {
+			if node.NodeID != "std-1" && node.NodeID != "std-2" {
+				t.Errorf("Expected only default nodes for %s, got %s", key, node.NodeID)
+			}
+		}
+	}
+
+	if nodes := hr.Namespace("silver").GetNodes("chat-1", 1); len(nodes) != 0 {
+		t.Errorf("Expected no nodes in an unknown namespace, got %v", nodes)
+	}
+}
+
+func TestNamespaceViewRefreshes(t *testing.T) {
+	hr := newTenantRing()
+
+	before := hr.Namespace("gold")
+	if hr.Namespace("gold") != before {
+		t.Error("Expected the view to be cached between changes")
+	}
+
+	hr.AddNodeInNamespace("gold-3", 10, "gold3:1", "", "gold")
+	after := hr.Namespace("gold")
+	if after.GetNodeCount() != 3 || after.Epoch() != hr.Epoch() {
+		t.Errorf("Expected 3 gold nodes at epoch %d, got %d at %d", hr.Epoch(), after.GetNodeCount(), after.Epoch())
+	}
+
+	hr.RemoveNode("gold-1")
+	if hr.Namespace("gold").NodeExists("gold-1") {
+		t.Error("Expected a removed node to leave the view")
+	}
+}
+
+func TestMigrationPlanPerNamespace(t *testing.T) {
+	from := newTenantRing()
+	to := NewHashRing(10)
+	to.Replace(from.State())
+	to.AddNodeInNamespace("gold-3", 10, "gold3:1", "", "gold")
+
+	moves := MigrationPlan(from.State(), to.State())
+	if len(moves) == 0 {
+		t.Fatal("Expected gold ranges to move to gold-3")
+	}
+	for _, move := range moves {
+		if move.To != "gold-3" || (move.From != "gold-1" && move.From != "gold-2") {
+			t.Errorf("Expected only gold -> gold-3 moves, got %s -> %s", move.From, move.To)
+		}
+	}
+
+	state := to.State()
+	if ranges := OwnedRanges(state, "std-1"); len(ranges) == 0 {
+		t.Fatal("Expected std-1 to own ranges")
+	} else if OwnedRanges(from.State(), "std-1")[0] != ranges[0] {
+		t.Error("Expected a gold node to leave default ownership unchanged")
+	}
+
+	moved := to.State()
+	moved.Nodes[0].Namespace = "silver"
+	if moved.Digest() == state.Digest() {
+		t.Error("Expected a namespace change to change the digest")
+	}
+}
@@ -0,0 +1,120 @@
+package ring
+
+import "sort"
+
+// OwnedRanges returns the arcs of the ring whose keys nodeID owns in its
+// namespace and region under state, with adjacent arcs merged. A node alone
+// in its region owns the single range (x, x], which covers the whole ring.
+// opts, such as WithHasher, must match the routing rings'.
+func OwnedRanges(state RingState, nodeID string, opts ...Option) []HashRange {
+	var spec NodeSpec
+	found := false
+	for _, node := range state.Nodes {
+		if node.NodeID == nodeID {
+			spec, found = node, true
+			break
+		}
+	}
+	if !found {
+		return nil
+	}
+
+	hr := ringFromState(state.InNamespace(spec.Namespace).InRegion(spec.Region), nil, opts...)
+	var ranges []HashRange
+	prev := hr.nodes[len(hr.nodes)-1].Hash // The first arc wraps around zero
+	for _, vNode := range hr.nodes {
+		start := prev
+		prev = vNode.Hash
+		if vNode.NodeID != nodeID {
+			continue
+		}
+		if start == vNode.Hash && len(hr.nodes) > 1 {
+			continue // Shares its position with an earlier virtual node
+		}
+		if n := len(ranges); n > 0 && ranges[n-1].End == start {
+			ranges[n-1].End = vNode.Hash // Extend the adjacent arc
+		} else {
+			ranges = append(ranges, HashRange{Start: start, End: vNode.Hash})
+		}
+	}
+
+	// Join the arc ending at the last virtual node with the one wrapping
+	// past zero
+	if n := len(ranges); n > 1 && ranges[n-1].End == ranges[0].Start {
+		ranges[0].Start = ranges[n-1].Start
+		ranges = ranges[:n-1]
+	}
+	return ranges
+}
+
+// Share returns the fraction of its namespace and region's keys nodeID owns
+// under state, from 0 to 1
+func Share(state RingState, nodeID string, opts ...Option) float64 {
+	var size uint64
+	for _, r := range OwnedRanges(state, nodeID, opts...) {
+		span := uint64(r.End - r.Start)
+		if span == 0 {
+			span = 1 << 32 // The whole ring
+		}
+		size += span
+	}
+	return float64(size) / (1 << 32)
+}
+
+// SubtractRanges returns the parts of ranges that no range in cut covers,
+// with adjacent parts merged
+func SubtractRanges(ranges, cut []HashRange) []HashRange {
+	if len(cut) == 0 {
+		return append([]HashRange(nil), ranges...)
+	}
+
+	var result []HashRange
+	for _, r := range ranges {
+		// Split r at every cut boundary strictly inside it, in ring order
+		// from r.Start; each piece is then either wholly cut or not at all
+		span := uint64(r.End - r.Start)
+		if span == 0 {
+			span = 1 << 32 // The whole ring
+		}
+		var points []uint32
+		for _, c := range cut {
+			for _, p := range []uint32{c.Start, c.End} {
+				if offset := uint64(p - r.Start); offset > 0 && offset < span {
+					points = append(points, p)
+				}
+			}
+		}
+		sort.Slice(points, func(i, j int) bool {
+			return points[i]-r.Start < points[j]-r.Start
+		})
+		points = append(points, r.End)
+
+		prev := r.Start
+		for _, end := range points {
+			if end == prev && end != r.End {
+				continue // Duplicate boundary
+			}
+			piece := HashRange{Start: prev, End: end}
+			prev = end
+			if covered(cut, piece.End) {
+				continue
+			}
+			if n := len(result); n > 0 && result[n-1].End == piece.Start {
+				result[n-1].End = piece.End
+			} else {
+				result = append(result, piece)
+			}
+		}
+	}
+	return result
+}
+
+// covered reports whether any range holds hash
+func covered(ranges []HashRange, hash uint32) bool {
+	for _, r := range ranges {
+		if r.Contains(hash) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,96 @@
+package ring
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestOwnedRangesMatchOwnership(t *testing.T) {
+	hr := NewHashRing(10)
+	hr.AddNodeInRegion("server-a", 10, "a:1", "us")
+	hr.AddNodeInRegion("server-b", 10, "b:1", "us")
+	hr.AddNodeInRegion("server-c", 10, "c:1", "eu")
+	state := hr.State()
+
+	owned := map[string][]HashRange{
+		"server-a": OwnedRanges(state, "server-a"),
+		"server-b": OwnedRanges(state, "server-b"),
+		"server-c": OwnedRanges(state, "server-c"),
+	}
+
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("chat-%d", i)
+		for _, region := range []string{"us", "eu"} {
+			owner := hr.GetNodesInRegion(key, 1, region)[0].NodeID
+			for nodeID, ranges := range owned {
+				inRegion, _ := hr.GetNodeRegion(nodeID)
+				if inRegion != region {
+					continue
+				}
+				if got := covered(ranges, KeyHash(key)); got != (nodeID == owner) {
+					t.Errorf("Key %s: expected %s to own it %v, got %v", key, nodeID, nodeID == owner, got)
+				}
+			}
+		}
+	}
+
+	// Alone in its region, server-c owns the whole ring
+	if r := owned["server-c"]; len(r) != 1 || r[0].Start != r[0].End {
+		t.Errorf("Expected server-c to own one whole-ring range, got %v", r)
+	}
+
+	if got := OwnedRanges(state, "server-x"); got != nil {
+		t.Errorf("Expected no ranges for an unknown node, got %v", got)
+	}
+}
+
+func TestSubtractRanges(t *testing.T) {
+	ranges := []HashRange{{Start: 10, End: 50}, {Start: 4000000000, End: 5}}
+	cut := []HashRange{{Start: 20, End: 30}, {Start: 4100000000, End: 2}}
+
+	got := SubtractRanges(ranges, cut)
+	expected := []HashRange{
+		{Start: 10, End: 20}, {Start: 30, End: 50},
+		{Start: 4000000000, End: 4100000000}, {Start: 2, End: 5},
+	}
+	if fmt.Sprint(got) != fmt.Sprint(expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+
+	// Cutting a piece out of the whole ring leaves the rest of it
+	whole := SubtractRanges([]HashRange{{Start: 7, End: 7}}, []HashRange{{Start: 100, End: 200}})
+	for _, hash := range []uint32{7, 8, 100, 201, 4000000000} {
+		if !covered(whole, hash) {
+			t.Errorf("Expected %d to remain in %v", hash, whole)
+		}
+	}
+	if covered(whole, 150) {
+		t.Errorf("Expected 150 to be cut from %v", whole)
+	}
+
+	if got := SubtractRanges(ranges, []HashRange{{Start: 0, End: 0}}); len(got) != 0 {
+		t.Errorf("Expected a whole-ring cut to remove everything, got %v", got)
+	}
+}
+
+func TestShare(t *testing.T) {
+	hr := NewHashRing(100)
+	hr.AddNodeInRegion("server-a", 100, "a:1", "us")
+	hr.AddNodeInRegion("server-b", 300, "b:1", "us")
+	hr.AddNodeInRegion("server-c", 100, "c:1", "eu")
+	state := hr.State()
+
+	a, b := Share(state, "server-a"), Share(state, "server-b")
+	if diff := a + b - 1; diff > 1e-9 || diff < -1e-9 {
+		t.Errorf("Expected the region's shares to sum to 1, got %f + %f", a, b)
+	}
+	if b < 0.6 || b > 0.9 {
+		t.Errorf("Expected server-b's share near its 3/4 of the weight, got %f", b)
+	}
+	if c := Share(state, "server-c"); c != 1 {
+		t.Errorf("Expected server-c alone in its region to own everything, got %f", c)
+	}
+	if s := Share(state, "server-x"); s != 0 {
+		t.Errorf("Expected no share for an unknown node, got %f", s)
+	}
+}
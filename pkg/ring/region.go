@@ -0,0 +1,119 @@
+package ring
+
+import "sort"
+
+// Regions returns the distinct regions of the ring's nodes, sorted. A ring
+// built without regions has the single default region "".
+func (hr *HashRing) Regions() []string {
+	hr.mu.RLock()
+	defer hr.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	for _, region := range hr.nodeRegion {
+		seen[region] = true
+	}
+	regions := make([]string, 0, len(seen))
+	for region := range seen {
+		regions = append(regions, region)
+	}
+	sort.Strings(regions)
+	return regions
+}
+
+// GetNodeRegion returns the region of a given node ID
+func (hr *HashRing) GetNodeRegion(nodeID string) (string, bool) {
+	hr.mu.RLock()
+	defer hr.mu.RUnlock()
+	region, ok := hr.nodeRegion[nodeID]
+	return region, ok
+}
+
+// GetNodesInRegion returns up to count distinct nodes of one region, in ring
+// order from the key. The first is the key's owner within that region: each
+// region keeps its own copy of every chat, placed as if the region's nodes
+// formed a ring of their own.
+func (hr *HashRing) GetNodesInRegion(key string, count int, region string) []NodeInfo {
+	return hr.walk(key, func(nodes []NodeInfo) bool {
+		return len(nodes) >= count
+	}, func(nodeID string) bool {
+		return hr.nodeRegion[nodeID] == region
+	})
+}
+
+// GetNodesNear returns up to count distinct nodes for a key, ordered by
+// region proximity: nodes in regions[0] first, then regions[1], and so on,
+// then every other node. Within a region, nodes keep ring order, so the
+// first entry is the key's owner in the nearest region that has one. With no
+// regions given the order is the same as GetNodes.
+func (hr *HashRing) GetNodesNear(key string, count int, regions ...string) []NodeInfo {
+	all := hr.walk(key, func([]NodeInfo) bool { return false }, func(string) bool { return true })
+
+	rank := make(map[string]int, len(regions))
+	for i, region := range regions {
+		if _, ok := rank[region]; !ok {
+			rank[region] = i
+		}
+	}
+	rankOf := func(node NodeInfo) int {
+		if r, ok := rank[node.Region]; ok {
+			return r
+		}
+		return len(regions)
+	}
+	sort.SliceStable(all, func(i, j int) bool {
+		return rankOf(all[i]) < rankOf(all[j])
+	})
+
+	if len(all) > count {
+		all = all[:count]
+	}
+	return all
+}
+
+// walk visits distinct physical nodes in ring order from the key, collecting
+// those accepted by include until done reports the result is complete
+func (hr *HashRing) walk(key string, done func([]NodeInfo) bool, include func(nodeID string) bool) []NodeInfo {
+	hr.mu.RLock()
+	defer hr.mu.RUnlock()
+
+	if len(hr.nodes) == 0 {
+		return nil
+	}
+
+	hash := hr.hash(key)
+	startIdx := sort.Search(len(hr.nodes), func(i int) bool {
+		return hr.nodes[i].Hash >= hash
+	})
+	if startIdx >= len(hr.nodes) {
+		startIdx = 0
+	}
+
+	seen := make(map[string]bool)
+	var result []NodeInfo
+	for i := 0; i < len(hr.nodes) && !done(result); i++ {
+		nodeID := hr.nodes[(startIdx+i)%len(hr.nodes)].NodeID
+		if seen[nodeID] {
+			continue
+		}
+		seen[nodeID] = true
+		if include(nodeID) {
+			result = append(result, NodeInfo{
+				NodeID:  nodeID,
+				Address: hr.nodeAddress[nodeID],
+				Region:  hr.nodeRegion[nodeID],
+			})
+		}
+	}
+	return result
+}
+
+// InRegion returns the state restricted to one region's nodes
+func (s RingState) InRegion(region string) RingState {
+	filtered := RingState{Epoch: s.Epoch}
+	for _, node := range s.Nodes {
+		if node.Region == region {
+			filtered.Nodes = append(filtered.Nodes, node)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,127 @@
+package ring
+
+import (
+	"fmt"
+	"testing"
+)
+
+// newRegionalRing places us-1, us-2 in "us" and eu-1, eu-2 in "eu"
+func newRegionalRing() *HashRing {
+	hr := NewHashRing(10)
+	hr.AddNodeInRegion("us-1", 10, "us1:1", "us")
+	hr.AddNodeInRegion("us-2", 10, "us2:1", "us")
+	hr.AddNodeInRegion("eu-1", 10, "eu1:1", "eu")
+	hr.AddNodeInRegion("eu-2", 10, "eu2:1", "eu")
+	return hr
+}
+
+func TestGetNodesInRegion(t *testing.T) {
+	hr := newRegionalRing()
+
+	if regions := hr.Regions(); len(regions) != 2 || regions[0] != "eu" || regions[1] != "us" {
+		t.Errorf("Expected regions [eu us], got %v", regions)
+	}
+
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("chat-%d", i)
+		for _, region := range []string{"us", "eu"} {
+			nodes := hr.GetNodesInRegion(key, 3, region)
+			if len(nodes) != 2 {
+				t.Fatalf("Expected both %s nodes for %s, got %d", region, key, len(nodes))
+			}
+			for _, node := range nodes {
+				if node.Region != region {
+					t.Errorf("Expected only %s nodes, got %s in %s", region, node.NodeID, node.Region)
+				}
+			}
+		}
+	}
+
+	if nodes := hr.GetNodesInRegion("chat-1", 1, "ap"); len(nodes) != 0 {
+		t.Errorf("Expected no nodes in an unknown region, got %v", nodes)
+	}
+}
+
+func TestGetNodesNear(t *testing.T) {
+	hr := newRegionalRing()
+
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("chat-%d", i)
+		nodes := hr.GetNodesNear(key, 4, "eu", "us")
+		if len(nodes) != 4 {
+			t.Fatalf("Expected 4 nodes, got %d", len(nodes))
+		}
+		if nodes[0].Region != "eu" || nodes[1].Region != "eu" || nodes[2].Region != "us" {
+			t.Errorf("Expected eu nodes before us nodes, got %v", nodes)
+		}
+		if owner := hr.GetNodesInRegion(key, 1, "eu")[0]; nodes[0] != owner {
+			t.Errorf("Expected the eu owner %s first, got %s", owner.NodeID, nodes[0].NodeID)
+		}
+	}
+
+	// Without a preference the order is plain ring order
+	plain, near := hr.GetNodes("chat-7", 4), hr.GetNodesNear("chat-7", 4)
+	for i := range plain {
+		if plain[i] != near[i] {
+			t.Errorf("Expected ring order %v, got %v", plain, near)
+			break
+		}
+	}
+}
+
+func TestRegionsSurviveState(t *testing.T) {
+	hr := newRegionalRing()
+
+	copied := NewHashRing(10)
+	copied.Replace(hr.State())
+	if region, _ := copied.GetNodeRegion("eu-2"); region != "eu" {
+		t.Errorf("Expected eu-2 to stay in eu, got %q", region)
+	}
+	if copied.State().Digest() != hr.State().Digest() {
+		t.Error("Expected equal digests for equal regional membership")
+	}
+
+	moved := hr.State()
+	moved.Nodes[0].Region = "ap"
+	if moved.Digest() == hr.State().Digest() {
+		t.Error("Expected a region change to change the digest")
+	}
+}
+
+func TestMigrationPlanPerRegion(t *testing.T) {
+	from := newRegionalRing()
+	to := NewHashRing(10)
+	to.Replace(from.State())
+	to.AddNodeInRegion("eu-3", 10, "eu3:1", "eu")
+
+	moves := MigrationPlan(from.State(), to.State())
+	if len(moves) == 0 {
+		t.Fatal("Expected eu ranges to move to eu-3")
+	}
+	for _, move := range moves {
+		if move.To != "eu-3" || (move.From != "eu-1" && move.From != "eu-2") {
+			t.Errorf("Expected only eu -> eu-3 moves, got %s -> %s", move.From, move.To)
+		}
+	}
+}
+
+func TestPlace(t *testing.T) {
+	hr := newRegionalRing()
+
+	p := hr.Place("chat-7", 2)
+	if p.Epoch != hr.Epoch() || len(p.Replicas) != 2 {
+		t.Fatalf("Expected replicas in 2 regions at epoch %d, got %+v", hr.Epoch(), p)
+	}
+	for _, region := range []string{"us", "eu"} {
+		owner, ok := p.Owner(region)
+		if !ok || owner != hr.GetNodesInRegion("chat-7", 1, region)[0] {
+			t.Errorf("Expected the %s owner to match GetNodesInRegion, got %v", region, owner)
+		}
+		if len(p.Replicas[region]) != 2 {
+			t.Errorf("Expected 2 %s replicas, got %d", region, len(p.Replicas[region]))
+		}
+	}
+	if _, ok := p.Owner("ap"); ok {
+		t.Error("Expected no owner in an unknown region")
+	}
+}
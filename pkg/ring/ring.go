@@ -9,9 +9,13 @@ package ring
 import (
 	"fmt"
 	"hash/crc32"
-	"log"
+	"log/slog"
 	"sort"
+	"strconv"
 	"sync"
+	"unsafe"
+
+	"github.com/sh4shv4t/DistriChat/pkg/logging"
 )
 
 // VirtualNode represents a single point on the hash ring
@@ -25,48 +29,135 @@ type VirtualNode struct {
 // for load distribution across a cluster of servers.
 type HashRing struct {
 	mu           sync.RWMutex
-	nodes        []VirtualNode        // Sorted list of virtual nodes
-	nodeCapacity map[string]int       // Physical node -> capacity (number of virtual nodes)
-	nodeAddress  map[string]string    // Physical node -> network address
-	replicas     int                  // Default number of virtual nodes per physical node
+	nodes        []VirtualNode     // Sorted list of virtual nodes
+	nodeCapacity map[string]int    // Physical node -> capacity (number of virtual nodes)
+	nodeAddress  map[string]string // Physical node -> network address
+	nodeRegion   map[string]string // Physical node -> region ("" is the default region)
+	nodeSpace    map[string]string // Physical node -> namespace ("" is the default namespace)
+	replicas     int               // Default number of virtual nodes per physical node
+	epoch        uint64            // Incremented on every membership change
+
+	// Per-namespace rings built by Namespace, dropped on every change
+	views map[string]*HashRing
+
+	// Physical node -> hashes of its virtual nodes, by index. Kept across
+	// Replace so a new membership only hashes nodes it hasn't seen.
+	vnodeHashes map[string][]uint32
+
+	// Series the ring reports to, if SetMetrics was called
+	metrics *ringMetrics
+
+	hash Hasher // Places keys and virtual nodes on the ring
+	log  *slog.Logger
+}
+
+// Hasher maps a key to its position on the ring
+type Hasher func(key string) uint32
+
+// Option adjusts a ring as NewHashRing creates it
+type Option func(*HashRing)
+
+// WithHasher places keys and virtual nodes with h in place of CRC32. Every
+// ring routing the same keys, the client's and the servers', must use the
+// same hasher, and so must the plans and ranges computed for them
+// (MigrationPlan, ReplicationPlan, OwnedRanges), which take it as an option
+// too.
+func WithHasher(h Hasher) Option {
+	return func(hr *HashRing) { hr.hash = h }
+}
+
+// WithLogger makes the ring log to logger. By default it logs nothing,
+// so programs embedding it don't get its records on their output.
+func WithLogger(logger *slog.Logger) Option {
+	return func(hr *HashRing) { hr.log = logger }
 }
 
 // NewHashRing creates a new consistent hash ring.
 // The replicas parameter sets the default number of virtual nodes per physical node.
 // More virtual nodes = better load distribution but more memory usage.
-func NewHashRing(replicas int) *HashRing {
+func NewHashRing(replicas int, opts ...Option) *HashRing {
 	if replicas < 1 {
 		replicas = 100 // Default to 100 virtual nodes
 	}
-	return &HashRing{
+	hr := &HashRing{
 		nodes:        make([]VirtualNode, 0),
 		nodeCapacity: make(map[string]int),
 		nodeAddress:  make(map[string]string),
+		nodeRegion:   make(map[string]string),
+		nodeSpace:    make(map[string]string),
+		vnodeHashes:  make(map[string][]uint32),
 		replicas:     replicas,
+		hash:         hashKey,
+		log:          logging.Nop(),
 	}
+	for _, opt := range opts {
+		opt(hr)
+	}
+	return hr
+}
+
+// SetLogger replaces the ring's logger, e.g. with one also tagged with the
+// server holding the ring
+func (hr *HashRing) SetLogger(logger *slog.Logger) {
+	hr.mu.Lock()
+	defer hr.mu.Unlock()
+	hr.log = logger
 }
 
 // hashKey generates a consistent hash for a given key using CRC32
 // This provides fast, deterministic hashing suitable for consistent hashing.
+// It reads the key's bytes in place: converting to []byte would copy every
+// key looked up to the heap, and CRC32 only reads them.
 func hashKey(key string) uint32 {
-	return crc32.ChecksumIEEE([]byte(key))
+	return crc32.ChecksumIEEE(unsafe.Slice(unsafe.StringData(key), len(key)))
 }
 
 // virtualNodeKey generates a unique key for a virtual node
 func virtualNodeKey(nodeID string, vNodeIdx int) string {
-	return fmt.Sprintf("%s#%d", nodeID, vNodeIdx)
+	return nodeID + "#" + strconv.Itoa(vNodeIdx)
+}
+
+// hashesOf returns the hashes of a node's first capacity virtual nodes,
+// hashing only those not yet known (must be called with lock held). The
+// returned slice is never modified, so views of the ring may share it.
+func (hr *HashRing) hashesOf(nodeID string, capacity int) []uint32 {
+	hashes := hr.vnodeHashes[nodeID]
+	if len(hashes) < capacity {
+		grown := make([]uint32, capacity)
+		copy(grown, hashes)
+		for i := len(hashes); i < capacity; i++ {
+			grown[i] = hr.hash(virtualNodeKey(nodeID, i))
+		}
+		hashes = grown
+		hr.vnodeHashes[nodeID] = hashes
+	}
+	return hashes[:capacity]
 }
 
 // AddNode adds a physical node to the hash ring with a specified capacity.
 // The capacity determines how many virtual nodes this server gets.
 // Higher capacity servers should get more virtual nodes to handle more load.
 func (hr *HashRing) AddNode(nodeID string, capacity int, address string) {
+	hr.AddNodeInRegion(nodeID, capacity, address, "")
+}
+
+// AddNodeInRegion adds a physical node to the hash ring and records the
+// region it runs in. Regional lookups (see GetNodesInRegion) only consider
+// nodes in the requested region.
+func (hr *HashRing) AddNodeInRegion(nodeID string, capacity int, address string, region string) {
+	hr.AddNodeInNamespace(nodeID, capacity, address, region, "")
+}
+
+// AddNodeInNamespace adds a physical node to one of the ring's namespaces,
+// running in the given region. Each namespace is a logical ring of its own
+// (see Namespace); the default namespace is "".
+func (hr *HashRing) AddNodeInNamespace(nodeID string, capacity int, address string, region string, namespace string) {
 	hr.mu.Lock()
 	defer hr.mu.Unlock()
 
 	// Check if node already exists
 	if _, exists := hr.nodeCapacity[nodeID]; exists {
-		log.Printf("[RING] Node %s already exists, skipping...", nodeID)
+		hr.log.Warn("Node already exists, skipping", logging.NodeID(nodeID))
 		return
 	}
 
@@ -74,28 +165,38 @@ func (hr *HashRing) AddNode(nodeID string, capacity int, address string) {
 		capacity = hr.replicas
 	}
 
+	hr.addVirtualNodes(nodeID, capacity, address, region, namespace)
+	hr.sortNodes()
+	hr.epoch++
+	hr.changedLocked()
+
+	hr.log.Info("Added node", logging.NodeID(nodeID), "virtual_nodes", capacity, "address", address,
+		logging.Epoch(hr.epoch))
+}
+
+// addVirtualNodes records a physical node and appends its virtual nodes
+// (must be called with lock held; the caller is responsible for sorting)
+func (hr *HashRing) addVirtualNodes(nodeID string, capacity int, address string, region string, namespace string) {
 	hr.nodeCapacity[nodeID] = capacity
 	hr.nodeAddress[nodeID] = address
+	hr.nodeRegion[nodeID] = region
+	hr.nodeSpace[nodeID] = namespace
 
-	// Create virtual nodes
-	for i := 0; i < capacity; i++ {
-		vNodeKey := virtualNodeKey(nodeID, i)
-		hash := hashKey(vNodeKey)
-
-		vNode := VirtualNode{
+	for i, hash := range hr.hashesOf(nodeID, capacity) {
+		hr.nodes = append(hr.nodes, VirtualNode{
 			Hash:     hash,
 			NodeID:   nodeID,
 			VNodeIdx: i,
-		}
-		hr.nodes = append(hr.nodes, vNode)
+		})
 	}
+}
 
-	// Sort nodes by hash value for binary search
+// sortNodes sorts virtual nodes by hash value for binary search
+// (must be called with lock held)
+func (hr *HashRing) sortNodes() {
 	sort.Slice(hr.nodes, func(i, j int) bool {
 		return hr.nodes[i].Hash < hr.nodes[j].Hash
 	})
-
-	log.Printf("[RING] Added node %s with %d virtual nodes at %s", nodeID, capacity, address)
 }
 
 // RemoveNode removes a physical node and all its virtual nodes from the ring.
@@ -105,7 +206,7 @@ func (hr *HashRing) RemoveNode(nodeID string) {
 	defer hr.mu.Unlock()
 
 	if _, exists := hr.nodeCapacity[nodeID]; !exists {
-		log.Printf("[RING] Node %s not found, nothing to remove", nodeID)
+		hr.log.Warn("Node not found, nothing to remove", logging.NodeID(nodeID))
 		return
 	}
 
@@ -123,8 +224,13 @@ func (hr *HashRing) RemoveNode(nodeID string) {
 	hr.nodes = newNodes
 	delete(hr.nodeCapacity, nodeID)
 	delete(hr.nodeAddress, nodeID)
+	delete(hr.nodeRegion, nodeID)
+	delete(hr.nodeSpace, nodeID)
+	delete(hr.vnodeHashes, nodeID)
+	hr.epoch++
+	hr.changedLocked()
 
-	log.Printf("[RING] Removed node %s (%d virtual nodes removed). Keys rebalanced.", nodeID, removedCount)
+	hr.log.Info("Removed node", logging.NodeID(nodeID), "virtual_nodes", removedCount, logging.Epoch(hr.epoch))
 }
 
 // GetNode finds the physical node responsible for a given key.
@@ -138,7 +244,7 @@ func (hr *HashRing) GetNode(key string) (nodeID string, address string, ok bool)
 		return "", "", false
 	}
 
-	hash := hashKey(key)
+	hash := hr.hash(key)
 
 	// Binary search for the first node with hash >= key hash
 	idx := sort.Search(len(hr.nodes), func(i int) bool {
@@ -165,7 +271,7 @@ func (hr *HashRing) GetNodes(key string, count int) []NodeInfo {
 		return nil
 	}
 
-	hash := hashKey(key)
+	hash := hr.hash(key)
 
 	// Find starting position
 	startIdx := sort.Search(len(hr.nodes), func(i int) bool {
@@ -176,19 +282,22 @@ func (hr *HashRing) GetNodes(key string, count int) []NodeInfo {
 		startIdx = 0
 	}
 
-	// Collect distinct physical nodes
-	seen := make(map[string]bool)
+	// Collect distinct physical nodes. There are at most count of them, so
+	// scanning the result finds those seen without allocating a set.
+	if count > len(hr.nodeCapacity) {
+		count = len(hr.nodeCapacity)
+	}
 	result := make([]NodeInfo, 0, count)
 
 	for i := 0; i < len(hr.nodes) && len(result) < count; i++ {
 		idx := (startIdx + i) % len(hr.nodes)
 		nodeID := hr.nodes[idx].NodeID
 
-		if !seen[nodeID] {
-			seen[nodeID] = true
+		if !containsNode(result, nodeID) {
 			result = append(result, NodeInfo{
 				NodeID:  nodeID,
 				Address: hr.nodeAddress[nodeID],
+				Region:  hr.nodeRegion[nodeID],
 			})
 		}
 	}
@@ -196,10 +305,26 @@ func (hr *HashRing) GetNodes(key string, count int) []NodeInfo {
 	return result
 }
 
+// containsNode reports whether nodes include nodeID
+func containsNode(nodes []NodeInfo, nodeID string) bool {
+	for _, node := range nodes {
+		if node.NodeID == nodeID {
+			return true
+		}
+	}
+	return false
+}
+
 // NodeInfo contains information about a physical node
 type NodeInfo struct {
 	NodeID  string
 	Address string
+	Region  string
+}
+
+// KeyHash returns the ring position of a key under the ring's hasher
+func (hr *HashRing) KeyHash(key string) uint32 {
+	return hr.hash(key)
 }
 
 // GetNodeCount returns the number of physical nodes in the ring
@@ -252,16 +377,123 @@ func (hr *HashRing) GetNodeAddress(nodeID string) (string, bool) {
 	return addr, ok
 }
 
+// NodeSpec describes a physical node as it is placed on the ring
+type NodeSpec struct {
+	NodeID    string
+	Address   string
+	Capacity  int
+	Region    string
+	Namespace string
+}
+
+// RingState is a portable description of a ring's membership. Two rings
+// with equal states route every key identically.
+type RingState struct {
+	Epoch uint64
+	Nodes []NodeSpec // Sorted by NodeID
+}
+
+// Digest returns a short fingerprint of the membership (ignoring the epoch),
+// so views built independently can be compared cheaply.
+func (s RingState) Digest() string {
+	h := crc32.NewIEEE()
+	for _, node := range s.Nodes {
+		fmt.Fprintf(h, "%s|%s|%d", node.NodeID, node.Address, node.Capacity)
+		if node.Region != "" {
+			fmt.Fprintf(h, "|%s", node.Region)
+		}
+		if node.Namespace != "" {
+			fmt.Fprintf(h, "|ns=%s", node.Namespace)
+		}
+		h.Write([]byte{';'})
+	}
+	return fmt.Sprintf("%08x", h.Sum32())
+}
+
+// Epoch returns the ring's membership version
+func (hr *HashRing) Epoch() uint64 {
+	hr.mu.RLock()
+	defer hr.mu.RUnlock()
+	return hr.epoch
+}
+
+// State returns a snapshot of the ring's membership and epoch
+func (hr *HashRing) State() RingState {
+	hr.mu.RLock()
+	defer hr.mu.RUnlock()
+	return hr.stateLocked()
+}
+
+// stateLocked builds the ring's state (must be called with lock held)
+func (hr *HashRing) stateLocked() RingState {
+	nodes := make([]NodeSpec, 0, len(hr.nodeCapacity))
+	for nodeID, capacity := range hr.nodeCapacity {
+		nodes = append(nodes, NodeSpec{
+			NodeID:    nodeID,
+			Address:   hr.nodeAddress[nodeID],
+			Capacity:  capacity,
+			Region:    hr.nodeRegion[nodeID],
+			Namespace: hr.nodeSpace[nodeID],
+		})
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].NodeID < nodes[j].NodeID
+	})
+
+	return RingState{Epoch: hr.epoch, Nodes: nodes}
+}
+
+// Replace swaps the ring's membership for the given state if the state's
+// epoch is newer than the ring's. Returns whether the state was applied.
+func (hr *HashRing) Replace(state RingState) bool {
+	hr.mu.Lock()
+	defer hr.mu.Unlock()
+
+	if state.Epoch <= hr.epoch {
+		return false
+	}
+
+	hr.nodes = make([]VirtualNode, 0)
+	hr.nodeCapacity = make(map[string]int)
+	hr.nodeAddress = make(map[string]string)
+	hr.nodeRegion = make(map[string]string)
+	hr.nodeSpace = make(map[string]string)
+
+	for _, node := range state.Nodes {
+		capacity := node.Capacity
+		if capacity < 1 {
+			capacity = hr.replicas
+		}
+		hr.addVirtualNodes(node.NodeID, capacity, node.Address, node.Region, node.Namespace)
+	}
+	for nodeID := range hr.vnodeHashes {
+		if _, ok := hr.nodeCapacity[nodeID]; !ok {
+			delete(hr.vnodeHashes, nodeID)
+		}
+	}
+	hr.sortNodes()
+	hr.epoch = state.Epoch
+	hr.changedLocked()
+
+	hr.log.Info("Replaced membership", logging.Epoch(hr.epoch), "nodes", len(state.Nodes))
+	return true
+}
+
 // DebugPrint prints the current state of the hash ring for debugging
 func (hr *HashRing) DebugPrint() {
 	hr.mu.RLock()
 	defer hr.mu.RUnlock()
 
 	fmt.Println("\n=== Hash Ring State ===")
+	fmt.Printf("Epoch: %d\n", hr.epoch)
 	fmt.Printf("Physical Nodes: %d\n", len(hr.nodeCapacity))
 	fmt.Printf("Virtual Nodes: %d\n", len(hr.nodes))
 
 	for nodeID, capacity := range hr.nodeCapacity {
+		if region := hr.nodeRegion[nodeID]; region != "" {
+			fmt.Printf("  - %s: %d virtual nodes @ %s (%s)\n", nodeID, capacity, hr.nodeAddress[nodeID], region)
+			continue
+		}
 		fmt.Printf("  - %s: %d virtual nodes @ %s\n", nodeID, capacity, hr.nodeAddress[nodeID])
 	}
 
@@ -271,5 +503,6 @@ func (hr *HashRing) DebugPrint() {
 			fmt.Printf("  Hash: %10d -> %s#%d\n", vNode.Hash, vNode.NodeID, vNode.VNodeIdx)
 		}
 	}
-	fmt.Println("========================\n")
+	fmt.Println("========================")
+	fmt.Println()
 }
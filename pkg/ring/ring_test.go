@@ -1,7 +1,10 @@
 package ring
 
 import (
+	"bytes"
 	"fmt"
+	"log/slog"
+	"strings"
 	"sync"
 	"testing"
 )
@@ -106,6 +109,50 @@ func TestGetNode(t *testing.T) {
 	}
 }
 
+func TestWithHasher(t *testing.T) {
+	positions := map[string]uint32{
+		"server-a#0": 1000,
+		"server-b#0": 2000,
+		"chat-low":   500,
+		"chat-mid":   1500,
+		"chat-high":  2500,
+	}
+	ring := NewHashRing(1, WithHasher(func(key string) uint32 { return positions[key] }))
+	ring.AddNode("server-a", 1, "localhost:50051")
+	ring.AddNode("server-b", 1, "localhost:50052")
+
+	// chat-high is past the last virtual node, so it wraps around to server-a
+	want := map[string]string{"chat-low": "server-a", "chat-mid": "server-b", "chat-high": "server-a"}
+	for key, owner := range want {
+		if nodeID, _, _ := ring.GetNode(key); nodeID != owner {
+			t.Errorf("Expected %s on %s, got %s", key, owner, nodeID)
+		}
+		if nodeID, _, _ := ring.Namespace("").GetNode(key); nodeID != owner {
+			t.Errorf("Expected %s on %s in the namespace view, got %s", key, owner, nodeID)
+		}
+	}
+}
+
+func TestLogger(t *testing.T) {
+	var global bytes.Buffer
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewTextHandler(&global, nil)))
+
+	NewHashRing(10).AddNode("server-a", 10, "localhost:50051")
+	if global.Len() != 0 {
+		t.Errorf("Expected nothing logged by default, got %q", global.String())
+	}
+
+	var buf bytes.Buffer
+	NewHashRing(10, WithLogger(slog.New(slog.NewTextHandler(&buf, nil)))).AddNode("server-a", 10, "localhost:50051")
+	if !strings.Contains(buf.String(), "Added node") {
+		t.Errorf("Expected the given logger to get \"Added node\", got %q", buf.String())
+	}
+	if global.Len() != 0 {
+		t.Errorf("Expected nothing logged to slog's default, got %q", global.String())
+	}
+}
+
 func TestGetNodeEmptyRing(t *testing.T) {
 	ring := NewHashRing(10)
 
@@ -174,6 +221,79 @@ func TestConsistency(t *testing.T) {
 	t.Logf("Keys moved after adding node: %d/100", moved)
 }
 
+func TestEpoch(t *testing.T) {
+	ring := NewHashRing(10)
+
+	if ring.Epoch() != 0 {
+		t.Errorf("Expected epoch 0 for empty ring, got %d", ring.Epoch())
+	}
+
+	ring.AddNode("server-a", 10, "localhost:50051")
+	ring.AddNode("server-b", 10, "localhost:50052")
+	ring.AddNode("server-a", 10, "localhost:50051") // duplicate, no change
+	ring.RemoveNode("server-a")
+	ring.RemoveNode("server-z") // unknown, no change
+
+	if ring.Epoch() != 3 {
+		t.Errorf("Expected epoch 3 after three membership changes, got %d", ring.Epoch())
+	}
+}
+
+func TestStateDigest(t *testing.T) {
+	ring1 := NewHashRing(10)
+	ring1.AddNode("server-a", 10, "localhost:50051")
+	ring1.AddNode("server-b", 20, "localhost:50052")
+
+	// Same membership added in a different order
+	ring2 := NewHashRing(10)
+	ring2.AddNode("server-b", 20, "localhost:50052")
+	ring2.AddNode("server-a", 10, "localhost:50051")
+
+	if ring1.State().Digest() != ring2.State().Digest() {
+		t.Error("Expected equal digests for identical membership")
+	}
+
+	ring2.AddNode("server-c", 10, "localhost:50053")
+	if ring1.State().Digest() == ring2.State().Digest() {
+		t.Error("Expected different digests after membership change")
+	}
+}
+
+func TestReplace(t *testing.T) {
+	source := NewHashRing(10)
+	source.AddNode("server-a", 10, "localhost:50051")
+	source.AddNode("server-b", 20, "localhost:50052")
+	source.AddNode("server-c", 10, "localhost:50053")
+
+	target := NewHashRing(10)
+	target.AddNode("server-x", 10, "localhost:50099")
+
+	if !target.Replace(source.State()) {
+		t.Fatal("Expected newer state to be applied")
+	}
+	if target.Epoch() != source.Epoch() {
+		t.Errorf("Expected epoch %d, got %d", source.Epoch(), target.Epoch())
+	}
+	if target.NodeExists("server-x") {
+		t.Error("Expected server-x to be gone after replace")
+	}
+
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("chat-%d", i)
+		want, _, _ := source.GetNode(key)
+		got, _, _ := target.GetNode(key)
+		if want != got {
+			t.Errorf("Key %s routed to %s, expected %s", key, got, want)
+		}
+	}
+
+	// Stale states must be ignored
+	stale := RingState{Epoch: 1, Nodes: []NodeSpec{{NodeID: "server-z", Capacity: 10}}}
+	if target.Replace(stale) {
+		t.Error("Expected stale state to be rejected")
+	}
+}
+
 func TestConcurrency(t *testing.T) {
 	ring := NewHashRing(50)
 
@@ -244,6 +364,73 @@ func TestLoadDistribution(t *testing.T) {
 	}
 }
 
+func TestLookupAllocations(t *testing.T) {
+	ring := NewHashRing(100)
+	ring.AddNode("server-a", 100, "localhost:50051")
+	ring.AddNode("server-b", 100, "localhost:50052")
+	ring.AddNode("server-c", 100, "localhost:50053")
+	key := "chat-with-an-id-longer-than-any-stack-buffer-0123456789"
+
+	if allocs := testing.AllocsPerRun(100, func() { ring.GetNode(key) }); allocs != 0 {
+		t.Errorf("Expected GetNode not to allocate, got %.0f allocations", allocs)
+	}
+	if allocs := testing.AllocsPerRun(100, func() { ring.GetNodes(key, 5) }); allocs != 1 {
+		t.Errorf("Expected GetNodes to allocate only its result, got %.0f allocations", allocs)
+	}
+	if nodes := ring.GetNodes(key, 5); len(nodes) != 3 {
+		t.Errorf("Expected all 3 nodes, got %v", nodes)
+	}
+}
+
+func TestVirtualNodeHashesReused(t *testing.T) {
+	hashed := 0
+	ring := NewHashRing(10, WithHasher(func(key string) uint32 {
+		hashed++
+		return hashKey(key)
+	}))
+	ring.AddNode("server-a", 10, "localhost:50051")
+	ring.AddNodeInNamespace("server-b", 10, "localhost:50052", "", "premium")
+	want := ring.GetNodes("chat-1", 2)
+
+	// A new membership hashes only the nodes that joined
+	state := ring.State()
+	state.Epoch++
+	state.Nodes = append(state.Nodes, NodeSpec{NodeID: "server-c", Address: "localhost:50053", Capacity: 20})
+	hashed = 0
+	ring.Replace(state)
+	if hashed != 20 {
+		t.Errorf("Expected only server-c's 20 virtual nodes hashed, got %d", hashed)
+	}
+
+	// Namespace views share the ring's hashes
+	hashed = 0
+	ring.Namespace("premium")
+	if hashed != 0 {
+		t.Errorf("Expected the view built from known hashes, got %d hashed", hashed)
+	}
+
+	// A node that left and rejoins is hashed again, the same way
+	ring.RemoveNode("server-c")
+	hashed = 0
+	ring.AddNode("server-c", 20, "localhost:50053")
+	if hashed != 20 {
+		t.Errorf("Expected a rejoining node hashed again, got %d", hashed)
+	}
+	ring.RemoveNode("server-c")
+	if got := ring.GetNodes("chat-1", 2); len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("Expected routing unchanged, got %v want %v", got, want)
+	}
+}
+
+// benchmarkKeys are chat IDs made up front, so lookups are measured alone
+var benchmarkKeys = func() []string {
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("chat-%d", i)
+	}
+	return keys
+}()
+
 func BenchmarkGetNode(b *testing.B) {
 	ring := NewHashRing(100)
 
@@ -251,10 +438,38 @@ func BenchmarkGetNode(b *testing.B) {
 	ring.AddNode("server-b", 100, "localhost:50052")
 	ring.AddNode("server-c", 100, "localhost:50053")
 
+	b.ReportAllocs()
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		key := fmt.Sprintf("chat-%d", i)
-		ring.GetNode(key)
+		ring.GetNode(benchmarkKeys[i%len(benchmarkKeys)])
+	}
+}
+
+func BenchmarkGetNodes(b *testing.B) {
+	ring := NewHashRing(100)
+	for i := 0; i < 10; i++ {
+		ring.AddNode(fmt.Sprintf("server-%d", i), 100, fmt.Sprintf("localhost:%d", 50051+i))
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ring.GetNodes(benchmarkKeys[i%len(benchmarkKeys)], 3)
+	}
+}
+
+func BenchmarkReplace(b *testing.B) {
+	ring := NewHashRing(100)
+	for i := 0; i < 10; i++ {
+		ring.AddNode(fmt.Sprintf("server-%d", i), 100, fmt.Sprintf("localhost:%d", 50051+i))
+	}
+	state := ring.State()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		state.Epoch++
+		ring.Replace(state)
 	}
 }
 
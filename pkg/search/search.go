@@ -0,0 +1,354 @@
+// Package search is an embedded full-text index over a server's messages.
+// Messages are indexed as the server accepts them and removed when it
+// loses them, so a query is answered from posting lists rather than by
+// scanning every chat's history. Matches are ranked with BM25: messages
+// where the query's words are frequent and rare elsewhere come first,
+// newer ones breaking ties.
+package search
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// BM25 parameters: how quickly repeated words stop adding to a score, and
+// how much long messages are penalized
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// Doc is one message as indexed
+type Doc struct {
+	ChatID    string
+	MessageID string // Empty for unreplicated messages, which are keyed by Seq
+	Seq       uint64
+	SenderID  string
+	Text      string
+	Timestamp time.Time
+}
+
+// key identifies a doc within the index
+type key struct {
+	chatID string
+	id     string
+}
+
+// docKey returns the key a doc is indexed under
+func docKey(chatID, messageID string, seq uint64) key {
+	if messageID == "" {
+		messageID = "#" + strconv.FormatUint(seq, 10)
+	}
+	return key{chatID, messageID}
+}
+
+// entry is an indexed doc and its word counts
+type entry struct {
+	doc    Doc
+	terms  map[string]int
+	length int
+}
+
+// Config configures an index
+type Config struct {
+	// MaxResults caps the hits a query returns (default: 100)
+	MaxResults int
+}
+
+// withDefaults fills in unset fields
+func (c Config) withDefaults() Config {
+	if c.MaxResults <= 0 {
+		c.MaxResults = 100
+	}
+	return c
+}
+
+// Query selects and ranks messages
+type Query struct {
+	// Text holds the words every match must contain, in any order and case
+	Text string
+
+	// ChatIDs restricts the query to these chats (empty: every chat)
+	ChatIDs []string
+
+	// SenderID restricts the query to one sender's messages
+	SenderID string
+
+	// Limit is how many hits to return (default and cap: MaxResults)
+	Limit int
+
+	// Filter, if set, drops the chats it returns false for before ranking,
+	// e.g. those the caller may not read. It is called once per chat.
+	Filter func(chatID string) bool
+}
+
+// Hit is a message matching a query
+type Hit struct {
+	Doc
+	Score float64
+}
+
+// Result is a query's best hits, best first, and how many matched
+type Result struct {
+	Hits  []Hit
+	Total int
+}
+
+// Stats describe an index's contents
+type Stats struct {
+	Docs  int // Messages indexed
+	Chats int // Chats with a message indexed
+	Terms int // Distinct words
+}
+
+// Index is an in-memory inverted index of messages. It is safe for
+// concurrent use.
+type Index struct {
+	mu       sync.RWMutex
+	config   Config
+	docs     map[key]*entry
+	postings map[string]map[key]int // Word -> doc -> occurrences
+	chats    map[string]map[key]struct{}
+	totalLen int
+}
+
+// NewIndex creates an empty index
+func NewIndex(config Config) *Index {
+	return &Index{
+		config:   config.withDefaults(),
+		docs:     make(map[key]*entry),
+		postings: make(map[string]map[key]int),
+		chats:    make(map[string]map[key]struct{}),
+	}
+}
+
+// Tokenize splits text into the words it is indexed and queried by:
+// lowercased runs of letters and digits
+func Tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// Add indexes doc, replacing the doc indexed under the same chat and
+// message ID, so a message applied twice is indexed once
+func (ix *Index) Add(doc Doc) {
+	k := docKey(doc.ChatID, doc.MessageID, doc.Seq)
+	words := Tokenize(doc.Text)
+	e := &entry{doc: doc, terms: make(map[string]int, len(words)), length: len(words)}
+	for _, w := range words {
+		e.terms[w]++
+	}
+
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.removeLocked(k)
+	ix.docs[k] = e
+	ix.totalLen += e.length
+	for w, n := range e.terms {
+		posting := ix.postings[w]
+		if posting == nil {
+			posting = make(map[key]int)
+			ix.postings[w] = posting
+		}
+		posting[k] = n
+	}
+	chat := ix.chats[doc.ChatID]
+	if chat == nil {
+		chat = make(map[key]struct{})
+		ix.chats[doc.ChatID] = chat
+	}
+	chat[k] = struct{}{}
+}
+
+// Delete removes a message, reporting whether it was indexed
+func (ix *Index) Delete(chatID, messageID string) bool {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	return ix.removeLocked(key{chatID, messageID})
+}
+
+// DeleteChat removes every message of a chat, returning how many there were
+func (ix *Index) DeleteChat(chatID string) int {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	n := 0
+	for k := range ix.chats[chatID] {
+		if ix.removeLocked(k) {
+			n++
+		}
+	}
+	return n
+}
+
+// DeleteBefore removes a chat's messages sent before cutoff, returning how
+// many there were
+func (ix *Index) DeleteBefore(chatID string, cutoff time.Time) int {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	n := 0
+	for k := range ix.chats[chatID] {
+		if ix.docs[k].doc.Timestamp.Before(cutoff) && ix.removeLocked(k) {
+			n++
+		}
+	}
+	return n
+}
+
+// DeleteSender removes a sender's messages sent up to before, in every
+// chat, returning how many there were
+func (ix *Index) DeleteSender(senderID string, before time.Time) int {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	n := 0
+	for k, e := range ix.docs {
+		if e.doc.SenderID == senderID && !e.doc.Timestamp.After(before) && ix.removeLocked(k) {
+			n++
+		}
+	}
+	return n
+}
+
+// removeLocked unindexes the doc under k
+func (ix *Index) removeLocked(k key) bool {
+	e, ok := ix.docs[k]
+	if !ok {
+		return false
+	}
+	delete(ix.docs, k)
+	ix.totalLen -= e.length
+	for w := range e.terms {
+		posting := ix.postings[w]
+		delete(posting, k)
+		if len(posting) == 0 {
+			delete(ix.postings, w)
+		}
+	}
+	chat := ix.chats[k.chatID]
+	delete(chat, k)
+	if len(chat) == 0 {
+		delete(ix.chats, k.chatID)
+	}
+	return true
+}
+
+// Search returns the messages containing every word of q.Text that pass
+// its restrictions, best first. A query without words matches nothing.
+func (ix *Index) Search(q Query) Result {
+	words := uniqueWords(Tokenize(q.Text))
+	if len(words) == 0 {
+		return Result{}
+	}
+	limit := q.Limit
+	if limit <= 0 || limit > ix.config.MaxResults {
+		limit = ix.config.MaxResults
+	}
+	var chats map[string]bool
+	if len(q.ChatIDs) > 0 {
+		chats = make(map[string]bool, len(q.ChatIDs))
+		for _, id := range q.ChatIDs {
+			chats[id] = true
+		}
+	}
+
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	// Walk the rarest word's postings, checking the others against each doc
+	postings := make([]map[key]int, len(words))
+	for i, w := range words {
+		postings[i] = ix.postings[w]
+		if len(postings[i]) == 0 {
+			return Result{}
+		}
+	}
+	sort.Slice(postings, func(i, j int) bool { return len(postings[i]) < len(postings[j]) })
+
+	n := float64(len(ix.docs))
+	avgLen := float64(ix.totalLen) / n
+	idf := make([]float64, len(postings))
+	for i, posting := range postings {
+		df := float64(len(posting))
+		idf[i] = math.Log(1 + (n-df+0.5)/(df+0.5))
+	}
+
+	allowed := make(map[string]bool)
+	var hits []Hit
+	for k := range postings[0] {
+		e := ix.docs[k]
+		if chats != nil && !chats[k.chatID] {
+			continue
+		}
+		if q.SenderID != "" && e.doc.SenderID != q.SenderID {
+			continue
+		}
+		if q.Filter != nil {
+			ok, seen := allowed[k.chatID]
+			if !seen {
+				ok = q.Filter(k.chatID)
+				allowed[k.chatID] = ok
+			}
+			if !ok {
+				continue
+			}
+		}
+
+		var score float64
+		matched := true
+		for i, posting := range postings {
+			tf, ok := posting[k]
+			if !ok {
+				matched = false
+				break
+			}
+			f := float64(tf)
+			score += idf[i] * f * (bm25K1 + 1) / (f + bm25K1*(1-bm25B+bm25B*float64(e.length)/avgLen))
+		}
+		if matched {
+			hits = append(hits, Hit{Doc: e.doc, Score: score})
+		}
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		if !hits[i].Timestamp.Equal(hits[j].Timestamp) {
+			return hits[i].Timestamp.After(hits[j].Timestamp)
+		}
+		if hits[i].ChatID != hits[j].ChatID {
+			return hits[i].ChatID < hits[j].ChatID
+		}
+		return hits[i].Seq > hits[j].Seq
+	})
+	total := len(hits)
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return Result{Hits: hits, Total: total}
+}
+
+// uniqueWords drops repeated words from a query
+func uniqueWords(words []string) []string {
+	seen := make(map[string]bool, len(words))
+	out := words[:0]
+	for _, w := range words {
+		if !seen[w] {
+			seen[w] = true
+			out = append(out, w)
+		}
+	}
+	return out
+}
+
+// Stats returns the index's sizes
+func (ix *Index) Stats() Stats {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return Stats{Docs: len(ix.docs), Chats: len(ix.chats), Terms: len(ix.postings)}
+}
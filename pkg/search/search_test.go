@@ -0,0 +1,79 @@
+package search
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIndexSearch(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	ix := NewIndex(Config{})
+	docs := []Doc{
+		{ChatID: "chat-1", MessageID: "m-1", Seq: 1, SenderID: "alice", Text: "Lunch at noon?"},
+		{ChatID: "chat-1", MessageID: "m-2", Seq: 2, SenderID: "bob", Text: "lunch lunch LUNCH, yes please"},
+		{ChatID: "chat-1", MessageID: "m-3", Seq: 3, SenderID: "alice", Text: "the deploy is done"},
+		{ChatID: "chat-2", MessageID: "m-4", Seq: 1, SenderID: "carol", Text: "lunch after the deploy"},
+	}
+	for i, doc := range docs {
+		doc.Timestamp = start.Add(time.Duration(i) * time.Minute)
+		ix.Add(doc)
+	}
+
+	// Repeated words rank higher, case aside
+	res := ix.Search(Query{Text: "Lunch"})
+	if res.Total != 3 || res.Hits[0].MessageID != "m-2" {
+		t.Errorf("Expected 3 matches led by m-2, got %+v", res)
+	}
+
+	// Every word must match
+	res = ix.Search(Query{Text: "deploy lunch"})
+	if res.Total != 1 || res.Hits[0].MessageID != "m-4" {
+		t.Errorf("Expected only m-4 to contain both words, got %+v", res)
+	}
+
+	// Restrictions apply before the limit
+	res = ix.Search(Query{Text: "lunch", ChatIDs: []string{"chat-1"}, SenderID: "alice"})
+	if res.Total != 1 || res.Hits[0].MessageID != "m-1" {
+		t.Errorf("Expected only alice's m-1 in chat-1, got %+v", res)
+	}
+	res = ix.Search(Query{Text: "lunch", Filter: func(chatID string) bool { return chatID == "chat-2" }})
+	if res.Total != 1 || res.Hits[0].ChatID != "chat-2" {
+		t.Errorf("Expected the filter to keep chat-2 only, got %+v", res)
+	}
+	res = ix.Search(Query{Text: "lunch", Limit: 1})
+	if res.Total != 3 || len(res.Hits) != 1 {
+		t.Errorf("Expected 1 of 3 hits, got %+v", res)
+	}
+
+	// Re-adding a message replaces it
+	ix.Add(Doc{ChatID: "chat-1", MessageID: "m-2", Seq: 2, SenderID: "bob", Text: "no thanks", Timestamp: start.Add(time.Minute)})
+	if res := ix.Search(Query{Text: "lunch"}); res.Total != 2 {
+		t.Errorf("Expected m-2 reindexed without lunch, got %+v", res)
+	}
+
+	// Deleted messages and chats are gone
+	if n := ix.DeleteBefore("chat-1", start.Add(time.Minute)); n != 1 {
+		t.Errorf("Expected only m-1 sent before the cutoff, got %d", n)
+	}
+	if ix.Delete("chat-1", "m-1") {
+		t.Errorf("Expected m-1 already deleted")
+	}
+	if n := ix.DeleteSender("carol", start); n != 0 {
+		t.Errorf("Expected carol's later message kept, got %d deleted", n)
+	}
+	if n := ix.DeleteChat("chat-2"); n != 1 {
+		t.Errorf("Expected 1 message of chat-2 deleted, got %d", n)
+	}
+	if res := ix.Search(Query{Text: "lunch"}); res.Total != 0 {
+		t.Errorf("Expected no lunch left, got %+v", res)
+	}
+	if n := ix.DeleteSender("alice", start.Add(time.Hour)); n != 1 {
+		t.Errorf("Expected alice's m-3 deleted, got %d", n)
+	}
+	if stats := ix.Stats(); stats.Docs != 1 || stats.Chats != 1 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+	if res := ix.Search(Query{Text: "  ,"}); res.Total != 0 {
+		t.Errorf("Expected a query without words to match nothing, got %+v", res)
+	}
+}
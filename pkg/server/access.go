@@ -0,0 +1,143 @@
+package server
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sh4shv4t/DistriChat/pkg/audit"
+	"github.com/sh4shv4t/DistriChat/pkg/auth"
+	"github.com/sh4shv4t/DistriChat/pkg/cache"
+	"github.com/sh4shv4t/DistriChat/pkg/chaterr"
+	pb "github.com/sh4shv4t/DistriChat/proto"
+)
+
+// AccessControl restricts each chat to its members: the principal who
+// created it with a CHAT_CREATED event, and those since added with
+// MEMBER_JOINED events and not removed with MEMBER_LEFT ones.
+type AccessControl struct {
+	// Admins are principals allowed into every chat, beside those the
+	// Authenticator marks as admins. Peers read each other's copies of
+	// chats as themselves, so with replication every server's identity
+	// belongs here.
+	Admins []string
+}
+
+// isAdmin reports whether p may read and write every chat
+func (a *AccessControl) isAdmin(p auth.Principal) bool {
+	if p.Admin {
+		return true
+	}
+	for _, id := range a.Admins {
+		if id == p.ID {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultAuthenticator identifies callers by the credentials config
+// accepts: API keys and bearer tokens, and client certificates with TLS.
+// Without API keys, tokens or access control it is nil, and callers stay
+// anonymous.
+func defaultAuthenticator(config ServerConfig) auth.Authenticator {
+	var authenticators []auth.Authenticator
+	if config.APIKeys != nil {
+		authenticators = append(authenticators, auth.APIKeys(config.APIKeys))
+	}
+	if config.JWT != nil {
+		authenticators = append(authenticators, auth.JWT(config.JWT))
+	}
+	if config.TLS != nil || (len(authenticators) == 0 && config.AccessControl != nil) {
+		authenticators = append(authenticators, auth.TLSIdentity())
+	}
+
+	switch len(authenticators) {
+	case 0:
+		return nil
+	case 1:
+		return authenticators[0]
+	default:
+		return auth.FirstOf(authenticators...)
+	}
+}
+
+// authenticate identifies the caller of ctx, returning ctx carrying the
+// principal. Without an authenticator every call is let through as is.
+func (s *ChatServer) authenticate(ctx context.Context, method, tenant string) (context.Context, auth.Principal, error) {
+	if s.authenticator == nil {
+		return ctx, auth.Principal{}, nil
+	}
+	p, err := s.authenticator.Authenticate(ctx)
+	if err == nil && p.ID == "" {
+		err = fmt.Errorf("%w: no principal", chaterr.ErrUnauthenticated)
+	}
+	if err != nil {
+		s.audit(ctx, audit.Event{Action: audit.ActionAuthFailure, Target: method,
+			Outcome: audit.OutcomeDenied, Reason: err.Error()})
+		return ctx, p, err
+	}
+	if p.KeyID != "" {
+		s.metrics.apiKeyRequests.With(method, p.KeyID, tenant).Inc()
+	}
+	return auth.NewContext(ctx, p), p, nil
+}
+
+// authorizeWrite returns an error wrapping chaterr.ErrPermissionDenied if
+// p may not post msg to the chat. The principal's scopes must allow writes
+// in the request's namespace. Under access control, anyone may create a
+// chat that has no members yet, and members may post, but not system
+// events made out to someone else.
+func (s *ChatServer) authorizeWrite(ctx context.Context, p auth.Principal, req *pb.ChatRequest, msg cache.Message) error {
+	chatID := req.ChatId
+	if !p.Allows(auth.ScopeWrite, req.Namespace) {
+		return s.deny(ctx, chatID, fmt.Errorf("%w: %s may not post in namespace %q",
+			chaterr.ErrPermissionDenied, p.ID, req.Namespace))
+	}
+	if s.access == nil || s.access.isAdmin(p) {
+		return nil
+	}
+	if msg.Event != nil && msg.Event.ActorID != p.ID {
+		return s.deny(ctx, chatID, fmt.Errorf("%w: %s can't post events as %s",
+			chaterr.ErrPermissionDenied, p.ID, msg.Event.ActorID))
+	}
+
+	members := s.cache.MembersContext(ctx, chatID)
+	if len(members) == 0 && msg.Event != nil && msg.Event.Type == cache.EventChatCreated {
+		return nil
+	}
+	if !members[p.ID] {
+		return s.deny(ctx, chatID, fmt.Errorf("%w: %s is not a member of chat %s",
+			chaterr.ErrPermissionDenied, p.ID, chatID))
+	}
+	return nil
+}
+
+// authorizeRead returns an error wrapping chaterr.ErrPermissionDenied if
+// p may not read the chat. The principal's scopes must allow reads in the
+// request's namespace. Under access control, a local read of this server's
+// copy is a peer's and only admins may make it.
+func (s *ChatServer) authorizeRead(ctx context.Context, p auth.Principal, req *pb.HistoryRequest) error {
+	if !p.Allows(auth.ScopeRead, req.Namespace) {
+		return s.deny(ctx, req.ChatId, fmt.Errorf("%w: %s may not read in namespace %q",
+			chaterr.ErrPermissionDenied, p.ID, req.Namespace))
+	}
+	if s.access == nil || s.access.isAdmin(p) {
+		return nil
+	}
+	if req.Local {
+		return s.deny(ctx, req.ChatId, fmt.Errorf("%w: only admins read replicas' copies",
+			chaterr.ErrPermissionDenied))
+	}
+	if !s.cache.MembersContext(ctx, req.ChatId)[p.ID] {
+		return s.deny(ctx, req.ChatId, fmt.Errorf("%w: %s is not a member of chat %s",
+			chaterr.ErrPermissionDenied, p.ID, req.ChatId))
+	}
+	return nil
+}
+
+// deny records a refused call in the audit log and returns err
+func (s *ChatServer) deny(ctx context.Context, chatID string, err error) error {
+	s.audit(ctx, audit.Event{Action: audit.ActionAccessDenied, Target: chatID,
+		Outcome: audit.OutcomeDenied, Reason: err.Error()})
+	return err
+}
@@ -0,0 +1,377 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/pkg/audit"
+	"github.com/sh4shv4t/DistriChat/pkg/logging"
+	pb "github.com/sh4shv4t/DistriChat/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// adminTokenHeader is the metadata key carrying the admin credential
+	adminTokenHeader = "x-admin-token"
+
+	// Stats subscription push intervals
+	defaultStatsInterval = time.Second
+	minStatsInterval     = 100 * time.Millisecond
+)
+
+// AdminServer implements the gRPC AdminService for a ChatServer.
+// It is kept separate from the chat data plane and served on its own port.
+type AdminServer struct {
+	pb.UnimplementedAdminServiceServer
+
+	chat *ChatServer
+}
+
+// NewAdminServer creates an admin service bound to a chat server
+func NewAdminServer(chat *ChatServer) *AdminServer {
+	return &AdminServer{chat: chat}
+}
+
+// startAdmin starts the admin gRPC server on the configured admin port or
+// listener
+func (s *ChatServer) startAdmin() error {
+	listener := s.adminListener
+	if listener == nil {
+		var err error
+		listener, err = net.Listen("tcp", fmt.Sprintf(":%d", s.adminPort))
+		if err != nil {
+			return fmt.Errorf("failed to listen on admin port %d: %w", s.adminPort, err)
+		}
+	}
+
+	s.adminServer = grpc.NewServer(
+		grpc.ChainUnaryInterceptor(s.adminAuthInterceptor, s.adminAuditInterceptor),
+		grpc.ChainStreamInterceptor(s.adminStreamAuthInterceptor, s.adminStreamAuditInterceptor),
+	)
+	pb.RegisterAdminServiceServer(s.adminServer, NewAdminServer(s))
+
+	s.log.Info("Starting admin server", "port", s.adminPort)
+
+	go func() {
+		if err := s.adminServer.Serve(listener); err != nil {
+			s.log.Error("Admin server error", logging.Err(err))
+		}
+	}()
+
+	return nil
+}
+
+// adminAddress returns the address AdminService is reachable at: the
+// advertised host with the admin port, or "" if it isn't served
+func (s *ChatServer) adminAddress() string {
+	if s.adminPort <= 0 {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(s.address)
+	if err != nil {
+		host = "localhost"
+	}
+	return net.JoinHostPort(host, strconv.Itoa(s.adminPort))
+}
+
+// adminAuthInterceptor rejects admin calls that don't carry the admin token
+func (s *ChatServer) adminAuthInterceptor(ctx context.Context, req interface{},
+	info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := s.authorizeAdmin(ctx, info.FullMethod); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// adminStreamAuthInterceptor is the streaming counterpart of adminAuthInterceptor
+func (s *ChatServer) adminStreamAuthInterceptor(srv interface{}, ss grpc.ServerStream,
+	info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := s.authorizeAdmin(ss.Context(), info.FullMethod); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
+
+// authorizeAdmin checks the admin token carried in the call metadata
+func (s *ChatServer) authorizeAdmin(ctx context.Context, method string) error {
+	if s.adminToken == "" {
+		return nil
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	tokens := md.Get(adminTokenHeader)
+	if len(tokens) == 0 || subtle.ConstantTimeCompare([]byte(tokens[0]), []byte(s.adminToken)) != 1 {
+		s.log.Warn("Rejected unauthenticated admin call", "method", method)
+		s.audit(ctx, audit.Event{Action: audit.ActionAuthFailure, Target: method, Outcome: audit.OutcomeDenied})
+		return status.Error(codes.Unauthenticated, "invalid admin token")
+	}
+	return nil
+}
+
+// State returns the server's lifecycle state
+func (s *ChatServer) State() pb.ServerState {
+	switch {
+	case !s.healthy.Load():
+		return pb.ServerState_SERVER_STATE_DECOMMISSIONED
+	case s.draining.Load():
+		return pb.ServerState_SERVER_STATE_DRAINING
+	default:
+		return pb.ServerState_SERVER_STATE_SERVING
+	}
+}
+
+// Drain stops the server from accepting new messages without shutting it down
+func (s *ChatServer) Drain(reason string) {
+	if s.draining.CompareAndSwap(false, true) {
+		s.log.Info("Draining", "reason", reason)
+	}
+}
+
+// GetTopology reports the server's identity, state and resident sessions
+func (a *AdminServer) GetTopology(ctx context.Context, req *pb.TopologyRequest) (*pb.TopologyResponse, error) {
+	info := a.chat.cache.GetCacheInfo()
+
+	return &pb.TopologyResponse{
+		ServerId:      a.chat.serverID,
+		Address:       a.chat.address,
+		State:         a.chat.State(),
+		UptimeSeconds: int64(a.chat.uptime().Seconds()),
+		L1Chats:       info.L1Chats,
+		L2Chats:       info.L2Chats,
+	}, nil
+}
+
+// Drain stops the server from accepting new messages
+func (a *AdminServer) Drain(ctx context.Context, req *pb.DrainRequest) (*pb.DrainResponse, error) {
+	a.chat.Drain(req.Reason)
+	a.chat.audit(ctx, audit.Event{Action: audit.ActionDrain, Target: a.chat.serverID, Reason: req.Reason})
+	return &pb.DrainResponse{State: a.chat.State()}, nil
+}
+
+// Decommission drains the server, drops its cache and shuts it down.
+// Shutdown happens after the response is sent so the caller gets an answer.
+func (a *AdminServer) Decommission(ctx context.Context, req *pb.DecommissionRequest) (*pb.DecommissionResponse, error) {
+	a.chat.Drain(req.Reason)
+
+	info := a.chat.cache.GetCacheInfo()
+	dropped := info.L1Size + info.L2Size
+	a.chat.cache.Clear()
+
+	a.chat.log.Info("Decommissioning", "reason", req.Reason, "dropped_sessions", dropped)
+	a.chat.audit(ctx, audit.Event{
+		Action:  audit.ActionDecommission,
+		Target:  a.chat.serverID,
+		Reason:  req.Reason,
+		Details: map[string]string{"dropped_sessions": strconv.Itoa(dropped)},
+	})
+
+	go a.chat.Stop()
+
+	return &pb.DecommissionResponse{
+		State:           pb.ServerState_SERVER_STATE_DECOMMISSIONED,
+		DroppedSessions: int32(dropped),
+	}, nil
+}
+
+// ClearCache empties both cache tiers
+func (a *AdminServer) ClearCache(ctx context.Context, req *pb.ClearCacheRequest) (*pb.ClearCacheResponse, error) {
+	info := a.chat.cache.GetCacheInfo()
+	resident := a.chat.cache.Resident()
+	a.chat.cache.Clear()
+	a.chat.unindexCleared(resident)
+	a.chat.audit(ctx, audit.Event{
+		Action:  audit.ActionCachePurge,
+		Target:  "cache",
+		Details: map[string]string{"cleared_sessions": strconv.Itoa(info.L1Size + info.L2Size)},
+	})
+
+	return &pb.ClearCacheResponse{
+		ClearedSessions: int32(info.L1Size + info.L2Size),
+	}, nil
+}
+
+// ReloadConfig applies new runtime configuration values
+func (a *AdminServer) ReloadConfig(ctx context.Context, req *pb.ReloadConfigRequest) (*pb.ReloadConfigResponse, error) {
+	if req.L1Capacity < 0 || req.L2Capacity < 0 {
+		a.chat.audit(ctx, audit.Event{
+			Action:  audit.ActionConfigChange,
+			Target:  "cache",
+			Outcome: audit.OutcomeRejected,
+			Details: map[string]string{
+				"l1_capacity": strconv.Itoa(int(req.L1Capacity)),
+				"l2_capacity": strconv.Itoa(int(req.L2Capacity)),
+			},
+		})
+		return nil, status.Error(codes.InvalidArgument, "capacities must not be negative")
+	}
+
+	previous := a.chat.cache.GetCacheInfo()
+	a.chat.cache.Resize(int(req.L1Capacity), int(req.L2Capacity))
+	info := a.chat.cache.GetCacheInfo()
+	a.chat.audit(ctx, audit.Event{
+		Action: audit.ActionConfigChange,
+		Target: "cache",
+		Details: map[string]string{
+			"l1_capacity": fmt.Sprintf("%d -> %d", previous.L1Capacity, info.L1Capacity),
+			"l2_capacity": fmt.Sprintf("%d -> %d", previous.L2Capacity, info.L2Capacity),
+		},
+	})
+
+	return &pb.ReloadConfigResponse{
+		L1Capacity: int32(info.L1Capacity),
+		L2Capacity: int32(info.L2Capacity),
+	}, nil
+}
+
+// GetStatsSnapshot returns a point-in-time snapshot of server statistics
+func (a *AdminServer) GetStatsSnapshot(ctx context.Context, req *pb.StatsSnapshotRequest) (*pb.StatsSnapshot, error) {
+	return a.chat.statsSnapshot(), nil
+}
+
+// GetRebalanceStatus reports migration and re-replication progress
+func (a *AdminServer) GetRebalanceStatus(ctx context.Context, req *pb.RebalanceStatusRequest) (*pb.RebalanceStatus, error) {
+	return a.chat.rebalanceStatus(), nil
+}
+
+// SetRebalanceRate changes the rebalancing bandwidth and operation caps. It
+// applies to the rebalancer on this server, so it is set on the leader;
+// setting it on every server keeps it in effect across leader changes.
+func (a *AdminServer) SetRebalanceRate(ctx context.Context, req *pb.SetRebalanceRateRequest) (*pb.RebalanceStatus, error) {
+	a.chat.setRebalanceRate(req.BytesPerSecond, req.OpsPerSecond)
+	a.chat.log.Info("Rebalance rate set via admin API",
+		"bytes_per_second", req.BytesPerSecond, "sessions_per_second", req.OpsPerSecond)
+	a.chat.audit(ctx, audit.Event{
+		Action: audit.ActionConfigChange,
+		Target: "rebalance_rate",
+		Details: map[string]string{
+			"bytes_per_second":    strconv.FormatInt(req.BytesPerSecond, 10),
+			"sessions_per_second": strconv.FormatFloat(req.OpsPerSecond, 'g', -1, 64),
+		},
+	})
+	return a.chat.rebalanceStatus(), nil
+}
+
+// GetLogLevel reports the log level in effect
+func (a *AdminServer) GetLogLevel(ctx context.Context, req *pb.GetLogLevelRequest) (*pb.LogLevel, error) {
+	return logLevel(), nil
+}
+
+// SetLogLevel changes the log level. The level belongs to the process, so
+// other servers running in it (as in the simulation) follow it too.
+func (a *AdminServer) SetLogLevel(ctx context.Context, req *pb.SetLogLevelRequest) (*pb.LogLevel, error) {
+	details := map[string]string{"level": req.Level, "revert_after_ms": strconv.FormatInt(req.RevertAfterMs, 10)}
+	level, err := logging.ParseLevel(req.Level)
+	if err == nil && req.RevertAfterMs < 0 {
+		err = errors.New("revert_after_ms must not be negative")
+	}
+	if err != nil {
+		a.chat.audit(ctx, audit.Event{Action: audit.ActionConfigChange, Target: "log_level", Outcome: audit.OutcomeRejected, Details: details})
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	previous := logging.Level()
+	revertAfter := time.Duration(req.RevertAfterMs) * time.Millisecond
+	logging.SetLevelFor(level, revertAfter)
+	a.chat.log.Warn("Log level set via admin API",
+		"previous", previous.String(), "level", level.String(), "revert_after", revertAfter)
+	details["level"] = fmt.Sprintf("%s -> %s", previous, level)
+	a.chat.audit(ctx, audit.Event{Action: audit.ActionConfigChange, Target: "log_level", Details: details})
+	return logLevel(), nil
+}
+
+// logLevel describes the log level in effect
+func logLevel() *pb.LogLevel {
+	resp := &pb.LogLevel{Level: logging.Level().String()}
+	if at := logging.RevertsAt(); !at.IsZero() {
+		resp.RevertsAt = at.Unix()
+	}
+	return resp
+}
+
+// GetClusterStats returns the statistics last collected from every server.
+// Servers not running the aggregator report inactive.
+func (a *AdminServer) GetClusterStats(ctx context.Context, req *pb.ClusterStatsRequest) (*pb.ClusterStats, error) {
+	return a.chat.clusterStats(), nil
+}
+
+// SubscribeStats streams stats snapshots at the requested interval until the
+// caller cancels or the server shuts down
+func (a *AdminServer) SubscribeStats(req *pb.SubscribeStatsRequest, stream pb.AdminService_SubscribeStatsServer) error {
+	interval := time.Duration(req.IntervalMs) * time.Millisecond
+	if interval <= 0 {
+		interval = defaultStatsInterval
+	}
+	if interval < minStatsInterval {
+		interval = minStatsInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := stream.Send(a.chat.statsSnapshot()); err != nil {
+			return err
+		}
+
+		select {
+		case <-ticker.C:
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		case <-a.chat.shutdownCh:
+			return nil
+		}
+	}
+}
+
+// statsSnapshot captures the server's current statistics
+func (s *ChatServer) statsSnapshot() *pb.StatsSnapshot {
+	info := s.cache.GetCacheInfo()
+	fanoutStats := s.hub.Stats()
+	var subscribers int
+	for _, n := range fanoutStats.Subscribers {
+		subscribers += n
+	}
+
+	return &pb.StatsSnapshot{
+		ServerId:      s.serverID,
+		Timestamp:     s.wall.Now().Unix(),
+		State:         s.State(),
+		UptimeSeconds: int64(s.uptime().Seconds()),
+		L1Size:        int32(info.L1Size),
+		L1Capacity:    int32(info.L1Capacity),
+		L2Size:        int32(info.L2Size),
+		L2Capacity:    int32(info.L2Capacity),
+		TotalRequests: info.Stats.TotalRequests,
+		CacheHits:     info.Stats.CacheHits,
+		CacheMisses:   info.Stats.CacheMisses,
+		L1Hits:        info.Stats.L1Hits,
+		L2Hits:        info.Stats.L2Hits,
+		Evictions:     info.Stats.Evictions,
+		Demotions:     info.Stats.Demotions,
+		StaleReads:    s.staleReads.Load(),
+		RateLimited:   s.rateLimited.Load(),
+		RingConflicts: s.ringConflicts.Load(),
+		SlowRequests:  s.slowRequests.Load(),
+
+		PolicyFlagged:  s.policyFlagged.Load(),
+		PolicyRejected: s.policyRejected.Load(),
+
+		Subscribers:   int64(subscribers),
+		SlowConsumers: fanoutStats.Disconnected,
+
+		NotificationsSent:   s.notificationsSent.Load(),
+		NotificationsFailed: s.notificationsFailed.Load(),
+
+		UsersOnline:     int64(s.presence.Online()),
+		IndexedMessages: int64(s.indexedMessages()),
+		MessagesPurged:  s.messagesPurged.Load(),
+	}
+}
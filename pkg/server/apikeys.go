@@ -0,0 +1,93 @@
+package server
+
+import (
+	"context"
+	"errors"
+
+	"github.com/sh4shv4t/DistriChat/pkg/audit"
+	"github.com/sh4shv4t/DistriChat/pkg/auth"
+	pb "github.com/sh4shv4t/DistriChat/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// errNoKeyStore is returned by the API key admin calls of a server without
+// ServerConfig.APIKeys
+var errNoKeyStore = status.Error(codes.FailedPrecondition, "API keys are not enabled")
+
+// CreateAPIKey issues an API key for a tenant
+func (a *AdminServer) CreateAPIKey(ctx context.Context, req *pb.CreateAPIKeyRequest) (*pb.CreateAPIKeyResponse, error) {
+	if a.chat.apiKeys == nil {
+		return nil, errNoKeyStore
+	}
+	if req.Principal == "" {
+		return nil, status.Error(codes.InvalidArgument, "principal is required")
+	}
+	for _, scope := range req.Scopes {
+		if scope != auth.ScopeRead && scope != auth.ScopeWrite {
+			return nil, status.Errorf(codes.InvalidArgument, "unknown scope %q", scope)
+		}
+	}
+
+	key, secret, err := a.chat.apiKeys.Create(auth.APIKey{
+		Tenant:      req.Tenant,
+		Principal:   req.Principal,
+		Scopes:      append([]string{}, req.Scopes...),
+		Description: req.Description,
+	})
+	if err != nil {
+		return nil, err
+	}
+	a.chat.audit(ctx, audit.Event{Action: audit.ActionAPIKeyCreated, Target: key.ID,
+		Details: map[string]string{"tenant": key.Tenant, "principal": key.Principal}})
+	return &pb.CreateAPIKeyResponse{Key: apiKeyToProto(key), Secret: secret}, nil
+}
+
+// RevokeAPIKey invalidates an API key
+func (a *AdminServer) RevokeAPIKey(ctx context.Context, req *pb.RevokeAPIKeyRequest) (*pb.APIKey, error) {
+	if a.chat.apiKeys == nil {
+		return nil, errNoKeyStore
+	}
+	key, err := a.chat.apiKeys.Revoke(req.Id)
+	if errors.Is(err, auth.ErrKeyNotFound) {
+		return nil, status.Errorf(codes.NotFound, "no API key %q", req.Id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	a.chat.audit(ctx, audit.Event{Action: audit.ActionAPIKeyRevoked, Target: key.ID,
+		Details: map[string]string{"tenant": key.Tenant, "principal": key.Principal}})
+	return apiKeyToProto(key), nil
+}
+
+// ListAPIKeys returns the API keys of a tenant, or of all tenants
+func (a *AdminServer) ListAPIKeys(ctx context.Context, req *pb.ListAPIKeysRequest) (*pb.ListAPIKeysResponse, error) {
+	if a.chat.apiKeys == nil {
+		return nil, errNoKeyStore
+	}
+	keys, err := a.chat.apiKeys.List(req.Tenant, req.AllTenants)
+	if err != nil {
+		return nil, err
+	}
+	resp := &pb.ListAPIKeysResponse{}
+	for _, key := range keys {
+		resp.Keys = append(resp.Keys, apiKeyToProto(key))
+	}
+	return resp, nil
+}
+
+// apiKeyToProto describes a stored key, leaving out its hash
+func apiKeyToProto(key auth.APIKey) *pb.APIKey {
+	out := &pb.APIKey{
+		Id:          key.ID,
+		Tenant:      key.Tenant,
+		Principal:   key.Principal,
+		Scopes:      key.Scopes,
+		Description: key.Description,
+		CreatedMs:   key.Created.UnixMilli(),
+	}
+	if !key.Valid() {
+		out.RevokedMs = key.Revoked.UnixMilli()
+	}
+	return out
+}
@@ -0,0 +1,41 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/pkg/logging"
+)
+
+// refresher is implemented by cold tiers that can pick up chats archived
+// by other servers (such as archive.Archive)
+type refresher interface {
+	Refresh(ctx context.Context) error
+}
+
+// archiveLoop moves idle chats to the cold tier every archiveInterval until
+// shutdown, each after its retention policy's hot time (see hotFor). Writes under way when the server's lifetime ends are abandoned,
+// leaving their chats cached.
+func (s *ChatServer) archiveLoop() {
+	ticker := time.NewTicker(s.archiveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if r, ok := s.archive.(refresher); ok {
+				ctx, cancel := context.WithTimeout(s.lifetime, s.archiveInterval)
+				if err := r.Refresh(ctx); err != nil {
+					s.log.Warn("Failed to refresh the archive", logging.Err(err))
+				}
+				cancel()
+			}
+
+			if archived := s.cache.ArchiveIdleFunc(s.lifetime, s.hotFor); len(archived) > 0 {
+				s.log.Info("Archived idle chats", "chats", len(archived))
+			}
+		case <-s.shutdownCh:
+			return
+		}
+	}
+}
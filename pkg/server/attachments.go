@@ -0,0 +1,286 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/pkg/archive"
+	"github.com/sh4shv4t/DistriChat/pkg/cache"
+	"github.com/sh4shv4t/DistriChat/pkg/chaterr"
+	"github.com/sh4shv4t/DistriChat/pkg/logging"
+	pb "github.com/sh4shv4t/DistriChat/proto"
+)
+
+// AttachmentConfig sets where uploaded attachments are kept
+type AttachmentConfig struct {
+	// Store keeps the attachments. Any replica of a chat may be asked for
+	// them, so it should be shared by the cluster, e.g. an S3 bucket.
+	Store archive.ObjectStore
+
+	// Largest attachment accepted, in bytes (default: 25 MiB). Uploads are
+	// held in memory until stored.
+	MaxSize int64
+
+	// BaseURL, if set, is prefixed to attachments' keys to make their
+	// URLs, e.g. a CDN serving Store; otherwise URLs are attachment:<key>.
+	// DownloadAttachment takes either.
+	BaseURL string
+}
+
+// attachmentScheme prefixes attachment URLs without a BaseURL
+const attachmentScheme = "attachment:"
+
+// attachmentChunkSize is the data DownloadAttachment sends per message
+const attachmentChunkSize = 64 << 10
+
+// attachmentMeta describes a stored attachment, kept beside it
+type attachmentMeta struct {
+	ChatID    string    `json:"chat_id"`
+	Namespace string    `json:"namespace,omitempty"`
+	SenderID  string    `json:"sender_id,omitempty"`
+	Filename  string    `json:"filename,omitempty"`
+	MimeType  string    `json:"mime_type"`
+	Size      int64     `json:"size"`
+	SHA256    string    `json:"sha256"`
+	Uploaded  time.Time `json:"uploaded"`
+}
+
+// UploadAttachment stores an attachment streamed in chunks and returns the
+// reference to post in a message, so its content never passes through the
+// chat's write path, cache or replicas. It is taken by the chat's replicas,
+// under the access rules of posting to the chat.
+func (s *ChatServer) UploadAttachment(stream pb.ChatService_UploadAttachmentServer) (err error) {
+	var first *pb.AttachmentChunk
+	var resp *pb.UploadAttachmentResponse
+	s.inFlight.Add(1)
+	defer func(start time.Time) {
+		s.inFlight.Add(-1)
+		s.observeRequest("UploadAttachment", first.GetNamespace(), resp.GetErrorCode(), start)
+	}(time.Now())
+	end := func(code pb.ErrorCode, err error) error {
+		resp = &pb.UploadAttachmentResponse{ServerId: s.serverID, ErrorCode: code, ErrorDetails: err.Error()}
+		return stream.SendAndClose(resp)
+	}
+
+	first, err = stream.Recv()
+	if errors.Is(err, io.EOF) {
+		return end(pb.ErrorCode_ERROR_VALIDATION_FAILED, errors.New("upload is empty"))
+	}
+	if err != nil {
+		return err
+	}
+	ctx := logging.With(stream.Context(), logging.ChatID(first.ChatId))
+	if err := s.checkAttachment(first.ChatId, first.Namespace); err != nil {
+		return end(chaterr.Code(err, pb.ErrorCode_ERROR_VALIDATION_FAILED), err)
+	}
+	ctx, principal, err := s.authenticate(ctx, "UploadAttachment", first.Namespace)
+	if err != nil {
+		return end(chaterr.Code(err, pb.ErrorCode_ERROR_INTERNAL), err)
+	}
+	req := &pb.ChatRequest{ChatId: first.ChatId, Namespace: first.Namespace, SenderId: first.SenderId}
+	if err := s.authorizeWrite(ctx, principal, req, cache.Message{Type: cache.ContentAttachment}); err != nil {
+		return end(chaterr.Code(err, pb.ErrorCode_ERROR_INTERNAL), err)
+	}
+
+	var data bytes.Buffer
+	for chunk := first; ; {
+		if int64(data.Len()+len(chunk.Data)) > s.attachments.MaxSize {
+			return end(pb.ErrorCode_ERROR_VALIDATION_FAILED,
+				fmt.Errorf("attachment is larger than %d bytes", s.attachments.MaxSize))
+		}
+		data.Write(chunk.Data)
+
+		chunk, err = stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	sum := sha256.Sum256(data.Bytes())
+	meta := attachmentMeta{
+		ChatID:    first.ChatId,
+		Namespace: first.Namespace,
+		SenderID:  first.SenderId,
+		Filename:  first.Filename,
+		MimeType:  first.MimeType,
+		Size:      int64(data.Len()),
+		SHA256:    hex.EncodeToString(sum[:]),
+		Uploaded:  s.wall.Now(),
+	}
+	if meta.MimeType == "" {
+		meta.MimeType = http.DetectContentType(data.Bytes())
+	}
+	key, err := s.storeAttachment(ctx, data.Bytes(), meta)
+	if err != nil {
+		s.log.WarnContext(ctx, "Failed to store attachment", logging.Err(err))
+		return end(pb.ErrorCode_ERROR_INTERNAL, err)
+	}
+	s.metrics.attachmentBytes.With("upload").Add(float64(meta.Size))
+	s.log.DebugContext(ctx, "Stored attachment", "key", key, "bytes", meta.Size)
+
+	resp = &pb.UploadAttachmentResponse{
+		Success:    true,
+		ServerId:   s.serverID,
+		Attachment: s.attachmentProto(key, meta),
+		Sha256:     meta.SHA256,
+	}
+	return stream.SendAndClose(resp)
+}
+
+// DownloadAttachment streams an uploaded attachment back, description
+// first, to callers allowed to read its chat. It is served by the chat's
+// replicas.
+func (s *ChatServer) DownloadAttachment(req *pb.DownloadAttachmentRequest, stream pb.ChatService_DownloadAttachmentServer) error {
+	ctx := logging.With(stream.Context(), logging.ChatID(req.ChatId))
+	end := func(code pb.ErrorCode, err error) error {
+		return stream.Send(&pb.AttachmentData{ServerId: s.serverID, ErrorCode: code, ErrorDetails: err.Error()})
+	}
+
+	if err := s.checkAttachment(req.ChatId, req.Namespace); err != nil {
+		return end(chaterr.Code(err, pb.ErrorCode_ERROR_VALIDATION_FAILED), err)
+	}
+	key, ok := s.attachmentKey(req.Url)
+	if !ok {
+		return end(pb.ErrorCode_ERROR_VALIDATION_FAILED, fmt.Errorf("%q is not an attachment of this cluster", req.Url))
+	}
+	ctx, principal, err := s.authenticate(ctx, "DownloadAttachment", req.Namespace)
+	if err != nil {
+		return end(chaterr.Code(err, pb.ErrorCode_ERROR_INTERNAL), err)
+	}
+	if err := s.authorizeRead(ctx, principal, &pb.HistoryRequest{ChatId: req.ChatId, Namespace: req.Namespace}); err != nil {
+		return end(chaterr.Code(err, pb.ErrorCode_ERROR_INTERNAL), err)
+	}
+
+	// An attachment is only found through the chat it was uploaded to, so
+	// access to one chat doesn't reach another's
+	meta, err := s.attachmentMeta(ctx, key)
+	if err == nil && (meta.ChatID != req.ChatId || meta.Namespace != req.Namespace) {
+		err = archive.ErrNotFound
+	}
+	if errors.Is(err, archive.ErrNotFound) {
+		return end(pb.ErrorCode_ERROR_VALIDATION_FAILED, fmt.Errorf("no attachment %s in chat %s", req.Url, req.ChatId))
+	}
+	if err != nil {
+		return end(pb.ErrorCode_ERROR_INTERNAL, err)
+	}
+	data, err := s.attachments.Store.Get(ctx, key)
+	if err != nil {
+		return end(pb.ErrorCode_ERROR_INTERNAL, err)
+	}
+
+	out := &pb.AttachmentData{ServerId: s.serverID, Attachment: s.attachmentProto(key, meta)}
+	for {
+		n := len(data)
+		if n > attachmentChunkSize {
+			n = attachmentChunkSize
+		}
+		out.Data = data[:n]
+		if err := stream.Send(out); err != nil {
+			return err
+		}
+		data = data[n:]
+		if len(data) == 0 {
+			break
+		}
+		out = &pb.AttachmentData{ServerId: s.serverID}
+	}
+	s.metrics.attachmentBytes.With("download").Add(float64(meta.Size))
+	return nil
+}
+
+// checkAttachment returns an error if this server can't take attachments
+// of a chat: it keeps none, or doesn't hold the chat (wrapping
+// chaterr.ErrNotOwner)
+func (s *ChatServer) checkAttachment(chatID, namespace string) error {
+	if s.attachments == nil {
+		return fmt.Errorf("server %s keeps no attachments", s.serverID)
+	}
+	if chatID == "" {
+		return errors.New("chat_id is required")
+	}
+	if err := s.checkNamespace(namespace); err != nil {
+		return err
+	}
+	if !s.holdsChat(chatID) {
+		return fmt.Errorf("%w: server %s holds no replica of chat %s", chaterr.ErrNotOwner, s.serverID, chatID)
+	}
+	return nil
+}
+
+// storeAttachment writes an attachment and its description under a new
+// key, which it returns
+func (s *ChatServer) storeAttachment(ctx context.Context, data []byte, meta attachmentMeta) (string, error) {
+	id := make([]byte, 16)
+	rand.Read(id)
+	key := "attachments/" + hex.EncodeToString(id)
+
+	encoded, err := json.Marshal(meta)
+	if err != nil {
+		return "", err
+	}
+	if err := s.attachments.Store.Put(ctx, key, data); err != nil {
+		return "", err
+	}
+	if err := s.attachments.Store.Put(ctx, key+".meta", encoded); err != nil {
+		s.attachments.Store.Delete(ctx, key)
+		return "", err
+	}
+	return key, nil
+}
+
+// attachmentMeta reads the description of the attachment under key
+func (s *ChatServer) attachmentMeta(ctx context.Context, key string) (attachmentMeta, error) {
+	var meta attachmentMeta
+	data, err := s.attachments.Store.Get(ctx, key+".meta")
+	if err != nil {
+		return meta, err
+	}
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return meta, fmt.Errorf("attachment %s: %w", key, err)
+	}
+	return meta, nil
+}
+
+// attachmentURL returns the URL of the attachment under key
+func (s *ChatServer) attachmentURL(key string) string {
+	if s.attachments.BaseURL != "" {
+		return s.attachments.BaseURL + key
+	}
+	return attachmentScheme + key
+}
+
+// attachmentKey returns the key of the attachment at url, or false if url
+// isn't one of this cluster's attachments
+func (s *ChatServer) attachmentKey(url string) (string, bool) {
+	key, ok := strings.CutPrefix(url, attachmentScheme)
+	if !ok && s.attachments.BaseURL != "" {
+		key, ok = strings.CutPrefix(url, s.attachments.BaseURL)
+	}
+	if !ok || !strings.HasPrefix(key, "attachments/") || strings.HasSuffix(key, ".meta") {
+		return "", false
+	}
+	return key, true
+}
+
+// attachmentProto builds the reference to the attachment under key
+func (s *ChatServer) attachmentProto(key string, meta attachmentMeta) *pb.Attachment {
+	return &pb.Attachment{
+		Url:       s.attachmentURL(key),
+		MimeType:  meta.MimeType,
+		SizeBytes: meta.Size,
+		Filename:  meta.Filename,
+	}
+}
@@ -0,0 +1,121 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/pkg/chaterr"
+	"github.com/sh4shv4t/DistriChat/pkg/fanout"
+	"github.com/sh4shv4t/DistriChat/pkg/logging"
+	"github.com/sh4shv4t/DistriChat/pkg/ring"
+	pb "github.com/sh4shv4t/DistriChat/proto"
+)
+
+// AckMessages moves a durable subscriber's cursor in a chat up to the
+// acknowledged sequence. Clients acknowledge for themselves on any of the
+// chat's replicas, which relays the ack to the others in the background,
+// so a subscription resumed after failover isn't sent everything again.
+func (s *ChatServer) AckMessages(ctx context.Context, req *pb.AckRequest) (*pb.AckResponse, error) {
+	ctx = logging.With(ctx, logging.ChatID(req.ChatId))
+	if req.ChatId == "" {
+		return s.ackError(pb.ErrorCode_ERROR_VALIDATION_FAILED, "chat_id is required"), nil
+	}
+	if err := s.checkNamespace(req.Namespace); err != nil {
+		return s.ackError(pb.ErrorCode_ERROR_NOT_OWNER, err.Error()), nil
+	}
+
+	subscriber := req.SubscriberId
+	if req.RelayedBy == "" {
+		if !s.holdsChat(req.ChatId) {
+			return s.ackError(pb.ErrorCode_ERROR_NOT_OWNER, fmt.Sprintf("%v: server %s holds no replica of chat %s",
+				chaterr.ErrNotOwner, s.serverID, req.ChatId)), nil
+		}
+		ctx, principal, err := s.authenticate(ctx, "AckMessages", req.Namespace)
+		if err != nil {
+			return s.ackError(chaterr.Code(err, pb.ErrorCode_ERROR_INTERNAL), err.Error()), nil
+		}
+		if err := s.authorizeRead(ctx, principal, &pb.HistoryRequest{ChatId: req.ChatId, Namespace: req.Namespace}); err != nil {
+			return s.ackError(chaterr.Code(err, pb.ErrorCode_ERROR_INTERNAL), err.Error()), nil
+		}
+		if principal.ID != "" {
+			subscriber = principal.ID
+		}
+	} else if err := s.authorizePeer(ctx, pb.ChatService_AckMessages_FullMethodName); err != nil {
+		// Relayed acks name any subscriber, so only peers may relay them
+		return s.ackError(pb.ErrorCode_ERROR_PERMISSION_DENIED, err.Error()), nil
+	}
+	if subscriber == "" {
+		return s.ackError(pb.ErrorCode_ERROR_VALIDATION_FAILED, "subscriber_id is required"), nil
+	}
+
+	key := fanout.CursorKey{ChatID: s.cursorChat(req.Namespace, req.ChatId), Subscriber: subscriber}
+	seq := s.cursors.Ack(key, req.Seq)
+	if req.RelayedBy == "" {
+		s.relayAck(ctx, &pb.AckRequest{
+			ChatId:       req.ChatId,
+			Namespace:    req.Namespace,
+			SubscriberId: subscriber,
+			Seq:          seq,
+			RelayedBy:    s.serverID,
+		})
+	}
+	return &pb.AckResponse{Success: true, ServerId: s.serverID, Seq: seq}, nil
+}
+
+// ackError builds a failed AckResponse
+func (s *ChatServer) ackError(code pb.ErrorCode, details string) *pb.AckResponse {
+	return &pb.AckResponse{ServerId: s.serverID, ErrorCode: code, ErrorDetails: details}
+}
+
+// relayAck passes an acknowledgement on to the chat's other replicas
+// without waiting. One that misses it only redelivers more on resumption.
+func (s *ChatServer) relayAck(ctx context.Context, req *pb.AckRequest) {
+	for _, peer := range s.replicaPeers(req.ChatId) {
+		go func(peer ring.NodeInfo) {
+			client, err := s.peerClient(peer.Address)
+			if err != nil {
+				return
+			}
+			ctx, cancel := s.detach(ctx)
+			defer cancel()
+			ctx, cancelTimeout := context.WithTimeout(ctx, s.replication.Timeout)
+			defer cancelTimeout()
+
+			resp, err := client.AckMessages(ctx, req)
+			if err == nil && !resp.Success {
+				err = errors.New(resp.ErrorDetails)
+			}
+			if err != nil {
+				s.log.DebugContext(ctx, "Failed to relay ack", logging.NodeID(peer.NodeID), logging.Err(err))
+			}
+		}(peer)
+	}
+}
+
+// cursorChat returns the chat a cursor is kept for, qualified by its
+// namespace so namespaces don't share cursors
+func (s *ChatServer) cursorChat(namespace, chatID string) string {
+	if namespace == "" {
+		return chatID
+	}
+	return namespace + "/" + chatID
+}
+
+// cursorLoop forgets durable subscribers' cursors past their TTL
+func (s *ChatServer) cursorLoop() {
+	ticker := time.NewTicker(expiryInterval(s.cursors.TTL()))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if n := s.cursors.Expire(); n > 0 {
+				s.log.Debug("Expired subscriber cursors", "cursors", n)
+			}
+		case <-s.shutdownCh:
+			return
+		}
+	}
+}
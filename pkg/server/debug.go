@@ -0,0 +1,114 @@
+package server
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/pkg/gossip"
+	"github.com/sh4shv4t/DistriChat/pkg/logging"
+	pb "github.com/sh4shv4t/DistriChat/proto"
+)
+
+// recentErrorsKept is how many warnings and errors DebugState reports
+const recentErrorsKept = 50
+
+// DebugState returns a dump of the server's state for troubleshooting
+func (a *AdminServer) DebugState(ctx context.Context, req *pb.DebugStateRequest) (*pb.ServerDebugState, error) {
+	s := a.chat
+	resp := &pb.ServerDebugState{
+		ServerId:      s.serverID,
+		Address:       s.address,
+		State:         s.State(),
+		UptimeSeconds: int64(s.uptime().Seconds()),
+		Config:        s.debugConfig(),
+		Ring:          pb.NewRingState(s.ring.State()),
+	}
+
+	resident := s.cache.Resident()
+	resp.TotalSessions = int32(len(resident))
+	if req.MaxSessions > 0 && len(resident) > int(req.MaxSessions) {
+		resident = resident[:req.MaxSessions]
+	}
+	for _, session := range resident {
+		debug := &pb.DebugSession{
+			ChatId:   session.ChatID,
+			Tier:     session.Level.Label(),
+			Messages: int32(session.Messages),
+			LastSeq:  session.LastSeq,
+			Shared:   session.Shared,
+		}
+		if !session.LastAccessed.IsZero() {
+			debug.LastAccessedMs = session.LastAccessed.UnixMilli()
+		}
+		resp.Sessions = append(resp.Sessions, debug)
+	}
+
+	s.peerMu.Lock()
+	for address, conn := range s.peerConns {
+		resp.Connections = append(resp.Connections, &pb.DebugConnection{
+			Address: address,
+			State:   conn.GetState().String(),
+		})
+	}
+	s.peerMu.Unlock()
+
+	if s.gossip != nil {
+		for _, m := range s.gossip.Members() {
+			resp.Members = append(resp.Members, gossip.MemberToProto(m))
+		}
+	}
+
+	for _, r := range s.recorder.Records() {
+		resp.RecentErrors = append(resp.RecentErrors, &pb.DebugLogRecord{
+			TimestampMs: r.Time.UnixMilli(),
+			Level:       r.Level.String(),
+			Message:     r.Message,
+			Attrs:       r.Attrs,
+		})
+	}
+	return resp, nil
+}
+
+// debugConfig returns the server's effective settings. The admin token is
+// reported only as set or not.
+func (s *ChatServer) debugConfig() map[string]string {
+	info := s.cache.GetCacheInfo()
+	config := map[string]string{
+		"port":                   strconv.Itoa(s.port),
+		"region":                 s.region,
+		"namespace":              s.namespace,
+		"l1_capacity":            strconv.Itoa(info.L1Capacity),
+		"l2_capacity":            strconv.Itoa(info.L2Capacity),
+		"capacity":               strconv.Itoa(s.capacity),
+		"coordinator":            s.coordinatorAddress,
+		"admin_port":             strconv.Itoa(s.adminPort),
+		"admin_token":            "",
+		"metrics_port":           strconv.Itoa(s.metricsPort),
+		"replication_n":          strconv.Itoa(s.replication.N),
+		"replication_w":          strconv.Itoa(s.replication.W),
+		"replication_r":          strconv.Itoa(s.replication.R),
+		"replication_timeout":    s.replication.Timeout.String(),
+		"gossip":                 strconv.FormatBool(s.gossip != nil),
+		"metadata":               strconv.FormatBool(s.metadataConfig != nil),
+		"rebalance":              strconv.FormatBool(s.rebalanceConfig != nil),
+		"dead_node_timeout":      s.deadNodeTimeout.String(),
+		"rate_limit":             strconv.FormatBool(s.limiter != nil),
+		"archive":                strconv.FormatBool(s.archive != nil),
+		"message_log":            strconv.FormatBool(s.messageLog != nil),
+		"aggregate_stats":        strconv.FormatBool(s.aggregateStats),
+		"slow_request_threshold": s.slowThreshold.String(),
+		"metric_history":         "off",
+		"log_level":              logging.Level().String(),
+	}
+	if s.adminToken != "" {
+		config["admin_token"] = "set"
+	}
+	if s.archive != nil {
+		config["archive_after"] = s.archiveAfter.String()
+	}
+	if s.metricHistory != nil {
+		config["metric_history"] = (s.metricHistoryInterval * time.Duration(s.metricHistory.Capacity())).String()
+	}
+	return config
+}
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/pkg/cache"
+	"github.com/sh4shv4t/DistriChat/pkg/dedup"
+	"github.com/sh4shv4t/DistriChat/pkg/logging"
+	"github.com/sh4shv4t/DistriChat/pkg/metrics"
+	pb "github.com/sh4shv4t/DistriChat/proto"
+)
+
+// storedBefore returns the dedup entry of a message this server stored
+// within the window but no longer caches, e.g. since it was evicted,
+// deleted or lost to a restart. A message still cached is left to the
+// cache to collapse, so the duplicate is replicated again as usual.
+func (s *ChatServer) storedBefore(ctx context.Context, chatID, messageID string) (dedup.Entry, bool) {
+	entry, ok, err := s.dedup.Get(chatID, messageID)
+	if err != nil {
+		s.log.WarnContext(ctx, "Failed to read dedup store", logging.Err(err))
+		return dedup.Entry{}, false
+	}
+	if !ok || s.cache.HoldsMessage(ctx, chatID, messageID) {
+		return dedup.Entry{}, false
+	}
+	return entry, true
+}
+
+// rememberMessage records a message stored here, as coordinator or
+// replica, in the dedup store. A write whose message can't be recorded
+// fails, so the client retries it, and the retry, collapsed by the cache
+// while it holds the message, records it again.
+func (s *ChatServer) rememberMessage(ctx context.Context, chatID string, msg cache.Message) error {
+	err := s.dedup.Put(dedup.Entry{ChatID: chatID, MessageID: msg.ID, Seq: msg.Seq, Stored: s.wall.Now()})
+	if err != nil {
+		s.log.ErrorContext(ctx, "Failed to record message in dedup store", "message_id", msg.ID, logging.Err(err))
+		return fmt.Errorf("failed to record message: %w", err)
+	}
+	return nil
+}
+
+// duplicateResponse answers a retried write whose message was stored
+// before, from its dedup entry
+func (s *ChatServer) duplicateResponse(entry dedup.Entry) *pb.ChatResponse {
+	s.metrics.dedupHits.Inc()
+	return &pb.ChatResponse{
+		Success:       true,
+		ServerId:      s.serverID,
+		CacheLocation: pb.CacheLocation_CACHE_MISS,
+		RingEpoch:     s.ring.Epoch(),
+		MessageId:     entry.MessageID,
+		Seq:           entry.Seq,
+		Duplicate:     true,
+	}
+}
+
+// registerDedupMetrics reports the dedup store's size in reg
+func (s *ChatServer) registerDedupMetrics(reg metrics.Registry) {
+	reg.GaugeFunc("districhat_server_dedup_entries", "Message IDs held by the dedup store", func() float64 {
+		return float64(s.dedup.Len())
+	})
+}
+
+// dedupLoop forgets the IDs of messages stored longer ago than the window
+func (s *ChatServer) dedupLoop() {
+	ticker := time.NewTicker(expiryInterval(s.dedupWindow))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			n, err := s.dedup.Expire(s.wall.Now().Add(-s.dedupWindow))
+			if err != nil {
+				s.log.Warn("Failed to expire dedup entries", logging.Err(err))
+			} else if n > 0 {
+				s.log.Debug("Expired dedup entries", "entries", n)
+			}
+		case <-s.shutdownCh:
+			return
+		}
+	}
+}
+
+// expiryInterval is how often a loop expiring entries kept for ttl runs:
+// ten times per ttl, between once a second and once a minute
+func expiryInterval(ttl time.Duration) time.Duration {
+	return min(max(ttl/10, time.Second), time.Minute)
+}
@@ -0,0 +1,144 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/sh4shv4t/DistriChat/pkg/cache"
+	"github.com/sh4shv4t/DistriChat/pkg/chaterr"
+	"github.com/sh4shv4t/DistriChat/pkg/fanout"
+	"github.com/sh4shv4t/DistriChat/pkg/logging"
+	"github.com/sh4shv4t/DistriChat/pkg/metrics"
+	pb "github.com/sh4shv4t/DistriChat/proto"
+	"google.golang.org/grpc/peer"
+)
+
+// fanOut hands a message this server just stored to the chat's
+// subscribers. It never blocks the write: slow subscribers are dealt with
+// by the hub.
+func (s *ChatServer) fanOut(chatID string, msg cache.Message) {
+	if s.hub.Subscribers(chatID) == 0 {
+		return
+	}
+	n := s.hub.Publish(chatID, storedFromMessage(chatID, msg))
+	s.metrics.fanoutDeliveries.Add(float64(n))
+}
+
+// registerFanoutMetrics reports the hub's subscribers in reg
+func (s *ChatServer) registerFanoutMetrics(reg metrics.Registry) {
+	reg.GaugeFunc("districhat_server_subscribers", "Subscribers following chats on this server", func() float64 {
+		var total int
+		for _, n := range s.hub.Stats().Subscribers {
+			total += n
+		}
+		return float64(total)
+	})
+	reg.GaugeFunc("districhat_server_subscriber_cursors", "Durable subscribers' acknowledged positions held", func() float64 {
+		return float64(s.cursors.Len())
+	})
+}
+
+// Subscribe streams a chat's messages as this server stores them, whether
+// it coordinated the write or replicated it, so any of the chat's replicas
+// can serve its subscribers. The messages after AfterSeq the server already
+// holds are replayed first; a durable subscription replays instead after
+// the subscriber's last acknowledged message, if its cursor hasn't lapsed,
+// so what it received but never acknowledged is delivered again. Refusals
+// and the end of the subscription are sent as a last response carrying the
+// error code.
+func (s *ChatServer) Subscribe(req *pb.SubscribeRequest, stream pb.ChatService_SubscribeServer) error {
+	ctx := logging.With(stream.Context(), logging.ChatID(req.ChatId))
+	end := func(code pb.ErrorCode, err error) error {
+		return stream.Send(&pb.SubscribeResponse{ServerId: s.serverID, ErrorCode: code, ErrorDetails: err.Error()})
+	}
+
+	if req.ChatId == "" {
+		return end(pb.ErrorCode_ERROR_VALIDATION_FAILED, errors.New("chat_id is required"))
+	}
+	if err := s.checkAccepting(); err != nil {
+		return end(chaterr.Code(err, pb.ErrorCode_ERROR_INTERNAL), err)
+	}
+	if err := s.checkNamespace(req.Namespace); err != nil {
+		return end(pb.ErrorCode_ERROR_NOT_OWNER, err)
+	}
+	if !s.holdsChat(req.ChatId) {
+		return end(pb.ErrorCode_ERROR_NOT_OWNER, fmt.Errorf("%w: server %s holds no replica of chat %s",
+			chaterr.ErrNotOwner, s.serverID, req.ChatId))
+	}
+	ctx, principal, err := s.authenticate(ctx, "Subscribe", req.Namespace)
+	if err != nil {
+		return end(chaterr.Code(err, pb.ErrorCode_ERROR_INTERNAL), err)
+	}
+	if err := s.authorizeRead(ctx, principal, &pb.HistoryRequest{ChatId: req.ChatId, Namespace: req.Namespace}); err != nil {
+		return end(chaterr.Code(err, pb.ErrorCode_ERROR_INTERNAL), err)
+	}
+
+	// Named for the user following the chat, so members subscribed here
+	// aren't sent notifications
+	user := principal.ID
+	if user == "" {
+		user = req.SubscriberId
+	}
+	if req.Durable && user == "" {
+		return end(pb.ErrorCode_ERROR_VALIDATION_FAILED, errors.New("durable subscriptions need a subscriber_id"))
+	}
+	name := user
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil && name == "" {
+		name = p.Addr.String()
+	}
+	sub, err := s.hub.Subscribe(req.ChatId, fanout.Options{Kind: fanout.Stream, Name: name})
+	switch {
+	case errors.Is(err, fanout.ErrTooManySubscribers):
+		return end(pb.ErrorCode_ERROR_OVERLOADED, err)
+	case err != nil:
+		return end(pb.ErrorCode_ERROR_DRAINING, err)
+	}
+	defer sub.Close()
+	afterSeq := req.AfterSeq
+	if req.Durable {
+		afterSeq = s.cursors.Resume(fanout.CursorKey{ChatID: s.cursorChat(req.Namespace, req.ChatId), Subscriber: user}, afterSeq)
+	}
+	s.log.DebugContext(ctx, "Subscriber joined", "subscriber", name, "after_seq", afterSeq, "durable", req.Durable)
+
+	// A user following a chat is online for as long as the stream lasts
+	if user != "" {
+		s.streamJoined(user)
+		defer s.streamLeft(user)
+	}
+
+	// Subscribing before reading the replay leaves no gap between them;
+	// messages found in both are sent once
+	replayed := afterSeq
+	for _, msg := range storedFromMessages(req.ChatId, s.cache.HistoryContext(ctx, req.ChatId, 0)) {
+		if msg.Seq <= replayed {
+			continue
+		}
+		if err := stream.Send(&pb.SubscribeResponse{Message: msg, ServerId: s.serverID}); err != nil {
+			return err
+		}
+		replayed = msg.Seq
+	}
+
+	for {
+		select {
+		case msg, ok := <-sub.C():
+			if !ok {
+				if errors.Is(sub.Err(), fanout.ErrSlowConsumer) {
+					s.metrics.slowConsumers.With(sub.Kind().String()).Inc()
+					s.log.WarnContext(ctx, "Disconnected slow subscriber", "subscriber", name)
+					return end(pb.ErrorCode_ERROR_SLOW_CONSUMER, fmt.Errorf("%w: chat %s", chaterr.ErrSlowConsumer, req.ChatId))
+				}
+				return end(pb.ErrorCode_ERROR_DRAINING, fmt.Errorf("%w: server %s is shutting down",
+					chaterr.ErrDraining, s.serverID))
+			}
+			if msg.Seq <= replayed {
+				continue
+			}
+			if err := stream.Send(&pb.SubscribeResponse{Message: msg, ServerId: s.serverID}); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
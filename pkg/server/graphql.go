@@ -0,0 +1,447 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/sh4shv4t/DistriChat/pkg/cache"
+	pb "github.com/sh4shv4t/DistriChat/proto"
+)
+
+// GraphQL page sizes, of chats or messages
+const (
+	defaultGraphQLPage = 50
+	maxGraphQLPage     = 500
+)
+
+// graphQLLong is a 64-bit integer scalar, for sequence numbers and
+// counters GraphQL's 32-bit Int can't hold
+var graphQLLong = graphql.NewScalar(graphql.ScalarConfig{
+	Name:        "Long",
+	Description: "A 64-bit integer",
+	Serialize: func(value any) any {
+		switch v := value.(type) {
+		case int64, uint64, int:
+			return v
+		case int32:
+			return int64(v)
+		default:
+			return nil
+		}
+	},
+	ParseValue: func(value any) any {
+		switch v := value.(type) {
+		case float64:
+			return int64(v)
+		case int:
+			return int64(v)
+		default:
+			return nil
+		}
+	},
+	ParseLiteral: func(value ast.Value) any {
+		if v, ok := value.(*ast.IntValue); ok {
+			n, err := strconv.ParseInt(v.Value, 10, 64)
+			if err == nil {
+				return n
+			}
+		}
+		return nil
+	},
+})
+
+// pageArgs are the arguments of paginated fields: at most first items,
+// after the item the cursor names
+var pageArgs = graphql.FieldConfigArgument{
+	"first": &graphql.ArgumentConfig{Type: graphql.Int, Description: "Items to return (default: 50, maximum: 500)"},
+	"after": &graphql.ArgumentConfig{Type: graphql.String, Description: "Cursor of the item to start after"},
+}
+
+// newGraphQLSchema builds the schema of the server's read API: the server,
+// its statistics, the chats it holds, and any chat's messages
+func (s *ChatServer) newGraphQLSchema() (graphql.Schema, error) {
+	pageInfo := graphql.NewObject(graphql.ObjectConfig{
+		Name: "PageInfo",
+		Fields: graphql.Fields{
+			"hasNextPage": &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+			"endCursor":   &graphql.Field{Type: graphql.String},
+		},
+	})
+	attachment := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Attachment",
+		Fields: graphql.Fields{
+			"url":       &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"mimeType":  &graphql.Field{Type: graphql.String},
+			"sizeBytes": &graphql.Field{Type: graphQLLong},
+			"filename":  &graphql.Field{Type: graphql.String},
+		},
+	})
+	event := graphql.NewObject(graphql.ObjectConfig{
+		Name: "SystemEvent",
+		Fields: graphql.Fields{
+			"type":    &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"actorId": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		},
+	})
+	message := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Message",
+		Fields: graphql.Fields{
+			"id":         &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+			"seq":        &graphql.Field{Type: graphql.NewNonNull(graphQLLong)},
+			"senderId":   &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"type":       &graphql.Field{Type: graphql.NewNonNull(graphql.String), Description: "text, attachment or system_event"},
+			"text":       &graphql.Field{Type: graphql.String},
+			"attachment": &graphql.Field{Type: attachment},
+			"event":      &graphql.Field{Type: event},
+			"timestamp":  &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+			"origin":     &graphql.Field{Type: graphql.String, Description: "Server that accepted the message"},
+		},
+	})
+	messageConnection := graphql.NewObject(graphql.ObjectConfig{
+		Name: "MessageConnection",
+		Fields: graphql.Fields{
+			"nodes":        &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(message)))},
+			"pageInfo":     &graphql.Field{Type: graphql.NewNonNull(pageInfo)},
+			"totalCount":   &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"replicasRead": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		},
+	})
+	chat := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Chat",
+		Fields: graphql.Fields{
+			"id":           &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+			"namespace":    &graphql.Field{Type: graphql.String},
+			"tier":         &graphql.Field{Type: graphql.String, Description: "L1 or L2 if this server holds the chat"},
+			"shared":       &graphql.Field{Type: graphql.Boolean, Description: "Held in the shared L2 tier"},
+			"messageCount": &graphql.Field{Type: graphql.Int, Description: "Messages this server holds"},
+			"lastSeq":      &graphql.Field{Type: graphQLLong},
+			"lastAccessed": &graphql.Field{Type: graphql.DateTime},
+			"messages": &graphql.Field{
+				Type:        graphql.NewNonNull(messageConnection),
+				Description: "The chat's messages in sequence order, read from a quorum of its replicas",
+				Args:        pageArgs,
+				Resolve:     s.resolveMessages,
+			},
+		},
+	})
+	chatConnection := graphql.NewObject(graphql.ObjectConfig{
+		Name: "ChatConnection",
+		Fields: graphql.Fields{
+			"nodes":      &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(chat)))},
+			"pageInfo":   &graphql.Field{Type: graphql.NewNonNull(pageInfo)},
+			"totalCount": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		},
+	})
+
+	stats := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Stats",
+		Fields: graphql.Fields{
+			"l1Size":         &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"l1Capacity":     &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"l2Size":         &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"l2Capacity":     &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"totalRequests":  &graphql.Field{Type: graphql.NewNonNull(graphQLLong)},
+			"cacheHits":      &graphql.Field{Type: graphql.NewNonNull(graphQLLong)},
+			"cacheMisses":    &graphql.Field{Type: graphql.NewNonNull(graphQLLong)},
+			"hitRatio":       &graphql.Field{Type: graphql.NewNonNull(graphql.Float)},
+			"evictions":      &graphql.Field{Type: graphql.NewNonNull(graphQLLong)},
+			"demotions":      &graphql.Field{Type: graphql.NewNonNull(graphQLLong)},
+			"staleReads":     &graphql.Field{Type: graphql.NewNonNull(graphQLLong)},
+			"rateLimited":    &graphql.Field{Type: graphql.NewNonNull(graphQLLong)},
+			"slowRequests":   &graphql.Field{Type: graphql.NewNonNull(graphQLLong)},
+			"policyFlagged":  &graphql.Field{Type: graphql.NewNonNull(graphQLLong)},
+			"policyRejected": &graphql.Field{Type: graphql.NewNonNull(graphQLLong)},
+			"subscribers":    &graphql.Field{Type: graphql.NewNonNull(graphQLLong)},
+			"slowConsumers":  &graphql.Field{Type: graphql.NewNonNull(graphQLLong)},
+			"inFlight":       &graphql.Field{Type: graphql.NewNonNull(graphQLLong)},
+		},
+	})
+	serverType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Server",
+		Fields: graphql.Fields{
+			"id":            &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+			"address":       &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"region":        &graphql.Field{Type: graphql.String},
+			"namespace":     &graphql.Field{Type: graphql.String},
+			"state":         &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"uptimeSeconds": &graphql.Field{Type: graphql.NewNonNull(graphQLLong)},
+			"ringEpoch":     &graphql.Field{Type: graphql.NewNonNull(graphQLLong)},
+			"ringNodes":     &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String)))},
+			"stats":         &graphql.Field{Type: graphql.NewNonNull(stats), Resolve: s.resolveStats},
+		},
+	})
+
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"server": &graphql.Field{
+				Type:        graphql.NewNonNull(serverType),
+				Description: "The server answering",
+				Resolve:     s.resolveServer,
+			},
+			"chats": &graphql.Field{
+				Type:        graphql.NewNonNull(chatConnection),
+				Description: "The chats this server holds in L1 and L2, by ID",
+				Args:        pageArgs,
+				Resolve:     s.resolveChats,
+			},
+			"chat": &graphql.Field{
+				Type:        chat,
+				Description: "A chat, whether or not this server holds it",
+				Args: graphql.FieldConfigArgument{
+					"id":        &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+					"namespace": &graphql.ArgumentConfig{Type: graphql.String},
+				},
+				Resolve: s.resolveChat,
+			},
+		},
+	})
+	return graphql.NewSchema(graphql.SchemaConfig{Query: query})
+}
+
+// GraphQLHandler returns an HTTP handler serving the server's read API as
+// GraphQL: the server and its statistics, the chats it holds, and any
+// chat's messages, paginated. Queries are POSTed as JSON ({"query",
+// "variables", "operationName"}) or sent in a GET's query parameter. With
+// an AdminToken set, callers must present it in the X-Admin-Token header:
+// the API reads every chat, like the admin service.
+func (s *ChatServer) GraphQLHandler() http.Handler {
+	schema, err := s.newGraphQLSchema()
+	if err != nil {
+		// The schema is fixed, so this is a bug rather than bad input
+		panic("server: invalid GraphQL schema: " + err.Error())
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.adminToken != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get(adminTokenHeader)), []byte(s.adminToken)) != 1 {
+			s.log.Warn("Rejected unauthenticated GraphQL query", "remote", r.RemoteAddr)
+			http.Error(w, "invalid admin token", http.StatusUnauthorized)
+			return
+		}
+
+		var req struct {
+			Query         string         `json:"query"`
+			Variables     map[string]any `json:"variables"`
+			OperationName string         `json:"operationName"`
+		}
+		switch r.Method {
+		case http.MethodGet:
+			req.Query = r.URL.Query().Get("query")
+			req.OperationName = r.URL.Query().Get("operationName")
+			if v := r.URL.Query().Get("variables"); v != "" {
+				if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+					http.Error(w, "malformed variables: "+err.Error(), http.StatusBadRequest)
+					return
+				}
+			}
+		case http.MethodPost:
+			if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+				http.Error(w, "malformed request: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		default:
+			http.Error(w, "use GET or POST", http.StatusMethodNotAllowed)
+			return
+		}
+
+		result := graphql.Do(graphql.Params{
+			Schema:         schema,
+			RequestString:  req.Query,
+			VariableValues: req.Variables,
+			OperationName:  req.OperationName,
+			Context:        r.Context(),
+		})
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	})
+}
+
+func (s *ChatServer) resolveServer(p graphql.ResolveParams) (any, error) {
+	nodes := s.ring.GetAllNodes()
+	sort.Strings(nodes)
+	return map[string]any{
+		"id":            s.serverID,
+		"address":       s.address,
+		"region":        s.region,
+		"namespace":     s.namespace,
+		"state":         s.State().String(),
+		"uptimeSeconds": int64(s.uptime().Seconds()),
+		"ringEpoch":     s.ring.Epoch(),
+		"ringNodes":     nodes,
+	}, nil
+}
+
+func (s *ChatServer) resolveStats(p graphql.ResolveParams) (any, error) {
+	snapshot := s.statsSnapshot()
+	hitRatio := 0.0
+	if snapshot.TotalRequests > 0 {
+		hitRatio = float64(snapshot.CacheHits) / float64(snapshot.TotalRequests)
+	}
+	return map[string]any{
+		"l1Size":         snapshot.L1Size,
+		"l1Capacity":     snapshot.L1Capacity,
+		"l2Size":         snapshot.L2Size,
+		"l2Capacity":     snapshot.L2Capacity,
+		"totalRequests":  snapshot.TotalRequests,
+		"cacheHits":      snapshot.CacheHits,
+		"cacheMisses":    snapshot.CacheMisses,
+		"hitRatio":       hitRatio,
+		"evictions":      snapshot.Evictions,
+		"demotions":      snapshot.Demotions,
+		"staleReads":     snapshot.StaleReads,
+		"rateLimited":    snapshot.RateLimited,
+		"slowRequests":   snapshot.SlowRequests,
+		"policyFlagged":  snapshot.PolicyFlagged,
+		"policyRejected": snapshot.PolicyRejected,
+		"subscribers":    snapshot.Subscribers,
+		"slowConsumers":  snapshot.SlowConsumers,
+		"inFlight":       s.inFlight.Load(),
+	}, nil
+}
+
+// chatFields describes a chat for the Chat type, with what this server
+// holds of it if it is resident
+func chatFields(id, namespace string, resident *cache.ResidentSession) map[string]any {
+	fields := map[string]any{"id": id, "namespace": namespace}
+	if resident != nil {
+		fields["tier"] = resident.Level.Label()
+		fields["shared"] = resident.Shared
+		fields["messageCount"] = resident.Messages
+		fields["lastSeq"] = resident.LastSeq
+		if !resident.LastAccessed.IsZero() {
+			fields["lastAccessed"] = resident.LastAccessed
+		}
+	}
+	return fields
+}
+
+func (s *ChatServer) resolveChats(p graphql.ResolveParams) (any, error) {
+	resident := s.cache.Resident()
+	sort.Slice(resident, func(i, j int) bool { return resident[i].ChatID < resident[j].ChatID })
+
+	first, err := pageSize(p.Args)
+	if err != nil {
+		return nil, err
+	}
+	start := 0
+	if after, ok := p.Args["after"].(string); ok {
+		start = sort.Search(len(resident), func(i int) bool { return resident[i].ChatID > after })
+	}
+	end := min(start+first, len(resident))
+
+	nodes := make([]map[string]any, 0, end-start)
+	for i := start; i < end; i++ {
+		nodes = append(nodes, chatFields(resident[i].ChatID, s.namespace, &resident[i]))
+	}
+	info := map[string]any{"hasNextPage": end < len(resident)}
+	if end > start {
+		info["endCursor"] = resident[end-1].ChatID
+	}
+	return map[string]any{"nodes": nodes, "pageInfo": info, "totalCount": len(resident)}, nil
+}
+
+func (s *ChatServer) resolveChat(p graphql.ResolveParams) (any, error) {
+	id, _ := p.Args["id"].(string)
+	namespace, _ := p.Args["namespace"].(string)
+	for _, r := range s.cache.Resident() {
+		if r.ChatID == id {
+			return chatFields(id, namespace, &r), nil
+		}
+	}
+	return chatFields(id, namespace, nil), nil
+}
+
+// resolveMessages reads a page of the chat's history the way GetHistory
+// does, from the configured read quorum of its replicas
+func (s *ChatServer) resolveMessages(p graphql.ResolveParams) (any, error) {
+	chat, _ := p.Source.(map[string]any)
+	chatID, _ := chat["id"].(string)
+	namespace, _ := chat["namespace"].(string)
+	first, err := pageSize(p.Args)
+	if err != nil {
+		return nil, err
+	}
+	var after uint64
+	if cursor, ok := p.Args["after"].(string); ok {
+		if after, err = strconv.ParseUint(cursor, 10, 64); err != nil {
+			return nil, errors.New("malformed cursor")
+		}
+	}
+
+	if err := s.checkNamespace(namespace); err != nil {
+		return nil, err
+	}
+	ctx := p.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	resp := s.readHistory(ctx, &pb.HistoryRequest{ChatId: chatID, Namespace: namespace})
+	if !resp.Success {
+		return nil, errors.New(resp.ErrorCode.String() + ": " + resp.ErrorDetails)
+	}
+
+	messages := resp.Messages
+	start := sort.Search(len(messages), func(i int) bool { return messages[i].Seq > after })
+	end := min(start+first, len(messages))
+	nodes := make([]map[string]any, 0, end-start)
+	for _, m := range messages[start:end] {
+		nodes = append(nodes, messageFields(m))
+	}
+	info := map[string]any{"hasNextPage": end < len(messages)}
+	if end > start {
+		info["endCursor"] = strconv.FormatUint(messages[end-1].Seq, 10)
+	}
+	return map[string]any{
+		"nodes":        nodes,
+		"pageInfo":     info,
+		"totalCount":   len(messages),
+		"replicasRead": int(resp.ReplicasRead),
+	}, nil
+}
+
+// messageFields describes a stored message for the Message type
+func messageFields(m *pb.StoredMessage) map[string]any {
+	req := m.GetRequest()
+	fields := map[string]any{
+		"id":        m.MessageId,
+		"seq":       m.Seq,
+		"senderId":  req.GetSenderId(),
+		"timestamp": time.Unix(req.GetTimestamp(), 0).UTC(),
+		"origin":    m.Origin,
+	}
+	switch {
+	case req.GetAttachment() != nil:
+		att := req.GetAttachment()
+		fields["type"] = "attachment"
+		fields["attachment"] = map[string]any{
+			"url": att.Url, "mimeType": att.MimeType, "sizeBytes": att.SizeBytes, "filename": att.Filename,
+		}
+	case req.GetSystemEvent() != nil:
+		fields["type"] = "system_event"
+		fields["event"] = map[string]any{"type": req.GetSystemEvent().Type.String(), "actorId": req.GetSystemEvent().ActorId}
+	default:
+		fields["type"] = "text"
+		fields["text"] = req.GetText()
+	}
+	return fields
+}
+
+// pageSize returns the first argument of a paginated field, defaulted and
+// checked
+func pageSize(args map[string]any) (int, error) {
+	first, ok := args["first"].(int)
+	if !ok {
+		return defaultGraphQLPage, nil
+	}
+	if first < 0 || first > maxGraphQLPage {
+		return 0, errors.New("first must be between 0 and " + strconv.Itoa(maxGraphQLPage))
+	}
+	return first, nil
+}
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/pkg/chaterr"
+	"github.com/sh4shv4t/DistriChat/pkg/ring"
+	pb "github.com/sh4shv4t/DistriChat/proto"
+)
+
+// ownershipLease is the server's current grant of write ownership from its
+// coordinator
+type ownershipLease struct {
+	epoch   uint64
+	ranges  []ring.HashRange
+	expires time.Time
+}
+
+// setLease installs the lease a coordinator response carried. Validity is
+// counted from sent, when the request that obtained it left, so the lease
+// always ends here before the coordinator considers it over. A registered
+// server given no lease has a coordinator that doesn't grant them; an
+// unregistered one just holds none.
+func (s *ChatServer) setLease(lease *pb.OwnershipLease, registered bool, sent time.Time) {
+	s.leaseMu.Lock()
+	defer s.leaseMu.Unlock()
+
+	if lease == nil {
+		s.leased = s.leased && !registered
+		s.lease = nil
+		return
+	}
+
+	s.leased = true
+	s.lease = &ownershipLease{
+		epoch:   lease.Epoch,
+		ranges:  pb.RingRanges(lease.Ranges),
+		expires: sent.Add(time.Duration(lease.DurationMs) * time.Millisecond),
+	}
+}
+
+// checkLease rejects writes for chats outside the server's valid lease.
+// Without a coordinator granting leases, every chat is accepted.
+func (s *ChatServer) checkLease(chatID string) error {
+	s.leaseMu.Lock()
+	defer s.leaseMu.Unlock()
+
+	if !s.leased {
+		return nil
+	}
+	if s.lease == nil {
+		return fmt.Errorf("%w: no ownership lease", chaterr.ErrNotOwner)
+	}
+	if !time.Now().Before(s.lease.expires) {
+		return fmt.Errorf("%w: ownership lease expired at %s", chaterr.ErrNotOwner, s.lease.expires.Format(time.RFC3339Nano))
+	}
+	if !inRanges(s.lease.ranges, s.ring.KeyHash(chatID)) {
+		return fmt.Errorf("%w: chat %s is outside the ownership lease (epoch %d)", chaterr.ErrNotOwner, chatID, s.lease.epoch)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/pkg/cache"
+	"github.com/sh4shv4t/DistriChat/pkg/logging"
+	pb "github.com/sh4shv4t/DistriChat/proto"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// publishMessage appends an accepted message to the external log, for the
+// outbox dispatcher, which retries it until it succeeds. Failures are
+// logged and returned.
+func (s *ChatServer) publishMessage(ctx context.Context, chatID string, msg cache.Message) error {
+	if s.messageLog == nil {
+		return nil
+	}
+
+	ctx, span := tracer.Start(ctx, "msglog.Publish")
+	defer span.End()
+
+	ctx, cancel := s.detach(ctx)
+	defer cancel()
+	ctx, cancelTimeout := context.WithTimeout(ctx, 5*time.Second)
+	defer cancelTimeout()
+
+	if err := s.messageLog.Publish(ctx, storedFromMessage(chatID, msg)); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "publish failed")
+		s.log.WarnContext(ctx, "Failed to publish message", "message_id", msg.ID, logging.Err(err))
+		return err
+	}
+	return nil
+}
+
+// Rebuild replays the external message log into the cache, keeping only
+// chats this server replicates under its current ring view (all of them if
+// it has none). Messages already held or past retention are skipped.
+// Returns the number of messages added.
+func (s *ChatServer) Rebuild(ctx context.Context) (int, error) {
+	if s.messageLog == nil {
+		return 0, fmt.Errorf("no message log configured")
+	}
+
+	added, skipped := 0, 0
+	err := s.messageLog.Replay(ctx, func(stored *pb.StoredMessage) error {
+		chatID, msg, err := messageFromStored(stored)
+		if err != nil {
+			skipped++
+			return nil
+		}
+		if !s.holdsChat(chatID) || s.expired(chatID, msg) {
+			return nil
+		}
+
+		if !msg.HLC.IsZero() {
+			s.clock.Update(msg.HLC)
+		}
+		if ok, err := s.cache.ApplyMessageContext(ctx, chatID, msg); err == nil && ok {
+			s.indexMessage(chatID, msg)
+			added++
+		} else if err != nil {
+			skipped++
+		}
+		return nil
+	})
+	if err != nil {
+		return added, fmt.Errorf("failed to replay message log: %w", err)
+	}
+
+	s.log.Info("Rebuilt messages from the message log", "added", added, "skipped", skipped)
+	return added, nil
+}
+
+// holdsChat reports whether this server is one of the chat's replicas in
+// its region. With no ring view yet, every chat is kept.
+func (s *ChatServer) holdsChat(chatID string) bool {
+	if s.ring.GetNodeCount() == 0 {
+		return true
+	}
+	for _, node := range s.placement().GetNodesInRegion(chatID, s.replication.N, s.region) {
+		if node.NodeID == s.serverID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/internal/statsdiff"
+	"github.com/sh4shv4t/DistriChat/internal/timeseries"
+	pb "github.com/sh4shv4t/DistriChat/proto"
+)
+
+// Metric history defaults: 15 minutes at 1s resolution
+const (
+	defaultMetricHistory         = 15 * time.Minute
+	defaultMetricHistoryInterval = time.Second
+)
+
+// sampleMetricsLoop records the server's metrics in the history buffer
+// every interval until shutdown
+func (s *ChatServer) sampleMetricsLoop() {
+	ticker := time.NewTicker(s.metricHistoryInterval)
+	defer ticker.Stop()
+
+	prev := s.sampleMetrics(statsdiff.Snapshot{})
+	for {
+		select {
+		case <-ticker.C:
+			prev = s.sampleMetrics(prev)
+		case <-s.shutdownCh:
+			return
+		}
+	}
+}
+
+// sampleMetrics records one sample: the cache sizes and requests in
+// flight, and the counters' rates since prev (none in the first sample).
+// It returns the counters read, to diff the next sample against.
+func (s *ChatServer) sampleMetrics(prev statsdiff.Snapshot) statsdiff.Snapshot {
+	now := s.wall.Now()
+	snapshot := s.statsSnapshot()
+	values := map[string]float64{
+		"l1_size":   float64(snapshot.L1Size),
+		"l2_size":   float64(snapshot.L2Size),
+		"in_flight": float64(s.inFlight.Load()),
+	}
+
+	cur := statsdiff.FromServer(now, snapshot)
+	if !prev.Time.IsZero() {
+		delta := statsdiff.Diff(prev, cur)
+		for _, name := range delta.Names() {
+			values[name+"_per_second"] = delta.Rate(name)
+		}
+		values["hit_ratio"] = delta.HitRatio()
+	}
+	s.metricHistory.Add(timeseries.Sample{Time: now, Values: values})
+	return cur
+}
+
+// GetMetricHistory returns the server's recent metrics, oldest sample
+// first. With history disabled the response has no series.
+func (a *AdminServer) GetMetricHistory(ctx context.Context, req *pb.MetricHistoryRequest) (*pb.MetricHistory, error) {
+	s := a.chat
+	resp := &pb.MetricHistory{ServerId: s.serverID}
+	if s.metricHistory == nil {
+		return resp, nil
+	}
+	resp.IntervalMs = s.metricHistoryInterval.Milliseconds()
+	resp.WindowMs = s.metricHistoryInterval.Milliseconds() * int64(s.metricHistory.Capacity())
+
+	q := timeseries.Query{Names: req.Metrics, MaxPoints: int(req.MaxPoints)}
+	if req.SinceMs > 0 {
+		q.Since = time.UnixMilli(req.SinceMs)
+	}
+	for _, series := range s.metricHistory.Query(q) {
+		out := &pb.MetricSeries{Name: series.Name}
+		for _, p := range series.Points {
+			out.TimestampsMs = append(out.TimestampsMs, p.Time.UnixMilli())
+			out.Values = append(out.Values, p.Value)
+		}
+		resp.Series = append(resp.Series, out)
+	}
+	return resp, nil
+}
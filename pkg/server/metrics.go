@@ -0,0 +1,76 @@
+package server
+
+import (
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/pkg/metrics"
+	pb "github.com/sh4shv4t/DistriChat/proto"
+)
+
+// serverMetrics holds the server's series. The atomic counters behind the
+// admin StatsSnapshot stay the in-process view.
+type serverMetrics struct {
+	requests      metrics.CounterVec
+	latency       metrics.HistogramVec
+	staleReads    metrics.Counter
+	rateLimited   metrics.Counter
+	ringConflicts metrics.Counter
+	slowRequests  metrics.CounterVec
+
+	apiKeyRequests   metrics.CounterVec
+	policyActions    metrics.CounterVec
+	transformActions metrics.CounterVec
+
+	fanoutDeliveries metrics.Counter
+	slowConsumers    metrics.CounterVec
+	notifications    metrics.CounterVec
+	retentionPurged  metrics.Counter
+	attachmentBytes  metrics.CounterVec
+	dedupHits        metrics.Counter
+	outboxFailures   metrics.Counter
+}
+
+// newServerMetrics creates the server's series in reg
+func newServerMetrics(reg metrics.Registry) serverMetrics {
+	return serverMetrics{
+		requests: reg.Counter("districhat_server_requests_total",
+			"Client requests handled, by method, tenant and error code", "method", "tenant", "code"),
+		latency: reg.Histogram("districhat_server_request_duration_seconds",
+			"Time to answer client requests", metrics.LatencyBuckets, "method", "tenant"),
+		staleReads: reg.Counter("districhat_server_stale_reads_total",
+			"History reads answered from the local copy alone").With(),
+		rateLimited: reg.Counter("districhat_server_rate_limited_total",
+			"Messages rejected by sender rate limits").With(),
+		ringConflicts: reg.Counter("districhat_server_ring_conflicts_total",
+			"Peer ring views with our epoch but a different digest").With(),
+		slowRequests: reg.Counter("districhat_server_slow_requests_total",
+			"Requests taking longer than the slow request threshold, by method", "method"),
+		apiKeyRequests: reg.Counter("districhat_server_api_key_requests_total",
+			"Client requests authenticated with an API key, by method, key and tenant", "method", "key_id", "tenant"),
+		policyActions: reg.Counter("districhat_server_policy_actions_total",
+			"Messages flagged or rejected by message policies, by policy and action", "policy", "action"),
+		transformActions: reg.Counter("districhat_server_transform_actions_total",
+			"Messages rewritten, annotated or rejected by transform stages, or stages failed, by stage and action", "stage", "action"),
+		fanoutDeliveries: reg.Counter("districhat_server_fanout_deliveries_total",
+			"Messages queued for subscribers to their chats").With(),
+		slowConsumers: reg.Counter("districhat_server_slow_consumers_total",
+			"Subscribers disconnected for falling too far behind, by kind", "kind"),
+		notifications: reg.Counter("districhat_server_notifications_total",
+			"Notifications of messages to members not subscribed, by outcome", "outcome"),
+		retentionPurged: reg.Counter("districhat_server_retention_purged_total",
+			"Messages purged past their chat's retention").With(),
+		attachmentBytes: reg.Counter("districhat_server_attachment_bytes_total",
+			"Attachment content uploaded and downloaded, by direction", "direction"),
+		dedupHits: reg.Counter("districhat_server_dedup_hits_total",
+			"Retried writes answered as duplicates from the dedup store, their message no longer cached").With(),
+		outboxFailures: reg.Counter("districhat_server_outbox_publish_failures_total",
+			"Attempts to publish an outbox entry to the message log that failed, to be retried").With(),
+	}
+}
+
+// observeRequest records a client request's outcome and latency. The
+// tenant is the request's namespace.
+func (s *ChatServer) observeRequest(method, tenant string, code pb.ErrorCode, start time.Time) {
+	s.metrics.requests.With(method, tenant, code.String()).Inc()
+	s.metrics.latency.With(method, tenant).Observe(time.Since(start).Seconds())
+}
@@ -0,0 +1,181 @@
+package server
+
+import (
+	"context"
+	"io"
+	"slices"
+
+	"github.com/sh4shv4t/DistriChat/pkg/rebalance"
+	"github.com/sh4shv4t/DistriChat/pkg/ring"
+	pb "github.com/sh4shv4t/DistriChat/proto"
+)
+
+// MigrationServer implements the gRPC MigrationService for a ChatServer,
+// letting the rebalancer move sessions when ring ownership changes
+type MigrationServer struct {
+	pb.UnimplementedMigrationServiceServer
+
+	chat *ChatServer
+}
+
+// NewMigrationServer creates a migration service bound to a chat server
+func NewMigrationServer(chat *ChatServer) *MigrationServer {
+	return &MigrationServer{chat: chat}
+}
+
+// ExportSession streams every held session whose chat ID hashes into one of
+// the requested ranges. Sessions stay in place; the old owner's cache ages
+// them out once traffic moves to the new owner.
+func (m *MigrationServer) ExportSession(req *pb.ExportSessionRequest, stream pb.MigrationService_ExportSessionServer) error {
+	s := m.chat
+	ranges := make([]ring.HashRange, 0, len(req.Ranges))
+	for _, r := range req.Ranges {
+		ranges = append(ranges, ring.HashRange{Start: r.Start, End: r.End})
+	}
+
+	info := s.cache.GetCacheInfo()
+	chatIDs := append(info.L1Chats, info.L2Chats...)
+
+	exported := 0
+	for _, chatID := range chatIDs {
+		if err := stream.Context().Err(); err != nil {
+			return err
+		}
+		if !inRanges(ranges, s.ring.KeyHash(chatID)) {
+			continue
+		}
+
+		messages := s.cache.HistoryContext(stream.Context(), chatID, 0)
+		if messages == nil {
+			continue // Evicted since the listing
+		}
+		snapshot := &pb.SessionSnapshot{
+			ChatId:   chatID,
+			Messages: storedFromMessages(chatID, messages),
+			Version:  s.cache.VersionContext(stream.Context(), chatID),
+		}
+		if err := stream.Send(snapshot); err != nil {
+			return err
+		}
+		exported++
+	}
+
+	s.log.Info("Exported sessions", "sessions", exported, "ranges", len(ranges))
+	return nil
+}
+
+// ImportSession merges streamed sessions into the local cache. Messages
+// already held are skipped, so an interrupted transfer can simply be rerun.
+// A fresh session whose chat was written to otherwise is refused: its
+// numbering would collide with the chat's.
+func (m *MigrationServer) ImportSession(stream pb.MigrationService_ImportSessionServer) error {
+	s := m.chat
+	resp := &pb.ImportSessionResponse{ServerId: s.serverID}
+
+	for {
+		snapshot, err := stream.Recv()
+		if err == io.EOF {
+			s.log.Info("Imported sessions", "sessions", resp.Sessions, "messages", resp.Messages)
+			return stream.SendAndClose(resp)
+		}
+		if err != nil {
+			return err
+		}
+
+		resp.Sessions++
+		if snapshot.Fresh && s.writtenOutside(stream.Context(), snapshot) {
+			if !slices.Contains(resp.Refused, snapshot.ChatId) {
+				s.log.Warn("Refused import into a chat already written to", "chat_id", snapshot.ChatId)
+				resp.Refused = append(resp.Refused, snapshot.ChatId)
+			}
+			continue
+		}
+		for _, stored := range snapshot.Messages {
+			chatID, msg, err := messageFromStored(stored)
+			if err != nil || chatID != snapshot.ChatId || s.expired(chatID, msg) {
+				continue
+			}
+			if !msg.HLC.IsZero() {
+				s.clock.Update(msg.HLC)
+			}
+			if added, err := s.cache.ApplyMessageContext(stream.Context(), chatID, msg); err == nil && added {
+				s.indexMessage(chatID, msg)
+				resp.Messages++
+			}
+		}
+	}
+}
+
+// writtenOutside reports whether the chat of a snapshot holds messages
+// from origins the snapshot doesn't carry
+func (s *ChatServer) writtenOutside(ctx context.Context, snapshot *pb.SessionSnapshot) bool {
+	carried := make(map[string]bool, len(snapshot.Version))
+	for origin := range snapshot.Version {
+		carried[origin] = true
+	}
+	for _, stored := range snapshot.Messages {
+		carried[stored.Origin] = true
+	}
+	for origin, counter := range s.cache.VersionContext(ctx, snapshot.ChatId) {
+		if counter > 0 && !carried[origin] {
+			return true
+		}
+	}
+	return false
+}
+
+// runRebalancer migrates sessions after every committed membership change
+// until ctx is cancelled. It runs as an elected duty, so only the metadata
+// leader moves sessions. A new leader can't know what its predecessor
+// finished, so it plans from the state current when it took over.
+func (s *ChatServer) runRebalancer(ctx context.Context) {
+	mover := rebalance.NewGRPCMover(s.peerAuthOptions()...)
+	defer mover.Close()
+
+	config := *s.rebalanceConfig
+	if config.Replicas <= 0 {
+		config.Replicas = s.replication.N
+	}
+	if config.Hasher == nil {
+		config.Hasher = s.hasher
+	}
+	rebalancer := rebalance.New(config, mover)
+
+	s.rebalanceMu.Lock()
+	if rate := s.rebalanceRate; rate != nil {
+		rebalancer.SetBytesPerSecond(rate.bytesPerSecond)
+		rebalancer.SetOpsPerSecond(rate.opsPerSecond)
+	}
+	s.rebalancer = rebalancer
+	s.rebalanceMu.Unlock()
+	defer func() {
+		s.rebalanceMu.Lock()
+		s.rebalancer = nil
+		s.rebalanceMu.Unlock()
+	}()
+
+	go func() {
+		<-ctx.Done()
+		rebalancer.Stop()
+	}()
+
+	rebalancer.Apply(s.metadata.State())
+	for {
+		select {
+		case state := <-s.metadataUpdates:
+			rebalancer.Apply(state)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// inRanges reports whether a key hash falls in any of the ranges
+func inRanges(ranges []ring.HashRange, hash uint32) bool {
+	for _, r := range ranges {
+		if r.Contains(hash) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"context"
+	"errors"
+
+	"github.com/sh4shv4t/DistriChat/pkg/cache"
+	"github.com/sh4shv4t/DistriChat/pkg/logging"
+	"github.com/sh4shv4t/DistriChat/pkg/notify"
+	pb "github.com/sh4shv4t/DistriChat/proto"
+)
+
+// notifyOffline queues a notification of a message this server just
+// accepted for the chat's members who don't follow it here: everyone but
+// the sender and those with a subscription on this server. System events
+// aren't announced.
+func (s *ChatServer) notifyOffline(ctx context.Context, req *pb.ChatRequest, msg cache.Message) {
+	if s.notifier == nil || msg.Type == cache.ContentSystemEvent {
+		return
+	}
+
+	online := s.hub.Names(req.ChatId)
+	var recipients []string
+	for member := range s.cache.MembersContext(ctx, req.ChatId) {
+		if member != msg.SenderID && !online[member] {
+			recipients = append(recipients, member)
+		}
+	}
+	if len(recipients) == 0 {
+		return
+	}
+
+	preview := truncateString(msg.Content, 100)
+	if msg.Type == cache.ContentAttachment && msg.Attachment != nil {
+		preview = "Sent an attachment: " + msg.Attachment.Filename
+	}
+	s.notifier.Enqueue(notify.Notification{
+		ChatID:     req.ChatId,
+		Namespace:  req.Namespace,
+		MessageID:  msg.ID,
+		Seq:        msg.Seq,
+		SenderID:   msg.SenderID,
+		Recipients: recipients,
+		Preview:    preview,
+		Timestamp:  msg.Timestamp,
+	})
+}
+
+// notificationDone counts and logs a notification's outcome
+func (s *ChatServer) notificationDone(n notify.Notification, err error) {
+	switch {
+	case err == nil:
+		s.notificationsSent.Add(1)
+		s.metrics.notifications.With("sent").Inc()
+	case errors.Is(err, notify.ErrQueueFull):
+		s.notificationsFailed.Add(1)
+		s.metrics.notifications.With("dropped").Inc()
+		s.log.Warn("Dropped notification, queue full", logging.ChatID(n.ChatID), "message_id", n.MessageID)
+	default:
+		s.notificationsFailed.Add(1)
+		s.metrics.notifications.With("failed").Inc()
+		s.log.Warn("Failed to send notification", logging.ChatID(n.ChatID), "message_id", n.MessageID,
+			"recipients", len(n.Recipients), logging.Err(err))
+	}
+}
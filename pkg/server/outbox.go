@@ -0,0 +1,146 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/pkg/cache"
+	"github.com/sh4shv4t/DistriChat/pkg/logging"
+	"github.com/sh4shv4t/DistriChat/pkg/metrics"
+	pb "github.com/sh4shv4t/DistriChat/proto"
+)
+
+// outboxRetryInterval spaces out attempts to publish messages the message
+// log refused
+const outboxRetryInterval = 5 * time.Second
+
+// commitMessage records a message just appended to the cache in the
+// outbox, making it durable and due for delivery in one step, and wakes
+// the dispatcher. If the outbox can't take it, the message is taken back
+// out of the cache, so it is neither stored nor delivered and the client's
+// retry starts afresh. A committed message is delivered even if its write
+// then falls short of the quorum: it is stored here, and the replicas that
+// took it deliver it too.
+func (s *ChatServer) commitMessage(ctx context.Context, chatID string, msg cache.Message) error {
+	if _, err := s.outbox.Add(storedFromMessage(chatID, msg), s.wall.Now()); err != nil {
+		s.log.ErrorContext(ctx, "Failed to commit message to the outbox, dropping it", "message_id", msg.ID, logging.Err(err))
+		if _, purgeErr := s.cache.Purge(ctx, chatID, func(m cache.Message) bool { return m.ID == msg.ID }); purgeErr != nil {
+			s.log.ErrorContext(ctx, "Failed to drop uncommitted message", "message_id", msg.ID, logging.Err(purgeErr))
+		}
+		return fmt.Errorf("failed to commit message: %w", err)
+	}
+
+	select {
+	case s.outboxWake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// recoverOutbox puts the messages of entries left pending by a crash back
+// in the cache, in case it lost them, before the server takes requests.
+// The dispatcher then delivers them.
+func (s *ChatServer) recoverOutbox() error {
+	pending, err := s.outbox.Pending()
+	if err != nil {
+		return fmt.Errorf("failed to read outbox: %w", err)
+	}
+	restored := 0
+	for _, entry := range pending {
+		chatID, msg, err := messageFromStored(entry.Message)
+		if err != nil {
+			s.log.Warn("Dropping unreadable outbox entry", "entry", entry.ID, logging.Err(err))
+			s.outbox.Done(entry.ID)
+			continue
+		}
+		if !msg.HLC.IsZero() {
+			s.clock.Update(msg.HLC)
+		}
+		if added, err := s.cache.ApplyMessage(chatID, msg); err == nil && added {
+			s.indexMessage(chatID, msg)
+			// A failure is logged by rememberMessage; the dispatcher
+			// still delivers the message
+			s.rememberMessage(context.Background(), chatID, msg)
+			restored++
+		}
+	}
+	if len(pending) > 0 {
+		s.log.Info("Recovered outbox", "pending", len(pending), "restored", restored)
+	}
+	return nil
+}
+
+// outboxLoop delivers committed messages in the order they were accepted:
+// at once when woken, and again every retry interval for those the message
+// log refused
+func (s *ChatServer) outboxLoop() {
+	ticker := time.NewTicker(outboxRetryInterval)
+	defer ticker.Stop()
+
+	s.dispatchOutbox(true)
+	for {
+		select {
+		case <-s.outboxWake:
+			s.dispatchOutbox(false)
+		case <-ticker.C:
+			s.dispatchOutbox(true)
+		case <-s.shutdownCh:
+			return
+		}
+	}
+}
+
+// dispatchOutbox delivers the pending entries. Entries already delivered
+// only wait on the message log, and are retried only if retry is set, so
+// a log outage doesn't hold up new messages to subscribers.
+func (s *ChatServer) dispatchOutbox(retry bool) {
+	pending, err := s.outbox.Pending()
+	if err != nil {
+		s.log.Warn("Failed to read outbox", logging.Err(err))
+		return
+	}
+	logDown := false
+	for _, entry := range pending {
+		if entry.Delivered && !retry {
+			continue
+		}
+		chatID, msg, err := messageFromStored(entry.Message)
+		if err != nil {
+			s.outbox.Done(entry.ID)
+			continue
+		}
+		ctx := logging.With(s.lifetime, logging.ChatID(chatID))
+
+		if !entry.Delivered {
+			s.replicateCrossRegion(ctx, chatID, msg)
+			s.fanOut(chatID, msg)
+			s.notifyOffline(ctx, &pb.ChatRequest{ChatId: chatID, Namespace: s.namespace}, msg)
+			if err := s.outbox.MarkDelivered(entry.ID); err != nil {
+				s.log.WarnContext(ctx, "Failed to record outbox delivery", "message_id", msg.ID, logging.Err(err))
+			}
+		}
+
+		// Once the log fails, the rest wait for the next retry rather than
+		// for its timeout each
+		if logDown {
+			continue
+		}
+		if err := s.publishMessage(ctx, chatID, msg); err != nil {
+			logDown = true
+			s.outbox.MarkFailed(entry.ID)
+			s.metrics.outboxFailures.Inc()
+			continue
+		}
+		if err := s.outbox.Done(entry.ID); err != nil {
+			s.log.WarnContext(ctx, "Failed to record outbox entry done", "message_id", msg.ID, logging.Err(err))
+		}
+	}
+}
+
+// registerOutboxMetrics reports the outbox's backlog in reg
+func (s *ChatServer) registerOutboxMetrics(reg metrics.Registry) {
+	reg.GaugeFunc("districhat_server_outbox_pending", "Accepted messages not yet delivered and published", func() float64 {
+		return float64(s.outbox.Len())
+	})
+}
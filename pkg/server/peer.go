@@ -0,0 +1,126 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/pkg/audit"
+	"github.com/sh4shv4t/DistriChat/pkg/auth"
+	"github.com/sh4shv4t/DistriChat/pkg/mtls"
+	pb "github.com/sh4shv4t/DistriChat/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// peerMethods are the calls on the chat port only other servers make. They
+// store, move or hand out chats without the clients' authentication and
+// access control, trusting the caller to be a replica, so they require a
+// peer's identity instead (see authorizePeer).
+var peerMethods = map[string]bool{
+	pb.ChatService_Replicate_FullMethodName:          true,
+	pb.ChatService_AcquireQuota_FullMethodName:       true,
+	pb.MigrationService_ExportSession_FullMethodName: true,
+	pb.MigrationService_ImportSession_FullMethodName: true,
+	pb.GossipService_Exchange_FullMethodName:         true,
+	pb.PeerService_PurgeSender_FullMethodName:        true,
+}
+
+// PeerServer implements PeerService, the operational calls servers make on
+// each other
+type PeerServer struct {
+	pb.UnimplementedPeerServiceServer
+
+	chat *ChatServer
+}
+
+// NewPeerServer creates a peer service bound to a chat server
+func NewPeerServer(chat *ChatServer) *PeerServer {
+	return &PeerServer{chat: chat}
+}
+
+// PurgeSender deletes a sender's messages sent up to the request's time
+// from this server, for a PurgeUser job run by another server
+func (p *PeerServer) PurgeSender(ctx context.Context, req *pb.PurgeSenderRequest) (*pb.PurgeSenderResponse, error) {
+	if req.SenderId == "" {
+		return p.chat.purgeSenderError(pb.ErrorCode_ERROR_VALIDATION_FAILED, "sender_id is required"), nil
+	}
+	chats, messages, err := p.chat.purgeSender(ctx, req.SenderId, time.UnixMilli(req.BeforeMs))
+	if err != nil {
+		return p.chat.purgeSenderError(pb.ErrorCode_ERROR_INTERNAL, err.Error()), nil
+	}
+	p.chat.log.InfoContext(ctx, "Purged sender's messages", "job_id", req.JobId, "chats", chats, "messages", messages)
+	return &pb.PurgeSenderResponse{Success: true, ServerId: p.chat.serverID, Chats: int32(chats), Messages: messages}, nil
+}
+
+// purgeSenderError builds a failed PurgeSenderResponse
+func (s *ChatServer) purgeSenderError(code pb.ErrorCode, details string) *pb.PurgeSenderResponse {
+	return &pb.PurgeSenderResponse{Success: false, ServerId: s.serverID, ErrorCode: code, ErrorDetails: details}
+}
+
+// peerAuthInterceptor rejects peer-only calls from callers that aren't peers
+func (s *ChatServer) peerAuthInterceptor(ctx context.Context, req interface{},
+	info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if peerMethods[info.FullMethod] {
+		if err := s.authorizePeer(ctx, info.FullMethod); err != nil {
+			return nil, err
+		}
+	}
+	return handler(ctx, req)
+}
+
+// peerStreamAuthInterceptor is the streaming counterpart of peerAuthInterceptor
+func (s *ChatServer) peerStreamAuthInterceptor(srv interface{}, ss grpc.ServerStream,
+	info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if peerMethods[info.FullMethod] {
+		if err := s.authorizePeer(ss.Context(), info.FullMethod); err != nil {
+			return err
+		}
+	}
+	return handler(srv, ss)
+}
+
+// authorizePeer checks that the caller of ctx is another server of the
+// cluster. Servers send each other the admin token, if one is set, and
+// present their certificate with TLS, which must name a server of the ring
+// or a configured admin: any other verified certificate is only a client's.
+// A cluster with
+// neither admin token nor TLS has no peer credentials: it takes peer calls
+// from anyone only if it takes client calls from anyone too, i.e. without
+// an authenticator.
+func (s *ChatServer) authorizePeer(ctx context.Context, method string) error {
+	if s.adminToken != "" {
+		md, _ := metadata.FromIncomingContext(ctx)
+		if tokens := md.Get(adminTokenHeader); len(tokens) > 0 &&
+			subtle.ConstantTimeCompare([]byte(tokens[0]), []byte(s.adminToken)) == 1 {
+			return nil
+		}
+	}
+	if s.tls != nil {
+		id := mtls.Identity(ctx)
+		if id != "" && (s.ring.NodeExists(id) || (s.access != nil && s.access.isAdmin(auth.Principal{ID: id}))) {
+			return nil
+		}
+	}
+	if s.adminToken == "" && s.tls == nil && s.authenticator == nil {
+		return nil
+	}
+
+	s.log.Warn("Rejected peer call from a caller that isn't a peer", "method", method)
+	s.audit(ctx, audit.Event{Action: audit.ActionAuthFailure, Target: method, Outcome: audit.OutcomeDenied,
+		Reason: "not a peer"})
+	return status.Error(codes.PermissionDenied, "only servers of the cluster may call "+method)
+}
+
+// adminTokenCredentials sends the admin token on every call to a peer
+type adminTokenCredentials string
+
+func (t adminTokenCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{adminTokenHeader: string(t)}, nil
+}
+
+func (t adminTokenCredentials) RequireTransportSecurity() bool {
+	return false
+}
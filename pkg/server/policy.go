@@ -0,0 +1,44 @@
+package server
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sh4shv4t/DistriChat/pkg/auth"
+	"github.com/sh4shv4t/DistriChat/pkg/cache"
+	"github.com/sh4shv4t/DistriChat/pkg/chaterr"
+	"github.com/sh4shv4t/DistriChat/pkg/policy"
+	pb "github.com/sh4shv4t/DistriChat/proto"
+)
+
+// screenMessage runs msg past the server's message policies, returning an
+// error wrapping chaterr.ErrPolicyRejected if one refuses it. Every flag
+// and rejection is logged and counted by policy.
+func (s *ChatServer) screenMessage(ctx context.Context, req *pb.ChatRequest, p auth.Principal, msg cache.Message) error {
+	if len(s.policies) == 0 || req.FederatedFrom != "" {
+		return nil
+	}
+
+	outcome, decisions := policy.Evaluate(ctx, s.policies, policy.Message{
+		ChatID:    req.ChatId,
+		Namespace: req.Namespace,
+		SenderID:  req.SenderId,
+		Principal: p.ID,
+		Content:   msg.Content,
+		Timestamp: msg.Timestamp,
+	})
+	for _, d := range decisions {
+		s.metrics.policyActions.With(d.Policy, d.Action.String()).Inc()
+		s.log.WarnContext(ctx, "Message policy objected to message", "policy", d.Policy,
+			"action", d.Action.String(), "sender_id", req.SenderId, "reason", d.Reason)
+	}
+
+	switch outcome.Action {
+	case policy.Reject:
+		s.policyRejected.Add(1)
+		return fmt.Errorf("%w: %s: %s", chaterr.ErrPolicyRejected, outcome.Policy, outcome.Reason)
+	case policy.Flag:
+		s.policyFlagged.Add(1)
+	}
+	return nil
+}
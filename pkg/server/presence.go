@@ -0,0 +1,361 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/pkg/chaterr"
+	"github.com/sh4shv4t/DistriChat/pkg/logging"
+	"github.com/sh4shv4t/DistriChat/pkg/metrics"
+	"github.com/sh4shv4t/DistriChat/pkg/presence"
+	pb "github.com/sh4shv4t/DistriChat/proto"
+)
+
+// presenceOwner returns the server holding a user's presence: the one its
+// ID hashes to, this server's own ID with no ring view yet
+func (s *ChatServer) presenceOwner(userID string) (string, string) {
+	nodeID, address, ok := s.placement().GetNode(userID)
+	if !ok {
+		return s.serverID, s.address
+	}
+	return nodeID, address
+}
+
+// registerPresenceMetrics reports the users online here in reg
+func (s *ChatServer) registerPresenceMetrics(reg metrics.Registry) {
+	reg.GaugeFunc("districhat_server_users_online", "Users whose presence this server holds that are online", func() float64 {
+		return float64(s.presence.Online())
+	})
+}
+
+// streamSource names this server's subscription streams as a presence
+// source
+func streamSource(serverID string) string {
+	return "stream:" + serverID
+}
+
+// streamJoined counts a subscription stream for userID, reporting the user
+// online on its first
+func (s *ChatServer) streamJoined(userID string) {
+	s.presenceMu.Lock()
+	s.streamUsers[userID]++
+	first := s.streamUsers[userID] == 1
+	s.presenceMu.Unlock()
+	if first {
+		s.reportPresence(userID, false)
+	}
+}
+
+// streamLeft uncounts a subscription stream for userID, reporting the
+// source ended with its last
+func (s *ChatServer) streamLeft(userID string) {
+	s.presenceMu.Lock()
+	s.streamUsers[userID]--
+	last := s.streamUsers[userID] <= 0
+	if last {
+		delete(s.streamUsers, userID)
+	}
+	s.presenceMu.Unlock()
+	if last {
+		s.reportPresence(userID, true)
+	}
+}
+
+// reportPresence refreshes or ends this server's streams as a presence
+// source for userID, on the server holding the user's presence. Remote
+// reports are sent in the background; one lost is made up by the next
+// refresh, or by the source's TTL.
+func (s *ChatServer) reportPresence(userID string, offline bool) {
+	ownerID, address := s.presenceOwner(userID)
+	if ownerID == s.serverID {
+		if offline {
+			s.presence.Leave(userID, streamSource(s.serverID))
+		} else {
+			s.presence.Touch(userID, streamSource(s.serverID), 0)
+		}
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(s.lifetime, 5*time.Second)
+		defer cancel()
+		client, err := s.peerClient(address)
+		if err == nil {
+			_, err = client.UpdatePresence(ctx, &pb.PresenceUpdate{
+				UserId:   userID,
+				Offline:  offline,
+				TtlMs:    s.presence.TTL().Milliseconds(),
+				Reporter: s.serverID,
+			})
+		}
+		if err != nil && s.lifetime.Err() == nil {
+			s.log.Debug("Failed to report presence", "user_id", userID, logging.NodeID(ownerID), logging.Err(err))
+		}
+	}()
+}
+
+// presenceLoop expires stale presence sources and refreshes this server's
+// streams as sources, at a third of the TTL so a report may be lost
+// without its users flickering offline
+func (s *ChatServer) presenceLoop() {
+	ticker := time.NewTicker(s.presence.TTL() / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.presence.Expire()
+
+			s.presenceMu.Lock()
+			users := make([]string, 0, len(s.streamUsers))
+			for userID := range s.streamUsers {
+				users = append(users, userID)
+			}
+			s.presenceMu.Unlock()
+			for _, userID := range users {
+				s.reportPresence(userID, false)
+			}
+		case <-s.shutdownCh:
+			return
+		}
+	}
+}
+
+// UpdatePresence refreshes or ends a presence source of a user. Clients may
+// only send heartbeats for themselves, to any server: those landing off the
+// user's owner are relayed to it. Servers report their subscription streams
+// and relay heartbeats under their own ID.
+func (s *ChatServer) UpdatePresence(ctx context.Context, req *pb.PresenceUpdate) (*pb.PresenceResponse, error) {
+	if req.UserId == "" {
+		return s.presenceError(pb.ErrorCode_ERROR_VALIDATION_FAILED, "user_id is required"), nil
+	}
+
+	if req.Reporter == "" {
+		ctx, principal, err := s.authenticate(ctx, "UpdatePresence", s.namespace)
+		if err != nil {
+			return s.presenceError(chaterr.Code(err, pb.ErrorCode_ERROR_INTERNAL), err.Error()), nil
+		}
+		if principal.ID != "" && principal.ID != req.UserId {
+			err := s.deny(ctx, req.UserId, fmt.Errorf("%w: %s can't send heartbeats for %s",
+				chaterr.ErrPermissionDenied, principal.ID, req.UserId))
+			return s.presenceError(pb.ErrorCode_ERROR_PERMISSION_DENIED, err.Error()), nil
+		}
+		if req.Device == "" {
+			req.Device = "default"
+		}
+		if ownerID, address := s.presenceOwner(req.UserId); ownerID != s.serverID {
+			return s.relayHeartbeat(ctx, ownerID, address, req), nil
+		}
+	}
+
+	// Heartbeats carry the device sending them; stream reports don't
+	source := streamSource(req.Reporter)
+	if req.Device != "" {
+		source = "client:" + req.Device
+	}
+	var state presence.State
+	if req.Offline {
+		state = s.presence.Leave(req.UserId, source)
+	} else {
+		state = s.presence.Touch(req.UserId, source, time.Duration(req.TtlMs)*time.Millisecond)
+	}
+	return &pb.PresenceResponse{Success: true, ServerId: s.serverID, Presence: []*pb.Presence{presenceProto(state)}}, nil
+}
+
+// relayHeartbeat passes a client's heartbeat on to the user's owner
+func (s *ChatServer) relayHeartbeat(ctx context.Context, ownerID, address string, req *pb.PresenceUpdate) *pb.PresenceResponse {
+	relayed := &pb.PresenceUpdate{
+		UserId:   req.UserId,
+		Offline:  req.Offline,
+		TtlMs:    req.TtlMs,
+		Device:   req.Device,
+		Reporter: s.serverID,
+	}
+
+	client, err := s.peerClient(address)
+	if err != nil {
+		return s.presenceError(pb.ErrorCode_ERROR_INTERNAL, err.Error())
+	}
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	resp, err := client.UpdatePresence(ctx, relayed)
+	if err != nil {
+		return s.presenceError(pb.ErrorCode_ERROR_INTERNAL, fmt.Sprintf("relaying heartbeat to %s: %v", ownerID, err))
+	}
+	return resp
+}
+
+// GetPresence returns users' presence, asking the servers holding it
+func (s *ChatServer) GetPresence(ctx context.Context, req *pb.GetPresenceRequest) (*pb.PresenceResponse, error) {
+	if !req.Local {
+		if _, _, err := s.authenticate(ctx, "GetPresence", s.namespace); err != nil {
+			return s.presenceError(chaterr.Code(err, pb.ErrorCode_ERROR_INTERNAL), err.Error()), nil
+		}
+	}
+
+	resp := &pb.PresenceResponse{Success: true, ServerId: s.serverID}
+	for address, users := range s.groupByPresenceOwner(req.UserIds, req.Local) {
+		if address == "" {
+			for _, userID := range users {
+				resp.Presence = append(resp.Presence, presenceProto(s.presence.Get(userID)))
+			}
+			continue
+		}
+
+		// Users whose server can't be reached are reported unknown
+		remote, err := s.remotePresence(ctx, address, users)
+		if err != nil {
+			s.log.WarnContext(ctx, "Failed to read presence", "address", address, logging.Err(err))
+			for _, userID := range users {
+				resp.Presence = append(resp.Presence, &pb.Presence{UserId: userID})
+			}
+			continue
+		}
+		resp.Presence = append(resp.Presence, remote...)
+	}
+	return resp, nil
+}
+
+// remotePresence reads users' presence from the server holding it
+func (s *ChatServer) remotePresence(ctx context.Context, address string, users []string) ([]*pb.Presence, error) {
+	client, err := s.peerClient(address)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	resp, err := client.GetPresence(ctx, &pb.GetPresenceRequest{UserIds: users, Local: true})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Presence, nil
+}
+
+// groupByPresenceOwner groups users by the address of the server holding
+// their presence, "" for this one. With local set, every user is
+// answered here.
+func (s *ChatServer) groupByPresenceOwner(users []string, local bool) map[string][]string {
+	groups := make(map[string][]string)
+	for _, userID := range users {
+		ownerID, address := s.presenceOwner(userID)
+		if local || ownerID == s.serverID {
+			address = ""
+		}
+		groups[address] = append(groups[address], userID)
+	}
+	return groups
+}
+
+// WatchPresence streams users' presence: their current states, then each
+// change. Users held elsewhere are watched on their servers and relayed;
+// the stream ends when one of those breaks, and the caller opens it again.
+func (s *ChatServer) WatchPresence(req *pb.WatchPresenceRequest, stream pb.ChatService_WatchPresenceServer) error {
+	ctx := stream.Context()
+	if !req.Local {
+		if _, _, err := s.authenticate(ctx, "WatchPresence", s.namespace); err != nil {
+			return err
+		}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	updates := make(chan *pb.Presence, len(req.UserIds)+1)
+	failed := make(chan error, 1)
+	for address, users := range s.groupByPresenceOwner(req.UserIds, req.Local) {
+		if address == "" {
+			watch := s.presence.Watch(users...)
+			defer watch.Close()
+			for _, userID := range users {
+				if err := stream.Send(presenceProto(s.presence.Get(userID))); err != nil {
+					return err
+				}
+			}
+			go func() {
+				for state := range watch.C() {
+					select {
+					case updates <- presenceProto(state):
+					case <-ctx.Done():
+						return
+					}
+				}
+			}()
+			continue
+		}
+
+		go func(address string, users []string) {
+			err := s.relayPresence(ctx, address, users, updates)
+			select {
+			case failed <- err:
+			default:
+			}
+		}(address, users)
+	}
+
+	for {
+		select {
+		case update := <-updates:
+			if err := stream.Send(update); err != nil {
+				return err
+			}
+		case err := <-failed:
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("presence watch on a peer ended: %w", err)
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-s.shutdownCh:
+			return nil
+		}
+	}
+}
+
+// relayPresence watches users on the server holding their presence,
+// passing every update to out until the watch or ctx ends
+func (s *ChatServer) relayPresence(ctx context.Context, address string, users []string, out chan<- *pb.Presence) error {
+	client, err := s.peerClient(address)
+	if err != nil {
+		return err
+	}
+	watch, err := client.WatchPresence(ctx, &pb.WatchPresenceRequest{UserIds: users, Local: true})
+	if err != nil {
+		return err
+	}
+	for {
+		update, err := watch.Recv()
+		if errors.Is(err, io.EOF) {
+			return errors.New("peer closed the stream")
+		}
+		if err != nil {
+			return err
+		}
+		select {
+		case out <- update:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// presenceError builds a failed PresenceResponse
+func (s *ChatServer) presenceError(code pb.ErrorCode, details string) *pb.PresenceResponse {
+	return &pb.PresenceResponse{Success: false, ServerId: s.serverID, ErrorCode: code, ErrorDetails: details}
+}
+
+// presenceProto converts a presence state for the wire
+func presenceProto(state presence.State) *pb.Presence {
+	p := &pb.Presence{UserId: state.UserID}
+	switch state.Status {
+	case presence.Online:
+		p.Status = pb.PresenceStatus_PRESENCE_ONLINE
+	case presence.Offline:
+		p.Status = pb.PresenceStatus_PRESENCE_OFFLINE
+	}
+	if !state.LastSeen.IsZero() {
+		p.LastSeenMs = state.LastSeen.UnixMilli()
+	}
+	return p
+}
@@ -0,0 +1,278 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/pkg/audit"
+	"github.com/sh4shv4t/DistriChat/pkg/cache"
+	"github.com/sh4shv4t/DistriChat/pkg/logging"
+	"github.com/sh4shv4t/DistriChat/pkg/msglog"
+	"github.com/sh4shv4t/DistriChat/pkg/purge"
+	pb "github.com/sh4shv4t/DistriChat/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// purgeCallTimeout bounds one server's purge within a job. A server holding
+// many archived chats rewrites each of them.
+const purgeCallTimeout = 5 * time.Minute
+
+// PurgeUser starts a job purging a user's messages from every server of
+// the cluster, or resumes an unfinished one. The job runs in the
+// background; GetPurgeJob follows it.
+func (a *AdminServer) PurgeUser(ctx context.Context, req *pb.PurgeUserRequest) (*pb.PurgeJob, error) {
+	s := a.chat
+	var job purge.Job
+	if req.JobId != "" {
+		var err error
+		job, err = s.purgeJobs.Get(req.JobId)
+		if errors.Is(err, purge.ErrJobNotFound) {
+			return nil, status.Errorf(codes.NotFound, "no purge job %q", req.JobId)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if job.State == purge.Done {
+			return purgeJobProto(job), nil
+		}
+	} else {
+		if req.UserId == "" {
+			return nil, status.Error(codes.InvalidArgument, "user_id is required")
+		}
+		job = purge.NewJob(req.UserId, req.Reason, s.purgeTargets(), s.wall.Now())
+	}
+
+	job.State = purge.Running
+	if err := s.purgeJobs.Save(job); err != nil {
+		return nil, err
+	}
+	s.audit(ctx, audit.Event{Action: audit.ActionUserPurge, Target: job.UserID, Reason: job.Reason,
+		Details: map[string]string{"job_id": job.ID, "resumed": fmt.Sprint(req.JobId != "")}})
+	// The job's progress is updated in place once running, so the answer
+	// is built first
+	resp := purgeJobProto(job)
+	s.startPurge(job)
+	return resp, nil
+}
+
+// GetPurgeJob reports a purge job's progress
+func (a *AdminServer) GetPurgeJob(ctx context.Context, req *pb.GetPurgeJobRequest) (*pb.PurgeJob, error) {
+	job, err := a.chat.purgeJobs.Get(req.JobId)
+	if errors.Is(err, purge.ErrJobNotFound) {
+		return nil, status.Errorf(codes.NotFound, "no purge job %q", req.JobId)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return purgeJobProto(job), nil
+}
+
+// purgeTargets returns every server of the cluster, in all namespaces and
+// regions: a user may have written to chats on any of them
+func (s *ChatServer) purgeTargets() []string {
+	servers := s.ring.GetAllNodes()
+	if len(servers) == 0 {
+		servers = []string{s.serverID}
+	}
+	return servers
+}
+
+// resumePurges restarts the jobs that were running when the server last
+// stopped
+func (s *ChatServer) resumePurges() {
+	jobs, err := s.purgeJobs.List()
+	if err != nil {
+		s.log.Warn("Failed to list purge jobs", logging.Err(err))
+		return
+	}
+	for _, job := range jobs {
+		if job.State == purge.Running {
+			s.log.Info("Resuming purge job", "job_id", job.ID, "pending", len(job.Pending()))
+			s.startPurge(job)
+		}
+	}
+}
+
+// startPurge runs a job in the background unless it is already running
+func (s *ChatServer) startPurge(job purge.Job) {
+	s.purgeMu.Lock()
+	defer s.purgeMu.Unlock()
+	if s.purgesRunning[job.ID] {
+		return
+	}
+	s.purgesRunning[job.ID] = true
+	go func() {
+		defer func() {
+			s.purgeMu.Lock()
+			delete(s.purgesRunning, job.ID)
+			s.purgeMu.Unlock()
+		}()
+		s.runPurge(job)
+	}()
+}
+
+// runPurge asks each server the job hasn't purged yet to purge the user's
+// messages, saving the job after each so a restart resumes where it
+// stopped. Servers that joined since the job started are added to it.
+// Shutdown leaves the job running, to be resumed on the next start.
+func (s *ChatServer) runPurge(job purge.Job) {
+	job.Add(s.purgeTargets()...)
+	for _, serverID := range job.Pending() {
+		if s.lifetime.Err() != nil {
+			return
+		}
+		chats, messages, err := s.purgeOn(serverID, job)
+		if err != nil && s.lifetime.Err() != nil {
+			return
+		}
+		if err != nil {
+			s.log.Warn("Failed to purge user's messages", "job_id", job.ID, logging.NodeID(serverID), logging.Err(err))
+		}
+		job.Record(serverID, chats, messages, err, s.wall.Now())
+		if err := s.purgeJobs.Save(job); err != nil {
+			s.log.Warn("Failed to save purge job", "job_id", job.ID, logging.Err(err))
+		}
+	}
+
+	job.Finish(s.wall.Now())
+	if err := s.purgeJobs.Save(job); err != nil {
+		s.log.Warn("Failed to save purge job", "job_id", job.ID, logging.Err(err))
+	}
+	s.log.Info("Purge job finished", "job_id", job.ID, "state", job.State.String(),
+		"servers_done", job.ServersDone(), "servers", len(job.Servers), "messages", job.Messages())
+}
+
+// purgeOn purges the job's user's messages from one server: this one
+// directly, others through PurgeSender
+func (s *ChatServer) purgeOn(serverID string, job purge.Job) (int, int64, error) {
+	ctx, cancel := context.WithTimeout(s.lifetime, purgeCallTimeout)
+	defer cancel()
+	if serverID == s.serverID {
+		return s.purgeSender(ctx, job.UserID, job.Before)
+	}
+
+	address, ok := s.ring.GetNodeAddress(serverID)
+	if !ok {
+		return 0, 0, fmt.Errorf("server %s is not in the ring", serverID)
+	}
+	conn, err := s.peerConn(address)
+	if err != nil {
+		return 0, 0, err
+	}
+	resp, err := pb.NewPeerServiceClient(conn).PurgeSender(ctx, &pb.PurgeSenderRequest{
+		SenderId: job.UserID,
+		BeforeMs: job.Before.UnixMilli(),
+		JobId:    job.ID,
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	if !resp.Success {
+		return 0, 0, fmt.Errorf("%s: %s", resp.ErrorCode, resp.ErrorDetails)
+	}
+	return int(resp.Chats), resp.Messages, nil
+}
+
+// purgeSender removes a sender's messages sent up to before from every chat
+// this server holds, cached or archived, from its search index and from a
+// message log that supports compaction. System events are kept, as chats'
+// members are derived from them. The sender is remembered for the life of
+// the process, so peers, migrations and log replays can't bring the
+// messages back. Purging again is harmless, so a failed purge is retried
+// whole. Returns the chats that lost messages and how many they lost.
+func (s *ChatServer) purgeSender(ctx context.Context, senderID string, before time.Time) (int, int64, error) {
+	s.purgeMu.Lock()
+	if current, ok := s.purgedSenders[senderID]; !ok || before.After(current) {
+		s.purgedSenders[senderID] = before
+	}
+	s.purgeMu.Unlock()
+
+	chats := make(map[string]bool)
+	for _, session := range s.cache.Resident() {
+		chats[session.ChatID] = true
+	}
+	if lister, ok := s.archive.(archivedLister); ok {
+		for _, chatID := range lister.Archived() {
+			chats[chatID] = true
+		}
+	}
+
+	match := func(msg cache.Message) bool {
+		return msg.SenderID == senderID && msg.Type != cache.ContentSystemEvent && !msg.Timestamp.After(before)
+	}
+	purgedChats, purged := 0, int64(0)
+	var failed error
+	for chatID := range chats {
+		if err := ctx.Err(); err != nil {
+			return purgedChats, purged, err
+		}
+		removed, err := s.cache.Purge(ctx, chatID, match)
+		if err != nil {
+			failed = fmt.Errorf("failed to purge %s: %w", chatID, err)
+		}
+		if len(removed) > 0 {
+			purgedChats++
+			purged += int64(len(removed))
+		}
+	}
+	if s.search != nil {
+		s.search.DeleteSender(senderID, before)
+	}
+
+	if compactor, ok := s.messageLog.(msglog.Compactor); ok {
+		_, err := compactor.Compact(ctx, func(stored *pb.StoredMessage) bool {
+			req := stored.GetRequest()
+			return req.GetSenderId() == senderID && req.GetSystemEvent() == nil &&
+				!time.Unix(req.GetTimestamp(), 0).After(before)
+		})
+		if err != nil {
+			failed = fmt.Errorf("failed to compact the message log: %w", err)
+		}
+	}
+	return purgedChats, purged, failed
+}
+
+// purgedSender reports whether a message's sender was purged from this
+// server after it was sent
+func (s *ChatServer) purgedSender(msg cache.Message) bool {
+	s.purgeMu.Lock()
+	before, ok := s.purgedSenders[msg.SenderID]
+	s.purgeMu.Unlock()
+	return ok && msg.Type != cache.ContentSystemEvent && !msg.Timestamp.After(before)
+}
+
+// purgeJobProto converts a purge job for the wire
+func purgeJobProto(job purge.Job) *pb.PurgeJob {
+	out := &pb.PurgeJob{
+		Id:             job.ID,
+		UserId:         job.UserID,
+		Reason:         job.Reason,
+		BeforeMs:       job.Before.UnixMilli(),
+		CreatedMs:      job.Created.UnixMilli(),
+		UpdatedMs:      job.Updated.UnixMilli(),
+		ServersDone:    int32(job.ServersDone()),
+		MessagesPurged: job.Messages(),
+	}
+	switch job.State {
+	case purge.Running:
+		out.State = pb.PurgeJobState_PURGE_JOB_RUNNING
+	case purge.Done:
+		out.State = pb.PurgeJobState_PURGE_JOB_DONE
+	case purge.Incomplete:
+		out.State = pb.PurgeJobState_PURGE_JOB_INCOMPLETE
+	}
+	for _, p := range job.Servers {
+		out.Servers = append(out.Servers, &pb.PurgeProgress{
+			ServerId: p.ServerID,
+			Done:     p.Done,
+			Chats:    int32(p.Chats),
+			Messages: p.Messages,
+			Attempts: int32(p.Attempts),
+			Error:    p.Err,
+		})
+	}
+	return out
+}
@@ -0,0 +1,79 @@
+package server
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sh4shv4t/DistriChat/pkg/chaterr"
+	"github.com/sh4shv4t/DistriChat/pkg/logging"
+	pb "github.com/sh4shv4t/DistriChat/proto"
+)
+
+// checkSender spends one of the sender's tokens, returning an error wrapping
+// chaterr.ErrRateLimited when the sender is over its quota. Messages relayed by a federation bridge were
+// counted by the cluster they came from. If the sender's owner can't be
+// reached, this server falls back to its own bucket for the sender, so the
+// limit still holds per server until the owner is back.
+func (s *ChatServer) checkSender(ctx context.Context, req *pb.ChatRequest) error {
+	if s.quota == nil || req.FederatedFrom != "" {
+		return nil
+	}
+
+	allowed, err := s.quota.Allow(ctx, req.SenderId)
+	if err != nil {
+		s.log.WarnContext(ctx, "Quota lease failed, enforcing locally", "sender_id", req.SenderId, logging.Err(err))
+		allowed = s.limiter.Acquire(req.SenderId, 1) == 1
+	}
+	if allowed {
+		return nil
+	}
+	s.rateLimited.Add(1)
+	s.metrics.rateLimited.Inc()
+	return fmt.Errorf("%w: sender %s exceeded %g messages/s", chaterr.ErrRateLimited, req.SenderId, s.limiter.Config().Rate)
+}
+
+// acquireQuota leases up to n tokens from the bucket of the server the
+// sender hashes to
+func (s *ChatServer) acquireQuota(ctx context.Context, senderID string, n int) (int, error) {
+	ownerID, address, ok := s.placement().GetNode(senderID)
+	if !ok || ownerID == s.serverID {
+		return s.limiter.Acquire(senderID, n), nil
+	}
+
+	client, err := s.peerClient(address)
+	if err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.replication.Timeout)
+	defer cancel()
+
+	resp, err := client.AcquireQuota(ctx, &pb.QuotaRequest{SenderId: senderID, Tokens: int32(n)})
+	if err != nil {
+		return 0, fmt.Errorf("quota owner %s: %w", ownerID, err)
+	}
+	if !resp.Success {
+		return 0, &chaterr.Rejection{ServerID: ownerID, Op: "quota lease", Code: resp.ErrorCode, Details: resp.ErrorDetails}
+	}
+	return int(resp.Granted), nil
+}
+
+// AcquireQuota grants tokens from the bucket of a sender this server owns.
+// It answers even if its ring view disagrees about the owner: during an
+// ownership change the sender briefly has two buckets, which only loosens
+// the limit.
+func (s *ChatServer) AcquireQuota(ctx context.Context, req *pb.QuotaRequest) (*pb.QuotaResponse, error) {
+	if s.limiter == nil {
+		return &pb.QuotaResponse{
+			ServerId:     s.serverID,
+			ErrorCode:    pb.ErrorCode_ERROR_VALIDATION_FAILED,
+			ErrorDetails: "rate limiting is not enabled",
+		}, nil
+	}
+
+	return &pb.QuotaResponse{
+		Success:  true,
+		ServerId: s.serverID,
+		Granted:  int32(s.limiter.Acquire(req.SenderId, int(req.Tokens))),
+	}, nil
+}
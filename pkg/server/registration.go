@@ -0,0 +1,121 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/pkg/logging"
+	pb "github.com/sh4shv4t/DistriChat/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// joinCoordinator registers the server with its coordinator, installs the
+// returned ring, and keeps the registration alive and the ring current
+// until the server stops
+func (s *ChatServer) joinCoordinator() error {
+	conn, err := grpc.Dial(s.coordinatorAddress, s.coordinatorDialOptions()...)
+	if err != nil {
+		return fmt.Errorf("failed to connect to coordinator %s: %w", s.coordinatorAddress, err)
+	}
+	coordinator := pb.NewCoordinatorServiceClient(conn)
+
+	if err := s.register(coordinator); err != nil {
+		conn.Close()
+		return err
+	}
+
+	s.mu.Lock()
+	s.coordinator = coordinator
+	s.mu.Unlock()
+
+	s.watchCoordinator(conn)
+	go s.heartbeatLoop(coordinator)
+
+	s.log.Info("Registered with coordinator", "coordinator", s.coordinatorAddress, "address", s.address,
+		"region", s.region, "namespace", s.namespace)
+	return nil
+}
+
+// coordinatorDialOptions are the options of the connection to the
+// coordinator, which is plaintext (see Mutual TLS in the README)
+func (s *ChatServer) coordinatorDialOptions() []grpc.DialOption {
+	opts := []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+	if s.dialer != nil {
+		opts = append(opts, grpc.WithContextDialer(s.dialer))
+	}
+	return opts
+}
+
+// register announces the server and installs the ring it is now part of
+func (s *ChatServer) register(coordinator pb.CoordinatorServiceClient) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	sent := time.Now()
+	resp, err := coordinator.Register(ctx, &pb.RegisterRequest{
+		ServerId:     s.serverID,
+		Address:      s.address,
+		Capacity:     int32(s.capacity),
+		Region:       s.region,
+		Namespace:    s.namespace,
+		AdminAddress: s.adminAddress(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to register with coordinator %s: %w", s.coordinatorAddress, err)
+	}
+	s.SetRingState(resp.Ring.ToRing())
+	s.setLease(resp.Lease, true, sent)
+	return nil
+}
+
+// heartbeatLoop reports liveness every heartbeatInterval, renewing the
+// ownership lease, and registers again if the coordinator evicted the
+// server (e.g. after a long pause). While the coordinator is unreachable the
+// lease is not renewed, so a partitioned server stops accepting writes.
+func (s *ChatServer) heartbeatLoop(coordinator pb.CoordinatorServiceClient) {
+	ticker := time.NewTicker(s.heartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), s.heartbeatInterval)
+			sent := time.Now()
+			resp, err := coordinator.Heartbeat(ctx, &pb.HeartbeatRequest{ServerId: s.serverID})
+			cancel()
+			if err != nil {
+				s.log.Warn("Heartbeat failed", logging.Err(err))
+				continue
+			}
+			s.setLease(resp.Lease, resp.Registered, sent)
+			if !resp.Registered && s.healthy.Load() {
+				s.log.Warn("Evicted by coordinator, registering again")
+				if err := s.register(coordinator); err != nil {
+					s.log.Error("Registration failed", logging.Err(err))
+				}
+			}
+		case <-s.shutdownCh:
+			return
+		}
+	}
+}
+
+// leaveCoordinator deregisters the server if it joined a coordinator, giving
+// up after 2s or when ctx ends (must be called with s.mu held, before
+// shutdownCh closes the connection)
+func (s *ChatServer) leaveCoordinator(ctx context.Context) {
+	if s.coordinator == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	if _, err := s.coordinator.Deregister(ctx, &pb.DeregisterRequest{ServerId: s.serverID}); err != nil {
+		s.log.Warn("Failed to deregister", logging.Err(err))
+		return
+	}
+	s.log.Info("Deregistered from coordinator")
+}
@@ -0,0 +1,144 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/pkg/audit"
+	"github.com/sh4shv4t/DistriChat/pkg/gossip"
+	"github.com/sh4shv4t/DistriChat/pkg/logging"
+	pb "github.com/sh4shv4t/DistriChat/proto"
+)
+
+// runReaper removes ring members that gossip has reported dead for longer
+// than deadNodeTimeout, until ctx is cancelled. It runs as an elected duty
+// on the metadata leader. Removing a node is what declares it permanently
+// lost: the rebalancer then re-replicates its chats from the survivors.
+func (s *ChatServer) runReaper(ctx context.Context) {
+	interval := s.deadNodeTimeout / 4
+	if interval < 100*time.Millisecond {
+		interval = 100 * time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// When each down member was first seen down by this leader
+	downSince := make(map[string]time.Time)
+	for {
+		select {
+		case <-ticker.C:
+			s.reap(ctx, downSince)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// reap removes the members down for longer than deadNodeTimeout
+func (s *ChatServer) reap(ctx context.Context, downSince map[string]time.Time) {
+	now := time.Now()
+
+	down := make(map[string]bool)
+	for _, m := range s.gossip.Members() {
+		if m.State != gossip.StateDead && m.State != gossip.StateLeft {
+			continue
+		}
+		down[m.ID] = true
+		if _, ok := downSince[m.ID]; !ok {
+			downSince[m.ID] = now
+		}
+	}
+
+	for nodeID, since := range downSince {
+		if !down[nodeID] {
+			delete(downSince, nodeID) // Came back
+			continue
+		}
+		if now.Sub(since) < s.deadNodeTimeout || !s.ring.NodeExists(nodeID) {
+			continue
+		}
+
+		if err := s.metadata.RemoveNode(nodeID); err != nil {
+			s.log.Warn("Failed to remove dead node", logging.NodeID(nodeID), logging.Err(err))
+			continue
+		}
+		delete(downSince, nodeID)
+
+		s.rebalanceMu.Lock()
+		s.deadNodes = append(s.deadNodes, nodeID)
+		s.rebalanceMu.Unlock()
+
+		s.log.Warn("Declared node permanently dead", logging.NodeID(nodeID),
+			"down_for", now.Sub(since).Round(time.Millisecond))
+		s.audit(ctx, audit.Event{
+			Action:  audit.ActionNodeRemoved,
+			Target:  nodeID,
+			Reason:  "dead longer than the dead node timeout",
+			Details: map[string]string{"down_for": now.Sub(since).Round(time.Millisecond).String()},
+		})
+	}
+}
+
+// rebalanceRate is an operator override of the rebalancing caps
+type rebalanceRate struct {
+	bytesPerSecond int64
+	opsPerSecond   float64
+}
+
+// rebalanceStatus reports the rebalancer's progress on this server
+func (s *ChatServer) rebalanceStatus() *pb.RebalanceStatus {
+	s.rebalanceMu.Lock()
+	rebalancer := s.rebalancer
+	status := &pb.RebalanceStatus{
+		Active:    rebalancer != nil,
+		DeadNodes: append([]string(nil), s.deadNodes...),
+	}
+	if rate := s.rebalanceRate; rate != nil {
+		status.BytesPerSecond = rate.bytesPerSecond
+		status.OpsPerSecond = rate.opsPerSecond
+	}
+	s.rebalanceMu.Unlock()
+
+	if rebalancer == nil {
+		return status
+	}
+
+	progress := rebalancer.Progress()
+	stats := rebalancer.Stats()
+	status.Epoch = progress.Epoch
+	status.Replicas = int32(rebalancer.Replicas())
+	status.Transfers = int32(progress.Transfers)
+	status.Completed = int32(progress.Completed)
+	status.Failed = int32(progress.Failed)
+	status.BytesPerSecond = progress.BytesPerSecond
+	status.OpsPerSecond = progress.OpsPerSecond
+	for _, window := range progress.Windows {
+		status.Windows = append(status.Windows, window.String())
+	}
+	if !progress.PausedUntil.IsZero() {
+		status.PausedUntilMs = progress.PausedUntil.UnixMilli()
+	}
+	status.SessionsMoved = stats.Sessions
+	status.BytesMoved = stats.Bytes
+	for _, transfer := range progress.Pending {
+		status.Pending = append(status.Pending, &pb.RebalanceTransfer{
+			From:   transfer.From,
+			To:     transfer.To,
+			Ranges: int32(len(transfer.Ranges)),
+		})
+	}
+	return status
+}
+
+// setRebalanceRate changes the rebalancing caps, now and for rebalancers
+// started later on this server
+func (s *ChatServer) setRebalanceRate(bytesPerSecond int64, opsPerSecond float64) {
+	s.rebalanceMu.Lock()
+	defer s.rebalanceMu.Unlock()
+
+	s.rebalanceRate = &rebalanceRate{bytesPerSecond: bytesPerSecond, opsPerSecond: opsPerSecond}
+	if s.rebalancer != nil {
+		s.rebalancer.SetBytesPerSecond(bytesPerSecond)
+		s.rebalancer.SetOpsPerSecond(opsPerSecond)
+	}
+}
@@ -0,0 +1,708 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/internal/requestid"
+	"github.com/sh4shv4t/DistriChat/pkg/cache"
+	"github.com/sh4shv4t/DistriChat/pkg/chaos"
+	"github.com/sh4shv4t/DistriChat/pkg/chaterr"
+	"github.com/sh4shv4t/DistriChat/pkg/clock"
+	"github.com/sh4shv4t/DistriChat/pkg/logging"
+	"github.com/sh4shv4t/DistriChat/pkg/ring"
+	"github.com/sh4shv4t/DistriChat/pkg/tracing"
+	pb "github.com/sh4shv4t/DistriChat/proto"
+	"go.opentelemetry.io/otel/attribute"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// ReplicationConfig sets how many copies of each chat are kept and how many
+// of them must answer a write (W) or a read (R). Choosing R + W > N makes
+// every read overlap the latest acknowledged write.
+type ReplicationConfig struct {
+	N int // Replicas per chat, including the coordinating server (default: 1)
+	W int // Acknowledgements required before a write succeeds (default: N/2+1)
+	R int // Replicas consulted on a read (default: N/2+1)
+
+	Timeout time.Duration // Per-replica RPC timeout (default: 2s)
+}
+
+// withDefaults fills unset values and clamps W and R to N
+func (c ReplicationConfig) withDefaults() ReplicationConfig {
+	if c.N <= 0 {
+		c.N = 1
+	}
+	if c.W <= 0 {
+		c.W = c.N/2 + 1
+	}
+	if c.R <= 0 {
+		c.R = c.N/2 + 1
+	}
+	if c.W > c.N {
+		c.W = c.N
+	}
+	if c.R > c.N {
+		c.R = c.N
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = 2 * time.Second
+	}
+	return c
+}
+
+// Replication returns the server's effective replication settings
+func (s *ChatServer) Replication() ReplicationConfig {
+	return s.replication
+}
+
+// required returns how many replicas a request at the given consistency
+// level must reach, falling back to configured (W or R) for the default level
+func (s *ChatServer) required(level pb.ConsistencyLevel, configured int) int {
+	switch level {
+	case pb.ConsistencyLevel_CONSISTENCY_ONE:
+		return 1
+	case pb.ConsistencyLevel_CONSISTENCY_QUORUM:
+		return s.replication.N/2 + 1
+	case pb.ConsistencyLevel_CONSISTENCY_ALL:
+		return s.replication.N
+	default:
+		return configured
+	}
+}
+
+// replicaPeers returns the other servers in this server's region holding
+// copies of a chat according to the server's ring view. If this server isn't
+// one of the chat's N replicas (e.g. it took the write during failover), it
+// keeps the local copy and the first N-1 regional replicas hold the rest.
+func (s *ChatServer) replicaPeers(chatID string) []ring.NodeInfo {
+	if s.replication.N <= 1 {
+		return nil
+	}
+
+	nodes := s.placement().GetNodesInRegion(chatID, s.replication.N, s.region)
+	peers := make([]ring.NodeInfo, 0, len(nodes))
+	for _, node := range nodes {
+		if node.NodeID != s.serverID {
+			peers = append(peers, node)
+		}
+	}
+	if len(peers) > s.replication.N-1 {
+		peers = peers[:s.replication.N-1]
+	}
+	return peers
+}
+
+// nextMessageID returns a cluster-unique ID for a message accepted here
+func (s *ChatServer) nextMessageID() string {
+	return fmt.Sprintf("%s-%x-%d", s.serverID, s.startTime.UnixNano(), s.messageSeq.Add(1))
+}
+
+// replicate sends a stored message to the chat's other replicas and waits
+// for needed of them to acknowledge, or for ctx to end. Replicas beyond
+// that are still written, in the background. Returns the number of
+// acknowledgements seen.
+func (s *ChatServer) replicate(ctx context.Context, chatID string, msg cache.Message, needed int) int {
+	peers := s.replicaPeers(chatID)
+	if len(peers) == 0 {
+		return 0
+	}
+
+	ctx, span := tracer.Start(ctx, "server.replicate")
+	defer span.End()
+
+	req := &pb.ReplicateRequest{
+		Message:       storedFromMessage(chatID, msg),
+		CoordinatorId: s.serverID,
+	}
+
+	// Buffered so late replicas don't block after the quorum is reached
+	results := make(chan bool, len(peers))
+	for _, peer := range peers {
+		go func(peer ring.NodeInfo) {
+			results <- s.replicateTo(ctx, peer, req)
+		}(peer)
+	}
+
+	acks := 0
+wait:
+	for i := 0; i < len(peers) && acks < needed; i++ {
+		select {
+		case ok := <-results:
+			if ok {
+				acks++
+			}
+		case <-ctx.Done():
+			break wait
+		}
+	}
+	span.SetAttributes(
+		attribute.Int("replication.peers", len(peers)),
+		attribute.Int("replication.needed", needed),
+		attribute.Int("replication.acks", acks),
+	)
+	return acks
+}
+
+// remoteOwners returns the chat's owner in every region but this server's
+func (s *ChatServer) remoteOwners(chatID string) []ring.NodeInfo {
+	var owners []ring.NodeInfo
+	placement := s.placement()
+	for _, region := range placement.Regions() {
+		if region == s.region {
+			continue
+		}
+		if nodes := placement.GetNodesInRegion(chatID, 1, region); len(nodes) > 0 {
+			owners = append(owners, nodes[0])
+		}
+	}
+	return owners
+}
+
+// replicateCrossRegion sends a stored message to the chat's owner in every
+// other region without waiting: cross-region links are too slow to sit in
+// the write path. Each owner fans the message out to its region's replicas.
+func (s *ChatServer) replicateCrossRegion(ctx context.Context, chatID string, msg cache.Message) {
+	owners := s.remoteOwners(chatID)
+	if len(owners) == 0 {
+		return
+	}
+
+	req := &pb.ReplicateRequest{
+		Message:       storedFromMessage(chatID, msg),
+		CoordinatorId: s.serverID,
+		CrossRegion:   true,
+	}
+	for _, owner := range owners {
+		go s.replicateTo(ctx, owner, req)
+	}
+}
+
+// replicateTo writes one message to one replica. The write continues the
+// trace in ctx but not its cancellation, since it may outlive the request:
+// the message is already stored here, and replicas left without it would
+// diverge. It ends with the server's lifetime instead.
+func (s *ChatServer) replicateTo(ctx context.Context, peer ring.NodeInfo, req *pb.ReplicateRequest) bool {
+	client, err := s.peerClient(peer.Address)
+	if err != nil {
+		s.log.WarnContext(ctx, "Replication failed", logging.NodeID(peer.NodeID), logging.Err(err))
+		return false
+	}
+
+	ctx, cancel := s.detach(ctx)
+	defer cancel()
+	ctx, cancelTimeout := context.WithTimeout(ctx, s.replication.Timeout)
+	defer cancelTimeout()
+
+	resp, err := client.Replicate(ctx, req)
+	if err != nil {
+		s.log.WarnContext(ctx, "Replication failed", logging.NodeID(peer.NodeID), logging.Err(err))
+		return false
+	}
+	if !resp.Success {
+		s.log.WarnContext(ctx, "Replica rejected message", logging.NodeID(peer.NodeID),
+			"code", resp.ErrorCode.String(), "details", resp.ErrorDetails)
+		return false
+	}
+	return true
+}
+
+// Replicate stores a message sent by the server that coordinated the write
+func (s *ChatServer) Replicate(ctx context.Context, req *pb.ReplicateRequest) (*pb.ReplicateResponse, error) {
+	if err := s.checkAccepting(); err != nil {
+		return s.replicateError(pb.ErrorCode_ERROR_DRAINING, err.Error()), nil
+	}
+
+	chatID, msg, err := messageFromStored(req.GetMessage())
+	if err != nil {
+		return s.replicateError(pb.ErrorCode_ERROR_VALIDATION_FAILED, err.Error()), nil
+	}
+
+	// Keep later local writes causally after everything replicated here
+	if !msg.HLC.IsZero() {
+		s.clock.Update(msg.HLC)
+	}
+
+	// A message this replica purged already, or would on its next pass,
+	// isn't stored again
+	if s.expired(chatID, msg) {
+		return &pb.ReplicateResponse{Success: true, ServerId: s.serverID}, nil
+	}
+
+	added, err := s.cache.ApplyMessageContext(ctx, chatID, msg)
+	if err != nil {
+		return s.replicateError(pb.ErrorCode_ERROR_VALIDATION_FAILED, err.Error()), nil
+	}
+
+	if added {
+		s.indexMessage(chatID, msg)
+		// Only the coordinator's deliveries go through the outbox; this
+		// replica's subscribers are served directly
+		s.fanOut(chatID, msg)
+	}
+	// A copy held already is recorded again, in case the first attempt
+	// failed to; a replica that can't record it doesn't acknowledge it
+	if err := s.rememberMessage(ctx, chatID, msg); err != nil {
+		return s.replicateError(pb.ErrorCode_ERROR_INTERNAL, err.Error()), nil
+	}
+
+	// A write from another region lands on our regional owner only; pass it
+	// on to the rest of this region's replicas in the background
+	if req.CrossRegion && added {
+		s.replicate(ctx, chatID, msg, 0)
+	}
+
+	return &pb.ReplicateResponse{Success: true, ServerId: s.serverID}, nil
+}
+
+// replicateError builds a failed ReplicateResponse
+func (s *ChatServer) replicateError(code pb.ErrorCode, details string) *pb.ReplicateResponse {
+	return &pb.ReplicateResponse{
+		ServerId:     s.serverID,
+		ErrorCode:    code,
+		ErrorDetails: details,
+	}
+}
+
+// GetHistory returns a chat's messages merged from R of its replicas, or as
+// many as the request's consistency level asks for. Replicas found missing
+// messages are repaired in the background. With AllowStale, a replica
+// answers from its own copy and reports how far it lags.
+func (s *ChatServer) GetHistory(ctx context.Context, req *pb.HistoryRequest) (resp *pb.HistoryResponse, err error) {
+	if !req.Local {
+		s.inFlight.Add(1)
+		defer func(start time.Time) {
+			s.inFlight.Add(-1)
+			s.observeRequest("GetHistory", req.Namespace, resp.GetErrorCode(), start)
+		}(time.Now())
+	}
+	defer func() {
+		if resp != nil {
+			resp.RequestId = requestid.FromContext(ctx)
+		}
+	}()
+	ctx = logging.With(ctx, logging.ChatID(req.ChatId))
+
+	if req.ChatId == "" {
+		return s.historyError(pb.ErrorCode_ERROR_VALIDATION_FAILED, "chat_id is required"), nil
+	}
+	if err := s.checkNamespace(req.Namespace); err != nil {
+		return s.historyError(pb.ErrorCode_ERROR_NOT_OWNER, err.Error()), nil
+	}
+	ctx, principal, err := s.authenticate(ctx, "GetHistory", req.Namespace)
+	if err != nil {
+		return s.historyError(chaterr.Code(err, pb.ErrorCode_ERROR_INTERNAL), err.Error()), nil
+	}
+	if err := s.authorizeRead(ctx, principal, req); err != nil {
+		return s.historyError(chaterr.Code(err, pb.ErrorCode_ERROR_INTERNAL), err.Error()), nil
+	}
+	return s.readHistory(ctx, req), nil
+}
+
+// readHistory reads a chat's history for a request already authorized:
+// this server's copy, or a quorum of replicas' merged
+func (s *ChatServer) readHistory(ctx context.Context, req *pb.HistoryRequest) *pb.HistoryResponse {
+	local := replicaCopy{
+		local:    true,
+		messages: storedFromMessages(req.ChatId, s.cache.HistoryContext(ctx, req.ChatId, int(req.Limit))),
+		version:  s.cache.VersionContext(ctx, req.ChatId),
+	}
+	if req.Local {
+		return &pb.HistoryResponse{
+			Success:      true,
+			ServerId:     s.serverID,
+			Messages:     local.messages,
+			ReplicasRead: 1,
+			Version:      local.version,
+		}
+	}
+
+	// A server outside the chat's replica set may hold nothing for it, so
+	// it falls back to a quorum read
+	if req.AllowStale && s.holdsChat(req.ChatId) {
+		served, known := s.cache.WatermarksContext(ctx, req.ChatId)
+		s.staleReads.Add(1)
+		s.metrics.staleReads.Inc()
+		return &pb.HistoryResponse{
+			Success:      true,
+			ServerId:     s.serverID,
+			Messages:     local.messages,
+			ReplicasRead: 1,
+			Version:      local.version,
+			Stale:        true,
+			ServedSeq:    served,
+			KnownSeq:     known,
+		}
+	}
+
+	r := s.required(req.Consistency, s.replication.R)
+	copies := []replicaCopy{local}
+	copies = append(copies, s.readReplicas(ctx, req, r-1)...)
+	if len(copies) < r {
+		return s.historyError(pb.ErrorCode_ERROR_QUORUM_FAILED,
+			fmt.Sprintf("read %d of %d required replicas", len(copies), r))
+	}
+
+	merged := mergeHistories(copies, int(req.Limit))
+	version := make(clock.VersionVector)
+	for _, replica := range copies {
+		if replica.version.Compare(version) == clock.Concurrent {
+			s.log.InfoContext(ctx, "Merging concurrent histories",
+				"version", replica.version.String(), "merged", version.String())
+		}
+		version.Merge(replica.version)
+	}
+	if len(copies) > 1 {
+		go s.readRepair(ctx, req.ChatId, copies, merged, version, int(req.Limit))
+	}
+
+	return &pb.HistoryResponse{
+		Success:      true,
+		ServerId:     s.serverID,
+		Messages:     merged,
+		ReplicasRead: int32(len(copies)),
+		Version:      version,
+	}
+}
+
+// replicaCopy is one replica's answer to a history read
+type replicaCopy struct {
+	node     ring.NodeInfo // Replica that answered (unset for the local copy)
+	local    bool
+	messages []*pb.StoredMessage
+	version  clock.VersionVector
+}
+
+// readReplicas reads the local copies of up to needed other replicas
+func (s *ChatServer) readReplicas(ctx context.Context, req *pb.HistoryRequest, needed int) []replicaCopy {
+	peers := s.replicaPeers(req.ChatId)
+	if needed <= 0 || len(peers) == 0 {
+		return nil
+	}
+
+	localReq := &pb.HistoryRequest{ChatId: req.ChatId, Limit: req.Limit, Local: true, Namespace: req.Namespace}
+	results := make(chan replicaCopy, len(peers))
+	for _, peer := range peers {
+		go func(peer ring.NodeInfo) {
+			messages, version := s.readFrom(ctx, peer, localReq)
+			results <- replicaCopy{node: peer, messages: messages, version: version}
+		}(peer)
+	}
+
+	var copies []replicaCopy
+	for i := 0; i < len(peers) && len(copies) < needed; i++ {
+		if result := <-results; result.messages != nil {
+			copies = append(copies, result)
+		}
+	}
+	return copies
+}
+
+// readFrom fetches one replica's copy and version vector, or nil messages if
+// it couldn't be read before the replication timeout or ctx's end
+func (s *ChatServer) readFrom(ctx context.Context, peer ring.NodeInfo, req *pb.HistoryRequest) ([]*pb.StoredMessage, clock.VersionVector) {
+	client, err := s.peerClient(peer.Address)
+	if err != nil {
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.replication.Timeout)
+	defer cancel()
+
+	resp, err := client.GetHistory(ctx, req)
+	if err != nil {
+		s.log.WarnContext(ctx, "History read failed", logging.NodeID(peer.NodeID), logging.Err(err))
+		return nil, nil
+	}
+	if !resp.Success {
+		s.log.WarnContext(ctx, "Replica rejected history read", logging.NodeID(peer.NodeID),
+			"code", resp.ErrorCode.String(), "details", resp.ErrorDetails)
+		return nil, nil
+	}
+	if resp.Messages == nil {
+		return []*pb.StoredMessage{}, resp.Version
+	}
+	return resp.Messages, resp.Version
+}
+
+// detach returns a context with ctx's values (trace, request ID, log
+// attributes) but not its cancellation or deadline, for work that outlives
+// the request. It ends with the server's lifetime instead.
+func (s *ChatServer) detach(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(s.lifetime, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// readRepair pushes the merged messages a replica's copy lacks back to it.
+// Replicas whose version vector already covers the merged one are skipped.
+func (s *ChatServer) readRepair(ctx context.Context, chatID string, copies []replicaCopy, merged []*pb.StoredMessage, version clock.VersionVector, limit int) {
+	// Repair runs after the read has been answered
+	ctx, cancel := s.detach(ctx)
+	defer cancel()
+
+	for _, replica := range copies {
+		if order := replica.version.Compare(version); order == clock.Equal || order == clock.After {
+			continue
+		}
+		missing := missingFrom(replica.messages, merged, limit)
+		if len(missing) == 0 {
+			continue
+		}
+
+		repaired := 0
+		for _, msg := range missing {
+			if replica.local {
+				if _, stored, err := messageFromStored(msg); err == nil && !s.expired(chatID, stored) {
+					s.clock.Update(stored.HLC)
+					if added, _ := s.cache.ApplyMessageContext(ctx, chatID, stored); added {
+						s.indexMessage(chatID, stored)
+						repaired++
+					}
+				}
+				continue
+			}
+			if s.replicateTo(ctx, replica.node, &pb.ReplicateRequest{Message: msg, CoordinatorId: s.serverID}) {
+				repaired++
+			}
+		}
+
+		target := s.serverID
+		if !replica.local {
+			target = replica.node.NodeID
+		}
+		s.log.Info("Read repair pushed missing messages", logging.ChatID(chatID), logging.NodeID(target),
+			"repaired", repaired, "missing", len(missing))
+	}
+}
+
+// missingFrom returns the merged messages absent from a replica's copy,
+// including ones it holds a different copy of (a duplicate it hasn't
+// collapsed yet). A limited read only shows a replica's most recent
+// messages, so older messages are only counted as missing if they fall
+// inside that window.
+func missingFrom(have, merged []*pb.StoredMessage, limit int) []*pb.StoredMessage {
+	present := make(map[string]*pb.StoredMessage, len(have))
+	for _, msg := range have {
+		present[msg.MessageId] = msg
+	}
+	windowed := limit > 0 && len(have) >= limit && len(have) > 0
+
+	var missing []*pb.StoredMessage
+	for _, msg := range merged {
+		if held, ok := present[msg.MessageId]; ok && sameCopy(held, msg) {
+			continue
+		}
+		if windowed && storedLess(msg, have[0]) {
+			continue
+		}
+		missing = append(missing, msg)
+	}
+	return missing
+}
+
+// historyError builds a failed HistoryResponse
+func (s *ChatServer) historyError(code pb.ErrorCode, details string) *pb.HistoryResponse {
+	return &pb.HistoryResponse{
+		ServerId:     s.serverID,
+		ErrorCode:    code,
+		ErrorDetails: details,
+	}
+}
+
+// mergeHistories unions replica copies by message ID and returns the most
+// recent limit messages in sequence order (all of them if limit <= 0).
+// Where replicas hold different copies of one ID, the first in storage
+// order wins, as it does in the cache.
+func mergeHistories(copies []replicaCopy, limit int) []*pb.StoredMessage {
+	seen := make(map[string]int)
+	var merged []*pb.StoredMessage
+	for _, replica := range copies {
+		for _, msg := range replica.messages {
+			if i, ok := seen[msg.MessageId]; ok {
+				if storedLess(msg, merged[i]) {
+					merged[i] = msg
+				}
+				continue
+			}
+			seen[msg.MessageId] = len(merged)
+			merged = append(merged, msg)
+		}
+	}
+
+	sort.Slice(merged, func(i, j int) bool {
+		return storedLess(merged[i], merged[j])
+	})
+
+	if limit > 0 && len(merged) > limit {
+		merged = merged[len(merged)-limit:]
+	}
+	return merged
+}
+
+// sameCopy reports whether two wire messages are the same write, rather
+// than two acceptances of one message ID
+func sameCopy(a, b *pb.StoredMessage) bool {
+	return a.Origin == b.Origin && a.Counter == b.Counter
+}
+
+// storedLess orders wire messages the same way replicas store them
+func storedLess(a, b *pb.StoredMessage) bool {
+	return cache.MessageLess(
+		cache.Message{ID: a.MessageId, Seq: a.Seq, HLC: timestampFromProto(a.Hlc)},
+		cache.Message{ID: b.MessageId, Seq: b.Seq, HLC: timestampFromProto(b.Hlc)},
+	)
+}
+
+// peerClient returns a cached ChatService client for another server
+func (s *ChatServer) peerClient(address string) (pb.ChatServiceClient, error) {
+	conn, err := s.peerConn(address)
+	if err != nil {
+		return nil, err
+	}
+	return pb.NewChatServiceClient(conn), nil
+}
+
+// peerConn returns a cached (lazily dialed) connection to another server
+func (s *ChatServer) peerConn(address string) (*grpc.ClientConn, error) {
+	s.peerMu.Lock()
+	defer s.peerMu.Unlock()
+
+	if conn, ok := s.peerConns[address]; ok {
+		return conn, nil
+	}
+
+	opts := append([]grpc.DialOption{
+		tracing.DialOption(),
+		requestid.DialOption(),
+	}, s.peerDialOptions()...)
+	conn, err := grpc.Dial(address, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", address, err)
+	}
+	s.peerConns[address] = conn
+	return conn, nil
+}
+
+// peerDialOptions are the options every connection to another server adds:
+// its credentials, the configured dialer and the chaos tag
+func (s *ChatServer) peerDialOptions() []grpc.DialOption {
+	opts := append(s.peerAuthOptions(), chaos.DialOptions(s.chaos, s.serverID)...)
+	if s.dialer != nil {
+		opts = append(opts, grpc.WithContextDialer(s.dialer))
+	}
+	return opts
+}
+
+// peerCredentials secures connections to other servers' chat ports: mutual
+// TLS with the server's certificate, if it has one
+func (s *ChatServer) peerCredentials() grpc.DialOption {
+	if s.tls != nil {
+		return grpc.WithTransportCredentials(s.tls.ClientCredentials())
+	}
+	return grpc.WithTransportCredentials(insecure.NewCredentials())
+}
+
+// peerAuthOptions identify the server to the peers it dials, as
+// authorizePeer expects: its certificate, and the admin token if set
+func (s *ChatServer) peerAuthOptions() []grpc.DialOption {
+	opts := []grpc.DialOption{s.peerCredentials()}
+	if s.adminToken != "" {
+		opts = append(opts, grpc.WithPerRPCCredentials(adminTokenCredentials(s.adminToken)))
+	}
+	return opts
+}
+
+// closePeers closes all cached peer connections
+func (s *ChatServer) closePeers() {
+	s.peerMu.Lock()
+	defer s.peerMu.Unlock()
+
+	for address, conn := range s.peerConns {
+		conn.Close()
+		delete(s.peerConns, address)
+	}
+}
+
+// storedFromMessages converts cached messages to their wire form
+func storedFromMessages(chatID string, messages []cache.Message) []*pb.StoredMessage {
+	out := make([]*pb.StoredMessage, 0, len(messages))
+	for _, msg := range messages {
+		out = append(out, storedFromMessage(chatID, msg))
+	}
+	return out
+}
+
+// storedFromMessage converts a cached message to its wire form
+func storedFromMessage(chatID string, msg cache.Message) *pb.StoredMessage {
+	return &pb.StoredMessage{
+		MessageId: msg.ID,
+		Seq:       msg.Seq,
+		Request:   requestFromMessage(chatID, msg),
+		Hlc:       &pb.HybridTimestamp{WallTime: msg.HLC.WallTime, Logical: msg.HLC.Logical},
+		Origin:    msg.Origin,
+		Counter:   msg.Counter,
+	}
+}
+
+// timestampFromProto converts a wire timestamp (nil reads as zero)
+func timestampFromProto(ts *pb.HybridTimestamp) clock.Timestamp {
+	return clock.Timestamp{WallTime: ts.GetWallTime(), Logical: ts.GetLogical()}
+}
+
+// messageFromStored converts a wire message back to a cached message
+func messageFromStored(stored *pb.StoredMessage) (string, cache.Message, error) {
+	if stored == nil || stored.Request == nil {
+		return "", cache.Message{}, fmt.Errorf("message is empty")
+	}
+	if stored.Request.ChatId == "" {
+		return "", cache.Message{}, fmt.Errorf("chat_id is required")
+	}
+
+	msg, err := messageFromRequest(stored.Request)
+	if err != nil {
+		return "", msg, err
+	}
+	msg.ID = stored.MessageId
+	msg.Seq = stored.Seq
+	msg.HLC = timestampFromProto(stored.Hlc)
+	msg.Origin = stored.Origin
+	msg.Counter = stored.Counter
+	return stored.Request.ChatId, msg, nil
+}
+
+// requestFromMessage is the inverse of messageFromRequest
+func requestFromMessage(chatID string, msg cache.Message) *pb.ChatRequest {
+	req := &pb.ChatRequest{
+		ChatId:        chatID,
+		SenderId:      msg.SenderID,
+		Timestamp:     msg.Timestamp.Unix(),
+		FederatedFrom: msg.Federated,
+		Annotations:   msg.Annotations,
+	}
+
+	switch {
+	case msg.Type == cache.ContentAttachment && msg.Attachment != nil:
+		req.Content = &pb.ChatRequest_Attachment{Attachment: &pb.Attachment{
+			Url:       msg.Attachment.URL,
+			MimeType:  msg.Attachment.MimeType,
+			SizeBytes: msg.Attachment.SizeBytes,
+			Filename:  msg.Attachment.Filename,
+		}}
+	case msg.Type == cache.ContentSystemEvent && msg.Event != nil:
+		req.Content = &pb.ChatRequest_SystemEvent{SystemEvent: &pb.SystemEvent{
+			Type:    pb.SystemEventType(pb.SystemEventType_value[msg.Event.Type]),
+			ActorId: msg.Event.ActorID,
+			Details: msg.Event.Details,
+		}}
+	default:
+		req.Content = &pb.ChatRequest_Text{Text: msg.Content}
+	}
+	return req
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"fmt"
+	"testing"
+
+	pb "github.com/sh4shv4t/DistriChat/proto"
+)
+
+// storedCopy builds the copy of message seq accepted by origin
+func storedCopy(seq uint64, origin string) *pb.StoredMessage {
+	return &pb.StoredMessage{
+		MessageId: fmt.Sprintf("m-%d", seq),
+		Seq:       seq,
+		Hlc:       &pb.HybridTimestamp{WallTime: int64(seq)},
+		Origin:    origin,
+		Counter:   seq,
+	}
+}
+
+// seqs lists the sequence numbers of messages
+func seqs(messages []*pb.StoredMessage) []uint64 {
+	out := make([]uint64, 0, len(messages))
+	for _, msg := range messages {
+		out = append(out, msg.Seq)
+	}
+	return out
+}
+
+func TestMissingFrom(t *testing.T) {
+	var merged []*pb.StoredMessage
+	for seq := uint64(1); seq <= 5; seq++ {
+		merged = append(merged, storedCopy(seq, "server-a"))
+	}
+
+	tests := []struct {
+		name  string
+		have  []*pb.StoredMessage
+		limit int
+		want  []uint64
+	}{
+		{"up to date", merged, 0, nil},
+		{"unwindowed gaps", []*pb.StoredMessage{merged[0], merged[2]}, 0, []uint64{2, 4, 5}},
+		{"empty replica", nil, 3, []uint64{1, 2, 3, 4, 5}},
+		// A different copy of an ID, not collapsed yet, is replaced
+		{"different copy", []*pb.StoredMessage{merged[0], storedCopy(2, "server-b"), merged[2], merged[3], merged[4]}, 0, []uint64{2}},
+		// A full window hides what the replica holds before it, so only
+		// gaps inside it count
+		{"windowed", []*pb.StoredMessage{merged[2], merged[4]}, 2, []uint64{4}},
+		// A replica answering with less than the limit showed everything
+		{"window not full", []*pb.StoredMessage{merged[2], merged[4]}, 3, []uint64{1, 2, 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := seqs(missingFrom(tt.have, merged, tt.limit)); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("Expected %v missing, got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/pkg/cache"
+	"github.com/sh4shv4t/DistriChat/pkg/logging"
+	"github.com/sh4shv4t/DistriChat/pkg/msglog"
+	pb "github.com/sh4shv4t/DistriChat/proto"
+)
+
+// RetentionPolicy says how long a chat stays hot and how long its messages
+// are kept at all, e.g. 90 days hot and a year in the archive:
+//
+//	RetentionPolicy{Hot: 90 * 24 * time.Hour, Retain: 455 * 24 * time.Hour}
+type RetentionPolicy struct {
+	// Hot is how long a chat stays cached after its last access before it
+	// is archived (0: ArchiveAfter). It has no effect without an Archive.
+	Hot time.Duration
+
+	// Retain is how long a message is kept after it was sent, cached or
+	// archived, before it is purged (0: forever). System events are kept,
+	// as a chat's members are derived from them.
+	Retain time.Duration
+}
+
+// RetentionConfig assigns retention policies to chats. The most specific
+// policy set applies as a whole: the chat's own, else its tenant's (by
+// namespace), else Default.
+type RetentionConfig struct {
+	Default RetentionPolicy
+	Tenants map[string]RetentionPolicy // By namespace
+	Chats   map[string]RetentionPolicy // By chat ID
+
+	// How often messages past retention are purged (default: 1h)
+	Interval time.Duration
+}
+
+// policy returns the retention policy of a chat in namespace
+func (c *RetentionConfig) policy(namespace, chatID string) RetentionPolicy {
+	if p, ok := c.Chats[chatID]; ok {
+		return p
+	}
+	if p, ok := c.Tenants[namespace]; ok {
+		return p
+	}
+	return c.Default
+}
+
+// archivedLister is implemented by cold tiers that can list the chats they
+// hold (such as archive.Archive)
+type archivedLister interface {
+	Archived() []string
+}
+
+// hotFor returns how long a chat may stay idle in the cache before it is
+// archived
+func (s *ChatServer) hotFor(chatID string) time.Duration {
+	if s.retention != nil {
+		if hot := s.retention.policy(s.namespace, chatID).Hot; hot > 0 {
+			return hot
+		}
+	}
+	return s.archiveAfter
+}
+
+// retentionCutoff returns the send time before which a chat's messages
+// are past retention, or false if they are kept forever
+func (s *ChatServer) retentionCutoff(chatID string) (time.Time, bool) {
+	if s.retention == nil {
+		return time.Time{}, false
+	}
+	retain := s.retention.policy(s.namespace, chatID).Retain
+	if retain <= 0 {
+		return time.Time{}, false
+	}
+	return s.wall.Now().Add(-retain), true
+}
+
+// expired reports whether a message is past its chat's retention or its
+// sender was purged, so a peer, a migration or the message log mustn't
+// bring it back once purged
+func (s *ChatServer) expired(chatID string, msg cache.Message) bool {
+	if msg.Type == cache.ContentSystemEvent {
+		return false
+	}
+	if s.purgedSender(msg) {
+		return true
+	}
+	cutoff, ok := s.retentionCutoff(chatID)
+	return ok && msg.Timestamp.Before(cutoff)
+}
+
+// retentionLoop purges messages past retention every retention interval
+// until shutdown
+func (s *ChatServer) retentionLoop() {
+	ticker := time.NewTicker(s.retention.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if purged := s.EnforceRetention(s.lifetime); purged > 0 {
+				s.log.Info("Purged messages past retention", "messages", purged)
+			}
+		case <-s.shutdownCh:
+			return
+		}
+	}
+}
+
+// EnforceRetention purges the messages past their chat's retention from
+// the cache and archive of every chat this server holds, its search index
+// and, if the message log supports it, the log. Each replica enforces the
+// same policies, so their copies converge. Returns the number of messages
+// purged from chats; failures are logged and retried on the next pass.
+func (s *ChatServer) EnforceRetention(ctx context.Context) int {
+	if s.retention == nil {
+		return 0
+	}
+
+	chats := make(map[string]bool)
+	for _, session := range s.cache.Resident() {
+		chats[session.ChatID] = true
+	}
+	if lister, ok := s.archive.(archivedLister); ok {
+		for _, chatID := range lister.Archived() {
+			if s.holdsChat(chatID) {
+				chats[chatID] = true
+			}
+		}
+	}
+
+	purged := 0
+	for chatID := range chats {
+		if ctx.Err() != nil {
+			return purged
+		}
+		cutoff, ok := s.retentionCutoff(chatID)
+		if !ok {
+			continue
+		}
+		removed, err := s.cache.PurgeBefore(ctx, chatID, cutoff)
+		if err != nil {
+			s.log.Warn("Failed to purge messages past retention", logging.ChatID(chatID), logging.Err(err))
+		}
+		if s.search != nil {
+			s.search.DeleteBefore(chatID, cutoff)
+		}
+		if len(removed) > 0 {
+			purged += len(removed)
+			s.messagesPurged.Add(int64(len(removed)))
+			s.metrics.retentionPurged.Add(float64(len(removed)))
+			s.log.Debug("Purged messages past retention", logging.ChatID(chatID), "messages", len(removed))
+		}
+	}
+
+	if compactor, ok := s.messageLog.(msglog.Compactor); ok {
+		n, err := compactor.Compact(ctx, func(stored *pb.StoredMessage) bool {
+			req := stored.GetRequest()
+			if req.GetNamespace() != s.namespace || req.GetSystemEvent() != nil {
+				return false
+			}
+			cutoff, ok := s.retentionCutoff(req.GetChatId())
+			return ok && time.Unix(req.GetTimestamp(), 0).Before(cutoff)
+		})
+		if err != nil {
+			s.log.Warn("Failed to compact the message log", logging.Err(err))
+		} else if n > 0 {
+			s.log.Info("Compacted the message log", "messages", n)
+		}
+	}
+	return purged
+}
@@ -0,0 +1,314 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/internal/topology"
+	"github.com/sh4shv4t/DistriChat/pkg/chaterr"
+	"github.com/sh4shv4t/DistriChat/pkg/election"
+	"github.com/sh4shv4t/DistriChat/pkg/events"
+	"github.com/sh4shv4t/DistriChat/pkg/gossip"
+	"github.com/sh4shv4t/DistriChat/pkg/logging"
+	"github.com/sh4shv4t/DistriChat/pkg/metadata"
+	"github.com/sh4shv4t/DistriChat/pkg/ring"
+	pb "github.com/sh4shv4t/DistriChat/proto"
+	"google.golang.org/grpc"
+)
+
+// SetRingState installs a newer ring view on the server. Views with an epoch
+// not newer than the current one are ignored. Returns whether it was applied.
+func (s *ChatServer) SetRingState(state ring.RingState) bool {
+	// Held across install and publish so watchers see views in epoch order
+	s.topologyMu.Lock()
+	defer s.topologyMu.Unlock()
+
+	applied := s.ring.Replace(state)
+	if applied {
+		s.log.Info("Ring view updated", logging.Epoch(state.Epoch))
+		s.publishTopology(state)
+		s.publishRingChanged(state)
+		if s.gossip != nil {
+			s.gossip.SetRingView(gossip.RingView{Epoch: state.Epoch, Digest: state.Digest()})
+		}
+	}
+	return applied
+}
+
+// comparePeerRing reacts to a gossip message whose sender holds a
+// different ring view: a newer one is pulled from the sender, and one with
+// our epoch but different content is counted and logged as a conflict. A
+// sender with an older view pulls ours when it sees it.
+func (s *ChatServer) comparePeerRing(from gossip.Member, view gossip.RingView) {
+	local := s.gossip.RingView()
+	switch {
+	case view.Epoch > local.Epoch:
+		s.ringPullMu.Lock()
+		if view.Epoch <= s.ringPulling {
+			s.ringPullMu.Unlock()
+			return // Already being fetched
+		}
+		s.ringPulling = view.Epoch
+		s.ringPullMu.Unlock()
+
+		go s.pullRing(from)
+
+	case view.Epoch == local.Epoch && view.Digest != local.Digest:
+		s.ringConflicts.Add(1)
+		s.metrics.ringConflicts.Inc()
+		if s.lastConflict.Swap(view.Epoch) != view.Epoch {
+			s.log.Warn("Peer ring view conflicts with ours", logging.NodeID(from.ID), logging.Epoch(view.Epoch),
+				"digest", view.Digest, "local_digest", local.Digest)
+		}
+	}
+}
+
+// pullRing fetches the ring view held by a gossip peer and installs it if
+// it is newer than ours
+func (s *ChatServer) pullRing(from gossip.Member) {
+	defer func() {
+		s.ringPullMu.Lock()
+		s.ringPulling = 0
+		s.ringPullMu.Unlock()
+	}()
+
+	client, err := s.peerClient(from.Address)
+	if err != nil {
+		s.log.Warn("Ring pull failed", logging.NodeID(from.ID), logging.Err(err))
+		return
+	}
+
+	local := s.ring.State()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	resp, err := client.GetRingState(ctx, &pb.RingStateRequest{
+		KnownEpoch:  local.Epoch,
+		KnownDigest: local.Digest(),
+	})
+	if err != nil {
+		s.log.Warn("Ring pull failed", logging.NodeID(from.ID), logging.Err(err))
+		return
+	}
+	if resp.InSync {
+		return
+	}
+	if s.SetRingState(resp.State.ToRing()) {
+		s.log.Info("Adopted gossiped ring", logging.Epoch(resp.State.Epoch), logging.NodeID(from.ID))
+	}
+}
+
+// WatchTopology streams the server's ring view whenever it changes
+func (s *ChatServer) WatchTopology(req *pb.WatchTopologyRequest, stream pb.ChatService_WatchTopologyServer) error {
+	id, updates := s.addTopologyWatcher()
+	defer s.removeTopologyWatcher(id)
+
+	if state := s.ring.State(); state.Epoch > req.KnownEpoch {
+		if err := stream.Send(pb.NewRingState(state)); err != nil {
+			return err
+		}
+	}
+
+	for {
+		select {
+		case state := <-updates:
+			if err := stream.Send(pb.NewRingState(state)); err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		case <-s.shutdownCh:
+			return nil
+		}
+	}
+}
+
+// addTopologyWatcher registers a topology watcher
+func (s *ChatServer) addTopologyWatcher() (int, <-chan ring.RingState) {
+	s.topologyMu.Lock()
+	defer s.topologyMu.Unlock()
+
+	id := s.nextWatcher
+	s.nextWatcher++
+	ch := make(chan ring.RingState, 1)
+	s.topologyWatchers[id] = ch
+	return id, ch
+}
+
+// removeTopologyWatcher unregisters a topology watcher
+func (s *ChatServer) removeTopologyWatcher(id int) {
+	s.topologyMu.Lock()
+	defer s.topologyMu.Unlock()
+	delete(s.topologyWatchers, id)
+}
+
+// publishTopology delivers a ring state to every watcher (must be called with
+// topologyMu held). A pending older state is replaced by the newer one.
+func (s *ChatServer) publishTopology(state ring.RingState) {
+	for _, ch := range s.topologyWatchers {
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- state
+	}
+}
+
+// publishRingChanged publishes a newly installed ring view on the event bus
+func (s *ChatServer) publishRingChanged(state ring.RingState) {
+	nodes := make([]string, 0, len(state.Nodes))
+	for _, node := range state.Nodes {
+		nodes = append(nodes, node.NodeID)
+	}
+	s.events.Publish(events.RingChanged{Time: s.wall.Now(), Server: s.serverID, Epoch: state.Epoch, Nodes: nodes})
+}
+
+// memberChanged publishes a NodeDown event when gossip declares a member
+// dead or sees it leave
+func (s *ChatServer) memberChanged(m gossip.Member) {
+	var reason string
+	switch m.State {
+	case gossip.StateDead:
+		reason = "declared dead by gossip"
+	case gossip.StateLeft:
+		reason = "left the cluster"
+	default:
+		return
+	}
+	s.events.Publish(events.NodeDown{Time: s.wall.Now(), Node: m.ID, Reporter: s.serverID, Reason: reason})
+}
+
+// FollowCoordinator keeps the server's ring view in sync with the
+// coordinator's authoritative ring until the server stops
+func (s *ChatServer) FollowCoordinator(address string) error {
+	conn, err := grpc.Dial(address, s.coordinatorDialOptions()...)
+	if err != nil {
+		return fmt.Errorf("failed to connect to coordinator %s: %w", address, err)
+	}
+	s.watchCoordinator(conn)
+
+	s.log.Info("Following coordinator", "coordinator", address)
+	return nil
+}
+
+// watchCoordinator follows the topology stream of the coordinator on conn,
+// closing conn when the server stops
+func (s *ChatServer) watchCoordinator(conn *grpc.ClientConn) {
+	coordinator := pb.NewCoordinatorServiceClient(conn)
+
+	source := func(ctx context.Context, knownEpoch uint64) (topology.Stream, error) {
+		return coordinator.WatchTopology(ctx, &pb.WatchTopologyRequest{KnownEpoch: knownEpoch})
+	}
+	watcher := topology.NewWatcher(s.serverID, source, s.ring.Epoch, s.SetRingState)
+	watcher.Start()
+
+	go func() {
+		<-s.shutdownCh
+		watcher.Stop()
+		conn.Close()
+	}()
+}
+
+// startMetadata joins the Raft metadata group and follows its membership.
+// Every committed change is installed as the server's ring view.
+func (s *ChatServer) startMetadata() error {
+	store, err := metadata.Open(*s.metadataConfig)
+	if err != nil {
+		return fmt.Errorf("failed to start metadata replica: %w", err)
+	}
+	store.OnChange(func(state ring.RingState) {
+		s.SetRingState(state)
+
+		// Hand the latest state to the rebalancer, replacing any it hasn't
+		// picked up yet
+		select {
+		case <-s.metadataUpdates:
+		default:
+		}
+		s.metadataUpdates <- state
+	})
+	// Pick up anything recovered before the callback was installed
+	s.SetRingState(store.State())
+	s.metadata = store
+
+	s.election = election.New(s.serverID, store)
+	if s.rebalanceConfig != nil {
+		s.election.Run("rebalancer", s.runRebalancer)
+	}
+	if s.deadNodeTimeout > 0 && s.gossip != nil {
+		s.election.Run("reaper", s.runReaper)
+	}
+	if s.aggregateStats {
+		s.election.Run("aggregator", s.runAggregator)
+	}
+	s.election.Start()
+
+	s.log.Info("Metadata replica started", "raft_address", s.metadataConfig.RaftAddress)
+	return nil
+}
+
+// Election returns the server's metadata leadership, or nil if metadata is
+// not configured. Observe it to follow which replica runs singleton duties.
+func (s *ChatServer) Election() *election.Election {
+	return s.election
+}
+
+// Metadata returns the server's metadata replica, or nil if not configured.
+// Membership changes must be proposed on the current leader.
+func (s *ChatServer) Metadata() *metadata.Store {
+	return s.metadata
+}
+
+// RingState returns the server's current ring view
+func (s *ChatServer) RingState() ring.RingState {
+	return s.ring.State()
+}
+
+// GetRingState returns the server's ring view, or only an acknowledgement if
+// the caller already holds the same view
+func (s *ChatServer) GetRingState(ctx context.Context, req *pb.RingStateRequest) (*pb.RingStateResponse, error) {
+	state := s.ring.State()
+	if req.KnownEpoch == state.Epoch && req.KnownDigest == state.Digest() {
+		return &pb.RingStateResponse{InSync: true}, nil
+	}
+	return &pb.RingStateResponse{State: pb.NewRingState(state)}, nil
+}
+
+// checkOwnership rejects requests routed with an older ring view when this
+// server's newer view says another node owns the chat. Requests carrying the
+// same or a newer epoch are accepted so failover to a successor still works.
+func (s *ChatServer) checkOwnership(req *pb.ChatRequest) error {
+	if err := s.checkNamespace(req.Namespace); err != nil {
+		return err
+	}
+
+	epoch := s.ring.Epoch()
+	if epoch == 0 || req.RingEpoch >= epoch {
+		return nil
+	}
+
+	owners := s.placement().GetNodesInRegion(req.ChatId, 1, s.region)
+	if len(owners) == 0 || owners[0].NodeID == s.serverID {
+		return nil
+	}
+	owner := owners[0].NodeID
+
+	return fmt.Errorf("%w: stale ring epoch %d (current %d): chat %s is owned by %s",
+		chaterr.ErrNotOwner, req.RingEpoch, epoch, req.ChatId, owner)
+}
+
+// placement returns the ring chats on this server are placed with: the
+// server's own namespace. Other namespaces' servers never hold its chats.
+func (s *ChatServer) placement() *ring.HashRing {
+	return s.ring.Namespace(s.namespace)
+}
+
+// checkNamespace rejects requests for a chat in another namespace, which no
+// server here owns
+func (s *ChatServer) checkNamespace(namespace string) error {
+	if namespace != s.namespace {
+		return fmt.Errorf("%w: server %s serves namespace %q, not %q", chaterr.ErrNotOwner, s.serverID, s.namespace, namespace)
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/pkg/auth"
+	"github.com/sh4shv4t/DistriChat/pkg/cache"
+	"github.com/sh4shv4t/DistriChat/pkg/chaterr"
+	"github.com/sh4shv4t/DistriChat/pkg/events"
+	"github.com/sh4shv4t/DistriChat/pkg/logging"
+	"github.com/sh4shv4t/DistriChat/pkg/metrics"
+	"github.com/sh4shv4t/DistriChat/pkg/search"
+	pb "github.com/sh4shv4t/DistriChat/proto"
+)
+
+// indexMessage adds a message this server just stored to its search index.
+// Text messages are indexed by their text, attachments by their file name;
+// system events aren't indexed.
+func (s *ChatServer) indexMessage(chatID string, msg cache.Message) {
+	if s.search == nil {
+		return
+	}
+	var text string
+	switch msg.Type {
+	case cache.ContentText:
+		text = msg.Content
+	case cache.ContentAttachment:
+		if msg.Attachment != nil {
+			text = msg.Attachment.Filename
+		}
+	}
+	if text == "" {
+		return
+	}
+	s.search.Add(search.Doc{
+		ChatID:    chatID,
+		MessageID: msg.ID,
+		Seq:       msg.Seq,
+		SenderID:  msg.SenderID,
+		Text:      text,
+		Timestamp: msg.Timestamp,
+	})
+}
+
+// unindexEvicted drops the chats this server's cache evicted without
+// archiving from its search index, as their messages are gone from it
+func (s *ChatServer) unindexEvicted(e events.Event) {
+	eviction, ok := e.(events.CacheEviction)
+	if !ok || eviction.Server != s.serverID || eviction.Archived {
+		return
+	}
+	if n := s.search.DeleteChat(eviction.ChatID); n > 0 {
+		s.log.Debug("Unindexed evicted chat", logging.ChatID(eviction.ChatID), "messages", n)
+	}
+}
+
+// unindexCleared drops the chats a cache purge lost from the search index:
+// every chat resident before it, unless they are archived
+func (s *ChatServer) unindexCleared(resident []cache.ResidentSession) {
+	if s.search == nil || s.archive != nil {
+		return
+	}
+	for _, session := range resident {
+		s.search.DeleteChat(session.ChatID)
+	}
+}
+
+// registerSearchMetrics reports the search index's size in reg
+func (s *ChatServer) registerSearchMetrics(reg metrics.Registry) {
+	reg.GaugeFunc("districhat_server_indexed_messages", "Messages in the server's search index", func() float64 {
+		return float64(s.indexedMessages())
+	})
+}
+
+// indexedMessages returns how many messages the search index holds (0
+// without one)
+func (s *ChatServer) indexedMessages() int {
+	if s.search == nil {
+		return 0
+	}
+	return s.search.Stats().Docs
+}
+
+// SearchMessages answers a full-text query from this server's index: the
+// messages of the chats it holds that contain every word of the query,
+// best first. With access control set, only the chats the caller is a
+// member of are searched.
+func (s *ChatServer) SearchMessages(ctx context.Context, req *pb.SearchRequest) (resp *pb.SearchResponse, err error) {
+	s.inFlight.Add(1)
+	defer func(start time.Time) {
+		s.inFlight.Add(-1)
+		s.observeRequest("SearchMessages", req.Namespace, resp.GetErrorCode(), start)
+	}(time.Now())
+
+	if s.search == nil {
+		return s.searchError(pb.ErrorCode_ERROR_VALIDATION_FAILED,
+			fmt.Sprintf("server %s has no search index", s.serverID)), nil
+	}
+	if len(search.Tokenize(req.Query)) == 0 {
+		return s.searchError(pb.ErrorCode_ERROR_VALIDATION_FAILED, "query has no words"), nil
+	}
+	if err := s.checkNamespace(req.Namespace); err != nil {
+		return s.searchError(pb.ErrorCode_ERROR_NOT_OWNER, err.Error()), nil
+	}
+	ctx, principal, err := s.authenticate(ctx, "SearchMessages", req.Namespace)
+	if err != nil {
+		return s.searchError(chaterr.Code(err, pb.ErrorCode_ERROR_INTERNAL), err.Error()), nil
+	}
+
+	if !principal.Allows(auth.ScopeRead, req.Namespace) {
+		err := s.deny(ctx, "", fmt.Errorf("%w: %s may not read in namespace %q",
+			chaterr.ErrPermissionDenied, principal.ID, req.Namespace))
+		return s.searchError(pb.ErrorCode_ERROR_PERMISSION_DENIED, err.Error()), nil
+	}
+
+	// Chats the caller names must each be readable; unnamed ones it may
+	// not read are skipped
+	for _, chatID := range req.ChatIds {
+		if err := s.authorizeRead(ctx, principal, &pb.HistoryRequest{ChatId: chatID, Namespace: req.Namespace}); err != nil {
+			return s.searchError(chaterr.Code(err, pb.ErrorCode_ERROR_INTERNAL), err.Error()), nil
+		}
+	}
+	var filter func(string) bool
+	if s.access != nil && !s.access.isAdmin(principal) {
+		filter = func(chatID string) bool { return s.cache.MembersContext(ctx, chatID)[principal.ID] }
+	}
+
+	result := s.search.Search(search.Query{
+		Text:     req.Query,
+		ChatIDs:  req.ChatIds,
+		SenderID: req.SenderId,
+		Limit:    int(req.Limit),
+		Filter:   filter,
+	})
+	resp = &pb.SearchResponse{Success: true, ServerId: s.serverID, Total: int32(result.Total)}
+	for _, hit := range result.Hits {
+		resp.Hits = append(resp.Hits, &pb.SearchHit{
+			ChatId:    hit.ChatID,
+			MessageId: hit.MessageID,
+			Seq:       hit.Seq,
+			SenderId:  hit.SenderID,
+			Text:      hit.Text,
+			Timestamp: hit.Timestamp.Unix(),
+			Score:     hit.Score,
+		})
+	}
+	return resp, nil
+}
+
+// searchError builds a failed SearchResponse
+func (s *ChatServer) searchError(code pb.ErrorCode, details string) *pb.SearchResponse {
+	return &pb.SearchResponse{Success: false, ServerId: s.serverID, ErrorCode: code, ErrorDetails: details}
+}
@@ -0,0 +1,1296 @@
+// Package server implements a gRPC chat server with hierarchical caching.
+// Each server instance represents a compute node with limited memory
+// that can handle chat sessions with L1/L2 cache tiers.
+package server
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/internal/clusterstats"
+	"github.com/sh4shv4t/DistriChat/internal/requestid"
+	"github.com/sh4shv4t/DistriChat/internal/timeseries"
+	"github.com/sh4shv4t/DistriChat/pkg/audit"
+	"github.com/sh4shv4t/DistriChat/pkg/auth"
+	"github.com/sh4shv4t/DistriChat/pkg/cache"
+	"github.com/sh4shv4t/DistriChat/pkg/chaos"
+	"github.com/sh4shv4t/DistriChat/pkg/chaterr"
+	"github.com/sh4shv4t/DistriChat/pkg/clock"
+	"github.com/sh4shv4t/DistriChat/pkg/dedup"
+	"github.com/sh4shv4t/DistriChat/pkg/election"
+	"github.com/sh4shv4t/DistriChat/pkg/events"
+	"github.com/sh4shv4t/DistriChat/pkg/fanout"
+	"github.com/sh4shv4t/DistriChat/pkg/gossip"
+	"github.com/sh4shv4t/DistriChat/pkg/logging"
+	"github.com/sh4shv4t/DistriChat/pkg/metadata"
+	"github.com/sh4shv4t/DistriChat/pkg/metrics"
+	"github.com/sh4shv4t/DistriChat/pkg/msglog"
+	"github.com/sh4shv4t/DistriChat/pkg/mtls"
+	"github.com/sh4shv4t/DistriChat/pkg/notify"
+	"github.com/sh4shv4t/DistriChat/pkg/outbox"
+	"github.com/sh4shv4t/DistriChat/pkg/policy"
+	"github.com/sh4shv4t/DistriChat/pkg/presence"
+	"github.com/sh4shv4t/DistriChat/pkg/purge"
+	"github.com/sh4shv4t/DistriChat/pkg/ratelimit"
+	"github.com/sh4shv4t/DistriChat/pkg/rebalance"
+	"github.com/sh4shv4t/DistriChat/pkg/ring"
+	"github.com/sh4shv4t/DistriChat/pkg/search"
+	"github.com/sh4shv4t/DistriChat/pkg/tracing"
+	"github.com/sh4shv4t/DistriChat/pkg/transform"
+	pb "github.com/sh4shv4t/DistriChat/proto"
+	"google.golang.org/grpc"
+)
+
+// tracer records the server's steps of a request: replication and
+// persistence, inside the RPC span started by tracing.ServerOption
+var tracer = tracing.Tracer("github.com/sh4shv4t/DistriChat/pkg/server")
+
+// ChatServer implements the gRPC ChatService with hierarchical caching
+type ChatServer struct {
+	pb.UnimplementedChatServiceServer
+
+	// Server identification
+	serverID  string
+	address   string
+	port      int
+	region    string
+	namespace string
+
+	// Cache for chat sessions
+	cache *cache.HierarchicalCache
+
+	// The server's view of cluster ownership (empty until one is installed)
+	ring *ring.HashRing
+
+	// Places chats on the ring, for the plans computed from it (nil: CRC32)
+	hasher ring.Hasher
+
+	// Topology watchers - each receives the latest ring after every change
+	topologyMu       sync.Mutex
+	topologyWatchers map[int]chan ring.RingState
+	nextWatcher      int
+
+	// SWIM membership (nil when gossip is disabled)
+	gossip          *gossip.Node
+	gossipTransport *gossip.GRPCTransport
+	gossipSeeds     []string
+
+	// Ring views seen in gossip: the highest epoch being pulled from a
+	// peer, and how often a peer's view conflicted with ours
+	ringPullMu    sync.Mutex
+	ringPulling   uint64
+	ringConflicts atomic.Int64
+	lastConflict  atomic.Uint64 // Epoch of the last conflict logged
+
+	// Quorum replication settings and cached connections to peer replicas
+	replication ReplicationConfig
+	peerMu      sync.Mutex
+	peerConns   map[string]*grpc.ClientConn
+	messageSeq  atomic.Uint64
+	clock       *clock.HLC
+	staleReads  atomic.Int64 // History reads answered from the local copy alone
+
+	// Per-sender rate limiting (nil when not configured): the buckets of
+	// senders hashing to this server, and tokens leased from other owners
+	limiter     *ratelimit.Limiter
+	quota       *ratelimit.Quota
+	rateLimited atomic.Int64
+
+	// Spam and abuse screening of posted messages (nil: none), and how
+	// many messages it flagged and refused
+	policies       []policy.Policy
+	policyFlagged  atomic.Int64
+	policyRejected atomic.Int64
+
+	// Stages rewriting posted text messages before they are stored (nil:
+	// none)
+	transforms []transform.Stage
+
+	// Where uploaded attachments are kept (nil: uploads are refused)
+	attachments *AttachmentConfig
+
+	// Subscribers following chats on this server, fed every message it
+	// accepts or replicates
+	hub *fanout.Hub
+
+	// cursors hold durable subscribers' acknowledged positions
+	cursors *fanout.Cursors
+
+	// IDs of the messages stored here within the dedup window
+	dedup       dedup.Store
+	dedupWindow time.Duration
+
+	// Accepted messages awaiting delivery, and the dispatcher's wake-up
+	outbox     outbox.Store
+	outboxWake chan struct{}
+
+	// Push notifications of messages to members not subscribed here (nil
+	// when not configured), and how many were sent and given up on
+	notifier            *notify.Dispatcher
+	notificationsSent   atomic.Int64
+	notificationsFailed atomic.Int64
+
+	// Presence of the users hashing to this server, and how many
+	// subscription streams each user has open here
+	presence    *presence.Tracker
+	presenceMu  sync.Mutex
+	streamUsers map[string]int
+
+	// Full-text index of the messages this server holds (nil when not
+	// configured), and its subscription to the cache evictions that
+	// remove chats from it
+	search          *search.Index
+	searchEvictions *events.Subscription
+
+	// Raft-replicated ring membership (nil when not configured)
+	metadataConfig  *metadata.Config
+	metadata        *metadata.Store
+	metadataUpdates chan ring.RingState // Latest committed state (capacity 1)
+
+	// Singleton duties, run while this replica leads the metadata group
+	election        *election.Election
+	rebalanceConfig *rebalance.Config
+	deadNodeTimeout time.Duration
+
+	// The rebalancer while this replica leads (nil otherwise), the
+	// operator's rate override, and nodes reaped as permanently dead
+	rebalanceMu   sync.Mutex
+	rebalancer    *rebalance.Rebalancer
+	rebalanceRate *rebalanceRate
+	deadNodes     []string
+
+	// Coordinator registration (coordinatorAddress is empty when not joining)
+	coordinatorAddress string
+	capacity           int
+	heartbeatInterval  time.Duration
+	coordinator        pb.CoordinatorServiceClient
+
+	// Ownership lease from the coordinator. leased is set once the
+	// coordinator grants leases; writes then need a valid one.
+	leaseMu sync.Mutex
+	leased  bool
+	lease   *ownershipLease
+
+	// Cold tier below L2 (nil when not configured) and its sweep policy
+	archive         cache.ColdTier
+	archiveAfter    time.Duration
+	archiveInterval time.Duration
+
+	// Per-chat retention policies (nil keeps messages forever) and the
+	// messages purged under them
+	retention      *RetentionConfig
+	messagesPurged atomic.Int64
+
+	// PurgeUser jobs, the ones running here, and the senders purged from
+	// this server with the send time their purge reached
+	purgeJobs     purge.Store
+	purgeMu       sync.Mutex
+	purgesRunning map[string]bool
+	purgedSenders map[string]time.Time
+
+	// External durable log of accepted messages (nil when not configured)
+	messageLog msglog.Log
+	replayLog  bool
+
+	// Audit log of administrative events
+	auditLog audit.Log
+
+	// gRPC server instance
+	grpcServer *grpc.Server
+
+	// Admin gRPC server, served on its own port (nil when disabled)
+	adminServer   *grpc.Server
+	adminPort     int
+	adminToken    string
+	adminListener net.Listener
+
+	// Cluster stats aggregation: the aggregator while this server runs it
+	// (nil otherwise) and the HTTP server exposing it (nil when disabled)
+	aggregateStats bool
+	statsInterval  time.Duration
+	aggregatorMu   sync.Mutex
+	aggregator     *clusterstats.Aggregator
+	metricsPort    int
+	metricsServer  *http.Server
+	graphQL        bool
+
+	// Series exported per server, the registry holding them, and the
+	// live values served on /debug/vars
+	registry metrics.Registry
+	metrics  serverMetrics
+	vars     *expvar.Map
+	inFlight atomic.Int64 // Client requests being handled
+
+	// Requests taking longer than slowThreshold are logged (0: never)
+	slowThreshold time.Duration
+	slowRequests  atomic.Int64
+
+	// Bus the server's ring changes and dead members are published on
+	events *events.Bus
+
+	// Recent metrics sampled every metricHistoryInterval (nil: disabled)
+	metricHistory         *timeseries.Buffer
+	metricHistoryInterval time.Duration
+
+	// Fault injection applied to calls this server receives (nil: none)
+	chaos *chaos.Injector
+
+	// Chat port listener and peer dialer supplied in the config (nil: TCP)
+	listener net.Listener
+	dialer   func(ctx context.Context, address string) (net.Conn, error)
+
+	// Certificates for mutual TLS on the chat port and to peers (nil:
+	// plaintext)
+	tls *mtls.Provider
+
+	// Per-chat access control (nil allows everyone), the caller
+	// identification it and API key scopes rely on (nil lets everyone in
+	// anonymously), and the API keys issued
+	access        *AccessControl
+	authenticator auth.Authenticator
+	apiKeys       auth.KeyStore
+
+	log *slog.Logger
+
+	// Recent warnings and errors logged through log, for DebugState
+	recorder *logging.Recorder
+
+	// Wall clock message timestamps, cache access times and uptime are
+	// read from
+	wall clock.Clock
+
+	// Server state
+	startTime time.Time
+	healthy   atomic.Bool
+	draining  atomic.Bool
+	mu        sync.RWMutex
+
+	// Shutdown coordination. shutdownCh closes as shutdown begins; lifetime
+	// ends once in-flight requests are done (or cut off), cancelling the
+	// work that outlives them: replication past the quorum, read repair
+	// and archiving.
+	shutdownCh  chan struct{}
+	lifetime    context.Context
+	endLifetime context.CancelFunc
+}
+
+// ServerConfig contains configuration for creating a new server
+type ServerConfig struct {
+	ServerID   string
+	Port       int
+	L1Capacity int // GPU VRAM simulation (default: 5)
+	L2Capacity int // RAM simulation (default: 20)
+
+	// SharedL2, if set, backs the L2 tier with a store shared between
+	// servers (e.g. a cache.RedisTier), so a replica taking over a chat
+	// after a failover finds it warm
+	SharedL2 cache.SharedTier
+
+	// Archive, if set, is the cold tier below L2 (e.g. an archive.Archive
+	// over S3): sessions evicted from L2 or idle longer than ArchiveAfter
+	// are written to it and restored when next accessed
+	Archive         cache.ColdTier
+	ArchiveAfter    time.Duration // Idle time before archiving (default: 1h)
+	ArchiveInterval time.Duration // How often idle chats are swept (default: 1m)
+
+	// Coordinator, if set, is joined on Start: the server registers itself
+	// (ID, advertised address, capacity, region), heartbeats, follows the
+	// authoritative ring, and deregisters on Stop
+	Coordinator       string
+	Capacity          int           // Virtual node weight (default: the coordinator's VirtualNodes)
+	AdvertiseAddress  string        // Address registered for clients and peers (default: localhost:Port)
+	HeartbeatInterval time.Duration // How often to heartbeat the coordinator (default: 1s)
+
+	// Region the server runs in. Each region keeps its own replicas of every
+	// chat; writes reach other regions asynchronously. Empty is the default
+	// region, which is all a single-region cluster needs.
+	Region string
+
+	// Namespace is the logical ring the server joins, e.g. "premium" for
+	// servers dedicated to premium tenants. Chats in a namespace are placed
+	// only on its servers, so tenants are isolated from each other's load.
+	// Empty is the default namespace.
+	Namespace string
+
+	// AdminPort serves AdminService on a separate listener (0 disables it)
+	AdminPort int
+	// AdminToken, if set, must be presented by admin callers in the
+	// "x-admin-token" metadata header
+	AdminToken string
+	// AdminListener, if set, serves AdminService in place of listening on
+	// AdminPort (e.g. an in-memory bufconn listener in tests)
+	AdminListener net.Listener
+
+	// EnableGossip runs SWIM membership on the chat port, joining via
+	// GossipSeeds (addresses of existing servers; empty for the first node)
+	EnableGossip bool
+	GossipSeeds  []string
+	GossipPeriod time.Duration // Protocol period (default: 1s)
+
+	// Replication controls how many servers hold each chat (default: one)
+	Replication ReplicationConfig
+
+	// Metadata, if set, runs a replica of the Raft metadata group and takes
+	// the server's ring view from it
+	Metadata *metadata.Config
+
+	// Rebalance, with Metadata set, migrates sessions after membership
+	// changes. It runs only on the replica leading the metadata group.
+	// Its Replicas defaults to Replication.N, so replica sets that lose a
+	// member are restored too.
+	Rebalance *rebalance.Config
+
+	// DeadNodeTimeout, with EnableGossip and Metadata set, has the metadata
+	// leader remove ring members that gossip has reported dead for this
+	// long. They are declared permanently lost, and with Rebalance set
+	// their chats are re-replicated onto the survivors (0 disables).
+	DeadNodeTimeout time.Duration
+
+	// MessageLog, if set, receives every message this server accepts (e.g.
+	// a msglog.KafkaLog). With ReplayLog, the server rebuilds its cache from
+	// the log on start, keeping only the chats it replicates.
+	MessageLog msglog.Log
+	ReplayLog  bool
+
+	// AggregateStats runs the cluster stats aggregator, polling every ring
+	// member's cache statistics each StatsInterval (default: 10s). With
+	// Metadata set it runs only on the metadata leader; otherwise here.
+	AggregateStats bool
+	StatsInterval  time.Duration
+
+	// MetricsPort serves the server's metrics, and the aggregated cluster
+	// statistics while this server runs the aggregator, as Prometheus
+	// metrics on /metrics, and its live values (ring epoch, cache sizes,
+	// requests in flight) as expvar JSON on /debug/vars (0 disables it)
+	MetricsPort int
+
+	// GraphQL also serves the server's read API (the server and its
+	// statistics, the chats it holds, and any chat's messages) as GraphQL
+	// on MetricsPort's /graphql, behind AdminToken if one is set
+	GraphQL bool
+
+	// Metrics receives the server's, its cache's and its ring's series
+	// (default: a Prometheus registry labelled with server_id). Only a
+	// *metrics.Prometheus registry is served on MetricsPort; with another
+	// one, the caller exposes it.
+	Metrics metrics.Registry
+
+	// RateLimit, if set, caps how fast each sender may post across the
+	// whole cluster. Every sender's quota is held by the server the sender
+	// hashes to; others lease tokens from it.
+	RateLimit *ratelimit.Config
+
+	// Listener, if set, serves the chat port in place of listening on Port
+	// (e.g. an in-memory bufconn listener in tests), and Dialer connects
+	// to peers for replication, quota leases and gossip, and to the
+	// coordinator, in place of TCP
+	Listener net.Listener
+	Dialer   func(ctx context.Context, address string) (net.Conn, error)
+
+	// Clock drives message timestamps, cache access times and uptime
+	// (default: the system clock), e.g. a simulation's virtual clock
+	Clock clock.Clock
+
+	// Chaos, if set, applies its fault rules to the calls this server
+	// receives, tags the calls it makes to peers with its ID, and lets
+	// Chaos.Kill(ServerID) stop it
+	Chaos *chaos.Injector
+
+	// AuditLog records security and administrative events: rejected admin
+	// tokens, drains, decommissions, cache purges, configuration changes
+	// and dead nodes removed (default: in memory, lost on exit; an
+	// audit.FileLog keeps them). AdminService.QueryAuditLog reads it.
+	AuditLog audit.Log
+
+	// SlowRequestThreshold is how long a client or peer request may take
+	// before it is logged as slow, with its chat, the cache tiers it was
+	// served from and its wait for the cache lock (default: 500ms;
+	// negative disables it)
+	SlowRequestThreshold time.Duration
+
+	// MetricHistory is how long the server keeps its recent metrics
+	// (request and cache rates, cache sizes, requests in flight) in memory,
+	// sampled every MetricHistoryInterval, for AdminService.GetMetricHistory
+	// (default: 15m at 1s; negative disables it)
+	MetricHistory         time.Duration
+	MetricHistoryInterval time.Duration
+
+	// Events receives the server's ring changes, the members its gossip
+	// declares dead, and its cache's evictions and demotions (default:
+	// events.Default())
+	Events *events.Bus
+
+	// Logger the server, its cache and its ring log to, tagged with their
+	// components and ServerID (default: slog's default logger)
+	Logger *slog.Logger
+
+	// EvictionPolicy decides which sessions full cache tiers demote and
+	// evict (default: cache.LRU)
+	EvictionPolicy cache.EvictionPolicy
+
+	// Hasher places chats on the ring (default: CRC32). Every server and
+	// client of the cluster must use the same one.
+	Hasher ring.Hasher
+
+	// TLS, if set, serves the chat port over mutual TLS, refusing clients
+	// without a certificate from its CAs, and presents its certificate to
+	// the peers this server dials, so the certificate must allow both
+	// server and client authentication. Rotated files are picked up
+	// without a restart. The admin port is unaffected.
+	TLS *mtls.Provider
+
+	// AccessControl, if set, restricts chats to their members: PostMessage
+	// and GetHistory calls from other principals are refused with
+	// ERROR_PERMISSION_DENIED, and calls the Authenticator can't identify
+	// with ERROR_UNAUTHENTICATED
+	AccessControl *AccessControl
+
+	// Authenticator, if set, identifies the callers of PostMessage and
+	// GetHistory, refusing those it can't with ERROR_UNAUTHENTICATED
+	// (default: with APIKeys or JWT, by their API key or bearer token, or
+	// else their client certificate if TLS is set; with only
+	// AccessControl, by their certificate, auth.TLSIdentity)
+	Authenticator auth.Authenticator
+
+	// APIKeys, if set, holds the API keys clients may authenticate with,
+	// issued and revoked with AdminService.CreateAPIKey and RevokeAPIKey.
+	// A key limits its caller to its tenant's namespace and its scopes.
+	// Peers hold no keys, so with replication set TLS too: peers then
+	// authenticate by certificate.
+	APIKeys auth.KeyStore
+
+	// JWT, if set, authenticates clients by bearer tokens from an identity
+	// provider, checked against its published keys. The token's claims
+	// name the principal and may limit it to a tenant, like an API key.
+	JWT *auth.JWTVerifier
+
+	// MessagePolicies screen every message posted for spam and abuse, in
+	// order: the first to reject a message refuses it with
+	// ERROR_POLICY_REJECTED, and messages flagged are logged and counted
+	// but accepted. Messages relayed by a federation bridge were screened
+	// by the cluster they came from.
+	MessagePolicies []policy.Policy
+
+	// Transforms rewrite every text message posted, once MessagePolicies
+	// accept it and before it is stored and replicated, in order: each
+	// may change its text, annotate it, or refuse it with
+	// ERROR_POLICY_REJECTED. Annotations are kept with the message; those
+	// sent by clients are dropped. Messages relayed by a federation bridge
+	// were transformed by the cluster they came from.
+	Transforms []transform.Stage
+
+	// Fanout sizes the queues of subscribers to the server's chats, and
+	// caps how many may follow one chat. A subscriber a whole queue behind
+	// is disconnected with ERROR_SLOW_CONSUMER.
+	Fanout fanout.Config
+
+	// CursorTTL is how long a durable subscriber's acknowledged position
+	// is kept after its last ack or subscription (default: 24h). One that
+	// returns later starts over from the after_seq it asks for.
+	CursorTTL time.Duration
+
+	// Notifier, if set, is told of every message the server accepts for a
+	// chat whose members aren't all subscribed to it here, e.g. to send
+	// them push notifications: a notify.Webhook, a notify.FCM or your own.
+	// Notifications are sent in the background and retried with backoff
+	// as Notifications configures.
+	Notifier      notify.Notifier
+	Notifications notify.DispatcherConfig
+
+	// Presence tracks which users are online: each user's presence is held
+	// by the server the user's ID hashes to, fed by its subscription
+	// streams on every server and its clients' heartbeats (Clock defaults
+	// to the server's)
+	Presence presence.Config
+
+	// Search, if set, indexes every message the server stores for
+	// SearchMessages, ranking matches by relevance. Chats the cache loses
+	// (evicted or purged without an Archive) leave the index with them.
+	// The index lives in memory; with ReplayLog it is rebuilt on start.
+	Search *search.Config
+
+	// Attachments, if set, lets clients upload attachments with
+	// UploadAttachment, kept in Attachments.Store, and download them with
+	// DownloadAttachment
+	Attachments *AttachmentConfig
+
+	// Retention, if set, gives chats their own time in the cache before
+	// archiving and purges messages past their retention every Interval,
+	// from the cache, the Archive, the search index and a MessageLog that
+	// implements msglog.Compactor
+	Retention *RetentionConfig
+
+	// PurgeJobs holds the PurgeUser jobs this server runs, so they can be
+	// followed and resumed; jobs still running are resumed on Start
+	// (default: in memory)
+	PurgeJobs purge.Store
+
+	// DedupStore remembers the IDs of the messages this server stored for
+	// DedupWindow (default: 24h), apart from the cache, so a write retried
+	// after its message left the cache, was deleted or, with a
+	// dedup.FileStore, after a restart, is answered as a duplicate rather
+	// than stored again (default: in memory)
+	DedupStore  dedup.Store
+	DedupWindow time.Duration
+
+	// Outbox holds each message this server accepts as coordinator until it
+	// is delivered: fanned out to subscribers, other regions and
+	// notifications, and published to the MessageLog. Committing a message
+	// to it is part of accepting it, so with an outbox.FileStore a message
+	// accepted before a crash is restored and delivered after it (default:
+	// in memory). Replicas fan replicated messages out to their own
+	// subscribers directly, without it: a replica crashing first only costs
+	// its subscribers a live message, which they read back on resuming.
+	Outbox outbox.Store
+}
+
+// Option adjusts a server's configuration as NewChatServer creates it,
+// after the ServerConfig it is given
+type Option func(*ServerConfig)
+
+// WithLogger sets the logger the server, its cache and its ring log to
+// (ServerConfig.Logger)
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *ServerConfig) { c.Logger = logger }
+}
+
+// WithEvictionPolicy sets the cache's eviction policy
+// (ServerConfig.EvictionPolicy)
+func WithEvictionPolicy(policy cache.EvictionPolicy) Option {
+	return func(c *ServerConfig) { c.EvictionPolicy = policy }
+}
+
+// WithHasher sets the hasher placing chats on the ring (ServerConfig.Hasher)
+func WithHasher(h ring.Hasher) Option {
+	return func(c *ServerConfig) { c.Hasher = h }
+}
+
+// WithTLS serves the chat port and dials peers over mutual TLS
+// (ServerConfig.TLS)
+func WithTLS(provider *mtls.Provider) Option {
+	return func(c *ServerConfig) { c.TLS = provider }
+}
+
+// NewChatServer creates a new chat server instance
+func NewChatServer(config ServerConfig, opts ...Option) *ChatServer {
+	for _, opt := range opts {
+		opt(&config)
+	}
+	if config.L1Capacity <= 0 {
+		config.L1Capacity = 5
+	}
+	if config.L2Capacity <= 0 {
+		config.L2Capacity = 20
+	}
+	if config.AuditLog == nil {
+		config.AuditLog = audit.NewMemoryLog()
+	}
+	if config.SlowRequestThreshold == 0 {
+		config.SlowRequestThreshold = defaultSlowRequestThreshold
+	}
+	if config.MetricHistory == 0 {
+		config.MetricHistory = defaultMetricHistory
+	}
+	if config.MetricHistoryInterval <= 0 {
+		config.MetricHistoryInterval = defaultMetricHistoryInterval
+	}
+	if config.Events == nil {
+		config.Events = events.Default()
+	}
+	if config.Authenticator == nil {
+		config.Authenticator = defaultAuthenticator(config)
+	}
+
+	componentLogger := func(component string) *slog.Logger {
+		if config.Logger == nil {
+			return logging.Logger(component).With(logging.ServerID(config.ServerID))
+		}
+		return config.Logger.With(logging.Component(component), logging.ServerID(config.ServerID))
+	}
+
+	cacheOpts := []cache.Option{cache.WithEvictionPolicy(config.EvictionPolicy), cache.WithLogger(componentLogger("cache"))}
+	chatCache := cache.NewHierarchicalCache(config.ServerID, config.L1Capacity, config.L2Capacity, cacheOpts...)
+	if config.SharedL2 != nil {
+		chatCache = cache.NewSharedL2Cache(config.ServerID, config.L1Capacity, config.L2Capacity, config.SharedL2, cacheOpts...)
+	}
+	if config.Archive != nil {
+		chatCache.SetColdTier(config.Archive)
+		if config.ArchiveAfter <= 0 {
+			config.ArchiveAfter = time.Hour
+		}
+		if config.ArchiveInterval <= 0 {
+			config.ArchiveInterval = time.Minute
+		}
+	}
+
+	address := config.AdvertiseAddress
+	if address == "" {
+		address = fmt.Sprintf("localhost:%d", config.Port)
+	}
+	if config.HeartbeatInterval <= 0 {
+		config.HeartbeatInterval = time.Second
+	}
+	if config.StatsInterval <= 0 {
+		config.StatsInterval = 10 * time.Second
+	}
+	if config.Metrics == nil {
+		config.Metrics = metrics.NewPrometheus(map[string]string{"server_id": config.ServerID})
+	}
+	chatCache.SetMetrics(config.Metrics)
+	if config.Clock == nil {
+		config.Clock = clock.System()
+	}
+	chatCache.SetClock(config.Clock)
+	chatCache.SetEvents(config.Events)
+	lifetime, endLifetime := context.WithCancel(context.Background())
+	recorder := logging.NewRecorder(recentErrorsKept)
+	logger := componentLogger("server")
+	ringOpts := []ring.Option{ring.WithLogger(componentLogger("ring"))}
+	if config.Hasher != nil {
+		ringOpts = append(ringOpts, ring.WithHasher(config.Hasher))
+	}
+
+	server := &ChatServer{
+		serverID:           config.ServerID,
+		port:               config.Port,
+		region:             config.Region,
+		namespace:          config.Namespace,
+		address:            address,
+		coordinatorAddress: config.Coordinator,
+		capacity:           config.Capacity,
+		heartbeatInterval:  config.HeartbeatInterval,
+		cache:              chatCache,
+		ring:               ring.NewHashRing(0, ringOpts...),
+		hasher:             config.Hasher,
+		topologyWatchers:   make(map[int]chan ring.RingState),
+		adminPort:          config.AdminPort,
+		adminToken:         config.AdminToken,
+		adminListener:      config.AdminListener,
+		aggregateStats:     config.AggregateStats,
+		statsInterval:      config.StatsInterval,
+		metricsPort:        config.MetricsPort,
+		graphQL:            config.GraphQL,
+		registry:           config.Metrics,
+		metrics:            newServerMetrics(config.Metrics),
+		replication:        config.Replication.withDefaults(),
+		peerConns:          make(map[string]*grpc.ClientConn),
+		clock:              clock.NewHLCFrom(config.Clock),
+		metadataConfig:     config.Metadata,
+		metadataUpdates:    make(chan ring.RingState, 1),
+		rebalanceConfig:    config.Rebalance,
+		deadNodeTimeout:    config.DeadNodeTimeout,
+		messageLog:         config.MessageLog,
+		auditLog:           config.AuditLog,
+		events:             config.Events,
+		slowThreshold:      config.SlowRequestThreshold,
+		replayLog:          config.ReplayLog,
+		archive:            config.Archive,
+		archiveAfter:       config.ArchiveAfter,
+		archiveInterval:    config.ArchiveInterval,
+		chaos:              config.Chaos,
+		listener:           config.Listener,
+		dialer:             config.Dialer,
+		tls:                config.TLS,
+		access:             config.AccessControl,
+		authenticator:      config.Authenticator,
+		apiKeys:            config.APIKeys,
+		policies:           config.MessagePolicies,
+		transforms:         config.Transforms,
+		purgeJobs:          config.PurgeJobs,
+		purgesRunning:      make(map[string]bool),
+		purgedSenders:      make(map[string]time.Time),
+		hub:                fanout.NewHub(config.Fanout),
+		cursors:            fanout.NewCursors(config.CursorTTL, config.Clock),
+		dedup:              config.DedupStore,
+		dedupWindow:        config.DedupWindow,
+		outbox:             config.Outbox,
+		outboxWake:         make(chan struct{}, 1),
+		streamUsers:        make(map[string]int),
+		log:                slog.New(recorder.Wrap(logger.Handler())),
+		recorder:           recorder,
+		wall:               config.Clock,
+		startTime:          config.Clock.Now(),
+		shutdownCh:         make(chan struct{}),
+		lifetime:           lifetime,
+		endLifetime:        endLifetime,
+	}
+
+	if config.Presence.Clock == nil {
+		config.Presence.Clock = config.Clock
+	}
+	server.presence = presence.NewTracker(config.Presence)
+
+	if config.Retention != nil {
+		retention := *config.Retention
+		if retention.Interval <= 0 {
+			retention.Interval = time.Hour
+		}
+		server.retention = &retention
+	}
+
+	if config.Attachments != nil {
+		attachments := *config.Attachments
+		if attachments.MaxSize <= 0 {
+			attachments.MaxSize = 25 << 20
+		}
+		server.attachments = &attachments
+	}
+
+	if server.purgeJobs == nil {
+		server.purgeJobs = purge.NewMemoryStore()
+	}
+	if server.dedup == nil {
+		server.dedup = dedup.NewMemoryStore()
+	}
+	if server.dedupWindow <= 0 {
+		server.dedupWindow = 24 * time.Hour
+	}
+	if server.outbox == nil {
+		server.outbox = outbox.NewMemoryStore()
+	}
+
+	if config.Search != nil {
+		server.search = search.NewIndex(*config.Search)
+		server.searchEvictions = config.Events.Subscribe(server.unindexEvicted, events.KindCacheEviction)
+	}
+
+	if config.Notifier != nil {
+		dispatch := config.Notifications
+		dispatch.OnDone = server.notificationDone
+		server.notifier = notify.NewDispatcher(config.Notifier, dispatch)
+	}
+
+	if config.RateLimit != nil {
+		server.limiter = ratelimit.NewLimiter(*config.RateLimit)
+		server.quota = ratelimit.NewQuota(*config.RateLimit, ratelimit.SourceFunc(server.acquireQuota))
+	}
+
+	if config.EnableGossip {
+		server.gossipTransport = gossip.NewGRPCTransport(server.peerDialOptions()...)
+		server.gossip = gossip.New(gossip.Config{
+			ID:             config.ServerID,
+			Address:        server.address,
+			ProtocolPeriod: config.GossipPeriod,
+		}, server.gossipTransport)
+		server.gossip.OnRingView(server.comparePeerRing)
+		server.gossip.OnChange(server.memberChanged)
+		server.gossipSeeds = config.GossipSeeds
+	}
+
+	if config.MetricHistory > 0 {
+		server.metricHistory = timeseries.NewBuffer(int(config.MetricHistory / config.MetricHistoryInterval))
+		server.metricHistoryInterval = config.MetricHistoryInterval
+	}
+
+	server.ring.SetMetrics(config.Metrics)
+	server.registerFanoutMetrics(config.Metrics)
+	server.registerPresenceMetrics(config.Metrics)
+	server.registerSearchMetrics(config.Metrics)
+	server.registerDedupMetrics(config.Metrics)
+	server.registerOutboxMetrics(config.Metrics)
+	server.vars = server.newVars()
+	server.healthy.Store(true)
+
+	if config.Chaos != nil {
+		config.Chaos.Register(config.ServerID, server.Stop)
+	}
+
+	return server
+}
+
+// Start starts the gRPC server and begins accepting connections
+func (s *ChatServer) Start() error {
+	if s.messageLog != nil && s.replayLog {
+		if _, err := s.Rebuild(context.Background()); err != nil {
+			return err
+		}
+	}
+	if err := s.recoverOutbox(); err != nil {
+		return err
+	}
+
+	listener := s.listener
+	if listener == nil {
+		var err error
+		listener, err = net.Listen("tcp", fmt.Sprintf(":%d", s.port))
+		if err != nil {
+			return fmt.Errorf("failed to listen on port %d: %w", s.port, err)
+		}
+	}
+
+	opts := append([]grpc.ServerOption{tracing.ServerOption(), requestid.ServerOption(),
+		grpc.ChainUnaryInterceptor(s.slowRequestInterceptor, s.peerAuthInterceptor),
+		grpc.ChainStreamInterceptor(s.peerStreamAuthInterceptor)},
+		chaos.ServerOptions(s.chaos, s.serverID)...)
+	if s.tls != nil {
+		opts = append(opts, grpc.Creds(s.tls.ServerCredentials()))
+	}
+	s.grpcServer = grpc.NewServer(opts...)
+	pb.RegisterChatServiceServer(s.grpcServer, s)
+	pb.RegisterMigrationServiceServer(s.grpcServer, NewMigrationServer(s))
+	pb.RegisterPeerServiceServer(s.grpcServer, NewPeerServer(s))
+	if s.gossip != nil {
+		pb.RegisterGossipServiceServer(s.grpcServer, gossip.NewGRPCService(s.gossip))
+	}
+
+	info := s.cache.GetCacheInfo()
+	s.log.Info("Starting gRPC server", "address", s.address,
+		"l1_capacity", info.L1Capacity, "l2_capacity", info.L2Capacity)
+
+	go func() {
+		if err := s.grpcServer.Serve(listener); err != nil {
+			s.log.Error("gRPC server error", logging.Err(err))
+		}
+	}()
+
+	if s.adminPort > 0 || s.adminListener != nil {
+		if err := s.startAdmin(); err != nil {
+			s.grpcServer.Stop()
+			return err
+		}
+	}
+
+	if s.metadataConfig != nil {
+		if err := s.startMetadata(); err != nil {
+			s.grpcServer.Stop()
+			if s.adminServer != nil {
+				s.adminServer.Stop()
+			}
+			return err
+		}
+	}
+
+	if s.gossip != nil {
+		if len(s.gossipSeeds) > 0 {
+			if err := s.gossip.Join(s.gossipSeeds); err != nil {
+				s.log.Warn("Gossip join failed", logging.Err(err))
+			}
+		}
+		s.gossip.Start()
+	}
+
+	if s.archive != nil {
+		go s.archiveLoop()
+	}
+	if s.retention != nil {
+		go s.retentionLoop()
+	}
+	go s.presenceLoop()
+	go s.cursorLoop()
+	go s.dedupLoop()
+	go s.outboxLoop()
+	if s.metricHistory != nil {
+		go s.sampleMetricsLoop()
+	}
+
+	// Without metadata there is no leader to elect; this server aggregates
+	if s.aggregateStats && s.metadataConfig == nil {
+		go s.runAggregator(s.lifetime)
+	}
+	if s.metricsPort > 0 {
+		if err := s.startMetrics(); err != nil {
+			s.Stop()
+			return err
+		}
+	}
+
+	if s.coordinatorAddress != "" {
+		if err := s.joinCoordinator(); err != nil {
+			s.Stop()
+			return err
+		}
+	}
+
+	s.resumePurges()
+	return nil
+}
+
+// Stop gracefully stops the server, waiting for in-flight requests
+func (s *ChatServer) Stop() {
+	s.Shutdown(context.Background())
+}
+
+// Shutdown stops the server like Stop, but once ctx ends it stops waiting
+// for in-flight requests: their connections are closed and the work they
+// left running (replication, read repair, archiving) is cancelled. It
+// returns ctx's error if the stop wasn't graceful.
+func (s *ChatServer) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	select {
+	case <-s.shutdownCh:
+		return nil // already stopped
+	default:
+	}
+
+	s.healthy.Store(false)
+
+	// Leave the ring before draining so clients stop routing here
+	s.leaveCoordinator(ctx)
+
+	// Signal long-lived streams first so GracefulStop doesn't wait on them
+	close(s.shutdownCh)
+	s.hub.Close()
+	if s.searchEvictions != nil {
+		s.searchEvictions.Close()
+	}
+
+	if s.gossip != nil {
+		s.gossip.Leave()
+		s.gossipTransport.Close()
+	}
+
+	if s.election != nil {
+		s.election.Stop()
+	}
+	if s.metadata != nil {
+		if err := s.metadata.Close(); err != nil {
+			s.log.Warn("Metadata shutdown error", logging.Err(err))
+		}
+	}
+
+	graceful := true
+	if s.grpcServer != nil {
+		s.log.Info("Shutting down")
+		graceful = gracefulStop(ctx, s.grpcServer)
+	}
+	if s.adminServer != nil {
+		graceful = gracefulStop(ctx, s.adminServer) && graceful
+	}
+	if s.metricsServer != nil {
+		s.metricsServer.Close()
+	}
+	if s.notifier != nil {
+		graceful = s.notifier.Close(ctx) == nil && graceful
+	}
+	s.endLifetime()
+	s.closePeers()
+
+	if !graceful {
+		s.log.Warn("Server stopped before in-flight requests finished", logging.Err(ctx.Err()))
+		return ctx.Err()
+	}
+	s.log.Info("Server stopped")
+	return nil
+}
+
+// gracefulStop stops srv gracefully, or abruptly once ctx ends. It reports
+// whether the stop was graceful.
+func gracefulStop(ctx context.Context, srv *grpc.Server) bool {
+	done := make(chan struct{})
+	go func() {
+		srv.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		srv.Stop()
+		<-done
+		return false
+	}
+}
+
+// PostMessage handles incoming chat messages
+func (s *ChatServer) PostMessage(ctx context.Context, req *pb.ChatRequest) (resp *pb.ChatResponse, err error) {
+	s.inFlight.Add(1)
+	defer func(start time.Time) {
+		s.inFlight.Add(-1)
+		s.observeRequest("PostMessage", req.Namespace, resp.GetErrorCode(), start)
+	}(time.Now())
+	defer func() {
+		if resp != nil {
+			resp.RequestId = requestid.FromContext(ctx)
+		}
+	}()
+	ctx = logging.With(ctx, logging.ChatID(req.ChatId))
+
+	if err := s.checkAccepting(); err != nil {
+		return s.rejectResponse(err), nil
+	}
+	if err := s.checkOwnership(req); err != nil {
+		return s.rejectResponse(err), nil
+	}
+	if err := s.checkLease(req.ChatId); err != nil {
+		return s.errorResponse(pb.ErrorCode_ERROR_NO_LEASE, err.Error()), nil
+	}
+
+	// Convert the request body to a cache message
+	msg, err := messageFromRequest(req)
+	if err != nil {
+		return s.errorResponse(pb.ErrorCode_ERROR_VALIDATION_FAILED, err.Error()), nil
+	}
+
+	ctx, principal, err := s.authenticate(ctx, "PostMessage", req.Namespace)
+	if err != nil {
+		return s.rejectResponse(err), nil
+	}
+	if err := s.authorizeWrite(ctx, principal, req, msg); err != nil {
+		return s.rejectResponse(err), nil
+	}
+
+	if err := s.checkSender(ctx, req); err != nil {
+		return s.rejectResponse(err), nil
+	}
+	if err := s.screenMessage(ctx, req, principal, msg); err != nil {
+		return s.rejectResponse(err), nil
+	}
+	if err := s.transformMessage(ctx, req, principal, &msg); err != nil {
+		return s.rejectResponse(err), nil
+	}
+
+	s.log.DebugContext(ctx, "Received message", "type", msg.Type.String(),
+		"content", truncateString(msg.Content, 50))
+
+	if msg.ID == "" {
+		msg.ID = s.nextMessageID()
+	} else if entry, ok := s.storedBefore(ctx, req.ChatId, msg.ID); ok {
+		s.log.InfoContext(ctx, "Message stored before and since dropped from the cache, not adding it again",
+			"message_id", entry.MessageID, "seq", entry.Seq)
+		return s.duplicateResponse(entry), nil
+	}
+	msg.HLC = s.clock.Now()
+	msg.Origin = s.serverID
+	stored, session, level, err := s.cache.AppendMessageContext(ctx, req.ChatId, msg)
+	duplicate := errors.Is(err, cache.ErrDuplicateMessage)
+	if err != nil && !duplicate {
+		return s.errorResponse(pb.ErrorCode_ERROR_INTERNAL, err.Error()), nil
+	}
+	if !duplicate {
+		if err := s.commitMessage(ctx, req.ChatId, stored); err != nil {
+			return s.errorResponse(pb.ErrorCode_ERROR_INTERNAL, err.Error()), nil
+		}
+		s.indexMessage(req.ChatId, stored)
+	}
+	if duplicate {
+		s.log.InfoContext(ctx, "Message already stored, not adding it again",
+			"message_id", stored.ID, "seq", stored.Seq)
+	}
+	// A duplicate is recorded again, in case the first attempt failed to
+	if err := s.rememberMessage(ctx, req.ChatId, stored); err != nil {
+		return s.errorResponse(pb.ErrorCode_ERROR_INTERNAL, err.Error()), nil
+	}
+
+	// The local copy counts toward the write quorum. A duplicate is sent
+	// to the replicas again, since the first attempt may have fallen short.
+	w := s.required(req.Consistency, s.replication.W)
+	acks := 1 + s.replicate(ctx, req.ChatId, stored, w-1)
+	if acks < w {
+		return s.errorResponse(pb.ErrorCode_ERROR_QUORUM_FAILED,
+			fmt.Sprintf("%d of %d required replicas acknowledged message %s",
+				acks, w, stored.ID)), nil
+	}
+
+	// Convert cache level to proto enum
+	var cacheLocation pb.CacheLocation
+	switch level {
+	case cache.LevelL1:
+		cacheLocation = pb.CacheLocation_CACHE_L1
+	case cache.LevelL2:
+		cacheLocation = pb.CacheLocation_CACHE_L2
+	case cache.LevelMiss:
+		cacheLocation = pb.CacheLocation_CACHE_MISS
+	case cache.LevelArchive:
+		cacheLocation = pb.CacheLocation_CACHE_ARCHIVE
+	default:
+		cacheLocation = pb.CacheLocation_CACHE_UNKNOWN
+	}
+
+	s.log.DebugContext(ctx, "Processed message", "message_id", stored.ID,
+		"cache_level", level.Label(), "messages", session.MessageCount)
+
+	return &pb.ChatResponse{
+		Success:       true,
+		ServerId:      s.serverID,
+		CacheLocation: cacheLocation,
+		MessageCount:  int32(session.MessageCount),
+		RingEpoch:     s.ring.Epoch(),
+		MessageId:     stored.ID,
+		Seq:           stored.Seq,
+		ReplicasAcked: int32(acks),
+		Duplicate:     duplicate,
+	}, nil
+}
+
+// errorResponse builds a failed ChatResponse with a structured error code
+func (s *ChatServer) errorResponse(code pb.ErrorCode, details string) *pb.ChatResponse {
+	return &pb.ChatResponse{
+		Success:      false,
+		ServerId:     s.serverID,
+		ErrorCode:    code,
+		ErrorDetails: details,
+		RingEpoch:    s.ring.Epoch(),
+	}
+}
+
+// rejectResponse builds a failed ChatResponse for an error wrapping one of
+// the chaterr sentinels, with the sentinel's code
+func (s *ChatServer) rejectResponse(err error) *pb.ChatResponse {
+	return s.errorResponse(chaterr.Code(err, pb.ErrorCode_ERROR_INTERNAL), err.Error())
+}
+
+// checkAccepting returns an error wrapping chaterr.ErrDraining once the
+// server is draining or shutting down
+func (s *ChatServer) checkAccepting() error {
+	if !s.healthy.Load() {
+		return fmt.Errorf("%w: server is shutting down", chaterr.ErrDraining)
+	}
+	if s.draining.Load() {
+		return fmt.Errorf("%w: server is draining", chaterr.ErrDraining)
+	}
+	return nil
+}
+
+// GetCacheStats returns current cache statistics
+func (s *ChatServer) GetCacheStats(ctx context.Context, req *pb.StatsRequest) (*pb.StatsResponse, error) {
+	info := s.cache.GetCacheInfo()
+
+	return &pb.StatsResponse{
+		ServerId:      s.serverID,
+		L1Size:        int32(info.L1Size),
+		L1Capacity:    int32(info.L1Capacity),
+		L2Size:        int32(info.L2Size),
+		L2Capacity:    int32(info.L2Capacity),
+		TotalRequests: info.Stats.TotalRequests,
+		CacheHits:     info.Stats.CacheHits,
+		CacheMisses:   info.Stats.CacheMisses,
+		L1Chats:       info.L1Chats,
+		L2Chats:       info.L2Chats,
+	}, nil
+}
+
+// HealthCheck verifies the server is alive
+func (s *ChatServer) HealthCheck(ctx context.Context, req *pb.HealthRequest) (*pb.HealthResponse, error) {
+	return &pb.HealthResponse{
+		Healthy:       s.healthy.Load(),
+		ServerId:      s.serverID,
+		UptimeSeconds: int64(s.uptime().Seconds()),
+	}, nil
+}
+
+// GetServerID returns the server's ID
+func (s *ChatServer) GetServerID() string {
+	return s.serverID
+}
+
+// GetAddress returns the server's network address
+func (s *ChatServer) GetAddress() string {
+	return s.address
+}
+
+// GetPort returns the server's port
+func (s *ChatServer) GetPort() int {
+	return s.port
+}
+
+// IsHealthy returns whether the server is accepting requests
+func (s *ChatServer) IsHealthy() bool {
+	return s.healthy.Load()
+}
+
+// IsDraining returns whether the server has been asked to stop taking new messages
+func (s *ChatServer) IsDraining() bool {
+	return s.draining.Load()
+}
+
+// Members returns the server's gossip view of cluster membership
+// (nil when gossip is disabled)
+func (s *ChatServer) Members() []gossip.Member {
+	if s.gossip == nil {
+		return nil
+	}
+	return s.gossip.Members()
+}
+
+// uptime returns how long the server has been running
+func (s *ChatServer) uptime() time.Duration {
+	return s.wall.Now().Sub(s.startTime)
+}
+
+// GetCacheInfo returns detailed cache information
+func (s *ChatServer) GetCacheInfo() cache.CacheInfo {
+	return s.cache.GetCacheInfo()
+}
+
+// DebugPrint prints the current server and cache state
+func (s *ChatServer) DebugPrint() {
+	fmt.Printf("\n=== Server %s ===\n", s.serverID)
+	fmt.Printf("Address: %s\n", s.address)
+	fmt.Printf("Healthy: %v\n", s.healthy.Load())
+	fmt.Printf("Draining: %v\n", s.draining.Load())
+	fmt.Printf("Uptime: %v\n", s.uptime())
+	s.cache.DebugPrint()
+}
+
+// maxMessageIDLength bounds client-assigned message IDs
+const maxMessageIDLength = 128
+
+// messageFromRequest converts the oneof content of a ChatRequest into a
+// cache message. Non-text content gets a short textual summary in Content
+// so logs and text-only consumers still have something to show.
+func messageFromRequest(req *pb.ChatRequest) (cache.Message, error) {
+	msg := cache.Message{
+		ID:          req.MessageId,
+		SenderID:    req.SenderId,
+		Timestamp:   time.Unix(req.Timestamp, 0),
+		Federated:   req.FederatedFrom,
+		Annotations: req.Annotations,
+	}
+	if len(req.MessageId) > maxMessageIDLength {
+		return msg, fmt.Errorf("message_id is longer than %d bytes", maxMessageIDLength)
+	}
+
+	switch content := req.Content.(type) {
+	case *pb.ChatRequest_Text:
+		msg.Type = cache.ContentText
+		msg.Content = content.Text
+	case *pb.ChatRequest_Attachment:
+		if content.Attachment == nil || content.Attachment.Url == "" {
+			return msg, fmt.Errorf("attachment requires a url")
+		}
+		att := content.Attachment
+		msg.Type = cache.ContentAttachment
+		msg.Attachment = &cache.Attachment{
+			URL:       att.Url,
+			MimeType:  att.MimeType,
+			SizeBytes: att.SizeBytes,
+			Filename:  att.Filename,
+		}
+		msg.Content = fmt.Sprintf("[attachment %s %s, %d bytes]", att.MimeType, att.Url, att.SizeBytes)
+	case *pb.ChatRequest_SystemEvent:
+		if content.SystemEvent == nil {
+			return msg, fmt.Errorf("system event is empty")
+		}
+		event := content.SystemEvent
+		msg.Type = cache.ContentSystemEvent
+		msg.Event = &cache.SystemEvent{
+			Type:    event.Type.String(),
+			ActorID: event.ActorId,
+			Details: event.Details,
+		}
+		msg.Content = fmt.Sprintf("[event %s by %s]", event.Type, event.ActorId)
+	default:
+		return msg, fmt.Errorf("message content is required")
+	}
+
+	return msg, nil
+}
+
+// truncateString truncates a string to maxLen characters
+func truncateString(s string, maxLen int) string {
+	if len(s) <= maxLen {
+		return s
+	}
+	return s[:maxLen] + "..."
+}
@@ -0,0 +1,72 @@
+package server
+
+import (
+	"context"
+	"path"
+	"time"
+
+	"github.com/sh4shv4t/DistriChat/pkg/cache"
+	"github.com/sh4shv4t/DistriChat/pkg/logging"
+	pb "github.com/sh4shv4t/DistriChat/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// defaultSlowRequestThreshold is how long a request may take before it is
+// reported as slow
+const defaultSlowRequestThreshold = 500 * time.Millisecond
+
+// slowRequestInterceptor times every unary call on the chat port and
+// reports those taking longer than the slow request threshold, with what
+// the cache did for them
+func (s *ChatServer) slowRequestInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler) (any, error) {
+	if s.slowThreshold <= 0 {
+		return handler(ctx, req)
+	}
+
+	ctx, trace := cache.WithTrace(ctx)
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	if elapsed := time.Since(start); elapsed >= s.slowThreshold {
+		s.reportSlowRequest(ctx, path.Base(info.FullMethod), req, resp, err, elapsed, trace)
+	}
+	return resp, err
+}
+
+// reportSlowRequest logs a slow request and counts it
+func (s *ChatServer) reportSlowRequest(ctx context.Context, method string, req, resp any, err error,
+	elapsed time.Duration, trace *cache.Trace) {
+	s.slowRequests.Add(1)
+	s.metrics.slowRequests.With(method).Inc()
+
+	attrs := []any{
+		"method", method,
+		"duration", elapsed,
+		"threshold", s.slowThreshold,
+		"cache_path", trace.Path(),
+		"lock_wait", trace.LockWait(),
+		"in_flight", s.inFlight.Load(),
+	}
+	if chatID := requestChatID(req); chatID != "" {
+		attrs = append(attrs, logging.ChatID(chatID))
+	}
+	if r, ok := resp.(interface{ GetErrorCode() pb.ErrorCode }); ok && r.GetErrorCode() != pb.ErrorCode_ERROR_NONE {
+		attrs = append(attrs, "error_code", r.GetErrorCode().String())
+	}
+	if err != nil {
+		attrs = append(attrs, "grpc_code", status.Code(err).String(), logging.Err(err))
+	}
+	s.log.WarnContext(ctx, "Slow request", attrs...)
+}
+
+// requestChatID returns the chat a request is about, or ""
+func requestChatID(req any) string {
+	switch r := req.(type) {
+	case interface{ GetChatId() string }:
+		return r.GetChatId()
+	case *pb.ReplicateRequest:
+		return r.GetMessage().GetRequest().GetChatId()
+	}
+	return ""
+}
@@ -0,0 +1,148 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/sh4shv4t/DistriChat/internal/clusterstats"
+	"github.com/sh4shv4t/DistriChat/pkg/logging"
+	"github.com/sh4shv4t/DistriChat/pkg/metrics"
+	"github.com/sh4shv4t/DistriChat/pkg/ring"
+	pb "github.com/sh4shv4t/DistriChat/proto"
+)
+
+// runAggregator collects every ring member's statistics each statsInterval
+// until ctx is cancelled. With metadata it runs as an elected duty on the
+// leader.
+func (s *ChatServer) runAggregator(ctx context.Context) {
+	fetcher := clusterstats.NewGRPCFetcher(s.peerCredentials())
+	defer fetcher.Close()
+
+	aggregator := clusterstats.New(clusterstats.Config{Interval: s.statsInterval}, s.statsMembers, fetcher)
+
+	s.aggregatorMu.Lock()
+	s.aggregator = aggregator
+	s.aggregatorMu.Unlock()
+	defer func() {
+		s.aggregatorMu.Lock()
+		s.aggregator = nil
+		s.aggregatorMu.Unlock()
+	}()
+
+	s.log.Info("Aggregating cluster stats", "interval", s.statsInterval)
+	aggregator.Run(ctx)
+}
+
+// statsMembers returns the servers to collect from: the ring's members,
+// or just this server before it has a ring view
+func (s *ChatServer) statsMembers() []ring.NodeSpec {
+	if nodes := s.ring.State().Nodes; len(nodes) > 0 {
+		return nodes
+	}
+	return []ring.NodeSpec{{NodeID: s.serverID, Address: s.address}}
+}
+
+// currentAggregator returns the aggregator if this server runs it
+func (s *ChatServer) currentAggregator() *clusterstats.Aggregator {
+	s.aggregatorMu.Lock()
+	defer s.aggregatorMu.Unlock()
+	return s.aggregator
+}
+
+// startMetrics serves /metrics and /debug/vars, and /graphql if enabled,
+// on metricsPort. Every
+// server answers, but only the one running the aggregator reports cluster
+// series, so scraping all of them yields exactly one copy.
+func (s *ChatServer) startMetrics() error {
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", s.metricsPort))
+	if err != nil {
+		return fmt.Errorf("failed to listen on metrics port %d: %w", s.metricsPort, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", s.serveMetrics)
+	mux.Handle("/debug/vars", s.VarsHandler())
+	if s.graphQL {
+		mux.Handle("/graphql", s.GraphQLHandler())
+	}
+	s.metricsServer = &http.Server{Handler: mux}
+
+	s.log.Info("Serving metrics", "port", s.metricsPort)
+	go func() {
+		if err := s.metricsServer.Serve(listener); err != nil && err != http.ErrServerClosed {
+			s.log.Error("Metrics server error", logging.Err(err))
+		}
+	}()
+	return nil
+}
+
+// serveMetrics writes the server's own series, then the aggregated cluster
+// statistics if this server runs the aggregator
+func (s *ChatServer) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	if registry, ok := s.registry.(*metrics.Prometheus); ok {
+		if err := registry.Write(w); err != nil {
+			s.log.Warn("Failed to write metrics", logging.Err(err))
+		}
+	}
+
+	aggregator := s.currentAggregator()
+	active := 0
+	if aggregator != nil {
+		active = 1
+	}
+	fmt.Fprintf(w, "# HELP districhat_aggregator_active Whether this server aggregates cluster stats\n")
+	fmt.Fprintf(w, "# TYPE districhat_aggregator_active gauge\n")
+	fmt.Fprintf(w, "districhat_aggregator_active{server=%q} %d\n", s.serverID, active)
+
+	if aggregator != nil {
+		clusterstats.WriteMetrics(w, aggregator.Summary())
+	}
+}
+
+// clusterStats converts the aggregator's latest summary to its wire form
+func (s *ChatServer) clusterStats() *pb.ClusterStats {
+	aggregator := s.currentAggregator()
+	if aggregator == nil {
+		return &pb.ClusterStats{}
+	}
+
+	summary := aggregator.Summary()
+	stats := &pb.ClusterStats{
+		Active:            true,
+		Servers:           int32(summary.Servers),
+		Reachable:         int32(summary.Reachable),
+		L1Size:            int32(summary.L1Size),
+		L1Capacity:        int32(summary.L1Capacity),
+		L2Size:            int32(summary.L2Size),
+		L2Capacity:        int32(summary.L2Capacity),
+		TotalRequests:     summary.TotalRequests,
+		CacheHits:         summary.CacheHits,
+		CacheMisses:       summary.CacheMisses,
+		HitRatio:          summary.HitRatio(),
+		RequestsPerSecond: summary.RequestsPerSecond,
+	}
+	if !summary.CollectedAt.IsZero() {
+		stats.CollectedAt = summary.CollectedAt.Unix()
+	}
+	for _, server := range summary.PerServer {
+		stats.PerServer = append(stats.PerServer, &pb.ServerStatsSummary{
+			ServerId:          server.NodeID,
+			Address:           server.Address,
+			Reachable:         server.Reachable,
+			Error:             server.Error,
+			L1Size:            int32(server.L1Size),
+			L1Capacity:        int32(server.L1Capacity),
+			L2Size:            int32(server.L2Size),
+			L2Capacity:        int32(server.L2Capacity),
+			TotalRequests:     server.TotalRequests,
+			CacheHits:         server.CacheHits,
+			CacheMisses:       server.CacheMisses,
+			RequestsPerSecond: server.RequestsPerSecond,
+		})
+	}
+	return stats
+}
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sh4shv4t/DistriChat/pkg/auth"
+	"github.com/sh4shv4t/DistriChat/pkg/cache"
+	"github.com/sh4shv4t/DistriChat/pkg/chaterr"
+	"github.com/sh4shv4t/DistriChat/pkg/logging"
+	"github.com/sh4shv4t/DistriChat/pkg/transform"
+	pb "github.com/sh4shv4t/DistriChat/proto"
+)
+
+// transformMessage runs a posted text message through the server's
+// transform stages, rewriting and annotating msg, and returns an error
+// wrapping chaterr.ErrPolicyRejected if a stage refuses it. Annotations the
+// client sent are dropped first. Every stage's action is counted, and
+// failures and rejections logged.
+func (s *ChatServer) transformMessage(ctx context.Context, req *pb.ChatRequest, p auth.Principal, msg *cache.Message) error {
+	if req.FederatedFrom != "" {
+		return nil
+	}
+	msg.Annotations = nil
+	if len(s.transforms) == 0 || msg.Type != cache.ContentText {
+		return nil
+	}
+
+	tm := transform.Message{
+		ChatID:    req.ChatId,
+		Namespace: req.Namespace,
+		SenderID:  req.SenderId,
+		Principal: p.ID,
+		Timestamp: msg.Timestamp,
+		Text:      msg.Content,
+	}
+	outcomes, err := transform.Apply(ctx, s.transforms, &tm)
+	for _, o := range outcomes {
+		s.metrics.transformActions.With(o.Stage, o.Action.String()).Inc()
+		switch o.Action {
+		case transform.Fail:
+			s.log.WarnContext(ctx, "Transform stage failed, skipping it", "stage", o.Stage, logging.Err(o.Err))
+		case transform.Reject:
+			s.log.WarnContext(ctx, "Transform stage rejected message", "stage", o.Stage,
+				"sender_id", req.SenderId, "reason", o.Err.Error())
+		}
+	}
+	if err != nil {
+		s.policyRejected.Add(1)
+		return fmt.Errorf("%w: %v", chaterr.ErrPolicyRejected, err)
+	}
+
+	msg.Content = tm.Text
+	msg.Annotations = tm.Annotations
+	return nil
+}
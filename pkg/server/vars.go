@@ -0,0 +1,69 @@
+package server
+
+import (
+	"expvar"
+	"fmt"
+	"net/http"
+)
+
+// newVars returns the server's live values for /debug/vars. They are kept
+// in a map of the server's own rather than published to expvar's global
+// one, so several servers can run in one process.
+func (s *ChatServer) newVars() *expvar.Map {
+	vars := new(expvar.Map).Init()
+	vars.Set("server_id", expvar.Func(func() any { return s.serverID }))
+	vars.Set("namespace", expvar.Func(func() any { return s.namespace }))
+	vars.Set("ring_epoch", expvar.Func(func() any { return s.ring.Epoch() }))
+	vars.Set("ring_nodes", expvar.Func(func() any { return len(s.ring.GetAllNodes()) }))
+	vars.Set("cache", expvar.Func(func() any {
+		info := s.cache.GetCacheInfo()
+		return map[string]int{
+			"l1_sessions": info.L1Size,
+			"l1_capacity": info.L1Capacity,
+			"l2_sessions": info.L2Size,
+			"l2_capacity": info.L2Capacity,
+		}
+	}))
+	vars.Set("in_flight", expvar.Func(func() any { return s.inFlight.Load() }))
+	vars.Set("stale_reads", expvar.Func(func() any { return s.staleReads.Load() }))
+	vars.Set("slow_requests", expvar.Func(func() any { return s.slowRequests.Load() }))
+	vars.Set("rate_limited", expvar.Func(func() any { return s.rateLimited.Load() }))
+	vars.Set("policy_flagged", expvar.Func(func() any { return s.policyFlagged.Load() }))
+	vars.Set("policy_rejected", expvar.Func(func() any { return s.policyRejected.Load() }))
+	vars.Set("subscribers", expvar.Func(func() any {
+		counts := make(map[string]int)
+		for kind, n := range s.hub.Stats().Subscribers {
+			counts[kind.String()] = n
+		}
+		return counts
+	}))
+	vars.Set("notifications_sent", expvar.Func(func() any { return s.notificationsSent.Load() }))
+	vars.Set("notifications_failed", expvar.Func(func() any { return s.notificationsFailed.Load() }))
+	vars.Set("users_online", expvar.Func(func() any { return s.presence.Online() }))
+	vars.Set("indexed_messages", expvar.Func(func() any { return s.indexedMessages() }))
+	vars.Set("messages_purged", expvar.Func(func() any { return s.messagesPurged.Load() }))
+	vars.Set("ring_conflicts", expvar.Func(func() any { return s.ringConflicts.Load() }))
+	vars.Set("healthy", expvar.Func(func() any { return s.healthy.Load() }))
+	vars.Set("draining", expvar.Func(func() any { return s.draining.Load() }))
+	return vars
+}
+
+// VarsHandler returns an HTTP handler serving the metrics port's
+// /debug/vars, for embedders serving it on a mux of their own
+func (s *ChatServer) VarsHandler() http.Handler {
+	return http.HandlerFunc(s.serveVars)
+}
+
+// serveVars writes expvar's JSON: the process-wide variables (cmdline,
+// memstats and anything the application published) plus this server's
+// under "districhat"
+func (s *ChatServer) serveVars(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+	fmt.Fprintf(w, "{\n")
+	expvar.Do(func(kv expvar.KeyValue) {
+		fmt.Fprintf(w, "%q: %s,\n", kv.Key, kv.Value)
+	})
+	fmt.Fprintf(w, "%q: %s\n", "districhat", s.vars)
+	fmt.Fprintf(w, "}\n")
+}
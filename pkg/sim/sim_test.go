@@ -0,0 +1,28 @@
+package sim
+
+import "testing"
+
+func TestRandIsReproducible(t *testing.T) {
+	a, b := New(42).Rand("client"), New(42).Rand("client")
+	for i := 0; i < 10; i++ {
+		if x, y := a.Uint64(), b.Uint64(); x != y {
+			t.Fatalf("Expected the same draw from the same seed, got %d and %d", x, y)
+		}
+	}
+}
+
+func TestRandStreamsDiffer(t *testing.T) {
+	s := New(42)
+	if a, b := s.Rand("client").Uint64(), s.Rand("chaos").Uint64(); a == b {
+		t.Errorf("Expected distinct streams per name, got %d twice", a)
+	}
+	if a, b := New(1).Rand("client").Uint64(), New(2).Rand("client").Uint64(); a == b {
+		t.Errorf("Expected distinct streams per seed, got %d twice", a)
+	}
+}
+
+func TestClockStartsAtStart(t *testing.T) {
+	if got := New(1).Clock.Now(); !got.Equal(Start) {
+		t.Errorf("Expected %v, got %v", Start, got)
+	}
+}
@@ -0,0 +1,109 @@
+// Package tracing wires DistriChat into OpenTelemetry. Setup installs a
+// tracer provider exporting over OTLP/gRPC (to Jaeger, Tempo or a
+// collector) and the W3C trace context propagator; ServerOption and
+// DialOption make gRPC servers and connections start a span per RPC and
+// carry the trace context in request metadata, so one message's path from
+// client to owner to replicas shows up as a single trace.
+//
+// Without Setup, the global provider is OpenTelemetry's no-op one and every
+// span in the codebase costs next to nothing.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+)
+
+// Config contains configuration for trace export
+type Config struct {
+	// Service name spans are reported under (default: "districhat")
+	ServiceName string
+
+	// OTLP/gRPC endpoint spans are exported to, as host:port or a URL such
+	// as http://localhost:4317. Empty disables export; trace context is
+	// still propagated.
+	Endpoint string
+
+	// Fraction of new traces to sample, in (0, 1] (default: 1). Traces
+	// started elsewhere keep their caller's decision.
+	SampleRatio float64
+}
+
+// withDefaults fills in unset fields
+func (c Config) withDefaults() Config {
+	if c.ServiceName == "" {
+		c.ServiceName = "districhat"
+	}
+	if c.SampleRatio <= 0 || c.SampleRatio > 1 {
+		c.SampleRatio = 1
+	}
+	return c
+}
+
+// Setup installs the global tracer provider and propagator. The returned
+// function flushes pending spans and shuts the exporter down.
+func Setup(ctx context.Context, config Config) (func(context.Context) error, error) {
+	config = config.withDefaults()
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{}))
+
+	if config.Endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	endpoint := config.Endpoint
+	if u, err := url.Parse(endpoint); err == nil && u.Host != "" {
+		endpoint = u.Host
+	}
+	exporter, err := otlptracegrpc.New(ctx,
+		otlptracegrpc.WithEndpoint(endpoint),
+		otlptracegrpc.WithInsecure(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace exporter for %s: %w", config.Endpoint, err)
+	}
+
+	provider := NewProvider(config, sdktrace.WithBatcher(exporter))
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// NewProvider creates a tracer provider for config with the given extra
+// options (e.g. a span processor), without installing it
+func NewProvider(config Config, opts ...sdktrace.TracerProviderOption) *sdktrace.TracerProvider {
+	config = config.withDefaults()
+	opts = append([]sdktrace.TracerProviderOption{
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(config.ServiceName))),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.SampleRatio))),
+	}, opts...)
+	return sdktrace.NewTracerProvider(opts...)
+}
+
+// Tracer returns a tracer from the global provider for the named component.
+// It follows later calls to Setup.
+func Tracer(name string) trace.Tracer {
+	return otel.Tracer(name)
+}
+
+// ServerOption makes a gRPC server start a span per incoming RPC, continuing
+// the trace found in the request metadata
+func ServerOption() grpc.ServerOption {
+	return grpc.StatsHandler(otelgrpc.NewServerHandler())
+}
+
+// DialOption makes a gRPC connection start a span per outgoing RPC and
+// inject the trace context into the request metadata
+func DialOption() grpc.DialOption {
+	return grpc.WithStatsHandler(otelgrpc.NewClientHandler())
+}
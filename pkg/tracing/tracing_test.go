@@ -0,0 +1,86 @@
+package tracing
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	pb "github.com/sh4shv4t/DistriChat/proto"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// echoServer records the trace each PostMessage arrives in
+type echoServer struct {
+	pb.UnimplementedChatServiceServer
+	traceID trace.TraceID
+}
+
+func (e *echoServer) PostMessage(ctx context.Context, req *pb.ChatRequest) (*pb.ChatResponse, error) {
+	e.traceID = trace.SpanContextFromContext(ctx).TraceID()
+	return &pb.ChatResponse{Success: true}, nil
+}
+
+func TestTraceContextCrossesGRPC(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := NewProvider(Config{ServiceName: "test"}, sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+	defer otel.SetTracerProvider(trace.NewNoopTracerProvider())
+	if _, err := Setup(context.Background(), Config{}); err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	echo := &echoServer{}
+	server := grpc.NewServer(ServerOption())
+	pb.RegisterChatServiceServer(server, echo)
+	go server.Serve(listener)
+	defer server.Stop()
+
+	conn, err := grpc.Dial(listener.Addr().String(),
+		grpc.WithTransportCredentials(insecure.NewCredentials()), DialOption())
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+
+	ctx, span := Tracer("test").Start(context.Background(), "send")
+	_, err = pb.NewChatServiceClient(conn).PostMessage(ctx, &pb.ChatRequest{ChatId: "chat-1"})
+	span.End()
+	if err != nil {
+		t.Fatalf("PostMessage failed: %v", err)
+	}
+
+	if echo.traceID != span.SpanContext().TraceID() {
+		t.Errorf("Expected the server to continue trace %s, got %s", span.SpanContext().TraceID(), echo.traceID)
+	}
+
+	// The caller's span, the client RPC span and the server RPC span
+	server.Stop()
+	spans := recorder.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("Expected 3 spans, got %d", len(spans))
+	}
+	for _, s := range spans {
+		if s.SpanContext().TraceID() != span.SpanContext().TraceID() {
+			t.Errorf("Expected span %s in trace %s, got %s", s.Name(), span.SpanContext().TraceID(), s.SpanContext().TraceID())
+		}
+	}
+}
+
+func TestSetupWithoutEndpoint(t *testing.T) {
+	shutdown, err := Setup(context.Background(), Config{})
+	if err != nil {
+		t.Fatalf("Expected setup without an endpoint to succeed, got %v", err)
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Errorf("Expected no-op shutdown, got %v", err)
+	}
+}
@@ -0,0 +1,186 @@
+package transform
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaskWords replaces the words given, whole and in any case, with as many
+// asterisks, annotating the message with how many it masked ("masked")
+func MaskWords(words ...string) Stage {
+	quoted := make([]string, len(words))
+	for i, word := range words {
+		quoted[i] = regexp.QuoteMeta(word)
+	}
+	pattern := regexp.MustCompile(`(?i)\b(?:` + strings.Join(quoted, "|") + `)\b`)
+	return New("mask_words", func(ctx context.Context, msg *Message) error {
+		if len(words) == 0 {
+			return nil
+		}
+		masked := 0
+		msg.Text = pattern.ReplaceAllStringFunc(msg.Text, func(word string) string {
+			masked++
+			return strings.Repeat("*", utf8.RuneCountInString(word))
+		})
+		if masked > 0 {
+			msg.Annotate("masked", strconv.Itoa(masked))
+		}
+		return nil
+	})
+}
+
+var (
+	emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+	cardPattern  = regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`)
+	phonePattern = regexp.MustCompile(`\+?\d[\d ().-]{7,}\d`)
+)
+
+// RedactPII replaces email addresses, payment card numbers (passing the
+// Luhn check) and phone numbers (9 digits or more) with [email], [card] and
+// [phone], annotating the message with the kinds redacted ("redacted",
+// e.g. "card,email")
+func RedactPII() Stage {
+	return New("redact_pii", func(ctx context.Context, msg *Message) error {
+		found := make(map[string]bool)
+		redact := func(pattern *regexp.Regexp, kind string, valid func(string) bool) {
+			msg.Text = pattern.ReplaceAllStringFunc(msg.Text, func(match string) string {
+				if valid != nil && !valid(match) {
+					return match
+				}
+				found[kind] = true
+				return "[" + kind + "]"
+			})
+		}
+		redact(emailPattern, "email", nil)
+		redact(cardPattern, "card", luhn)
+		redact(phonePattern, "phone", func(match string) bool { return digits(match) >= 9 })
+
+		if len(found) > 0 {
+			kinds := make([]string, 0, len(found))
+			for kind := range found {
+				kinds = append(kinds, kind)
+			}
+			sort.Strings(kinds)
+			msg.Annotate("redacted", strings.Join(kinds, ","))
+		}
+		return nil
+	})
+}
+
+// digits counts the decimal digits in s
+func digits(s string) int {
+	n := 0
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			n++
+		}
+	}
+	return n
+}
+
+// luhn reports whether the digits in s pass the Luhn check card numbers
+// carry
+func luhn(s string) bool {
+	sum, double := 0, false
+	for i := len(s) - 1; i >= 0; i-- {
+		if s[i] < '0' || s[i] > '9' {
+			continue
+		}
+		d := int(s[i] - '0')
+		if double {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// DefaultEmoji maps common shortcodes and emoticons to the emoji
+// NormalizeEmoji replaces them with
+var DefaultEmoji = map[string]string{
+	":smile:":            "😄",
+	":grin:":             "😁",
+	":joy:":              "😂",
+	":wink:":             "😉",
+	":heart:":            "❤️",
+	":thumbsup:":         "👍",
+	":+1:":               "👍",
+	":thumbsdown:":       "👎",
+	":-1:":               "👎",
+	":fire:":             "🔥",
+	":tada:":             "🎉",
+	":thinking:":         "🤔",
+	":cry:":              "😢",
+	":eyes:":             "👀",
+	":wave:":             "👋",
+	":pray:":             "🙏",
+	":rocket:":           "🚀",
+	":white_check_mark:": "✅",
+	":)":                 "🙂",
+	":-)":                "🙂",
+	":(":                 "🙁",
+	":-(":                "🙁",
+	";)":                 "😉",
+}
+
+// NormalizeEmoji replaces shortcodes and emoticons with the emoji they
+// stand for, so every client shows messages alike (nil: DefaultEmoji).
+// Longer codes win over shorter ones they start with.
+func NormalizeEmoji(emoji map[string]string) Stage {
+	if emoji == nil {
+		emoji = DefaultEmoji
+	}
+	codes := make([]string, 0, len(emoji))
+	for code := range emoji {
+		codes = append(codes, code)
+	}
+	sort.Slice(codes, func(i, j int) bool {
+		if len(codes[i]) != len(codes[j]) {
+			return len(codes[i]) > len(codes[j])
+		}
+		return codes[i] < codes[j]
+	})
+	pairs := make([]string, 0, 2*len(codes))
+	for _, code := range codes {
+		pairs = append(pairs, code, emoji[code])
+	}
+	replacer := strings.NewReplacer(pairs...)
+	return New("normalize_emoji", func(ctx context.Context, msg *Message) error {
+		msg.Text = replacer.Replace(msg.Text)
+		return nil
+	})
+}
+
+// Translator translates text into the target language (e.g. "en"),
+// returning the translation and the language it detected the text in.
+// It is typically a call to a translation service.
+type Translator func(ctx context.Context, text, target string) (translation, source string, err error)
+
+// Translate annotates messages not in the target language with their
+// translation ("translation.<target>") and language ("lang"), keeping the
+// text as written. A translator failing leaves the message untranslated.
+func Translate(target string, translate Translator) Stage {
+	return New("translate", func(ctx context.Context, msg *Message) error {
+		if strings.TrimSpace(msg.Text) == "" {
+			return nil
+		}
+		translation, source, err := translate(ctx, msg.Text, target)
+		if err != nil {
+			return err
+		}
+		if source != "" {
+			msg.Annotate("lang", source)
+		}
+		if source != target && translation != "" && translation != msg.Text {
+			msg.Annotate("translation."+target, translation)
+		}
+		return nil
+	})
+}
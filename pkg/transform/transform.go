@@ -0,0 +1,171 @@
+// Package transform rewrites messages on a server's write path, before
+// they are stored and replicated. A server runs each text message through
+// its Stages in order; each may rewrite the text, annotate the message
+// (annotations are stored and replicated with it), or reject it, which
+// refuses it with ERROR_POLICY_REJECTED and skips the stages after.
+//
+//	srv := server.NewChatServer(server.ServerConfig{
+//		Transforms: []transform.Stage{
+//			transform.MaskWords("darn", "heck"),
+//			transform.RedactPII(),
+//			transform.NormalizeEmoji(nil),
+//			transform.Translate("en", translator),
+//		},
+//	})
+//
+// A stage that fails otherwise, such as a translation service that can't
+// be reached, is skipped: the message carries on as it was before it.
+package transform
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrRejected is wrapped by the errors of stages refusing a message
+var ErrRejected = errors.New("message rejected")
+
+// Refuse returns the error a stage refusing a message returns, giving why
+func Refuse(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", ErrRejected, fmt.Sprintf(format, args...))
+}
+
+// Message is a text message being posted, as stages see and change it
+type Message struct {
+	ChatID    string
+	Namespace string
+	SenderID  string
+	Principal string // Authenticated caller, if any
+	Timestamp time.Time
+
+	// Text is the message's text, rewritten by stages in turn
+	Text string
+
+	// Annotations are set by stages and kept with the message, e.g. the
+	// kinds of data redacted or a translation
+	Annotations map[string]string
+}
+
+// Annotate sets one of the message's annotations
+func (m *Message) Annotate(key, value string) {
+	if m.Annotations == nil {
+		m.Annotations = make(map[string]string)
+	}
+	m.Annotations[key] = value
+}
+
+// Stage is one step of a server's pipeline. Transform is called for every
+// text message a server is posted, concurrently, so it must be safe for
+// concurrent use; the message is the caller's alone.
+type Stage interface {
+	// Name labels the stage in logs and metrics
+	Name() string
+
+	// Transform changes msg in place, or returns an error wrapping
+	// ErrRejected to refuse it. Any other error skips the stage.
+	Transform(ctx context.Context, msg *Message) error
+}
+
+// New creates a stage named name calling f
+func New(name string, f func(ctx context.Context, msg *Message) error) Stage {
+	return funcStage{name: name, f: f}
+}
+
+// funcStage is the Stage New returns
+type funcStage struct {
+	name string
+	f    func(ctx context.Context, msg *Message) error
+}
+
+func (s funcStage) Name() string { return s.name }
+
+func (s funcStage) Transform(ctx context.Context, msg *Message) error { return s.f(ctx, msg) }
+
+// Action is what a stage did to a message
+type Action int
+
+const (
+	Pass     Action = iota // Left it as it was
+	Rewrite                // Changed its text, and maybe its annotations
+	Annotate               // Changed only its annotations
+	Reject                 // Refused it
+	Fail                   // Failed, and was skipped
+)
+
+// String returns the action's metric label
+func (a Action) String() string {
+	switch a {
+	case Pass:
+		return "pass"
+	case Rewrite:
+		return "rewrite"
+	case Annotate:
+		return "annotate"
+	case Reject:
+		return "reject"
+	case Fail:
+		return "fail"
+	default:
+		return "unknown"
+	}
+}
+
+// Outcome is what one stage did to a message
+type Outcome struct {
+	Stage  string
+	Action Action
+	Err    error // Why the stage rejected the message or failed
+}
+
+// Apply runs msg through stages in order, stopping at the first that
+// rejects it. A stage that fails leaves msg as it was before the stage. It
+// returns the outcome of every stage other than Pass, and the rejection,
+// if any, which wraps ErrRejected.
+func Apply(ctx context.Context, stages []Stage, msg *Message) (outcomes []Outcome, err error) {
+	for _, stage := range stages {
+		before := *msg
+		before.Annotations = copyAnnotations(msg.Annotations)
+
+		err := stage.Transform(ctx, msg)
+		switch {
+		case errors.Is(err, ErrRejected):
+			outcomes = append(outcomes, Outcome{Stage: stage.Name(), Action: Reject, Err: err})
+			return outcomes, fmt.Errorf("%s: %w", stage.Name(), err)
+		case err != nil:
+			*msg = before
+			outcomes = append(outcomes, Outcome{Stage: stage.Name(), Action: Fail, Err: err})
+		case msg.Text != before.Text:
+			outcomes = append(outcomes, Outcome{Stage: stage.Name(), Action: Rewrite})
+		case !sameAnnotations(msg.Annotations, before.Annotations):
+			outcomes = append(outcomes, Outcome{Stage: stage.Name(), Action: Annotate})
+		}
+	}
+	return outcomes, nil
+}
+
+// copyAnnotations copies a message's annotations
+func copyAnnotations(annotations map[string]string) map[string]string {
+	if annotations == nil {
+		return nil
+	}
+	out := make(map[string]string, len(annotations))
+	for k, v := range annotations {
+		out[k] = v
+	}
+	return out
+}
+
+// sameAnnotations reports whether a and b hold the same annotations
+func sameAnnotations(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if w, ok := b[k]; !ok || w != v {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,128 @@
+package transform
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestApply(t *testing.T) {
+	ctx := context.Background()
+	failing := New("flaky", func(ctx context.Context, msg *Message) error {
+		msg.Text = "half done"
+		msg.Annotate("flaky", "yes")
+		return errors.New("service unavailable")
+	})
+	tag := New("tag", func(ctx context.Context, msg *Message) error {
+		msg.Annotate("seen", "true")
+		return nil
+	})
+	shout := New("shout", func(ctx context.Context, msg *Message) error {
+		msg.Text += "!"
+		return nil
+	})
+
+	msg := &Message{Text: "hello"}
+	outcomes, err := Apply(ctx, []Stage{failing, tag, shout, New("noop", func(context.Context, *Message) error { return nil })}, msg)
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if msg.Text != "hello!" || msg.Annotations["seen"] != "true" || msg.Annotations["flaky"] != "" {
+		t.Errorf("Expected the failed stage undone and the others applied, got %+v", msg)
+	}
+	want := []Action{Fail, Annotate, Rewrite}
+	if len(outcomes) != len(want) {
+		t.Fatalf("Expected %d outcomes, got %+v", len(want), outcomes)
+	}
+	for i, action := range want {
+		if outcomes[i].Action != action {
+			t.Errorf("Expected %s from %s, got %s", action, outcomes[i].Stage, outcomes[i].Action)
+		}
+	}
+
+	refuse := New("refuse", func(ctx context.Context, msg *Message) error {
+		return Refuse("not today")
+	})
+	msg = &Message{Text: "hello"}
+	outcomes, err = Apply(ctx, []Stage{refuse, shout}, msg)
+	if !errors.Is(err, ErrRejected) || len(outcomes) != 1 || outcomes[0].Action != Reject {
+		t.Errorf("Expected a rejection by the first stage, got %v (%+v)", err, outcomes)
+	}
+	if msg.Text != "hello" {
+		t.Errorf("Expected the stages after a rejection skipped, got %q", msg.Text)
+	}
+}
+
+func TestStages(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name        string
+		stage       Stage
+		text        string
+		want        string
+		annotations map[string]string
+	}{
+		{
+			name:        "mask words",
+			stage:       MaskWords("darn", "heck"),
+			text:        "Darn it, what the heck? Darned.",
+			want:        "**** it, what the ****? Darned.",
+			annotations: map[string]string{"masked": "2"},
+		},
+		{
+			name:        "redact pii",
+			stage:       RedactPII(),
+			text:        "Mail bob@example.com or call +1 (555) 123-4567; card 4111 1111 1111 1111",
+			want:        "Mail [email] or call [phone]; card [card]",
+			annotations: map[string]string{"redacted": "card,email,phone"},
+		},
+		{
+			name:  "dates are not phones",
+			stage: RedactPII(),
+			text:  "Due 2024-01-01",
+			want:  "Due 2024-01-01",
+		},
+		{
+			name:  "normalize emoji",
+			stage: NormalizeEmoji(nil),
+			text:  "Ship it :rocket: :-) :+1:",
+			want:  "Ship it 🚀 🙂 👍",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := &Message{Text: tt.text}
+			if err := tt.stage.Transform(ctx, msg); err != nil {
+				t.Fatalf("Transform failed: %v", err)
+			}
+			if msg.Text != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, msg.Text)
+			}
+			if !sameAnnotations(msg.Annotations, tt.annotations) {
+				t.Errorf("Expected annotations %v, got %v", tt.annotations, msg.Annotations)
+			}
+		})
+	}
+}
+
+func TestTranslate(t *testing.T) {
+	translator := func(ctx context.Context, text, target string) (string, string, error) {
+		if text == "bonjour" {
+			return "hello", "fr", nil
+		}
+		return text, "en", nil
+	}
+	stage := Translate("en", translator)
+
+	msg := &Message{Text: "bonjour"}
+	stage.Transform(context.Background(), msg)
+	if msg.Text != "bonjour" || msg.Annotations["lang"] != "fr" || msg.Annotations["translation.en"] != "hello" {
+		t.Errorf("Expected the text kept with its translation, got %+v", msg)
+	}
+
+	msg = &Message{Text: "hello"}
+	stage.Transform(context.Background(), msg)
+	if _, ok := msg.Annotations["translation.en"]; ok || msg.Annotations["lang"] != "en" {
+		t.Errorf("Expected no translation of a message in the target language, got %v", msg.Annotations)
+	}
+}
@@ -0,0 +1,910 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             v4.25.1
+// source: proto/admin.proto
+
+package proto
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	AdminService_GetTopology_FullMethodName        = "/chat.AdminService/GetTopology"
+	AdminService_Drain_FullMethodName              = "/chat.AdminService/Drain"
+	AdminService_Decommission_FullMethodName       = "/chat.AdminService/Decommission"
+	AdminService_ClearCache_FullMethodName         = "/chat.AdminService/ClearCache"
+	AdminService_ReloadConfig_FullMethodName       = "/chat.AdminService/ReloadConfig"
+	AdminService_GetStatsSnapshot_FullMethodName   = "/chat.AdminService/GetStatsSnapshot"
+	AdminService_SubscribeStats_FullMethodName     = "/chat.AdminService/SubscribeStats"
+	AdminService_GetRebalanceStatus_FullMethodName = "/chat.AdminService/GetRebalanceStatus"
+	AdminService_SetRebalanceRate_FullMethodName   = "/chat.AdminService/SetRebalanceRate"
+	AdminService_GetClusterStats_FullMethodName    = "/chat.AdminService/GetClusterStats"
+	AdminService_GetLogLevel_FullMethodName        = "/chat.AdminService/GetLogLevel"
+	AdminService_SetLogLevel_FullMethodName        = "/chat.AdminService/SetLogLevel"
+	AdminService_QueryAuditLog_FullMethodName      = "/chat.AdminService/QueryAuditLog"
+	AdminService_GetMetricHistory_FullMethodName   = "/chat.AdminService/GetMetricHistory"
+	AdminService_DebugState_FullMethodName         = "/chat.AdminService/DebugState"
+	AdminService_CreateAPIKey_FullMethodName       = "/chat.AdminService/CreateAPIKey"
+	AdminService_RevokeAPIKey_FullMethodName       = "/chat.AdminService/RevokeAPIKey"
+	AdminService_ListAPIKeys_FullMethodName        = "/chat.AdminService/ListAPIKeys"
+	AdminService_PurgeUser_FullMethodName          = "/chat.AdminService/PurgeUser"
+	AdminService_GetPurgeJob_FullMethodName        = "/chat.AdminService/GetPurgeJob"
+)
+
+// AdminServiceClient is the client API for AdminService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type AdminServiceClient interface {
+	// GetTopology reports the server's identity, state and resident sessions
+	GetTopology(ctx context.Context, in *TopologyRequest, opts ...grpc.CallOption) (*TopologyResponse, error)
+	// Drain stops the server from accepting new messages while keeping it up
+	Drain(ctx context.Context, in *DrainRequest, opts ...grpc.CallOption) (*DrainResponse, error)
+	// Decommission drains the server, drops its cache and shuts it down
+	Decommission(ctx context.Context, in *DecommissionRequest, opts ...grpc.CallOption) (*DecommissionResponse, error)
+	// ClearCache empties both cache tiers
+	ClearCache(ctx context.Context, in *ClearCacheRequest, opts ...grpc.CallOption) (*ClearCacheResponse, error)
+	// ReloadConfig applies new runtime configuration values
+	ReloadConfig(ctx context.Context, in *ReloadConfigRequest, opts ...grpc.CallOption) (*ReloadConfigResponse, error)
+	// GetStatsSnapshot returns a point-in-time snapshot of server statistics
+	GetStatsSnapshot(ctx context.Context, in *StatsSnapshotRequest, opts ...grpc.CallOption) (*StatsSnapshot, error)
+	// SubscribeStats streams a stats snapshot at a fixed interval until the
+	// caller cancels or the server shuts down
+	SubscribeStats(ctx context.Context, in *SubscribeStatsRequest, opts ...grpc.CallOption) (AdminService_SubscribeStatsClient, error)
+	// GetRebalanceStatus reports the progress of session migration and
+	// re-replication. Only the metadata leader runs them; other servers
+	// report inactive.
+	GetRebalanceStatus(ctx context.Context, in *RebalanceStatusRequest, opts ...grpc.CallOption) (*RebalanceStatus, error)
+	// SetRebalanceRate changes the bandwidth and sessions-per-second caps
+	// of migration and re-replication
+	SetRebalanceRate(ctx context.Context, in *SetRebalanceRateRequest, opts ...grpc.CallOption) (*RebalanceStatus, error)
+	// GetClusterStats returns the cluster-wide statistics last collected
+	// by the stats aggregator. Only the server running it (the metadata
+	// leader) reports active.
+	GetClusterStats(ctx context.Context, in *ClusterStatsRequest, opts ...grpc.CallOption) (*ClusterStats, error)
+	// GetLogLevel reports the server process's log level
+	GetLogLevel(ctx context.Context, in *GetLogLevelRequest, opts ...grpc.CallOption) (*LogLevel, error)
+	// SetLogLevel changes the server process's log level, for good or for
+	// a while (e.g. debug on one server during an incident)
+	SetLogLevel(ctx context.Context, in *SetLogLevelRequest, opts ...grpc.CallOption) (*LogLevel, error)
+	// QueryAuditLog returns the security and administrative events recorded
+	// by the server, oldest first
+	QueryAuditLog(ctx context.Context, in *AuditLogQuery, opts ...grpc.CallOption) (*AuditLogResponse, error)
+	// GetMetricHistory returns the server's recent metrics, sampled in
+	// memory, e.g. for dashboard sparklines
+	GetMetricHistory(ctx context.Context, in *MetricHistoryRequest, opts ...grpc.CallOption) (*MetricHistory, error)
+	// DebugState dumps the server's state for troubleshooting: resident
+	// sessions, ring view, peer connections, configuration and recent
+	// warnings and errors
+	DebugState(ctx context.Context, in *DebugStateRequest, opts ...grpc.CallOption) (*ServerDebugState, error)
+	// CreateAPIKey issues an API key for a tenant. The key itself is only
+	// returned here.
+	CreateAPIKey(ctx context.Context, in *CreateAPIKeyRequest, opts ...grpc.CallOption) (*CreateAPIKeyResponse, error)
+	// RevokeAPIKey invalidates an API key; calls with it are refused from
+	// then on
+	RevokeAPIKey(ctx context.Context, in *RevokeAPIKeyRequest, opts ...grpc.CallOption) (*APIKey, error)
+	// ListAPIKeys returns the API keys of a tenant, or of all tenants
+	ListAPIKeys(ctx context.Context, in *ListAPIKeysRequest, opts ...grpc.CallOption) (*ListAPIKeysResponse, error)
+	// PurgeUser starts a job deleting a user's messages from every server
+	// of the cluster, or resumes an unfinished one given its ID. It returns
+	// once the job is saved; GetPurgeJob follows it.
+	PurgeUser(ctx context.Context, in *PurgeUserRequest, opts ...grpc.CallOption) (*PurgeJob, error)
+	// GetPurgeJob reports a purge job's progress
+	GetPurgeJob(ctx context.Context, in *GetPurgeJobRequest, opts ...grpc.CallOption) (*PurgeJob, error)
+}
+
+type adminServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAdminServiceClient(cc grpc.ClientConnInterface) AdminServiceClient {
+	return &adminServiceClient{cc}
+}
+
+func (c *adminServiceClient) GetTopology(ctx context.Context, in *TopologyRequest, opts ...grpc.CallOption) (*TopologyResponse, error) {
+	out := new(TopologyResponse)
+	err := c.cc.Invoke(ctx, AdminService_GetTopology_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) Drain(ctx context.Context, in *DrainRequest, opts ...grpc.CallOption) (*DrainResponse, error) {
+	out := new(DrainResponse)
+	err := c.cc.Invoke(ctx, AdminService_Drain_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) Decommission(ctx context.Context, in *DecommissionRequest, opts ...grpc.CallOption) (*DecommissionResponse, error) {
+	out := new(DecommissionResponse)
+	err := c.cc.Invoke(ctx, AdminService_Decommission_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) ClearCache(ctx context.Context, in *ClearCacheRequest, opts ...grpc.CallOption) (*ClearCacheResponse, error) {
+	out := new(ClearCacheResponse)
+	err := c.cc.Invoke(ctx, AdminService_ClearCache_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) ReloadConfig(ctx context.Context, in *ReloadConfigRequest, opts ...grpc.CallOption) (*ReloadConfigResponse, error) {
+	out := new(ReloadConfigResponse)
+	err := c.cc.Invoke(ctx, AdminService_ReloadConfig_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) GetStatsSnapshot(ctx context.Context, in *StatsSnapshotRequest, opts ...grpc.CallOption) (*StatsSnapshot, error) {
+	out := new(StatsSnapshot)
+	err := c.cc.Invoke(ctx, AdminService_GetStatsSnapshot_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) SubscribeStats(ctx context.Context, in *SubscribeStatsRequest, opts ...grpc.CallOption) (AdminService_SubscribeStatsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &AdminService_ServiceDesc.Streams[0], AdminService_SubscribeStats_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &adminServiceSubscribeStatsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type AdminService_SubscribeStatsClient interface {
+	Recv() (*StatsSnapshot, error)
+	grpc.ClientStream
+}
+
+type adminServiceSubscribeStatsClient struct {
+	grpc.ClientStream
+}
+
+func (x *adminServiceSubscribeStatsClient) Recv() (*StatsSnapshot, error) {
+	m := new(StatsSnapshot)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *adminServiceClient) GetRebalanceStatus(ctx context.Context, in *RebalanceStatusRequest, opts ...grpc.CallOption) (*RebalanceStatus, error) {
+	out := new(RebalanceStatus)
+	err := c.cc.Invoke(ctx, AdminService_GetRebalanceStatus_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) SetRebalanceRate(ctx context.Context, in *SetRebalanceRateRequest, opts ...grpc.CallOption) (*RebalanceStatus, error) {
+	out := new(RebalanceStatus)
+	err := c.cc.Invoke(ctx, AdminService_SetRebalanceRate_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) GetClusterStats(ctx context.Context, in *ClusterStatsRequest, opts ...grpc.CallOption) (*ClusterStats, error) {
+	out := new(ClusterStats)
+	err := c.cc.Invoke(ctx, AdminService_GetClusterStats_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) GetLogLevel(ctx context.Context, in *GetLogLevelRequest, opts ...grpc.CallOption) (*LogLevel, error) {
+	out := new(LogLevel)
+	err := c.cc.Invoke(ctx, AdminService_GetLogLevel_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) SetLogLevel(ctx context.Context, in *SetLogLevelRequest, opts ...grpc.CallOption) (*LogLevel, error) {
+	out := new(LogLevel)
+	err := c.cc.Invoke(ctx, AdminService_SetLogLevel_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) QueryAuditLog(ctx context.Context, in *AuditLogQuery, opts ...grpc.CallOption) (*AuditLogResponse, error) {
+	out := new(AuditLogResponse)
+	err := c.cc.Invoke(ctx, AdminService_QueryAuditLog_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) GetMetricHistory(ctx context.Context, in *MetricHistoryRequest, opts ...grpc.CallOption) (*MetricHistory, error) {
+	out := new(MetricHistory)
+	err := c.cc.Invoke(ctx, AdminService_GetMetricHistory_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) DebugState(ctx context.Context, in *DebugStateRequest, opts ...grpc.CallOption) (*ServerDebugState, error) {
+	out := new(ServerDebugState)
+	err := c.cc.Invoke(ctx, AdminService_DebugState_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) CreateAPIKey(ctx context.Context, in *CreateAPIKeyRequest, opts ...grpc.CallOption) (*CreateAPIKeyResponse, error) {
+	out := new(CreateAPIKeyResponse)
+	err := c.cc.Invoke(ctx, AdminService_CreateAPIKey_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) RevokeAPIKey(ctx context.Context, in *RevokeAPIKeyRequest, opts ...grpc.CallOption) (*APIKey, error) {
+	out := new(APIKey)
+	err := c.cc.Invoke(ctx, AdminService_RevokeAPIKey_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) ListAPIKeys(ctx context.Context, in *ListAPIKeysRequest, opts ...grpc.CallOption) (*ListAPIKeysResponse, error) {
+	out := new(ListAPIKeysResponse)
+	err := c.cc.Invoke(ctx, AdminService_ListAPIKeys_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) PurgeUser(ctx context.Context, in *PurgeUserRequest, opts ...grpc.CallOption) (*PurgeJob, error) {
+	out := new(PurgeJob)
+	err := c.cc.Invoke(ctx, AdminService_PurgeUser_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) GetPurgeJob(ctx context.Context, in *GetPurgeJobRequest, opts ...grpc.CallOption) (*PurgeJob, error) {
+	out := new(PurgeJob)
+	err := c.cc.Invoke(ctx, AdminService_GetPurgeJob_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// AdminServiceServer is the server API for AdminService service.
+// All implementations must embed UnimplementedAdminServiceServer
+// for forward compatibility
+type AdminServiceServer interface {
+	// GetTopology reports the server's identity, state and resident sessions
+	GetTopology(context.Context, *TopologyRequest) (*TopologyResponse, error)
+	// Drain stops the server from accepting new messages while keeping it up
+	Drain(context.Context, *DrainRequest) (*DrainResponse, error)
+	// Decommission drains the server, drops its cache and shuts it down
+	Decommission(context.Context, *DecommissionRequest) (*DecommissionResponse, error)
+	// ClearCache empties both cache tiers
+	ClearCache(context.Context, *ClearCacheRequest) (*ClearCacheResponse, error)
+	// ReloadConfig applies new runtime configuration values
+	ReloadConfig(context.Context, *ReloadConfigRequest) (*ReloadConfigResponse, error)
+	// GetStatsSnapshot returns a point-in-time snapshot of server statistics
+	GetStatsSnapshot(context.Context, *StatsSnapshotRequest) (*StatsSnapshot, error)
+	// SubscribeStats streams a stats snapshot at a fixed interval until the
+	// caller cancels or the server shuts down
+	SubscribeStats(*SubscribeStatsRequest, AdminService_SubscribeStatsServer) error
+	// GetRebalanceStatus reports the progress of session migration and
+	// re-replication. Only the metadata leader runs them; other servers
+	// report inactive.
+	GetRebalanceStatus(context.Context, *RebalanceStatusRequest) (*RebalanceStatus, error)
+	// SetRebalanceRate changes the bandwidth and sessions-per-second caps
+	// of migration and re-replication
+	SetRebalanceRate(context.Context, *SetRebalanceRateRequest) (*RebalanceStatus, error)
+	// GetClusterStats returns the cluster-wide statistics last collected
+	// by the stats aggregator. Only the server running it (the metadata
+	// leader) reports active.
+	GetClusterStats(context.Context, *ClusterStatsRequest) (*ClusterStats, error)
+	// GetLogLevel reports the server process's log level
+	GetLogLevel(context.Context, *GetLogLevelRequest) (*LogLevel, error)
+	// SetLogLevel changes the server process's log level, for good or for
+	// a while (e.g. debug on one server during an incident)
+	SetLogLevel(context.Context, *SetLogLevelRequest) (*LogLevel, error)
+	// QueryAuditLog returns the security and administrative events recorded
+	// by the server, oldest first
+	QueryAuditLog(context.Context, *AuditLogQuery) (*AuditLogResponse, error)
+	// GetMetricHistory returns the server's recent metrics, sampled in
+	// memory, e.g. for dashboard sparklines
+	GetMetricHistory(context.Context, *MetricHistoryRequest) (*MetricHistory, error)
+	// DebugState dumps the server's state for troubleshooting: resident
+	// sessions, ring view, peer connections, configuration and recent
+	// warnings and errors
+	DebugState(context.Context, *DebugStateRequest) (*ServerDebugState, error)
+	// CreateAPIKey issues an API key for a tenant. The key itself is only
+	// returned here.
+	CreateAPIKey(context.Context, *CreateAPIKeyRequest) (*CreateAPIKeyResponse, error)
+	// RevokeAPIKey invalidates an API key; calls with it are refused from
+	// then on
+	RevokeAPIKey(context.Context, *RevokeAPIKeyRequest) (*APIKey, error)
+	// ListAPIKeys returns the API keys of a tenant, or of all tenants
+	ListAPIKeys(context.Context, *ListAPIKeysRequest) (*ListAPIKeysResponse, error)
+	// PurgeUser starts a job deleting a user's messages from every server
+	// of the cluster, or resumes an unfinished one given its ID. It returns
+	// once the job is saved; GetPurgeJob follows it.
+	PurgeUser(context.Context, *PurgeUserRequest) (*PurgeJob, error)
+	// GetPurgeJob reports a purge job's progress
+	GetPurgeJob(context.Context, *GetPurgeJobRequest) (*PurgeJob, error)
+	mustEmbedUnimplementedAdminServiceServer()
+}
+
+// UnimplementedAdminServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedAdminServiceServer struct {
+}
+
+func (UnimplementedAdminServiceServer) GetTopology(context.Context, *TopologyRequest) (*TopologyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetTopology not implemented")
+}
+func (UnimplementedAdminServiceServer) Drain(context.Context, *DrainRequest) (*DrainResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Drain not implemented")
+}
+func (UnimplementedAdminServiceServer) Decommission(context.Context, *DecommissionRequest) (*DecommissionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Decommission not implemented")
+}
+func (UnimplementedAdminServiceServer) ClearCache(context.Context, *ClearCacheRequest) (*ClearCacheResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ClearCache not implemented")
+}
+func (UnimplementedAdminServiceServer) ReloadConfig(context.Context, *ReloadConfigRequest) (*ReloadConfigResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReloadConfig not implemented")
+}
+func (UnimplementedAdminServiceServer) GetStatsSnapshot(context.Context, *StatsSnapshotRequest) (*StatsSnapshot, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetStatsSnapshot not implemented")
+}
+func (UnimplementedAdminServiceServer) SubscribeStats(*SubscribeStatsRequest, AdminService_SubscribeStatsServer) error {
+	return status.Errorf(codes.Unimplemented, "method SubscribeStats not implemented")
+}
+func (UnimplementedAdminServiceServer) GetRebalanceStatus(context.Context, *RebalanceStatusRequest) (*RebalanceStatus, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRebalanceStatus not implemented")
+}
+func (UnimplementedAdminServiceServer) SetRebalanceRate(context.Context, *SetRebalanceRateRequest) (*RebalanceStatus, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetRebalanceRate not implemented")
+}
+func (UnimplementedAdminServiceServer) GetClusterStats(context.Context, *ClusterStatsRequest) (*ClusterStats, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetClusterStats not implemented")
+}
+func (UnimplementedAdminServiceServer) GetLogLevel(context.Context, *GetLogLevelRequest) (*LogLevel, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetLogLevel not implemented")
+}
+func (UnimplementedAdminServiceServer) SetLogLevel(context.Context, *SetLogLevelRequest) (*LogLevel, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetLogLevel not implemented")
+}
+func (UnimplementedAdminServiceServer) QueryAuditLog(context.Context, *AuditLogQuery) (*AuditLogResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method QueryAuditLog not implemented")
+}
+func (UnimplementedAdminServiceServer) GetMetricHistory(context.Context, *MetricHistoryRequest) (*MetricHistory, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetMetricHistory not implemented")
+}
+func (UnimplementedAdminServiceServer) DebugState(context.Context, *DebugStateRequest) (*ServerDebugState, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DebugState not implemented")
+}
+func (UnimplementedAdminServiceServer) CreateAPIKey(context.Context, *CreateAPIKeyRequest) (*CreateAPIKeyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateAPIKey not implemented")
+}
+func (UnimplementedAdminServiceServer) RevokeAPIKey(context.Context, *RevokeAPIKeyRequest) (*APIKey, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RevokeAPIKey not implemented")
+}
+func (UnimplementedAdminServiceServer) ListAPIKeys(context.Context, *ListAPIKeysRequest) (*ListAPIKeysResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListAPIKeys not implemented")
+}
+func (UnimplementedAdminServiceServer) PurgeUser(context.Context, *PurgeUserRequest) (*PurgeJob, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PurgeUser not implemented")
+}
+func (UnimplementedAdminServiceServer) GetPurgeJob(context.Context, *GetPurgeJobRequest) (*PurgeJob, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetPurgeJob not implemented")
+}
+func (UnimplementedAdminServiceServer) mustEmbedUnimplementedAdminServiceServer() {}
+
+// UnsafeAdminServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to AdminServiceServer will
+// result in compilation errors.
+type UnsafeAdminServiceServer interface {
+	mustEmbedUnimplementedAdminServiceServer()
+}
+
+func RegisterAdminServiceServer(s grpc.ServiceRegistrar, srv AdminServiceServer) {
+	s.RegisterService(&AdminService_ServiceDesc, srv)
+}
+
+func _AdminService_GetTopology_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TopologyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).GetTopology(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_GetTopology_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).GetTopology(ctx, req.(*TopologyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_Drain_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DrainRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).Drain(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_Drain_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).Drain(ctx, req.(*DrainRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_Decommission_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DecommissionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).Decommission(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_Decommission_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).Decommission(ctx, req.(*DecommissionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_ClearCache_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ClearCacheRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).ClearCache(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_ClearCache_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).ClearCache(ctx, req.(*ClearCacheRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_ReloadConfig_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReloadConfigRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).ReloadConfig(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_ReloadConfig_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).ReloadConfig(ctx, req.(*ReloadConfigRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_GetStatsSnapshot_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StatsSnapshotRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).GetStatsSnapshot(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_GetStatsSnapshot_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).GetStatsSnapshot(ctx, req.(*StatsSnapshotRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_SubscribeStats_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SubscribeStatsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(AdminServiceServer).SubscribeStats(m, &adminServiceSubscribeStatsServer{stream})
+}
+
+type AdminService_SubscribeStatsServer interface {
+	Send(*StatsSnapshot) error
+	grpc.ServerStream
+}
+
+type adminServiceSubscribeStatsServer struct {
+	grpc.ServerStream
+}
+
+func (x *adminServiceSubscribeStatsServer) Send(m *StatsSnapshot) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _AdminService_GetRebalanceStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RebalanceStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).GetRebalanceStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_GetRebalanceStatus_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).GetRebalanceStatus(ctx, req.(*RebalanceStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_SetRebalanceRate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetRebalanceRateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).SetRebalanceRate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_SetRebalanceRate_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).SetRebalanceRate(ctx, req.(*SetRebalanceRateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_GetClusterStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ClusterStatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).GetClusterStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_GetClusterStats_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).GetClusterStats(ctx, req.(*ClusterStatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_GetLogLevel_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetLogLevelRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).GetLogLevel(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_GetLogLevel_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).GetLogLevel(ctx, req.(*GetLogLevelRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_SetLogLevel_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetLogLevelRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).SetLogLevel(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_SetLogLevel_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).SetLogLevel(ctx, req.(*SetLogLevelRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_QueryAuditLog_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AuditLogQuery)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).QueryAuditLog(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_QueryAuditLog_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).QueryAuditLog(ctx, req.(*AuditLogQuery))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_GetMetricHistory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MetricHistoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).GetMetricHistory(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_GetMetricHistory_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).GetMetricHistory(ctx, req.(*MetricHistoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_DebugState_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DebugStateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).DebugState(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_DebugState_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).DebugState(ctx, req.(*DebugStateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_CreateAPIKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateAPIKeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).CreateAPIKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_CreateAPIKey_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).CreateAPIKey(ctx, req.(*CreateAPIKeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_RevokeAPIKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RevokeAPIKeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).RevokeAPIKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_RevokeAPIKey_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).RevokeAPIKey(ctx, req.(*RevokeAPIKeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_ListAPIKeys_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListAPIKeysRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).ListAPIKeys(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_ListAPIKeys_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).ListAPIKeys(ctx, req.(*ListAPIKeysRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_PurgeUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PurgeUserRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).PurgeUser(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_PurgeUser_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).PurgeUser(ctx, req.(*PurgeUserRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_GetPurgeJob_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetPurgeJobRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).GetPurgeJob(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_GetPurgeJob_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).GetPurgeJob(ctx, req.(*GetPurgeJobRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// AdminService_ServiceDesc is the grpc.ServiceDesc for AdminService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var AdminService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "chat.AdminService",
+	HandlerType: (*AdminServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetTopology",
+			Handler:    _AdminService_GetTopology_Handler,
+		},
+		{
+			MethodName: "Drain",
+			Handler:    _AdminService_Drain_Handler,
+		},
+		{
+			MethodName: "Decommission",
+			Handler:    _AdminService_Decommission_Handler,
+		},
+		{
+			MethodName: "ClearCache",
+			Handler:    _AdminService_ClearCache_Handler,
+		},
+		{
+			MethodName: "ReloadConfig",
+			Handler:    _AdminService_ReloadConfig_Handler,
+		},
+		{
+			MethodName: "GetStatsSnapshot",
+			Handler:    _AdminService_GetStatsSnapshot_Handler,
+		},
+		{
+			MethodName: "GetRebalanceStatus",
+			Handler:    _AdminService_GetRebalanceStatus_Handler,
+		},
+		{
+			MethodName: "SetRebalanceRate",
+			Handler:    _AdminService_SetRebalanceRate_Handler,
+		},
+		{
+			MethodName: "GetClusterStats",
+			Handler:    _AdminService_GetClusterStats_Handler,
+		},
+		{
+			MethodName: "GetLogLevel",
+			Handler:    _AdminService_GetLogLevel_Handler,
+		},
+		{
+			MethodName: "SetLogLevel",
+			Handler:    _AdminService_SetLogLevel_Handler,
+		},
+		{
+			MethodName: "QueryAuditLog",
+			Handler:    _AdminService_QueryAuditLog_Handler,
+		},
+		{
+			MethodName: "GetMetricHistory",
+			Handler:    _AdminService_GetMetricHistory_Handler,
+		},
+		{
+			MethodName: "DebugState",
+			Handler:    _AdminService_DebugState_Handler,
+		},
+		{
+			MethodName: "CreateAPIKey",
+			Handler:    _AdminService_CreateAPIKey_Handler,
+		},
+		{
+			MethodName: "RevokeAPIKey",
+			Handler:    _AdminService_RevokeAPIKey_Handler,
+		},
+		{
+			MethodName: "ListAPIKeys",
+			Handler:    _AdminService_ListAPIKeys_Handler,
+		},
+		{
+			MethodName: "PurgeUser",
+			Handler:    _AdminService_PurgeUser_Handler,
+		},
+		{
+			MethodName: "GetPurgeJob",
+			Handler:    _AdminService_GetPurgeJob_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "SubscribeStats",
+			Handler:       _AdminService_SubscribeStats_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "proto/admin.proto",
+}
@@ -19,9 +19,22 @@ import (
 const _ = grpc.SupportPackageIsVersion7
 
 const (
-	ChatService_PostMessage_FullMethodName   = "/chat.ChatService/PostMessage"
-	ChatService_GetCacheStats_FullMethodName = "/chat.ChatService/GetCacheStats"
-	ChatService_HealthCheck_FullMethodName   = "/chat.ChatService/HealthCheck"
+	ChatService_PostMessage_FullMethodName        = "/chat.ChatService/PostMessage"
+	ChatService_GetCacheStats_FullMethodName      = "/chat.ChatService/GetCacheStats"
+	ChatService_HealthCheck_FullMethodName        = "/chat.ChatService/HealthCheck"
+	ChatService_GetRingState_FullMethodName       = "/chat.ChatService/GetRingState"
+	ChatService_WatchTopology_FullMethodName      = "/chat.ChatService/WatchTopology"
+	ChatService_GetHistory_FullMethodName         = "/chat.ChatService/GetHistory"
+	ChatService_Subscribe_FullMethodName          = "/chat.ChatService/Subscribe"
+	ChatService_AckMessages_FullMethodName        = "/chat.ChatService/AckMessages"
+	ChatService_UpdatePresence_FullMethodName     = "/chat.ChatService/UpdatePresence"
+	ChatService_GetPresence_FullMethodName        = "/chat.ChatService/GetPresence"
+	ChatService_WatchPresence_FullMethodName      = "/chat.ChatService/WatchPresence"
+	ChatService_SearchMessages_FullMethodName     = "/chat.ChatService/SearchMessages"
+	ChatService_UploadAttachment_FullMethodName   = "/chat.ChatService/UploadAttachment"
+	ChatService_DownloadAttachment_FullMethodName = "/chat.ChatService/DownloadAttachment"
+	ChatService_Replicate_FullMethodName          = "/chat.ChatService/Replicate"
+	ChatService_AcquireQuota_FullMethodName       = "/chat.ChatService/AcquireQuota"
 )
 
 // ChatServiceClient is the client API for ChatService service.
@@ -34,6 +47,49 @@ type ChatServiceClient interface {
 	GetCacheStats(ctx context.Context, in *StatsRequest, opts ...grpc.CallOption) (*StatsResponse, error)
 	// HealthCheck verifies the server is alive and accepting requests
 	HealthCheck(ctx context.Context, in *HealthRequest, opts ...grpc.CallOption) (*HealthResponse, error)
+	// GetRingState returns the server's view of the hash ring so clients and
+	// peers can detect and repair stale ownership views
+	GetRingState(ctx context.Context, in *RingStateRequest, opts ...grpc.CallOption) (*RingStateResponse, error)
+	// WatchTopology streams the server's ring view whenever it changes, so
+	// clients can follow topology without a coordinator
+	WatchTopology(ctx context.Context, in *WatchTopologyRequest, opts ...grpc.CallOption) (ChatService_WatchTopologyClient, error)
+	// GetHistory returns a chat's messages, merged from the read quorum of
+	// its replicas, or with allow_stale from any one replica's copy
+	GetHistory(ctx context.Context, in *HistoryRequest, opts ...grpc.CallOption) (*HistoryResponse, error)
+	// Subscribe streams a chat's messages as one of its replicas stores
+	// them, after replaying those past after_seq it already holds. A
+	// refused or ended subscription sends one last response carrying the
+	// error code.
+	Subscribe(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (ChatService_SubscribeClient, error)
+	// AckMessages records that a durable subscriber has handled a chat's
+	// messages up to a sequence. The receiving replica passes it on to the
+	// chat's others, so a subscription resumed on any of them is sent again
+	// only what wasn't acknowledged.
+	AckMessages(ctx context.Context, in *AckRequest, opts ...grpc.CallOption) (*AckResponse, error)
+	// UpdatePresence keeps a user online from one source, a client's
+	// heartbeats or a server's subscription streams, or ends the source.
+	// Sent to the server the user ID hashes to, which holds its presence.
+	UpdatePresence(ctx context.Context, in *PresenceUpdate, opts ...grpc.CallOption) (*PresenceResponse, error)
+	// GetPresence returns users' presence, gathered from the servers their
+	// IDs hash to
+	GetPresence(ctx context.Context, in *GetPresenceRequest, opts ...grpc.CallOption) (*PresenceResponse, error)
+	// WatchPresence streams users' presence: their current states, then
+	// each change between online and offline
+	WatchPresence(ctx context.Context, in *WatchPresenceRequest, opts ...grpc.CallOption) (ChatService_WatchPresenceClient, error)
+	// SearchMessages finds the messages matching a full-text query among
+	// those the receiving server holds, best matches first
+	SearchMessages(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error)
+	// UploadAttachment stores content streamed in chunks, the first naming
+	// the chat, and returns the Attachment to post referencing it
+	UploadAttachment(ctx context.Context, opts ...grpc.CallOption) (ChatService_UploadAttachmentClient, error)
+	// DownloadAttachment streams back an uploaded attachment's content
+	DownloadAttachment(ctx context.Context, in *DownloadAttachmentRequest, opts ...grpc.CallOption) (ChatService_DownloadAttachmentClient, error)
+	// Replicate stores a message on a replica. Called by the server
+	// coordinating the write, never by clients.
+	Replicate(ctx context.Context, in *ReplicateRequest, opts ...grpc.CallOption) (*ReplicateResponse, error)
+	// AcquireQuota leases tokens from a sender's rate limit bucket, held by
+	// the server the sender hashes to. Called by servers, never by clients.
+	AcquireQuota(ctx context.Context, in *QuotaRequest, opts ...grpc.CallOption) (*QuotaResponse, error)
 }
 
 type chatServiceClient struct {
@@ -71,6 +127,240 @@ func (c *chatServiceClient) HealthCheck(ctx context.Context, in *HealthRequest,
 	return out, nil
 }
 
+func (c *chatServiceClient) GetRingState(ctx context.Context, in *RingStateRequest, opts ...grpc.CallOption) (*RingStateResponse, error) {
+	out := new(RingStateResponse)
+	err := c.cc.Invoke(ctx, ChatService_GetRingState_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chatServiceClient) WatchTopology(ctx context.Context, in *WatchTopologyRequest, opts ...grpc.CallOption) (ChatService_WatchTopologyClient, error) {
+	stream, err := c.cc.NewStream(ctx, &ChatService_ServiceDesc.Streams[0], ChatService_WatchTopology_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &chatServiceWatchTopologyClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type ChatService_WatchTopologyClient interface {
+	Recv() (*RingState, error)
+	grpc.ClientStream
+}
+
+type chatServiceWatchTopologyClient struct {
+	grpc.ClientStream
+}
+
+func (x *chatServiceWatchTopologyClient) Recv() (*RingState, error) {
+	m := new(RingState)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *chatServiceClient) GetHistory(ctx context.Context, in *HistoryRequest, opts ...grpc.CallOption) (*HistoryResponse, error) {
+	out := new(HistoryResponse)
+	err := c.cc.Invoke(ctx, ChatService_GetHistory_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chatServiceClient) Subscribe(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (ChatService_SubscribeClient, error) {
+	stream, err := c.cc.NewStream(ctx, &ChatService_ServiceDesc.Streams[1], ChatService_Subscribe_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &chatServiceSubscribeClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type ChatService_SubscribeClient interface {
+	Recv() (*SubscribeResponse, error)
+	grpc.ClientStream
+}
+
+type chatServiceSubscribeClient struct {
+	grpc.ClientStream
+}
+
+func (x *chatServiceSubscribeClient) Recv() (*SubscribeResponse, error) {
+	m := new(SubscribeResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *chatServiceClient) AckMessages(ctx context.Context, in *AckRequest, opts ...grpc.CallOption) (*AckResponse, error) {
+	out := new(AckResponse)
+	err := c.cc.Invoke(ctx, ChatService_AckMessages_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chatServiceClient) UpdatePresence(ctx context.Context, in *PresenceUpdate, opts ...grpc.CallOption) (*PresenceResponse, error) {
+	out := new(PresenceResponse)
+	err := c.cc.Invoke(ctx, ChatService_UpdatePresence_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chatServiceClient) GetPresence(ctx context.Context, in *GetPresenceRequest, opts ...grpc.CallOption) (*PresenceResponse, error) {
+	out := new(PresenceResponse)
+	err := c.cc.Invoke(ctx, ChatService_GetPresence_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chatServiceClient) WatchPresence(ctx context.Context, in *WatchPresenceRequest, opts ...grpc.CallOption) (ChatService_WatchPresenceClient, error) {
+	stream, err := c.cc.NewStream(ctx, &ChatService_ServiceDesc.Streams[2], ChatService_WatchPresence_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &chatServiceWatchPresenceClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type ChatService_WatchPresenceClient interface {
+	Recv() (*Presence, error)
+	grpc.ClientStream
+}
+
+type chatServiceWatchPresenceClient struct {
+	grpc.ClientStream
+}
+
+func (x *chatServiceWatchPresenceClient) Recv() (*Presence, error) {
+	m := new(Presence)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *chatServiceClient) SearchMessages(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error) {
+	out := new(SearchResponse)
+	err := c.cc.Invoke(ctx, ChatService_SearchMessages_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chatServiceClient) UploadAttachment(ctx context.Context, opts ...grpc.CallOption) (ChatService_UploadAttachmentClient, error) {
+	stream, err := c.cc.NewStream(ctx, &ChatService_ServiceDesc.Streams[3], ChatService_UploadAttachment_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &chatServiceUploadAttachmentClient{stream}
+	return x, nil
+}
+
+type ChatService_UploadAttachmentClient interface {
+	Send(*AttachmentChunk) error
+	CloseAndRecv() (*UploadAttachmentResponse, error)
+	grpc.ClientStream
+}
+
+type chatServiceUploadAttachmentClient struct {
+	grpc.ClientStream
+}
+
+func (x *chatServiceUploadAttachmentClient) Send(m *AttachmentChunk) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *chatServiceUploadAttachmentClient) CloseAndRecv() (*UploadAttachmentResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(UploadAttachmentResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *chatServiceClient) DownloadAttachment(ctx context.Context, in *DownloadAttachmentRequest, opts ...grpc.CallOption) (ChatService_DownloadAttachmentClient, error) {
+	stream, err := c.cc.NewStream(ctx, &ChatService_ServiceDesc.Streams[4], ChatService_DownloadAttachment_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &chatServiceDownloadAttachmentClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type ChatService_DownloadAttachmentClient interface {
+	Recv() (*AttachmentData, error)
+	grpc.ClientStream
+}
+
+type chatServiceDownloadAttachmentClient struct {
+	grpc.ClientStream
+}
+
+func (x *chatServiceDownloadAttachmentClient) Recv() (*AttachmentData, error) {
+	m := new(AttachmentData)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *chatServiceClient) Replicate(ctx context.Context, in *ReplicateRequest, opts ...grpc.CallOption) (*ReplicateResponse, error) {
+	out := new(ReplicateResponse)
+	err := c.cc.Invoke(ctx, ChatService_Replicate_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chatServiceClient) AcquireQuota(ctx context.Context, in *QuotaRequest, opts ...grpc.CallOption) (*QuotaResponse, error) {
+	out := new(QuotaResponse)
+	err := c.cc.Invoke(ctx, ChatService_AcquireQuota_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // ChatServiceServer is the server API for ChatService service.
 // All implementations must embed UnimplementedChatServiceServer
 // for forward compatibility
@@ -81,6 +371,49 @@ type ChatServiceServer interface {
 	GetCacheStats(context.Context, *StatsRequest) (*StatsResponse, error)
 	// HealthCheck verifies the server is alive and accepting requests
 	HealthCheck(context.Context, *HealthRequest) (*HealthResponse, error)
+	// GetRingState returns the server's view of the hash ring so clients and
+	// peers can detect and repair stale ownership views
+	GetRingState(context.Context, *RingStateRequest) (*RingStateResponse, error)
+	// WatchTopology streams the server's ring view whenever it changes, so
+	// clients can follow topology without a coordinator
+	WatchTopology(*WatchTopologyRequest, ChatService_WatchTopologyServer) error
+	// GetHistory returns a chat's messages, merged from the read quorum of
+	// its replicas, or with allow_stale from any one replica's copy
+	GetHistory(context.Context, *HistoryRequest) (*HistoryResponse, error)
+	// Subscribe streams a chat's messages as one of its replicas stores
+	// them, after replaying those past after_seq it already holds. A
+	// refused or ended subscription sends one last response carrying the
+	// error code.
+	Subscribe(*SubscribeRequest, ChatService_SubscribeServer) error
+	// AckMessages records that a durable subscriber has handled a chat's
+	// messages up to a sequence. The receiving replica passes it on to the
+	// chat's others, so a subscription resumed on any of them is sent again
+	// only what wasn't acknowledged.
+	AckMessages(context.Context, *AckRequest) (*AckResponse, error)
+	// UpdatePresence keeps a user online from one source, a client's
+	// heartbeats or a server's subscription streams, or ends the source.
+	// Sent to the server the user ID hashes to, which holds its presence.
+	UpdatePresence(context.Context, *PresenceUpdate) (*PresenceResponse, error)
+	// GetPresence returns users' presence, gathered from the servers their
+	// IDs hash to
+	GetPresence(context.Context, *GetPresenceRequest) (*PresenceResponse, error)
+	// WatchPresence streams users' presence: their current states, then
+	// each change between online and offline
+	WatchPresence(*WatchPresenceRequest, ChatService_WatchPresenceServer) error
+	// SearchMessages finds the messages matching a full-text query among
+	// those the receiving server holds, best matches first
+	SearchMessages(context.Context, *SearchRequest) (*SearchResponse, error)
+	// UploadAttachment stores content streamed in chunks, the first naming
+	// the chat, and returns the Attachment to post referencing it
+	UploadAttachment(ChatService_UploadAttachmentServer) error
+	// DownloadAttachment streams back an uploaded attachment's content
+	DownloadAttachment(*DownloadAttachmentRequest, ChatService_DownloadAttachmentServer) error
+	// Replicate stores a message on a replica. Called by the server
+	// coordinating the write, never by clients.
+	Replicate(context.Context, *ReplicateRequest) (*ReplicateResponse, error)
+	// AcquireQuota leases tokens from a sender's rate limit bucket, held by
+	// the server the sender hashes to. Called by servers, never by clients.
+	AcquireQuota(context.Context, *QuotaRequest) (*QuotaResponse, error)
 	mustEmbedUnimplementedChatServiceServer()
 }
 
@@ -97,6 +430,45 @@ func (UnimplementedChatServiceServer) GetCacheStats(context.Context, *StatsReque
 func (UnimplementedChatServiceServer) HealthCheck(context.Context, *HealthRequest) (*HealthResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method HealthCheck not implemented")
 }
+func (UnimplementedChatServiceServer) GetRingState(context.Context, *RingStateRequest) (*RingStateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRingState not implemented")
+}
+func (UnimplementedChatServiceServer) WatchTopology(*WatchTopologyRequest, ChatService_WatchTopologyServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchTopology not implemented")
+}
+func (UnimplementedChatServiceServer) GetHistory(context.Context, *HistoryRequest) (*HistoryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetHistory not implemented")
+}
+func (UnimplementedChatServiceServer) Subscribe(*SubscribeRequest, ChatService_SubscribeServer) error {
+	return status.Errorf(codes.Unimplemented, "method Subscribe not implemented")
+}
+func (UnimplementedChatServiceServer) AckMessages(context.Context, *AckRequest) (*AckResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AckMessages not implemented")
+}
+func (UnimplementedChatServiceServer) UpdatePresence(context.Context, *PresenceUpdate) (*PresenceResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdatePresence not implemented")
+}
+func (UnimplementedChatServiceServer) GetPresence(context.Context, *GetPresenceRequest) (*PresenceResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetPresence not implemented")
+}
+func (UnimplementedChatServiceServer) WatchPresence(*WatchPresenceRequest, ChatService_WatchPresenceServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchPresence not implemented")
+}
+func (UnimplementedChatServiceServer) SearchMessages(context.Context, *SearchRequest) (*SearchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SearchMessages not implemented")
+}
+func (UnimplementedChatServiceServer) UploadAttachment(ChatService_UploadAttachmentServer) error {
+	return status.Errorf(codes.Unimplemented, "method UploadAttachment not implemented")
+}
+func (UnimplementedChatServiceServer) DownloadAttachment(*DownloadAttachmentRequest, ChatService_DownloadAttachmentServer) error {
+	return status.Errorf(codes.Unimplemented, "method DownloadAttachment not implemented")
+}
+func (UnimplementedChatServiceServer) Replicate(context.Context, *ReplicateRequest) (*ReplicateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Replicate not implemented")
+}
+func (UnimplementedChatServiceServer) AcquireQuota(context.Context, *QuotaRequest) (*QuotaResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AcquireQuota not implemented")
+}
 func (UnimplementedChatServiceServer) mustEmbedUnimplementedChatServiceServer() {}
 
 // UnsafeChatServiceServer may be embedded to opt out of forward compatibility for this service.
@@ -164,6 +536,260 @@ func _ChatService_HealthCheck_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _ChatService_GetRingState_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RingStateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChatServiceServer).GetRingState(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChatService_GetRingState_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChatServiceServer).GetRingState(ctx, req.(*RingStateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ChatService_WatchTopology_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchTopologyRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ChatServiceServer).WatchTopology(m, &chatServiceWatchTopologyServer{stream})
+}
+
+type ChatService_WatchTopologyServer interface {
+	Send(*RingState) error
+	grpc.ServerStream
+}
+
+type chatServiceWatchTopologyServer struct {
+	grpc.ServerStream
+}
+
+func (x *chatServiceWatchTopologyServer) Send(m *RingState) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _ChatService_GetHistory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HistoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChatServiceServer).GetHistory(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChatService_GetHistory_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChatServiceServer).GetHistory(ctx, req.(*HistoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ChatService_Subscribe_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SubscribeRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ChatServiceServer).Subscribe(m, &chatServiceSubscribeServer{stream})
+}
+
+type ChatService_SubscribeServer interface {
+	Send(*SubscribeResponse) error
+	grpc.ServerStream
+}
+
+type chatServiceSubscribeServer struct {
+	grpc.ServerStream
+}
+
+func (x *chatServiceSubscribeServer) Send(m *SubscribeResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _ChatService_AckMessages_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AckRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChatServiceServer).AckMessages(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChatService_AckMessages_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChatServiceServer).AckMessages(ctx, req.(*AckRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ChatService_UpdatePresence_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PresenceUpdate)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChatServiceServer).UpdatePresence(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChatService_UpdatePresence_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChatServiceServer).UpdatePresence(ctx, req.(*PresenceUpdate))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ChatService_GetPresence_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetPresenceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChatServiceServer).GetPresence(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChatService_GetPresence_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChatServiceServer).GetPresence(ctx, req.(*GetPresenceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ChatService_WatchPresence_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchPresenceRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ChatServiceServer).WatchPresence(m, &chatServiceWatchPresenceServer{stream})
+}
+
+type ChatService_WatchPresenceServer interface {
+	Send(*Presence) error
+	grpc.ServerStream
+}
+
+type chatServiceWatchPresenceServer struct {
+	grpc.ServerStream
+}
+
+func (x *chatServiceWatchPresenceServer) Send(m *Presence) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _ChatService_SearchMessages_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChatServiceServer).SearchMessages(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChatService_SearchMessages_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChatServiceServer).SearchMessages(ctx, req.(*SearchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ChatService_UploadAttachment_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(ChatServiceServer).UploadAttachment(&chatServiceUploadAttachmentServer{stream})
+}
+
+type ChatService_UploadAttachmentServer interface {
+	SendAndClose(*UploadAttachmentResponse) error
+	Recv() (*AttachmentChunk, error)
+	grpc.ServerStream
+}
+
+type chatServiceUploadAttachmentServer struct {
+	grpc.ServerStream
+}
+
+func (x *chatServiceUploadAttachmentServer) SendAndClose(m *UploadAttachmentResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *chatServiceUploadAttachmentServer) Recv() (*AttachmentChunk, error) {
+	m := new(AttachmentChunk)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _ChatService_DownloadAttachment_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(DownloadAttachmentRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ChatServiceServer).DownloadAttachment(m, &chatServiceDownloadAttachmentServer{stream})
+}
+
+type ChatService_DownloadAttachmentServer interface {
+	Send(*AttachmentData) error
+	grpc.ServerStream
+}
+
+type chatServiceDownloadAttachmentServer struct {
+	grpc.ServerStream
+}
+
+func (x *chatServiceDownloadAttachmentServer) Send(m *AttachmentData) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _ChatService_Replicate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReplicateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChatServiceServer).Replicate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChatService_Replicate_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChatServiceServer).Replicate(ctx, req.(*ReplicateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ChatService_AcquireQuota_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(QuotaRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChatServiceServer).AcquireQuota(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChatService_AcquireQuota_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChatServiceServer).AcquireQuota(ctx, req.(*QuotaRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // ChatService_ServiceDesc is the grpc.ServiceDesc for ChatService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -183,7 +809,65 @@ var ChatService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "HealthCheck",
 			Handler:    _ChatService_HealthCheck_Handler,
 		},
+		{
+			MethodName: "GetRingState",
+			Handler:    _ChatService_GetRingState_Handler,
+		},
+		{
+			MethodName: "GetHistory",
+			Handler:    _ChatService_GetHistory_Handler,
+		},
+		{
+			MethodName: "AckMessages",
+			Handler:    _ChatService_AckMessages_Handler,
+		},
+		{
+			MethodName: "UpdatePresence",
+			Handler:    _ChatService_UpdatePresence_Handler,
+		},
+		{
+			MethodName: "GetPresence",
+			Handler:    _ChatService_GetPresence_Handler,
+		},
+		{
+			MethodName: "SearchMessages",
+			Handler:    _ChatService_SearchMessages_Handler,
+		},
+		{
+			MethodName: "Replicate",
+			Handler:    _ChatService_Replicate_Handler,
+		},
+		{
+			MethodName: "AcquireQuota",
+			Handler:    _ChatService_AcquireQuota_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchTopology",
+			Handler:       _ChatService_WatchTopology_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Subscribe",
+			Handler:       _ChatService_Subscribe_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "WatchPresence",
+			Handler:       _ChatService_WatchPresence_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "UploadAttachment",
+			Handler:       _ChatService_UploadAttachment_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "DownloadAttachment",
+			Handler:       _ChatService_DownloadAttachment_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "proto/chat.proto",
 }
@@ -0,0 +1,342 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             v4.25.1
+// source: proto/coordinator.proto
+
+package proto
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	CoordinatorService_Register_FullMethodName      = "/chat.CoordinatorService/Register"
+	CoordinatorService_Deregister_FullMethodName    = "/chat.CoordinatorService/Deregister"
+	CoordinatorService_Heartbeat_FullMethodName     = "/chat.CoordinatorService/Heartbeat"
+	CoordinatorService_GetRing_FullMethodName       = "/chat.CoordinatorService/GetRing"
+	CoordinatorService_WatchTopology_FullMethodName = "/chat.CoordinatorService/WatchTopology"
+	CoordinatorService_LocateChats_FullMethodName   = "/chat.CoordinatorService/LocateChats"
+)
+
+// CoordinatorServiceClient is the client API for CoordinatorService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type CoordinatorServiceClient interface {
+	// Register adds (or refreshes) a server in the authoritative ring
+	Register(ctx context.Context, in *RegisterRequest, opts ...grpc.CallOption) (*RegisterResponse, error)
+	// Deregister removes a server from the authoritative ring
+	Deregister(ctx context.Context, in *DeregisterRequest, opts ...grpc.CallOption) (*DeregisterResponse, error)
+	// Heartbeat reports that a registered server is alive and renews its
+	// ownership lease
+	Heartbeat(ctx context.Context, in *HeartbeatRequest, opts ...grpc.CallOption) (*HeartbeatResponse, error)
+	// GetRing returns the authoritative ring (or an in-sync acknowledgement)
+	GetRing(ctx context.Context, in *RingStateRequest, opts ...grpc.CallOption) (*RingStateResponse, error)
+	// WatchTopology streams the ring whenever it changes, starting with the
+	// current ring if the caller's known epoch is older
+	WatchTopology(ctx context.Context, in *WatchTopologyRequest, opts ...grpc.CallOption) (CoordinatorService_WatchTopologyClient, error)
+	// LocateChats looks chats up in the directory: each chat's owner and
+	// replicas in every region under the authoritative ring, and the
+	// previous owner while a migration is still moving it
+	LocateChats(ctx context.Context, in *LocateChatsRequest, opts ...grpc.CallOption) (*LocateChatsResponse, error)
+}
+
+type coordinatorServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewCoordinatorServiceClient(cc grpc.ClientConnInterface) CoordinatorServiceClient {
+	return &coordinatorServiceClient{cc}
+}
+
+func (c *coordinatorServiceClient) Register(ctx context.Context, in *RegisterRequest, opts ...grpc.CallOption) (*RegisterResponse, error) {
+	out := new(RegisterResponse)
+	err := c.cc.Invoke(ctx, CoordinatorService_Register_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *coordinatorServiceClient) Deregister(ctx context.Context, in *DeregisterRequest, opts ...grpc.CallOption) (*DeregisterResponse, error) {
+	out := new(DeregisterResponse)
+	err := c.cc.Invoke(ctx, CoordinatorService_Deregister_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *coordinatorServiceClient) Heartbeat(ctx context.Context, in *HeartbeatRequest, opts ...grpc.CallOption) (*HeartbeatResponse, error) {
+	out := new(HeartbeatResponse)
+	err := c.cc.Invoke(ctx, CoordinatorService_Heartbeat_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *coordinatorServiceClient) GetRing(ctx context.Context, in *RingStateRequest, opts ...grpc.CallOption) (*RingStateResponse, error) {
+	out := new(RingStateResponse)
+	err := c.cc.Invoke(ctx, CoordinatorService_GetRing_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *coordinatorServiceClient) WatchTopology(ctx context.Context, in *WatchTopologyRequest, opts ...grpc.CallOption) (CoordinatorService_WatchTopologyClient, error) {
+	stream, err := c.cc.NewStream(ctx, &CoordinatorService_ServiceDesc.Streams[0], CoordinatorService_WatchTopology_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &coordinatorServiceWatchTopologyClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type CoordinatorService_WatchTopologyClient interface {
+	Recv() (*RingState, error)
+	grpc.ClientStream
+}
+
+type coordinatorServiceWatchTopologyClient struct {
+	grpc.ClientStream
+}
+
+func (x *coordinatorServiceWatchTopologyClient) Recv() (*RingState, error) {
+	m := new(RingState)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *coordinatorServiceClient) LocateChats(ctx context.Context, in *LocateChatsRequest, opts ...grpc.CallOption) (*LocateChatsResponse, error) {
+	out := new(LocateChatsResponse)
+	err := c.cc.Invoke(ctx, CoordinatorService_LocateChats_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CoordinatorServiceServer is the server API for CoordinatorService service.
+// All implementations must embed UnimplementedCoordinatorServiceServer
+// for forward compatibility
+type CoordinatorServiceServer interface {
+	// Register adds (or refreshes) a server in the authoritative ring
+	Register(context.Context, *RegisterRequest) (*RegisterResponse, error)
+	// Deregister removes a server from the authoritative ring
+	Deregister(context.Context, *DeregisterRequest) (*DeregisterResponse, error)
+	// Heartbeat reports that a registered server is alive and renews its
+	// ownership lease
+	Heartbeat(context.Context, *HeartbeatRequest) (*HeartbeatResponse, error)
+	// GetRing returns the authoritative ring (or an in-sync acknowledgement)
+	GetRing(context.Context, *RingStateRequest) (*RingStateResponse, error)
+	// WatchTopology streams the ring whenever it changes, starting with the
+	// current ring if the caller's known epoch is older
+	WatchTopology(*WatchTopologyRequest, CoordinatorService_WatchTopologyServer) error
+	// LocateChats looks chats up in the directory: each chat's owner and
+	// replicas in every region under the authoritative ring, and the
+	// previous owner while a migration is still moving it
+	LocateChats(context.Context, *LocateChatsRequest) (*LocateChatsResponse, error)
+	mustEmbedUnimplementedCoordinatorServiceServer()
+}
+
+// UnimplementedCoordinatorServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedCoordinatorServiceServer struct {
+}
+
+func (UnimplementedCoordinatorServiceServer) Register(context.Context, *RegisterRequest) (*RegisterResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Register not implemented")
+}
+func (UnimplementedCoordinatorServiceServer) Deregister(context.Context, *DeregisterRequest) (*DeregisterResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Deregister not implemented")
+}
+func (UnimplementedCoordinatorServiceServer) Heartbeat(context.Context, *HeartbeatRequest) (*HeartbeatResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Heartbeat not implemented")
+}
+func (UnimplementedCoordinatorServiceServer) GetRing(context.Context, *RingStateRequest) (*RingStateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRing not implemented")
+}
+func (UnimplementedCoordinatorServiceServer) WatchTopology(*WatchTopologyRequest, CoordinatorService_WatchTopologyServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchTopology not implemented")
+}
+func (UnimplementedCoordinatorServiceServer) LocateChats(context.Context, *LocateChatsRequest) (*LocateChatsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method LocateChats not implemented")
+}
+func (UnimplementedCoordinatorServiceServer) mustEmbedUnimplementedCoordinatorServiceServer() {}
+
+// UnsafeCoordinatorServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to CoordinatorServiceServer will
+// result in compilation errors.
+type UnsafeCoordinatorServiceServer interface {
+	mustEmbedUnimplementedCoordinatorServiceServer()
+}
+
+func RegisterCoordinatorServiceServer(s grpc.ServiceRegistrar, srv CoordinatorServiceServer) {
+	s.RegisterService(&CoordinatorService_ServiceDesc, srv)
+}
+
+func _CoordinatorService_Register_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RegisterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CoordinatorServiceServer).Register(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CoordinatorService_Register_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CoordinatorServiceServer).Register(ctx, req.(*RegisterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CoordinatorService_Deregister_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeregisterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CoordinatorServiceServer).Deregister(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CoordinatorService_Deregister_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CoordinatorServiceServer).Deregister(ctx, req.(*DeregisterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CoordinatorService_Heartbeat_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HeartbeatRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CoordinatorServiceServer).Heartbeat(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CoordinatorService_Heartbeat_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CoordinatorServiceServer).Heartbeat(ctx, req.(*HeartbeatRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CoordinatorService_GetRing_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RingStateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CoordinatorServiceServer).GetRing(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CoordinatorService_GetRing_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CoordinatorServiceServer).GetRing(ctx, req.(*RingStateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CoordinatorService_WatchTopology_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchTopologyRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(CoordinatorServiceServer).WatchTopology(m, &coordinatorServiceWatchTopologyServer{stream})
+}
+
+type CoordinatorService_WatchTopologyServer interface {
+	Send(*RingState) error
+	grpc.ServerStream
+}
+
+type coordinatorServiceWatchTopologyServer struct {
+	grpc.ServerStream
+}
+
+func (x *coordinatorServiceWatchTopologyServer) Send(m *RingState) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _CoordinatorService_LocateChats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LocateChatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CoordinatorServiceServer).LocateChats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CoordinatorService_LocateChats_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CoordinatorServiceServer).LocateChats(ctx, req.(*LocateChatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// CoordinatorService_ServiceDesc is the grpc.ServiceDesc for CoordinatorService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var CoordinatorService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "chat.CoordinatorService",
+	HandlerType: (*CoordinatorServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Register",
+			Handler:    _CoordinatorService_Register_Handler,
+		},
+		{
+			MethodName: "Deregister",
+			Handler:    _CoordinatorService_Deregister_Handler,
+		},
+		{
+			MethodName: "Heartbeat",
+			Handler:    _CoordinatorService_Heartbeat_Handler,
+		},
+		{
+			MethodName: "GetRing",
+			Handler:    _CoordinatorService_GetRing_Handler,
+		},
+		{
+			MethodName: "LocateChats",
+			Handler:    _CoordinatorService_LocateChats_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchTopology",
+			Handler:       _CoordinatorService_WatchTopology_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "proto/coordinator.proto",
+}
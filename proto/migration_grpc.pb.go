@@ -0,0 +1,213 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             v4.25.1
+// source: proto/migration.proto
+
+package proto
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	MigrationService_ExportSession_FullMethodName = "/chat.MigrationService/ExportSession"
+	MigrationService_ImportSession_FullMethodName = "/chat.MigrationService/ImportSession"
+)
+
+// MigrationServiceClient is the client API for MigrationService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type MigrationServiceClient interface {
+	// ExportSession streams the sessions held by the server whose chat IDs
+	// hash into any of the requested ranges
+	ExportSession(ctx context.Context, in *ExportSessionRequest, opts ...grpc.CallOption) (MigrationService_ExportSessionClient, error)
+	// ImportSession merges sessions streamed from their previous owner
+	ImportSession(ctx context.Context, opts ...grpc.CallOption) (MigrationService_ImportSessionClient, error)
+}
+
+type migrationServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewMigrationServiceClient(cc grpc.ClientConnInterface) MigrationServiceClient {
+	return &migrationServiceClient{cc}
+}
+
+func (c *migrationServiceClient) ExportSession(ctx context.Context, in *ExportSessionRequest, opts ...grpc.CallOption) (MigrationService_ExportSessionClient, error) {
+	stream, err := c.cc.NewStream(ctx, &MigrationService_ServiceDesc.Streams[0], MigrationService_ExportSession_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &migrationServiceExportSessionClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type MigrationService_ExportSessionClient interface {
+	Recv() (*SessionSnapshot, error)
+	grpc.ClientStream
+}
+
+type migrationServiceExportSessionClient struct {
+	grpc.ClientStream
+}
+
+func (x *migrationServiceExportSessionClient) Recv() (*SessionSnapshot, error) {
+	m := new(SessionSnapshot)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *migrationServiceClient) ImportSession(ctx context.Context, opts ...grpc.CallOption) (MigrationService_ImportSessionClient, error) {
+	stream, err := c.cc.NewStream(ctx, &MigrationService_ServiceDesc.Streams[1], MigrationService_ImportSession_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &migrationServiceImportSessionClient{stream}
+	return x, nil
+}
+
+type MigrationService_ImportSessionClient interface {
+	Send(*SessionSnapshot) error
+	CloseAndRecv() (*ImportSessionResponse, error)
+	grpc.ClientStream
+}
+
+type migrationServiceImportSessionClient struct {
+	grpc.ClientStream
+}
+
+func (x *migrationServiceImportSessionClient) Send(m *SessionSnapshot) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *migrationServiceImportSessionClient) CloseAndRecv() (*ImportSessionResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(ImportSessionResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// MigrationServiceServer is the server API for MigrationService service.
+// All implementations must embed UnimplementedMigrationServiceServer
+// for forward compatibility
+type MigrationServiceServer interface {
+	// ExportSession streams the sessions held by the server whose chat IDs
+	// hash into any of the requested ranges
+	ExportSession(*ExportSessionRequest, MigrationService_ExportSessionServer) error
+	// ImportSession merges sessions streamed from their previous owner
+	ImportSession(MigrationService_ImportSessionServer) error
+	mustEmbedUnimplementedMigrationServiceServer()
+}
+
+// UnimplementedMigrationServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedMigrationServiceServer struct {
+}
+
+func (UnimplementedMigrationServiceServer) ExportSession(*ExportSessionRequest, MigrationService_ExportSessionServer) error {
+	return status.Errorf(codes.Unimplemented, "method ExportSession not implemented")
+}
+func (UnimplementedMigrationServiceServer) ImportSession(MigrationService_ImportSessionServer) error {
+	return status.Errorf(codes.Unimplemented, "method ImportSession not implemented")
+}
+func (UnimplementedMigrationServiceServer) mustEmbedUnimplementedMigrationServiceServer() {}
+
+// UnsafeMigrationServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to MigrationServiceServer will
+// result in compilation errors.
+type UnsafeMigrationServiceServer interface {
+	mustEmbedUnimplementedMigrationServiceServer()
+}
+
+func RegisterMigrationServiceServer(s grpc.ServiceRegistrar, srv MigrationServiceServer) {
+	s.RegisterService(&MigrationService_ServiceDesc, srv)
+}
+
+func _MigrationService_ExportSession_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ExportSessionRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(MigrationServiceServer).ExportSession(m, &migrationServiceExportSessionServer{stream})
+}
+
+type MigrationService_ExportSessionServer interface {
+	Send(*SessionSnapshot) error
+	grpc.ServerStream
+}
+
+type migrationServiceExportSessionServer struct {
+	grpc.ServerStream
+}
+
+func (x *migrationServiceExportSessionServer) Send(m *SessionSnapshot) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _MigrationService_ImportSession_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(MigrationServiceServer).ImportSession(&migrationServiceImportSessionServer{stream})
+}
+
+type MigrationService_ImportSessionServer interface {
+	SendAndClose(*ImportSessionResponse) error
+	Recv() (*SessionSnapshot, error)
+	grpc.ServerStream
+}
+
+type migrationServiceImportSessionServer struct {
+	grpc.ServerStream
+}
+
+func (x *migrationServiceImportSessionServer) SendAndClose(m *ImportSessionResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *migrationServiceImportSessionServer) Recv() (*SessionSnapshot, error) {
+	m := new(SessionSnapshot)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// MigrationService_ServiceDesc is the grpc.ServiceDesc for MigrationService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var MigrationService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "chat.MigrationService",
+	HandlerType: (*MigrationServiceServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ExportSession",
+			Handler:       _MigrationService_ExportSession_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "ImportSession",
+			Handler:       _MigrationService_ImportSession_Handler,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "proto/migration.proto",
+}
@@ -0,0 +1,55 @@
+package proto
+
+import "github.com/sh4shv4t/DistriChat/pkg/ring"
+
+// NewRingState converts a ring state to its wire representation
+func NewRingState(state ring.RingState) *RingState {
+	nodes := make([]*RingNode, 0, len(state.Nodes))
+	for _, node := range state.Nodes {
+		nodes = append(nodes, &RingNode{
+			NodeId:    node.NodeID,
+			Address:   node.Address,
+			Weight:    int32(node.Capacity),
+			Region:    node.Region,
+			Namespace: node.Namespace,
+		})
+	}
+	return &RingState{
+		Epoch:  state.Epoch,
+		Nodes:  nodes,
+		Digest: state.Digest(),
+	}
+}
+
+// ToRing converts a wire ring state back to the ring package's form
+func (x *RingState) ToRing() ring.RingState {
+	nodes := make([]ring.NodeSpec, 0, len(x.GetNodes()))
+	for _, node := range x.GetNodes() {
+		nodes = append(nodes, ring.NodeSpec{
+			NodeID:    node.NodeId,
+			Address:   node.Address,
+			Capacity:  int(node.Weight),
+			Region:    node.Region,
+			Namespace: node.Namespace,
+		})
+	}
+	return ring.RingState{Epoch: x.GetEpoch(), Nodes: nodes}
+}
+
+// NewHashRanges converts ring ranges to their wire representation
+func NewHashRanges(ranges []ring.HashRange) []*HashRange {
+	out := make([]*HashRange, 0, len(ranges))
+	for _, r := range ranges {
+		out = append(out, &HashRange{Start: r.Start, End: r.End})
+	}
+	return out
+}
+
+// RingRanges converts wire ranges back to the ring package's form
+func RingRanges(ranges []*HashRange) []ring.HashRange {
+	out := make([]ring.HashRange, 0, len(ranges))
+	for _, r := range ranges {
+		out = append(out, ring.HashRange{Start: r.GetStart(), End: r.GetEnd()})
+	}
+	return out
+}